@@ -0,0 +1,103 @@
+// Package annotate lists the refs on each line of a file, for rendering
+// source code with links to the defs that it refers to and for checking
+// which parts of a file a grapher covers.
+package annotate
+
+import (
+	"bytes"
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A Line is a line of a file and the refs that start on it.
+type Line struct {
+	// Number is the 1-based line number.
+	Number int
+
+	// Text is the line's contents, without its line ending.
+	Text string
+
+	// Annotations are the refs that start on the line, sorted by position.
+	Annotations []*Annotation `json:",omitempty"`
+}
+
+// An Annotation is a ref on a line.
+type Annotation struct {
+	// StartCol and EndCol are the byte offsets of the ref in the line's
+	// Text. If the ref continues on the next line, EndCol is the end of
+	// Text.
+	StartCol int
+	EndCol   int
+
+	// The def that the ref points to.
+	graph.RefDefKey
+
+	// Def is whether the ref is the def's definition.
+	Def bool `json:",omitempty"`
+
+	// Kind is how the ref uses the def, if known.
+	Kind graph.RefKind `json:",omitempty"`
+}
+
+// Lines returns the lines of a file whose contents are src, annotated with
+// refs (which are in the file). Refs that are empty or not within src are
+// skipped; Lines returns how many were, since that usually means that the
+// file has changed since it was graphed.
+func Lines(src []byte, refs []*graph.Ref) (lines []*Line, skipped int) {
+	var starts []int // the byte offset of each line
+	for start := 0; start < len(src); {
+		starts = append(starts, start)
+		end := bytes.IndexByte(src[start:], '\n')
+		if end == -1 {
+			end = len(src)
+		} else {
+			end += start
+		}
+		text := src[start:end]
+		if len(text) > 0 && text[len(text)-1] == '\r' {
+			text = text[:len(text)-1]
+		}
+		lines = append(lines, &Line{Number: len(lines) + 1, Text: string(text)})
+		start = end + 1
+	}
+
+	for _, ref := range refs {
+		if ref.Start < 0 || ref.Start >= ref.End || ref.End > len(src) {
+			skipped++
+			continue
+		}
+		i := sort.Search(len(starts), func(i int) bool { return starts[i] > ref.Start }) - 1
+		line := lines[i]
+		a := &Annotation{
+			StartCol:  ref.Start - starts[i],
+			EndCol:    ref.End - starts[i],
+			RefDefKey: graph.RefDefKey{DefRepo: ref.DefRepo, DefUnitType: ref.DefUnitType, DefUnit: ref.DefUnit, DefPath: ref.DefPath},
+			Def:       ref.Def,
+			Kind:      ref.Kind,
+		}
+		if a.StartCol > len(line.Text) {
+			a.StartCol = len(line.Text)
+		}
+		if a.EndCol > len(line.Text) {
+			a.EndCol = len(line.Text)
+		}
+		line.Annotations = append(line.Annotations, a)
+	}
+
+	for _, line := range lines {
+		sort.Sort(byCol(line.Annotations))
+	}
+	return lines, skipped
+}
+
+type byCol []*Annotation
+
+func (v byCol) Len() int      { return len(v) }
+func (v byCol) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v byCol) Less(i, j int) bool {
+	if v[i].StartCol != v[j].StartCol {
+		return v[i].StartCol < v[j].StartCol
+	}
+	return v[i].EndCol < v[j].EndCol
+}
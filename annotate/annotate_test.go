@@ -0,0 +1,51 @@
+package annotate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestLines(t *testing.T) {
+	src := "package p\r\n\nfunc f() {\n\tg()\n}"
+	ref := func(context string, length int, path string, isDef bool) *graph.Ref {
+		start := strings.Index(src, context)
+		return &graph.Ref{DefPath: graph.DefPath(path), Start: start, End: start + length, Def: isDef}
+	}
+	refs := []*graph.Ref{
+		ref("g()", 1, "g", false),
+		ref("f()", 1, "f", true),
+		ref("func", 14, "f", false), // continues on the next line
+		ref("p\r", 1, "", true),
+		{Start: 3, End: 3},         // empty
+		{Start: 30, End: 100},      // out of range
+		{Start: -1, End: len(src)}, // out of range
+	}
+
+	lines, skipped := Lines([]byte(src), refs)
+	key := func(path string) graph.RefDefKey { return graph.RefDefKey{DefPath: graph.DefPath(path)} }
+	want := []*Line{
+		{Number: 1, Text: "package p", Annotations: []*Annotation{{StartCol: 8, EndCol: 9, RefDefKey: key(""), Def: true}}},
+		{Number: 2, Text: ""},
+		{Number: 3, Text: "func f() {", Annotations: []*Annotation{
+			{StartCol: 0, EndCol: 10, RefDefKey: key("f")},
+			{StartCol: 5, EndCol: 6, RefDefKey: key("f"), Def: true},
+		}},
+		{Number: 4, Text: "\tg()", Annotations: []*Annotation{{StartCol: 1, EndCol: 2, RefDefKey: key("g")}}},
+		{Number: 5, Text: "}"},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		for _, line := range lines {
+			t.Logf("%+v", line)
+			for _, a := range line.Annotations {
+				t.Logf("  %+v", a)
+			}
+		}
+		t.Errorf("got lines above, want %d lines", len(want))
+	}
+	if skipped != 3 {
+		t.Errorf("got %d skipped, want 3", skipped)
+	}
+}
@@ -0,0 +1,133 @@
+// Package apisurface extracts the public API surface of a source unit: its
+// exported defs, with their signatures and docs. The API surface is much
+// smaller than the full graph output, so it is suitable for documentation
+// pipelines and for reviewing API changes.
+package apisurface
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// START Def OMIT
+// A Def is an exported def in an API surface.
+type Def struct {
+	Path graph.DefPath
+	Name string
+	Kind graph.DefKind `json:",omitempty"`
+
+	// Signature is the def's declaration, such as "func F(a int) error" for
+	// a Go func. If the def's toolchain has registered a def formatter, it is
+	// used to format the signature; otherwise the first line of the def's
+	// source text is used.
+	Signature string `json:",omitempty"`
+
+	// Doc and DocFormat are the def's documentation and its MIME type (plain
+	// text is preferred if the grapher emitted docs in several formats).
+	Doc       string `json:",omitempty"`
+	DocFormat string `json:",omitempty"`
+
+	File string `json:",omitempty"`
+}
+
+// END Def OMIT
+
+// A Surface is the API surface of a source unit.
+type Surface struct {
+	UnitType string
+	Unit     string
+	Defs     []*Def
+}
+
+// maxSignatureLen is the maximum length of a signature taken from source
+// text.
+const maxSignatureLen = 200
+
+// Extract returns the API surface of source unit u, whose graph output is o.
+// The readFile func returns the contents of a file in the source unit; it is
+// used to get signatures from the defs' source text. If readFile is nil or
+// returns an error, defs without a def formatter have no signature.
+func Extract(u *unit.SourceUnit, o *grapher.Output, readFile func(file string) ([]byte, error)) *Surface {
+	docs := make(map[graph.DefPath]*graph.Doc, len(o.Docs))
+	for _, doc := range o.Docs {
+		if d, present := docs[doc.Path]; !present || (d.Format != "text/plain" && doc.Format == "text/plain") {
+			docs[doc.Path] = doc
+		}
+	}
+
+	files := make(map[string][]byte)
+	source := func(file string) []byte {
+		if readFile == nil || file == "" {
+			return nil
+		}
+		if data, present := files[file]; present {
+			return data
+		}
+		data, err := readFile(file)
+		if err != nil {
+			data = nil
+		}
+		files[file] = data
+		return data
+	}
+
+	s := &Surface{UnitType: u.Type, Unit: u.Name, Defs: []*Def{}}
+	for _, def := range o.Defs {
+		if !def.Exported || def.Test {
+			continue
+		}
+		d := &Def{
+			Path: def.Path,
+			Name: def.Name,
+			Kind: def.Kind,
+			File: def.File,
+		}
+		if doc := docs[def.Path]; doc != nil {
+			d.Doc, d.DocFormat = doc.Data, doc.Format
+		}
+
+		unitType := def.UnitType
+		if unitType == "" {
+			unitType = u.Type
+		}
+		if mk, present := graph.MakeDefFormatters[unitType]; present {
+			if f := mk(def); f != nil {
+				d.Signature = strings.TrimSpace(f.DefKeyword() + " " + f.Name(graph.ScopeQualified) + f.NameAndTypeSeparator() + f.Type(graph.Unqualified))
+			}
+		} else if src := source(def.File); src != nil {
+			d.Signature = sourceSignature(src, def.DefStart, def.DefEnd)
+		}
+
+		s.Defs = append(s.Defs, d)
+	}
+	sort.Sort(defsByPath(s.Defs))
+	return s
+}
+
+// sourceSignature returns the first line of the source text src[start:end],
+// without a trailing opening brace.
+func sourceSignature(src []byte, start, end int) string {
+	if start < 0 || end > len(src) || start >= end {
+		return ""
+	}
+	text := src[start:end]
+	if i := bytes.IndexByte(text, '\n'); i != -1 {
+		text = text[:i]
+	}
+	sig := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(string(text)), "{"))
+	if len(sig) > maxSignatureLen {
+		sig = sig[:maxSignatureLen-3] + "..."
+	}
+	return sig
+}
+
+type defsByPath []*Def
+
+func (vs defsByPath) Len() int           { return len(vs) }
+func (vs defsByPath) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs defsByPath) Less(i, j int) bool { return vs[i].Path < vs[j].Path }
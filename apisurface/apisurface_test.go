@@ -0,0 +1,46 @@
+package apisurface
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestExtract(t *testing.T) {
+	src := "package p\n\nfunc F(a int) error {\n\treturn nil\n}\n\nfunc g() {}\n"
+	u := &unit.SourceUnit{Type: "t", Name: "p"}
+	o := &grapher.Output{
+		Defs: []*graph.Def{
+			{DefKey: graph.DefKey{Path: "g"}, Name: "g", Kind: "func", File: "p.go", DefStart: 48, DefEnd: 59},
+			{DefKey: graph.DefKey{Path: "F"}, Name: "F", Kind: "func", File: "p.go", DefStart: 11, DefEnd: 46, Exported: true},
+			{DefKey: graph.DefKey{Path: "TestF"}, Name: "TestF", Kind: "func", File: "p_test.go", Exported: true, Test: true},
+			{DefKey: graph.DefKey{Path: "V"}, Name: "V", Kind: "var", File: "missing.go", Exported: true},
+		},
+		Docs: []*graph.Doc{
+			{DefKey: graph.DefKey{Path: "F"}, Format: "text/html", Data: "<p>F does things.</p>"},
+			{DefKey: graph.DefKey{Path: "F"}, Format: "text/plain", Data: "F does things."},
+		},
+	}
+	readFile := func(file string) ([]byte, error) {
+		if file == "p.go" {
+			return []byte(src), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	want := &Surface{
+		UnitType: "t",
+		Unit:     "p",
+		Defs: []*Def{
+			{Path: "F", Name: "F", Kind: "func", Signature: "func F(a int) error", Doc: "F does things.", DocFormat: "text/plain", File: "p.go"},
+			{Path: "V", Name: "V", Kind: "var", File: "missing.go"},
+		},
+	}
+	if got := Extract(u, o, readFile); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package apisurface
+
+import "sort"
+
+// A ChangeKind is the kind of change to a def in an API surface.
+type ChangeKind string
+
+const (
+	// Added means that the def was added to the API.
+	Added ChangeKind = "added"
+
+	// Removed means that the def was removed from the API (or is no longer
+	// exported).
+	Removed ChangeKind = "removed"
+
+	// SignatureChanged means that the def's signature or kind changed.
+	SignatureChanged ChangeKind = "signature-changed"
+)
+
+// Breaking reports whether changes of kind k may break code that uses the
+// API.
+func (k ChangeKind) Breaking() bool { return k == Removed || k == SignatureChanged }
+
+// A Change is a difference between two API surfaces.
+type Change struct {
+	Kind     ChangeKind
+	UnitType string
+	Unit     string
+
+	// Old and New are the def before and after the change. Old is nil for
+	// added defs, and New is nil for removed defs.
+	Old *Def `json:",omitempty"`
+	New *Def `json:",omitempty"`
+
+	Breaking bool
+}
+
+// Def returns the def that changed (after the change, unless it was
+// removed).
+func (c *Change) Def() *Def {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// Diff returns the changes between the old and new API surfaces, sorted by
+// source unit and def path. Source units that only exist in old have all of
+// their defs removed, and vice versa.
+func Diff(old, new []*Surface) []*Change {
+	type defKey struct {
+		unitType, unit, path string
+	}
+	oldDefs := make(map[defKey]*Def)
+	for _, s := range old {
+		for _, d := range s.Defs {
+			oldDefs[defKey{s.UnitType, s.Unit, string(d.Path)}] = d
+		}
+	}
+
+	var changes []*Change
+	newDefs := make(map[defKey]bool)
+	for _, s := range new {
+		for _, d := range s.Defs {
+			k := defKey{s.UnitType, s.Unit, string(d.Path)}
+			newDefs[k] = true
+			if o, present := oldDefs[k]; !present {
+				changes = append(changes, &Change{Kind: Added, UnitType: s.UnitType, Unit: s.Unit, New: d})
+			} else if o.Signature != d.Signature || o.Kind != d.Kind {
+				changes = append(changes, &Change{Kind: SignatureChanged, UnitType: s.UnitType, Unit: s.Unit, Old: o, New: d})
+			}
+		}
+	}
+	for _, s := range old {
+		for _, d := range s.Defs {
+			if !newDefs[defKey{s.UnitType, s.Unit, string(d.Path)}] {
+				changes = append(changes, &Change{Kind: Removed, UnitType: s.UnitType, Unit: s.Unit, Old: d})
+			}
+		}
+	}
+
+	for _, c := range changes {
+		c.Breaking = c.Kind.Breaking()
+	}
+	sort.Sort(changesByDef(changes))
+	return changes
+}
+
+type changesByDef []*Change
+
+func (vs changesByDef) Len() int      { return len(vs) }
+func (vs changesByDef) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs changesByDef) Less(i, j int) bool {
+	a, b := vs[i], vs[j]
+	if a.UnitType != b.UnitType {
+		return a.UnitType < b.UnitType
+	}
+	if a.Unit != b.Unit {
+		return a.Unit < b.Unit
+	}
+	return a.Def().Path < b.Def().Path
+}
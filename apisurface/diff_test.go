@@ -0,0 +1,53 @@
+package apisurface
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := []*Surface{
+		{UnitType: "t", Unit: "a", Defs: []*Def{
+			{Path: "F", Kind: "func", Signature: "func F()"},
+			{Path: "G", Kind: "func", Signature: "func G()"},
+			{Path: "T", Kind: "type", Signature: "type T struct"},
+			{Path: "V", Kind: "var"},
+		}},
+		{UnitType: "t", Unit: "gone", Defs: []*Def{
+			{Path: "X", Kind: "func"},
+		}},
+	}
+	new := []*Surface{
+		{UnitType: "t", Unit: "a", Defs: []*Def{
+			{Path: "F", Kind: "func", Signature: "func F(a int)"},
+			{Path: "H", Kind: "func", Signature: "func H()"},
+			{Path: "T", Kind: "type", Signature: "type T struct"},
+			{Path: "V", Kind: "const"},
+		}},
+	}
+
+	type change struct {
+		Kind     ChangeKind
+		Unit     string
+		Path     string
+		Breaking bool
+	}
+	var got []change
+	for _, c := range Diff(old, new) {
+		got = append(got, change{c.Kind, c.Unit, string(c.Def().Path), c.Breaking})
+	}
+	want := []change{
+		{SignatureChanged, "a", "F", true},
+		{Removed, "a", "G", true},
+		{Added, "a", "H", false},
+		{SignatureChanged, "a", "V", true},
+		{Removed, "gone", "X", true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %+v, want %+v", got, want)
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("got %d changes between identical surfaces, want none", len(changes))
+	}
+}
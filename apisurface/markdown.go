@@ -0,0 +1,204 @@
+package apisurface
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MarkdownIndexPath is the path of the Markdown file that lists the source
+// units, relative to the root of the Markdown docs.
+const MarkdownIndexPath = "index.md"
+
+// MarkdownPath returns the path of the Markdown reference docs for the given
+// source unit, relative to the root of the Markdown docs.
+func MarkdownPath(unitType, unit string) string {
+	return path.Join(unitType, unit) + ".md"
+}
+
+// Markdown generates Markdown reference documentation for the API surfaces:
+// a file per source unit (at MarkdownPath) that documents each of its
+// exported defs, and an index file (at MarkdownIndexPath) that lists the
+// source units. It returns a map of each file's path to its contents.
+//
+// Names of exported defs that are quoted in docs (as in "`Name`") are linked
+// to the defs' docs, preferring defs in the same source unit. Names that
+// refer to more than one def are not linked.
+//
+// Source unit names come from toolchains, so source units whose files would
+// be outside of the root (or would replace the index) are skipped.
+func Markdown(surfaces []*Surface) map[string][]byte {
+	surfaces = validMarkdownSurfaces(surfaces)
+	sort.Sort(surfacesByUnit(surfaces))
+
+	// Assign each def an anchor and index the defs by name, for linking.
+	anchors := make(map[*Def]string)
+	unitOf := make(map[*Def]*Surface)
+	byName := make(map[string][]*Def)
+	for _, s := range surfaces {
+		used := make(map[string]bool)
+		for _, d := range s.Defs {
+			anchor := markdownAnchor(string(d.Path))
+			for i := 2; used[anchor]; i++ {
+				anchor = fmt.Sprintf("%s-%d", markdownAnchor(string(d.Path)), i)
+			}
+			used[anchor] = true
+			anchors[d] = anchor
+			unitOf[d] = s
+			byName[d.Name] = append(byName[d.Name], d)
+		}
+	}
+
+	// link returns the link from the docs of source unit s to the def named
+	// name, or "" if there is no single such def.
+	link := func(s *Surface, name string) string {
+		var target *Def
+		var sameUnit []*Def
+		for _, d := range byName[name] {
+			if unitOf[d] == s {
+				sameUnit = append(sameUnit, d)
+			}
+		}
+		if len(sameUnit) == 1 {
+			target = sameUnit[0]
+		} else if len(sameUnit) == 0 && len(byName[name]) == 1 {
+			target = byName[name][0]
+		} else {
+			return ""
+		}
+		t := unitOf[target]
+		if t == s {
+			return "#" + anchors[target]
+		}
+		return relativePath(MarkdownPath(s.UnitType, s.Unit), MarkdownPath(t.UnitType, t.Unit)) + "#" + anchors[target]
+	}
+
+	files := make(map[string][]byte, len(surfaces)+1)
+	var index bytes.Buffer
+	fmt.Fprintln(&index, "# API reference")
+	fmt.Fprintln(&index)
+	for _, s := range surfaces {
+		p := MarkdownPath(s.UnitType, s.Unit)
+		fmt.Fprintf(&index, "- [%s](%s) (%s, %d exported defs)\n", escapeMarkdown(s.Unit), relativePath(MarkdownIndexPath, p), s.UnitType, len(s.Defs))
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# %s\n\n", escapeMarkdown(s.Unit))
+		fmt.Fprintf(&buf, "Source unit `%s` of type %s. [All source units](%s)\n\n", s.Unit, s.UnitType, relativePath(p, MarkdownIndexPath))
+		if len(s.Defs) == 0 {
+			fmt.Fprintln(&buf, "This source unit has no exported defs.")
+		} else {
+			fmt.Fprintln(&buf, "## Index")
+			fmt.Fprintln(&buf)
+			for _, d := range s.Defs {
+				fmt.Fprintf(&buf, "- [%s](#%s) (%s)\n", escapeMarkdown(d.Name), anchors[d], d.Kind)
+			}
+			fmt.Fprintln(&buf)
+			fmt.Fprintln(&buf, "## Defs")
+		}
+		for _, d := range s.Defs {
+			fmt.Fprintf(&buf, "\n<a id=\"%s\"></a>\n### %s\n\n", anchors[d], escapeMarkdown(d.Name))
+			if d.Signature != "" {
+				fmt.Fprintf(&buf, "```\n%s\n```\n\n", d.Signature)
+			}
+			if doc := markdownDoc(d, func(name string) string {
+				if name == d.Name {
+					return ""
+				}
+				return link(s, name)
+			}); doc != "" {
+				fmt.Fprintf(&buf, "%s\n\n", doc)
+			}
+			if d.File != "" {
+				fmt.Fprintf(&buf, "*%s* `%s` in `%s`\n", d.Kind, d.Path, d.File)
+			} else {
+				fmt.Fprintf(&buf, "*%s* `%s`\n", d.Kind, d.Path)
+			}
+		}
+		files[p] = buf.Bytes()
+	}
+	files[MarkdownIndexPath] = index.Bytes()
+	return files
+}
+
+// validMarkdownSurfaces returns the surfaces whose source units' Markdown
+// paths are relative paths in the root of the Markdown docs, other than
+// the index's path. It logs the others.
+func validMarkdownSurfaces(surfaces []*Surface) []*Surface {
+	valid := make([]*Surface, 0, len(surfaces))
+	for _, s := range surfaces {
+		p := MarkdownPath(s.UnitType, s.Unit)
+		if p == MarkdownIndexPath || path.IsAbs(p) || strings.HasPrefix(p, "../") {
+			log.Printf("Warning: skipping the Markdown docs of source unit %q type %q (invalid path %q).", s.Unit, s.UnitType, p)
+			continue
+		}
+		valid = append(valid, s)
+	}
+	return valid
+}
+
+var (
+	htmlTag     = regexp.MustCompile(`<[^>]*>`)
+	quotedName  = regexp.MustCompile("`([A-Za-z_$][A-Za-z0-9_$]*)`")
+	nonAnchor   = regexp.MustCompile(`[^a-z0-9_]+`)
+	markdownEsc = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`)
+)
+
+// markdownDoc returns d's doc as Markdown, with quoted names linked to the
+// result of link (if it is nonempty). HTML docs are converted to plain text.
+func markdownDoc(d *Def, link func(name string) string) string {
+	doc := d.Doc
+	if d.DocFormat == "text/html" {
+		doc = html.UnescapeString(htmlTag.ReplaceAllString(doc, ""))
+	}
+	doc = strings.TrimSpace(doc)
+	return quotedName.ReplaceAllStringFunc(doc, func(q string) string {
+		if l := link(q[1 : len(q)-1]); l != "" {
+			return "[" + q + "](" + l + ")"
+		}
+		return q
+	})
+}
+
+// markdownAnchor returns an HTML anchor name for a def path.
+func markdownAnchor(defPath string) string {
+	a := strings.Trim(nonAnchor.ReplaceAllString(strings.ToLower(defPath), "-"), "-")
+	if a == "" {
+		a = "def"
+	}
+	return a
+}
+
+// escapeMarkdown escapes the characters in s that have special meaning in
+// Markdown text.
+func escapeMarkdown(s string) string { return markdownEsc.Replace(s) }
+
+// relativePath returns the relative URL of the file at path to from the file
+// at path from (both slash-separated and relative to the same root).
+func relativePath(from, to string) string {
+	fromDirs := strings.Split(from, "/")
+	fromDirs = fromDirs[:len(fromDirs)-1]
+	toParts := strings.Split(to, "/")
+	n := 0
+	for n < len(fromDirs) && n < len(toParts)-1 && fromDirs[n] == toParts[n] {
+		n++
+	}
+	rel := strings.Repeat("../", len(fromDirs)-n) + strings.Join(toParts[n:], "/")
+	return (&url.URL{Path: rel}).String()
+}
+
+type surfacesByUnit []*Surface
+
+func (vs surfacesByUnit) Len() int      { return len(vs) }
+func (vs surfacesByUnit) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs surfacesByUnit) Less(i, j int) bool {
+	if vs[i].UnitType != vs[j].UnitType {
+		return vs[i].UnitType < vs[j].UnitType
+	}
+	return vs[i].Unit < vs[j].Unit
+}
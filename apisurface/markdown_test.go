@@ -0,0 +1,72 @@
+package apisurface
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	surfaces := []*Surface{
+		{UnitType: "t", Unit: "b/c", Defs: []*Def{
+			{Path: "Open", Name: "Open", Kind: "func", Signature: "func Open() *File", Doc: "Open returns a `File` for `Reader`. See `Open`.", File: "b/c/open.go"},
+			{Path: "File", Name: "File", Kind: "type", Doc: "<p>A file &amp; its <code>Name</code>.</p>", DocFormat: "text/html"},
+		}},
+		{UnitType: "t", Unit: "a", Defs: []*Def{
+			{Path: "Reader", Name: "Reader", Kind: "type"},
+			{Path: "File", Name: "File", Kind: "type"},
+		}},
+	}
+
+	files := Markdown(surfaces)
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if want := []string{"index.md", "t/a.md", "t/b/c.md"}; strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("got files %v, want %v", paths, want)
+	}
+
+	for path, wants := range map[string][]string{
+		"index.md": {"- [a](t/a.md) (t, 2 exported defs)", "- [b/c](t/b/c.md) (t, 2 exported defs)"},
+		"t/b/c.md": {
+			"[All source units](../../index.md)",
+			"- [Open](#open) (func)",
+			"<a id=\"open\"></a>\n### Open\n\n```\nfunc Open() *File\n```\n",
+			// File links to the same unit's def, Reader to the other unit's,
+			// and Open doesn't link to itself.
+			"Open returns a [`File`](#file) for [`Reader`](../a.md#reader). See `Open`.",
+			"*func* `Open` in `b/c/open.go`",
+			"A file & its Name.",
+		},
+	} {
+		for _, want := range wants {
+			if !strings.Contains(string(files[path]), want) {
+				t.Errorf("%s doesn't contain %q:\n%s", path, want, files[path])
+			}
+		}
+	}
+}
+
+func TestMarkdown_invalidPaths(t *testing.T) {
+	surfaces := []*Surface{
+		{UnitType: "t", Unit: "a", Defs: []*Def{{Path: "A", Name: "A", Kind: "func"}}},
+		{UnitType: "t", Unit: "../../../x"},
+		{UnitType: "..", Unit: "y"},
+		{UnitType: "", Unit: "/etc/z"},
+		{UnitType: "", Unit: "index"},
+	}
+	files := Markdown(surfaces)
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if want := []string{"index.md", "t/a.md"}; strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("got files %v, want %v", paths, want)
+	}
+	if index := string(files["index.md"]); strings.Contains(index, "x.md") || strings.Contains(index, "y.md") {
+		t.Errorf("index links to skipped source units:\n%s", index)
+	}
+}
@@ -0,0 +1,65 @@
+package apisurface
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	plan.RegisterRuleMaker("apisurface", makeSurfaceRules)
+	buildstore.RegisterDataType("apisurface", &Surface{})
+}
+
+// makeSurfaceRules makes rules for extracting the API surface of each source
+// unit, which must wait until graphing completes.
+func makeSurfaceRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	var rules []makex.Rule
+	for _, rule := range existing {
+		if gr, ok := rule.(*grapher.GraphUnitRule); ok {
+			rules = append(rules, &SurfaceUnitRule{
+				dataDir:     dataDir,
+				Unit:        gr.Unit,
+				GraphOutput: gr.Target(),
+			})
+		}
+	}
+	return rules, nil
+}
+
+// SurfaceUnitRule extracts the API surface of a source unit.
+type SurfaceUnitRule struct {
+	dataDir     string
+	Unit        *unit.SourceUnit
+	GraphOutput string
+}
+
+func (r *SurfaceUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *SurfaceUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&Surface{}, r.Unit))
+}
+
+func (r *SurfaceUnitRule) unitDataFile() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))
+}
+
+func (r *SurfaceUnitRule) Prereqs() []string {
+	// Signatures may be taken from the source files.
+	ps := []string{r.unitDataFile(), r.GraphOutput}
+	ps = append(ps, r.Unit.Files...)
+	return ps
+}
+
+func (r *SurfaceUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src internal unit-api-surface --unit-data %s --graph-data %s 1> $@", makex.Quote(r.unitDataFile()), makex.Quote(r.GraphOutput)),
+	}
+}
@@ -80,6 +80,8 @@ type ComputeUnitAuthorshipRule struct {
 	GraphOutput string
 }
 
+func (r *ComputeUnitAuthorshipRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
 func (r *ComputeUnitAuthorshipRule) Target() string {
 	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&SourceUnitOutput{}, r.Unit))
 }
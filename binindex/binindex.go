@@ -0,0 +1,485 @@
+// Package binindex provides a compact binary index of a repository's defs
+// and refs, for servers and editor integrations that look up a few defs and
+// refs per request. The index file is memory-mapped read-only (so it is
+// shared by all of the processes that open it, and opening it doesn't read
+// or decode the whole file), and lookups are binary searches over sorted
+// tables of fixed-size records.
+//
+// The file consists of a header, followed by these sections:
+//
+//	strings      the strings that the records refer to (by offset and length)
+//	defs         def records, sorted by def key
+//	refs         ref records, sorted by file and position
+//	refs by def  indexes of the ref records, sorted by the key of their defs
+//	bloom        a bloom filter of the def keys, for fast negative lookups
+//
+// Each record also refers to the JSON encoding of its full def or ref (and,
+// for defs, of their docs), which is only decoded when the def or ref is
+// returned. All integers are little-endian.
+package binindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// Filename is the name of the index file in a commit's build data
+// directory.
+const Filename = "build.index"
+
+const (
+	magic   = "srclibix"
+	version = 1
+
+	headerSize = 72
+
+	strRefSize = 8                               // offset, length
+	defSize    = 5*strRefSize + 8 + 2*strRefSize // key (4), file; start, end; JSON, docs JSON
+	refSize    = 5*strRefSize + 8 + strRefSize   // file, def key (4); start, end; JSON
+
+	bloomBitsPerDef = 10
+	bloomHashes     = 7
+)
+
+// header is the header of an index file.
+type header struct {
+	Magic                 [8]byte
+	Version               uint32
+	NumDefs               uint32
+	NumRefs               uint32
+	BloomHashes           uint32
+	StringsOff, DefsOff   uint64
+	RefsOff, RefsByDefOff uint64
+	BloomOff, BloomBits   uint64
+}
+
+// Write writes an index of defs and refs (and the docs of the defs) to w.
+// The def keys of the defs, refs, and docs must be complete (with the
+// Repo, UnitType, and Unit fields set); the CommitID fields are ignored.
+func Write(w io.Writer, defs []*graph.Def, refs []*graph.Ref, docs []*graph.Doc) error {
+	var b builder
+	b.strs = make(map[string]strRef)
+
+	defs = append([]*graph.Def(nil), defs...)
+	sort.Sort(defsByKey(defs))
+	docsByKey := make(map[graph.DefKey][]*graph.Doc)
+	for _, doc := range docs {
+		key := indexKey(doc.DefKey)
+		docsByKey[key] = append(docsByKey[key], doc)
+	}
+	var defRecs bytes.Buffer
+	for i, def := range defs {
+		key := indexKey(def.DefKey)
+		if i > 0 && indexKey(defs[i-1].DefKey) == key {
+			return fmt.Errorf("duplicate def %s", key.Format())
+		}
+		defJSON, err := json.Marshal(def)
+		if err != nil {
+			return err
+		}
+		var docsJSON []byte
+		if ds := docsByKey[key]; len(ds) > 0 {
+			if docsJSON, err = json.Marshal(ds); err != nil {
+				return err
+			}
+		}
+		for _, s := range []string{string(key.Repo), key.UnitType, key.Unit, string(key.Path), def.File} {
+			b.putStr(&defRecs, s)
+		}
+		putUint32(&defRecs, def.DefStart, def.DefEnd)
+		b.putStr(&defRecs, string(defJSON))
+		b.putStr(&defRecs, string(docsJSON))
+	}
+
+	refs = append([]*graph.Ref(nil), refs...)
+	sort.Sort(refsByPosition(refs))
+	var refRecs bytes.Buffer
+	for _, ref := range refs {
+		refJSON, err := json.Marshal(ref)
+		if err != nil {
+			return err
+		}
+		for _, s := range []string{ref.File, string(ref.DefRepo), ref.DefUnitType, ref.DefUnit, string(ref.DefPath)} {
+			b.putStr(&refRecs, s)
+		}
+		putUint32(&refRecs, ref.Start, ref.End)
+		b.putStr(&refRecs, string(refJSON))
+	}
+	byDef := make([]int, len(refs))
+	for i := range byDef {
+		byDef[i] = i
+	}
+	sort.Stable(refIndexesByDef{byDef, refs})
+	var byDefRecs bytes.Buffer
+	for _, i := range byDef {
+		putUint32(&byDefRecs, i)
+	}
+
+	bloomBits := uint64(len(defs)*bloomBitsPerDef+63) / 64 * 64
+	if bloomBits == 0 {
+		bloomBits = 64
+	}
+	bloom := make([]byte, bloomBits/8)
+	for _, def := range defs {
+		for _, bit := range bloomBitsOf(indexKey(def.DefKey), bloomBits) {
+			bloom[bit/8] |= 1 << (bit % 8)
+		}
+	}
+
+	if uint64(b.strings.Len()) > math.MaxUint32 || uint64(len(refs)) > math.MaxUint32 {
+		return errors.New("too much data for an index")
+	}
+	h := header{
+		Version:     version,
+		NumDefs:     uint32(len(defs)),
+		NumRefs:     uint32(len(refs)),
+		BloomHashes: bloomHashes,
+		BloomBits:   bloomBits,
+	}
+	copy(h.Magic[:], magic)
+	h.StringsOff = headerSize
+	h.DefsOff = h.StringsOff + uint64(b.strings.Len())
+	h.RefsOff = h.DefsOff + uint64(defRecs.Len())
+	h.RefsByDefOff = h.RefsOff + uint64(refRecs.Len())
+	h.BloomOff = h.RefsByDefOff + uint64(byDefRecs.Len())
+	if err := binary.Write(w, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	for _, section := range [][]byte{b.strings.Bytes(), defRecs.Bytes(), refRecs.Bytes(), byDefRecs.Bytes(), bloom} {
+		if _, err := w.Write(section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A builder builds the strings section of an index.
+type builder struct {
+	strings bytes.Buffer
+	strs    map[string]strRef // deduplicates strings
+}
+
+type strRef struct{ off, len int }
+
+// putStr adds s to the strings section (if it's not already there), and
+// writes a reference to it to w.
+func (b *builder) putStr(w *bytes.Buffer, s string) {
+	r, present := b.strs[s]
+	if !present {
+		r = strRef{b.strings.Len(), len(s)}
+		b.strings.WriteString(s)
+		b.strs[s] = r
+	}
+	putUint32(w, r.off, r.len)
+}
+
+func putUint32(w *bytes.Buffer, vs ...int) {
+	var buf [4]byte
+	for _, v := range vs {
+		binary.LittleEndian.PutUint32(buf[:], uint32(v))
+		w.Write(buf[:])
+	}
+}
+
+// indexKey returns the part of key that is indexed.
+func indexKey(key graph.DefKey) graph.DefKey {
+	key.CommitID = ""
+	return key
+}
+
+// bloomBitsOf returns the bloom filter bits of key, in a filter of m bits.
+func bloomBitsOf(key graph.DefKey, m uint64) []uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, key.Format())
+	sum := h.Sum64()
+	h1, h2 := sum&0xFFFFFFFF, sum>>32|1
+	bits := make([]uint64, bloomHashes)
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % m
+	}
+	return bits
+}
+
+type defsByKey []*graph.Def
+
+func (v defsByKey) Len() int           { return len(v) }
+func (v defsByKey) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v defsByKey) Less(i, j int) bool { return indexKey(v[i].DefKey).Less(indexKey(v[j].DefKey)) }
+
+type refsByPosition []*graph.Ref
+
+func (v refsByPosition) Len() int      { return len(v) }
+func (v refsByPosition) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v refsByPosition) Less(i, j int) bool {
+	a, b := v[i], v[j]
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Start != b.Start {
+		return a.Start < b.Start
+	}
+	return a.End < b.End
+}
+
+type refIndexesByDef struct {
+	indexes []int
+	refs    []*graph.Ref
+}
+
+func (v refIndexesByDef) Len() int      { return len(v.indexes) }
+func (v refIndexesByDef) Swap(i, j int) { v.indexes[i], v.indexes[j] = v.indexes[j], v.indexes[i] }
+func (v refIndexesByDef) Less(i, j int) bool {
+	return refDefKey(v.refs[v.indexes[i]]).Less(refDefKey(v.refs[v.indexes[j]]))
+}
+
+func refDefKey(ref *graph.Ref) graph.DefKey {
+	return graph.DefKey{Repo: ref.DefRepo, UnitType: ref.DefUnitType, Unit: ref.DefUnit, Path: ref.DefPath}
+}
+
+// An Index is an open index file. It is safe for concurrent use.
+//
+// The methods of an Index that was opened from a corrupt file may return
+// wrong results (or errors, when decoding JSON), but never panic.
+type Index struct {
+	data  []byte
+	h     header
+	unmap func() error
+}
+
+// Open opens the index file, memory-mapping it if possible. Call Close
+// when done with it.
+func Open(file string) (*Index, error) {
+	data, unmap, err := mapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	x, err := Load(data)
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	x.unmap = unmap
+	return x, nil
+}
+
+// Load returns the index whose file contents are data. The index refers to
+// data, which must not be modified.
+func Load(data []byte) (*Index, error) {
+	x := &Index{data: data}
+	if len(data) < headerSize {
+		return nil, errors.New("not an index (too short)")
+	}
+	if err := binary.Read(bytes.NewReader(data[:headerSize]), binary.LittleEndian, &x.h); err != nil {
+		return nil, err
+	}
+	if string(x.h.Magic[:]) != magic {
+		return nil, errors.New("not an index")
+	}
+	if x.h.Version != version {
+		return nil, fmt.Errorf("unsupported index version %d (want %d)", x.h.Version, version)
+	}
+	h := x.h
+	n := uint64(len(data))
+	defsEnd, ok1 := sectionEnd(h.DefsOff, uint64(h.NumDefs), defSize, n)
+	refsEnd, ok2 := sectionEnd(h.RefsOff, uint64(h.NumRefs), refSize, n)
+	refsByDefEnd, ok3 := sectionEnd(h.RefsByDefOff, uint64(h.NumRefs), 4, n)
+	bloomEnd, ok4 := sectionEnd(h.BloomOff, h.BloomBits/8, 1, n)
+	if !ok1 || !ok2 || !ok3 || !ok4 ||
+		h.StringsOff < headerSize || h.StringsOff > h.DefsOff ||
+		defsEnd != h.RefsOff ||
+		refsEnd != h.RefsByDefOff ||
+		refsByDefEnd != h.BloomOff ||
+		h.BloomBits == 0 || h.BloomBits%8 != 0 || bloomEnd != n {
+		return nil, errors.New("corrupt index (bad section offsets)")
+	}
+	return x, nil
+}
+
+// sectionEnd returns the end offset of the section at off that holds n
+// items of size bytes each. It returns false if the section doesn't end
+// within limit bytes (without computing the end, which may overflow).
+func sectionEnd(off, n, size, limit uint64) (uint64, bool) {
+	if off > limit || n > (limit-off)/size {
+		return 0, false
+	}
+	return off + n*size, true
+}
+
+// Close closes the index. Its methods must not be called afterwards.
+func (x *Index) Close() error {
+	if x.unmap == nil {
+		return nil
+	}
+	return x.unmap()
+}
+
+// NumDefs returns the number of defs in the index.
+func (x *Index) NumDefs() int { return int(x.h.NumDefs) }
+
+// NumRefs returns the number of refs in the index.
+func (x *Index) NumRefs() int { return int(x.h.NumRefs) }
+
+func (x *Index) uint32At(off uint64) int {
+	return int(binary.LittleEndian.Uint32(x.data[off:]))
+}
+
+// str returns the string referred to at off.
+func (x *Index) str(off uint64) string {
+	so, sl := uint64(x.uint32At(off)), uint64(x.uint32At(off+4))
+	if x.h.StringsOff+so+sl > x.h.DefsOff {
+		return ""
+	}
+	return string(x.data[x.h.StringsOff+so : x.h.StringsOff+so+sl])
+}
+
+func (x *Index) defOff(i int) uint64 { return x.h.DefsOff + uint64(i)*defSize }
+func (x *Index) refOff(i int) uint64 { return x.h.RefsOff + uint64(i)*refSize }
+
+// keyAt returns the def key referred to by the 4 strings at off.
+func (x *Index) keyAt(off uint64) graph.DefKey {
+	return graph.DefKey{
+		Repo:     repo.URI(x.str(off)),
+		UnitType: x.str(off + strRefSize),
+		Unit:     x.str(off + 2*strRefSize),
+		Path:     graph.DefPath(x.str(off + 3*strRefSize)),
+	}
+}
+
+// MayHaveDef reports whether the index may have a def with the given key.
+// If it returns false, the index definitely doesn't. It's faster than Def.
+func (x *Index) MayHaveDef(key graph.DefKey) bool {
+	for _, bit := range bloomBitsOf(indexKey(key), x.h.BloomBits) {
+		if x.data[x.h.BloomOff+bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// findDef returns the index of the def record with the given key, or -1.
+func (x *Index) findDef(key graph.DefKey) int {
+	if !x.MayHaveDef(key) {
+		return -1
+	}
+	key = indexKey(key)
+	n := x.NumDefs()
+	i := sort.Search(n, func(i int) bool { return !x.keyAt(x.defOff(i)).Less(key) })
+	if i == n || x.keyAt(x.defOff(i)) != key {
+		return -1
+	}
+	return i
+}
+
+// Def returns the def with the given key (ignoring its CommitID), or nil if
+// there is none.
+func (x *Index) Def(key graph.DefKey) (*graph.Def, error) {
+	i := x.findDef(key)
+	if i == -1 {
+		return nil, nil
+	}
+	var def *graph.Def
+	if err := json.Unmarshal([]byte(x.str(x.defOff(i)+5*strRefSize+8)), &def); err != nil {
+		return nil, fmt.Errorf("def %s: %s", key.Format(), err)
+	}
+	return def, nil
+}
+
+// Docs returns the docs of the def with the given key (ignoring its
+// CommitID).
+func (x *Index) Docs(key graph.DefKey) ([]*graph.Doc, error) {
+	i := x.findDef(key)
+	if i == -1 {
+		return nil, nil
+	}
+	data := x.str(x.defOff(i) + 6*strRefSize + 8)
+	if data == "" {
+		return nil, nil
+	}
+	var docs []*graph.Doc
+	if err := json.Unmarshal([]byte(data), &docs); err != nil {
+		return nil, fmt.Errorf("docs of def %s: %s", key.Format(), err)
+	}
+	return docs, nil
+}
+
+// ref decodes the i'th ref record.
+func (x *Index) ref(i int) (*graph.Ref, error) {
+	if i >= x.NumRefs() {
+		return nil, fmt.Errorf("ref %d: out of range", i)
+	}
+	var ref *graph.Ref
+	if err := json.Unmarshal([]byte(x.str(x.refOff(i)+5*strRefSize+8)), &ref); err != nil {
+		return nil, fmt.Errorf("ref %d: %s", i, err)
+	}
+	return ref, nil
+}
+
+// fileRefs returns the range [i, j) of the ref records in file.
+func (x *Index) fileRefs(file string) (i, j int) {
+	n := x.NumRefs()
+	i = sort.Search(n, func(k int) bool { return x.str(x.refOff(k)) >= file })
+	j = i + sort.Search(n-i, func(k int) bool { return x.str(x.refOff(i+k)) != file })
+	return i, j
+}
+
+// FileRefs returns the refs in file, sorted by position.
+func (x *Index) FileRefs(file string) ([]*graph.Ref, error) {
+	i, j := x.fileRefs(file)
+	refs := make([]*graph.Ref, 0, j-i)
+	for k := i; k < j; k++ {
+		ref, err := x.ref(k)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// RefAt returns the innermost ref in file that contains the byte offset,
+// or nil if there is none.
+func (x *Index) RefAt(file string, offset int) (*graph.Ref, error) {
+	i, j := x.fileRefs(file)
+	// The refs that start at or before offset are [i, k).
+	k := i + sort.Search(j-i, func(l int) bool { return x.uint32At(x.refOff(i+l)+5*strRefSize) > offset })
+	for l := k - 1; l >= i; l-- {
+		if x.uint32At(x.refOff(l)+5*strRefSize+4) >= offset {
+			return x.ref(l)
+		}
+	}
+	return nil, nil
+}
+
+// DefRefs returns the refs to the def with the given key (ignoring its
+// CommitID), sorted by file and position.
+func (x *Index) DefRefs(key graph.DefKey) ([]*graph.Ref, error) {
+	key = indexKey(key)
+	n := x.NumRefs()
+	refKey := func(k int) graph.DefKey {
+		i := x.uint32At(x.h.RefsByDefOff + uint64(k)*4)
+		if i >= n {
+			return graph.DefKey{}
+		}
+		return x.keyAt(x.refOff(i) + strRefSize)
+	}
+	i := sort.Search(n, func(k int) bool { return !refKey(k).Less(key) })
+	var refs []*graph.Ref
+	for k := i; k < n && refKey(k) == key; k++ {
+		ref, err := x.ref(x.uint32At(x.h.RefsByDefOff + uint64(k)*4))
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
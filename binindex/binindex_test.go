@@ -0,0 +1,182 @@
+package binindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func key(path string) graph.DefKey {
+	return graph.DefKey{Repo: "r", UnitType: "t", Unit: "u", Path: graph.DefPath(path)}
+}
+
+func ref(file string, start, end int, defPath string) *graph.Ref {
+	return &graph.Ref{DefRepo: "r", DefUnitType: "t", DefUnit: "u", DefPath: graph.DefPath(defPath), Repo: "r", UnitType: "t", Unit: "u", File: file, Start: start, End: end}
+}
+
+func writeTestIndex(t *testing.T) string {
+	defs := []*graph.Def{
+		{DefKey: key("b"), Name: "b", File: "b.go", DefStart: 1, DefEnd: 9},
+		{DefKey: key("a"), Name: "a", File: "a.go", DefStart: 1, DefEnd: 9},
+	}
+	refs := []*graph.Ref{
+		ref("a.go", 10, 20, "a"),
+		ref("a.go", 12, 15, "b"),
+		ref("b.go", 1, 2, "b"),
+		ref("a.go", 30, 31, "a"),
+	}
+	docs := []*graph.Doc{
+		{DefKey: key("a"), Format: "text/plain", Data: "doc"},
+	}
+
+	dir, err := ioutil.TempDir("", "binindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, defs, refs, docs); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, Filename)
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestIndex(t *testing.T) {
+	file := writeTestIndex(t)
+	defer os.RemoveAll(filepath.Dir(file))
+
+	x, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	if x.NumDefs() != 2 || x.NumRefs() != 4 {
+		t.Errorf("got %d defs and %d refs, want 2 and 4", x.NumDefs(), x.NumRefs())
+	}
+
+	def, err := x.Def(graph.DefKey{Repo: "r", CommitID: "c", UnitType: "t", Unit: "u", Path: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def == nil || def.Name != "a" || def.File != "a.go" {
+		t.Errorf("got def %+v, want def a", def)
+	}
+	if def, err := x.Def(key("x")); def != nil || err != nil {
+		t.Errorf("got def %+v (error %v) for missing key, want nil", def, err)
+	}
+	if !x.MayHaveDef(key("a")) {
+		t.Error("MayHaveDef(a) = false, want true")
+	}
+
+	docs, err := x.Docs(key("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Data != "doc" {
+		t.Errorf("got docs %+v, want 1 doc", docs)
+	}
+	if docs, err := x.Docs(key("b")); len(docs) != 0 || err != nil {
+		t.Errorf("got docs %+v (error %v), want none", docs, err)
+	}
+
+	refs, err := x.FileRefs("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*graph.Ref{ref("a.go", 10, 20, "a"), ref("a.go", 12, 15, "b"), ref("a.go", 30, 31, "a")}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("got refs in a.go %+v, want %+v", refs, want)
+	}
+
+	refAtTests := map[int]*graph.Ref{
+		9:  nil,
+		10: ref("a.go", 10, 20, "a"),
+		13: ref("a.go", 12, 15, "b"),
+		16: ref("a.go", 10, 20, "a"),
+		25: nil,
+		31: ref("a.go", 30, 31, "a"),
+	}
+	for offset, want := range refAtTests {
+		got, err := x.RefAt("a.go", offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("RefAt(a.go, %d): got %+v, want %+v", offset, got, want)
+		}
+	}
+
+	refs, err = x.DefRefs(key("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*graph.Ref{ref("a.go", 12, 15, "b"), ref("b.go", 1, 2, "b")}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("got refs to b %+v, want %+v", refs, want)
+	}
+}
+
+func TestWrite_duplicateDef(t *testing.T) {
+	defs := []*graph.Def{{DefKey: key("a")}, {DefKey: key("a")}}
+	if err := Write(ioutil.Discard, defs, nil, nil); err == nil {
+		t.Error("got no error for duplicate defs")
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []*graph.Def{{DefKey: key("a")}}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// withHeader returns data with its header changed by f.
+	withHeader := func(f func(h *header)) []byte {
+		var h header
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &h); err != nil {
+			t.Fatal(err)
+		}
+		f(&h)
+		var b bytes.Buffer
+		if err := binary.Write(&b, binary.LittleEndian, &h); err != nil {
+			t.Fatal(err)
+		}
+		return append(b.Bytes(), data[headerSize:]...)
+	}
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"short":     data[:headerSize-1],
+		"magic":     append([]byte("xxxxxxxx"), data[8:]...),
+		"truncated": data[:len(data)-1],
+		"strings in header": withHeader(func(h *header) {
+			h.StringsOff = 0
+		}),
+		// The end of the defs section wraps around to 0, where the other
+		// sections start.
+		"wrapping offsets": withHeader(func(h *header) {
+			h.StringsOff = headerSize
+			h.DefsOff = math.MaxUint64 - uint64(h.NumDefs)*defSize + 1
+			h.NumRefs = 0
+			h.RefsOff, h.RefsByDefOff, h.BloomOff = 0, 0, 0
+			h.BloomBits = uint64(len(data)) * 8
+		}),
+	}
+	for label, data := range tests {
+		if _, err := Load(data); err == nil {
+			t.Errorf("%s: got no error", label)
+		}
+	}
+	if _, err := Load(data); err != nil {
+		t.Errorf("valid: %s", err)
+	}
+}
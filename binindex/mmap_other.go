@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package binindex
+
+import "io/ioutil"
+
+// mapFile reads the file (memory-mapping isn't supported on this platform),
+// and returns its contents and a no-op function.
+func mapFile(file string) (data []byte, unmap func() error, err error) {
+	data, err = ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,36 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package binindex
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mapFile memory-maps the file read-only, and returns its contents and a
+// function that unmaps it.
+func mapFile(file string) (data []byte, unmap func() error, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The mapping remains valid after the file is closed.
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, nil, errors.New(file + ": empty index")
+	}
+	if int64(int(fi.Size())) != fi.Size() {
+		return nil, nil, errors.New(file + ": index too large to map")
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
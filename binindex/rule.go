@@ -0,0 +1,66 @@
+package binindex
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	plan.RegisterRuleMaker("binindex", makeIndexRules)
+}
+
+// makeIndexRules makes the rule for building the index of all of the
+// source units' graph outputs, which must wait until graphing completes.
+func makeIndexRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	r := &IndexRule{dataDir: dataDir}
+	for _, rule := range existing {
+		if gr, ok := rule.(*grapher.GraphUnitRule); ok {
+			r.Units = append(r.Units, gr.Unit)
+			r.GraphOutputs = append(r.GraphOutputs, gr.Target())
+		}
+	}
+	if len(r.Units) == 0 {
+		return nil, nil
+	}
+	return []makex.Rule{r}, nil
+}
+
+// IndexRule builds the index (see Filename) of the graph outputs of a
+// repository's source units.
+type IndexRule struct {
+	dataDir      string
+	Units        []*unit.SourceUnit
+	GraphOutputs []string // GraphOutputs[i] is the graph output of Units[i]
+}
+
+func (r *IndexRule) Target() string { return filepath.Join(r.dataDir, Filename) }
+
+func (r *IndexRule) unitDataFile(u *unit.SourceUnit) string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, u))
+}
+
+func (r *IndexRule) Prereqs() []string {
+	var ps []string
+	for i, u := range r.Units {
+		ps = append(ps, r.unitDataFile(u), r.GraphOutputs[i])
+	}
+	return ps
+}
+
+func (r *IndexRule) Recipes() []string {
+	var args []string
+	for i, u := range r.Units {
+		args = append(args, "--unit-data", makex.Quote(r.unitDataFile(u)), "--graph-data", makex.Quote(r.GraphOutputs[i]))
+	}
+	return []string{
+		fmt.Sprintf("src internal binary-index %s 1> $@", strings.Join(args, " ")),
+	}
+}
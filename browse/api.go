@@ -0,0 +1,352 @@
+package browse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/query"
+	"sourcegraph.com/sourcegraph/srclib/vcsutil"
+)
+
+// APIVersion is the version of the site's JSON API. Its endpoints are under
+// the path "/api/APIVersion/" (e.g., "/api/v1/files"). Endpoints are only
+// removed or changed incompatibly in a new version.
+const APIVersion = "v1"
+
+// apiPrefix is the path prefix of the JSON API endpoints.
+const apiPrefix = "/api/" + APIVersion
+
+// An apiEndpoint is an endpoint of the JSON API. The list of endpoints is
+// used both to serve and validate requests and to generate the API's
+// OpenAPI spec, so that they can't disagree.
+type apiEndpoint struct {
+	Path        string // relative to apiPrefix
+	OperationID string
+	Summary     string
+	Params      []apiParam
+
+	// Result is a value of the type of the response body, from which its
+	// schema is generated.
+	Result interface{}
+
+	// serve returns the response body for a request whose query parameters
+	// have been validated.
+	serve func(s *Site, q url.Values) (interface{}, error)
+}
+
+// An apiParam is a query parameter of an API endpoint.
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// defKeyParams are the query parameters that identify a def.
+var defKeyParams = []apiParam{
+	{Name: "def", Description: "key of the def, as REPO[@COMMIT]:UNITTYPE:UNIT:PATH (instead of the other parameters)"},
+	{Name: "repo", Description: "repository URI of the def (defaults to the site's repository)"},
+	{Name: "unittype", Description: "source unit type of the def (required unless def is given)"},
+	{Name: "unit", Description: "source unit name of the def (required unless def is given)"},
+	{Name: "path", Description: "path of the def (required unless def is given)"},
+}
+
+// listParams are the query parameters of the endpoints that list defs or
+// refs, which select a page of the list, filter it, and select the fields
+// of its items (see package query). If there are more items after a page,
+// its response's Link header has the URL of the next page (with rel
+// "next"). The X-Total-Count header is the number of matching items.
+var listParams = []apiParam{
+	{Name: "first", Description: "maximum number of items to return (default: all)"},
+	{Name: "after", Description: "cursor of the item after which to start (from the URL of the next page in the Link header)"},
+	{Name: "kind", Description: "only list the items of this kind"},
+	fieldsParam,
+}
+
+// fieldsParam selects the fields of the returned defs or refs.
+var fieldsParam = apiParam{Name: "fields", Description: "comma-separated names of the fields of each item to return (default: all)"}
+
+var fileFilterParam = apiParam{Name: "file", Description: "only list the items in this file"}
+
+func concatParams(lists ...[]apiParam) []apiParam {
+	var ps []apiParam
+	for _, l := range lists {
+		ps = append(ps, l...)
+	}
+	return ps
+}
+
+var apiEndpoints = []*apiEndpoint{
+	{
+		Path:        "/files",
+		OperationID: "listFiles",
+		Summary:     "List the files that have build data",
+		Result:      []string{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			return s.files, nil
+		},
+	},
+	{
+		Path:        "/symbols",
+		OperationID: "listSymbols",
+		Summary:     "List the non-local defs that are in files, sorted by name",
+		Params:      concatParams(listParams, []apiParam{fileFilterParam}),
+		Result:      []*graph.Def{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			return s.listDefs(s.symbols, q)
+		},
+	},
+	{
+		Path:        "/def",
+		OperationID: "getDef",
+		Summary:     "Get a def",
+		Params:      concatParams(defKeyParams, []apiParam{fieldsParam}),
+		Result:      &graph.Def{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			key, err := s.apiDefKey(q)
+			if err != nil {
+				return nil, err
+			}
+			opt, err := apiListOptions(q, &graph.Def{})
+			if err != nil {
+				return nil, err
+			}
+			if s.defs[key] == nil {
+				return nil, &apiError{http.StatusNotFound, fmt.Sprintf("def %s not found", key.Format())}
+			}
+			return query.Select(s.apiDef(key), opt.Fields)
+		},
+	},
+	{
+		Path:        "/def/refs",
+		OperationID: "listDefRefs",
+		Summary:     "List the refs to a def, sorted by file and position",
+		Params:      concatParams(defKeyParams, listParams, []apiParam{fileFilterParam}),
+		Result:      []*graph.Ref{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			key, err := s.apiDefKey(q)
+			if err != nil {
+				return nil, err
+			}
+			return listRefs(s.refs[key], q)
+		},
+	},
+	{
+		Path:        "/file/refs",
+		OperationID: "listFileRefs",
+		Summary:     "List the refs in a file, sorted by position",
+		Params:      concatParams([]apiParam{{Name: "file", Description: "path of the file, relative to the repository root", Required: true}}, listParams),
+		Result:      []*graph.Ref{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			return listRefs(s.fileRefs[q.Get("file")], q)
+		},
+	},
+	{
+		Path:        "/file/blame",
+		OperationID: "getFileBlame",
+		Summary:     "Get the commit, author, and author date that last changed each hunk of a file, sorted by position",
+		Params:      []apiParam{{Name: "file", Description: "path of the file, relative to the repository root", Required: true}},
+		Result:      []*vcsutil.BlamedHunk{},
+		serve: func(s *Site, q url.Values) (interface{}, error) {
+			if s.Blame == nil {
+				return nil, &apiError{http.StatusNotFound, "no blame data (run `src make` first)"}
+			}
+			hunks := s.Blame.File(q.Get("file"))
+			if hunks == nil {
+				return nil, &apiError{http.StatusNotFound, fmt.Sprintf("file %s was not blamed", q.Get("file"))}
+			}
+			return hunks, nil
+		},
+	},
+}
+
+// apiDefKey returns the def key identified by the query q, whose repo
+// defaults to the site's repository.
+func (s *Site) apiDefKey(q url.Values) (graph.DefKey, error) {
+	key, err := parseDefQuery(q)
+	if err != nil {
+		return graph.DefKey{}, &apiError{http.StatusBadRequest, err.Error()}
+	}
+	if key.Repo == "" {
+		key.Repo = s.RepoURI
+	}
+	return key, nil
+}
+
+// apiDef returns a copy of the def with the given key, with its key's empty
+// fields filled in.
+func (s *Site) apiDef(key graph.DefKey) *graph.Def {
+	def := *s.defs[key]
+	def.DefKey = key
+	return &def
+}
+
+// apiListOptions returns the query options given by the list parameters
+// in q, for a list whose items are like item.
+func apiListOptions(q url.Values, item interface{}) (*query.Options, error) {
+	opt := &query.Options{First: query.NoLimit, After: q.Get("after"), File: q.Get("file")}
+	if s := q.Get("first"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, &apiError{http.StatusBadRequest, fmt.Sprintf("invalid first %q (must be a non-negative integer)", s)}
+		}
+		opt.First = n
+	}
+	if kind := q.Get("kind"); kind != "" {
+		opt.Kinds = []string{kind}
+	}
+	fields, err := query.ParseFields(q.Get("fields"), item)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, err.Error()}
+	}
+	opt.Fields = fields
+	return opt, nil
+}
+
+// An apiPage is a page of a list of defs or refs. Its items are the
+// response body, and its page determines the response's headers (see
+// listParams).
+type apiPage struct {
+	items []interface{}
+	page  *query.Page
+}
+
+// listDefs returns the page of the defs with the given keys selected by
+// the list parameters in q.
+func (s *Site) listDefs(keys []graph.DefKey, q url.Values) (*apiPage, error) {
+	opt, err := apiListOptions(q, &graph.Def{})
+	if err != nil {
+		return nil, err
+	}
+	return newAPIPage(opt, len(keys), func(i int) bool { return opt.MatchDef(s.defs[keys[i]]) }, func(i int) interface{} { return s.apiDef(keys[i]) })
+}
+
+// listRefs returns the page of refs selected by the list parameters in q.
+func listRefs(refs []*graph.Ref, q url.Values) (*apiPage, error) {
+	opt, err := apiListOptions(q, &graph.Ref{})
+	if err != nil {
+		return nil, err
+	}
+	return newAPIPage(opt, len(refs), func(i int) bool { return opt.MatchRef(refs[i]) }, func(i int) interface{} { return refs[i] })
+}
+
+func newAPIPage(opt *query.Options, n int, match func(i int) bool, item func(i int) interface{}) (*apiPage, error) {
+	page, err := opt.Page(n, match)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, err.Error()}
+	}
+	p := &apiPage{items: make([]interface{}, len(page.Indexes)), page: page}
+	for i, j := range page.Indexes {
+		if p.items[i], err = query.Select(item(j), opt.Fields); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// An apiError is an error response from the API. Its JSON encoding (with
+// the message in the Error field) is the response body.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+// serveAPI serves the JSON API endpoints.
+func (s *Site) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeAPIResponse(w, nil, &apiError{http.StatusNotFound, fmt.Sprintf("unsupported API version (the current version is %s)", APIVersion)})
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	if p == "/openapi.json" {
+		writeAPIResponse(w, OpenAPISpec(), nil)
+		return
+	}
+	if p == "/graphql" {
+		s.serveGraphQL(w, r)
+		return
+	}
+
+	var e *apiEndpoint
+	for _, e2 := range apiEndpoints {
+		if e2.Path == p {
+			e = e2
+			break
+		}
+	}
+	if e == nil {
+		writeAPIResponse(w, nil, &apiError{http.StatusNotFound, fmt.Sprintf("no API endpoint %s", r.URL.Path)})
+		return
+	}
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		writeAPIResponse(w, nil, &apiError{http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	q := r.URL.Query()
+	if err := e.validate(q); err != nil {
+		writeAPIResponse(w, nil, err)
+		return
+	}
+	v, err := e.serve(s, q)
+	if p, ok := v.(*apiPage); ok && err == nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(p.page.Total))
+		if next := p.page.Next(); next != "" {
+			q.Set("after", next)
+			u := *r.URL
+			u.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+		}
+		v = p.items
+	}
+	writeAPIResponse(w, v, err)
+}
+
+// validate returns an error if the query q has unknown or repeated
+// parameters, or is missing a required parameter.
+func (e *apiEndpoint) validate(q url.Values) error {
+	known := make(map[string]bool, len(e.Params))
+	for _, p := range e.Params {
+		known[p.Name] = true
+		if p.Required && q.Get(p.Name) == "" {
+			return &apiError{http.StatusBadRequest, fmt.Sprintf("missing required query parameter %q", p.Name)}
+		}
+	}
+	var names []string
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return &apiError{http.StatusBadRequest, fmt.Sprintf("unknown query parameter %q", name)}
+		}
+		if len(q[name]) > 1 {
+			return &apiError{http.StatusBadRequest, fmt.Sprintf("query parameter %q given more than once", name)}
+		}
+	}
+	return nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*apiError); ok {
+			status = e.Status
+		}
+		w.WriteHeader(status)
+		v = struct{ Error string }{err.Error()}
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
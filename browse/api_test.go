@@ -0,0 +1,145 @@
+package browse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-blame/blame"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/vcsutil"
+)
+
+func TestAPI(t *testing.T) {
+	site := newTestSite()
+	site.Blame = &vcsutil.BlameOutput{
+		CommitMap: map[string]blame.Commit{"c1": {ID: "c1", Author: blame.Author{Name: "A", Email: "a@example.com"}}},
+		HunkMap:   map[string][]blame.Hunk{"a.go": {{CommitID: "c1", LineStart: 3, LineEnd: 5}, {CommitID: "c2", LineStart: 1, LineEnd: 3}}},
+	}
+	s := httptest.NewServer(site.Handler())
+	defer s.Close()
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		return resp.StatusCode
+	}
+
+	var files []string
+	if get("/api/v1/files", &files); len(files) != 2 {
+		t.Errorf("got files %v, want 2", files)
+	}
+
+	var def *graph.Def
+	if status := get("/api/v1/def?unittype=t&unit=u&path=f", &def); status != http.StatusOK || def.Repo != "r" || def.Unit != "u" || def.Name != "f" {
+		t.Errorf("got status %d, def %+v", status, def)
+	}
+
+	var refs []*graph.Ref
+	if get("/api/v1/def/refs?repo=r&unittype=t&unit=u&path=f", &refs); len(refs) != 2 || refs[1].File != "b.go" {
+		t.Errorf("got refs %+v to f, want 2", refs)
+	}
+	if get("/api/v1/def/refs?def=r%3At%3Au%3Af", &refs); len(refs) != 2 {
+		t.Errorf("got refs %+v to f by its canonical key, want 2", refs)
+	}
+	if get("/api/v1/file/refs?file=b.go", &refs); len(refs) != 2 || refs[1].DefPath != "Println" {
+		t.Errorf("got refs %+v in b.go, want 2", refs)
+	}
+	if get("/api/v1/def/refs?def=r:t:u:f&file=b.go", &refs); len(refs) != 1 || refs[0].File != "b.go" {
+		t.Errorf("got refs %+v to f in b.go, want 1", refs)
+	}
+	var fields []map[string]interface{}
+	if get("/api/v1/file/refs?file=b.go&fields=file,start", &fields); len(fields) != 2 || len(fields[0]) != 2 || fields[0]["File"] != "b.go" {
+		t.Errorf("got refs %v with fields File and Start", fields)
+	}
+
+	// Page through the refs in b.go.
+	var pages [][]*graph.Ref
+	for path := "/api/v1/file/refs?file=b.go&first=1"; path != ""; {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []*graph.Ref
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total := resp.Header.Get("X-Total-Count"); total != "2" {
+			t.Errorf("%s: got X-Total-Count %q, want 2", path, total)
+		}
+		pages = append(pages, page)
+		path = ""
+		if link := resp.Header.Get("Link"); link != "" {
+			path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+		if len(pages) > 2 {
+			t.Fatal("too many pages")
+		}
+	}
+	if len(pages) != 2 || len(pages[0]) != 1 || len(pages[1]) != 1 || pages[1][0].DefPath != "Println" {
+		t.Errorf("got pages %+v, want 2 pages of 1 ref", pages)
+	}
+
+	var hunks []*vcsutil.BlamedHunk
+	if get("/api/v1/file/blame?file=a.go", &hunks); len(hunks) != 2 || hunks[0].CommitID != "c2" || hunks[1].AuthorEmail != "a@example.com" {
+		t.Errorf("got blame %+v of a.go", hunks)
+	}
+
+	for path, wantStatus := range map[string]int{
+		"/api/v1/file/blame?file=b.go":             http.StatusNotFound,
+		"/api/v1/def?unittype=t&unit=u&path=g":     http.StatusNotFound,
+		"/api/v1/def?unittype=t&unit=u":            http.StatusBadRequest,
+		"/api/v1/def?unittype=t&unit=u&path=f&x=1": http.StatusBadRequest,
+		"/api/v1/def?def=r:t:u:g":                  http.StatusNotFound,
+		"/api/v1/def?def=r:t:u":                    http.StatusBadRequest,
+		"/api/v1/def?def=r:t:u:f&path=f":           http.StatusBadRequest,
+		"/api/v1/file/refs?file=a.go&file=b.go":    http.StatusBadRequest,
+		"/api/v1/file/refs?file=a.go&first=-1":     http.StatusBadRequest,
+		"/api/v1/file/refs?file=a.go&after=x":      http.StatusBadRequest,
+		"/api/v1/file/refs?file=a.go&fields=x":     http.StatusBadRequest,
+		"/api/v1/nonexistent":                      http.StatusNotFound,
+		"/api/v2/files":                            http.StatusNotFound,
+	} {
+		var e struct{ Error string }
+		if status := get(path, &e); status != wantStatus || e.Error == "" {
+			t.Errorf("%s: got status %d and error %q, want status %d and an error", path, status, e.Error, wantStatus)
+		}
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	spec := OpenAPISpec()
+	paths := spec["paths"].(map[string]interface{})
+	if len(paths) != len(apiEndpoints)+2 { // +2 for the GraphQL and event stream endpoints
+		t.Errorf("got %d paths, want %d", len(paths), len(apiEndpoints)+2)
+	}
+	if _, present := paths["/api/v1/def/refs"]; !present {
+		t.Error("no /api/v1/def/refs path")
+	}
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	def, present := schemas["Def"].(map[string]interface{})
+	if !present {
+		t.Fatalf("no Def schema in %v", schemas)
+	}
+	props := def["properties"].(map[string]interface{})
+	// Embedded DefKey's fields are promoted, and JSON field names are used.
+	for _, name := range []string{"Path", "Repo", "Name", "DefStart", "Data"} {
+		if _, present := props[name]; !present {
+			t.Errorf("Def schema has no property %q", name)
+		}
+	}
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatal(err)
+	}
+}
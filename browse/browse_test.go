@@ -0,0 +1,188 @@
+package browse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+)
+
+// newTestSite returns a site for a repository with two files.
+func newTestSite() *Site {
+	files := map[string]string{
+		"a.go": "func f() {}\n",
+		"b.go": "x := f()\nfmt.Println(x < 1)\n",
+	}
+	readFile := func(file string) ([]byte, error) {
+		if src, present := files[file]; present {
+			return []byte(src), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	return NewSite("r", []*Unit{{
+		Type: "t",
+		Name: "u",
+		Output: &grapher.Output{
+			Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "f"}, Name: "f", Kind: "func", File: "a.go", DefStart: 0, DefEnd: 11}},
+			Refs: []*graph.Ref{
+				{DefPath: "f", File: "a.go", Start: 5, End: 6, Def: true},
+				{DefPath: "f", File: "b.go", Start: 5, End: 6},
+				{DefRepo: "fmt", DefUnitType: "t", DefUnit: "fmt", DefPath: "Println", File: "b.go", Start: 13, End: 20},
+			},
+			Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "f"}, Format: "text/html", Data: "<p>f does &lt;nothing&gt;.</p>"}},
+		},
+	}}, readFile)
+}
+
+func TestHandler(t *testing.T) {
+	s := httptest.NewServer(newTestSite().Handler())
+	defer s.Close()
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+	fKey := "r:t:u:f"
+
+	if _, body := get("/"); !strings.Contains(body, `href="/file/a.go"`) || !strings.Contains(body, `href="/file/b.go"`) {
+		t.Errorf("index doesn't link to files:\n%s", body)
+	}
+	if _, body := get("/symbols"); !strings.Contains(body, `<a href="/def/r:t:u:f">f</a>`) {
+		t.Errorf("symbols page doesn't link to defs:\n%s", body)
+	}
+
+	_, body := get("/file/b.go")
+	for _, want := range []string{
+		`x := <a href="/def/r:t:u:f" title="f does &lt;nothing&gt;." class="ref tok-function">f</a>()`,
+		`class="ref external">Println</a>(x &lt; 1)`,
+		`<span class="line" id="L2">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("file page doesn't contain %q:\n%s", want, body)
+		}
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(s.URL + "/def/" + fKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if loc, want := resp.Header.Get("location"), "/file/a.go#L1"; loc != want {
+		t.Errorf("jump to def redirected to %q, want %q", loc, want)
+	}
+
+	if _, body := get("/refs/" + fKey); !strings.Contains(body, `<a href="/file/a.go#L1">a.go:1</a>`) || !strings.Contains(body, `<a href="/file/b.go#L1">b.go:1</a> <code>x := f()</code>`) {
+		t.Errorf("refs page doesn't list refs:\n%s", body)
+	}
+
+	if resp, _ := get("/file/c.go"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d for nonexistent file, want 404", resp.StatusCode)
+	}
+}
+
+func TestWriteStatic(t *testing.T) {
+	pages := make(map[string]string)
+	writeFile := func(path string, data []byte) error {
+		pages[path] = string(data)
+		return nil
+	}
+	if err := newTestSite().WriteStatic(writeFile); err != nil {
+		t.Fatal(err)
+	}
+
+	refsPage := refsPagePath(graph.DefKey{Repo: "r", UnitType: "t", Unit: "u", Path: "f"})
+	want := map[string][]string{
+		"index.html":   {`<a href="file/a.go.html">a.go</a>`},
+		"symbols.html": {`<a href="file/a.go.html#L1">f</a>`, `<a href="` + refsPage + `">2 refs</a>`},
+		"file/a.go.html": {
+			`<a href="../index.html">Files</a>`,
+			`func <a href="../` + refsPage + `" title="f does &lt;nothing&gt;." class="def tok-function tok-declaration">f</a>() {}`,
+		},
+		"file/b.go.html": {
+			`x := <a href="../file/a.go.html#L1" title="f does &lt;nothing&gt;." class="ref tok-function">f</a>()`,
+			`<span title="" class="ref external">Println</span>`,
+		},
+		refsPage: {`<a href="../file/b.go.html#L1">b.go:1</a> <code>x := f()</code>`},
+	}
+	if len(pages) != len(want) {
+		var paths []string
+		for path := range pages {
+			paths = append(paths, path)
+		}
+		t.Errorf("got pages %v, want %d pages", paths, len(want))
+	}
+	for path, wantStrs := range want {
+		for _, w := range wantStrs {
+			if !strings.Contains(pages[path], w) {
+				t.Errorf("%s doesn't contain %q:\n%s", path, w, pages[path])
+			}
+		}
+	}
+}
+
+func TestNewSite_invalidFiles(t *testing.T) {
+	s := NewSite("r", []*Unit{{
+		Type:  "t",
+		Name:  "u",
+		Files: []string{"a.go", "../x.go", "/etc/passwd", "b/../../y.go", "./c.go"},
+		Output: &grapher.Output{
+			Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "f"}, Name: "f", File: "../../.bashrc"}},
+			Refs: []*graph.Ref{{DefPath: "f", File: "..", Start: 0, End: 1}},
+		},
+	}}, newTestSite().ReadFile)
+	if want := []string{"a.go"}; !reflect.DeepEqual(s.files, want) {
+		t.Errorf("got files %q, want %q", s.files, want)
+	}
+
+	var paths []string
+	writeFile := func(path string, data []byte) error {
+		paths = append(paths, path)
+		return nil
+	}
+	if err := s.WriteStatic(writeFile); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if !validFile(path) {
+			t.Errorf("wrote page %q outside of the site", path)
+		}
+	}
+}
+
+// FuzzHandler checks that the site's pages can be served, without
+// panicking, for any graph output that grapher.ReadOutput accepts.
+func FuzzHandler(f *testing.F) {
+	f.Add([]byte(`{"Defs":[{"Path":"f","Name":"f","Kind":"func","File":"a.go","DefStart":0,"DefEnd":11}],"Refs":[{"DefPath":"f","File":"a.go","Start":5,"End":6,"Def":true},{"DefPath":"f","File":"b.go","Start":5,"End":6}],"Docs":[{"Path":"f","Format":"text/html","Data":"<p>f.</p>"}]}`))
+	f.Add([]byte(`{"Defs":[{"Path":"g","Name":"g","File":"b.go","DefStart":-1,"DefEnd":99}],"Refs":[{"DefPath":"g","File":"b.go","Start":30,"End":2}]}`))
+	site := newTestSite()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		o, err := grapher.ReadOutput(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		s := NewSite("r", []*Unit{{Type: "t", Name: "u", Files: []string{"a.go", "b.go"}, Output: o}}, site.ReadFile)
+		paths := []string{"/", "/symbols", "/file/a.go", "/file/b.go", "/api/v1/files", "/api/v1/defs"}
+		for _, key := range s.Symbols() {
+			paths = append(paths, serverLinker{}.defURL(key), serverLinker{}.refsURL(key), "/api/v1/def?"+defQuery(key), "/api/v1/def/refs?"+defQuery(key))
+		}
+		h := s.Handler()
+		for _, path := range paths {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}
+	})
+}
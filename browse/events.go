@@ -0,0 +1,242 @@
+package browse
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// Event types.
+const (
+	// UnitRebuilt is sent when a source unit's graph data is added or
+	// changed.
+	UnitRebuilt = "unitRebuilt"
+
+	// UnitRemoved is sent when a source unit's graph data is removed.
+	UnitRemoved = "unitRemoved"
+
+	// DefsChanged is sent when the defs in a file are added, removed, or
+	// changed.
+	DefsChanged = "defsChanged"
+
+	// RefsChanged is sent when the refs in a file are added, removed, or
+	// changed.
+	RefsChanged = "refsChanged"
+)
+
+// An Event describes a change to a site's build data. UnitType and Unit are
+// set for unit events, and File is set for file events.
+type Event struct {
+	Type     string
+	UnitType string `json:",omitempty"`
+	Unit     string `json:",omitempty"`
+	File     string `json:",omitempty"`
+}
+
+// Changes returns the events that describe the changes from the old site
+// to the new one. A unit is considered rebuilt if its graph output is not
+// the same (pointer) as the old site's, so that callers can reuse unchanged
+// units' outputs to avoid spurious events.
+func Changes(old, new *Site) []*Event {
+	var events []*Event
+
+	oldUnits := make(map[[2]string]*Unit, len(old.units))
+	for _, u := range old.units {
+		oldUnits[[2]string{u.Type, u.Name}] = u
+	}
+	for _, u := range new.units {
+		id := [2]string{u.Type, u.Name}
+		if o := oldUnits[id]; o == nil || o.Output != u.Output {
+			events = append(events, &Event{Type: UnitRebuilt, UnitType: u.Type, Unit: u.Name})
+		}
+		delete(oldUnits, id)
+	}
+	for _, u := range old.units {
+		if oldUnits[[2]string{u.Type, u.Name}] != nil {
+			events = append(events, &Event{Type: UnitRemoved, UnitType: u.Type, Unit: u.Name})
+		}
+	}
+
+	files := make(map[string]bool)
+	for _, f := range old.files {
+		files[f] = true
+	}
+	for _, f := range new.files {
+		files[f] = true
+	}
+	var sorted []string
+	for f := range files {
+		sorted = append(sorted, f)
+	}
+	sort.Strings(sorted)
+	oldDefs, newDefs := old.fileDefs(), new.fileDefs()
+	for _, f := range sorted {
+		if !reflect.DeepEqual(oldDefs[f], newDefs[f]) {
+			events = append(events, &Event{Type: DefsChanged, File: f})
+		}
+		if !reflect.DeepEqual(old.fileRefs[f], new.fileRefs[f]) {
+			events = append(events, &Event{Type: RefsChanged, File: f})
+		}
+	}
+	return events
+}
+
+// fileDefs returns the site's defs (with their keys filled in) in each
+// file, sorted by key.
+func (s *Site) fileDefs() map[string][]*graph.Def {
+	var keys []graph.DefKey
+	for key := range s.defs {
+		keys = append(keys, key)
+	}
+	sort.Sort(defKeys(keys))
+	defs := make(map[string][]*graph.Def)
+	for _, key := range keys {
+		if def := s.defs[key]; def.File != "" {
+			defs[def.File] = append(defs[def.File], s.apiDef(key))
+		}
+	}
+	return defs
+}
+
+type defKeys []graph.DefKey
+
+func (v defKeys) Len() int      { return len(v) }
+func (v defKeys) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v defKeys) Less(i, j int) bool {
+	a, b := v[i], v[j]
+	if a.Repo != b.Repo {
+		return a.Repo < b.Repo
+	}
+	if a.UnitType != b.UnitType {
+		return a.UnitType < b.UnitType
+	}
+	if a.Unit != b.Unit {
+		return a.Unit < b.Unit
+	}
+	return a.Path < b.Path
+}
+
+// A Server serves a site that can be replaced while it is being served
+// (e.g., when the build data is rebuilt), and streams events describing
+// each replacement's changes to clients of "/api/APIVersion/events" as
+// server-sent events.
+type Server struct {
+	mu      sync.RWMutex
+	site    *Site
+	handler http.Handler
+	clients map[chan *Event]struct{}
+}
+
+// NewServer returns a server that serves site.
+func NewServer(site *Site) *Server {
+	return &Server{site: site, handler: site.Handler(), clients: make(map[chan *Event]struct{})}
+}
+
+// Site returns the site being served.
+func (srv *Server) Site() *Site {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return srv.site
+}
+
+// Update replaces the site being served and sends the events describing
+// the changes (see Changes) to the connected clients. It returns the
+// events.
+func (srv *Server) Update(site *Site) []*Event {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	events := Changes(srv.site, site)
+	srv.site, srv.handler = site, site.Handler()
+	for c := range srv.clients {
+		if !sendEvents(c, events) {
+			// The client isn't keeping up, so disconnect it. It can
+			// reconnect and refetch what it needs.
+			close(c)
+			delete(srv.clients, c)
+		}
+	}
+	return events
+}
+
+// sendEvents sends events to c without blocking. It returns false if c's
+// buffer is full.
+func sendEvents(c chan<- *Event, events []*Event) bool {
+	for _, e := range events {
+		select {
+		case c <- e:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// eventBuffer is the number of events that are buffered for each client.
+const eventBuffer = 100
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == apiPrefix+"/events" {
+		srv.serveEvents(w, r)
+		return
+	}
+	srv.mu.RLock()
+	h := srv.handler
+	srv.mu.RUnlock()
+	h.ServeHTTP(w, r)
+}
+
+// serveEvents streams events to the client as server-sent events, with the
+// event type in the event field and its JSON encoding in the data field.
+func (srv *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		writeAPIResponse(w, nil, &apiError{http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIResponse(w, nil, &apiError{http.StatusInternalServerError, "streaming is not supported"})
+		return
+	}
+
+	c := make(chan *Event, eventBuffer)
+	srv.mu.Lock()
+	srv.clients[c] = struct{}{}
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.clients, c)
+		srv.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("Error encoding event: %s.", err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package browse
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+)
+
+// rebuiltTestSite returns a copy of site whose unit u is rebuilt with its
+// def f moved and a new ref to it in c.go.
+func rebuiltTestSite(site *Site) *Site {
+	u := *site.units[0]
+	out := *u.Output
+	def := *out.Defs[0]
+	def.DefStart, def.DefEnd = 1, 12
+	out.Defs = []*graph.Def{&def}
+	out.Refs = append(out.Refs[:len(out.Refs):len(out.Refs)], &graph.Ref{DefPath: "f", File: "c.go", Start: 0, End: 1})
+	u.Output = &out
+	return NewSite(site.RepoURI, []*Unit{&u}, site.ReadFile)
+}
+
+func TestChanges(t *testing.T) {
+	old := newTestSite()
+	if events := Changes(old, NewSite(old.RepoURI, old.units, old.ReadFile)); len(events) != 0 {
+		t.Errorf("got events %+v for an unchanged site, want none", events)
+	}
+
+	want := []*Event{
+		{Type: UnitRebuilt, UnitType: "t", Unit: "u"},
+		{Type: DefsChanged, File: "a.go"},
+		{Type: RefsChanged, File: "c.go"},
+	}
+	if events := Changes(old, rebuiltTestSite(old)); !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %+v, want %+v", events, want)
+	}
+
+	empty := NewSite(old.RepoURI, []*Unit{{Type: "t", Name: "v", Output: &grapher.Output{}}}, old.ReadFile)
+	want = []*Event{
+		{Type: UnitRebuilt, UnitType: "t", Unit: "v"},
+		{Type: UnitRemoved, UnitType: "t", Unit: "u"},
+		{Type: DefsChanged, File: "a.go"},
+		{Type: RefsChanged, File: "a.go"},
+		{Type: RefsChanged, File: "b.go"},
+	}
+	if events := Changes(old, empty); !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %+v, want %+v", events, want)
+	}
+}
+
+func TestServer_events(t *testing.T) {
+	srv := NewServer(newTestSite())
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/api/v1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	if e := readEvent(); e != ": connected\n" {
+		t.Fatalf("got %q, want the connection comment", e)
+	}
+
+	srv.Update(rebuiltTestSite(srv.Site()))
+	for _, want := range []string{
+		"event: unitRebuilt\ndata: {\"Type\":\"unitRebuilt\",\"UnitType\":\"t\",\"Unit\":\"u\"}\n",
+		"event: defsChanged\ndata: {\"Type\":\"defsChanged\",\"File\":\"a.go\"}\n",
+		"event: refsChanged\ndata: {\"Type\":\"refsChanged\",\"File\":\"c.go\"}\n",
+	} {
+		if e := readEvent(); e != want {
+			t.Errorf("got event %q, want %q", e, want)
+		}
+	}
+
+	// The new site is served.
+	resp2, err := http.Get(s.URL + "/api/v1/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	if body, _ := bufio.NewReader(resp2.Body).ReadString('\n'); !strings.Contains(body, "c.go") {
+		t.Errorf("got files %s, want c.go to be listed", body)
+	}
+}
@@ -0,0 +1,427 @@
+package browse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A gqlObject is an object type in the GraphQL schema.
+type gqlObject struct {
+	name   string
+	desc   string
+	fields []*gqlFieldDef
+}
+
+func (o *gqlObject) field(name string) *gqlFieldDef {
+	for _, f := range o.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// A gqlFieldDef is a field of an object type in the GraphQL schema.
+type gqlFieldDef struct {
+	name string
+	typ  string // in SDL syntax, e.g. "[Def!]!"
+	args []gqlArgDef
+	desc string
+
+	// resolve returns the field's value, given the value of the object
+	// (src) and the coerced arguments.
+	resolve func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+// A gqlArgDef is an argument of a field in the GraphQL schema.
+type gqlArgDef struct {
+	name string
+	typ  string // String, Int, or Boolean, with "!" if required
+}
+
+// gqlScalars are the scalar types in the GraphQL schema.
+var gqlScalars = map[string]bool{"String": true, "Int": true, "Boolean": true}
+
+// A graphQLRequest is a GraphQL request (sent as JSON in the body of a POST
+// request, or as query parameters in a GET request).
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// A graphQLResponse is the response to a GraphQL request.
+type graphQLResponse struct {
+	Data   interface{}       `json:"data"`
+	Errors []graphQLErrorMsg `json:"errors,omitempty"`
+}
+
+type graphQLErrorMsg struct {
+	Message string `json:"message"`
+}
+
+// serveGraphQL serves the GraphQL endpoint.
+func (s *Site) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeAPIResponse(w, nil, &apiError{http.StatusBadRequest, "invalid variables: " + err.Error()})
+				return
+			}
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAPIResponse(w, nil, &apiError{http.StatusBadRequest, "invalid GraphQL request: " + err.Error()})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIResponse(w, nil, &apiError{http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	var resp graphQLResponse
+	data, err := s.execGraphQL(&req)
+	if err != nil {
+		resp.Errors = []graphQLErrorMsg{{Message: err.Error()}}
+	} else {
+		resp.Data = data
+	}
+	writeAPIResponse(w, resp, nil)
+}
+
+// execGraphQL executes a GraphQL request and returns the response data.
+func (s *Site) execGraphQL(req *graphQLRequest) (interface{}, error) {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var op *gqlOperation
+	if req.OperationName == "" {
+		if len(doc.ops) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with multiple operations")
+		}
+		op = doc.ops[0]
+	} else {
+		for _, op2 := range doc.ops {
+			if op2.name == req.OperationName {
+				op = op2
+			}
+		}
+		if op == nil {
+			return nil, fmt.Errorf("no operation named %q", req.OperationName)
+		}
+	}
+
+	vars := make(map[string]interface{}, len(op.vars))
+	for _, v := range op.vars {
+		val, present := req.Variables[v.name]
+		if !present {
+			val = v.def
+		}
+		if val == nil {
+			if strings.HasSuffix(v.typ, "!") {
+				return nil, fmt.Errorf("variable $%s of required type %s was not provided", v.name, v.typ)
+			}
+			continue
+		}
+		if val, err = coerceGraphQLInput(v.typ, val); err != nil {
+			return nil, fmt.Errorf("variable $%s: %s", v.name, err)
+		}
+		vars[v.name] = val
+	}
+
+	e := &gqlExecutor{s: s, doc: doc, vars: vars}
+	return e.selectionSet(gqlQueryType, nil, op.sel)
+}
+
+// gqlExecutor executes a GraphQL operation.
+type gqlExecutor struct {
+	s    *Site
+	doc  *gqlDocument
+	vars map[string]interface{}
+}
+
+// selectionSet returns the result of the selections on the object src of
+// type obj.
+func (e *gqlExecutor) selectionSet(obj *gqlObject, src interface{}, sel []gqlSelection) (*gqlResult, error) {
+	keys, fields, err := e.collectFields(obj, sel, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &gqlResult{}
+	for _, key := range keys {
+		f := fields[key][0]
+		if f.name == "__typename" {
+			res.add(key, obj.name)
+			continue
+		}
+		fd := obj.field(f.name)
+		if fd == nil {
+			return nil, fmt.Errorf("cannot query field %q on type %s", f.name, obj.name)
+		}
+		args, err := e.coerceArgs(fd, f)
+		if err != nil {
+			return nil, err
+		}
+		v, err := fd.resolve(e.s, src, args)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", obj.name, fd.name, err)
+		}
+
+		// Merge the selection sets of the fields with the same response key.
+		var subSel []gqlSelection
+		for _, f2 := range fields[key] {
+			if f2.name != f.name {
+				return nil, fmt.Errorf("fields %q and %q conflict because they have the same response name %q", f.name, f2.name, key)
+			}
+			subSel = append(subSel, f2.sel...)
+		}
+		v, err = e.complete(fd.typ, v, subSel, obj.name+"."+fd.name)
+		if err != nil {
+			return nil, err
+		}
+		res.add(key, v)
+	}
+	return res, nil
+}
+
+// collectFields returns the fields selected on an object of type obj by
+// sel, grouped by response key (in order of first appearance), following
+// fragment spreads and inline fragments. Because the schema has no
+// interfaces or unions, a fragment's type condition must be obj's type.
+// The spreading map contains the fragments being spread, to detect cycles.
+func (e *gqlExecutor) collectFields(obj *gqlObject, sel []gqlSelection, spreading map[string]bool) ([]string, map[string][]*gqlField, error) {
+	var keys []string
+	fields := make(map[string][]*gqlField)
+	add := func(keys2 []string, fields2 map[string][]*gqlField) {
+		for _, k := range keys2 {
+			if _, present := fields[k]; !present {
+				keys = append(keys, k)
+			}
+			fields[k] = append(fields[k], fields2[k]...)
+		}
+	}
+
+	for _, s := range sel {
+		var typeCond string
+		var fragSel []gqlSelection
+		switch s := s.(type) {
+		case *gqlField:
+			add([]string{s.alias}, map[string][]*gqlField{s.alias: {s}})
+			continue
+		case *gqlFragmentSpread:
+			frag, present := e.doc.frags[s.name]
+			if !present {
+				return nil, nil, fmt.Errorf("unknown fragment %q", s.name)
+			}
+			if spreading[s.name] {
+				return nil, nil, fmt.Errorf("fragment %q spreads itself", s.name)
+			}
+			typeCond, fragSel = frag.typeCond, frag.sel
+		case *gqlInlineFragment:
+			typeCond, fragSel = s.typeCond, s.sel
+		}
+		if typeCond != "" && typeCond != obj.name {
+			if gqlTypes[typeCond] == nil {
+				return nil, nil, fmt.Errorf("unknown type %q in fragment type condition", typeCond)
+			}
+			return nil, nil, fmt.Errorf("fragment on type %s can't be spread in a selection on type %s", typeCond, obj.name)
+		}
+		spread, isSpread := s.(*gqlFragmentSpread)
+		if isSpread {
+			spreading[spread.name] = true
+		}
+		keys2, fields2, err := e.collectFields(obj, fragSel, spreading)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isSpread {
+			delete(spreading, spread.name)
+		}
+		add(keys2, fields2)
+	}
+	return keys, fields, nil
+}
+
+// coerceArgs returns the values of the arguments of the field f (whose
+// definition is fd), with variables substituted.
+func (e *gqlExecutor) coerceArgs(fd *gqlFieldDef, f *gqlField) (map[string]interface{}, error) {
+	given := make(map[string]interface{}, len(f.args))
+	for _, a := range f.args {
+		given[a.name] = a.value
+	}
+
+	args := make(map[string]interface{}, len(fd.args))
+	for _, ad := range fd.args {
+		v, present := given[ad.name]
+		delete(given, ad.name)
+		if name, isVar := v.(gqlVariable); isVar {
+			v, present = e.vars[string(name)]
+		}
+		if !present || v == nil {
+			if strings.HasSuffix(ad.typ, "!") {
+				return nil, fmt.Errorf("argument %q of field %q is required", ad.name, fd.name)
+			}
+			continue
+		}
+		v, err := coerceGraphQLInput(ad.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q of field %q: %s", ad.name, fd.name, err)
+		}
+		args[ad.name] = v
+	}
+	for name := range given {
+		return nil, fmt.Errorf("unknown argument %q of field %q", name, fd.name)
+	}
+	return args, nil
+}
+
+// coerceGraphQLInput coerces an input value (from a query literal or the
+// JSON request variables) to the scalar type typ.
+func coerceGraphQLInput(typ string, v interface{}) (interface{}, error) {
+	switch strings.TrimSuffix(typ, "!") {
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == float64(int(n)) {
+				return int(n), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported input type %s", typ)
+	}
+	return nil, fmt.Errorf("expected a value of type %s, got %v", typ, v)
+}
+
+// complete returns the result for the value v of a field of type typ.
+func (e *gqlExecutor) complete(typ string, v interface{}, sel []gqlSelection, field string) (interface{}, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+
+	rv := reflect.ValueOf(v)
+	if v == nil || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil()) {
+		if nonNull {
+			return nil, fmt.Errorf("%s: null value for non-null type %s!", field, typ)
+		}
+		return nil, nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		elemType := typ[1 : len(typ)-1]
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			var err error
+			if list[i], err = e.complete(elemType, rv.Index(i).Interface(), sel, field); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	if gqlScalars[typ] {
+		if len(sel) > 0 {
+			return nil, fmt.Errorf("%s: field of scalar type %s must not have a selection set", field, typ)
+		}
+		return v, nil
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("%s: field of object type %s must have a selection set", field, typ)
+	}
+	return e.selectionSet(gqlTypes[typ], v, sel)
+}
+
+// A gqlResult is the result of a selection set, a JSON object whose keys are
+// in the order of the selections.
+type gqlResult struct {
+	keys   []string
+	values []interface{}
+}
+
+func (r *gqlResult) add(key string, v interface{}) {
+	r.keys = append(r.keys, key)
+	r.values = append(r.values, v)
+}
+
+func (r *gqlResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// GraphQLSchema returns the schema of the GraphQL endpoint (served at
+// "/api/APIVersion/graphql") in the GraphQL schema definition language.
+func GraphQLSchema() string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "schema {\n  query: Query\n}")
+	var names []string
+	for name := range gqlTypes {
+		if name != gqlQueryType.name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{gqlQueryType.name}, names...)
+	for _, name := range names {
+		t := gqlTypes[name]
+		fmt.Fprintln(&buf)
+		if t.desc != "" {
+			fmt.Fprintf(&buf, "# %s\n", t.desc)
+		}
+		fmt.Fprintf(&buf, "type %s {\n", t.name)
+		for _, f := range t.fields {
+			if f.desc != "" {
+				fmt.Fprintf(&buf, "  # %s\n", f.desc)
+			}
+			fmt.Fprintf(&buf, "  %s", f.name)
+			if len(f.args) > 0 {
+				args := make([]string, len(f.args))
+				for i, a := range f.args {
+					args[i] = a.name + ": " + a.typ
+				}
+				fmt.Fprintf(&buf, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&buf, ": %s\n", f.typ)
+		}
+		fmt.Fprintln(&buf, "}")
+	}
+	return buf.String()
+}
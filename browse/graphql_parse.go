@@ -0,0 +1,456 @@
+package browse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file contains a parser for the subset of the GraphQL query language
+// that the GraphQL endpoint supports: query operations with variables,
+// fields with aliases and arguments, and named and inline fragments.
+// Directives, mutations, and subscriptions are not supported.
+
+// A gqlDocument is a parsed GraphQL query document.
+type gqlDocument struct {
+	ops   []*gqlOperation
+	frags map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	name string
+	vars []*gqlVarDef
+	sel  []gqlSelection
+}
+
+type gqlVarDef struct {
+	name string
+	typ  string      // type in SDL syntax, e.g. "[String!]"
+	def  interface{} // default value, or nil
+}
+
+type gqlFragment struct {
+	typeCond string
+	sel      []gqlSelection
+}
+
+// A gqlSelection is a *gqlField, *gqlFragmentSpread, or *gqlInlineFragment.
+type gqlSelection interface{}
+
+type gqlField struct {
+	alias string // response key (the name if there is no alias)
+	name  string
+	args  []gqlArg
+	sel   []gqlSelection
+}
+
+type gqlArg struct {
+	name  string
+	value interface{}
+}
+
+type gqlFragmentSpread struct{ name string }
+
+type gqlInlineFragment struct {
+	typeCond string // empty if none
+	sel      []gqlSelection
+}
+
+// A gqlVariable is a reference to a variable in an argument value. Other
+// values are represented by nil, bool, int, float64, string, gqlEnum,
+// []interface{}, and map[string]interface{}.
+type gqlVariable string
+
+type gqlEnum string
+
+// parseGraphQL parses a GraphQL query document.
+func parseGraphQL(query string) (*gqlDocument, error) {
+	p := &gqlParser{src: query}
+	p.next()
+	doc := &gqlDocument{frags: make(map[string]*gqlFragment)}
+	for p.tok != "" {
+		switch {
+		case p.tok == "{":
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, &gqlOperation{sel: sel})
+		case p.tok == "query":
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, op)
+		case p.tok == "fragment":
+			p.next()
+			name := p.tok
+			if !p.isName() || name == "on" {
+				return nil, p.errorf("expected fragment name")
+			}
+			p.next()
+			if err := p.expect("on"); err != nil {
+				return nil, err
+			}
+			f := &gqlFragment{typeCond: p.tok}
+			if !p.isName() {
+				return nil, p.errorf("expected type condition")
+			}
+			p.next()
+			var err error
+			if f.sel, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			if _, present := doc.frags[name]; present {
+				return nil, fmt.Errorf("fragment %q is defined more than once", name)
+			}
+			doc.frags[name] = f
+		case p.tok == "mutation" || p.tok == "subscription":
+			return nil, p.errorf("%ss are not supported", p.tok)
+		default:
+			return nil, p.errorf("expected query or fragment")
+		}
+	}
+	if len(doc.ops) == 0 {
+		return nil, fmt.Errorf("no operation in GraphQL document")
+	}
+	return doc, nil
+}
+
+// gqlParser is a GraphQL lexer and recursive descent parser.
+type gqlParser struct {
+	src string
+	pos int
+
+	tok    string // current token ("" at EOF); strings are quoted
+	tokPos int
+	err    error // lexing error
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	line := 1 + strings.Count(p.src[:p.tokPos], "\n")
+	col := p.tokPos - strings.LastIndex(p.src[:p.tokPos], "\n")
+	return fmt.Errorf("GraphQL syntax error at line %d, column %d (at %q): %s", line, col, p.tok, fmt.Sprintf(format, args...))
+}
+
+func (p *gqlParser) isName() bool {
+	if p.tok == "" {
+		return false
+	}
+	c := p.tok[0]
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func (p *gqlParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.errorf("expected %q", tok)
+	}
+	p.next()
+	return nil
+}
+
+// next advances to the next token.
+func (p *gqlParser) next() {
+	// Skip ignored tokens: whitespace, commas, and comments.
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	p.tokPos = p.pos
+	if p.pos == len(p.src) {
+		p.tok = ""
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("!$():=@[]{}|", c) != -1:
+		p.pos++
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+	case c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z'):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+	case c == '-' || ('0' <= c && c <= '9'):
+		p.pos++
+		for p.pos < len(p.src) && (isNameChar(p.src[p.pos]) || p.src[p.pos] == '.' || ((p.src[p.pos] == '-' || p.src[p.pos] == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E'))) {
+			p.pos++
+		}
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' && p.src[p.pos] != '\n' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+			p.tok = p.src[start:]
+			p.err = p.errorf("unterminated string")
+			p.tok, p.pos = "", len(p.src)
+			return
+		}
+		p.pos++
+	default:
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	p.next() // "query"
+	op := &gqlOperation{}
+	if p.isName() {
+		op.name = p.tok
+		p.next()
+	}
+	if p.tok == "(" {
+		p.next()
+		for p.tok != ")" {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			if !p.isName() {
+				return nil, p.errorf("expected variable name")
+			}
+			v := &gqlVarDef{name: p.tok}
+			p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			var err error
+			if v.typ, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			if p.tok == "=" {
+				p.next()
+				if v.def, err = p.value(true); err != nil {
+					return nil, err
+				}
+			}
+			op.vars = append(op.vars, v)
+		}
+		p.next()
+	}
+	if p.tok == "@" {
+		return nil, p.errorf("directives are not supported")
+	}
+	var err error
+	op.sel, err = p.selectionSet()
+	return op, err
+}
+
+// typeRef parses a type reference and returns it in SDL syntax.
+func (p *gqlParser) typeRef() (string, error) {
+	var t string
+	if p.tok == "[" {
+		p.next()
+		elem, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		t = "[" + elem + "]"
+	} else if p.isName() {
+		t = p.tok
+		p.next()
+	} else {
+		return "", p.errorf("expected type")
+	}
+	if p.tok == "!" {
+		p.next()
+		t += "!"
+	}
+	return t, nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sel []gqlSelection
+	for p.tok != "}" {
+		if p.tok == "" {
+			return nil, p.errorf("unexpected end of query")
+		}
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, s)
+	}
+	p.next()
+	if len(sel) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return sel, nil
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	if p.tok == "..." {
+		p.next()
+		if p.isName() && p.tok != "on" {
+			s := &gqlFragmentSpread{name: p.tok}
+			p.next()
+			if p.tok == "@" {
+				return nil, p.errorf("directives are not supported")
+			}
+			return s, nil
+		}
+		f := &gqlInlineFragment{}
+		if p.tok == "on" {
+			p.next()
+			if !p.isName() {
+				return nil, p.errorf("expected type condition")
+			}
+			f.typeCond = p.tok
+			p.next()
+		}
+		if p.tok == "@" {
+			return nil, p.errorf("directives are not supported")
+		}
+		var err error
+		f.sel, err = p.selectionSet()
+		return f, err
+	}
+
+	if !p.isName() {
+		return nil, p.errorf("expected field")
+	}
+	f := &gqlField{alias: p.tok, name: p.tok}
+	p.next()
+	if p.tok == ":" {
+		p.next()
+		if !p.isName() {
+			return nil, p.errorf("expected field name after alias")
+		}
+		f.name = p.tok
+		p.next()
+	}
+	if p.tok == "(" {
+		p.next()
+		for p.tok != ")" {
+			if !p.isName() {
+				return nil, p.errorf("expected argument name")
+			}
+			a := gqlArg{name: p.tok}
+			p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			var err error
+			if a.value, err = p.value(false); err != nil {
+				return nil, err
+			}
+			f.args = append(f.args, a)
+		}
+		p.next()
+	}
+	if p.tok == "@" {
+		return nil, p.errorf("directives are not supported")
+	}
+	if p.tok == "{" {
+		var err error
+		if f.sel, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// value parses a value. Variables are not allowed in constant values.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case tok == "$" && !constant:
+		p.next()
+		if !p.isName() {
+			return nil, p.errorf("expected variable name")
+		}
+		name := p.tok
+		p.next()
+		return gqlVariable(name), nil
+	case tok == "[":
+		p.next()
+		list := []interface{}{}
+		for p.tok != "]" {
+			if p.tok == "" {
+				return nil, p.errorf("unexpected end of query")
+			}
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.next()
+		return list, nil
+	case tok == "{":
+		p.next()
+		obj := map[string]interface{}{}
+		for p.tok != "}" {
+			if !p.isName() {
+				return nil, p.errorf("expected object field name")
+			}
+			name := p.tok
+			p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = v
+		}
+		p.next()
+		return obj, nil
+	case strings.HasPrefix(tok, `"`):
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, p.errorf("invalid string")
+		}
+		p.next()
+		return s, nil
+	case tok == "true" || tok == "false":
+		p.next()
+		return tok == "true", nil
+	case tok == "null":
+		p.next()
+		return nil, nil
+	case p.isName():
+		p.next()
+		return gqlEnum(tok), nil
+	case tok != "" && (tok[0] == '-' || ('0' <= tok[0] && tok[0] <= '9')):
+		p.next()
+		if n, err := strconv.Atoi(tok); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(tok, 64); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("invalid number %q", tok)
+	}
+	return nil, p.errorf("expected value")
+}
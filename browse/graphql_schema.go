@@ -0,0 +1,334 @@
+package browse
+
+import (
+	"fmt"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/query"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// This file defines the GraphQL schema (see GraphQLSchema) and its
+// resolvers. The values of the object types are:
+//
+//	Query, Repo      nil, *Site
+//	Unit             *Unit
+//	File             string (the file's path)
+//	Def              graph.DefKey (with all fields but CommitID set)
+//	Ref              *graph.Ref (with its def key's fields set)
+//	Doc              *graph.Doc
+//	DefConnection,
+//	RefConnection    *gqlConnection
+//	DefEdge, RefEdge *gqlEdge
+//	PageInfo         *gqlConnection
+
+// gqlConnection is a page of a list, for the Relay-style connection types.
+// Cursors are opaque strings that encode an item's position in the list
+// (see package query).
+type gqlConnection struct {
+	items []interface{} // the items in the page
+	page  *query.Page
+}
+
+type gqlEdge struct {
+	cursor string
+	node   interface{}
+}
+
+// connectionArgs are the arguments of fields whose type is a connection.
+var connectionArgs = []gqlArgDef{
+	{name: "first", typ: "Int"},
+	{name: "after", typ: "String"},
+	{name: "kind", typ: "String"},
+	{name: "file", typ: "String"},
+}
+
+// maxPageSize is the maximum (and default) number of items in a page of a
+// connection.
+const maxPageSize = 1000
+
+// connectionOptions returns the query options selected by the connection
+// args.
+func connectionOptions(args map[string]interface{}) (*query.Options, error) {
+	opt := &query.Options{First: maxPageSize}
+	if v, present := args["first"]; present {
+		opt.First = v.(int)
+		if opt.First < 0 || opt.First > maxPageSize {
+			return nil, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+		}
+	}
+	if v, present := args["after"]; present {
+		opt.After = v.(string)
+	}
+	if v, present := args["kind"]; present {
+		opt.Kinds = []string{v.(string)}
+	}
+	if v, present := args["file"]; present {
+		opt.File = v.(string)
+	}
+	return opt, nil
+}
+
+// paginate returns the page of the n items (where item(i) returns the i'th
+// item, and match(opt, i) whether it satisfies the filters in opt) selected
+// by the connection args.
+func paginate(n int, item func(i int) interface{}, match func(opt *query.Options, i int) bool, args map[string]interface{}) (*gqlConnection, error) {
+	opt, err := connectionOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	page, err := opt.Page(n, func(i int) bool { return match(opt, i) })
+	if err != nil {
+		return nil, err
+	}
+	c := &gqlConnection{page: page}
+	for _, i := range page.Indexes {
+		c.items = append(c.items, item(i))
+	}
+	return c, nil
+}
+
+func (s *Site) defKeyConnection(keys []graph.DefKey, args map[string]interface{}) (*gqlConnection, error) {
+	return paginate(len(keys), func(i int) interface{} { return keys[i] }, func(opt *query.Options, i int) bool { return opt.MatchDef(s.defs[keys[i]]) }, args)
+}
+
+func refConnection(refs []*graph.Ref, args map[string]interface{}) (*gqlConnection, error) {
+	return paginate(len(refs), func(i int) interface{} { return refs[i] }, func(opt *query.Options, i int) bool { return opt.MatchRef(refs[i]) }, args)
+}
+
+// gqlDef returns the GraphQL value of the def with the given key, or nil if
+// it is not in the site.
+func (s *Site) gqlDef(key graph.DefKey) interface{} {
+	if s.defs[key] == nil {
+		return nil
+	}
+	return key
+}
+
+// field is a shorthand for defining a field without arguments.
+func field(name, typ, desc string, resolve func(s *Site, src interface{}) interface{}) *gqlFieldDef {
+	return &gqlFieldDef{name: name, typ: typ, desc: desc, resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+		return resolve(s, src), nil
+	}}
+}
+
+func defField(name, typ string, get func(def *graph.Def) interface{}) *gqlFieldDef {
+	return field(name, typ, "", func(s *Site, src interface{}) interface{} { return get(s.defs[src.(graph.DefKey)]) })
+}
+
+func refField(name, typ string, get func(ref *graph.Ref) interface{}) *gqlFieldDef {
+	return field(name, typ, "", func(s *Site, src interface{}) interface{} { return get(src.(*graph.Ref)) })
+}
+
+var gqlQueryType = &gqlObject{
+	name: "Query",
+	fields: []*gqlFieldDef{
+		field("repo", "Repo!", "the repository", func(s *Site, src interface{}) interface{} { return s }),
+		{
+			name: "unit", typ: "Unit", desc: "a source unit",
+			args: []gqlArgDef{{name: "type", typ: "String!"}, {name: "name", typ: "String!"}},
+			resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+				for _, u := range s.units {
+					if u.Type == args["type"] && u.Name == args["name"] {
+						return u, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{
+			name: "file", typ: "File", desc: "a file that has build data",
+			args: []gqlArgDef{{name: "path", typ: "String!"}},
+			resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+				for _, f := range s.files {
+					if f == args["path"] {
+						return f, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{
+			name: "def", typ: "Def", desc: "a def, by its key as REPO[@COMMIT]:UNITTYPE:UNIT:PATH or by the other arguments (repo defaults to the site's repository)",
+			args: []gqlArgDef{{name: "key", typ: "String"}, {name: "repo", typ: "String"}, {name: "unitType", typ: "String"}, {name: "unit", typ: "String"}, {name: "path", typ: "String"}},
+			resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+				var key graph.DefKey
+				if k, ok := args["key"].(string); ok {
+					var err error
+					if key, err = graph.ParseDefKey(k); err != nil {
+						return nil, err
+					}
+				} else {
+					unitType, ok1 := args["unitType"].(string)
+					unit, ok2 := args["unit"].(string)
+					path, ok3 := args["path"].(string)
+					if !ok1 || !ok2 || !ok3 {
+						return nil, fmt.Errorf(`def requires either the "key" argument or the "unitType", "unit", and "path" arguments`)
+					}
+					key = graph.DefKey{UnitType: unitType, Unit: unit, Path: graph.DefPath(path)}
+					if r, ok := args["repo"].(string); ok {
+						key.Repo = repo.URI(r)
+					}
+				}
+				if key.Repo == "" {
+					key.Repo = s.RepoURI
+				}
+				return s.gqlDef(key), nil
+			},
+		},
+	},
+}
+
+var gqlTypes = map[string]*gqlObject{
+	"Query": gqlQueryType,
+	"Repo": {
+		name: "Repo",
+		fields: []*gqlFieldDef{
+			field("uri", "String!", "", func(s *Site, src interface{}) interface{} { return string(s.RepoURI) }),
+			field("units", "[Unit!]!", "source units, sorted by type and name", func(s *Site, src interface{}) interface{} { return s.units }),
+			field("files", "[File!]!", "files that have build data, sorted by path", func(s *Site, src interface{}) interface{} { return s.files }),
+			{
+				name: "symbols", typ: "DefConnection!", desc: "non-local defs that are in files, sorted by name",
+				args: connectionArgs,
+				resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+					return s.defKeyConnection(s.symbols, args)
+				},
+			},
+		},
+	},
+	"Unit": {
+		name: "Unit",
+		fields: []*gqlFieldDef{
+			field("type", "String!", "", func(s *Site, src interface{}) interface{} { return src.(*Unit).Type }),
+			field("name", "String!", "", func(s *Site, src interface{}) interface{} { return src.(*Unit).Name }),
+			field("files", "[String!]!", "", func(s *Site, src interface{}) interface{} { return src.(*Unit).Files }),
+			{
+				name: "defs", typ: "DefConnection!", desc: "the source unit's defs, in the grapher's order",
+				args: connectionArgs,
+				resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+					u := src.(*Unit)
+					return paginate(len(u.Defs), func(i int) interface{} { return s.defKey(u, u.Defs[i].DefKey) }, func(opt *query.Options, i int) bool { return opt.MatchDef(u.Defs[i]) }, args)
+				},
+			},
+		},
+	},
+	"File": {
+		name: "File",
+		fields: []*gqlFieldDef{
+			field("path", "String!", "", func(s *Site, src interface{}) interface{} { return src.(string) }),
+			{
+				name: "refs", typ: "RefConnection!", desc: "refs in the file, sorted by position",
+				args: connectionArgs,
+				resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+					return refConnection(s.fileRefs[src.(string)], args)
+				},
+			},
+		},
+	},
+	"Def": {
+		name: "Def",
+		fields: []*gqlFieldDef{
+			field("repo", "String!", "", func(s *Site, src interface{}) interface{} { return string(src.(graph.DefKey).Repo) }),
+			field("unitType", "String!", "", func(s *Site, src interface{}) interface{} { return src.(graph.DefKey).UnitType }),
+			field("unit", "String!", "", func(s *Site, src interface{}) interface{} { return src.(graph.DefKey).Unit }),
+			field("path", "String!", "", func(s *Site, src interface{}) interface{} { return string(src.(graph.DefKey).Path) }),
+			defField("name", "String!", func(def *graph.Def) interface{} { return def.Name }),
+			defField("kind", "String!", func(def *graph.Def) interface{} { return string(def.Kind) }),
+			defField("file", "String!", func(def *graph.Def) interface{} { return def.File }),
+			defField("defStart", "Int!", func(def *graph.Def) interface{} { return def.DefStart }),
+			defField("defEnd", "Int!", func(def *graph.Def) interface{} { return def.DefEnd }),
+			defField("exported", "Boolean!", func(def *graph.Def) interface{} { return def.Exported }),
+			defField("local", "Boolean!", func(def *graph.Def) interface{} { return def.Local }),
+			defField("test", "Boolean!", func(def *graph.Def) interface{} { return def.Test }),
+			field("doc", "String", "plain text of the def's documentation", func(s *Site, src interface{}) interface{} {
+				if doc, present := s.docs[src.(graph.DefKey)]; present {
+					return doc
+				}
+				return nil
+			}),
+			field("docs", "[Doc!]!", "the def's documentation, in each format", func(s *Site, src interface{}) interface{} { return s.allDocs[src.(graph.DefKey)] }),
+			{
+				name: "refs", typ: "RefConnection!", desc: "refs to the def, sorted by file and position",
+				args: connectionArgs,
+				resolve: func(s *Site, src interface{}, args map[string]interface{}) (interface{}, error) {
+					return refConnection(s.refs[src.(graph.DefKey)], args)
+				},
+			},
+		},
+	},
+	"Ref": {
+		name: "Ref",
+		fields: []*gqlFieldDef{
+			refField("defRepo", "String!", func(ref *graph.Ref) interface{} { return string(ref.DefRepo) }),
+			refField("defUnitType", "String!", func(ref *graph.Ref) interface{} { return ref.DefUnitType }),
+			refField("defUnit", "String!", func(ref *graph.Ref) interface{} { return ref.DefUnit }),
+			refField("defPath", "String!", func(ref *graph.Ref) interface{} { return string(ref.DefPath) }),
+			field("def", "Def", "the def, if it is in the repository", func(s *Site, src interface{}) interface{} { return s.gqlDef(src.(*graph.Ref).DefKey()) }),
+			refField("isDef", "Boolean!", func(ref *graph.Ref) interface{} { return ref.Def }),
+			refField("kind", "String", func(ref *graph.Ref) interface{} {
+				if ref.Kind == "" {
+					return nil
+				}
+				return string(ref.Kind)
+			}),
+			refField("file", "String!", func(ref *graph.Ref) interface{} { return ref.File }),
+			refField("start", "Int!", func(ref *graph.Ref) interface{} { return ref.Start }),
+			refField("end", "Int!", func(ref *graph.Ref) interface{} { return ref.End }),
+		},
+	},
+	"Doc": {
+		name: "Doc",
+		fields: []*gqlFieldDef{
+			field("format", "String!", "MIME type", func(s *Site, src interface{}) interface{} { return src.(*graph.Doc).Format }),
+			field("data", "String!", "", func(s *Site, src interface{}) interface{} { return src.(*graph.Doc).Data }),
+			field("file", "String!", "", func(s *Site, src interface{}) interface{} { return src.(*graph.Doc).File }),
+		},
+	},
+	"DefConnection": connectionType("Def"),
+	"DefEdge":       edgeType("Def"),
+	"RefConnection": connectionType("Ref"),
+	"RefEdge":       edgeType("Ref"),
+	"PageInfo": {
+		name: "PageInfo",
+		fields: []*gqlFieldDef{
+			field("hasNextPage", "Boolean!", "", func(s *Site, src interface{}) interface{} { return src.(*gqlConnection).page.HasNext }),
+			field("endCursor", "String", "cursor of the last item in the page, to pass as the after argument to get the next page", func(s *Site, src interface{}) interface{} {
+				c := src.(*gqlConnection)
+				if len(c.items) == 0 {
+					return nil
+				}
+				return query.EncodeCursor(c.page.Indexes[len(c.items)-1])
+			}),
+		},
+	},
+}
+
+func connectionType(node string) *gqlObject {
+	return &gqlObject{
+		name: node + "Connection",
+		desc: "a page of a list of " + node + "s",
+		fields: []*gqlFieldDef{
+			field("edges", "["+node+"Edge!]!", "", func(s *Site, src interface{}) interface{} {
+				c := src.(*gqlConnection)
+				edges := make([]*gqlEdge, len(c.items))
+				for i, item := range c.items {
+					edges[i] = &gqlEdge{cursor: query.EncodeCursor(c.page.Indexes[i]), node: item}
+				}
+				return edges
+			}),
+			field("nodes", "["+node+"!]!", "", func(s *Site, src interface{}) interface{} { return src.(*gqlConnection).items }),
+			field("pageInfo", "PageInfo!", "", func(s *Site, src interface{}) interface{} { return src }),
+			field("totalCount", "Int!", "number of (matching) items in the whole list", func(s *Site, src interface{}) interface{} { return src.(*gqlConnection).page.Total }),
+		},
+	}
+}
+
+func edgeType(node string) *gqlObject {
+	return &gqlObject{
+		name: node + "Edge",
+		fields: []*gqlFieldDef{
+			field("cursor", "String!", "", func(s *Site, src interface{}) interface{} { return src.(*gqlEdge).cursor }),
+			field("node", node+"!", "", func(s *Site, src interface{}) interface{} { return src.(*gqlEdge).node }),
+		},
+	}
+}
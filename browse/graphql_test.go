@@ -0,0 +1,174 @@
+package browse
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	s := newTestSite()
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{
+			query: `{ repo { uri units { type name } files { path } } }`,
+			want:  `{"repo":{"uri":"r","units":[{"type":"t","name":"u"}],"files":[{"path":"a.go"},{"path":"b.go"}]}}`,
+		},
+		{
+			query: `{
+				f: def(unitType: "t", unit: "u", path: "f") {
+					name kind doc
+					docs { format }
+					refs { totalCount nodes { file start isDef } }
+				}
+				g: def(unitType: "t", unit: "u", path: "g") { name }
+				k: def(key: "r:t:u:f") { name }
+			}`,
+			want: `{"f":{"name":"f","kind":"func","doc":"f does \u003cnothing\u003e.","docs":[{"format":"text/html"}],"refs":{"totalCount":2,"nodes":[{"file":"a.go","start":5,"isDef":true},{"file":"b.go","start":5,"isDef":false}]}},"g":null,"k":{"name":"f"}}`,
+		},
+		{
+			// Fragments, and a ref to a def that's not in the repository.
+			query: `query Q($file: String!) { file(path: $file) { refs { nodes { ...R } } } }
+				fragment R on Ref { defPath ... on Ref { def { name } } }`,
+			vars: map[string]interface{}{"file": "b.go"},
+			want: `{"file":{"refs":{"nodes":[{"defPath":"f","def":{"name":"f"}},{"defPath":"Println","def":null}]}}}`,
+		},
+		{
+			query: `{ repo { ...U ...U } } fragment U on Repo { uri }`,
+			want:  `{"repo":{"uri":"r"}}`,
+		},
+		{
+			query: `{ unit(type: "t", name: "u") { defs(first: 0) { totalCount pageInfo { hasNextPage endCursor } } } }`,
+			want:  `{"unit":{"defs":{"totalCount":1,"pageInfo":{"hasNextPage":true,"endCursor":null}}}}`,
+		},
+		{
+			query: `{ def(unitType: "t", unit: "u", path: "f") { refs(file: "b.go") { totalCount nodes { file } } } unit(type: "t", name: "u") { defs(kind: "var") { totalCount } } }`,
+			want:  `{"def":{"refs":{"totalCount":1,"nodes":[{"file":"b.go"}]}},"unit":{"defs":{"totalCount":0}}}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := s.execGraphQL(&graphQLRequest{Query: test.query, Variables: test.vars})
+		if err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		got, err := json.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestGraphQL_pagination(t *testing.T) {
+	s := newTestSite()
+	var cursors []string
+	var after interface{}
+	for {
+		data, err := s.execGraphQL(&graphQLRequest{
+			Query:     `query($after: String) { file(path: "b.go") { refs(first: 1, after: $after) { edges { cursor node { defPath } } pageInfo { hasNextPage endCursor } } } }`,
+			Variables: map[string]interface{}{"after": after},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page struct {
+			File struct {
+				Refs struct {
+					Edges []struct {
+						Cursor string
+						Node   struct{ DefPath string }
+					}
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   string
+					}
+				}
+			}
+		}
+		b, _ := json.Marshal(data)
+		if err := json.Unmarshal(b, &page); err != nil {
+			t.Fatal(err)
+		}
+		refs := page.File.Refs
+		if len(refs.Edges) != 1 || refs.Edges[0].Cursor != refs.PageInfo.EndCursor {
+			t.Fatalf("got page %s, want 1 edge", b)
+		}
+		cursors = append(cursors, refs.Edges[0].Node.DefPath)
+		if !refs.PageInfo.HasNextPage {
+			break
+		}
+		after = refs.PageInfo.EndCursor
+	}
+	if got, want := strings.Join(cursors, ","), "f,Println"; got != want {
+		t.Errorf("got refs %s, want %s", got, want)
+	}
+}
+
+func TestGraphQL_errors(t *testing.T) {
+	s := newTestSite()
+	for _, query := range []string{
+		`{ repo { nonexistent } }`,
+		`{ repo }`,
+		`{ repo { uri { x } } }`,
+		`{ def(unit: "u", path: "f") { name } }`,
+		`{ def(key: "t:u:f") { name } }`,
+		`{ def(unitType: "t", unit: "u", path: "f", x: 1) { name } }`,
+		`{ repo { symbols(first: -1) { totalCount } } }`,
+		`{ repo { symbols(after: "x") { totalCount } } }`,
+		`query($n: Int!) { repo { symbols(first: $n) { totalCount } } }`,
+		`{ repo { ...F } } fragment F on Repo { ...F }`,
+		`{ repo { ... on Def { name } } }`,
+		`mutation { repo { uri } }`,
+		`{ repo { uri @skip(if: true) } }`,
+		`{ repo { uri }`,
+		`query A { repo { uri } } query B { repo { uri } }`,
+	} {
+		if _, err := s.execGraphQL(&graphQLRequest{Query: query}); err == nil {
+			t.Errorf("%s: got no error", query)
+		}
+	}
+}
+
+func TestGraphQL_http(t *testing.T) {
+	s := httptest.NewServer(newTestSite().Handler())
+	defer s.Close()
+	check := func(resp *http.Response, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var gr struct {
+			Data   struct{ Repo struct{ URI string } }
+			Errors []struct{ Message string }
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || gr.Data.Repo.URI != "r" || len(gr.Errors) != 0 {
+			t.Errorf("got status %d, response %+v", resp.StatusCode, gr)
+		}
+	}
+
+	check(http.Get(s.URL + "/api/v1/graphql?query=" + url.QueryEscape(`{repo{uri}}`)))
+	body, _ := json.Marshal(graphQLRequest{Query: `query Q($x: Int) { repo { uri } }`, Variables: map[string]interface{}{"x": 1}})
+	check(http.Post(s.URL+"/api/v1/graphql", "application/json", bytes.NewReader(body)))
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	schema := GraphQLSchema()
+	for _, want := range []string{"type Query {", "  refs(first: Int, after: String, kind: String, file: String): RefConnection!\n", "type PageInfo {"} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema doesn't contain %q:\n%s", want, schema)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package browse
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// Handler returns an HTTP handler that serves the site.
+//
+// The pages are:
+//
+//	GET /             list the files
+//	GET /symbols      list the defs
+//	GET /file/FILE    show FILE with its refs linked to their defs
+//	GET /def/KEY      jump to the def (redirects to its location in its file)
+//	GET /refs/KEY     list the refs to the def
+//
+// where KEY is the def's key in URL form (see graph.DefKey.URLString). The JSON
+// API (see OpenAPISpec) is served under "/api/APIVersion/".
+func (s *Site) Handler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("/api/", s.serveAPI)
+	m.HandleFunc("/", s.serveIndex)
+	m.HandleFunc("/symbols", s.serveSymbols)
+	m.HandleFunc("/file/", s.serveFile)
+	m.HandleFunc("/def/", s.serveDef)
+	m.HandleFunc("/refs/", s.serveRefs)
+	return m
+}
+
+// serverLinker links to the pages served by the site's handler.
+type serverLinker struct{}
+
+func (serverLinker) indexURL() string                { return "/" }
+func (serverLinker) symbolsURL() string              { return "/symbols" }
+func (serverLinker) fileURL(file string) string      { return "/file/" + file }
+func (serverLinker) defURL(key graph.DefKey) string  { return "/def/" + key.URLString() }
+func (serverLinker) refsURL(key graph.DefKey) string { return "/refs/" + key.URLString() }
+
+func (s *Site) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.execute(w, indexTmpl, s.indexData(serverLinker{}))
+}
+
+func (s *Site) serveSymbols(w http.ResponseWriter, r *http.Request) {
+	s.execute(w, symbolsTmpl, s.symbolsData(serverLinker{}))
+}
+
+func (s *Site) serveFile(w http.ResponseWriter, r *http.Request) {
+	file := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/file/"))[1:]
+	if file == "" {
+		http.NotFound(w, r)
+		return
+	}
+	src, err := s.ReadFile(file)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.execute(w, fileTmpl, s.fileData(serverLinker{}, file, src))
+}
+
+func (s *Site) serveDef(w http.ResponseWriter, r *http.Request) {
+	key, err := pathDefKey(r, "/def/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	def := s.defs[key]
+	if def != nil && def.File != "" {
+		if src, err := s.ReadFile(def.File); err == nil {
+			line, _ := lineAt(src, def.DefStart)
+			http.Redirect(w, r, serverLinker{}.fileURL(def.File)+"#L"+strconv.Itoa(line), http.StatusFound)
+			return
+		}
+	}
+
+	// The def is outside of the repository (or has no location), so just
+	// describe it.
+	s.execute(w, defTmpl, struct {
+		Key      graph.DefKey
+		Def      *graph.Def
+		Doc      string
+		RefsHref string
+	}{key, def, s.docs[key], serverLinker{}.refsURL(key)})
+}
+
+func (s *Site) serveRefs(w http.ResponseWriter, r *http.Request) {
+	key, err := pathDefKey(r, "/refs/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	srcs := make(map[string][]byte)
+	source := func(file string) []byte {
+		src, present := srcs[file]
+		if !present {
+			var err error
+			if src, err = s.ReadFile(file); err != nil {
+				log.Printf("Error reading %s: %s.", file, err)
+			}
+			srcs[file] = src
+		}
+		return src
+	}
+	s.execute(w, refsTmpl, s.refsData(serverLinker{}, key, source))
+}
+
+// pathDefKey returns the def key (in URL form) that follows prefix in the
+// path of the request r.
+func pathDefKey(r *http.Request, prefix string) (graph.DefKey, error) {
+	// Use the escaped path, because unescaping the path as a whole (and not
+	// each field of the key) would make it ambiguous.
+	return graph.ParseDefKey(strings.TrimPrefix(r.URL.EscapedPath(), prefix))
+}
+
+func (s *Site) execute(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	if err := t.Execute(w, newPage(serverLinker{}, data)); err != nil {
+		log.Printf("Error rendering %s: %s.", t.Name(), err)
+	}
+}
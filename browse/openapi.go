@@ -0,0 +1,190 @@
+package browse
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// OpenAPISpec returns the OpenAPI 3.0 specification of the site's JSON API,
+// which is also served at "/api/APIVersion/openapi.json". It is generated
+// from the same list of endpoints that is used to serve the API, and the
+// response schemas are generated from the Go types of the responses.
+func OpenAPISpec() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, e := range apiEndpoints {
+		params := []interface{}{}
+		for _, p := range e.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          "query",
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		paths[apiPrefix+e.Path] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": e.OperationID,
+				"summary":     e.Summary,
+				"parameters":  params,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(e.Result), schemas)},
+						},
+					},
+					"default": map[string]interface{}{
+						"description": "Error (such as an invalid request or a def that was not found)",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+						},
+					},
+				},
+			},
+		}
+	}
+	paths[apiPrefix+"/graphql"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "graphql",
+			"summary":     "Execute a GraphQL query (the schema is printed by `src browse --graphql-schema`)",
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(graphQLRequest{}), schemas)},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK (errors in the query are returned in the errors field)",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(graphQLResponse{}), schemas)},
+					},
+				},
+			},
+		},
+	}
+	paths[apiPrefix+"/events"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "streamEvents",
+			"summary":     "Stream changes to the build data as server-sent events (sent only by `src browse --watch`)",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "A stream of events, each with its type in the event field and its JSON encoding in the data field",
+					"content": map[string]interface{}{
+						"text/event-stream": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(Event{}), schemas)},
+					},
+				},
+			},
+		},
+	}
+	schemas["Error"] = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"Error": map[string]interface{}{"type": "string"}},
+		"required":   []string{"Error"},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "srclib browse API",
+			"version": APIVersion,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonSchema returns the OpenAPI schema of the JSON encoding of values of
+// type t. Named struct types are added to schemas (keyed by their type
+// names) and referred to by reference.
+func jsonSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		// The encoding is custom, so it could be anything.
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, present := schemas[t.Name()]; !present {
+			schemas[t.Name()] = nil // break cycles
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the OpenAPI schema of the JSON encoding of the
+// struct type t. Fields that aren't omitted when empty are required.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue // unexported
+			}
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i != -1 {
+				name, opts = tag[:i], tag[i+1:]
+			}
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				addFields(f.Type) // embedded struct's fields are promoted
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchema(f.Type, schemas)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	s := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
@@ -0,0 +1,246 @@
+package browse
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/semtok"
+)
+
+// A linker returns the URLs of the pages of a site, which differ between
+// the pages served over HTTP and the pages of a static site.
+type linker interface {
+	indexURL() string
+	symbolsURL() string
+	fileURL(file string) string
+
+	// defURL returns the URL of the def with the given key, or "" if it
+	// can't be linked to.
+	defURL(key graph.DefKey) string
+
+	// refsURL returns the URL of the list of refs to the def with the given
+	// key, or "" if there is none.
+	refsURL(key graph.DefKey) string
+}
+
+// A page is the data for a page template.
+type page struct {
+	IndexURL   string
+	SymbolsURL string
+	Data       interface{}
+}
+
+func newPage(lk linker, data interface{}) *page {
+	return &page{IndexURL: lk.indexURL(), SymbolsURL: lk.symbolsURL(), Data: data}
+}
+
+// A fileLink is a file in the index page.
+type fileLink struct {
+	Name string
+	Href string
+}
+
+func (s *Site) indexData(lk linker) interface{} {
+	files := make([]fileLink, len(s.files))
+	for i, f := range s.files {
+		files[i] = fileLink{Name: f, Href: lk.fileURL(f)}
+	}
+	return struct {
+		RepoURI string
+		Files   []fileLink
+	}{string(s.RepoURI), files}
+}
+
+// A symbol is a def in the symbol index page.
+type symbol struct {
+	ID       string // HTML id of the symbol's entry
+	Def      *graph.Def
+	Unit     string
+	Doc      string
+	Href     string
+	NumRefs  int
+	RefsHref string
+}
+
+func (s *Site) symbolsData(lk linker) interface{} {
+	syms := make([]symbol, len(s.symbols))
+	for i, key := range s.symbols {
+		syms[i] = symbol{
+			ID:       symbolID(key),
+			Def:      s.defs[key],
+			Unit:     key.Unit,
+			Doc:      summary(s.docs[key]),
+			Href:     lk.defURL(key),
+			NumRefs:  len(s.refs[key]),
+			RefsHref: lk.refsURL(key),
+		}
+	}
+	return struct {
+		RepoURI string
+		Symbols []symbol
+	}{string(s.RepoURI), syms}
+}
+
+// symbolID returns the HTML id of the entry for the def with the given key
+// in the symbol index page.
+func symbolID(key graph.DefKey) string {
+	return "sym-" + key.URLString()
+}
+
+func (s *Site) fileData(lk linker, file string, src []byte) interface{} {
+	return struct {
+		File   string
+		Source template.HTML
+	}{file, renderSource(src, s.fileRefs[file], func(ref *graph.Ref) link { return s.linkRef(lk, ref) })}
+}
+
+// A refLocation is a ref in a list of refs.
+type refLocation struct {
+	File string
+	Line int
+	Text string
+	Href string
+}
+
+// refsData returns the data for the list of refs to the def with the given
+// key. The source func returns the contents of a file, or nil if it can't be
+// read.
+func (s *Site) refsData(lk linker, key graph.DefKey, source func(file string) []byte) interface{} {
+	var locs []refLocation
+	for _, ref := range s.refs[key] {
+		src := source(ref.File)
+		if ref.Start > len(src) {
+			continue
+		}
+		line, text := lineAt(src, ref.Start)
+		locs = append(locs, refLocation{
+			File: ref.File,
+			Line: line,
+			Text: strings.TrimSpace(text),
+			Href: lk.fileURL(ref.File) + "#L" + strconv.Itoa(line),
+		})
+	}
+	return struct {
+		Key  graph.DefKey
+		Def  *graph.Def
+		Refs []refLocation
+	}{key, s.defs[key], locs}
+}
+
+// A link is the hyperlink that a ref is rendered as.
+type link struct {
+	Href  string // if empty, the ref is not linked
+	Title string // hover text
+	Class string
+}
+
+// linkRef returns the link for a ref. Refs that are defs link to their
+// references; others link to their defs. The link's class is "def" or "ref"
+// (and "external" for refs to defs outside of the repository) plus
+// "tok-TYPE" and "tok-MODIFIER" for the ref's semantic token type and
+// modifiers, for syntax highlighting.
+func (s *Site) linkRef(lk linker, ref *graph.Ref) link {
+	key := ref.DefKey()
+	def := s.defs[key]
+	l := link{Title: summary(s.docs[key])}
+
+	var classes []string
+	if ref.Def {
+		l.Href = lk.refsURL(key)
+		classes = append(classes, "def")
+	} else {
+		l.Href = lk.defURL(key)
+		classes = append(classes, "ref")
+		if def == nil {
+			classes = append(classes, "external")
+		}
+	}
+	typ, mods := semtok.Classify(ref, def)
+	if typ != "" {
+		classes = append(classes, "tok-"+typ)
+	}
+	for _, m := range mods {
+		classes = append(classes, "tok-"+m)
+	}
+	l.Class = strings.Join(classes, " ")
+	return l
+}
+
+// renderSource returns src as HTML, one line per line of src, with each line
+// given the id "L<n>" (where n is 1-based) and the given refs (which must be
+// sorted by position) rendered as hyperlinks to the link returned by linkRef.
+// Refs that span lines or overlap an earlier ref are not linked.
+func renderSource(src []byte, refs []*graph.Ref, linkRef func(*graph.Ref) link) template.HTML {
+	var buf bytes.Buffer
+	lineNum := 1
+	startLine := func() {
+		fmt.Fprintf(&buf, `<span class="line" id="L%d"><a class="lineno" href="#L%d">%d</a>`, lineNum, lineNum, lineNum)
+	}
+	startLine()
+
+	pos := 0
+	// write writes src[pos:end], breaking it into lines.
+	write := func(end int) {
+		for _, c := range src[pos:end] {
+			if c == '\n' {
+				lineNum++
+				buf.WriteString("</span>\n")
+				startLine()
+			} else {
+				template.HTMLEscape(&buf, []byte{c})
+			}
+		}
+		pos = end
+	}
+
+	for _, ref := range refs {
+		if ref.Start < pos || ref.End > len(src) || ref.Start >= ref.End || bytes.IndexByte(src[ref.Start:ref.End], '\n') != -1 {
+			continue
+		}
+		write(ref.Start)
+		l := linkRef(ref)
+		if l.Href != "" {
+			fmt.Fprintf(&buf, `<a href="%s" title="%s" class="%s">`, template.HTMLEscapeString(l.Href), template.HTMLEscapeString(l.Title), template.HTMLEscapeString(l.Class))
+			write(ref.End)
+			buf.WriteString("</a>")
+		} else {
+			fmt.Fprintf(&buf, `<span title="%s" class="%s">`, template.HTMLEscapeString(l.Title), template.HTMLEscapeString(l.Class))
+			write(ref.End)
+			buf.WriteString("</span>")
+		}
+	}
+	write(len(src))
+	buf.WriteString("</span>")
+	return template.HTML(buf.String())
+}
+
+// lineAt returns the 1-based line number of the byte offset in src, and the
+// text of that line.
+func lineAt(src []byte, offset int) (int, string) {
+	if offset > len(src) {
+		offset = len(src)
+	} else if offset < 0 {
+		offset = 0
+	}
+	line := 1 + bytes.Count(src[:offset], []byte{'\n'})
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := bytes.IndexByte(src[offset:], '\n')
+	if end == -1 {
+		end = len(src)
+	} else {
+		end += offset
+	}
+	return line, string(src[start:end])
+}
+
+// summary returns the first paragraph of doc, for use as hover text.
+func summary(doc string) string {
+	if i := strings.Index(doc, "\n\n"); i != -1 {
+		doc = doc[:i]
+	}
+	return doc
+}
@@ -0,0 +1,252 @@
+// Package browse renders a repository's source files as HTML, with the refs
+// in them hyperlinked to their defs, using the graph output of the
+// repository's source units. The pages are either served over HTTP or
+// written as a static site.
+package browse
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/vcsutil"
+)
+
+// A Unit is the graph output of a source unit. Defs and refs in the output
+// whose unit fields are empty are in this source unit.
+type Unit struct {
+	Type  string
+	Name  string
+	Files []string
+	*grapher.Output
+}
+
+// A Site is an index of a repository's build data for rendering its files.
+type Site struct {
+	// RepoURI is the URI of the repository. Defs in other repositories are
+	// not linked.
+	RepoURI repo.URI
+
+	// ReadFile returns the contents of a file in the repository, given its
+	// path relative to the repository root.
+	ReadFile func(file string) ([]byte, error)
+
+	// Blame is the blame of the repository's files, or nil if it hasn't
+	// been built.
+	Blame *vcsutil.BlameOutput
+
+	units    []*Unit // sorted by type and name
+	files    []string
+	symbols  []graph.DefKey // keys of non-local defs in files, sorted by name
+	defs     map[graph.DefKey]*graph.Def
+	docs     map[graph.DefKey]string // plain text of each def's first doc
+	allDocs  map[graph.DefKey][]*graph.Doc
+	refs     map[graph.DefKey][]*graph.Ref // refs to each def
+	fileRefs map[string][]*graph.Ref
+}
+
+// NewSite returns a site for the repository with the given URI, whose source
+// units' graph outputs are units. The defs, refs, and docs in the outputs
+// are not modified.
+func NewSite(repoURI repo.URI, units []*Unit, readFile func(file string) ([]byte, error)) *Site {
+	s := &Site{
+		RepoURI:  repoURI,
+		ReadFile: readFile,
+		defs:     make(map[graph.DefKey]*graph.Def),
+		docs:     make(map[graph.DefKey]string),
+		allDocs:  make(map[graph.DefKey][]*graph.Doc),
+		refs:     make(map[graph.DefKey][]*graph.Ref),
+		fileRefs: make(map[string][]*graph.Ref),
+	}
+
+	files := make(map[string]bool)
+	for _, u := range units {
+		for _, f := range u.Files {
+			files[f] = true
+		}
+		for _, def := range u.Defs {
+			key := s.defKey(u, def.DefKey)
+			s.defs[key] = def
+			if def.File != "" {
+				files[def.File] = true
+			}
+			if isSymbol(def) {
+				s.symbols = append(s.symbols, key)
+			}
+		}
+		for _, doc := range u.Docs {
+			key := s.defKey(u, doc.DefKey)
+			if _, present := s.docs[key]; !present {
+				s.docs[key] = docText(doc)
+			}
+			s.allDocs[key] = append(s.allDocs[key], doc)
+		}
+		for _, ref := range u.Refs {
+			// Copy the ref to fill in its def key without modifying the
+			// unit's output.
+			ref2 := *ref
+			ref2.SetFromDefKey(s.defKey(u, ref.DefKey()))
+			s.refs[ref2.DefKey()] = append(s.refs[ref2.DefKey()], &ref2)
+			s.fileRefs[ref.File] = append(s.fileRefs[ref.File], &ref2)
+			files[ref.File] = true
+		}
+	}
+	s.units = append(s.units, units...)
+	sort.Sort(unitsByName(s.units))
+
+	delete(files, "")
+	for f := range files {
+		if !validFile(f) {
+			log.Printf("Warning: skipping file %q in the graph data of %s (not a relative path in the repository).", f, repoURI)
+			continue
+		}
+		s.files = append(s.files, f)
+	}
+	sort.Strings(s.files)
+	sort.Sort(symbolsByName{s.symbols, s.defs})
+	for _, refs := range s.refs {
+		sort.Sort(refsByPosition(refs))
+	}
+	for _, refs := range s.fileRefs {
+		sort.Sort(refsByPosition(refs))
+	}
+	return s
+}
+
+// validFile reports whether file is a clean relative path that doesn't
+// begin with "..". The files in graph data come from toolchains, so other
+// files are skipped, rather than being read or written outside of the
+// repository or the site.
+func validFile(file string) bool {
+	return path.Clean(file) == file && !path.IsAbs(file) && file != ".." && !strings.HasPrefix(file, "../")
+}
+
+// isSymbol reports whether def is listed in the symbol index.
+func isSymbol(def *graph.Def) bool { return def.File != "" && !def.Local }
+
+// defKey returns key with its empty repo and unit fields filled in from the
+// site's repository and u.
+func (s *Site) defKey(u *Unit, key graph.DefKey) graph.DefKey {
+	if key.Repo == "" {
+		key.Repo = s.RepoURI
+	}
+	if key.UnitType == "" && key.Unit == "" {
+		key.UnitType, key.Unit = u.Type, u.Name
+	}
+	return key
+}
+
+// Units returns the graph outputs of the site's source units, sorted by
+// type and name.
+func (s *Site) Units() []*Unit { return s.units }
+
+// Files returns the paths of the files that have build data, sorted.
+func (s *Site) Files() []string { return s.files }
+
+// Symbols returns the keys of the non-local defs that are in files, sorted
+// by name.
+func (s *Site) Symbols() []graph.DefKey { return s.symbols }
+
+// Def returns the def with the given key (whose repo and unit fields must be
+// set), or nil if it is not in the repository.
+func (s *Site) Def(key graph.DefKey) *graph.Def { return s.defs[key] }
+
+// Refs returns the refs to the def with the given key, sorted by file and
+// position.
+func (s *Site) Refs(key graph.DefKey) []*graph.Ref { return s.refs[key] }
+
+// Doc returns the plain text of the documentation of the def with the given
+// key, or "" if it has none.
+func (s *Site) Doc(key graph.DefKey) string { return s.docs[key] }
+
+// Docs returns the docs of the def with the given key (in all formats).
+func (s *Site) Docs(key graph.DefKey) []*graph.Doc { return s.allDocs[key] }
+
+// defQuery returns the URL query string that identifies key.
+func defQuery(key graph.DefKey) string {
+	return url.Values{"def": {key.Format()}}.Encode()
+}
+
+// parseDefQuery returns the def key identified by the URL query q, either
+// by the def parameter (in the canonical form of graph.DefKey.Format) or by
+// the repo, unittype, unit, and path parameters.
+func parseDefQuery(q url.Values) (graph.DefKey, error) {
+	if s := q.Get("def"); s != "" {
+		for _, p := range []string{"repo", "unittype", "unit", "path"} {
+			if q.Get(p) != "" {
+				return graph.DefKey{}, fmt.Errorf("query parameters %q and \"def\" can't both be given", p)
+			}
+		}
+		return graph.ParseDefKey(s)
+	}
+	for _, p := range []string{"unittype", "unit", "path"} {
+		if q.Get(p) == "" {
+			return graph.DefKey{}, fmt.Errorf("missing required query parameter %q", p)
+		}
+	}
+	return graph.DefKey{
+		Repo:     repo.URI(q.Get("repo")),
+		UnitType: q.Get("unittype"),
+		Unit:     q.Get("unit"),
+		Path:     graph.DefPath(q.Get("path")),
+	}, nil
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// docText returns the plain text of doc.
+func docText(doc *graph.Doc) string {
+	text := doc.Data
+	if doc.Format == "text/html" {
+		text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	}
+	return strings.TrimSpace(text)
+}
+
+type refsByPosition []*graph.Ref
+
+func (vs refsByPosition) Len() int      { return len(vs) }
+func (vs refsByPosition) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs refsByPosition) Less(i, j int) bool {
+	if vs[i].File != vs[j].File {
+		return vs[i].File < vs[j].File
+	}
+	if vs[i].Start != vs[j].Start {
+		return vs[i].Start < vs[j].Start
+	}
+	return vs[i].End < vs[j].End
+}
+
+type symbolsByName struct {
+	keys []graph.DefKey
+	defs map[graph.DefKey]*graph.Def
+}
+
+func (vs symbolsByName) Len() int      { return len(vs.keys) }
+func (vs symbolsByName) Swap(i, j int) { vs.keys[i], vs.keys[j] = vs.keys[j], vs.keys[i] }
+func (vs symbolsByName) Less(i, j int) bool {
+	a, b := vs.defs[vs.keys[i]], vs.defs[vs.keys[j]]
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return vs.keys[i].Less(vs.keys[j])
+}
+
+type unitsByName []*Unit
+
+func (vs unitsByName) Len() int      { return len(vs) }
+func (vs unitsByName) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs unitsByName) Less(i, j int) bool {
+	if vs[i].Type != vs[j].Type {
+		return vs[i].Type < vs[j].Type
+	}
+	return vs[i].Name < vs[j].Name
+}
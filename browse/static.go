@@ -0,0 +1,140 @@
+package browse
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"html/template"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// WriteStatic writes the site as static HTML pages that link to each other
+// by relative URLs, so that they can be served by any static web server.
+// The writeFile func writes a page, given its slash-separated path relative
+// to the root of the site.
+//
+// The pages are:
+//
+//	index.html      list the files
+//	symbols.html    list the defs
+//	file/FILE.html  show FILE with its refs linked to their defs
+//	refs/ID.html    list the refs to a def (for each def in the symbol list)
+//
+// Files that don't exist are skipped.
+func (s *Site) WriteStatic(writeFile func(path string, data []byte) error) error {
+	ss := &staticSite{Site: s, srcs: make(map[string][]byte), errs: make(map[string]error)}
+
+	write := func(path string, t *template.Template, data interface{}) error {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, newPage(ss.linker(path), data)); err != nil {
+			return err
+		}
+		return writeFile(path, buf.Bytes())
+	}
+
+	if err := write("index.html", indexTmpl, s.indexData(ss.linker("index.html"))); err != nil {
+		return err
+	}
+	if err := write("symbols.html", symbolsTmpl, s.symbolsData(ss.linker("symbols.html"))); err != nil {
+		return err
+	}
+	for _, file := range s.files {
+		src, err := ss.readFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		path := "file/" + file + ".html"
+		if err := write(path, fileTmpl, s.fileData(ss.linker(path), file, src)); err != nil {
+			return err
+		}
+	}
+	for _, key := range s.symbols {
+		path := refsPagePath(key)
+		if err := write(path, refsTmpl, s.refsData(ss.linker(path), key, ss.source)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refsPagePath returns the path of the page that lists the refs to the def
+// with the given key.
+func refsPagePath(key graph.DefKey) string {
+	h := sha1.Sum([]byte(key.Format()))
+	return "refs/" + hex.EncodeToString(h[:])[:16] + ".html"
+}
+
+// A staticSite caches the contents of files while writing a static site.
+type staticSite struct {
+	*Site
+	srcs map[string][]byte
+	errs map[string]error
+}
+
+func (ss *staticSite) readFile(file string) ([]byte, error) {
+	if src, present := ss.srcs[file]; present {
+		return src, nil
+	}
+	if err, present := ss.errs[file]; present {
+		return nil, err
+	}
+	src, err := ss.ReadFile(file)
+	if err != nil {
+		ss.errs[file] = err
+		return nil, err
+	}
+	ss.srcs[file] = src
+	return src, nil
+}
+
+// source returns the contents of a file, or nil if it can't be read.
+func (ss *staticSite) source(file string) []byte {
+	src, _ := ss.readFile(file)
+	return src
+}
+
+// linker returns the linker for the page at the given path.
+func (ss *staticSite) linker(path string) staticLinker {
+	return staticLinker{ss: ss, root: strings.Repeat("../", strings.Count(path, "/"))}
+}
+
+// staticLinker links to the pages of a static site, relative to a page.
+type staticLinker struct {
+	ss   *staticSite
+	root string // relative URL of the root of the site from the page
+}
+
+func (lk staticLinker) url(path string) string {
+	return lk.root + (&url.URL{Path: path}).String()
+}
+
+func (lk staticLinker) indexURL() string           { return lk.url("index.html") }
+func (lk staticLinker) symbolsURL() string         { return lk.url("symbols.html") }
+func (lk staticLinker) fileURL(file string) string { return lk.url("file/" + file + ".html") }
+
+func (lk staticLinker) defURL(key graph.DefKey) string {
+	def := lk.ss.defs[key]
+	if def == nil || def.File == "" {
+		return ""
+	}
+	src := lk.ss.source(def.File)
+	if src == nil {
+		return ""
+	}
+	line, _ := lineAt(src, def.DefStart)
+	return lk.fileURL(def.File) + "#L" + strconv.Itoa(line)
+}
+
+func (lk staticLinker) refsURL(key graph.DefKey) string {
+	if def := lk.ss.defs[key]; def == nil || !isSymbol(def) {
+		return ""
+	}
+	return lk.url(refsPagePath(key))
+}
@@ -0,0 +1,84 @@
+package browse
+
+import "html/template"
+
+const header = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{template "title" .Data}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+pre { line-height: 1.4; }
+.line { display: block; }
+.line:target { background: #ffd; }
+.lineno { display: inline-block; width: 4em; color: #999; text-align: right; padding-right: 1em; text-decoration: none; }
+a.ref, a.def { color: inherit; text-decoration: none; border-bottom: 1px dotted #999; }
+a.def { font-weight: bold; }
+a.external { border-bottom-style: dashed; }
+a.ref:hover, a.def:hover { background: #eef; }
+.tok-namespace { color: #795da3; }
+.tok-type { color: #0086b3; }
+.tok-function { color: #183691; }
+.tok-property { color: #63a35c; }
+.tok-variable { color: #333; }
+.tok-readonly { color: #a71d5d; }
+.tok-local { font-style: italic; }
+.doc { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<p><a href="{{.IndexURL}}">Files</a> | <a href="{{.SymbolsURL}}">Symbols</a></p>
+{{with .Data}}`
+
+const footer = `{{end}}
+</body>
+</html>
+`
+
+func newTemplate(name, title, body string) *template.Template {
+	t := template.Must(template.New(name).Parse(header + body + footer))
+	template.Must(t.New("title").Parse(title))
+	return t
+}
+
+var indexTmpl = newTemplate("index", `{{.RepoURI}}`, `
+<h1>{{.RepoURI}}</h1>
+<ul>
+{{range .Files}}<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{else}}<li>No files have build data. Run <code>src make</code> first.</li>
+{{end}}</ul>
+`)
+
+var symbolsTmpl = newTemplate("symbols", `Symbols - {{.RepoURI}}`, `
+<h1>Symbols in {{.RepoURI}}</h1>
+<table>
+{{range .Symbols}}<tr id="{{.ID}}">
+<td>{{.Def.Kind}}</td>
+<td>{{if .Href}}<a href="{{.Href}}">{{.Def.Name}}</a>{{else}}{{.Def.Name}}{{end}}</td>
+<td><code>{{.Def.Path}}</code> ({{.Unit}})</td>
+<td>{{if .RefsHref}}<a href="{{.RefsHref}}">{{.NumRefs}} refs</a>{{else}}{{.NumRefs}} refs{{end}}</td>
+<td>{{.Doc}}</td>
+</tr>
+{{end}}</table>
+`)
+
+var fileTmpl = newTemplate("file", `{{.File}}`, `
+<h1>{{.File}}</h1>
+<pre>{{.Source}}</pre>
+`)
+
+var defTmpl = newTemplate("def", `{{.Key.Path}}`, `
+<h1>{{if .Def}}{{.Def.Kind}} {{.Def.Name}}{{else}}{{.Key.Path}}{{end}}</h1>
+<p>Defined in {{.Key.Repo}} (source unit {{.Key.Unit}} of type {{.Key.UnitType}}){{if not .Def}}, which is not in this repository{{end}}.</p>
+{{with .Doc}}<p class="doc">{{.}}</p>{{end}}
+<p><a href="{{.RefsHref}}">Find references</a></p>
+`)
+
+var refsTmpl = newTemplate("refs", `References to {{.Key.Path}}`, `
+<h1>References to {{if .Def}}{{.Def.Name}}{{else}}{{.Key.Path}}{{end}}</h1>
+<p>{{len .Refs}} references</p>
+<ul>
+{{range .Refs}}<li><a href="{{.Href}}">{{.File}}:{{.Line}}</a> <code>{{.Text}}</code></li>
+{{end}}</ul>
+`)
@@ -0,0 +1,239 @@
+// Package buildmanifest describes a build of a repository commit: which
+// toolchains built which source units, and which build data files each
+// produced. Consumers of a build should read its manifest to find its build
+// data, rather than guess file names from the plan.
+package buildmanifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/report"
+)
+
+func init() {
+	buildstore.RegisterDataType("buildmanifest.v0", &Manifest{})
+}
+
+// Filename is the name of the file (in the build data directory for a
+// commit) that the Manifest of the most recent build is written to. (Its
+// data type name is versioned, and it mustn't end in "manifest.json", which
+// is the suffix of the manifest package's data type.)
+const Filename = "buildmanifest.v0.json"
+
+// A Manifest describes a build of a repository commit.
+type Manifest struct {
+	Repo     string
+	CommitID string
+
+	// SrclibVersion is the version of src that ran the build.
+	SrclibVersion string
+
+	Start    time.Time
+	Duration time.Duration
+
+	// Error is the error that the build failed with, if any. (Units may
+	// have failed even if the build as a whole didn't; see Unit.Failed.)
+	Error string `json:",omitempty"`
+
+	// Toolchains are the toolchains that the build's plan uses, sorted by
+	// path.
+	Toolchains []*Toolchain
+
+	// Units are the source units that were built, sorted by type and name.
+	Units []*Unit
+
+	// Files are the build data files of the commit that don't belong to a
+	// source unit (such as the tree's configuration), sorted by path.
+	Files []*File
+
+	// Runs are the toolchain tools that ran during the build (steps whose
+	// outputs were up to date don't run), with their timings and errors.
+	Runs []*report.ToolRun
+}
+
+// A Toolchain is a toolchain that a build used.
+type Toolchain struct {
+	Path string
+
+	// Version is the commit ID of the toolchain's directory, or empty if it
+	// isn't in a git repository (see toolchain.Info.Version).
+	Version string `json:",omitempty"`
+}
+
+// A Unit is a source unit that a build built.
+type Unit struct {
+	Name string
+	Type string
+
+	// Files are the build data files for the unit, sorted by path. Files
+	// that a failed step didn't produce are omitted.
+	Files []*File
+
+	// Wall is the total wall time of the tool runs for the unit.
+	Wall time.Duration
+
+	// Failed is whether any of the tool runs for the unit failed (see the
+	// manifest's Runs).
+	Failed bool `json:",omitempty"`
+}
+
+// A File is a build data file.
+type File struct {
+	// Path is the path of the file, relative to the build data directory of
+	// the commit.
+	Path string
+
+	// Hash is the hash of the file's contents (see buildstore.HashBlob).
+	Hash string
+
+	Size int64
+}
+
+// AllFiles returns the build data files of m's units, followed by m.Files.
+func (m *Manifest) AllFiles() []*File {
+	var files []*File
+	for _, u := range m.Units {
+		files = append(files, u.Files...)
+	}
+	return append(files, m.Files...)
+}
+
+// ListedFiles returns the paths of the build data files that the manifest of
+// the most recent build of commitID in s lists (including the manifest
+// itself), or nil if no build of the commit has written a manifest. It is
+// suitable as buildstore.GCOptions.ManifestFiles.
+func ListedFiles(s *buildstore.RepositoryStore, commitID string) ([]string, error) {
+	m, err := Read(s, commitID)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	paths := []string{Filename}
+	for _, f := range m.AllFiles() {
+		paths = append(paths, f.Path)
+	}
+	return paths, nil
+}
+
+// StatFile returns the File for the build data file at path (relative to
+// the build data directory of commitID) in s.
+func StatFile(s *buildstore.RepositoryStore, commitID, path string) (*File, error) {
+	f, err := s.Open(s.FilePath(commitID, path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := s.Stat(s.FilePath(commitID, path))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := buildstore.HashBlob(f)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: filepath.ToSlash(path), Hash: hash, Size: fi.Size()}, nil
+}
+
+// AddOtherFiles adds the build data files of m's commit in s that don't
+// belong to any of m's units (except for the manifest itself and hidden
+// files, such as the build state file) to m.Files.
+func (m *Manifest) AddOtherFiles(s *buildstore.RepositoryStore) error {
+	unitFiles := map[string]bool{Filename: true}
+	for _, u := range m.Units {
+		for _, f := range u.Files {
+			unitFiles[f.Path] = true
+		}
+	}
+	dataFiles, err := s.DataFilesForCommit(m.CommitID)
+	if err != nil {
+		return err
+	}
+	for _, df := range dataFiles {
+		path := filepath.ToSlash(df.Path)
+		if unitFiles[path] || strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		f, err := StatFile(s, m.CommitID, path)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, f)
+	}
+	return nil
+}
+
+// Sort sorts m's toolchains, units, and files in the orders that their
+// fields' docs specify.
+func (m *Manifest) Sort() {
+	sort.Sort(toolchainsByPath(m.Toolchains))
+	sort.Sort(unitsByID(m.Units))
+	for _, u := range m.Units {
+		sort.Sort(filesByPath(u.Files))
+	}
+	sort.Sort(filesByPath(m.Files))
+}
+
+type toolchainsByPath []*Toolchain
+
+func (v toolchainsByPath) Len() int           { return len(v) }
+func (v toolchainsByPath) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v toolchainsByPath) Less(i, j int) bool { return v[i].Path < v[j].Path }
+
+type unitsByID []*Unit
+
+func (v unitsByID) Len() int      { return len(v) }
+func (v unitsByID) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v unitsByID) Less(i, j int) bool {
+	if v[i].Type != v[j].Type {
+		return v[i].Type < v[j].Type
+	}
+	return v[i].Name < v[j].Name
+}
+
+type filesByPath []*File
+
+func (v filesByPath) Len() int           { return len(v) }
+func (v filesByPath) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v filesByPath) Less(i, j int) bool { return v[i].Path < v[j].Path }
+
+// Write writes m (sorted; see Manifest.Sort) to the build data directory of
+// its commit in s.
+func Write(s *buildstore.RepositoryStore, m *Manifest) error {
+	m.Sort()
+	w, err := s.Create(s.FilePath(m.CommitID, Filename))
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Read reads the manifest of the most recent build of commitID in s. If no
+// build of the commit has written a manifest, the error satisfies
+// os.IsNotExist.
+func Read(s *buildstore.RepositoryStore, commitID string) (*Manifest, error) {
+	f, err := s.Open(s.FilePath(commitID, Filename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m *Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("build manifest of commit %s is null", commitID)
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+package buildmanifest
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+)
+
+func TestManifest(t *testing.T) {
+	files := map[string]string{
+		"r/c/u_graph.v0.json": "graph",
+		"r/c/config.json":     "config",
+		"r/c/logs/run.log":    "log",
+		"r/c/.build-state":    "state",
+	}
+	s, err := buildstore.New(rwvfs.Map(files)).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := func(path string) *File {
+		f, err := StatFile(s, "c", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	if _, err := Read(s, "c"); !os.IsNotExist(err) {
+		t.Errorf("got error %v reading a missing manifest, want not-exist error", err)
+	}
+
+	m := &Manifest{
+		Repo:       "r",
+		CommitID:   "c",
+		Toolchains: []*Toolchain{{Path: "t2"}, {Path: "t1", Version: "v"}},
+		Units: []*Unit{
+			{Name: "u", Type: "T", Files: []*File{file("u_graph.v0.json")}},
+			{Name: "u", Type: "S", Failed: true},
+		},
+	}
+	if err := m.AddOtherFiles(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(s, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewriting the manifest doesn't list the old one as a file.
+	m.Files = nil
+	if err := m.AddOtherFiles(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(s, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(s, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Manifest{
+		Repo:       "r",
+		CommitID:   "c",
+		Toolchains: []*Toolchain{{Path: "t1", Version: "v"}, {Path: "t2"}},
+		Units: []*Unit{
+			{Name: "u", Type: "S", Failed: true},
+			{Name: "u", Type: "T", Files: []*File{file("u_graph.v0.json")}},
+		},
+		Files: []*File{file("config.json"), file("logs/run.log")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got manifest %+v, want %+v", got, want)
+	}
+
+	hash, err := buildstore.HashBlob(strings.NewReader("graph"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := file("u_graph.v0.json"); f.Hash != hash || f.Size != int64(len("graph")) {
+		t.Errorf("got file %+v, want hash %s and size %d", f, hash, len("graph"))
+	}
+}
@@ -0,0 +1,368 @@
+package buildstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kr/fs"
+	"github.com/sourcegraph/rwvfs"
+	"github.com/sourcegraph/s3vfs"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// The build data files in a MultiStore may be stored by content: the file's
+// contents are stored once, as a blob named by their SHA-256 hash, and the
+// file at path is stored as a reference to the blob (a file, at
+// BlobRefPath(path), that contains the hash). Identical files, such as the
+// output for a source unit that didn't change between commits or that was
+// built on several branches, thus share one copy, and workers needn't
+// upload blobs that the store already has. The reference files in a
+// repository's store are the index from (commit, source unit) to blob.
+// RepositoryStore's Open and Stat methods resolve references transparently.
+
+// blobsDirName is the name of the directory, at the root of a MultiStore,
+// that holds the blobs of all of its repositories, unless they are stored
+// elsewhere (see NewWithBlobs). (It can't clash with a repository's
+// directory, since repository URIs don't begin with a dot.)
+const blobsDirName = ".blobs"
+
+// BlobRefPath returns the path of the reference to the blob that holds the
+// contents of the file at path.
+func BlobRefPath(path string) string { return path + ".blob" }
+
+// HashBlob returns the hash (the hex-encoded SHA-256 hash) of the contents
+// read from r, which names the blob that holds them.
+func HashBlob(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ValidBlobHash returns an error if hash is not a well-formed blob hash.
+func ValidBlobHash(hash string) error {
+	if len(hash) != 2*sha256.Size || strings.Trim(hash, "0123456789abcdef") != "" {
+		return fmt.Errorf("invalid blob hash %q (want %d lowercase hex digits)", hash, 2*sha256.Size)
+	}
+	return nil
+}
+
+// A BlobStore stores blobs of build data by their hash. Blobs are stored
+// under names that spread them over subdirectories, "HH/HASH" (where HH is
+// the first two digits of HASH), so that listings of the store look the same
+// for each implementation.
+//
+// Implementations store blobs in a filesystem (see NewFSBlobStore) and in
+// cloud object storage (see OpenBlobStore). They stream blobs' contents as
+// they are read and written, so blobs needn't fit in memory.
+type BlobStore interface {
+	// Has reports whether the store has the blob named by hash.
+	Has(hash string) (bool, error)
+
+	// Open opens the blob named by hash. If the store doesn't have it, the
+	// error satisfies os.IsNotExist.
+	Open(hash string) (vfs.ReadSeekCloser, error)
+
+	// Stat returns the FileInfo of the blob named by hash. If the store
+	// doesn't have it, the error satisfies os.IsNotExist.
+	Stat(hash string) (os.FileInfo, error)
+
+	// Put stores the blob whose contents (size bytes, or an unknown number
+	// of bytes if size is negative) are read from r, which must have the
+	// given hash. If the store already has the blob, r isn't read. If the
+	// contents don't have the hash, an error is returned and the store is
+	// left as it was.
+	Put(hash string, r io.Reader, size int64) error
+
+	// Remove removes the blob named by hash.
+	Remove(hash string) error
+
+	// Walk calls fn for each blob in the store, in no particular order.
+	Walk(fn func(hash string, fi os.FileInfo) error) error
+}
+
+// blobPath returns the name of the blob named by hash in a BlobStore.
+func blobPath(hash string) string { return path.Join(hash[:2], hash) }
+
+// Blobs returns the store of the blobs that the files of s's repositories
+// refer to: the one that s was created with (see NewWithBlobs), or else one
+// in the .blobs directory of s's filesystem.
+func (s *MultiStore) Blobs() (BlobStore, error) {
+	if s.blobs != nil {
+		return s.blobs, nil
+	}
+	if _, ok := s.walkableRWVFS.FileSystem.(*s3vfs.S3FS); !ok {
+		if err := rwvfs.MkdirAll(s, blobsDirName); err != nil {
+			return nil, err
+		}
+	}
+	return NewFSBlobStore(rwvfs.Sub(s.walkableRWVFS, blobsDirName)), nil
+}
+
+// NewFSBlobStore returns a BlobStore that stores blobs in fs.
+func NewFSBlobStore(fs rwvfs.FileSystem) BlobStore {
+	return &fsBlobStore{fs}
+}
+
+type fsBlobStore struct {
+	fs rwvfs.FileSystem
+}
+
+func (b *fsBlobStore) Has(hash string) (bool, error) {
+	if err := ValidBlobHash(hash); err != nil {
+		return false, err
+	}
+	_, err := b.fs.Stat(blobPath(hash))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *fsBlobStore) Open(hash string) (vfs.ReadSeekCloser, error) {
+	if err := ValidBlobHash(hash); err != nil {
+		return nil, err
+	}
+	return b.fs.Open(blobPath(hash))
+}
+
+func (b *fsBlobStore) Stat(hash string) (os.FileInfo, error) {
+	if err := ValidBlobHash(hash); err != nil {
+		return nil, err
+	}
+	return b.fs.Stat(blobPath(hash))
+}
+
+func (b *fsBlobStore) Put(hash string, r io.Reader, size int64) error {
+	if err := ValidBlobHash(hash); err != nil {
+		return err
+	}
+	p := blobPath(hash)
+	if _, err := b.fs.Stat(p); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := rwvfs.MkdirAll(b.fs, path.Dir(p)); err != nil {
+		return err
+	}
+	f, err := b.fs.Create(p)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		f.Close()
+		b.fs.Remove(p)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		b.fs.Remove(p)
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		b.fs.Remove(p)
+		return fmt.Errorf("blob contents have hash %s, not %s", got, hash)
+	}
+	return nil
+}
+
+func (b *fsBlobStore) Remove(hash string) error {
+	if err := ValidBlobHash(hash); err != nil {
+		return err
+	}
+	return b.fs.Remove(blobPath(hash))
+}
+
+func (b *fsBlobStore) Walk(fn func(hash string, fi os.FileInfo) error) error {
+	walker := fs.WalkFS(".", walkableRWVFS{b.fs})
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		fi := walker.Stat()
+		if fi == nil || fi.IsDir() || ValidBlobHash(fi.Name()) != nil {
+			continue
+		}
+		if err := fn(fi.Name(), fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LinkBlob stores the file at path in s as a reference to the blob named by
+// hash, which must be in s's blob store.
+func (s *RepositoryStore) LinkBlob(path, hash string) error {
+	if s.blobs == nil {
+		return fmt.Errorf("can't link %s to blob %s: the store has no blob store", path, hash)
+	}
+	if has, err := s.blobs.Has(hash); err != nil {
+		return err
+	} else if !has {
+		return fmt.Errorf("can't link %s to blob %s: no such blob", path, hash)
+	}
+	f, err := s.Create(BlobRefPath(path))
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, hash); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BlobHash returns the hash of the blob that the file at path in s refers
+// to, or false if it isn't stored as a reference to a blob.
+func (s *RepositoryStore) BlobHash(path string) (hash string, ok bool, err error) {
+	return readBlobRef(s.walkableRWVFS, path)
+}
+
+func readBlobRef(fs rwvfs.FileSystem, path string) (hash string, ok bool, err error) {
+	f, err := fs.Open(BlobRefPath(path))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(f, 2*sha256.Size+1))
+	if err != nil {
+		return "", false, err
+	}
+	hash = string(b)
+	if err := ValidBlobHash(hash); err != nil {
+		return "", false, fmt.Errorf("blob reference %s: %s", BlobRefPath(path), err)
+	}
+	return hash, true, nil
+}
+
+// files returns s's filesystem, in which the file at path is read from the
+// blob that BlobRefPath(path) refers to if path itself doesn't exist.
+func (s *RepositoryStore) files() rwvfs.FileSystem {
+	if s.blobs == nil {
+		return s.walkableRWVFS
+	}
+	return blobRefFS{s.walkableRWVFS, s.blobs}
+}
+
+type blobRefFS struct {
+	rwvfs.FileSystem
+	blobs BlobStore
+}
+
+func (b blobRefFS) Open(path string) (vfs.ReadSeekCloser, error) {
+	f, err := b.FileSystem.Open(path)
+	if !os.IsNotExist(err) {
+		return f, err
+	}
+	hash, ok, err2 := readBlobRef(b.FileSystem, path)
+	if err2 != nil {
+		return nil, err2
+	} else if !ok {
+		return nil, err
+	}
+	return b.blobs.Open(hash)
+}
+
+func (b blobRefFS) Stat(path string) (os.FileInfo, error) {
+	fi, err := b.FileSystem.Stat(path)
+	if !os.IsNotExist(err) {
+		return fi, err
+	}
+	rfi, err2 := b.FileSystem.Stat(BlobRefPath(path))
+	if err2 != nil {
+		return nil, err
+	}
+	hash, _, err := readBlobRef(b.FileSystem, path)
+	if err != nil {
+		return nil, err
+	}
+	bfi, err := b.blobs.Stat(hash)
+	if err != nil {
+		return nil, err
+	}
+	return renamedFileInfo{rfi, filepath.Base(path), bfi.Size()}, nil
+}
+
+// A StoredFileInfo describes a file as it is stored in a repository's store:
+// a build data file, or one of the forms in which it may be stored (see
+// StoredPaths), or a chunk of one.
+type StoredFileInfo struct {
+	// Path is the path of the file, relative to the build data directory of
+	// its commit. If the file is stored as a reference to a blob or as a
+	// delta, it is the path that the reference or delta is for (not
+	// BlobRefPath(Path) or DeltaPath(Path)).
+	Path string
+
+	// Hash is the hash of the file's contents (see HashBlob), or empty if
+	// the file isn't stored as a reference to a blob or as a delta.
+	Hash string `json:",omitempty"`
+
+	Size int64
+}
+
+// StoredFiles lists the files stored in the build data directory of a
+// commit, without reassembling chunked files or decompressing compressed
+// ones. Files stored as deltas are listed as the files they describe, which
+// can only be read with Open.
+func (s *RepositoryStore) StoredFiles(commitID string) ([]*StoredFileInfo, error) {
+	files := []*StoredFileInfo{}
+	dir := s.CommitPath(commitID)
+	walker := fs.WalkFS(dir, s.walkableRWVFS)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) && walker.Path() == dir {
+				break
+			}
+			return nil, err
+		}
+		fi := walker.Stat()
+		if fi == nil || fi.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(dir, walker.Path())
+		if err != nil {
+			return nil, err
+		}
+		file := &StoredFileInfo{Path: filepath.ToSlash(rel), Size: fi.Size()}
+		if strings.HasSuffix(file.Path, ".blob") {
+			file.Path = strings.TrimSuffix(file.Path, ".blob")
+			hash, _, err := s.BlobHash(s.FilePath(commitID, file.Path))
+			if err != nil {
+				return nil, err
+			}
+			fi, err := s.files().Stat(s.FilePath(commitID, file.Path))
+			if err != nil {
+				return nil, err
+			}
+			file.Hash, file.Size = hash, fi.Size()
+		} else if strings.HasSuffix(file.Path, ".delta") {
+			file.Path = strings.TrimSuffix(file.Path, ".delta")
+			d, _, err := s.readDelta(s.FilePath(commitID, file.Path))
+			if err != nil {
+				return nil, err
+			}
+			file.Hash, file.Size = d.Hash, d.Size
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// OpenStored opens the file stored at path (such as a chunk or the
+// compressed form of a build data file), reading it from the blob that it
+// refers to if it is stored as a reference to a blob. Unlike Open, it
+// doesn't reassemble chunked files or decompress compressed ones.
+func (s *RepositoryStore) OpenStored(path string) (vfs.ReadSeekCloser, error) {
+	return s.files().Open(path)
+}
@@ -0,0 +1,79 @@
+package buildstore
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// azureAPIVersion is the version of the Azure Blob Storage REST API that
+// azureAPI uses.
+const azureAPIVersion = "2019-12-12"
+
+// azureAPI is the API of Azure Blob Storage. Requests are authorized with a
+// shared access signature (SAS) for the container.
+type azureAPI struct {
+	containerURL string
+	sasToken     string // a URL query string, without the leading "?"
+}
+
+func (a *azureAPI) newRequest(method, name string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	if a.sasToken != "" {
+		sas, err := url.ParseQuery(a.sasToken)
+		if err != nil {
+			return nil, err
+		}
+		if query == nil {
+			query = url.Values{}
+		}
+		for k, v := range sas {
+			query[k] = v
+		}
+	}
+	u, err := objectURL(a.containerURL, name, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newObjectRequest(method, u, body, size)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Version", azureAPIVersion)
+	if method == "PUT" {
+		req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	}
+	return req, nil
+}
+
+func (a *azureAPI) listQuery(prefix, marker string) url.Values {
+	q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+	if marker != "" {
+		q.Set("marker", marker)
+	}
+	return q
+}
+
+func (a *azureAPI) parseList(r io.Reader) ([]*objectInfo, string, error) {
+	var result struct {
+		Blobs []struct {
+			Name       string
+			Properties struct {
+				ContentLength int64  `xml:"Content-Length"`
+				LastModified  string `xml:"Last-Modified"`
+			}
+		} `xml:"Blobs>Blob"`
+		NextMarker string
+	}
+	if err := xml.NewDecoder(r).Decode(&result); err != nil {
+		return nil, "", err
+	}
+	objects := make([]*objectInfo, len(result.Blobs))
+	for i, b := range result.Blobs {
+		objects[i] = &objectInfo{name: b.Name, size: b.Properties.ContentLength}
+		if t, err := http.ParseTime(b.Properties.LastModified); err == nil {
+			objects[i].modTime = t
+		}
+	}
+	return objects, result.NextMarker, nil
+}
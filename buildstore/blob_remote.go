@@ -0,0 +1,398 @@
+package buildstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/rwvfs"
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/srclib/network"
+)
+
+// OpenBlobStore opens the BlobStore at storeURL, which is one of:
+//
+//	s3://BUCKET/PREFIX                 an Amazon S3 bucket
+//	gs://BUCKET/PREFIX                 a Google Cloud Storage bucket
+//	azblob://ACCOUNT/CONTAINER/PREFIX  an Azure Blob Storage container
+//	file:///DIR or DIR                 a directory
+//
+// Blobs are stored as objects (or files) named PREFIX/HH/HASH (see
+// BlobStore). Credentials are read from the environment: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN for S3 (whose region is
+// AWS_REGION, or the region query parameter); GOOGLE_OAUTH_ACCESS_TOKEN (as
+// printed by "gcloud auth print-access-token") for Cloud Storage; and
+// AZURE_STORAGE_SAS_TOKEN (a shared access signature for the container) for
+// Azure. Without credentials, requests are made anonymously. The endpoint
+// query parameter overrides the service's endpoint (e.g., for S3-compatible
+// storage such as MinIO, or for emulators): the bucket's (or container's)
+// URL is the endpoint followed by its name. Requests go through the
+// proxies and mirrors of network.Default (see network.Transport).
+func OpenBlobStore(storeURL string) (BlobStore, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Scheme == "file" {
+		dir := u.Path
+		if u.Scheme == "" {
+			dir = storeURL
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		return NewFSBlobStore(rwvfs.OS(dir)), nil
+	}
+
+	bucket, prefix := u.Host, strings.Trim(u.Path, "/")
+	if u.Scheme == "azblob" {
+		// The account is the host, and the container is the first path
+		// component.
+		parts := strings.SplitN(prefix, "/", 2)
+		bucket, prefix = parts[0], ""
+		if len(parts) == 2 {
+			prefix = parts[1]
+		}
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("blob store URL %q has no bucket or container", storeURL)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	endpoint := u.Query().Get("endpoint")
+	bucketURL := func(defaultURL string) string {
+		if endpoint != "" {
+			return strings.TrimSuffix(endpoint, "/") + "/" + bucket
+		}
+		return defaultURL
+	}
+
+	var api objectAPI
+	switch u.Scheme {
+	case "s3":
+		region := u.Query().Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		api = &s3API{
+			bucketURL:    bucketURL(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)),
+			region:       region,
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	case "gs":
+		api = &s3API{
+			bucketURL:   bucketURL("https://storage.googleapis.com/" + bucket),
+			bearerToken: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		}
+	case "azblob":
+		api = &azureAPI{
+			containerURL: bucketURL(fmt.Sprintf("https://%s.blob.core.windows.net/%s", u.Host, bucket)),
+			sasToken:     strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported blob store URL scheme %q (want s3, gs, azblob, or file)", u.Scheme)
+	}
+	return &objectStore{client: network.Client(), api: api, prefix: prefix}, nil
+}
+
+// An objectAPI is the HTTP API of a cloud object storage service.
+type objectAPI interface {
+	// newRequest returns an authorized request for the object named name
+	// (or for the bucket, if name is empty), whose body (if any) is size
+	// bytes read from body.
+	newRequest(method, name string, query url.Values, body io.Reader, size int64) (*http.Request, error)
+
+	// listQuery returns the query of the request to list the objects whose
+	// names begin with prefix, starting after marker.
+	listQuery(prefix, marker string) url.Values
+
+	// parseList parses the response to a list request. It returns the
+	// objects listed and the marker to list the next page of objects from,
+	// or "" if there are no more.
+	parseList(r io.Reader) (objects []*objectInfo, next string, err error)
+}
+
+// objectStore is a BlobStore that stores blobs as objects in a bucket of
+// cloud object storage, accessed with api.
+type objectStore struct {
+	client *http.Client
+	api    objectAPI
+	prefix string // prepended to the names of blobs (see blobPath)
+}
+
+func (o *objectStore) name(hash string) string { return o.prefix + blobPath(hash) }
+
+// do sends req and returns its response if it was successful. Otherwise, it
+// returns an error, which satisfies os.IsNotExist if the object wasn't
+// found.
+func (o *objectStore) do(req *http.Request, name string) (*http.Response, error) {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && name != "" {
+		return nil, &os.PathError{Op: req.Method, Path: name, Err: os.ErrNotExist}
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (o *objectStore) Has(hash string) (bool, error) {
+	_, err := o.Stat(hash)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (o *objectStore) Stat(hash string) (os.FileInfo, error) {
+	if err := ValidBlobHash(hash); err != nil {
+		return nil, err
+	}
+	req, err := o.api.newRequest("HEAD", o.name(hash), nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.do(req, o.name(hash))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	fi := &objectInfo{name: hash, size: resp.ContentLength}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		fi.modTime = t
+	}
+	return fi, nil
+}
+
+func (o *objectStore) Open(hash string) (vfs.ReadSeekCloser, error) {
+	fi, err := o.Stat(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &objectReader{o: o, name: o.name(hash), size: fi.Size()}, nil
+}
+
+// Put copies the blob's contents to a temporary file and checks their hash
+// before uploading them, so that an object with the wrong contents is never
+// stored (even briefly, and even if it would be replaced).
+func (o *objectStore) Put(hash string, r io.Reader, size int64) error {
+	if err := ValidBlobHash(hash); err != nil {
+		return err
+	}
+	if has, err := o.Has(hash); err != nil {
+		return err
+	} else if has {
+		return nil
+	}
+
+	f, err := ioutil.TempFile("", "srclib-blob")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("blob %s has %d bytes, not %d", hash, n, size)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return fmt.Errorf("blob contents have hash %s, not %s", got, hash)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if n > 0 {
+		body = f
+	}
+	req, err := o.api.newRequest("PUT", o.name(hash), nil, body, n)
+	if err != nil {
+		return err
+	}
+	resp, err := o.do(req, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (o *objectStore) Remove(hash string) error {
+	if err := ValidBlobHash(hash); err != nil {
+		return err
+	}
+	req, err := o.api.newRequest("DELETE", o.name(hash), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := o.do(req, o.name(hash))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (o *objectStore) Walk(fn func(hash string, fi os.FileInfo) error) error {
+	var marker string
+	for {
+		req, err := o.api.newRequest("GET", "", o.api.listQuery(o.prefix, marker), nil, 0)
+		if err != nil {
+			return err
+		}
+		resp, err := o.do(req, "")
+		if err != nil {
+			return err
+		}
+		objects, next, err := o.api.parseList(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("listing blobs: %s", err)
+		}
+		for _, obj := range objects {
+			hash := path.Base(obj.name)
+			if ValidBlobHash(hash) != nil || obj.name != o.name(hash) {
+				continue
+			}
+			obj.name = hash
+			if err := fn(hash, obj); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		marker = next
+	}
+}
+
+// objectReader reads an object, streaming it with a GET request (for the
+// range of the object from the current offset) that is made on the first
+// Read after each Seek.
+type objectReader struct {
+	o         *objectStore
+	name      string
+	size, off int64
+	body      io.ReadCloser // the response body of the current GET, if any
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		req, err := r.o.api.newRequest("GET", r.name, nil, nil, 0)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.off))
+		resp, err := r.o.do(req, r.name)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && r.off > 0 {
+			resp.Body.Close()
+			return 0, fmt.Errorf("GET %s: range request not supported (%s)", r.name, resp.Status)
+		}
+		r.body = resp.Body
+	}
+	n, err := r.body.Read(p)
+	r.off += int64(n)
+	if err == io.EOF && r.off < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 1:
+		offset += r.off
+	case 2:
+		offset += r.size
+	}
+	if offset < 0 {
+		return r.off, fmt.Errorf("seek %s: negative offset", r.name)
+	}
+	if offset != r.off {
+		r.Close()
+		r.off = offset
+	}
+	return offset, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// objectInfo is the FileInfo of an object.
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi *objectInfo) Name() string       { return filepath.Base(fi.name) }
+func (fi *objectInfo) Size() int64        { return fi.size }
+func (fi *objectInfo) Mode() os.FileMode  { return 0444 }
+func (fi *objectInfo) ModTime() time.Time { return fi.modTime }
+func (fi *objectInfo) IsDir() bool        { return false }
+func (fi *objectInfo) Sys() interface{}   { return nil }
+
+// objectURL returns the URL of the object named name (or of the bucket, if
+// name is empty) in the bucket at bucketURL.
+func objectURL(bucketURL, name string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	} else if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = query.Encode()
+	return u, nil
+}
+
+// newObjectRequest returns a request for u whose body is size bytes read
+// from body.
+func newObjectRequest(method string, u *url.URL, body io.Reader, size int64) (*http.Request, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if method == "PUT" {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return req, nil
+}
@@ -0,0 +1,316 @@
+package buildstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/network"
+)
+
+// fakeObjectServer serves the parts of the S3 and Azure Blob Storage APIs
+// that objectStore uses, storing objects in memory.
+type fakeObjectServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte // keyed by URL path
+	auth    []string          // the Authorization header and sig query parameter of each request
+	puts    int               // the number of objects written
+}
+
+// fakeListPageSize is the number of objects per page of the fake object
+// server's listings, which is small to test paging.
+const fakeListPageSize = 2
+
+func (s *fakeObjectServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = append(s.auth, r.Header.Get("Authorization")+r.URL.Query().Get("sig"))
+
+	q := r.URL.Query()
+	if r.Method == "GET" && strings.Count(strings.Trim(r.URL.Path, "/"), "/") == 0 {
+		s.serveList(w, r.URL.Path+"/", q.Get("prefix"), q.Get("marker"), q.Get("comp") == "list")
+		return
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		data, present := s.objects[r.URL.Path]
+		if !present {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Unix(0, 0), bytes.NewReader(data))
+	case "PUT":
+		if r.ContentLength < 0 {
+			http.Error(w, "length required", http.StatusLengthRequired)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = data
+		s.puts++
+	case "DELETE":
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveList lists the objects in bucket (the URL path of the bucket, with a
+// trailing slash) whose names begin with prefix, starting after marker.
+func (s *fakeObjectServer) serveList(w http.ResponseWriter, bucket, prefix, marker string, azure bool) {
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, bucket+prefix) && k > bucket+marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > fakeListPageSize
+	if truncated {
+		keys = keys[:fakeListPageSize]
+	}
+	lastModified := time.Unix(0, 0).UTC()
+
+	var v interface{}
+	if azure {
+		type blob struct {
+			Name          string
+			ContentLength int    `xml:"Properties>Content-Length"`
+			LastModified  string `xml:"Properties>Last-Modified"`
+		}
+		result := struct {
+			XMLName    xml.Name `xml:"EnumerationResults"`
+			Blobs      []blob   `xml:"Blobs>Blob"`
+			NextMarker string
+		}{}
+		for _, k := range keys {
+			result.Blobs = append(result.Blobs, blob{strings.TrimPrefix(k, bucket), len(s.objects[k]), lastModified.Format(http.TimeFormat)})
+		}
+		if truncated {
+			result.NextMarker = strings.TrimPrefix(keys[len(keys)-1], bucket)
+		}
+		v = result
+	} else {
+		type object struct {
+			Key          string
+			Size         int
+			LastModified time.Time
+		}
+		result := struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			IsTruncated bool
+			Contents    []object
+		}{IsTruncated: truncated}
+		for _, k := range keys {
+			result.Contents = append(result.Contents, object{strings.TrimPrefix(k, bucket), len(s.objects[k]), lastModified})
+		}
+		v = result
+	}
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":         "k",
+		"AWS_SECRET_ACCESS_KEY":     "s",
+		"GOOGLE_OAUTH_ACCESS_TOKEN": "t",
+		"AZURE_STORAGE_SAS_TOKEN":   "?sv=2019-12-12&sig=x",
+	}
+	for k, v := range env {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	tests := []struct {
+		url      string
+		wantAuth string
+	}{
+		{url: "s3://b/p?region=r&endpoint=", wantAuth: "AWS4-HMAC-SHA256 Credential=k/"},
+		{url: "gs://b/p?endpoint=", wantAuth: "Bearer t"},
+		{url: "azblob://a/b/p?endpoint=", wantAuth: "x"},
+	}
+	for _, test := range tests {
+		srv := &fakeObjectServer{objects: map[string][]byte{
+			"/b/p/junk":                       []byte("not a blob"),
+			"/b/other/" + blobPath(otherHash): []byte("outside the prefix"),
+		}}
+		ts := httptest.NewServer(srv)
+		testObjectStore(t, test.url+ts.URL)
+		ts.Close()
+		if want := 3; srv.puts != want {
+			t.Errorf("%s: got %d objects written, want %d (only the valid blobs, once)", test.url, srv.puts, want)
+		}
+		for _, auth := range srv.auth {
+			if !strings.HasPrefix(auth, test.wantAuth) {
+				t.Errorf("%s: got authorization %q, want %q...", test.url, auth, test.wantAuth)
+				break
+			}
+		}
+	}
+}
+
+// countingTransport counts the requests that it sends with
+// http.DefaultTransport.
+type countingTransport struct{ n int }
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestObjectStore_networkTransport(t *testing.T) {
+	orig := network.Transport
+	defer func() { network.Transport = orig }()
+	ct := &countingTransport{}
+	network.Transport = ct
+
+	ts := httptest.NewServer(&fakeObjectServer{objects: map[string][]byte{}})
+	defer ts.Close()
+	b, err := OpenBlobStore("s3://b/p?region=r&endpoint=" + ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Has(otherHash); err != nil {
+		t.Fatal(err)
+	}
+	if ct.n == 0 {
+		t.Error("object store didn't send requests with network.Transport")
+	}
+}
+
+// otherHash is the hash of a blob outside of the prefix of the blob stores
+// that testObjectStore tests.
+var otherHash = strings.Repeat("1", 64)
+
+func testObjectStore(t *testing.T, url string) {
+	b, err := OpenBlobStore(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := func(data string) string {
+		h, err := HashBlob(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	big := strings.Repeat("0123456789", 100)
+	blobs := map[string]string{hash("a"): "a", hash(""): "", hash(big): big}
+	for h, data := range blobs {
+		size := int64(len(data))
+		if data == "a" {
+			size = -1 // unknown
+		}
+		if err := b.Put(h, strings.NewReader(data), size); err != nil {
+			t.Fatalf("%s: Put %q: %s", url, data, err)
+		}
+	}
+	if err := b.Put(hash("b"), strings.NewReader("c"), 1); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("%s: got error %v putting a blob with the wrong contents, want hash error", url, err)
+	}
+	if has, err := b.Has(hash("b")); err != nil || has {
+		t.Errorf("%s: got Has %v (error %v) after failed Put, want false", url, has, err)
+	}
+	if err := b.Put(hash("b"), strings.NewReader("b"), 2); err == nil {
+		t.Errorf("%s: got no error putting a blob with the wrong size", url)
+	}
+	// Putting a stored blob with the wrong contents leaves it intact.
+	b.Put(hash(big), strings.NewReader("c"), 1)
+	if got := readBlob(t, b, hash(big)); got != big {
+		t.Errorf("%s: got blob %.20q... after putting it again with the wrong contents, want %.20q...", url, got, big)
+	}
+
+	for h, data := range blobs {
+		if fi, err := b.Stat(h); err != nil || fi.Size() != int64(len(data)) {
+			t.Errorf("%s: Stat %q: got %v (error %v), want size %d", url, data, fi, err, len(data))
+		}
+	}
+	f, err := b.Open(hash(big))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(offset int64, whence int, n int) string {
+		if _, err := f.Seek(offset, whence); err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(f, p); err != nil {
+			t.Fatalf("%s: reading %d bytes at %d (whence %d): %s", url, n, offset, whence, err)
+		}
+		return string(p)
+	}
+	if got, want := read(505, 0, 3), big[505:508]; got != want {
+		t.Errorf("%s: got %q at 505, want %q", url, got, want)
+	}
+	if got, want := read(-4, 2, 4), big[len(big)-4:]; got != want {
+		t.Errorf("%s: got %q at end, want %q", url, got, want)
+	}
+	if got := read(0, 0, len(big)); got != big {
+		t.Errorf("%s: got contents %q, want %q", url, got, big)
+	}
+	f.Close()
+
+	walked := map[string]bool{}
+	err = b.Walk(func(h string, fi os.FileInfo) error {
+		if fi.Name() != h || fi.Size() != int64(len(blobs[h])) {
+			return fmt.Errorf("blob %s has FileInfo name %q and size %d, want size %d", h, fi.Name(), fi.Size(), len(blobs[h]))
+		}
+		walked[h] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != len(blobs) {
+		t.Errorf("%s: walked %v, want %d blobs", url, walked, len(blobs))
+	}
+
+	// Files refer to blobs in any BlobStore in the same way.
+	s, err := NewWithBlobs(rwvfs.Map(map[string]string{}), b).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LinkBlob("c/f", hash(big)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.readAll("c/f", s.Open); err != nil || string(data) != big {
+		t.Errorf("%s: got linked file %q (error %v), want %q", url, data, err, big)
+	}
+
+	if err := b.Remove(hash("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Open(hash("a")); !os.IsNotExist(err) {
+		t.Errorf("%s: got error %v opening a removed blob, want not-exist error", url, err)
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla test from the AWS Signature Version 4 test suite.
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signV4(req, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got Authorization\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package buildstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3API is the API of Amazon S3, and of services that are compatible with
+// it, such as the XML API of Google Cloud Storage.
+type s3API struct {
+	bucketURL string
+
+	// Requests are signed with AWS Signature Version 4 if accessKey is
+	// set, or else authorized with bearerToken if it is set.
+	region, accessKey, secretKey, sessionToken string
+	bearerToken                                string
+}
+
+func (a *s3API) newRequest(method, name string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	u, err := objectURL(a.bucketURL, name, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newObjectRequest(method, u, body, size)
+	if err != nil {
+		return nil, err
+	}
+	if a.accessKey != "" {
+		// The body is streamed, so it can't be hashed in advance.
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		signV4(req, a.accessKey, a.secretKey, a.sessionToken, a.region, "s3", time.Now())
+	} else if a.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.bearerToken)
+	}
+	return req, nil
+}
+
+func (a *s3API) listQuery(prefix, marker string) url.Values {
+	q := url.Values{"prefix": {prefix}}
+	if marker != "" {
+		q.Set("marker", marker)
+	}
+	return q
+}
+
+func (a *s3API) parseList(r io.Reader) ([]*objectInfo, string, error) {
+	var result struct {
+		IsTruncated bool
+		NextMarker  string
+		Contents    []struct {
+			Key          string
+			Size         int64
+			LastModified time.Time
+		}
+	}
+	if err := xml.NewDecoder(r).Decode(&result); err != nil {
+		return nil, "", err
+	}
+	objects := make([]*objectInfo, len(result.Contents))
+	for i, c := range result.Contents {
+		objects[i] = &objectInfo{name: c.Key, size: c.Size, modTime: c.LastModified}
+	}
+	if !result.IsTruncated {
+		return objects, "", nil
+	}
+	// NextMarker is only returned by S3 if a delimiter was given, in which
+	// case the next page starts after the last object.
+	next := result.NextMarker
+	if next == "" && len(objects) > 0 {
+		next = objects[len(objects)-1].name
+	}
+	return objects, next, nil
+}
+
+// signV4 signs req with AWS Signature Version 4, for the given credentials,
+// region and service, at time t. The hash of the payload is read from the
+// X-Amz-Content-Sha256 header, if it is set (otherwise, the body must be
+// empty).
+func signV4(req *http.Request, accessKey, secretKey, sessionToken, region, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = hex.EncodeToString(sha256Sum(nil))
+	}
+
+	// Sign the host header and the x-amz-* headers.
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sha256Sum([]byte(canonicalRequest)))
+	key := []byte("AWS4" + secretKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query string q as AWS Signature Version 4
+// canonicalizes it: sorted, with spaces encoded as %20.
+func canonicalQuery(q url.Values) string {
+	return strings.Replace(q.Encode(), "+", "%20", -1)
+}
+
+func sha256Sum(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, s)
+	return h.Sum(nil)
+}
@@ -0,0 +1,159 @@
+package buildstore
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+func TestBlobStore(t *testing.T) {
+	files := make(map[string]string)
+	ms := New(rwvfs.Map(files))
+	blobs, err := ms.Blobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ms.RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const data = "0123456789"
+	hash, err := HashBlob(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LinkBlob("c/f", hash); err == nil || !strings.Contains(err.Error(), "no such blob") {
+		t.Errorf("got error %v linking to a missing blob, want no such blob error", err)
+	}
+	if err := blobs.Put(hash, strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("got error %v putting a blob with the wrong contents, want hash error", err)
+	}
+	if has, err := blobs.Has(hash); err != nil || has {
+		t.Errorf("got Has %v (error %v) after failed Put, want false", has, err)
+	}
+	if err := blobs.Put("../x", strings.NewReader("x"), 1); err == nil {
+		t.Error("got no error putting a blob with an invalid hash")
+	}
+
+	// Files of any repository (and in any of their stored forms) may refer
+	// to the same blob.
+	if err := blobs.Put(hash, strings.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	// Putting a blob that is already stored leaves it intact, even if the
+	// new contents are wrong.
+	blobs.Put(hash, strings.NewReader("x"), 1)
+	if got := readBlob(t, blobs, hash); got != data {
+		t.Errorf("got blob %q after putting it again with the wrong contents, want %q", got, data)
+	}
+	s2, err := ms.RepositoryStore("r2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []struct {
+		s    *RepositoryStore
+		path string
+	}{{s, "c/f"}, {s, "c2/f.chunk0"}, {s2, "c/g"}} {
+		if err := link.s.LinkBlob(link.path, hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := PutChunked("c2/f", strings.NewReader(data+data), 20, 10, func(path string, r io.ReadSeeker) error {
+		if path == ChunkPath("c2/f", 0) {
+			return nil // already linked
+		}
+		f, err := s.Create(path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			return err
+		}
+		return f.Close()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(strings.Join(keys(files), " "), hash); n != 1 {
+		t.Errorf("got %d copies of the blob, want 1 (files are %v)", n, keys(files))
+	}
+
+	for _, test := range []struct {
+		s          *RepositoryStore
+		path, want string
+	}{{s, "c/f", data}, {s, "c2/f", data + data}, {s2, "c/g", data}} {
+		f, err := test.s.Open(test.path)
+		if err != nil {
+			t.Errorf("%s: %s", test.path, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil || string(got) != test.want {
+			t.Errorf("%s: got %q (error %v), want %q", test.path, got, err, test.want)
+		}
+		if fi, err := test.s.Stat(test.path); err != nil || fi.Size() != int64(len(test.want)) {
+			t.Errorf("%s: got Stat %v (error %v), want size %d", test.path, fi, err, len(test.want))
+		}
+	}
+
+	dataFiles, err := s.AllDataFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range dataFiles {
+		got = append(got, f.CommitID+"/"+f.Path)
+	}
+	if want := []string{"c/f", "c2/f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data files %v, want %v", got, want)
+	}
+
+	stored, err := s.StoredFiles("c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotStored []StoredFileInfo
+	for _, f := range stored {
+		gotStored = append(gotStored, *f)
+	}
+	wantStored := []StoredFileInfo{
+		{Path: "f.chunk0", Hash: hash, Size: 10},
+		{Path: "f.chunk1", Size: 10},
+		{Path: "f.chunks", Size: int64(len(files["r/c2/f.chunks"]))},
+	}
+	if !reflect.DeepEqual(gotStored, wantStored) {
+		t.Errorf("got stored files %+v, want %+v", gotStored, wantStored)
+	}
+	if f, err := s.OpenStored(s.FilePath("c2", "f.chunk0")); err != nil {
+		t.Error(err)
+	} else if b, _ := ioutil.ReadAll(f); !bytes.Equal(b, []byte(data)) {
+		t.Errorf("got stored chunk %q, want %q", b, data)
+	}
+}
+
+func keys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func readBlob(t *testing.T, blobs BlobStore, hash string) string {
+	f, err := blobs.Open(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,135 @@
+package buildstore
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kr/fs"
+	"github.com/sourcegraph/rwvfs"
+)
+
+// The builds in a store are indexed by branch as well as by commit: for
+// each branch, a file (in the branchesDirName directory, named for the
+// branch) lists the commits on the branch that were built, most recent
+// first. The index is only a record of which commit was checked out on
+// each branch when it was built; a commit's build data is still stored
+// (and found) by its commit ID.
+
+// branchesDirName is the name of the directory, at the root of a
+// RepositoryStore, that holds the branch index. It is hidden, so it can't
+// be confused with the build data directory of a commit.
+const branchesDirName = ".branches"
+
+// maxBranchBuilds is the number of builds per branch that the branch index
+// keeps.
+const maxBranchBuilds = 50
+
+// A BranchBuild records that a commit was built while it was checked out
+// on a branch.
+type BranchBuild struct {
+	CommitID string
+	Time     time.Time // when the build finished
+}
+
+func branchIndexPath(branch string) string {
+	return path.Join(branchesDirName, branch+".json")
+}
+
+// RecordBranchBuild records in the branch index that commitID was built on
+// branch at time t.
+func (s *RepositoryStore) RecordBranchBuild(branch, commitID string, t time.Time) error {
+	builds, err := s.readBranchIndex(branch)
+	if err != nil {
+		return err
+	}
+	updated := []*BranchBuild{{CommitID: commitID, Time: t}}
+	for _, b := range builds {
+		if b.CommitID != commitID && len(updated) < maxBranchBuilds {
+			updated = append(updated, b)
+		}
+	}
+
+	p := branchIndexPath(branch)
+	if err := rwvfs.MkdirAll(s, path.Dir(p)); err != nil {
+		return err
+	}
+	f, err := s.Create(p)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(updated); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BranchBuilds returns the builds of commits on branch whose build data is
+// still in s, most recent first.
+func (s *RepositoryStore) BranchBuilds(branch string) ([]*BranchBuild, error) {
+	builds, err := s.readBranchIndex(branch)
+	if err != nil {
+		return nil, err
+	}
+	present := builds[:0]
+	for _, b := range builds {
+		if _, err := s.Stat(s.CommitPath(b.CommitID)); err == nil {
+			present = append(present, b)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return present, nil
+}
+
+// LatestBranchBuild returns the commit ID of the most recent build on
+// branch whose build data is still in s, or "" if there is none.
+func (s *RepositoryStore) LatestBranchBuild(branch string) (string, error) {
+	builds, err := s.BranchBuilds(branch)
+	if err != nil || len(builds) == 0 {
+		return "", err
+	}
+	return builds[0].CommitID, nil
+}
+
+// Branches returns the names of the branches that the branch index has
+// recorded builds on, sorted.
+func (s *RepositoryStore) Branches() ([]string, error) {
+	var branches []string
+	walker := fs.WalkFS(branchesDirName, s.walkableRWVFS)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		fi := walker.Stat()
+		if fi == nil || fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+		p := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), "/"), branchesDirName+"/")
+		branches = append(branches, strings.TrimSuffix(p, ".json"))
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+func (s *RepositoryStore) readBranchIndex(branch string) ([]*BranchBuild, error) {
+	f, err := s.Open(branchIndexPath(branch))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var builds []*BranchBuild
+	if err := json.NewDecoder(f).Decode(&builds); err != nil {
+		return nil, err
+	}
+	return builds, nil
+}
@@ -0,0 +1,66 @@
+package buildstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+func TestRepositoryStore_branches(t *testing.T) {
+	files := map[string]string{
+		"c1/f.json": "f",
+		"c2/f.json": "f",
+		"c3/f.json": "f",
+	}
+	s := newRepositoryStore(walkableRWVFS{rwvfs.Map(files)})
+
+	if c, err := s.LatestBranchBuild("master"); err != nil || c != "" {
+		t.Errorf("got latest build %q (error %v) with none recorded, want none", c, err)
+	}
+
+	t0 := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, r := range []struct{ branch, commitID string }{
+		{"master", "c1"},
+		{"feature/x", "c2"},
+		{"master", "c3"},
+		{"feature/x", "gone"}, // its build data was removed
+		{"master", "c1"},      // rebuilt
+	} {
+		if err := s.RecordBranchBuild(r.branch, r.commitID, t0.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	builds, err := s.BranchBuilds("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*BranchBuild{{"c1", t0.Add(4 * time.Hour)}, {"c3", t0.Add(2 * time.Hour)}}
+	if !reflect.DeepEqual(builds, want) {
+		t.Errorf("got master builds %v, want %v", builds, want)
+	}
+	if c, err := s.LatestBranchBuild("feature/x"); err != nil || c != "c2" {
+		t.Errorf("got latest feature/x build %q (error %v), want c2", c, err)
+	}
+	if branches, err := s.Branches(); err != nil || !reflect.DeepEqual(branches, []string{"feature/x", "master"}) {
+		t.Errorf("got branches %v (error %v), want [feature/x master]", branches, err)
+	}
+
+	// The branch index isn't build data.
+	commits, err := s.ListCommits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c1", "c2", "c3"}; !reflect.DeepEqual(commits, want) {
+		t.Errorf("got commits %v, want %v", commits, want)
+	}
+	dataFiles, err := s.AllDataFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataFiles) != 3 {
+		t.Errorf("got %d data files, want 3 (the index shouldn't be listed)", len(dataFiles))
+	}
+}
@@ -0,0 +1,271 @@
+package buildstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/rwvfs"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// Build data files that are too large to store as single objects (some
+// storage backends reject multi-GB objects) are stored in chunks, which a
+// manifest ties together. The chunks of the file at path are at
+// ChunkPath(path, i), and its manifest is at ManifestPath(path); there is no
+// file at path itself. RepositoryStore's Open and Stat methods treat chunked
+// files like any other, so readers needn't know whether a file is chunked
+// (or compressed; see Compress).
+
+// DefaultMaxChunkSize is the default maximum size of the objects that build
+// data files are split into when they are pushed to storage.
+const DefaultMaxChunkSize int64 = 1 << 30
+
+// A ChunkManifest describes how a build data file is split into chunks.
+type ChunkManifest struct {
+	// Size is the size of the whole file, in bytes.
+	Size int64
+
+	// ChunkSizes are the sizes of the file's chunks, in order.
+	ChunkSizes []int64
+}
+
+// NewChunkManifest returns the manifest of a file of the given size split
+// into chunks of maxChunkSize bytes (except for the last, which may be
+// smaller).
+func NewChunkManifest(size, maxChunkSize int64) *ChunkManifest {
+	m := &ChunkManifest{Size: size}
+	for off := int64(0); off < size; off += maxChunkSize {
+		n := maxChunkSize
+		if size-off < n {
+			n = size - off
+		}
+		m.ChunkSizes = append(m.ChunkSizes, n)
+	}
+	return m
+}
+
+// Validate returns an error if m's chunk sizes don't add up to its size.
+func (m *ChunkManifest) Validate() error {
+	var total int64
+	for i, n := range m.ChunkSizes {
+		if n <= 0 {
+			return fmt.Errorf("chunk %d has size %d (must be positive)", i, n)
+		}
+		total += n
+	}
+	if total != m.Size {
+		return fmt.Errorf("chunks have total size %d, but the file has size %d", total, m.Size)
+	}
+	return nil
+}
+
+// ManifestPath returns the path of the chunk manifest of the file at path.
+func ManifestPath(path string) string { return path + ".chunks" }
+
+// ChunkPath returns the path of the i'th chunk of the file at path.
+func ChunkPath(path string, i int) string { return fmt.Sprintf("%s.chunk%d", path, i) }
+
+// isChunkPath reports whether path is the path of a chunk or manifest of a
+// chunked file.
+func isChunkPath(path string) bool {
+	if strings.HasSuffix(path, ".chunks") {
+		return true
+	}
+	i := strings.LastIndex(path, ".chunk")
+	if i == -1 || i+len(".chunk") == len(path) {
+		return false
+	}
+	for _, c := range path[i+len(".chunk"):] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ChunkedPath returns the path of the chunked file that the chunk at path is
+// a piece of, or false if path isn't the path of a chunk (see ChunkPath).
+func ChunkedPath(path string) (string, bool) {
+	if !isChunkPath(path) || strings.HasSuffix(path, ".chunks") {
+		return "", false
+	}
+	return path[:strings.LastIndex(path, ".chunk")], true
+}
+
+// PutChunked stores a file of the given size, whose contents are read from
+// r, using put. If the file is larger than maxChunkSize (and maxChunkSize is
+// positive), put is called with each of its chunks and then with its
+// manifest (so that the file doesn't appear until all of its chunks are
+// stored); otherwise it is called once with the whole file. The data that
+// put is called with is seekable, so that put may read it more than once
+// (e.g., to hash it before storing it; see HashBlob).
+func PutChunked(path string, r io.ReaderAt, size, maxChunkSize int64, put func(path string, data io.ReadSeeker) error) error {
+	if maxChunkSize <= 0 || size <= maxChunkSize {
+		return put(path, io.NewSectionReader(r, 0, size))
+	}
+	m := NewChunkManifest(size, maxChunkSize)
+	var off int64
+	for i, n := range m.ChunkSizes {
+		if err := put(ChunkPath(path, i), io.NewSectionReader(r, off, n)); err != nil {
+			return err
+		}
+		off += n
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return put(ManifestPath(path), bytes.NewReader(data))
+}
+
+// ReadChunkManifest reads and validates the chunk manifest of the file at
+// path in fs.
+func ReadChunkManifest(fs rwvfs.FileSystem, path string) (*ChunkManifest, error) {
+	f, err := fs.Open(ManifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m *ChunkManifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("chunk manifest of %s: %s", path, err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("chunk manifest of %s is null", path)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("chunk manifest of %s: %s", path, err)
+	}
+	return m, nil
+}
+
+// openChunked opens the file at path, reassembling it from its chunks if it
+// is chunked.
+func (s *RepositoryStore) openChunked(path string) (vfs.ReadSeekCloser, error) {
+	fs := s.files()
+	f, err := fs.Open(path)
+	if !os.IsNotExist(err) {
+		return f, err
+	}
+	m, err2 := ReadChunkManifest(fs, path)
+	if os.IsNotExist(err2) {
+		return nil, err
+	} else if err2 != nil {
+		return nil, err2
+	}
+	return &chunkedFile{fs: fs, path: path, m: m}, nil
+}
+
+// statChunked returns the FileInfo of the file at path. If the file is
+// chunked, its size is that of the whole file, and its modification time is
+// that of its manifest.
+func (s *RepositoryStore) statChunked(path string) (os.FileInfo, error) {
+	fs := s.files()
+	fi, err := fs.Stat(path)
+	if !os.IsNotExist(err) {
+		return fi, err
+	}
+	mfi, err2 := fs.Stat(ManifestPath(path))
+	if err2 != nil {
+		return nil, err
+	}
+	m, err := ReadChunkManifest(fs, path)
+	if err != nil {
+		return nil, err
+	}
+	return renamedFileInfo{mfi, filepath.Base(path), m.Size}, nil
+}
+
+// A renamedFileInfo is the FileInfo of a file that is stored under another
+// name (and size).
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+	size int64
+}
+
+func (fi renamedFileInfo) Name() string { return fi.name }
+func (fi renamedFileInfo) Size() int64  { return fi.size }
+
+// A chunkedFile reads a chunked file, opening its chunks as they are
+// reached.
+type chunkedFile struct {
+	fs   rwvfs.FileSystem
+	path string
+	m    *ChunkManifest
+	off  int64
+
+	chunk    vfs.ReadSeekCloser // the open chunk, or nil
+	chunkEnd int64              // offset of the end of the open chunk
+}
+
+func (f *chunkedFile) Read(p []byte) (int, error) {
+	if f.off >= f.m.Size {
+		return 0, io.EOF
+	}
+	if f.chunk == nil {
+		var start int64
+		for i, n := range f.m.ChunkSizes {
+			if f.off < start+n {
+				c, err := f.fs.Open(ChunkPath(f.path, i))
+				if err != nil {
+					return 0, err
+				}
+				if _, err := c.Seek(f.off-start, 0); err != nil {
+					c.Close()
+					return 0, err
+				}
+				f.chunk, f.chunkEnd = c, start+n
+				break
+			}
+			start += n
+		}
+	}
+
+	if max := f.chunkEnd - f.off; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := f.chunk.Read(p)
+	f.off += int64(n)
+	if f.off == f.chunkEnd {
+		err = f.closeChunk()
+	} else if err == io.EOF {
+		err = fmt.Errorf("chunk of %s is shorter than its manifest says", f.path)
+	}
+	return n, err
+}
+
+func (f *chunkedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 1:
+		offset += f.off
+	case 2:
+		offset += f.m.Size
+	}
+	if offset < 0 {
+		return f.off, fmt.Errorf("seek to negative offset %d in %s", offset, f.path)
+	}
+	if offset != f.off {
+		if err := f.closeChunk(); err != nil {
+			return f.off, err
+		}
+		f.off = offset
+	}
+	return f.off, nil
+}
+
+func (f *chunkedFile) closeChunk() error {
+	if f.chunk == nil {
+		return nil
+	}
+	err := f.chunk.Close()
+	f.chunk = nil
+	return err
+}
+
+func (f *chunkedFile) Close() error { return f.closeChunk() }
@@ -0,0 +1,116 @@
+package buildstore
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+func TestPutChunked(t *testing.T) {
+	const data = "0123456789abcdefghijklmnopqrstuvwxy"
+	tests := []struct {
+		size         int
+		maxChunkSize int64
+		wantPaths    []string
+	}{
+		{size: 0, maxChunkSize: 10, wantPaths: []string{"c/f"}},
+		{size: 10, maxChunkSize: 10, wantPaths: []string{"c/f"}},
+		{size: 35, maxChunkSize: 0, wantPaths: []string{"c/f"}},
+		{size: 11, maxChunkSize: 10, wantPaths: []string{"c/f.chunk0", "c/f.chunk1", "c/f.chunks"}},
+		{size: 35, maxChunkSize: 10, wantPaths: []string{"c/f.chunk0", "c/f.chunk1", "c/f.chunk2", "c/f.chunk3", "c/f.chunks"}},
+	}
+	for _, test := range tests {
+		files := make(map[string]string)
+		var paths []string
+		err := PutChunked("c/f", strings.NewReader(data), int64(test.size), test.maxChunkSize, func(path string, r io.ReadSeeker) error {
+			b, err := ioutil.ReadAll(r)
+			files[path] = string(b)
+			paths = append(paths, path)
+			return err
+		})
+		if err != nil {
+			t.Errorf("%d/%d: %s", test.size, test.maxChunkSize, err)
+			continue
+		}
+		if !reflect.DeepEqual(paths, test.wantPaths) {
+			t.Errorf("%d/%d: got paths %v, want %v", test.size, test.maxChunkSize, paths, test.wantPaths)
+		}
+
+		// Readers of the store see the whole file.
+		s := newRepositoryStore(walkableRWVFS{rwvfs.Map(files)})
+		f, err := s.Open("c/f")
+		if err != nil {
+			t.Errorf("%d/%d: %s", test.size, test.maxChunkSize, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%d/%d: %s", test.size, test.maxChunkSize, err)
+		} else if want := data[:test.size]; string(got) != want {
+			t.Errorf("%d/%d: got contents %q, want %q", test.size, test.maxChunkSize, got, want)
+		}
+		if fi, err := s.Stat("c/f"); err != nil || fi.Size() != int64(test.size) || fi.Name() != "f" {
+			t.Errorf("%d/%d: got Stat %v (error %v), want file f of size %d", test.size, test.maxChunkSize, fi, err, test.size)
+		}
+		dataFiles, err := s.AllDataFiles()
+		if err != nil {
+			t.Errorf("%d/%d: %s", test.size, test.maxChunkSize, err)
+		} else if len(dataFiles) != 1 || dataFiles[0].Path != "f" || dataFiles[0].Size != int64(test.size) {
+			t.Errorf("%d/%d: got data files %+v, want only f", test.size, test.maxChunkSize, dataFiles)
+		}
+	}
+}
+
+func TestChunkedFile(t *testing.T) {
+	files := map[string]string{
+		"f.chunk0": "0123",
+		"f.chunk1": "4567",
+		"f.chunk2": "89",
+		"f.chunks": `{"Size":10,"ChunkSizes":[4,4,2]}`,
+		"g.chunk0": "01",
+		"g.chunks": `{"Size":4,"ChunkSizes":[4]}`,
+		"h.chunks": `{"Size":4,"ChunkSizes":[2,3]}`,
+	}
+	s := newRepositoryStore(walkableRWVFS{rwvfs.Map(files)})
+
+	f, err := s.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, test := range []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{offset: 3, want: "3456789"},
+		{offset: -3, whence: 2, want: "789"},
+		{offset: 0, want: "0123456789"},
+	} {
+		if _, err := f.Seek(test.offset, test.whence); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ioutil.ReadAll(f); err != nil || string(got) != test.want {
+			t.Errorf("seek %d (whence %d): got %q (error %v), want %q", test.offset, test.whence, got, err, test.want)
+		}
+	}
+
+	// Chunks that are shorter than the manifest says are errors.
+	g, err := s.Open("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(g); err == nil || !strings.Contains(err.Error(), "shorter") {
+		t.Errorf("got error %v, want short chunk error", err)
+	}
+
+	// So are invalid manifests.
+	if _, err := s.Open("h"); err == nil || !strings.Contains(err.Error(), "total size") {
+		t.Errorf("got error %v, want invalid manifest error", err)
+	}
+}
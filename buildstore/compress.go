@@ -0,0 +1,238 @@
+package buildstore
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+//go:generate go run gen_zstd_dict.go
+
+// Build data files may be stored compressed with zstd, which shrinks graph
+// data (highly repetitive JSON) more than gzip does. The compressed form of
+// the file at path is stored at CompressedPath(path), and may itself be
+// chunked (see PutChunked). RepositoryStore's Open and Stat methods
+// decompress such files transparently.
+
+// CompressedPath returns the path of the compressed form of the file at path.
+func CompressedPath(path string) string { return path + ".zst" }
+
+// StoredPaths returns the paths at which the build data file at path may be
+// stored: as is, chunked, compressed, or compressed and chunked, each of
+// which may be a reference to a blob (see BlobRefPath), or as a delta (see
+// DeltaPath).
+func StoredPaths(path string) []string {
+	paths := []string{path, ManifestPath(path), CompressedPath(path), ManifestPath(CompressedPath(path))}
+	for _, p := range paths[:4] {
+		paths = append(paths, BlobRefPath(p))
+	}
+	return append(paths, DeltaPath(path))
+}
+
+// DataFilePath returns the path of the build data file that is stored at
+// stored (which is one of StoredPaths(path)). If stored is the path of a
+// chunk (or a reference to a chunk), it returns false.
+func DataFilePath(stored string) (path string, ok bool) {
+	stored = strings.TrimSuffix(strings.TrimSuffix(stored, ".blob"), ".delta")
+	if isChunkPath(stored) {
+		if !strings.HasSuffix(stored, ".chunks") {
+			return "", false
+		}
+		stored = strings.TrimSuffix(stored, ".chunks")
+	}
+	return strings.TrimSuffix(stored, ".zst"), true
+}
+
+// Compress writes the zstd-compressed contents of r, which are size bytes
+// long, to w. The size is recorded in the compressed data, so that Stat
+// needn't decompress it.
+//
+// If useDict is true, the data is compressed with a dictionary that was
+// trained on graph outputs and is shipped with srclib (see
+// gen_zstd_dict.go). The dictionary shrinks small graph outputs (of a few KB)
+// by a further 15-25%, but makes little difference to large files.
+// Decompression doesn't require any options, since the compressed data
+// records which dictionary (if any) it needs.
+func Compress(w io.Writer, r io.Reader, size int64, useDict bool) error {
+	opts := []zstd.EOption{zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithZeroFrames(true)}
+	if useDict {
+		opts = append(opts, zstd.WithEncoderDict(graphDict))
+	}
+
+	// The streaming encoder only records sizes of 256 bytes or more, so
+	// smaller files are compressed in one go, as a single segment (whose
+	// size is always recorded).
+	if size < 256 {
+		data, err := ioutil.ReadAll(io.LimitReader(r, size+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != size {
+			return fmt.Errorf("compressing %d bytes of data, but the size given is %d", len(data), size)
+		}
+		zw, err := zstd.NewWriter(nil, append(opts, zstd.WithSingleSegment(true))...)
+		if err != nil {
+			return err
+		}
+		defer zw.Close()
+		_, err = w.Write(zw.EncodeAll(data, nil))
+		return err
+	}
+
+	zw, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return err
+	}
+	zw.ResetContentSize(w, size)
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// Open opens the build data file at path, reassembling it from its chunks
+// if it is chunked, decompressing it if it is compressed, and applying its
+// delta if it is stored as a delta (see DeltaPath).
+func (s *RepositoryStore) Open(path string) (vfs.ReadSeekCloser, error) {
+	f, err := s.openChunked(path)
+	if !os.IsNotExist(err) {
+		return f, err
+	}
+	zf, err2 := s.openChunked(CompressedPath(path))
+	if os.IsNotExist(err2) {
+		df, err3 := s.openDelta(path)
+		if os.IsNotExist(err3) {
+			return nil, err
+		}
+		return df, err3
+	} else if err2 != nil {
+		return nil, err2
+	}
+	size, err := decompressedSize(zf, path)
+	if err != nil {
+		zf.Close()
+		return nil, err
+	}
+	d, err := zstd.NewReader(zf, zstd.WithDecoderConcurrency(1), zstd.WithDecoderDicts(graphDict))
+	if err != nil {
+		zf.Close()
+		return nil, err
+	}
+	return &compressedFile{f: zf, d: d, path: path, size: size}, nil
+}
+
+// Stat returns the FileInfo of the build data file at path. If the file is
+// chunked, compressed, or stored as a delta, its size is that of the whole,
+// decompressed file.
+func (s *RepositoryStore) Stat(path string) (os.FileInfo, error) {
+	fi, err := s.statChunked(path)
+	if !os.IsNotExist(err) {
+		return fi, err
+	}
+	zfi, err2 := s.statChunked(CompressedPath(path))
+	if os.IsNotExist(err2) {
+		d, dfi, err3 := s.readDelta(path)
+		if os.IsNotExist(err3) {
+			return nil, err
+		} else if err3 != nil {
+			return nil, err3
+		}
+		return renamedFileInfo{dfi, filepath.Base(path), d.Size}, nil
+	} else if err2 != nil {
+		return nil, err2
+	}
+	zf, err := s.openChunked(CompressedPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer zf.Close()
+	size, err := decompressedSize(zf, path)
+	if err != nil {
+		return nil, err
+	}
+	return renamedFileInfo{zfi, filepath.Base(path), size}, nil
+}
+
+// decompressedSize reads the size of the compressed build data file at path
+// from the header of its compressed data, which f reads. It leaves f at
+// offset 0.
+func decompressedSize(f io.ReadSeeker, path string) (int64, error) {
+	b := make([]byte, zstd.HeaderMaxSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	var h zstd.Header
+	if err := h.Decode(b[:n]); err != nil {
+		return 0, fmt.Errorf("compressed build data file %s: %s", path, err)
+	}
+	if !h.HasFCS {
+		return 0, fmt.Errorf("compressed build data file %s doesn't record its size", path)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, err
+	}
+	return int64(h.FrameContentSize), nil
+}
+
+// A compressedFile reads a compressed file. Seeking backwards starts
+// decompression over from the beginning, and seeking forwards decompresses
+// and discards the data in between, so it is only cheap to read sequentially.
+type compressedFile struct {
+	f    vfs.ReadSeekCloser // the compressed file
+	d    *zstd.Decoder
+	path string
+	size int64 // the size of the decompressed file
+
+	off int64 // offset of the next Read
+	pos int64 // offset of the data that d will decompress next
+}
+
+func (f *compressedFile) Read(p []byte) (int, error) {
+	if f.pos > f.off {
+		if _, err := f.f.Seek(0, 0); err != nil {
+			return 0, err
+		}
+		if err := f.d.Reset(f.f); err != nil {
+			return 0, err
+		}
+		f.pos = 0
+	}
+	if f.pos < f.off {
+		n, err := io.CopyN(ioutil.Discard, f.d, f.off-f.pos)
+		f.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.d.Read(p)
+	f.off += int64(n)
+	f.pos = f.off
+	return n, err
+}
+
+func (f *compressedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 1:
+		offset += f.off
+	case 2:
+		offset += f.size
+	}
+	if offset < 0 {
+		return f.off, fmt.Errorf("seek to negative offset %d in %s", offset, f.path)
+	}
+	f.off = offset
+	return f.off, nil
+}
+
+func (f *compressedFile) Close() error {
+	f.d.Close()
+	return f.f.Close()
+}
@@ -0,0 +1,134 @@
+package buildstore
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+func TestCompress(t *testing.T) {
+	data := strings.Repeat(`{"Repo":"github.com/a/b","UnitType":"GoPackage","Unit":"github.com/a/b/c","Path":"F"},`, 1000)
+	tests := []struct {
+		data         string
+		useDict      bool
+		maxChunkSize int64
+	}{
+		{data: ""},
+		{data: "a"},
+		{data: data},
+		{data: data, useDict: true},
+		{data: data, maxChunkSize: 100},
+	}
+	for _, test := range tests {
+		var z bytes.Buffer
+		if err := Compress(&z, strings.NewReader(test.data), int64(len(test.data)), test.useDict); err != nil {
+			t.Errorf("%d/%v: %s", len(test.data), test.useDict, err)
+			continue
+		}
+		files := make(map[string]string)
+		err := PutChunked(CompressedPath("c/f"), bytes.NewReader(z.Bytes()), int64(z.Len()), test.maxChunkSize, func(path string, r io.ReadSeeker) error {
+			b, err := ioutil.ReadAll(r)
+			files[path] = string(b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Readers of the store see the whole, decompressed file.
+		s := newRepositoryStore(walkableRWVFS{rwvfs.Map(files)})
+		f, err := s.Open("c/f")
+		if err != nil {
+			t.Errorf("%d/%v: %s", len(test.data), test.useDict, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%d/%v: %s", len(test.data), test.useDict, err)
+		} else if string(got) != test.data {
+			t.Errorf("%d/%v: got %d bytes of decompressed data, want %d", len(test.data), test.useDict, len(got), len(test.data))
+		}
+		if fi, err := s.Stat("c/f"); err != nil || fi.Size() != int64(len(test.data)) || fi.Name() != "f" {
+			t.Errorf("%d/%v: got Stat %v (error %v), want file f of size %d", len(test.data), test.useDict, fi, err, len(test.data))
+		}
+		dataFiles, err := s.AllDataFiles()
+		if err != nil {
+			t.Errorf("%d/%v: %s", len(test.data), test.useDict, err)
+		} else if len(dataFiles) != 1 || dataFiles[0].Path != "f" || dataFiles[0].Size != int64(len(test.data)) {
+			t.Errorf("%d/%v: got data files %+v, want only f", len(test.data), test.useDict, dataFiles)
+		}
+	}
+}
+
+func TestCompress_dict(t *testing.T) {
+	files, err := filepath.Glob("../testdata/repos-output/want/go-sample-0/*_graph.v0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var z, zd bytes.Buffer
+		if err := Compress(&z, bytes.NewReader(data), int64(len(data)), false); err != nil {
+			t.Fatal(err)
+		}
+		if err := Compress(&zd, bytes.NewReader(data), int64(len(data)), true); err != nil {
+			t.Fatal(err)
+		}
+		if zd.Len() >= z.Len() {
+			t.Errorf("%s: compressed to %d bytes with the dictionary, want fewer than the %d without it", file, zd.Len(), z.Len())
+		}
+	}
+}
+
+func TestCompressedFile_seek(t *testing.T) {
+	const data = "0123456789"
+	var z bytes.Buffer
+	if err := Compress(&z, strings.NewReader(data), int64(len(data)), false); err != nil {
+		t.Fatal(err)
+	}
+	s := newRepositoryStore(walkableRWVFS{rwvfs.Map(map[string]string{"f.zst": z.String()})})
+	f, err := s.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, test := range []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{offset: 3, want: "3456789"},
+		{offset: -3, whence: 2, want: "789"},
+		{offset: 0, want: "0123456789"},
+		{offset: 20, want: ""},
+	} {
+		if _, err := f.Seek(test.offset, test.whence); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ioutil.ReadAll(f); err != nil || string(got) != test.want {
+			t.Errorf("seek %d (whence %d): got %q (error %v), want %q", test.offset, test.whence, got, err, test.want)
+		}
+	}
+}
+
+func TestDataFilePath(t *testing.T) {
+	for _, path := range StoredPaths("c/f") {
+		if got, ok := DataFilePath(path); !ok || got != "c/f" {
+			t.Errorf("%s: got %q (ok %v), want c/f", path, got, ok)
+		}
+	}
+	for _, path := range []string{"c/f.chunk0", "c/f.zst.chunk1"} {
+		if got, ok := DataFilePath(path); ok {
+			t.Errorf("%s: got %q, want not ok (a chunk)", path, got)
+		}
+	}
+}
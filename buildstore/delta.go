@@ -0,0 +1,419 @@
+package buildstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// The build data files in a MultiStore may be stored as deltas against
+// other contents (usually those of the same file at a parent commit), since
+// most of a source unit's build data doesn't change from one commit to the
+// next. The delta of the file at path is stored at DeltaPath(path), and the
+// contents it applies to are stored as a blob, so that they can't change
+// under it. RepositoryStore's Open and Stat methods reconstruct such files
+// transparently.
+
+// MaxDeltaFileSize is the maximum size of a build data file that may be
+// stored as a delta. (Computing and checking deltas requires holding the
+// whole file and its base in memory.)
+var MaxDeltaFileSize int64 = 256 << 20
+
+// DeltaPath returns the path of the delta of the file at path.
+func DeltaPath(path string) string { return path + ".delta" }
+
+// A Delta describes the contents of a file in terms of other contents (its
+// base): the ranges of the base that it shares, and the data that it adds.
+type Delta struct {
+	// Base is the hash (see HashBlob) of the contents the delta applies to.
+	// In a store, it names the blob that holds them.
+	Base string
+
+	// BaseCommit is the commit whose build data file (at the same path) has
+	// the contents the delta applies to. It is set in the deltas that
+	// workers upload, which are relative to the file at the parent commit
+	// (see RepositoryStore.PutDelta), but not in stored deltas.
+	BaseCommit string `json:",omitempty"`
+
+	// Hash and Size are the hash and size of the contents described by
+	// the delta.
+	Hash string
+	Size int64
+
+	// Ops are the operations that produce the contents, in order.
+	Ops []DeltaOp
+}
+
+// A DeltaOp is an operation in a Delta: it either adds data, or copies the
+// Len bytes at offset Off in the base.
+type DeltaOp struct {
+	Add string `json:",omitempty"`
+
+	Off int64 `json:",omitempty"`
+	Len int64 `json:",omitempty"`
+}
+
+// deltaWindow is the number of lines that must match for Diff to copy
+// them from the base, so that short lines that appear everywhere in
+// indented JSON (like "},") don't lead to many tiny copies.
+const deltaWindow = 4
+
+// NewDelta returns the delta that produces data from base. Lines are the
+// units of sharing, since build data is indented JSON with one field per
+// line, so the delta of data that adds, removes, or changes a few records
+// adds the lines of those records and copies the rest.
+func NewDelta(base, data []byte) *Delta {
+	baseHash, _ := HashBlob(bytes.NewReader(base))
+	hash, _ := HashBlob(bytes.NewReader(data))
+	d := &Delta{Base: baseHash, Hash: hash, Size: int64(len(data))}
+
+	bl, dl := lineStarts(base), lineStarts(data)
+	line := func(b []byte, starts []int, i, n int) []byte { return b[starts[i]:starts[i+n]] }
+
+	// Index the windows of lines in base by their hash (recording the first
+	// occurrence of each).
+	index := make(map[uint64]int, len(bl))
+	for i := len(bl) - 1 - deltaWindow; i >= 0; i-- {
+		index[hashBytes(line(base, bl, i, deltaWindow))] = i
+	}
+
+	addStart := 0 // start of the data to add before the next copy
+	next := -1    // the line in base after the last copied one
+	for j := 0; j < len(dl)-1; {
+		// Continue copying where the last copy left off if possible (e.g.,
+		// after a changed line); otherwise find the window in base.
+		i := -1
+		if next >= 0 && next < len(bl)-1 && bytes.Equal(line(base, bl, next, 1), line(data, dl, j, 1)) {
+			i = next
+		} else if j+deltaWindow < len(dl) {
+			w := line(data, dl, j, deltaWindow)
+			if c, ok := index[hashBytes(w)]; ok && bytes.Equal(line(base, bl, c, deltaWindow), w) {
+				i = c
+			}
+		}
+		if i == -1 {
+			j++
+			continue
+		}
+
+		n := 1
+		for i+n < len(bl)-1 && j+n < len(dl)-1 && bytes.Equal(line(base, bl, i+n, 1), line(data, dl, j+n, 1)) {
+			n++
+		}
+		if dl[j] > addStart {
+			d.Ops = append(d.Ops, DeltaOp{Add: string(data[addStart:dl[j]])})
+		}
+		off, end := int64(bl[i]), int64(bl[i+n])
+		if k := len(d.Ops) - 1; k >= 0 && d.Ops[k].Add == "" && d.Ops[k].Off+d.Ops[k].Len == off {
+			d.Ops[k].Len += end - off
+		} else {
+			d.Ops = append(d.Ops, DeltaOp{Off: off, Len: end - off})
+		}
+		j += n
+		addStart, next = dl[j], i+n
+	}
+	if addStart < len(data) {
+		d.Ops = append(d.Ops, DeltaOp{Add: string(data[addStart:])})
+	}
+	return d
+}
+
+// lineStarts returns the offsets of the starts of the lines in b, followed by
+// len(b).
+func lineStarts(b []byte) []int {
+	starts := []int{0}
+	for i, c := range b {
+		if c == '\n' && i+1 < len(b) {
+			starts = append(starts, i+1)
+		}
+	}
+	if len(b) > 0 {
+		starts = append(starts, len(b))
+	}
+	return starts
+}
+
+func hashBytes(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// Validate returns an error if d's operations are invalid or don't add up to
+// its size. (It doesn't check that they are in bounds of the base.)
+func (d *Delta) Validate() error {
+	if err := ValidBlobHash(d.Base); err != nil {
+		return fmt.Errorf("base: %s", err)
+	}
+	if err := ValidBlobHash(d.Hash); err != nil {
+		return err
+	}
+	if d.BaseCommit != "" && (strings.Contains(d.BaseCommit, "/") || strings.HasPrefix(d.BaseCommit, ".")) {
+		return fmt.Errorf("invalid base commit %q", d.BaseCommit)
+	}
+	var size int64
+	for i, op := range d.Ops {
+		if op.Add != "" {
+			if op.Off != 0 || op.Len != 0 {
+				return fmt.Errorf("op %d both adds and copies data", i)
+			}
+			size += int64(len(op.Add))
+			continue
+		}
+		if op.Off < 0 || op.Len <= 0 {
+			return fmt.Errorf("op %d copies %d bytes at offset %d", i, op.Len, op.Off)
+		}
+		size += op.Len
+	}
+	if size != d.Size {
+		return fmt.Errorf("ops produce %d bytes, but the size is %d", size, d.Size)
+	}
+	return nil
+}
+
+// Apply returns the contents that d produces from base, and checks that
+// they have the hash and size that d says.
+func (d *Delta) Apply(base []byte) ([]byte, error) {
+	data := make([]byte, 0, d.Size)
+	for i, op := range d.Ops {
+		if op.Add != "" {
+			data = append(data, op.Add...)
+			continue
+		}
+		if op.Off < 0 || op.Len < 0 || op.Off > int64(len(base))-op.Len {
+			return nil, fmt.Errorf("delta op %d copies past the end of the base", i)
+		}
+		data = append(data, base[op.Off:op.Off+op.Len]...)
+	}
+	if hash, _ := HashBlob(bytes.NewReader(data)); int64(len(data)) != d.Size || hash != d.Hash {
+		return nil, fmt.Errorf("delta produces contents with hash %s and size %d, but should produce hash %s and size %d", hash, len(data), d.Hash, d.Size)
+	}
+	return data, nil
+}
+
+// readDelta reads the delta of the file at path in s. If there is none, the
+// error satisfies os.IsNotExist.
+func (s *RepositoryStore) readDelta(path string) (*Delta, os.FileInfo, error) {
+	f, err := s.files().Open(DeltaPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := s.files().Stat(DeltaPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	var d *Delta
+	if err := json.NewDecoder(f).Decode(&d); err != nil {
+		return nil, nil, fmt.Errorf("delta of %s: %s", path, err)
+	}
+	if d == nil {
+		return nil, nil, fmt.Errorf("delta of %s is null", path)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("delta of %s: %s", path, err)
+	}
+	return d, fi, nil
+}
+
+// openDelta opens the file at path in s, which is stored as a delta.
+func (s *RepositoryStore) openDelta(path string) (vfs.ReadSeekCloser, error) {
+	d, _, err := s.readDelta(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.blobs == nil {
+		return nil, fmt.Errorf("can't read %s: it is stored as a delta, but the store has no blob store", path)
+	}
+	base, err := s.blobs.Open(d.Base)
+	if err != nil {
+		return nil, fmt.Errorf("base of delta of %s: %s", path, err)
+	}
+	f := &deltaFile{base: base, path: path, d: d, starts: make([]int64, len(d.Ops)+1)}
+	for i, op := range d.Ops {
+		f.starts[i+1] = f.starts[i] + int64(len(op.Add)) + op.Len
+	}
+	return f, nil
+}
+
+// PutDelta stores the build data file at path in the build data directory
+// of commitID as delta d, and returns the path (one of StoredPaths) at which
+// it stored the file.
+//
+// If d.BaseCommit is set, d may be relative to the contents of the file at
+// the same path in that commit (which must have hash d.Base). Since stored
+// deltas must be relative to contents that are stored as blobs, PutDelta
+// stores those contents as a blob, unless the file at d.BaseCommit is
+// itself stored as a delta; then it stores the delta of the file against
+// that delta's base instead, so that reading a file never requires applying
+// more than one delta. If that delta is larger than half of the file, it
+// stores the whole file (as a blob) instead, which becomes the base for the
+// deltas of later commits.
+func (s *RepositoryStore) PutDelta(commitID, path string, d *Delta) (string, error) {
+	if s.blobs == nil {
+		return "", fmt.Errorf("can't store %s as a delta: the store has no blob store", path)
+	}
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	if d.Size > MaxDeltaFileSize {
+		return "", fmt.Errorf("can't store %s as a delta: its size (%d bytes) is larger than the maximum (%d bytes)", path, d.Size, MaxDeltaFileSize)
+	}
+	filePath := s.FilePath(commitID, path)
+
+	if has, err := s.blobs.Has(d.Base); err != nil {
+		return "", err
+	} else if has {
+		base, err := s.readAll(d.Base, s.blobs.Open)
+		if err != nil {
+			return "", err
+		}
+		if _, err := d.Apply(base); err != nil {
+			return "", err
+		}
+		d.BaseCommit = ""
+		return DeltaPath(filePath), s.writeDelta(filePath, d)
+	}
+
+	if d.BaseCommit == "" {
+		return "", fmt.Errorf("can't store %s as a delta: its base blob %s doesn't exist", path, d.Base)
+	}
+	basePath := s.FilePath(d.BaseCommit, path)
+	base, err := s.readAll(basePath, s.Open)
+	if err != nil {
+		return "", fmt.Errorf("base of delta of %s: %s", path, err)
+	}
+	if hash, _ := HashBlob(bytes.NewReader(base)); hash != d.Base {
+		return "", fmt.Errorf("base of delta of %s has hash %s, not %s", path, hash, d.Base)
+	}
+	data, err := d.Apply(base)
+	if err != nil {
+		return "", err
+	}
+
+	parent, _, err := s.readDelta(basePath)
+	if os.IsNotExist(err) {
+		if err := s.blobs.Put(d.Base, bytes.NewReader(base), int64(len(base))); err != nil {
+			return "", err
+		}
+		d.BaseCommit = ""
+		return DeltaPath(filePath), s.writeDelta(filePath, d)
+	} else if err != nil {
+		return "", err
+	}
+
+	root, err := s.readAll(parent.Base, s.blobs.Open)
+	if err != nil {
+		return "", err
+	}
+	d = NewDelta(root, data)
+	if b, err := json.Marshal(d); err != nil {
+		return "", err
+	} else if len(b) <= len(data)/2 {
+		return DeltaPath(filePath), s.writeDelta(filePath, d)
+	}
+	if err := s.blobs.Put(d.Hash, bytes.NewReader(data), int64(len(data))); err != nil {
+		return "", err
+	}
+	return BlobRefPath(filePath), s.LinkBlob(filePath, d.Hash)
+}
+
+// readAll reads the file (or blob) named by name, which it opens with open,
+// into memory. It returns an error if the file is larger than
+// MaxDeltaFileSize.
+func (s *RepositoryStore) readAll(name string, open func(string) (vfs.ReadSeekCloser, error)) ([]byte, error) {
+	f, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, MaxDeltaFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxDeltaFileSize {
+		return nil, fmt.Errorf("%s is larger than the maximum size of files stored as deltas (%d bytes)", name, MaxDeltaFileSize)
+	}
+	return data, nil
+}
+
+func (s *RepositoryStore) writeDelta(path string, d *Delta) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := s.Create(DeltaPath(path))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// A deltaFile reads a file that is stored as a delta, applying the delta's
+// operations to its base as they are reached.
+type deltaFile struct {
+	base   vfs.ReadSeekCloser
+	path   string
+	d      *Delta
+	starts []int64 // offsets of the data produced by each of d.Ops, and d.Size
+	off    int64
+}
+
+func (f *deltaFile) Read(p []byte) (int, error) {
+	if f.off >= f.d.Size {
+		return 0, io.EOF
+	}
+	i := sort.Search(len(f.d.Ops), func(i int) bool { return f.starts[i+1] > f.off })
+	op, rel := f.d.Ops[i], f.off-f.starts[i]
+	if max := f.starts[i+1] - f.off; int64(len(p)) > max {
+		p = p[:max]
+	}
+
+	var n int
+	if op.Add != "" {
+		n = copy(p, op.Add[rel:])
+	} else {
+		if _, err := f.base.Seek(op.Off+rel, 0); err != nil {
+			return 0, err
+		}
+		var err error
+		n, err = f.base.Read(p)
+		if err == io.EOF {
+			if n == 0 {
+				return 0, fmt.Errorf("base of delta of %s is shorter than the delta says", f.path)
+			}
+		} else if err != nil {
+			return n, err
+		}
+	}
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *deltaFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 1:
+		offset += f.off
+	case 2:
+		offset += f.d.Size
+	}
+	if offset < 0 {
+		return f.off, fmt.Errorf("seek to negative offset %d in %s", offset, f.path)
+	}
+	f.off = offset
+	return f.off, nil
+}
+
+func (f *deltaFile) Close() error { return f.base.Close() }
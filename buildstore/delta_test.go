@@ -0,0 +1,244 @@
+package buildstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+const deltaTestFile = "../testdata/repos-output/want/javascript-nodejs-xrefs-0/javascript-nodejs-xrefs-0@CommonJSPackage_graph.v0.json"
+
+func TestNewDelta(t *testing.T) {
+	base, err := ioutil.ReadFile(deltaTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(base), "\n")
+	edit := func(f func(lines []string) []string) []byte {
+		return []byte(strings.Join(f(append([]string(nil), lines...)), ""))
+	}
+	record := `    {
+      "Path": "commonjs/lib/new.js",
+      "Kind": "module"
+    },
+`
+
+	tests := []struct {
+		name       string
+		base, data []byte
+		small      bool // whether the delta should be much smaller than data
+	}{
+		{name: "empty", base: nil, data: nil},
+		{name: "empty base", base: nil, data: base},
+		{name: "empty data", base: base, data: nil},
+		{name: "same", base: base, data: base, small: true},
+		{name: "added", base: base, data: edit(func(l []string) []string {
+			return append(l[:2], append([]string{record}, l[2:]...)...)
+		}), small: true},
+		{name: "removed", base: base, data: edit(func(l []string) []string {
+			return append(l[:100], l[200:]...)
+		}), small: true},
+		{name: "changed", base: base, data: edit(func(l []string) []string {
+			for i := 10; i < len(l); i += 1000 {
+				l[i] = strings.Replace(l[i], `"`, `"x`, 1)
+			}
+			return l
+		}), small: true},
+		{name: "moved", base: base, data: edit(func(l []string) []string {
+			return append(l[len(l)/2:], l[:len(l)/2]...)
+		}), small: true},
+		{name: "no final newline", base: []byte("a\nb\nc\nd\ne"), data: []byte("a\nb\nc\nd\ne\nf")},
+		{name: "unrelated", base: []byte("a\nb\nc\nd\ne\n"), data: []byte("v\nw\nx\ny\nz\n")},
+	}
+	for _, test := range tests {
+		d := NewDelta(test.base, test.data)
+		if err := d.Validate(); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		got, err := d.Apply(test.base)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.data) {
+			t.Errorf("%s: got %d bytes of data from the delta, want %d", test.name, len(got), len(test.data))
+		}
+		if test.small {
+			b, err := json.Marshal(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b) > len(test.data)/20 {
+				t.Errorf("%s: got %d-byte delta for %d bytes of data, want at most 5%% of the data", test.name, len(b), len(test.data))
+			}
+		}
+	}
+}
+
+func TestDelta_Apply_wrongBase(t *testing.T) {
+	d := NewDelta([]byte("a\nb\nc\nd\ne\n"), []byte("a\nb\nc\nd\ne\nf\n"))
+	if _, err := d.Apply([]byte("a\nb\nc\nd\nx\n")); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("got error %v applying a delta to the wrong base, want hash error", err)
+	}
+	if _, err := d.Apply([]byte("a\n")); err == nil {
+		t.Error("got no error applying a delta to a base that is too short")
+	}
+
+	// Off+Len overflows int64, which must not wrap around to pass the bounds
+	// check.
+	d = &Delta{Base: d.Base, Hash: d.Hash, Size: 2, Ops: []DeltaOp{{Off: math.MaxInt64, Len: 2}}}
+	if _, err := d.Apply([]byte("a\n")); err == nil || !strings.Contains(err.Error(), "past the end") {
+		t.Errorf("got error %v applying a delta with a huge offset, want out of bounds error", err)
+	}
+}
+
+func TestPutDelta(t *testing.T) {
+	files := make(map[string]string)
+	s, err := New(rwvfs.Map(files)).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := ioutil.ReadFile(deltaTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := append(v1[:len(v1):len(v1)], "\n"+strings.Repeat("x\n", 100)...)
+	v3 := append(v2[:len(v2):len(v2)], "y\n"...)
+	v4 := bytes.Repeat([]byte("z\n"), 1000)
+
+	// The parent is stored whole (uploaded by a worker that didn't use
+	// deltas).
+	f, err := s.Create("c1/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(v1)
+	f.Close()
+
+	put := func(commitID, baseCommit string, base, data []byte) string {
+		d := NewDelta(base, data)
+		d.BaseCommit = baseCommit
+		stored, err := s.PutDelta(commitID, "f", d)
+		if err != nil {
+			t.Fatalf("%s: %s", commitID, err)
+		}
+		return stored
+	}
+	if stored := put("c2", "c1", v1, v2); stored != DeltaPath("c2/f") {
+		t.Errorf("c2: got stored path %s, want a delta", stored)
+	}
+	// c3's delta is against c2, which is itself a delta, so it is rebased
+	// onto c1.
+	if stored := put("c3", "c2", v2, v3); stored != DeltaPath("c3/f") {
+		t.Errorf("c3: got stored path %s, want a delta", stored)
+	}
+	if d, _, err := s.readDelta("c3/f"); err != nil {
+		t.Fatal(err)
+	} else if want := NewDelta(v1, nil).Base; d.Base != want || d.BaseCommit != "" {
+		t.Errorf("c3: got delta against %s (commit %q), want against c1's file %s", d.Base, d.BaseCommit, want)
+	}
+	// c4 has little in common with c1, so it is stored whole.
+	if stored := put("c4", "c3", v3, v4); stored != BlobRefPath("c4/f") {
+		t.Errorf("c4: got stored path %s, want a blob reference", stored)
+	}
+	// Deltas against stored blobs needn't name the base commit.
+	if stored := put("c5", "", v4, v3); stored != DeltaPath("c5/f") {
+		t.Errorf("c5: got stored path %s, want a delta", stored)
+	}
+
+	for _, test := range []struct {
+		commitID string
+		want     []byte
+	}{{"c2", v2}, {"c3", v3}, {"c4", v4}, {"c5", v3}} {
+		path := s.FilePath(test.commitID, "f")
+		f, err := s.Open(path)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got %d bytes (error %v), want %d", path, len(got), err, len(test.want))
+		}
+		if fi, err := s.Stat(path); err != nil || fi.Size() != int64(len(test.want)) || fi.Name() != "f" {
+			t.Errorf("%s: got Stat %v (error %v), want file f of size %d", path, fi, err, len(test.want))
+		}
+	}
+
+	stored, err := s.StoredFiles("c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := HashBlob(bytes.NewReader(v3))
+	if want := []*StoredFileInfo{{Path: "f", Hash: hash, Size: int64(len(v3))}}; !reflect.DeepEqual(stored, want) {
+		t.Errorf("got stored files %+v, want %+v", stored, want)
+	}
+	dataFiles, err := s.DataFilesForCommit("c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataFiles) != 1 || dataFiles[0].Path != "f" || dataFiles[0].Size != int64(len(v2)) {
+		t.Errorf("got data files %+v, want only f", dataFiles)
+	}
+
+	// Deltas whose base doesn't match are rejected.
+	d := NewDelta(v2, v3)
+	d.BaseCommit = "c1"
+	if _, err := s.PutDelta("c6", "f", d); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("got error %v putting a delta against the wrong base, want hash error", err)
+	}
+	d.BaseCommit = ""
+	if _, err := s.PutDelta("c6", "f", d); err == nil {
+		t.Error("got no error putting a delta against a missing blob without a base commit")
+	}
+}
+
+func TestDeltaFile_seek(t *testing.T) {
+	ms := New(rwvfs.Map(make(map[string]string)))
+	s, err := ms.RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := ms.Blobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := []byte("a\nb\nc\nd\ne\nf\n")
+	d := NewDelta(base, []byte("a\nb\nc\nd\nX\nf\n"))
+	if err := blobs.Put(d.Base, bytes.NewReader(base), int64(len(base))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutDelta("c", "f", d); err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Open("c/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, test := range []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{offset: 7, want: "\nX\nf\n"},
+		{offset: -4, whence: 2, want: "X\nf\n"},
+		{offset: 0, want: "a\nb\nc\nd\nX\nf\n"},
+		{offset: 20, want: ""},
+	} {
+		if _, err := f.Seek(test.offset, test.whence); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ioutil.ReadAll(f); err != nil || string(got) != test.want {
+			t.Errorf("seek %d (whence %d): got %q (error %v), want %q", test.offset, test.whence, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package buildstore
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kr/fs"
+)
+
+// GCOptions control garbage collection of build data, which removes the
+// stored files that no build refers to: the files that builds that were
+// interrupted left behind, chunks of uploads that were never completed,
+// and blobs that no file refers to.
+//
+// Garbage collection is safe to run concurrently with builds. It leaves
+// alone the build data of commits whose builds hold a lease (see
+// BuildLease), and files that were modified less than MinAge ago (such as
+// the pieces of a chunked file or a blob that a worker is still uploading).
+type GCOptions struct {
+	// MinAge is how long ago a file must have been last modified for it to
+	// be removed.
+	MinAge time.Duration
+
+	// ManifestFiles returns the paths (relative to the build data directory
+	// of commitID) of the build data files that the build manifest of
+	// commitID lists, including the manifest itself, or nil if the commit
+	// has no build manifest (see buildmanifest.ListedFiles). If it is nil,
+	// only partial uploads and interrupted builds are collected.
+	ManifestFiles func(s *RepositoryStore, commitID string) ([]string, error)
+
+	// Retention, if non-nil, is the policy that determines which commits'
+	// builds are kept. The build data of the others is removed, except for
+	// that of builds that hold a lease.
+	Retention *RetentionPolicy
+
+	// DryRun is whether to only list the files that would be removed.
+	DryRun bool
+}
+
+// A GCResult lists the files that garbage collection removed (or, with
+// DryRun, would have removed).
+type GCResult struct {
+	// Removed are the paths of the removed files, relative to the root of
+	// the store that was collected.
+	Removed []string
+
+	// Size is the total size of the removed files.
+	Size int64
+}
+
+// gcFile removes the file at path in fs (whose FileInfo is fi), unless it
+// was modified too recently, and records it in res.
+func gcFile(fs interface {
+	Remove(string) error
+}, path string, fi os.FileInfo, opt GCOptions, now time.Time, res *GCResult) error {
+	if now.Sub(fi.ModTime()) < opt.MinAge {
+		return nil
+	}
+	if !opt.DryRun {
+		if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	res.Removed = append(res.Removed, filepath.ToSlash(path))
+	res.Size += fi.Size()
+	return nil
+}
+
+// GC removes the files in s that no build refers to (see GCOptions): for
+// each commit whose build doesn't hold a lease, the pieces of chunked files
+// whose chunk manifest is missing (which were only partially uploaded),
+// and then
+//
+//   - if the commit has a build manifest, the build data files that it
+//     doesn't list (except for hidden files, such as the build state); or
+//   - if it has no build manifest, but has an expired lease (so its build
+//     was interrupted), all of its files.
+//
+// The build data of commits that have neither a build manifest nor a lease
+// (such as those built by older versions of srclib, or uploaded by dist
+// workers) is kept. But all of the files of the commits whose builds
+// opt.Retention (if any) doesn't retain are removed.
+func (s *RepositoryStore) GC(opt GCOptions) (*GCResult, error) {
+	res := &GCResult{Removed: []string{}}
+	now := time.Now()
+	commits, err := s.ListCommits()
+	if err != nil {
+		return nil, err
+	}
+	var expired map[string]bool
+	if opt.Retention != nil {
+		if expired, err = s.expiredCommits(opt.Retention, now); err != nil {
+			return nil, err
+		}
+	}
+	for _, commitID := range commits {
+		lease, err := s.ReadBuildLease(commitID)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil && lease.Live(now) {
+			continue
+		}
+
+		var listed map[string]bool
+		if opt.ManifestFiles != nil {
+			files, err := opt.ManifestFiles(s, commitID)
+			if err != nil {
+				return nil, err
+			}
+			if files != nil {
+				listed = make(map[string]bool, len(files))
+				for _, f := range files {
+					listed[filepath.ToSlash(f)] = true
+				}
+			}
+		}
+		removeAll := expired[commitID] || (lease != nil && listed == nil) // expired or interrupted
+
+		dir := s.CommitPath(commitID)
+		walker := fs.WalkFS(dir, s.walkableRWVFS)
+		var kept int
+		var dirs []string
+		for walker.Step() {
+			if err := walker.Err(); err != nil {
+				return nil, err
+			}
+			fi := walker.Stat()
+			if fi == nil {
+				continue
+			}
+			if fi.IsDir() {
+				dirs = append(dirs, walker.Path())
+				continue
+			}
+			rel, err := filepath.Rel(dir, walker.Path())
+			if err != nil {
+				return nil, err
+			}
+			rel = filepath.ToSlash(rel)
+
+			var garbage bool
+			switch {
+			case removeAll || rel == BuildLeaseFilename:
+				garbage = true
+			default:
+				dataPath, complete, err := s.storedFileOwner(commitID, rel)
+				if err != nil {
+					return nil, err
+				}
+				garbage = !complete || (listed != nil && !listed[dataPath] && !strings.HasPrefix(path.Base(dataPath), "."))
+			}
+			if !garbage {
+				kept++
+				continue
+			}
+			n := len(res.Removed)
+			if err := gcFile(s, walker.Path(), fi, opt, now, res); err != nil {
+				return nil, err
+			}
+			if len(res.Removed) == n {
+				kept++ // too new to remove
+			}
+		}
+		if kept == 0 && !opt.DryRun {
+			// Remove the commit's directory (subdirectories first), so that
+			// it isn't listed as built. This is best-effort, since
+			// filesystems without directories needn't support it.
+			for i := len(dirs) - 1; i >= 0; i-- {
+				s.Remove(dirs[i])
+			}
+		}
+	}
+	return res, nil
+}
+
+// storedFileOwner returns the path of the build data file that the file
+// stored at path (relative to the build data directory of commitID) holds
+// (see StoredPaths) or is a chunk of, and whether the data file is complete
+// (false if path is a chunk of a file whose chunk manifest is missing).
+func (s *RepositoryStore) storedFileOwner(commitID, path string) (string, bool, error) {
+	stored := strings.TrimSuffix(path, ".blob")
+	chunked, isChunk := ChunkedPath(stored)
+	if !isChunk {
+		dataPath, _ := DataFilePath(stored)
+		return dataPath, true, nil
+	}
+	dataPath, _ := DataFilePath(ManifestPath(chunked))
+	manifest := s.FilePath(commitID, ManifestPath(chunked))
+	for _, p := range []string{manifest, BlobRefPath(manifest)} {
+		if _, err := s.walkableRWVFS.Stat(p); err == nil {
+			return dataPath, true, nil
+		} else if !os.IsNotExist(err) {
+			return "", false, err
+		}
+	}
+	return dataPath, false, nil
+}
+
+// GCBlobs removes the blobs in s that no file in any of s's repositories
+// refers to, either as a reference to a blob (see BlobRefPath) or as the
+// base of a delta (see DeltaPath). Removed blobs are listed as if they were
+// in the store's .blobs directory, wherever they are stored.
+func (s *MultiStore) GCBlobs(opt GCOptions) (*GCResult, error) {
+	res := &GCResult{Removed: []string{}}
+	now := time.Now()
+	blobs, err := s.Blobs()
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+	walker := fs.WalkFS(".", s.walkableRWVFS)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		fi := walker.Stat()
+		if fi == nil {
+			continue
+		}
+		if fi.IsDir() {
+			if walker.Path() == blobsDirName {
+				walker.SkipDir()
+			}
+			continue
+		}
+		p := walker.Path()
+		switch {
+		case strings.HasSuffix(p, ".blob"):
+			hash, _, err := readBlobRef(s.walkableRWVFS, strings.TrimSuffix(p, ".blob"))
+			if err != nil {
+				return nil, err
+			}
+			referenced[hash] = true
+		case strings.HasSuffix(p, ".delta"):
+			d, _, err := (&RepositoryStore{walkableRWVFS: s.walkableRWVFS}).readDelta(strings.TrimSuffix(p, ".delta"))
+			if err != nil {
+				return nil, err
+			}
+			referenced[d.Base] = true
+		}
+	}
+
+	err = blobs.Walk(func(hash string, fi os.FileInfo) error {
+		if referenced[hash] || now.Sub(fi.ModTime()) < opt.MinAge {
+			return nil
+		}
+		if !opt.DryRun {
+			if err := blobs.Remove(hash); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		res.Removed = append(res.Removed, path.Join(blobsDirName, blobPath(hash)))
+		res.Size += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
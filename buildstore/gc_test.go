@@ -0,0 +1,258 @@
+package buildstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/rwvfs"
+)
+
+func TestRepositoryStore_GC(t *testing.T) {
+	files := map[string]string{
+		"r/live/f.json": "f",
+
+		"r/interrupted/f.json":        "f",
+		"r/interrupted/f.json.chunk0": "f",
+
+		"r/built/listed.json":          "f",
+		"r/built/unlisted.json":        "f",
+		"r/built/.build-state":         "f",
+		"r/built/big.json.chunks":      "{}",
+		"r/built/big.json.chunk0":      "f",
+		"r/built/partial.json.chunk0":  "f",
+		"r/built/partial.json.chunk1":  "f",
+		"r/built/sub/listed.json.blob": "f",
+
+		"r/unknown/f.json":         "f",
+		"r/unknown/g.json.chunk0":  "f",
+		"r/unknown/h.json.zst":     "f",
+		"r/unknown/.build-lease":   "f", // not a lease file
+		"r/unknown/i.json.chunk10": "f",
+	}
+	s, err := New(rwvfs.Map(files)).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AcquireBuildLease("live", "h", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AcquireBuildLease("interrupted", "h", -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AcquireBuildLease("built", "h", -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	opt := GCOptions{
+		ManifestFiles: func(s *RepositoryStore, commitID string) ([]string, error) {
+			if commitID == "built" {
+				return []string{"listed.json", "big.json", "sub/listed.json"}, nil
+			}
+			return nil, nil
+		},
+	}
+	want := []string{
+		"built/.build-lease.json",
+		"built/partial.json.chunk0",
+		"built/partial.json.chunk1",
+		"built/unlisted.json",
+		"interrupted/.build-lease.json",
+		"interrupted/f.json",
+		"interrupted/f.json.chunk0",
+		"unknown/g.json.chunk0",
+		"unknown/i.json.chunk10",
+	}
+
+	before := len(files)
+	opt.DryRun = true
+	res, err := s.GC(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(res.Removed)
+	if !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("dry run: got removed %v, want %v", res.Removed, want)
+	}
+	if len(files) != before {
+		t.Errorf("dry run: got %d files, want %d (none removed)", len(files), before)
+	}
+
+	opt.DryRun = false
+	res, err = s.GC(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(res.Removed)
+	if !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("got removed %v, want %v", res.Removed, want)
+	}
+	for _, p := range want {
+		if _, present := files["r/"+p]; present {
+			t.Errorf("%s wasn't removed", p)
+		}
+	}
+	if len(files) != before-len(want) {
+		t.Errorf("got %d files, want %d", len(files), before-len(want))
+	}
+}
+
+func TestRepositoryStore_GC_minAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclib-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := New(rwvfs.OS(dir)).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rwvfs.MkdirAll(s, "c"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"old.json", "new.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "r", "c", name), []byte("f"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "r", "c", "old.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AcquireBuildLease("c", "h", -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The build was interrupted, but only the files modified more than
+	// MinAge ago are removed (the lease was just written).
+	res, err := s.GC(GCOptions{MinAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c/old.json"}; !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("got removed %v, want %v", res.Removed, want)
+	}
+	for name, want := range map[string]bool{"old.json": false, "new.json": true, BuildLeaseFilename: true} {
+		if _, err := os.Stat(filepath.Join(dir, "r", "c", name)); (err == nil) != want {
+			t.Errorf("%s: got exists %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestRepositoryStore_GC_retention(t *testing.T) {
+	files := map[string]string{
+		"c1/f.json": "f",
+		"c2/f.json": "f",
+		"c3/f.json": "f",
+		"c4/f.json": "f",
+		"c5/f.json": "f",
+	}
+	s := newRepositoryStore(walkableRWVFS{rwvfs.Map(files)})
+	t0 := time.Now()
+	for i, commitID := range []string{"c2", "c1"} {
+		if err := s.RecordBranchBuild("master", commitID, t0.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AcquireBuildLease("c5", "h", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.GC(GCOptions{Retention: &RetentionPolicy{
+		KeepPerBranch: 1,
+		KeepCommits:   map[string]bool{"c3": true},
+		ExpireAfter:   time.Hour, // rwvfs.Map's files are all old
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(res.Removed)
+	if want := []string{"c2/f.json", "c4/f.json"}; !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("got removed %v, want %v", res.Removed, want)
+	}
+	if builds, err := s.BranchBuilds("master"); err != nil || len(builds) != 1 || builds[0].CommitID != "c1" {
+		t.Errorf("got master builds %v (error %v), want only c1's", builds, err)
+	}
+}
+
+func TestBuildLease(t *testing.T) {
+	s, err := New(rwvfs.Map(map[string]string{})).RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, err := s.ReadBuildLease("c"); err != nil || l != nil {
+		t.Errorf("got lease %+v (error %v) with none acquired, want nil", l, err)
+	}
+	if err := s.AcquireBuildLease("c", "a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// Releasing another build's lease leaves it alone.
+	if err := s.ReleaseBuildLease("c", "b"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.ReadBuildLease("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l == nil || l.Holder != "a" || !l.Live(time.Now()) || l.Live(time.Now().Add(2*time.Hour)) {
+		t.Errorf("got lease %+v, want a's lease for an hour", l)
+	}
+	if err := s.ReleaseBuildLease("c", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := s.ReadBuildLease("c"); err != nil || l != nil {
+		t.Errorf("got lease %+v (error %v) after release, want nil", l, err)
+	}
+}
+
+func TestMultiStore_GCBlobs(t *testing.T) {
+	files := make(map[string]string)
+	ms := New(rwvfs.Map(files))
+	blobs, err := ms.Blobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ms.RepositoryStore("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(data string) string {
+		hash, err := HashBlob(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := blobs.Put(hash, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	linked, base, unreferenced := put("a\n"), put("b\nc\nd\ne\nf\n"), put("c\n")
+	if err := s.LinkBlob("c/f", linked); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutDelta("c", "g", NewDelta([]byte("b\nc\nd\ne\nf\n"), []byte("b\nc\nd\ne\nf\ng\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ms.GCBlobs(GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".blobs/" + blobPath(unreferenced)}; !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("got removed %v, want %v", res.Removed, want)
+	}
+	for _, hash := range []string{linked, base, unreferenced} {
+		has, err := blobs.Has(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := hash != unreferenced; has != want {
+			t.Errorf("blob %s: got Has %v, want %v", hash, has, want)
+		}
+	}
+}
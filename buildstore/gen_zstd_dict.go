@@ -0,0 +1,63 @@
+// +build ignore
+
+// This program trains the zstd dictionary that srclib uses to compress build
+// data (see Compress) on the graph outputs in testdata, and writes it to
+// zstd_dict.go. Run it (with "go generate") in the buildstore directory.
+//
+// The dictionary's ID is recorded in each file compressed with it. If you
+// retrain the dictionary, give it a new ID and keep the old dictionary in
+// the list that Open decompresses with, so that existing build data can still
+// be read.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/klauspost/compress/dict"
+)
+
+const (
+	dictID      = 0x5c1b0001
+	maxDictSize = 64 << 10
+	sampleSize  = 16 << 10 // the trainer works best on many small samples
+	samples     = "../testdata/repos-output/want/*/*_graph.v0.json"
+)
+
+func main() {
+	files, err := filepath.Glob(samples)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("no graph outputs match %s", samples)
+	}
+	var input [][]byte
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for len(data) > sampleSize {
+			input, data = append(input, data[:sampleSize]), data[sampleSize:]
+		}
+		input = append(input, data)
+	}
+
+	d, err := dict.BuildZstdDict(input, dict.Options{MaxDictSize: maxDictSize, HashBytes: 6, ZstdDictID: dictID})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen_zstd_dict.go from %d graph outputs in testdata. DO NOT EDIT.\n\n", len(files))
+	fmt.Fprintf(&buf, "package buildstore\n\n")
+	fmt.Fprintf(&buf, "// graphDict is the zstd dictionary (ID %#x) trained on graph outputs.\n", uint32(dictID))
+	fmt.Fprintf(&buf, "var graphDict = []byte(%+q)\n", d)
+	if err := ioutil.WriteFile("zstd_dict.go", buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package buildstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Builds that are in progress hold a lease on their commit's build data
+// directory (a file, named BuildLeaseFilename, that says who holds it and
+// until when), so that garbage collection (see GCOptions) leaves their
+// partial build data alone. A build that is interrupted leaves its lease to
+// expire, which tells garbage collection that the build data that it left
+// behind (if the build didn't write a build manifest) is partial.
+
+// BuildLeaseFilename is the name of the file (in the build data directory
+// for a commit) that holds the lease of the build in progress, if any.
+const BuildLeaseFilename = ".build-lease.json"
+
+// A BuildLease records that a build of a commit is in progress.
+type BuildLease struct {
+	// Holder identifies the build (e.g., by host and process ID).
+	Holder string
+
+	// Expires is when the lease expires, unless it is renewed first.
+	Expires time.Time
+}
+
+// Live reports whether the lease hasn't expired at time t.
+func (l *BuildLease) Live(t time.Time) bool { return t.Before(l.Expires) }
+
+// AcquireBuildLease acquires (or renews) the lease on the build data of
+// commitID for holder, for duration d. Leases are advisory: concurrent
+// builds of the same commit each hold the lease in turn.
+func (s *RepositoryStore) AcquireBuildLease(commitID, holder string, d time.Duration) error {
+	data, err := json.Marshal(&BuildLease{Holder: holder, Expires: time.Now().Add(d)})
+	if err != nil {
+		return err
+	}
+	f, err := s.Create(s.FilePath(commitID, BuildLeaseFilename))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReleaseBuildLease releases holder's lease on the build data of commitID.
+// If another build holds the lease, it is left alone.
+func (s *RepositoryStore) ReleaseBuildLease(commitID, holder string) error {
+	l, err := s.ReadBuildLease(commitID)
+	if err != nil || l == nil || l.Holder != holder {
+		return err
+	}
+	if err := s.Remove(s.FilePath(commitID, BuildLeaseFilename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ReadBuildLease returns the lease on the build data of commitID, or nil if
+// there is none (e.g., because no build of the commit is in progress).
+func (s *RepositoryStore) ReadBuildLease(commitID string) (*BuildLease, error) {
+	f, err := s.Open(s.FilePath(commitID, BuildLeaseFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var l *BuildLease
+	if err := json.NewDecoder(f).Decode(&l); err != nil {
+		return nil, fmt.Errorf("build lease of commit %s: %s", commitID, err)
+	}
+	if l == nil {
+		return nil, fmt.Errorf("build lease of commit %s is null", commitID)
+	}
+	return l, nil
+}
@@ -0,0 +1,88 @@
+package buildstore
+
+import (
+	"time"
+
+	"github.com/kr/fs"
+)
+
+// A RetentionPolicy determines which commits' builds garbage collection
+// keeps (see GCOptions). A build is kept if any of the policy's rules
+// retains it; the build data of the other commits is removed.
+type RetentionPolicy struct {
+	// KeepPerBranch is the number of latest builds on each branch (see
+	// BranchBuilds) to keep.
+	KeepPerBranch int
+
+	// KeepCommits is the set of commits (e.g., those that tags point to,
+	// or the current commit) whose builds to keep.
+	KeepCommits map[string]bool
+
+	// ExpireAfter is how long to keep the other builds for (after they were
+	// last modified), or 0 to keep none of them.
+	ExpireAfter time.Duration
+}
+
+// expiredCommits returns the set of commits in s whose builds p doesn't
+// retain at time now.
+func (s *RepositoryStore) expiredCommits(p *RetentionPolicy, now time.Time) (map[string]bool, error) {
+	keep := make(map[string]bool, len(p.KeepCommits))
+	for c := range p.KeepCommits {
+		keep[c] = true
+	}
+	if p.KeepPerBranch > 0 {
+		branches, err := s.Branches()
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			builds, err := s.BranchBuilds(branch)
+			if err != nil {
+				return nil, err
+			}
+			for i, b := range builds {
+				if i == p.KeepPerBranch {
+					break
+				}
+				keep[b.CommitID] = true
+			}
+		}
+	}
+
+	commits, err := s.ListCommits()
+	if err != nil {
+		return nil, err
+	}
+	expired := map[string]bool{}
+	for _, commitID := range commits {
+		if keep[commitID] {
+			continue
+		}
+		if p.ExpireAfter > 0 {
+			t, err := s.buildTime(commitID)
+			if err != nil {
+				return nil, err
+			}
+			if now.Sub(t) < p.ExpireAfter {
+				continue
+			}
+		}
+		expired[commitID] = true
+	}
+	return expired, nil
+}
+
+// buildTime returns when the build data of commitID was last modified.
+func (s *RepositoryStore) buildTime(commitID string) (time.Time, error) {
+	var t time.Time
+	walker := fs.WalkFS(s.CommitPath(commitID), s.walkableRWVFS)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return time.Time{}, err
+		}
+		if fi := walker.Stat(); fi != nil && !fi.IsDir() && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t, nil
+}
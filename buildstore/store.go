@@ -26,10 +26,21 @@ var (
 
 type MultiStore struct {
 	walkableRWVFS
+
+	// blobs is the store of the blobs that files in the store may refer to,
+	// or nil if they are stored in the store's filesystem (see Blobs).
+	blobs BlobStore
 }
 
 func New(fs rwvfs.FileSystem) *MultiStore {
-	return &MultiStore{walkableRWVFS{fs}}
+	return &MultiStore{walkableRWVFS: walkableRWVFS{fs}}
+}
+
+// NewWithBlobs returns a MultiStore that stores files in fs, and the blobs
+// that they refer to (see BlobRefPath) in blobs, such as a bucket in cloud
+// object storage.
+func NewWithBlobs(fs rwvfs.FileSystem, blobs BlobStore) *MultiStore {
+	return &MultiStore{walkableRWVFS: walkableRWVFS{fs}, blobs: blobs}
 }
 
 func (s *MultiStore) RepositoryStore(repoURI repo.URI) (*RepositoryStore, error) {
@@ -43,15 +54,26 @@ func (s *MultiStore) RepositoryStore(repoURI repo.URI) (*RepositoryStore, error)
 		}
 	}
 
-	return newRepositoryStore(walkableRWVFS{rwvfs.Sub(s.walkableRWVFS, path)}), nil
+	blobs, err := s.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	rs := newRepositoryStore(walkableRWVFS{rwvfs.Sub(s.walkableRWVFS, path)})
+	rs.blobs = blobs
+	return rs, nil
 }
 
 type RepositoryStore struct {
 	walkableRWVFS
+
+	// blobs is the store of the blobs that files in the store may refer to
+	// (see BlobRefPath), or nil if the store's files are all stored
+	// directly.
+	blobs BlobStore
 }
 
 func newRepositoryStore(fs rwvfs.FileSystem) *RepositoryStore {
-	return &RepositoryStore{walkableRWVFS{fs}}
+	return &RepositoryStore{walkableRWVFS: walkableRWVFS{fs}}
 }
 
 func NewRepositoryStore(repoDir string) (*RepositoryStore, error) {
@@ -112,7 +134,7 @@ func (s *RepositoryStore) ListCommits() ([]string, error) {
 
 	var commits []string
 	for _, f := range files {
-		if f.IsDir() {
+		if f.IsDir() && f.Name() != branchesDirName {
 			commits = append(commits, f.Name())
 		}
 	}
@@ -128,10 +150,27 @@ func (s *RepositoryStore) DataFiles(path string) ([]*BuildDataFileInfo, error) {
 			continue
 		}
 		if fi.IsDir() {
+			if strings.TrimPrefix(walker.Path(), "/") == branchesDirName {
+				walker.SkipDir()
+			}
 			continue
 		}
 
-		path := strings.TrimPrefix(walker.Path(), "/")
+		// List each chunked or compressed file once, with the size of the
+		// whole, decompressed file.
+		stored := strings.TrimPrefix(walker.Path(), "/")
+		path, ok := DataFilePath(stored)
+		if !ok {
+			continue
+		}
+		size := fi.Size()
+		if path != stored {
+			fi, err := s.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			size = fi.Size()
+		}
 
 		parts := strings.SplitN(path, "/", 2)
 		if len(parts) != 2 {
@@ -144,7 +183,7 @@ func (s *RepositoryStore) DataFiles(path string) ([]*BuildDataFileInfo, error) {
 		files = append(files, &BuildDataFileInfo{
 			CommitID: commitID,
 			Path:     path,
-			Size:     fi.Size(),
+			Size:     size,
 			ModTime:  fi.ModTime(),
 			DataType: dataTypeName,
 		})
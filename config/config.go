@@ -1,13 +1,13 @@
 package config
 
 import (
-	"encoding/json"
 	"os"
-	"path/filepath"
 
+	"sourcegraph.com/sourcegraph/srclib/network"
 	"sourcegraph.com/sourcegraph/srclib/repo"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
 	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
 )
 
 // Filename is the name of the file that configures a directory tree or
@@ -22,6 +22,18 @@ type Repository struct {
 	// Tree is the configuration for the top-level directory tree in the
 	// repository.
 	Tree
+
+	// Toolchains pins the versions of toolchains that may be used in the
+	// repository. It maps toolchain paths to version constraints (e.g.,
+	// "^1.2" or ">=1.0 <1.5"; see bundle.Constraint). "src toolchain
+	// upgrade", when run in the repository, only upgrades toolchains to
+	// versions that satisfy their pins.
+	Toolchains map[string]string `json:",omitempty"`
+
+	// Network configures proxies and mirrors for network access when
+	// srclib is run in the repository, overriding the SRCLIBPATH config
+	// (see External). The environment overrides it.
+	Network *network.Settings `json:",omitempty"`
 }
 
 // Tree represents the config for a directory and its subdirectories.
@@ -56,6 +68,31 @@ type Tree struct {
 	// not processed further.
 	SkipDirs []string `json:",omitempty"`
 
+	// DefMerge configures how defs with the same path in a source unit's
+	// graph output are merged. It is copied to each source unit in this tree
+	// that doesn't specify its own DefMerge.
+	DefMerge *unit.DefMerge `json:",omitempty"`
+
+	// IncludeLocals is whether graphers should emit local defs and refs to
+	// them (see unit.SourceUnit.IncludeLocals). It is copied to each source
+	// unit in this tree that doesn't specify its own IncludeLocals.
+	IncludeLocals *bool `json:",omitempty"`
+
+	// Snippets configures the embedding of defs' source text in graph output
+	// (see unit.SourceUnit.Snippets). It is copied to each source unit in
+	// this tree that doesn't specify its own Snippets.
+	Snippets *unit.Snippets `json:",omitempty"`
+
+	// Normalize configures the passes that normalize a source unit's graph
+	// output (see unit.SourceUnit.Normalize). It is copied to each source
+	// unit in this tree that doesn't specify its own Normalize.
+	Normalize *unit.Normalize `json:",omitempty"`
+
+	// Filters drop or redact defs and refs in a source unit's graph output
+	// (see unit.SourceUnit.Filters). They are copied to each source unit in
+	// this tree that doesn't specify its own Filters.
+	Filters []*unit.Filter `json:",omitempty"`
+
 	// TODO(sqs): Add some type of field that lets the Srcfile and the scanners
 	// have input into which tools get used during the execution phase. Right
 	// now, we're going to try just using the system defaults (srclib-*) and
@@ -72,12 +109,17 @@ type Tree struct {
 // the Go code), then it is used instead of the Srcfile or the default
 // configuration.
 func ReadRepository(dir string, repoURI repo.URI) (*Repository, error) {
+	return ReadRepositoryFS(vfs.OS(dir), repoURI)
+}
+
+// ReadRepositoryFS is like ReadRepository, but it reads the Srcfile from the
+// root of the tree fs (such as a commit that isn't checked out).
+func ReadRepositoryFS(fs vfs.FileSystem, repoURI repo.URI) (*Repository, error) {
 	var c *Repository
 	if oc, overridden := overrides[repoURI]; overridden {
 		c = oc
-	} else if f, err := os.Open(filepath.Join(dir, Filename)); err == nil {
-		defer f.Close()
-		err = json.NewDecoder(f).Decode(&c)
+	} else if data, err := fs.ReadFile(Filename); err == nil {
+		c, err = ParseSrcfile(data)
 		if err != nil {
 			return nil, err
 		}
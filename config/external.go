@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/repo"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
 )
 
@@ -17,6 +19,15 @@ type External struct {
 	// Scanners is the default set of scanners to use. If not specified, all
 	// scanners in the SRCLIBPATH will be used.
 	Scanners []*toolchain.ToolRef
+
+	// Hosts are self-hosted code hosts (such as GitLab or Bitbucket Server
+	// instances) whose repository URLs should be normalized to URIs (see
+	// repo.Host).
+	Hosts []*repo.Host `json:",omitempty"`
+
+	// Network configures proxies and mirrors for all network access. A
+	// repository's Srcfile and the environment override it.
+	Network *network.Settings `json:",omitempty"`
 }
 
 // SrclibPathConfig is stored in SRCLIBPATH/.srclibconfig.
@@ -39,6 +50,14 @@ func init() {
 		}
 	}
 
+	for _, h := range SrclibPathConfig.Hosts {
+		if err := h.Validate(); err != nil {
+			log.Printf("Warning: ignoring invalid host in config file at %s: %s.", configFile, err)
+			continue
+		}
+		repo.Hosts = append(repo.Hosts, h)
+	}
+
 	// Default to using all available scanners.
 	if len(SrclibPathConfig.Scanners) == 0 {
 		SrclibPathConfig.Scanners, err = toolchain.ListTools("scan")
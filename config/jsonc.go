@@ -0,0 +1,215 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StripComments returns a copy of data (JSON that may contain "//" and
+// "/* */" comments, as a Srcfile may) with the comments replaced by spaces.
+// Newlines in comments are kept, so that offsets and line numbers in the
+// result are the same as in data.
+func StripComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		}
+	}
+	return out
+}
+
+// A jsonNode is a JSON value in a document, with the offsets of its text in
+// the document (so that it can be replaced without reformatting the rest of
+// the document).
+type jsonNode struct {
+	start, end int
+
+	kind    byte // '{', '[', '"', or 0 for other values
+	members []*jsonMember
+	elems   []*jsonNode
+}
+
+type jsonMember struct {
+	key   string
+	value *jsonNode
+}
+
+// get returns the value of the member of object n with the given key, or nil
+// if n isn't an object or has no such member.
+func (n *jsonNode) get(key string) *jsonNode {
+	for _, m := range n.object() {
+		if m.key == key {
+			return m.value
+		}
+	}
+	return nil
+}
+
+// list returns the elements of array n, or nil if n is nil or isn't an
+// array.
+func (n *jsonNode) list() []*jsonNode {
+	if n == nil {
+		return nil
+	}
+	return n.elems
+}
+
+// object returns the members of object n, or nil if n is nil or isn't an
+// object.
+func (n *jsonNode) object() []*jsonMember {
+	if n == nil {
+		return nil
+	}
+	return n.members
+}
+
+// parseJSON parses data, which must not contain comments (see
+// StripComments).
+func parseJSON(data []byte) (*jsonNode, error) {
+	p := &jsonParser{data: data}
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos != len(data) {
+		return nil, p.errorf("unexpected %q after top-level value", data[p.pos])
+	}
+	return n, nil
+}
+
+type jsonParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *jsonParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect skips whitespace and the byte c, which must come next.
+func (p *jsonParser) expect(c byte) error {
+	if p.skipSpace(); p.pos == len(p.data) || p.data[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// peek skips whitespace and returns whether c comes next (consuming it if
+// so).
+func (p *jsonParser) peek(c byte) bool {
+	if p.skipSpace(); p.pos < len(p.data) && p.data[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jsonParser) value() (*jsonNode, error) {
+	p.skipSpace()
+	if p.pos == len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	n := &jsonNode{start: p.pos, kind: p.data[p.pos]}
+	switch n.kind {
+	case '{':
+		p.pos++
+		for first := true; !p.peek('}'); first = false {
+			if !first {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+			}
+			key, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if key.kind != '"' {
+				return nil, p.errorf("object key is not a string")
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			var k string
+			if err := json.Unmarshal(p.data[key.start:key.end], &k); err != nil {
+				return nil, err
+			}
+			n.members = append(n.members, &jsonMember{key: k, value: v})
+		}
+	case '[':
+		p.pos++
+		for first := true; !p.peek(']'); first = false {
+			if !first {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			n.elems = append(n.elems, v)
+		}
+	case '"':
+		for p.pos++; p.pos < len(p.data) && p.data[p.pos] != '"'; p.pos++ {
+			if p.data[p.pos] == '\\' {
+				p.pos++
+			}
+		}
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated string")
+		}
+		p.pos++
+	default:
+		n.kind = 0
+		for p.pos < len(p.data) {
+			c := p.data[p.pos]
+			if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				break
+			}
+			p.pos++
+		}
+		if p.pos == n.start {
+			return nil, p.errorf("unexpected %q", p.data[p.pos])
+		}
+	}
+	n.end = p.pos
+	return n, nil
+}
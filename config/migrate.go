@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A migration rewrites a part of a Srcfile that uses an old form of the
+// config schema to the current form.
+type migration struct {
+	// desc describes what the migration rewrites.
+	desc string
+
+	// edits returns the edits that migrate the Srcfile whose parsed
+	// top-level value is root and whose text is data.
+	edits func(root *jsonNode, data []byte) ([]edit, error)
+}
+
+// An edit replaces data[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// migrations are the migrations that Migrate applies, in order. When the
+// config schema changes in a way that makes old Srcfiles invalid (or
+// changes their meaning), add a migration here (and, if needed, make it
+// parse the old form in Srcfiles that haven't been migrated yet).
+var migrations = []migration{
+	{
+		desc: `Scanners given as "TOOLCHAIN:TOOL" strings`,
+		edits: func(root *jsonNode, data []byte) ([]edit, error) {
+			return toolRefStringEdits(root.get("Scanners").list(), data)
+		},
+	},
+	{
+		desc: `source unit Ops given as "TOOLCHAIN:TOOL" strings`,
+		edits: func(root *jsonNode, data []byte) ([]edit, error) {
+			var edits []edit
+			for _, u := range root.get("SourceUnits").list() {
+				var tools []*jsonNode
+				for _, m := range u.get("Ops").object() {
+					tools = append(tools, m.value)
+				}
+				e, err := toolRefStringEdits(tools, data)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, e...)
+			}
+			return edits, nil
+		},
+	},
+}
+
+// toolRefStringEdits returns edits that replace the values in nodes that
+// are strings of the form "TOOLCHAIN:TOOL" (or "TOOLCHAIN TOOL") with
+// toolchain.ToolRef objects.
+func toolRefStringEdits(nodes []*jsonNode, data []byte) ([]edit, error) {
+	var edits []edit
+	for _, n := range nodes {
+		if n.kind != '"' {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(data[n.start:n.end], &s); err != nil {
+			return nil, err
+		}
+		sep := strings.IndexAny(s, ": ")
+		if sep == -1 {
+			return nil, fmt.Errorf("invalid tool %q (expected TOOLCHAIN:TOOL)", s)
+		}
+		toolchainPath, _ := json.Marshal(s[:sep])
+		subcmd, _ := json.Marshal(s[sep+1:])
+		edits = append(edits, edit{n.start, n.end, fmt.Sprintf(`{"Toolchain": %s, "Subcmd": %s}`, toolchainPath, subcmd)})
+	}
+	return edits, nil
+}
+
+// Migrate rewrites the Srcfile data from old forms of the config schema to
+// the current form. Only the parts that use an old form are rewritten, so
+// the rest of the Srcfile (including its comments and formatting) is kept.
+// It returns the new Srcfile and descriptions of the migrations that were
+// applied (which is empty if data is already in the current form).
+func Migrate(data []byte) ([]byte, []string, error) {
+	var applied []string
+	for _, m := range migrations {
+		root, err := parseJSON(StripComments(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		edits, err := m.edits(root, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("migrating %s: %s", m.desc, err)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		sort.Sort(sort.Reverse(byStart(edits)))
+		for _, e := range edits {
+			data = append(data[:e.start:e.start], append([]byte(e.text), data[e.end:]...)...)
+		}
+		applied = append(applied, m.desc)
+	}
+	return data, applied, nil
+}
+
+type byStart []edit
+
+func (v byStart) Len() int           { return len(v) }
+func (v byStart) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byStart) Less(i, j int) bool { return v[i].start < v[j].start }
+
+// ParseSrcfile parses the contents of a Srcfile (which may contain comments
+// and use old forms of the config schema; see Migrate).
+func ParseSrcfile(data []byte) (*Repository, error) {
+	data, _, err := Migrate(data)
+	if err != nil {
+		return nil, err
+	}
+	var c *Repository
+	if err := json.Unmarshal(StripComments(data), &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
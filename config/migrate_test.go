@@ -0,0 +1,88 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := map[string]string{
+		`{"a": 1} // x`:             `{"a": 1}     `,
+		"{/* a\nb */\"c\": \"//\"}": "{    \n    \"c\": \"//\"}",
+		`{"a": "\"/*"}`:             `{"a": "\"/*"}`,
+		`[1] /* unterminated`:       `[1]                `,
+	}
+	for in, want := range tests {
+		if got := string(StripComments([]byte(in))); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := map[string]struct {
+		in, want    string
+		wantApplied int
+	}{
+		"current": {
+			in:   `{"Scanners": [{"Toolchain": "t", "Subcmd": "scan"}]}`,
+			want: `{"Scanners": [{"Toolchain": "t", "Subcmd": "scan"}]}`,
+		},
+		"scanner strings": {
+			in: `{
+  // Our scanners.
+  "Scanners": ["t:scan", /* old */ "u scan"],
+  "SkipDirs": ["x"] // keep
+}`,
+			want: `{
+  // Our scanners.
+  "Scanners": [{"Toolchain": "t", "Subcmd": "scan"}, /* old */ {"Toolchain": "u", "Subcmd": "scan"}],
+  "SkipDirs": ["x"] // keep
+}`,
+			wantApplied: 1,
+		},
+		"ops strings": {
+			in:          `{"SourceUnits": [{"Name": "a", "Ops": {"graph": "t:graph", "depresolve": null}}], "Scanners": ["t:scan"]}`,
+			want:        `{"SourceUnits": [{"Name": "a", "Ops": {"graph": {"Toolchain": "t", "Subcmd": "graph"}, "depresolve": null}}], "Scanners": [{"Toolchain": "t", "Subcmd": "scan"}]}`,
+			wantApplied: 2,
+		},
+	}
+	for label, test := range tests {
+		got, applied, err := Migrate([]byte(test.in))
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", label, got, test.want)
+		}
+		if len(applied) != test.wantApplied {
+			t.Errorf("%s: got %d migrations applied (%v), want %d", label, len(applied), applied, test.wantApplied)
+		}
+	}
+
+	if _, _, err := Migrate([]byte(`{"Scanners": ["nocolon"]}`)); err == nil {
+		t.Error("got no error for an invalid scanner string")
+	}
+	if _, _, err := Migrate([]byte(`{"Scanners": [}`)); err == nil {
+		t.Error("got no error for invalid JSON")
+	}
+}
+
+func TestParseSrcfile(t *testing.T) {
+	c, err := ParseSrcfile([]byte(`{
+  "Scanners": ["t:scan"], // old form
+  "SkipDirs": ["vendor"]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*toolchain.ToolRef{{Toolchain: "t", Subcmd: "scan"}}; !reflect.DeepEqual(c.Scanners, want) {
+		t.Errorf("got Scanners %v, want %v", c.Scanners, want)
+	}
+	if want := []string{"vendor"}; !reflect.DeepEqual(c.SkipDirs, want) {
+		t.Errorf("got SkipDirs %v, want %v", c.SkipDirs, want)
+	}
+}
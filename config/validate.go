@@ -2,8 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/toolchain/bundle"
 )
 
 var (
@@ -12,8 +15,45 @@ var (
 	ErrInvalidFilePath = errors.New("invalid file path specified in config (above config root dir or source unit dir)")
 )
 
+func (c *Repository) validate() error {
+	if err := c.Tree.validate(); err != nil {
+		return err
+	}
+	for tc, pin := range c.Toolchains {
+		if _, err := bundle.ParseConstraint(pin); err != nil {
+			return fmt.Errorf("invalid pin for toolchain %s: %s", tc, err)
+		}
+	}
+	if c.Network != nil {
+		if err := c.Network.Validate(); err != nil {
+			return fmt.Errorf("invalid network settings: %s", err)
+		}
+	}
+	return nil
+}
+
 func (c *Tree) validate() error {
 	for _, u := range c.SourceUnits {
+		if u.DefMerge != nil {
+			if err := u.DefMerge.Validate(); err != nil {
+				return err
+			}
+		}
+		if u.Snippets != nil {
+			if err := u.Snippets.Validate(); err != nil {
+				return err
+			}
+		}
+		if u.Normalize != nil {
+			if err := u.Normalize.Validate(); err != nil {
+				return err
+			}
+		}
+		for _, f := range u.Filters {
+			if err := f.Validate(); err != nil {
+				return err
+			}
+		}
 		for _, p := range u.Files {
 			p = filepath.Clean(p)
 			if filepath.IsAbs(p) {
@@ -24,5 +64,25 @@ func (c *Tree) validate() error {
 			}
 		}
 	}
+	if c.DefMerge != nil {
+		if err := c.DefMerge.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Snippets != nil {
+		if err := c.Snippets.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Normalize != nil {
+		if err := c.Normalize.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.Filters {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
+	"sourcegraph.com/sourcegraph/srclib/network"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 )
 
@@ -19,3 +21,28 @@ func TestTree_validate(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_validate(t *testing.T) {
+	tests := map[string]struct {
+		pins    map[string]string
+		network *network.Settings
+		wantErr string
+	}{
+		"no pins":          {},
+		"valid pins":       {pins: map[string]string{"sourcegraph.com/sourcegraph/srclib-go": "^1.2", "example.com/foo": ">=1.0 <1.5"}},
+		"invalid operator": {pins: map[string]string{"example.com/foo": "!1.0"}, wantErr: "invalid pin for toolchain example.com/foo"},
+		"invalid version":  {pins: map[string]string{"example.com/foo": "^1.x"}, wantErr: "invalid pin for toolchain example.com/foo"},
+		"valid network":    {network: &network.Settings{HTTPSProxy: "proxy.example.com:3128", Mirrors: map[string]string{"https://github.com/": "https://mirror.example.com/github/"}}},
+		"invalid proxy":    {network: &network.Settings{HTTPProxy: "ftp://proxy.example.com"}, wantErr: "invalid network settings"},
+	}
+	for label, test := range tests {
+		err := (&Repository{Toolchains: test.pins, Network: test.network}).validate()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %s", label, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got err %v, want it to contain %q", label, err, test.wantErr)
+		}
+	}
+}
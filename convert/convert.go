@@ -0,0 +1,142 @@
+// Package convert reads and writes srclib graph data in several formats, so
+// that the output of a build can be used by tools that expect a format
+// other than the one it was produced in.
+//
+// Graph data is read and written as a stream of records, each of which
+// holds a single def, ref, doc, alias, or type relation. Except where a
+// format requires otherwise (LSIF dumps must be read in full), records are
+// read and written one at a time, so large builds can be converted without
+// holding all of their graph data in memory.
+//
+// The supported formats are:
+//
+//	json      the JSON encoding of grapher.Output, as stored in build data
+//	ndjson    one JSON-encoded Record per line
+//	protobuf  length-delimited Record messages (see srclib.proto)
+//	lsif      an LSIF dump in the JSON lines format (see package lsif)
+//	ctags     a ctags tags file in the extended format (defs only)
+//
+// Formats that can't represent some kinds of records (such as ctags, which
+// has no refs) skip them when writing.
+package convert
+
+import (
+	"fmt"
+	"io"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A Record is a single item of graph data. Exactly one of its fields is
+// set.
+type Record struct {
+	Def          *graph.Def          `json:",omitempty"`
+	Ref          *graph.Ref          `json:",omitempty"`
+	Doc          *graph.Doc          `json:",omitempty"`
+	Alias        *graph.Alias        `json:",omitempty"`
+	TypeRelation *graph.TypeRelation `json:",omitempty"`
+}
+
+// check returns an error if the record doesn't have exactly one field set.
+func (r *Record) check() error {
+	n := 0
+	for _, set := range []bool{r.Def != nil, r.Ref != nil, r.Doc != nil, r.Alias != nil, r.TypeRelation != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("record has %d fields set (must have exactly 1)", n)
+	}
+	return nil
+}
+
+// A Reader reads records of graph data.
+type Reader interface {
+	// Read returns the next record, or io.EOF if there are no more.
+	Read() (*Record, error)
+}
+
+// A Writer writes records of graph data.
+type Writer interface {
+	// Write writes a record.
+	Write(*Record) error
+
+	// Close writes any data that must follow the records. It does not
+	// close the underlying io.Writer.
+	Close() error
+}
+
+// Options configures readers and writers.
+type Options struct {
+	// ReadFile returns the contents of a source file, given its path in the
+	// graph data. It is required by the LSIF and ctags formats, which use
+	// line and character positions instead of byte offsets.
+	ReadFile func(file string) ([]byte, error)
+
+	// RootURI is the URI of the directory that the file paths in the graph
+	// data are relative to (usually that of the repository root). It is
+	// used as the project root of LSIF dumps.
+	RootURI string
+}
+
+// Formats lists the names of the supported formats.
+var Formats = []string{"json", "ndjson", "protobuf", "lsif", "ctags"}
+
+// NewReader returns a reader of graph data in the named format from r.
+func NewReader(format string, r io.Reader, opt Options) (Reader, error) {
+	if (format == "lsif" || format == "ctags") && opt.ReadFile == nil {
+		return nil, fmt.Errorf("reading the %s format requires access to source files", format)
+	}
+	switch format {
+	case "json":
+		return newJSONReader(r), nil
+	case "ndjson":
+		return newNDJSONReader(r), nil
+	case "protobuf":
+		return newProtobufReader(r), nil
+	case "lsif":
+		return newLSIFReader(r, opt)
+	case "ctags":
+		return newCtagsReader(r, opt), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// NewWriter returns a writer of graph data in the named format to w.
+func NewWriter(format string, w io.Writer, opt Options) (Writer, error) {
+	if (format == "lsif" || format == "ctags") && opt.ReadFile == nil {
+		return nil, fmt.Errorf("writing the %s format requires access to source files", format)
+	}
+	switch format {
+	case "json":
+		return newJSONWriter(w), nil
+	case "ndjson":
+		return newNDJSONWriter(w), nil
+	case "protobuf":
+		return newProtobufWriter(w), nil
+	case "lsif":
+		return newLSIFWriter(w, opt), nil
+	case "ctags":
+		return newCtagsWriter(w, opt), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Copy writes all of the records read from r to w, and returns the number
+// of records copied. It does not close w.
+func Copy(w Writer, r Reader) (int, error) {
+	n := 0
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if err := w.Write(rec); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,271 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx/types"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+const testSrc = "package p\n\n// F does nothing.\nfunc F() {}\n\nvar v = F\n"
+
+func readTestFile(file string) ([]byte, error) {
+	if file == "p.go" {
+		return []byte(testSrc), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+var testOpt = Options{ReadFile: readTestFile, RootURI: "file:///r"}
+
+func testRecords() []*Record {
+	fStart, vStart := strings.Index(testSrc, "func"), strings.Index(testSrc, "var")
+	return []*Record{
+		{Def: &graph.Def{DefKey: graph.DefKey{Repo: "example.com/r", UnitType: "GoPackage", Unit: "p", Path: "F"}, TreePath: "F", Name: "F", Kind: graph.Func, Callable: true, File: "p.go", DefStart: fStart, DefEnd: fStart + 11, Exported: true, Data: types.JsonText(`{"a":1}`)}},
+		{Def: &graph.Def{DefKey: graph.DefKey{Repo: "example.com/r", UnitType: "GoPackage", Unit: "p", Path: "v"}, Name: "v", Kind: graph.Var, File: "p.go", DefStart: vStart, DefEnd: vStart + 9}},
+		{Ref: &graph.Ref{DefRepo: "example.com/r", DefUnitType: "GoPackage", DefUnit: "p", DefPath: "F", Def: true, Repo: "example.com/r", UnitType: "GoPackage", Unit: "p", File: "p.go", Start: fStart + 5, End: fStart + 6}},
+		{Ref: &graph.Ref{DefRepo: "example.com/r", DefUnitType: "GoPackage", DefUnit: "p", DefPath: "F", Repo: "example.com/r", UnitType: "GoPackage", Unit: "p", File: "p.go", Start: vStart + 8, End: vStart + 9, Candidates: []*graph.RefCandidate{{RefDefKey: graph.RefDefKey{DefPath: "F"}, Score: 0.75}}}},
+		{Doc: &graph.Doc{DefKey: graph.DefKey{Repo: "example.com/r", UnitType: "GoPackage", Unit: "p", Path: "F"}, Format: "text/plain", Data: "F does nothing.", File: "p.go", Start: 11, End: 29}},
+		{Alias: &graph.Alias{Path: "G", DefPath: "F"}},
+		{TypeRelation: &graph.TypeRelation{Kind: graph.Implements, Path: "T", DefRepo: "example.com/x", DefPath: "I"}},
+	}
+}
+
+func recordsString(recs []*Record) string {
+	data, _ := json.Marshal(recs)
+	return string(data)
+}
+
+// convert writes the records in the format, and reads them back.
+func convert(t *testing.T, format string, recs []*Record) ([]*Record, string) {
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, testOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%s: %s", format, err)
+	}
+	data := buf.String()
+
+	r, err := NewReader(format, &buf, testOpt)
+	if err != nil {
+		t.Fatalf("%s: %s", format, err)
+	}
+	var got []*Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		got = append(got, rec)
+	}
+	return got, data
+}
+
+// FuzzReader checks that reading graph data in any format doesn't panic,
+// however malformed the data is.
+func FuzzReader(f *testing.F) {
+	for i, format := range Formats {
+		var buf bytes.Buffer
+		w, err := NewWriter(format, &buf, testOpt)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, rec := range testRecords() {
+			if err := w.Write(rec); err != nil {
+				f.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(uint8(i), buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, format uint8, data []byte) {
+		r, err := NewReader(Formats[int(format)%len(Formats)], bytes.NewReader(data), testOpt)
+		if err != nil {
+			return
+		}
+		// Each record is encoded in at least 1 byte, so reading more
+		// records than that means the reader is stuck.
+		for i := 0; i <= len(data); i++ {
+			if _, err := r.Read(); err != nil {
+				return
+			}
+		}
+		t.Fatalf("read more records (%d) than there are bytes", len(data)+1)
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "ndjson", "protobuf"} {
+		got, data := convert(t, format, testRecords())
+		if !reflect.DeepEqual(got, testRecords()) {
+			t.Errorf("%s: got records %s, want %s (encoded as %q)", format, recordsString(got), recordsString(testRecords()), data)
+		}
+	}
+}
+
+func TestRoundTrip_empty(t *testing.T) {
+	for _, format := range Formats {
+		if got, data := convert(t, format, nil); len(got) != 0 {
+			t.Errorf("%s: got records %+v from %q, want none", format, got, data)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	// The JSON format should be interchangeable with grapher.Output.
+	r := newJSONReader(strings.NewReader(`{"defs": [{"Path": "a"}], "Refs": null, "Other": {"x": [1]}, "Docs": [{"Path": "a"}, {"Path": "b"}]}`))
+	var paths []graph.DefPath
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Def != nil {
+			paths = append(paths, rec.Def.Path)
+		} else {
+			paths = append(paths, "doc:"+rec.Doc.Path)
+		}
+	}
+	if want := []graph.DefPath{"a", "doc:a", "doc:b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+
+	var buf bytes.Buffer
+	w := newJSONWriter(&buf)
+	if err := w.Write(&Record{Ref: &graph.Ref{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Record{Def: &graph.Def{}}); err == nil {
+		t.Error("got no error writing a def after a ref")
+	}
+	if err := w.Write(&Record{}); err == nil {
+		t.Error("got no error writing an empty record")
+	}
+}
+
+func TestProtobuf_unknownFields(t *testing.T) {
+	// Readers should skip fields added in later versions of the schema.
+	var def protoBuf
+	def.string(8, "F")
+	def.int(100, 7)
+	def.double(101, 1.5)
+	def.string(102, "x")
+	var rec protoBuf
+	rec.message(1, def)
+	var buf protoBuf
+	buf.varint(uint64(len(rec)))
+	buf = append(buf, rec...)
+
+	got, err := newProtobufReader(bytes.NewReader(buf)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &Record{Def: &graph.Def{Name: "F"}}) {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := newProtobufReader(bytes.NewReader(buf[:len(buf)-1])).Read(); err == nil {
+		t.Error("got no error reading a truncated message")
+	}
+}
+
+func TestCtags(t *testing.T) {
+	got, data := convert(t, "ctags", testRecords())
+	if want := "F\tp.go\t4;\"\tkind:func\tline:4\nv\tp.go\t6;\"\tkind:var\tline:6\tfile:\n"; !strings.HasSuffix(data, want) || !strings.HasPrefix(data, "!_TAG_FILE_FORMAT\t2\t") {
+		t.Errorf("got tags file %q, want it to end with %q", data, want)
+	}
+	fStart, vStart := strings.Index(testSrc, "func"), strings.Index(testSrc, "var")
+	want := []*Record{
+		{Def: &graph.Def{DefKey: graph.DefKey{Path: "p.go/F"}, Name: "F", Kind: graph.Func, Callable: true, File: "p.go", DefStart: fStart + 5, DefEnd: fStart + 6, Exported: true}},
+		{Def: &graph.Def{DefKey: graph.DefKey{Path: "p.go/v"}, Name: "v", Kind: graph.Var, File: "p.go", DefStart: vStart + 4, DefEnd: vStart + 5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", recordsString(got), recordsString(want))
+	}
+
+	// Tags files written by other tools use search patterns, kind letters,
+	// and scopes.
+	tags := "!_TAG_FILE_SORTED\t1\t//\n" +
+		"F\tp.go\t/^func F() {}$/;\"\tf\n" +
+		"F\tp.go\t/^\\/\\/ F does/;\"\tv\tclass:a.b\n" +
+		"F\tp.go\t/^func F/;\"\tf\n" +
+		"G\tmissing.go\t1;\"\tf\n"
+	r, err := NewReader("ctags", strings.NewReader(tags), testOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, string(rec.Def.Path)+" "+string(rec.Def.Kind))
+	}
+	if want := []string{"p.go/F func", "p.go/a/b/F var", "p.go/F@" + strconv.Itoa(fStart+5) + " func"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+}
+
+func TestLSIF(t *testing.T) {
+	got, _ := convert(t, "lsif", testRecords())
+
+	// LSIF has no repos, units, aliases, type relations, or candidates, and
+	// refs that are defs are declarations. Defs without def refs get def refs
+	// at their extents.
+	vStart := strings.Index(testSrc, "var")
+	var want []*Record
+	for _, rec := range append(testRecords()[:4], &Record{Ref: &graph.Ref{DefPath: "v", Def: true, File: "p.go", Start: vStart, End: vStart + 9}}, testRecords()[4]) {
+		switch {
+		case rec.Def != nil:
+			def := *rec.Def
+			def.Repo, def.UnitType, def.Unit, def.TreePath, def.Data = "", "", "", "", nil
+			want = append(want, &Record{Def: &def})
+		case rec.Ref != nil:
+			ref := *rec.Ref
+			ref.DefRepo, ref.DefUnitType, ref.DefUnit, ref.Repo, ref.UnitType, ref.Unit, ref.Candidates = "", "", "", "", "", "", nil
+			if ref.Def {
+				ref.Kind = graph.Declaration
+			}
+			want = append(want, &Record{Ref: &ref})
+		case rec.Doc != nil:
+			doc := *rec.Doc
+			doc.Repo, doc.UnitType, doc.Unit, doc.File, doc.Start, doc.End = "", "", "", "", 0, 0
+			want = append(want, &Record{Doc: &doc})
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", recordsString(got), recordsString(want))
+	}
+}
+
+func TestNewReader_errors(t *testing.T) {
+	if _, err := NewReader("xml", strings.NewReader(""), testOpt); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+	if _, err := NewWriter("ctags", &bytes.Buffer{}, Options{}); err == nil {
+		t.Error("got no error for ctags without ReadFile")
+	}
+}
@@ -0,0 +1,312 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// ctagsKinds maps the kind names (and the few kind letters that mean the
+// same thing in most languages) used in ctags tags files to def kinds.
+var ctagsKinds = map[string]graph.DefKind{
+	"f":         graph.Func,
+	"func":      graph.Func,
+	"function":  graph.Func,
+	"method":    graph.Func,
+	"type":      graph.Type,
+	"typedef":   graph.Type,
+	"class":     graph.Type,
+	"struct":    graph.Type,
+	"interface": graph.Type,
+	"enum":      graph.Type,
+	"v":         graph.Var,
+	"var":       graph.Var,
+	"variable":  graph.Var,
+	"field":     graph.Field,
+	"member":    graph.Field,
+	"const":     graph.Const,
+	"constant":  graph.Const,
+	"package":   graph.Package,
+	"namespace": graph.Package,
+	"module":    graph.Module,
+}
+
+// ctagsScopeFields are the extension fields that name the scope of a tag
+// (in addition to "scope" itself, whose value is prefixed by the scope's
+// kind).
+var ctagsScopeFields = map[string]bool{"class": true, "struct": true, "interface": true, "namespace": true, "enum": true}
+
+// ctagsReader reads a ctags tags file in the extended format. Each tag is
+// read as a def, whose path is derived from its file, scope, and name.
+// Tags in files that can't be read (or whose addresses can't be found in
+// their files) are skipped.
+type ctagsReader struct {
+	s     *bufio.Scanner
+	opt   Options
+	files map[string][]byte
+	paths map[graph.DefPath]bool
+	line  int
+}
+
+func newCtagsReader(r io.Reader, opt Options) *ctagsReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	return &ctagsReader{s: s, opt: opt, files: make(map[string][]byte), paths: make(map[graph.DefPath]bool)}
+}
+
+func (r *ctagsReader) Read() (*Record, error) {
+	for r.s.Scan() {
+		r.line++
+		line := r.s.Text()
+		if line == "" || strings.HasPrefix(line, "!_") {
+			continue
+		}
+		def, err := r.parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("reading ctags line %d: %s", r.line, err)
+		}
+		if def != nil {
+			return &Record{Def: def}, nil
+		}
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// parse returns the def for a tag line, or nil if the tag's location can't
+// be found.
+func (r *ctagsReader) parse(line string) (*graph.Def, error) {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("tag has fewer than 3 fields")
+	}
+	name, file, rest := parts[0], parts[1], parts[2]
+
+	// The address is terminated by `;"` in the extended format.
+	address, fields := rest, ""
+	if i := strings.Index(rest, `;"`); i != -1 {
+		address, fields = rest[:i], strings.TrimPrefix(rest[i+2:], "\t")
+	}
+
+	def := &graph.Def{Name: name, File: file, Exported: true}
+	var scope string
+	var lineNum int
+	for _, field := range strings.Split(fields, "\t") {
+		if field == "" {
+			continue
+		}
+		key, value := "kind", field
+		if i := strings.Index(field, ":"); i != -1 {
+			key, value = field[:i], field[i+1:]
+		}
+		switch {
+		case key == "kind":
+			def.Kind = ctagsKinds[value]
+		case key == "line":
+			lineNum, _ = strconv.Atoi(value)
+		case key == "file":
+			def.Exported = false
+		case key == "access" && value == "private":
+			def.Exported = false
+		case key == "scope":
+			if i := strings.Index(value, ":"); i != -1 {
+				value = value[i+1:]
+			}
+			scope = value
+		case ctagsScopeFields[key]:
+			scope = value
+		}
+	}
+	def.Callable = def.Kind == graph.Func
+
+	src, err := r.readFile(file)
+	if err != nil {
+		return nil, nil
+	}
+	start, ok := ctagsAddress(src, address, lineNum, name)
+	if !ok {
+		return nil, nil
+	}
+	def.DefStart, def.DefEnd = start, start+len(name)
+
+	scope = strings.NewReplacer("::", "/", ".", "/").Replace(scope)
+	path := file + "/" + name
+	if scope != "" {
+		path = file + "/" + scope + "/" + name
+	}
+	def.Path = graph.DefPath(path)
+	if r.paths[def.Path] {
+		def.Path = graph.DefPath(fmt.Sprintf("%s@%d", path, start))
+	}
+	r.paths[def.Path] = true
+	return def, nil
+}
+
+func (r *ctagsReader) readFile(file string) ([]byte, error) {
+	if src, present := r.files[file]; present {
+		if src == nil {
+			return nil, fmt.Errorf("unreadable file")
+		}
+		return src, nil
+	}
+	src, err := r.opt.ReadFile(file)
+	if err != nil {
+		r.files[file] = nil
+		return nil, err
+	}
+	if src == nil {
+		src = []byte{}
+	}
+	r.files[file] = src
+	return src, nil
+}
+
+// ctagsAddress returns the byte offset of the tag's name in src, given its
+// address (a line number or a /pattern/ or ?pattern? search command) and
+// its "line" field (which is 0 if there is none).
+func ctagsAddress(src []byte, address string, lineNum int, name string) (int, bool) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	lineStart := func(n int) int {
+		off := 0
+		for _, l := range lines[:n] {
+			off += len(l)
+		}
+		return off
+	}
+
+	line := -1 // zero-based
+	if n, err := strconv.Atoi(address); err == nil {
+		line = n - 1
+	} else if lineNum > 0 {
+		line = lineNum - 1
+	} else if len(address) >= 2 && (address[0] == '/' || address[0] == '?') && address[len(address)-1] == address[0] {
+		pattern := address[1 : len(address)-1]
+		anchorStart, anchorEnd := strings.HasPrefix(pattern, "^"), strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`)
+		pattern = strings.TrimPrefix(pattern, "^")
+		if anchorEnd {
+			pattern = strings.TrimSuffix(pattern, "$")
+		}
+		pattern = strings.NewReplacer(`\/`, "/", `\?`, "?", `\\`, `\`, `\$`, "$").Replace(pattern)
+		for i, l := range lines {
+			text := strings.TrimRight(string(l), "\r\n")
+			if (anchorStart && anchorEnd && text == pattern) ||
+				(anchorStart && !anchorEnd && strings.HasPrefix(text, pattern)) ||
+				(!anchorStart && anchorEnd && strings.HasSuffix(text, pattern)) ||
+				(!anchorStart && !anchorEnd && strings.Contains(text, pattern)) {
+				line = i
+				break
+			}
+		}
+	}
+	if line < 0 || line >= len(lines) {
+		return 0, false
+	}
+	return lineStart(line) + nameOffset(lines[line], name), true
+}
+
+// nameOffset returns the offset of the first occurrence of name in line as
+// a whole word (or, failing that, as part of a word), or 0 if name doesn't
+// occur in line.
+func nameOffset(line []byte, name string) int {
+	isWordByte := func(i int) bool {
+		if i < 0 || i >= len(line) {
+			return false
+		}
+		c := line[i]
+		return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= 0x80
+	}
+	first := -1
+	for off := 0; off < len(line); {
+		i := bytes.Index(line[off:], []byte(name))
+		if i == -1 {
+			break
+		}
+		i += off
+		if !isWordByte(i-1) && !isWordByte(i+len(name)) {
+			return i
+		}
+		if first == -1 {
+			first = i
+		}
+		off = i + 1
+	}
+	if first == -1 {
+		return 0
+	}
+	return first
+}
+
+// ctagsWriter writes a ctags tags file in the extended format. Each
+// non-local def is written as a tag whose address is its line number; refs
+// and other records are skipped. Tags are written in the order the defs are
+// written, so the file is marked as unsorted.
+type ctagsWriter struct {
+	w       *bufio.Writer
+	opt     Options
+	started bool
+	file    string
+	lines   []int // byte offsets of the starts of the lines of file
+}
+
+func newCtagsWriter(w io.Writer, opt Options) *ctagsWriter {
+	return &ctagsWriter{w: bufio.NewWriter(w), opt: opt}
+}
+
+func (w *ctagsWriter) Write(rec *Record) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+	w.writeHeader()
+	def := rec.Def
+	if def == nil || def.Local || def.File == "" || def.Name == "" || strings.ContainsAny(def.Name+def.File, "\t\n") {
+		return nil
+	}
+	if def.File != w.file {
+		src, err := w.opt.ReadFile(def.File)
+		if err != nil {
+			return nil
+		}
+		w.file, w.lines = def.File, []int{0}
+		for i, c := range src {
+			if c == '\n' {
+				w.lines = append(w.lines, i+1)
+			}
+		}
+	}
+	line := 0
+	for line+1 < len(w.lines) && w.lines[line+1] <= def.DefStart {
+		line++
+	}
+
+	fmt.Fprintf(w.w, "%s\t%s\t%d;\"", def.Name, def.File, line+1)
+	if def.Kind != "" {
+		fmt.Fprintf(w.w, "\tkind:%s", def.Kind)
+	}
+	fmt.Fprintf(w.w, "\tline:%d", line+1)
+	if !def.Exported {
+		fmt.Fprint(w.w, "\tfile:")
+	}
+	_, err := fmt.Fprintln(w.w)
+	return err
+}
+
+func (w *ctagsWriter) writeHeader() {
+	if !w.started {
+		fmt.Fprintln(w.w, "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/")
+		fmt.Fprintln(w.w, "!_TAG_FILE_SORTED\t0\t/0=unsorted, 1=sorted, 2=foldcase/")
+		fmt.Fprintln(w.w, "!_TAG_PROGRAM_NAME\tsrclib\t//")
+		w.started = true
+	}
+}
+
+func (w *ctagsWriter) Close() error {
+	w.writeHeader()
+	return w.w.Flush()
+}
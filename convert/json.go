@@ -0,0 +1,173 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+)
+
+// The sections of a JSON-encoded grapher.Output, in the order they are
+// written.
+var jsonSections = []string{"Defs", "Refs", "Docs", "Aliases", "TypeRelations"}
+
+// section returns the index in jsonSections of the section that holds the
+// record.
+func (r *Record) section() int {
+	switch {
+	case r.Def != nil:
+		return 0
+	case r.Ref != nil:
+		return 1
+	case r.Doc != nil:
+		return 2
+	case r.Alias != nil:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// jsonReader reads a JSON-encoded grapher.Output, decoding the items of its
+// arrays one at a time (see grapher.OutputDecoder).
+type jsonReader struct {
+	dec *grapher.OutputDecoder
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	return &jsonReader{dec: grapher.NewOutputDecoder(r)}
+}
+
+func (r *jsonReader) Read() (*Record, error) {
+	item, err := r.dec.Next()
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("reading JSON graph data: %s", err)
+	}
+	return &Record{Def: item.Def, Ref: item.Ref, Doc: item.Doc, Alias: item.Alias, TypeRelation: item.TypeRelation}, nil
+}
+
+// jsonWriter writes a JSON-encoded grapher.Output. Records must be written
+// in the order of the sections they belong in (defs, then refs, and so on),
+// as they are read from JSON graph data.
+type jsonWriter struct {
+	w       *bufio.Writer
+	section int  // index in jsonSections of the array being written, or -1
+	items   bool // whether any items have been written in the section
+	err     error
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: bufio.NewWriter(w), section: -1}
+}
+
+func (w *jsonWriter) Write(rec *Record) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+	if w.err != nil {
+		return w.err
+	}
+	section := rec.section()
+	if section < w.section {
+		return fmt.Errorf("can't write %s after %s in JSON graph data", strings.ToLower(jsonSections[section]), strings.ToLower(jsonSections[w.section]))
+	}
+	if section != w.section {
+		if w.section == -1 {
+			w.write("{")
+		} else {
+			w.write("],")
+		}
+		w.write(`"` + jsonSections[section] + `":[`)
+		w.section, w.items = section, false
+	}
+	if w.items {
+		w.write(",")
+	}
+	var v interface{}
+	switch section {
+	case 0:
+		v = rec.Def
+	case 1:
+		v = rec.Ref
+	case 2:
+		v = rec.Doc
+	case 3:
+		v = rec.Alias
+	case 4:
+		v = rec.TypeRelation
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(data); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.items = true
+	return w.err
+}
+
+func (w *jsonWriter) write(s string) {
+	if _, err := w.w.WriteString(s); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *jsonWriter) Close() error {
+	if w.section == -1 {
+		w.write("{}\n")
+	} else {
+		w.write("]}\n")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// ndjsonReader reads one JSON-encoded Record per line.
+type ndjsonReader struct {
+	dec *json.Decoder
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	return &ndjsonReader{dec: json.NewDecoder(bufio.NewReader(r))}
+}
+
+func (r *ndjsonReader) Read() (*Record, error) {
+	var rec Record
+	if err := r.dec.Decode(&rec); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("reading NDJSON graph data: %s", err)
+	}
+	if err := rec.check(); err != nil {
+		return nil, fmt.Errorf("reading NDJSON graph data: %s", err)
+	}
+	return &rec, nil
+}
+
+// ndjsonWriter writes one JSON-encoded Record per line.
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *ndjsonWriter) Write(rec *Record) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+	return w.enc.Encode(rec)
+}
+
+func (w *ndjsonWriter) Close() error { return w.w.Flush() }
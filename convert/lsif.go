@@ -0,0 +1,72 @@
+package convert
+
+import (
+	"io"
+
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/lsif"
+)
+
+// lsifReader reads an LSIF dump. Because an LSIF dump is a graph whose
+// elements may refer to elements later in the dump, the whole dump is read
+// and converted before the first record is returned.
+type lsifReader struct {
+	out  *grapher.Output
+	next int
+}
+
+func newLSIFReader(r io.Reader, opt Options) (*lsifReader, error) {
+	dump, err := lsif.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	_, out, err := dump.Convert(dump.ProjectDir(opt.RootURI), opt.ReadFile)
+	if err != nil {
+		return nil, err
+	}
+	return &lsifReader{out: out}, nil
+}
+
+func (r *lsifReader) Read() (*Record, error) {
+	i := r.next
+	r.next++
+	if i < len(r.out.Defs) {
+		return &Record{Def: r.out.Defs[i]}, nil
+	}
+	i -= len(r.out.Defs)
+	if i < len(r.out.Refs) {
+		return &Record{Ref: r.out.Refs[i]}, nil
+	}
+	i -= len(r.out.Refs)
+	if i < len(r.out.Docs) {
+		return &Record{Doc: r.out.Docs[i]}, nil
+	}
+	return nil, io.EOF
+}
+
+// lsifWriter writes an LSIF dump. LSIF has no equivalent of aliases or
+// type relations, so they are skipped.
+type lsifWriter struct {
+	w *lsif.Writer
+}
+
+func newLSIFWriter(w io.Writer, opt Options) *lsifWriter {
+	return &lsifWriter{w: lsif.NewWriter(w, opt.RootURI, opt.ReadFile)}
+}
+
+func (w *lsifWriter) Write(rec *Record) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+	switch {
+	case rec.Def != nil:
+		return w.w.WriteDef(rec.Def)
+	case rec.Ref != nil:
+		return w.w.WriteRef(rec.Ref)
+	case rec.Doc != nil:
+		return w.w.WriteDoc(rec.Doc)
+	}
+	return nil
+}
+
+func (w *lsifWriter) Close() error { return w.w.Close() }
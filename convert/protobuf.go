@@ -0,0 +1,512 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jmoiron/sqlx/types"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// maxProtobufMessageSize is the size of the largest Record message that
+// will be read, to guard against reading a corrupt length prefix.
+const maxProtobufMessageSize = 64 << 20
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoBuf is an encoded protobuf message. Its methods append fields to it,
+// omitting fields with zero values (as proto3 does).
+type protoBuf []byte
+
+func (b *protoBuf) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	*b = append(*b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (b *protoBuf) tag(num, wire int) { b.varint(uint64(num)<<3 | uint64(wire)) }
+
+func (b *protoBuf) bytes(num int, data []byte) {
+	if len(data) > 0 {
+		b.tag(num, wireBytes)
+		b.varint(uint64(len(data)))
+		*b = append(*b, data...)
+	}
+}
+
+func (b *protoBuf) string(num int, s string) {
+	if s != "" {
+		b.tag(num, wireBytes)
+		b.varint(uint64(len(s)))
+		*b = append(*b, s...)
+	}
+}
+
+func (b *protoBuf) int(num int, v int64) {
+	if v != 0 {
+		b.tag(num, wireVarint)
+		b.varint(uint64(v))
+	}
+}
+
+func (b *protoBuf) bool(num int, v bool) {
+	if v {
+		b.tag(num, wireVarint)
+		b.varint(1)
+	}
+}
+
+func (b *protoBuf) double(num int, v float64) {
+	if v != 0 {
+		b.tag(num, wireFixed64)
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+		*b = append(*b, tmp[:]...)
+	}
+}
+
+// message appends an embedded message field, even if the message is empty
+// (so that the field's presence is recorded).
+func (b *protoBuf) message(num int, m protoBuf) {
+	b.tag(num, wireBytes)
+	b.varint(uint64(len(m)))
+	*b = append(*b, m...)
+}
+
+// protoFields calls f with the number and value of each field of the
+// encoded message. The value of a varint or fixed-size field is v, and
+// that of a length-delimited field is data.
+func protoFields(b []byte, f func(num int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+		num, wire := int(key>>3), int(key&7)
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("invalid varint in field %d", num)
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return fmt.Errorf("truncated field %d", num)
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return fmt.Errorf("truncated field %d", num)
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return fmt.Errorf("truncated field %d", num)
+			}
+			data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported wire type %d in field %d", wire, num)
+		}
+		if err := f(num, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protobufReader reads length-delimited Record messages.
+type protobufReader struct {
+	r *bufio.Reader
+}
+
+func newProtobufReader(r io.Reader) *protobufReader {
+	return &protobufReader{r: bufio.NewReader(r)}
+}
+
+func (r *protobufReader) Read() (*Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("reading protobuf graph data: %s", err)
+	}
+	if size > maxProtobufMessageSize {
+		return nil, fmt.Errorf("reading protobuf graph data: message size %d exceeds maximum %d", size, maxProtobufMessageSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading protobuf graph data: %s", err)
+	}
+	rec, err := decodeRecord(msg)
+	if err != nil {
+		return nil, fmt.Errorf("reading protobuf graph data: %s", err)
+	}
+	return rec, nil
+}
+
+func decodeRecord(msg []byte) (*Record, error) {
+	var rec Record
+	err := protoFields(msg, func(num int, _ uint64, data []byte) error {
+		var err error
+		switch num {
+		case 1:
+			rec.Def, err = decodeDef(data)
+		case 2:
+			rec.Ref, err = decodeRef(data)
+		case 3:
+			rec.Doc, err = decodeDoc(data)
+		case 4:
+			rec.Alias, err = decodeAlias(data)
+		case 5:
+			rec.TypeRelation, err = decodeTypeRelation(data)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := rec.check(); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func decodeDef(msg []byte) (*graph.Def, error) {
+	var d graph.Def
+	return &d, protoFields(msg, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			d.Repo = repo.URI(data)
+		case 2:
+			d.CommitID = string(data)
+		case 3:
+			d.UnitType = string(data)
+		case 4:
+			d.Unit = string(data)
+		case 5:
+			d.Path = graph.DefPath(data)
+		case 6:
+			d.TreePath = graph.TreePath(data)
+		case 7:
+			d.Kind = graph.DefKind(data)
+		case 8:
+			d.Name = string(data)
+		case 9:
+			d.Callable = v != 0
+		case 10:
+			d.File = string(data)
+		case 11:
+			d.DefStart = int(v)
+		case 12:
+			d.DefEnd = int(v)
+		case 13:
+			d.Exported = v != 0
+		case 14:
+			d.Test = v != 0
+		case 15:
+			d.Local = v != 0
+		case 16:
+			d.Anonymous = v != 0
+		case 17:
+			d.Snippet = string(data)
+		case 18:
+			d.Data = types.JsonText(data)
+		case 19:
+			d.SID = graph.SID(v)
+		}
+		return nil
+	})
+}
+
+func decodeRef(msg []byte) (*graph.Ref, error) {
+	var r graph.Ref
+	return &r, protoFields(msg, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			r.DefRepo = repo.URI(data)
+		case 2:
+			r.DefUnitType = string(data)
+		case 3:
+			r.DefUnit = string(data)
+		case 4:
+			r.DefPath = graph.DefPath(data)
+		case 5:
+			r.Def = v != 0
+		case 6:
+			r.Kind = graph.RefKind(data)
+		case 7:
+			r.Repo = repo.URI(data)
+		case 8:
+			r.CommitID = string(data)
+		case 9:
+			r.UnitType = string(data)
+		case 10:
+			r.Unit = string(data)
+		case 11:
+			r.File = string(data)
+		case 12:
+			r.Start = int(v)
+		case 13:
+			r.End = int(v)
+		case 14:
+			c, err := decodeRefCandidate(data)
+			if err != nil {
+				return err
+			}
+			r.Candidates = append(r.Candidates, c)
+		}
+		return nil
+	})
+}
+
+func decodeRefCandidate(msg []byte) (*graph.RefCandidate, error) {
+	var c graph.RefCandidate
+	return &c, protoFields(msg, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			c.DefRepo = repo.URI(data)
+		case 2:
+			c.DefUnitType = string(data)
+		case 3:
+			c.DefUnit = string(data)
+		case 4:
+			c.DefPath = graph.DefPath(data)
+		case 5:
+			c.Score = math.Float64frombits(v)
+		}
+		return nil
+	})
+}
+
+func decodeDoc(msg []byte) (*graph.Doc, error) {
+	var d graph.Doc
+	return &d, protoFields(msg, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			d.Repo = repo.URI(data)
+		case 2:
+			d.CommitID = string(data)
+		case 3:
+			d.UnitType = string(data)
+		case 4:
+			d.Unit = string(data)
+		case 5:
+			d.Path = graph.DefPath(data)
+		case 6:
+			d.Format = string(data)
+		case 7:
+			d.Data = string(data)
+		case 8:
+			d.File = string(data)
+		case 9:
+			d.Start = int(v)
+		case 10:
+			d.End = int(v)
+		}
+		return nil
+	})
+}
+
+func decodeAlias(msg []byte) (*graph.Alias, error) {
+	var a graph.Alias
+	return &a, protoFields(msg, func(num int, _ uint64, data []byte) error {
+		switch num {
+		case 1:
+			a.Repo = repo.URI(data)
+		case 2:
+			a.UnitType = string(data)
+		case 3:
+			a.Unit = string(data)
+		case 4:
+			a.Path = graph.DefPath(data)
+		case 5:
+			a.DefRepo = repo.URI(data)
+		case 6:
+			a.DefUnitType = string(data)
+		case 7:
+			a.DefUnit = string(data)
+		case 8:
+			a.DefPath = graph.DefPath(data)
+		}
+		return nil
+	})
+}
+
+func decodeTypeRelation(msg []byte) (*graph.TypeRelation, error) {
+	var r graph.TypeRelation
+	return &r, protoFields(msg, func(num int, _ uint64, data []byte) error {
+		switch num {
+		case 1:
+			r.Kind = graph.TypeRelationKind(data)
+		case 2:
+			r.Repo = repo.URI(data)
+		case 3:
+			r.UnitType = string(data)
+		case 4:
+			r.Unit = string(data)
+		case 5:
+			r.Path = graph.DefPath(data)
+		case 6:
+			r.DefRepo = repo.URI(data)
+		case 7:
+			r.DefUnitType = string(data)
+		case 8:
+			r.DefUnit = string(data)
+		case 9:
+			r.DefPath = graph.DefPath(data)
+		}
+		return nil
+	})
+}
+
+// protobufWriter writes length-delimited Record messages.
+type protobufWriter struct {
+	w *bufio.Writer
+}
+
+func newProtobufWriter(w io.Writer) *protobufWriter {
+	return &protobufWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *protobufWriter) Write(rec *Record) error {
+	if err := rec.check(); err != nil {
+		return err
+	}
+	msg := encodeRecord(rec)
+	var size protoBuf
+	size.varint(uint64(len(msg)))
+	if _, err := w.w.Write(size); err != nil {
+		return err
+	}
+	_, err := w.w.Write(msg)
+	return err
+}
+
+func (w *protobufWriter) Close() error { return w.w.Flush() }
+
+func encodeRecord(rec *Record) protoBuf {
+	var b protoBuf
+	switch {
+	case rec.Def != nil:
+		b.message(1, encodeDef(rec.Def))
+	case rec.Ref != nil:
+		b.message(2, encodeRef(rec.Ref))
+	case rec.Doc != nil:
+		b.message(3, encodeDoc(rec.Doc))
+	case rec.Alias != nil:
+		b.message(4, encodeAlias(rec.Alias))
+	case rec.TypeRelation != nil:
+		b.message(5, encodeTypeRelation(rec.TypeRelation))
+	}
+	return b
+}
+
+func encodeDef(d *graph.Def) protoBuf {
+	var b protoBuf
+	b.string(1, string(d.Repo))
+	b.string(2, d.CommitID)
+	b.string(3, d.UnitType)
+	b.string(4, d.Unit)
+	b.string(5, string(d.Path))
+	b.string(6, string(d.TreePath))
+	b.string(7, string(d.Kind))
+	b.string(8, d.Name)
+	b.bool(9, d.Callable)
+	b.string(10, d.File)
+	b.int(11, int64(d.DefStart))
+	b.int(12, int64(d.DefEnd))
+	b.bool(13, d.Exported)
+	b.bool(14, d.Test)
+	b.bool(15, d.Local)
+	b.bool(16, d.Anonymous)
+	b.string(17, d.Snippet)
+	b.bytes(18, d.Data)
+	b.int(19, int64(d.SID))
+	return b
+}
+
+func encodeRef(r *graph.Ref) protoBuf {
+	var b protoBuf
+	b.string(1, string(r.DefRepo))
+	b.string(2, r.DefUnitType)
+	b.string(3, r.DefUnit)
+	b.string(4, string(r.DefPath))
+	b.bool(5, r.Def)
+	b.string(6, string(r.Kind))
+	b.string(7, string(r.Repo))
+	b.string(8, r.CommitID)
+	b.string(9, r.UnitType)
+	b.string(10, r.Unit)
+	b.string(11, r.File)
+	b.int(12, int64(r.Start))
+	b.int(13, int64(r.End))
+	for _, c := range r.Candidates {
+		var cb protoBuf
+		cb.string(1, string(c.DefRepo))
+		cb.string(2, c.DefUnitType)
+		cb.string(3, c.DefUnit)
+		cb.string(4, string(c.DefPath))
+		cb.double(5, c.Score)
+		b.message(14, cb)
+	}
+	return b
+}
+
+func encodeDoc(d *graph.Doc) protoBuf {
+	var b protoBuf
+	b.string(1, string(d.Repo))
+	b.string(2, d.CommitID)
+	b.string(3, d.UnitType)
+	b.string(4, d.Unit)
+	b.string(5, string(d.Path))
+	b.string(6, d.Format)
+	b.string(7, d.Data)
+	b.string(8, d.File)
+	b.int(9, int64(d.Start))
+	b.int(10, int64(d.End))
+	return b
+}
+
+func encodeAlias(a *graph.Alias) protoBuf {
+	var b protoBuf
+	b.string(1, string(a.Repo))
+	b.string(2, a.UnitType)
+	b.string(3, a.Unit)
+	b.string(4, string(a.Path))
+	b.string(5, string(a.DefRepo))
+	b.string(6, a.DefUnitType)
+	b.string(7, a.DefUnit)
+	b.string(8, string(a.DefPath))
+	return b
+}
+
+func encodeTypeRelation(r *graph.TypeRelation) protoBuf {
+	var b protoBuf
+	b.string(1, string(r.Kind))
+	b.string(2, string(r.Repo))
+	b.string(3, r.UnitType)
+	b.string(4, r.Unit)
+	b.string(5, string(r.Path))
+	b.string(6, string(r.DefRepo))
+	b.string(7, r.DefUnitType)
+	b.string(8, r.DefUnit)
+	b.string(9, string(r.DefPath))
+	return b
+}
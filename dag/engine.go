@@ -0,0 +1,493 @@
+// Package dag executes a build plan (a set of makex rules) as a dependency
+// graph of steps. Unlike make, it decides whether a step needs to run by
+// comparing content hashes instead of file modification times: each step is
+// fingerprinted by its commands and the contents of its inputs, and it only
+// runs if that fingerprint (or the content of its output) has changed since
+// the step last ran successfully.
+//
+// Fingerprints are recorded in a state file (see Engine.StateFile) so that
+// they persist across builds.
+package dag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+// An Engine builds goals from a set of rules.
+type Engine struct {
+	// Rules are the build steps. Each rule's target is the (only) output of
+	// the step, and its prereqs are the step's inputs. A prereq that isn't
+	// the target of another rule must be an existing file. A rule with no
+	// recipes is phony (like "all"): it has no output of its own and is done
+	// when its prereqs are.
+	Rules []makex.Rule
+
+	// Goals are the targets to build.
+	Goals []string
+
+	// StateFile is the path of the file that records the fingerprints of
+	// steps that ran successfully. If empty, fingerprints are not persisted
+	// (and all steps run every time).
+	StateFile string
+
+	// ParallelJobs is the maximum number of steps to run concurrently. If it
+	// is 0 or less, steps run one at a time.
+	ParallelJobs int
+
+	// Stdout and Stderr receive the output of the steps' commands. If nil,
+	// os.Stdout and os.Stderr are used.
+	Stdout, Stderr io.Writer
+
+	// Log receives progress messages, if non-nil.
+	Log *log.Logger
+
+	// Verbose causes the commands run by each step (and the steps that are
+	// skipped because they are up to date) to be logged.
+	Verbose bool
+
+	// OnStep, if non-nil, is called when a step (other than a phony one)
+	// starts to run, finishes running, fails (err is the reason, which may
+	// be an *ErrPrereqFailed), or is skipped because it is up to date. It
+	// may be called concurrently.
+	OnStep func(rule makex.Rule, status StepStatus, err error)
+
+	hashes   map[string]string // file content hash cache
+	hashesMu sync.Mutex
+}
+
+// State records the fingerprints of the steps that ran successfully, keyed
+// by target.
+type State map[string]*StepState
+
+// StepState is the recorded result of a step that ran successfully.
+type StepState struct {
+	// Inputs is the fingerprint of the step's commands and inputs when it
+	// ran.
+	Inputs string
+
+	// Output is the content hash of the step's output after it ran.
+	Output string
+}
+
+// ErrPrereqFailed indicates that a step was not run because one of its
+// prereqs failed to build.
+type ErrPrereqFailed struct {
+	Target string
+	Prereq string
+}
+
+func (e *ErrPrereqFailed) Error() string {
+	return fmt.Sprintf("%s: not built because prerequisite %s failed", e.Target, e.Prereq)
+}
+
+// StepStatus is the status of a step reported to Engine.OnStep.
+type StepStatus int
+
+const (
+	StepStarted StepStatus = iota
+	StepSucceeded
+	StepFailed
+	StepUpToDate
+)
+
+func (e *Engine) onStep(rule makex.Rule, status StepStatus, err error) {
+	if e.OnStep != nil {
+		e.OnStep(rule, status, err)
+	}
+}
+
+type node struct {
+	rule makex.Rule
+	deps []*node // prereqs that are targets of other rules
+
+	done chan struct{} // closed when the node is finished (successfully or not)
+	err  error
+}
+
+// graph returns the nodes needed to build e.Goals, in topological order
+// (each node comes after its deps).
+func (e *Engine) graph() ([]*node, error) {
+	rules := make(map[string]makex.Rule, len(e.Rules))
+	for _, r := range e.Rules {
+		rules[r.Target()] = r
+	}
+
+	nodes := make(map[string]*node)
+	visiting := make(map[string]bool)
+	var order []*node
+	var visit func(target string) (*node, error)
+	visit = func(target string) (*node, error) {
+		if n, present := nodes[target]; present {
+			return n, nil
+		}
+		if visiting[target] {
+			return nil, fmt.Errorf("dependency cycle involving %s", target)
+		}
+		r, present := rules[target]
+		if !present {
+			return nil, nil
+		}
+
+		visiting[target] = true
+		n := &node{rule: r, done: make(chan struct{})}
+		for _, p := range r.Prereqs() {
+			dep, err := visit(p)
+			if err != nil {
+				return nil, err
+			}
+			if dep != nil {
+				n.deps = append(n.deps, dep)
+			}
+		}
+		visiting[target] = false
+
+		nodes[target] = n
+		order = append(order, n)
+		return n, nil
+	}
+
+	for _, goal := range e.Goals {
+		n, err := visit(goal)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			if _, err := os.Stat(goal); err != nil {
+				return nil, fmt.Errorf("no rule to make target %q", goal)
+			}
+		}
+	}
+	return order, nil
+}
+
+func isPhony(r makex.Rule) bool { return len(r.Recipes()) == 0 }
+
+// Run builds the goals, running each step whose fingerprint has changed (and
+// the steps that depend on it). Steps that don't depend on a failed step are
+// still run. If any steps fail, an error is returned for each.
+func (e *Engine) Run() error {
+	nodes, err := e.graph()
+	if err != nil {
+		return err
+	}
+	state, err := e.readState()
+	if err != nil {
+		return err
+	}
+	var stateMu sync.Mutex
+
+	jobs := e.ParallelJobs
+	if jobs <= 0 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			defer close(n.done)
+			for _, dep := range n.deps {
+				<-dep.done
+				if dep.err != nil {
+					n.err = &ErrPrereqFailed{Target: n.rule.Target(), Prereq: dep.rule.Target()}
+					if !isPhony(n.rule) {
+						e.onStep(n.rule, StepFailed, n.err)
+					}
+					return
+				}
+			}
+			if isPhony(n.rule) {
+				return
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			stateMu.Lock()
+			prev := state[n.rule.Target()]
+			stateMu.Unlock()
+
+			st, err := e.build(n.rule, prev)
+			if err != nil {
+				n.err = err
+				return
+			}
+			stateMu.Lock()
+			state[n.rule.Target()] = st
+			stateMu.Unlock()
+		}(n)
+	}
+	wg.Wait()
+
+	// Save the state even if some steps failed, so that the steps that
+	// succeeded don't need to run again.
+	if err := e.writeState(state); err != nil {
+		return err
+	}
+
+	var errs util.Errors
+	for _, n := range nodes {
+		if _, isPrereqErr := n.err.(*ErrPrereqFailed); n.err != nil && !isPrereqErr {
+			errs = append(errs, n.err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// build runs the step for rule unless it is up to date (according to prev).
+// It returns the new state of the step.
+func (e *Engine) build(rule makex.Rule, prev *StepState) (*StepState, error) {
+	target := rule.Target()
+	fp, err := e.fingerprint(rule)
+	if err != nil {
+		e.onStep(rule, StepFailed, err)
+		return nil, err
+	}
+	if prev != nil && prev.Inputs == fp {
+		if out, err := e.hashFile(target, true); err == nil && out == prev.Output {
+			if e.Verbose && e.Log != nil {
+				e.Log.Printf("%s is up to date.", target)
+			}
+			e.onStep(rule, StepUpToDate, nil)
+			return prev, nil
+		}
+	}
+
+	e.onStep(rule, StepStarted, nil)
+	st, err := e.run(rule, fp)
+	if err != nil {
+		e.onStep(rule, StepFailed, err)
+	} else {
+		e.onStep(rule, StepSucceeded, nil)
+	}
+	return st, err
+}
+
+// run runs the step for rule, whose fingerprint is fp, and returns its new
+// state.
+func (e *Engine) run(rule makex.Rule, fp string) (*StepState, error) {
+	target := rule.Target()
+	if e.Log != nil {
+		e.Log.Printf("Building %s", target)
+	}
+	if dir := filepath.Dir(target); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	for _, recipe := range rule.Recipes() {
+		cmdStr := makex.ExpandAutoVars(rule, recipe)
+		if e.Verbose && e.Log != nil {
+			e.Log.Printf("  $ %s", cmdStr)
+		}
+		cmd := exec.Command("sh", "-c", cmdStr)
+		cmd.Stdout, cmd.Stderr = e.Stdout, e.Stderr
+		if cmd.Stdout == nil {
+			cmd.Stdout = os.Stdout
+		}
+		if cmd.Stderr == nil {
+			cmd.Stderr = os.Stderr
+		}
+		if err := cmd.Run(); err != nil {
+			// Like make's .DELETE_ON_ERROR, don't leave a partial target
+			// that might be mistaken for a complete one.
+			os.Remove(target)
+			return nil, fmt.Errorf("%s: command failed: %s (command was: %s)", target, err, cmdStr)
+		}
+	}
+
+	out, err := e.hashFile(target, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: step did not produce its target: %s", target, err)
+	}
+	return &StepState{Inputs: fp, Output: out}, nil
+}
+
+// fingerprint returns the hash of the rule's commands and the contents of its
+// prereqs.
+func (e *Engine) fingerprint(rule makex.Rule) (string, error) {
+	h := sha256.New()
+	for _, recipe := range rule.Recipes() {
+		fmt.Fprintf(h, "recipe %q\n", makex.ExpandAutoVars(rule, recipe))
+	}
+	for _, p := range rule.Prereqs() {
+		ph, err := e.hashFile(p, true)
+		if err != nil {
+			return "", fmt.Errorf("%s: prerequisite %s: %s", rule.Target(), p, err)
+		}
+		fmt.Fprintf(h, "prereq %q %s\n", p, ph)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile returns the SHA-256 hash of the file's contents. If useCache is
+// true, a previously computed hash is returned (if any); otherwise the file
+// is rehashed and the cache is updated.
+func (e *Engine) hashFile(path string, useCache bool) (string, error) {
+	e.hashesMu.Lock()
+	if e.hashes == nil {
+		e.hashes = make(map[string]string)
+	}
+	h, present := e.hashes[path]
+	e.hashesMu.Unlock()
+	if present && useCache {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hh := sha256.New()
+	if _, err := io.Copy(hh, f); err != nil {
+		return "", err
+	}
+	h = hex.EncodeToString(hh.Sum(nil))
+
+	e.hashesMu.Lock()
+	e.hashes[path] = h
+	e.hashesMu.Unlock()
+	return h, nil
+}
+
+// Needed returns the targets (in build order) of the steps that are needed
+// to build the goals, whether or not they are stale. It doesn't run any
+// steps.
+func (e *Engine) Needed() ([]string, error) {
+	nodes, err := e.graph()
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, n := range nodes {
+		if !isPhony(n.rule) {
+			targets = append(targets, n.rule.Target())
+		}
+	}
+	return targets, nil
+}
+
+// Stale returns the targets (in build order) of the steps that Run would run.
+// It doesn't run any steps. A step is stale if its fingerprint or output has
+// changed since it last ran, or if any of its prereqs is stale.
+func (e *Engine) Stale() ([]string, error) {
+	nodes, err := e.graph()
+	if err != nil {
+		return nil, err
+	}
+	state, err := e.readState()
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make(map[*node]bool)
+	var targets []string
+	for _, n := range nodes {
+		for _, dep := range n.deps {
+			if stale[dep] {
+				stale[n] = true
+			}
+		}
+		if !stale[n] && !isPhony(n.rule) {
+			stale[n] = !e.upToDate(n.rule, state[n.rule.Target()])
+		}
+		if stale[n] && !isPhony(n.rule) {
+			targets = append(targets, n.rule.Target())
+		}
+	}
+	return targets, nil
+}
+
+func (e *Engine) upToDate(rule makex.Rule, prev *StepState) bool {
+	if prev == nil {
+		return false
+	}
+	if fp, err := e.fingerprint(rule); err != nil || fp != prev.Inputs {
+		return false
+	}
+	out, err := e.hashFile(rule.Target(), true)
+	return err == nil && out == prev.Output
+}
+
+// DryRun writes the commands that Run would run to w, without running them.
+func (e *Engine) DryRun(w io.Writer) error {
+	targets, err := e.Stale()
+	if err != nil {
+		return err
+	}
+	rules := make(map[string]makex.Rule, len(e.Rules))
+	for _, r := range e.Rules {
+		rules[r.Target()] = r
+	}
+	for _, target := range targets {
+		r := rules[target]
+		for _, recipe := range r.Recipes() {
+			if _, err := fmt.Fprintln(w, makex.ExpandAutoVars(r, recipe)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) readState() (State, error) {
+	state := make(State)
+	if e.StateFile == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(e.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		// A corrupt state file just means that everything is rebuilt.
+		if e.Log != nil {
+			e.Log.Printf("Warning: ignoring invalid build state file %s: %s.", e.StateFile, err)
+		}
+		return make(State), nil
+	}
+	return state, nil
+}
+
+func (e *Engine) writeState(state State) error {
+	if e.StateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.StateFile), 0700); err != nil {
+		return err
+	}
+
+	// Write atomically so that an interrupted build doesn't corrupt the
+	// state file.
+	tmp := e.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.StateFile)
+}
@@ -0,0 +1,144 @@
+package dag
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/makex"
+)
+
+func TestEngine(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-dag-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	path := func(name string) string { return filepath.Join(tmpdir, name) }
+	writeFile := func(name, data string) {
+		if err := ioutil.WriteFile(path(name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := func() []string {
+		data, _ := ioutil.ReadFile(path("log"))
+		return strings.Fields(string(data))
+	}
+
+	writeFile("src", "1")
+	e := &Engine{
+		Rules: []makex.Rule{
+			&makex.BasicRule{TargetFile: "all", PrereqFiles: []string{path("b")}},
+			&makex.BasicRule{TargetFile: path("a"), PrereqFiles: []string{path("src")}, RecipeCmds: []string{"cut -c1 " + path("src") + " > " + path("a") + " && echo a >> " + path("log")}},
+			&makex.BasicRule{TargetFile: path("b"), PrereqFiles: []string{path("a")}, RecipeCmds: []string{"cat " + path("a") + " > " + path("b") + " && echo b >> " + path("log")}},
+		},
+		Goals:     []string{"all"},
+		StateFile: path("state.json"),
+	}
+	build := func(wantRuns ...string) {
+		os.Remove(path("log"))
+		e.hashes = nil
+		if err := e.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := runs(); strings.Join(got, " ") != strings.Join(wantRuns, " ") {
+			t.Errorf("got steps %v run, want %v", got, wantRuns)
+		}
+	}
+
+	build("a", "b")
+
+	// Nothing changed.
+	build()
+
+	// Touching a file without changing its contents doesn't cause a rebuild.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path("src"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	build()
+
+	// Changing the contents of src reruns a, but b is not rerun because a's
+	// output is the same as before (a only uses the first character of src).
+	writeFile("src", "12")
+	build("a")
+
+	// Modifying an output causes its step to run again.
+	writeFile("b", "x")
+	build("b")
+
+	e.hashes = nil
+	if stale, err := e.Stale(); err != nil {
+		t.Fatal(err)
+	} else if len(stale) != 0 {
+		t.Errorf("got stale targets %v, want none", stale)
+	}
+	writeFile("src", "3")
+	e.hashes = nil
+	if stale, err := e.Stale(); err != nil {
+		t.Fatal(err)
+	} else if want := []string{path("a"), path("b")}; strings.Join(stale, " ") != strings.Join(want, " ") {
+		t.Errorf("got stale targets %v, want %v", stale, want)
+	}
+}
+
+func TestEngine_failure(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-dag-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	a, b, c := filepath.Join(tmpdir, "a"), filepath.Join(tmpdir, "b"), filepath.Join(tmpdir, "c")
+
+	e := &Engine{
+		Rules: []makex.Rule{
+			&makex.BasicRule{TargetFile: "all", PrereqFiles: []string{b, c}},
+			&makex.BasicRule{TargetFile: a, RecipeCmds: []string{"echo partial > " + a + " && false"}},
+			&makex.BasicRule{TargetFile: b, PrereqFiles: []string{a}, RecipeCmds: []string{"touch " + b}},
+			&makex.BasicRule{TargetFile: c, RecipeCmds: []string{"touch " + c}},
+		},
+		Goals:        []string{"all"},
+		ParallelJobs: 2,
+		Stderr:       ioutil.Discard,
+	}
+	var mu sync.Mutex
+	statuses := make(map[string][]StepStatus)
+	e.OnStep = func(rule makex.Rule, status StepStatus, err error) {
+		if (status == StepFailed) != (err != nil) {
+			t.Errorf("%s: got status %d with error %v", rule.Target(), status, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[rule.Target()] = append(statuses[rule.Target()], status)
+	}
+	err = e.Run()
+	if err == nil {
+		t.Fatal("got no error, want error")
+	}
+	if strings.Contains(err.Error(), "prerequisite") {
+		t.Errorf("got error %q, want only the failed step's error", err)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Errorf("failed step's target %s still exists", a)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("step %s that depends on a failed step ran", b)
+	}
+	if _, err := os.Stat(c); err != nil {
+		t.Errorf("independent step %s didn't run: %s", c, err)
+	}
+
+	want := map[string][]StepStatus{
+		a: {StepStarted, StepFailed},
+		b: {StepFailed},
+		c: {StepStarted, StepSucceeded},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got step statuses %v, want %v", statuses, want)
+	}
+}
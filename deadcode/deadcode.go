@@ -0,0 +1,138 @@
+// Package deadcode finds defs that are never referenced, using the refs in
+// the graph output of a repository's source units.
+package deadcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// A Unit is the graph output of a source unit. Defs and refs in the output
+// whose unit fields are empty are in this source unit.
+type Unit struct {
+	Type string
+	Name string
+	*grapher.Output
+}
+
+// An Allowlist is a list of def path patterns (in the syntax of path.Match)
+// for defs that should never be reported as unreferenced, such as entry
+// points that are only called by external code.
+type Allowlist []string
+
+// ReadAllowlist reads an allowlist containing one pattern per line. Blank
+// lines and lines starting with "#" are ignored.
+func ReadAllowlist(r io.Reader) (Allowlist, error) {
+	var a Allowlist
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowlist pattern %q: %s", line, err)
+		}
+		a = append(a, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Allows reports whether def's path matches a pattern in the allowlist.
+func (a Allowlist) Allows(def *graph.Def) bool {
+	for _, pat := range a {
+		if ok, _ := path.Match(pat, string(def.Path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the non-test defs in the units (which are in the repository
+// repoURI) that have no references from non-test code in any of the units,
+// other than those allowed by the allowlist. A ref is in test code if it is
+// in a file that defines test defs. A def's references to itself (e.g.,
+// recursive calls) don't count. The returned defs have complete DefKeys and
+// are sorted.
+func Find(repoURI repo.URI, units []*Unit, allow Allowlist) []*graph.Def {
+	defs := make(map[graph.DefKey]*graph.Def)
+	testFiles := make(map[string]bool)
+	for _, u := range units {
+		for _, def := range u.Defs {
+			def2 := *def
+			if def2.Repo == "" {
+				def2.Repo = repoURI
+			}
+			if def2.UnitType == "" && def2.Unit == "" {
+				def2.UnitType, def2.Unit = u.Type, u.Name
+			}
+			defs[def2.DefKey] = &def2
+			if def.Test {
+				testFiles[def.File] = true
+			}
+		}
+	}
+
+	referenced := make(map[graph.DefKey]bool)
+	for _, u := range units {
+		defKey := func(r repo.URI, unitType, unit string, p graph.DefPath) graph.DefKey {
+			if r == "" {
+				r = repoURI
+			}
+			if unitType == "" && unit == "" {
+				unitType, unit = u.Type, u.Name
+			}
+			return graph.DefKey{Repo: r, UnitType: unitType, Unit: unit, Path: p}
+		}
+
+		for _, ref := range u.Refs {
+			if ref.Def || testFiles[ref.File] {
+				continue
+			}
+			keys := []graph.DefKey{defKey(ref.DefRepo, ref.DefUnitType, ref.DefUnit, ref.DefPath)}
+			for _, c := range ref.Candidates {
+				// Any candidate of an ambiguous ref may be the def it refers
+				// to.
+				keys = append(keys, defKey(c.DefRepo, c.DefUnitType, c.DefUnit, c.DefPath))
+			}
+			for _, key := range keys {
+				if def := defs[key]; def != nil && def.File == ref.File && def.DefStart <= ref.Start && ref.End <= def.DefEnd {
+					// Self-reference
+					continue
+				}
+				referenced[key] = true
+			}
+		}
+
+		for _, a := range u.Aliases {
+			// The def that an alias refers to is used by the alias.
+			referenced[defKey(a.DefRepo, a.DefUnitType, a.DefUnit, a.DefPath)] = true
+		}
+	}
+
+	var unreferenced []*graph.Def
+	for key, def := range defs {
+		if !def.Test && !referenced[key] && !allow.Allows(def) {
+			unreferenced = append(unreferenced, def)
+		}
+	}
+	sort.Sort(defsByKey(unreferenced))
+	return unreferenced
+}
+
+type defsByKey []*graph.Def
+
+func (vs defsByKey) Len() int           { return len(vs) }
+func (vs defsByKey) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs defsByKey) Less(i, j int) bool { return vs[i].DefKey.Less(vs[j].DefKey) }
@@ -0,0 +1,77 @@
+package deadcode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+)
+
+func TestFind(t *testing.T) {
+	units := []*Unit{
+		{
+			Type: "t", Name: "a",
+			Output: &grapher.Output{
+				Defs: []*graph.Def{
+					{DefKey: graph.DefKey{Path: "Used"}, File: "a.go", DefStart: 0, DefEnd: 10, Exported: true},
+					{DefKey: graph.DefKey{Path: "Unused"}, File: "a.go", DefStart: 10, DefEnd: 20, Exported: true},
+					{DefKey: graph.DefKey{Path: "recursive"}, File: "a.go", DefStart: 20, DefEnd: 30},
+					{DefKey: graph.DefKey{Path: "onlyUsedInTests"}, File: "a.go", DefStart: 30, DefEnd: 40},
+					{DefKey: graph.DefKey{Path: "Main"}, File: "a.go", DefStart: 40, DefEnd: 50, Exported: true},
+					{DefKey: graph.DefKey{Path: "aliased"}, File: "a.go", DefStart: 50, DefEnd: 60},
+					{DefKey: graph.DefKey{Path: "TestX"}, File: "a_test.go", Test: true},
+				},
+				Refs: []*graph.Ref{
+					{DefPath: "Unused", Def: true, File: "a.go", Start: 10, End: 16},
+					{DefPath: "recursive", File: "a.go", Start: 25, End: 29},
+					{DefPath: "onlyUsedInTests", File: "a_test.go", Start: 5, End: 9},
+				},
+				Aliases: []*graph.Alias{
+					{Path: "Alias", DefPath: "aliased"},
+				},
+			},
+		},
+		{
+			Type: "t", Name: "b",
+			Output: &grapher.Output{
+				Defs: []*graph.Def{
+					{DefKey: graph.DefKey{Path: "ambiguous1"}, File: "b.go"},
+					{DefKey: graph.DefKey{Path: "ambiguous2"}, File: "b.go"},
+				},
+				Refs: []*graph.Ref{
+					{DefUnitType: "t", DefUnit: "a", DefPath: "Used", File: "b.go", Start: 100, End: 104},
+					{DefPath: "ambiguous1", File: "b.go", Start: 110, End: 114, Candidates: []*graph.RefCandidate{
+						{RefDefKey: graph.RefDefKey{DefPath: "ambiguous1"}, Score: 0.6},
+						{RefDefKey: graph.RefDefKey{DefPath: "ambiguous2"}, Score: 0.4},
+					}},
+				},
+			},
+		},
+	}
+
+	allow, err := ReadAllowlist(strings.NewReader("# entry points\nMain\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, def := range Find("r", units, allow) {
+		got = append(got, def.DefKey.String())
+	}
+	want := []string{
+		graph.DefKey{Repo: "r", UnitType: "t", Unit: "a", Path: "Unused"}.String(),
+		graph.DefKey{Repo: "r", UnitType: "t", Unit: "a", Path: "onlyUsedInTests"}.String(),
+		graph.DefKey{Repo: "r", UnitType: "t", Unit: "a", Path: "recursive"}.String(),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got unreferenced defs %v, want %v", got, want)
+	}
+}
+
+func TestReadAllowlist_invalid(t *testing.T) {
+	if _, err := ReadAllowlist(strings.NewReader("foo/[")); err == nil {
+		t.Error("got nil error for invalid pattern")
+	}
+}
@@ -0,0 +1,413 @@
+// Package defmap matches the defs of two builds of a repository, so that a
+// def can be followed across commits even when it (or its file) is renamed
+// or moved.
+//
+// Defs are matched first by their keys (unit type, unit, and path). The
+// remaining defs are matched by their kinds and names within files that
+// were renamed, then by the similarity of their source text, and then by
+// their kinds and names anywhere.
+package defmap
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func init() {
+	buildstore.RegisterDataType("defmap", &Map{})
+}
+
+// Filename is the name of the file (in the build data directory for a
+// commit) that the map from the defs of the previous build to the commit's
+// defs is written to.
+const Filename = "build.defmap.json"
+
+// A MatchKind is how an old def was matched to a new def.
+type MatchKind string
+
+const (
+	// ByRename means that the defs have the same kind and name, and are in
+	// a file that was renamed (and no other unmatched defs in the file
+	// do).
+	ByRename MatchKind = "rename"
+
+	// ByContent means that the defs' source text is similar.
+	ByContent MatchKind = "content"
+
+	// ByName means that the defs have the same kind and name, and no other
+	// unmatched defs do.
+	ByName MatchKind = "name"
+)
+
+// A Match is a def whose key changed between two builds.
+type Match struct {
+	Old, New graph.DefKey
+	By       MatchKind
+
+	// Similarity is the similarity (from 0 to 1) of the defs' source text,
+	// for ByContent matches.
+	Similarity float64 `json:",omitempty"`
+}
+
+// A Map maps the defs of an old build to the defs of a new build. Defs with
+// the same key in both builds are the same def; they are only counted (in
+// Unchanged).
+type Map struct {
+	OldCommitID, NewCommitID string
+
+	// RenamedFiles maps the old paths of renamed files to their new paths.
+	RenamedFiles map[string]string `json:",omitempty"`
+
+	Unchanged int
+	Matches   []*Match       // sorted by new key
+	Added     []graph.DefKey // new defs that match no old def, sorted
+	Removed   []graph.DefKey // old defs that match no new def, sorted
+}
+
+// Old returns the key in the old build of the def with the given key in the
+// new build, and whether the def was in the old build.
+func (m *Map) Old(key graph.DefKey) (graph.DefKey, bool) {
+	key = mapKey(key)
+	for _, a := range m.Added {
+		if a == key {
+			return graph.DefKey{}, false
+		}
+	}
+	for _, mt := range m.Matches {
+		if mt.New == key {
+			return mt.Old, true
+		}
+	}
+	return key, true
+}
+
+// New returns the key in the new build of the def with the given key in the
+// old build, and whether the def is in the new build.
+func (m *Map) New(key graph.DefKey) (graph.DefKey, bool) {
+	key = mapKey(key)
+	for _, r := range m.Removed {
+		if r == key {
+			return graph.DefKey{}, false
+		}
+	}
+	for _, mt := range m.Matches {
+		if mt.Old == key {
+			return mt.New, true
+		}
+	}
+	return key, true
+}
+
+// A HistoryEntry is the key of a def at a commit.
+type HistoryEntry struct {
+	CommitID string
+	Def      graph.DefKey
+
+	// By is how the def was matched to its key at the next (older)
+	// entry's commit, if its key changed.
+	By MatchKind `json:",omitempty"`
+}
+
+// History follows the def with the given key at a commit back through the
+// maps returned by readMap, which returns the map whose NewCommitID is the
+// given commit (or nil if there is none). It returns the def's key at each
+// commit, newest first, ending at the commit that added the def (or that
+// has no map).
+func History(commitID string, key graph.DefKey, readMap func(commitID string) (*Map, error)) ([]*HistoryEntry, error) {
+	key = mapKey(key)
+	history := []*HistoryEntry{{CommitID: commitID, Def: key}}
+	seen := map[string]bool{commitID: true}
+	for {
+		m, err := readMap(commitID)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil || seen[m.OldCommitID] {
+			return history, nil
+		}
+		oldKey, present := m.Old(key)
+		if !present {
+			return history, nil
+		}
+		if oldKey != key {
+			for _, mt := range m.Matches {
+				if mt.New == key {
+					history[len(history)-1].By = mt.By
+				}
+			}
+		}
+		commitID, key = m.OldCommitID, oldKey
+		seen[commitID] = true
+		history = append(history, &HistoryEntry{CommitID: commitID, Def: key})
+	}
+}
+
+// A Build is the defs of a build of a repository.
+type Build struct {
+	CommitID string
+
+	// Defs are the build's defs, with their UnitType and Unit set. Local
+	// defs are ignored.
+	Defs []*graph.Def
+
+	// ReadFile returns the contents of a file (given its path relative to
+	// the repository root) at the build's commit. If it is nil, defs whose
+	// keys changed are only matched by name.
+	ReadFile func(file string) ([]byte, error)
+}
+
+// MinSimilarity is the minimum similarity of the source text of two defs
+// for them to be matched by content.
+const MinSimilarity = 0.8
+
+// minTokens is the minimum number of tokens in the source text of a def for
+// it to be matched by content. Shorter defs (such as most variables) are
+// too similar to each other to be matched reliably.
+const minTokens = 8
+
+// Compute returns the map from the defs of the old build to the defs of the
+// new build. The renames map the old paths of files (relative to the
+// repository root) that were renamed between the builds' commits to their
+// new paths, as detected by the VCS. Defs in renamed files are matched
+// before looking at the defs' contents, so that they aren't treated as
+// removed and added.
+func Compute(old, new *Build, renames map[string]string) *Map {
+	m := &Map{OldCommitID: old.CommitID, NewCommitID: new.CommitID}
+	if len(renames) > 0 {
+		m.RenamedFiles = renames
+	}
+
+	oldDefs, newDefs := defsByKey(old.Defs), defsByKey(new.Defs)
+	var unmatchedOld, unmatchedNew []*graph.Def
+	for key, d := range newDefs {
+		if _, present := oldDefs[key]; present {
+			m.Unchanged++
+		} else {
+			unmatchedNew = append(unmatchedNew, d)
+		}
+	}
+	for key, d := range oldDefs {
+		if _, present := newDefs[key]; !present {
+			unmatchedOld = append(unmatchedOld, d)
+		}
+	}
+	sort.Sort(defsByKeyString(unmatchedOld))
+	sort.Sort(defsByKeyString(unmatchedNew))
+
+	matchedOld, matchedNew := map[*graph.Def]bool{}, map[*graph.Def]bool{}
+	addMatch := func(o, n *graph.Def, by MatchKind, similarity float64) {
+		matchedOld[o], matchedNew[n] = true, true
+		m.Matches = append(m.Matches, &Match{Old: mapKey(o.DefKey), New: mapKey(n.DefKey), By: by, Similarity: similarity})
+	}
+
+	// Match defs in renamed files whose kind and name are unique among the
+	// unmatched defs in the file.
+	if len(renames) > 0 {
+		oldFiles := make(map[string]string, len(renames))
+		for oldFile, newFile := range renames {
+			oldFiles[newFile] = oldFile
+		}
+		oldByFile, newByFile := byFileKindName(unmatchedOld), byFileKindName(unmatchedNew)
+		for _, n := range unmatchedNew {
+			oldFile, renamed := oldFiles[n.File]
+			if !renamed || n.Name == "" {
+				continue
+			}
+			olds := oldByFile[fileKindName{oldFile, n.Kind, n.Name}]
+			if len(olds) == 1 && len(newByFile[fileKindName{n.File, n.Kind, n.Name}]) == 1 {
+				addMatch(olds[0], n, ByRename, 0)
+			}
+		}
+	}
+
+	// Match by content, most similar pairs first.
+	if old.ReadFile != nil && new.ReadFile != nil {
+		oldTokens := defTokens(unmatchedOld, old.ReadFile)
+		newTokens := defTokens(unmatchedNew, new.ReadFile)
+		var candidates []candidate
+		for _, o := range unmatchedOld {
+			for _, n := range unmatchedNew {
+				if o.Kind != n.Kind || oldTokens[o] == nil || newTokens[n] == nil {
+					continue
+				}
+				if s := similarity(oldTokens[o], newTokens[n]); s >= MinSimilarity {
+					candidates = append(candidates, candidate{o, n, s})
+				}
+			}
+		}
+		sort.Stable(candidatesBySimilarity(candidates))
+		for _, c := range candidates {
+			if !matchedOld[c.o] && !matchedNew[c.n] {
+				addMatch(c.o, c.n, ByContent, c.similarity)
+			}
+		}
+	}
+
+	// Match the remaining defs whose kind and name are unique among them.
+	byKindName := func(defs []*graph.Def, matched map[*graph.Def]bool) map[fileKindName][]*graph.Def {
+		ds := make(map[fileKindName][]*graph.Def)
+		for _, d := range defs {
+			if !matched[d] && d.Name != "" {
+				k := fileKindName{kind: d.Kind, name: d.Name}
+				ds[k] = append(ds[k], d)
+			}
+		}
+		return ds
+	}
+	oldByName, newByName := byKindName(unmatchedOld, matchedOld), byKindName(unmatchedNew, matchedNew)
+	for _, n := range unmatchedNew {
+		if matchedNew[n] || n.Name == "" {
+			continue
+		}
+		k := fileKindName{kind: n.Kind, name: n.Name}
+		if olds := oldByName[k]; len(olds) == 1 && len(newByName[k]) == 1 {
+			addMatch(olds[0], n, ByName, 0)
+		}
+	}
+
+	for _, d := range unmatchedNew {
+		if !matchedNew[d] {
+			m.Added = append(m.Added, mapKey(d.DefKey))
+		}
+	}
+	for _, d := range unmatchedOld {
+		if !matchedOld[d] {
+			m.Removed = append(m.Removed, mapKey(d.DefKey))
+		}
+	}
+	sort.Sort(matchesByNewKey(m.Matches))
+	return m
+}
+
+// A fileKindName identifies the defs with a kind and name (in a file).
+type fileKindName struct {
+	file string
+	kind graph.DefKind
+	name string
+}
+
+func byFileKindName(defs []*graph.Def) map[fileKindName][]*graph.Def {
+	ds := make(map[fileKindName][]*graph.Def)
+	for _, d := range defs {
+		if d.Name != "" {
+			k := fileKindName{d.File, d.Kind, d.Name}
+			ds[k] = append(ds[k], d)
+		}
+	}
+	return ds
+}
+
+// mapKey returns the def key with only the fields that identify a def
+// within a build.
+func mapKey(key graph.DefKey) graph.DefKey {
+	return graph.DefKey{UnitType: key.UnitType, Unit: key.Unit, Path: key.Path}
+}
+
+func defsByKey(defs []*graph.Def) map[graph.DefKey]*graph.Def {
+	m := make(map[graph.DefKey]*graph.Def, len(defs))
+	for _, d := range defs {
+		if !d.Local {
+			m[mapKey(d.DefKey)] = d
+		}
+	}
+	return m
+}
+
+// defTokens returns the tokens of the source text of each def (or nil if
+// the text can't be read or is too short).
+func defTokens(defs []*graph.Def, readFile func(string) ([]byte, error)) map[*graph.Def]map[string]int {
+	files := make(map[string][]byte)
+	tokens := make(map[*graph.Def]map[string]int, len(defs))
+	for _, d := range defs {
+		if d.File == "" {
+			continue
+		}
+		data, present := files[d.File]
+		if !present {
+			data, _ = readFile(d.File) // unreadable files are not matched by content
+			files[d.File] = data
+		}
+		if d.DefStart < 0 || d.DefEnd > len(data) || d.DefStart >= d.DefEnd {
+			continue
+		}
+		if ts, n := tokenize(string(data[d.DefStart:d.DefEnd])); n >= minTokens {
+			tokens[d] = ts
+		}
+	}
+	return tokens
+}
+
+// tokenize returns the number of times each token (a word or punctuation
+// character) occurs in text, and the total number of tokens.
+func tokenize(text string) (map[string]int, int) {
+	tokens := make(map[string]int)
+	var n int
+	for _, f := range strings.FieldsFunc(text, unicode.IsSpace) {
+		start := 0
+		for i, r := range f {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+				continue
+			}
+			if i > start {
+				tokens[f[start:i]]++
+				n++
+			}
+			tokens[string(r)]++
+			n++
+			start = i + len(string(r))
+		}
+		if start < len(f) {
+			tokens[f[start:]]++
+			n++
+		}
+	}
+	return tokens, n
+}
+
+// similarity returns the Dice coefficient of the token multisets a and b.
+func similarity(a, b map[string]int) float64 {
+	var common, total int
+	for t, na := range a {
+		total += na
+		if nb := b[t]; nb < na {
+			common += nb
+		} else {
+			common += na
+		}
+	}
+	for _, nb := range b {
+		total += nb
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// A candidate is a pair of defs that may be matched by content.
+type candidate struct {
+	o, n       *graph.Def
+	similarity float64
+}
+
+type candidatesBySimilarity []candidate
+
+func (v candidatesBySimilarity) Len() int           { return len(v) }
+func (v candidatesBySimilarity) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v candidatesBySimilarity) Less(i, j int) bool { return v[i].similarity > v[j].similarity }
+
+type defsByKeyString []*graph.Def
+
+func (v defsByKeyString) Len() int           { return len(v) }
+func (v defsByKeyString) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v defsByKeyString) Less(i, j int) bool { return v[i].DefKey.Less(v[j].DefKey) }
+
+type matchesByNewKey []*Match
+
+func (v matchesByNewKey) Len() int           { return len(v) }
+func (v matchesByNewKey) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v matchesByNewKey) Less(i, j int) bool { return v[i].New.Less(v[j].New) }
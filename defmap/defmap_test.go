@@ -0,0 +1,137 @@
+package defmap
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// testBuild returns a build of the files, whose defs are each
+// "KIND NAME(...) {...}" declaration in them (one per line).
+func testBuild(commitID string, files map[string]string) *Build {
+	b := &Build{CommitID: commitID}
+	for file, data := range files {
+		var offset int
+		for _, line := range strings.SplitAfter(data, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				name := strings.SplitN(fields[1], "(", 2)[0]
+				b.Defs = append(b.Defs, &graph.Def{
+					DefKey:   graph.DefKey{UnitType: "t", Unit: strings.SplitN(file, "/", 2)[0], Path: graph.DefPath(name)},
+					Kind:     graph.DefKind(fields[0]),
+					Name:     name,
+					File:     file,
+					DefStart: offset,
+					DefEnd:   offset + len(strings.TrimSpace(line)),
+				})
+			}
+			offset += len(line)
+		}
+	}
+	b.ReadFile = func(file string) ([]byte, error) {
+		data, present := files[file]
+		if !present {
+			return nil, os.ErrNotExist
+		}
+		return []byte(data), nil
+	}
+	return b
+}
+
+func TestCompute(t *testing.T) {
+	old := testBuild("c1", map[string]string{
+		"a/a.go": "func Same() { return 1 }\n" +
+			"func Renamed(x int) { return x * 2 + 1 }\n" +
+			"func Gone(y string) { print(y, y, y) }\n" +
+			"var Moved = 3\n",
+		"d/old.go": "var X = 1\n",
+		"e/e.go":   "var X = 2\n",
+	})
+	new := testBuild("c2", map[string]string{
+		"a/a.go": "func Same() { return 1 }\n" +
+			"func Renamed2(x int) { return x * 2 + 1 }\n" +
+			"func New(z float) { println(z + z) }\n",
+		"b/b.go":   "var Moved = 3\n",
+		"f/new.go": "var X = 1\n",
+		"g/g.go":   "var X = 2\n",
+	})
+	renames := map[string]string{"d/old.go": "f/new.go"}
+
+	m := Compute(old, new, renames)
+	want := &Map{
+		OldCommitID:  "c1",
+		NewCommitID:  "c2",
+		RenamedFiles: renames,
+		Unchanged:    1,
+		Matches: []*Match{
+			{Old: graph.DefKey{UnitType: "t", Unit: "a", Path: "Renamed"}, New: graph.DefKey{UnitType: "t", Unit: "a", Path: "Renamed2"}, By: ByContent, Similarity: m.Matches[0].Similarity},
+			{Old: graph.DefKey{UnitType: "t", Unit: "a", Path: "Moved"}, New: graph.DefKey{UnitType: "t", Unit: "b", Path: "Moved"}, By: ByName},
+			{Old: graph.DefKey{UnitType: "t", Unit: "d", Path: "X"}, New: graph.DefKey{UnitType: "t", Unit: "f", Path: "X"}, By: ByRename},
+			// Once the X in the renamed file is matched, the other X is
+			// unique.
+			{Old: graph.DefKey{UnitType: "t", Unit: "e", Path: "X"}, New: graph.DefKey{UnitType: "t", Unit: "g", Path: "X"}, By: ByName},
+		},
+		Added:   []graph.DefKey{{UnitType: "t", Unit: "a", Path: "New"}},
+		Removed: []graph.DefKey{{UnitType: "t", Unit: "a", Path: "Gone"}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got map %s, want %s", mapString(m), mapString(want))
+	}
+	if s := m.Matches[0].Similarity; s < MinSimilarity || s >= 1 {
+		t.Errorf("got similarity %f, want [%f, 1)", s, MinSimilarity)
+	}
+
+	if k, ok := m.Old(graph.DefKey{UnitType: "t", Unit: "a", Path: "Renamed2"}); !ok || k.Path != "Renamed" {
+		t.Errorf("Old(Renamed2): got %v, %v", k, ok)
+	}
+	if k, ok := m.Old(graph.DefKey{UnitType: "t", Unit: "a", Path: "Same"}); !ok || k.Path != "Same" {
+		t.Errorf("Old(Same): got %v, %v", k, ok)
+	}
+	if _, ok := m.Old(graph.DefKey{UnitType: "t", Unit: "a", Path: "New"}); ok {
+		t.Error("Old(New): got present, want added")
+	}
+	if _, ok := m.New(graph.DefKey{UnitType: "t", Unit: "a", Path: "Gone"}); ok {
+		t.Error("New(Gone): got present, want removed")
+	}
+
+	// Without file contents or renames, defs are only matched by name (and
+	// the Xs are ambiguous).
+	old.ReadFile, new.ReadFile = nil, nil
+	if m := Compute(old, new, nil); len(m.Matches) != 1 || m.Matches[0].By != ByName {
+		t.Errorf("got matches %s without contents, want only the match by name", mapString(m))
+	}
+}
+
+func TestHistory(t *testing.T) {
+	maps := map[string]*Map{
+		"c3": {OldCommitID: "c2", NewCommitID: "c3", Matches: []*Match{{Old: graph.DefKey{Path: "b"}, New: graph.DefKey{Path: "c"}, By: ByContent}}},
+		"c2": {OldCommitID: "c1", NewCommitID: "c2"},
+		"c1": {OldCommitID: "c0", NewCommitID: "c1", Added: []graph.DefKey{{Path: "b"}}},
+	}
+	readMap := func(commitID string) (*Map, error) { return maps[commitID], nil }
+
+	history, err := History("c3", graph.DefKey{Repo: "r", Path: "c"}, readMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*HistoryEntry{
+		{CommitID: "c3", Def: graph.DefKey{Path: "c"}, By: ByContent},
+		{CommitID: "c2", Def: graph.DefKey{Path: "b"}},
+		{CommitID: "c1", Def: graph.DefKey{Path: "b"}},
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("got history %+v, want %+v", history, want)
+	}
+}
+
+func mapString(m *Map) string {
+	s := fmt.Sprintf("%+v", *m)
+	for _, mt := range m.Matches {
+		s += fmt.Sprintf("\n\t%+v", *mt)
+	}
+	return s
+}
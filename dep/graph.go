@@ -0,0 +1,185 @@
+package dep
+
+import (
+	"path"
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// A Node is a source unit in a dependency graph.
+type Node struct {
+	Repo     repo.URI
+	UnitType string
+	Unit     string
+
+	// Version is the version (or, if there is none, the VCS revision) of
+	// a source unit in another repository that a dependency specifies,
+	// if any. Dependencies on different versions of a source unit are
+	// different nodes.
+	Version string `json:",omitempty"`
+}
+
+func (n Node) String() string {
+	s := string(n.Repo) + " " + n.Unit + " (" + n.UnitType + ")"
+	if n.Version != "" {
+		s += " " + n.Version
+	}
+	return s
+}
+
+// Matches returns whether the node's repository URI or source unit name
+// match pattern, which is a path.Match pattern (or a plain URI or name).
+func (n Node) Matches(pattern string) bool {
+	for _, s := range []string{string(n.Repo), n.Unit} {
+		if s == pattern {
+			return true
+		}
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// A Graph is the dependency graph of a repository's source units, as
+// resolved by dependency resolvers. Only the dependencies of the
+// repository's own source units are known, so source units in other
+// repositories have no dependencies in the graph.
+type Graph struct {
+	// Roots are the repository's source units, sorted.
+	Roots []Node
+
+	// Deps maps source units to their direct dependencies (sorted).
+	Deps map[Node][]Node
+}
+
+// NewGraph returns the dependency graph of the source units of the
+// repository repoURI, given the resolved dependencies of the units.
+func NewGraph(repoURI repo.URI, units []*unit.SourceUnit, deps []*ResolvedDep) *Graph {
+	g := &Graph{Deps: map[Node][]Node{}}
+	for _, u := range units {
+		g.Roots = append(g.Roots, Node{Repo: repoURI, UnitType: u.Type, Unit: u.Name})
+	}
+	sort.Sort(nodes(g.Roots))
+
+	seen := map[[2]Node]bool{}
+	for _, d := range deps {
+		from := Node{Repo: repoURI, UnitType: d.FromUnitType, Unit: d.FromUnit}
+		to := Node{Repo: d.ToRepo, UnitType: d.ToUnitType, Unit: d.ToUnit}
+		if to.Repo != repoURI {
+			to.Version = d.ToVersionString
+			if to.Version == "" {
+				to.Version = d.ToRevSpec
+			}
+		}
+		if from == to || seen[[2]Node{from, to}] {
+			continue
+		}
+		seen[[2]Node{from, to}] = true
+		g.Deps[from] = append(g.Deps[from], to)
+	}
+	for _, ns := range g.Deps {
+		sort.Sort(nodes(ns))
+	}
+	return g
+}
+
+// A TreeNode is a source unit in a dependency tree (see Graph.Tree).
+type TreeNode struct {
+	Node
+
+	// Deps are the source unit's dependencies.
+	Deps []*TreeNode `json:",omitempty"`
+
+	// Repeated is whether the source unit's dependencies are omitted
+	// because they appear earlier in the tree (which is always true of
+	// the units in a dependency cycle).
+	Repeated bool `json:",omitempty"`
+}
+
+// Tree returns the dependency trees of roots (which are usually some of
+// g.Roots). Each source unit's dependencies appear only once, where the
+// unit first appears in a depth-first traversal.
+func (g *Graph) Tree(roots []Node) []*TreeNode {
+	expanded := map[Node]bool{}
+	var tree func(n Node) *TreeNode
+	tree = func(n Node) *TreeNode {
+		t := &TreeNode{Node: n}
+		if len(g.Deps[n]) == 0 {
+			return t
+		}
+		if expanded[n] {
+			t.Repeated = true
+			return t
+		}
+		expanded[n] = true
+		for _, d := range g.Deps[n] {
+			t.Deps = append(t.Deps, tree(d))
+		}
+		return t
+	}
+	trees := make([]*TreeNode, len(roots))
+	for i, root := range roots {
+		trees[i] = tree(root)
+	}
+	return trees
+}
+
+// Why returns the shortest dependency path from each of the repository's
+// source units to each source unit that matches pattern (see
+// Node.Matches) and that it depends on, directly or indirectly. Each path
+// starts with the repository's source unit and ends with the matching
+// unit. Paths are sorted by their first and then their last unit.
+func (g *Graph) Why(pattern string) [][]Node {
+	var paths [][]Node
+	for _, root := range g.Roots {
+		// Breadth-first search, so the first path found to each unit is
+		// a shortest one.
+		prev := map[Node]Node{root: root}
+		queue := []Node{root}
+		var found []Node
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			if n != root && n.Matches(pattern) {
+				found = append(found, n)
+			}
+			for _, d := range g.Deps[n] {
+				if _, seen := prev[d]; !seen {
+					prev[d] = n
+					queue = append(queue, d)
+				}
+			}
+		}
+		sort.Sort(nodes(found))
+		for _, n := range found {
+			path := []Node{n}
+			for n != root {
+				n = prev[n]
+				path = append([]Node{n}, path...)
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+type nodes []Node
+
+func (v nodes) Len() int      { return len(v) }
+func (v nodes) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v nodes) Less(i, j int) bool {
+	a, b := v[i], v[j]
+	if a.Repo != b.Repo {
+		return a.Repo < b.Repo
+	}
+	if a.UnitType != b.UnitType {
+		return a.UnitType < b.UnitType
+	}
+	if a.Unit != b.Unit {
+		return a.Unit < b.Unit
+	}
+	return a.Version < b.Version
+}
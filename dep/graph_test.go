@@ -0,0 +1,71 @@
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func testGraph() *Graph {
+	units := []*unit.SourceUnit{{Name: "cmd", Type: "t"}, {Name: "lib", Type: "t"}, {Name: "util", Type: "t"}}
+	return NewGraph("r", units, []*ResolvedDep{
+		{FromUnit: "cmd", FromUnitType: "t", ToRepo: "r", ToUnit: "lib", ToUnitType: "t"},
+		{FromUnit: "cmd", FromUnitType: "t", ToRepo: "r", ToUnit: "util", ToUnitType: "t"},
+		{FromUnit: "cmd", FromUnitType: "t", ToRepo: "r", ToUnit: "cmd", ToUnitType: "t"},
+		{FromUnit: "lib", FromUnitType: "t", ToRepo: "r", ToUnit: "util", ToUnitType: "t"},
+		{FromUnit: "lib", FromUnitType: "t", ToRepo: "x", ToUnit: "x", ToUnitType: "t", ToVersionString: "v1"},
+		{FromUnit: "lib", FromUnitType: "t", ToRepo: "x", ToUnit: "x", ToUnitType: "t", ToVersionString: "v1"},
+		{FromUnit: "util", FromUnitType: "t", ToRepo: "y", ToUnit: "y", ToUnitType: "t", ToRevSpec: "abc"},
+		{FromUnit: "util", FromUnitType: "t", ToRepo: "r", ToUnit: "lib", ToUnitType: "t"},
+	})
+}
+
+func TestGraph_Tree(t *testing.T) {
+	g := testGraph()
+	var (
+		cmd  = Node{Repo: "r", UnitType: "t", Unit: "cmd"}
+		lib  = Node{Repo: "r", UnitType: "t", Unit: "lib"}
+		util = Node{Repo: "r", UnitType: "t", Unit: "util"}
+		x    = Node{Repo: "x", UnitType: "t", Unit: "x", Version: "v1"}
+		y    = Node{Repo: "y", UnitType: "t", Unit: "y", Version: "abc"}
+	)
+	if want := []Node{cmd, lib, util}; !reflect.DeepEqual(g.Roots, want) {
+		t.Fatalf("got roots %v, want %v", g.Roots, want)
+	}
+
+	// lib and util depend on each other, and cmd depends on both.
+	want := []*TreeNode{{Node: cmd, Deps: []*TreeNode{
+		{Node: lib, Deps: []*TreeNode{
+			{Node: util, Deps: []*TreeNode{
+				{Node: lib, Repeated: true},
+				{Node: y},
+			}},
+			{Node: x},
+		}},
+		{Node: util, Repeated: true},
+	}}}
+	if got := g.Tree([]Node{cmd}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got tree %+v, want %+v", got, want)
+	}
+}
+
+func TestGraph_Why(t *testing.T) {
+	g := testGraph()
+	var (
+		cmd  = Node{Repo: "r", UnitType: "t", Unit: "cmd"}
+		lib  = Node{Repo: "r", UnitType: "t", Unit: "lib"}
+		util = Node{Repo: "r", UnitType: "t", Unit: "util"}
+		y    = Node{Repo: "y", UnitType: "t", Unit: "y", Version: "abc"}
+	)
+	tests := map[string][][]Node{
+		"y":       {{cmd, util, y}, {lib, util, y}, {util, y}},
+		"l*":      {{cmd, lib}, {util, lib}},
+		"missing": nil,
+	}
+	for pattern, want := range tests {
+		if got := g.Why(pattern); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", pattern, got, want)
+		}
+	}
+}
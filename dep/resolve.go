@@ -2,10 +2,13 @@ package dep
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"sort"
 
 	"sourcegraph.com/sourcegraph/srclib/repo"
 	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/util"
 )
 
 // START ResolvedTarget OMIT
@@ -53,6 +56,37 @@ type Resolution struct {
 
 // END Resolution OMIT
 
+// Limits on the dependency resolution output that ReadResolutions reads.
+// They protect programs that read build data from corrupted or malicious
+// files.
+var (
+	// MaxResolutionsSize is the maximum size of dependency resolution
+	// output, in bytes.
+	MaxResolutionsSize int64 = 256 << 20
+
+	// MaxResolutionsDepth is the maximum nesting depth of arrays and
+	// objects in dependency resolution output (mostly in raw deps).
+	MaxResolutionsDepth = 64
+)
+
+// ReadResolutions reads dependency resolution output (as written by
+// dependency resolvers and stored in build data) from r. Unlike decoding
+// the JSON directly, it returns an error if the output exceeds
+// MaxResolutionsSize or MaxResolutionsDepth or if it contains null
+// resolutions.
+func ReadResolutions(r io.Reader) ([]*Resolution, error) {
+	var ress []*Resolution
+	if err := util.ReadJSON(r, &ress, MaxResolutionsSize, MaxResolutionsDepth); err != nil {
+		return nil, err
+	}
+	for i, res := range ress {
+		if res == nil {
+			return nil, fmt.Errorf("dependency resolution output: resolution %d is null", i)
+		}
+	}
+	return ress, nil
+}
+
 // Command for dep resolution has no options.
 type Command struct{}
 
@@ -72,14 +106,18 @@ func ResolutionsToResolvedDeps(ress []*Resolution, unit *unit.SourceUnit, fromRe
 	}
 	var resolved []*ResolvedDep
 	for _, res := range ress {
-		if res.Error != "" {
+		if res == nil || res.Error != "" {
 			continue
 		}
 
 		if rt := res.Target; rt != nil {
 			var uri repo.URI
 			if rt.ToRepoCloneURL != "" {
-				uri = repo.MakeURI(rt.ToRepoCloneURL)
+				var err error
+				uri, err = repo.ParseCloneURL(rt.ToRepoCloneURL)
+				if err != nil {
+					return nil, fmt.Errorf("dependency %v resolved to invalid clone URL %q: %s", res.Raw, rt.ToRepoCloneURL, err)
+				}
 			} else {
 				uri = fromRepo
 			}
@@ -0,0 +1,50 @@
+package dep
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestReadResolutions(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr string
+	}{
+		{data: `[]`},
+		{data: `null`},
+		{data: `[{"Raw":{"a":[1]},"Target":{"ToRepoCloneURL":"https://example.com/r"}},{"Raw":"x","Error":"e"}]`},
+		{data: `[{"Raw":"x"},null]`, wantErr: "resolution 1 is null"},
+		{data: `[{"Raw":` + strings.Repeat("[", MaxResolutionsDepth) + strings.Repeat("]", MaxResolutionsDepth) + `}]`, wantErr: "nested too deeply"},
+		{data: `{}`, wantErr: "cannot unmarshal object"},
+	}
+	for _, test := range tests {
+		_, err := ReadResolutions(strings.NewReader(test.data))
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: got error %q, want no error", test.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", test.data, err, test.wantErr)
+		}
+	}
+}
+
+// FuzzReadResolutions checks that reading dependency resolution output,
+// and converting it to resolved deps, doesn't panic, however malformed the
+// output is.
+func FuzzReadResolutions(f *testing.F) {
+	f.Add([]byte(`[{"Raw":{"name":"a"},"Target":{"ToRepoCloneURL":"https://example.com/r.git","ToUnit":"u","ToUnitType":"t"}},{"Raw":null,"Error":"e"}]`))
+	f.Add([]byte(`[{"Target":{"ToRepoCloneURL":"git@example.com:r"}},{"Target":null}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ress, err := ReadResolutions(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		ResolutionsToResolvedDeps(ress, &unit.SourceUnit{Name: "u", Type: "t"}, "example.com/r", "c")
+	})
+}
@@ -44,6 +44,10 @@ type ResolveDepsRule struct {
 	opt     plan.Options
 }
 
+func (r *ResolveDepsRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *ResolveDepsRule) ToolRef() *toolchain.ToolRef { return r.Tool }
+
 func (r *ResolveDepsRule) Target() string {
 	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename([]*ResolvedDep{}, r.Unit))
 }
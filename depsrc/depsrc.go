@@ -0,0 +1,326 @@
+// Package depsrc checks out the repositories of a source unit's resolved
+// dependencies, so that graphers that need the dependencies' sources (for
+// type information or inherited docs, for example) can read them.
+//
+// Checkouts are shallow clones of the dependencies at their resolved
+// revisions, and they are cached (see Cache), so each dependency revision
+// is only cloned once. Graphers whose toolchains have the
+// toolchain.DepSources capability find the checkouts of the source unit's
+// dependencies in the file named by toolchain.DepSourcesEnv.
+package depsrc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+func init() {
+	buildstore.RegisterDataType("depsrc", []*Source{})
+}
+
+// A Source is a checkout of a dependency's repository.
+type Source struct {
+	// Repo is the URI of the dependency's repository.
+	Repo repo.URI
+
+	// CloneURL is the URL that the repository was cloned from (before
+	// any mirror was applied).
+	CloneURL string
+
+	// RevSpec is the revision that the dependency resolved to, or empty
+	// for the repository's default branch.
+	RevSpec string `json:",omitempty"`
+
+	// CommitID is the commit that is checked out, or empty for a local
+	// checkout (see Cache.Local), whose working tree is used as is.
+	CommitID string
+
+	// Dir is the absolute path of the checkout.
+	Dir string
+}
+
+// A Cache holds dependency checkouts in a directory. Checkouts are stored
+// by repository and commit, so dependencies on different revisions that
+// resolve to the same commit share a checkout. A Cache may be used by
+// concurrent processes (such as several builds).
+type Cache struct {
+	// Dir is the directory that holds the checkouts. The checkout of
+	// commit C of repository R is in Dir/R/@C, and information about it
+	// (a Source) is in Dir/R/@C.json, whose modification time is when the
+	// checkout was last used. The commits that other revisions resolved
+	// to are in Dir/R/refs.
+	Dir string
+
+	// MaxAge is how long the commit that a revision that isn't a commit ID
+	// (such as a branch or tag) resolved to is used before the revision is
+	// resolved (and perhaps checked out) again. If MaxAge is 0, revisions
+	// are only resolved once.
+	MaxAge time.Duration
+
+	// Local maps the URIs of repositories that are checked out locally
+	// (such as the other roots of a workspace) to the directories of their
+	// working trees, which are used (regardless of the revision) instead
+	// of cached checkouts.
+	Local map[repo.URI]string
+}
+
+// DefaultMaxAge is the MaxAge of the cache returned by DefaultCache.
+const DefaultMaxAge = 24 * time.Hour
+
+// DefaultCache returns the cache in the "deps" directory of the srclib
+// cache directory (srclib.CacheDir).
+func DefaultCache() *Cache {
+	return &Cache{Dir: filepath.Join(srclib.CacheDir, "deps"), MaxAge: DefaultMaxAge}
+}
+
+var commitID = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Get returns a checkout of the repository at cloneURL at revision rev (or
+// its default branch, if rev is empty), cloning it if it isn't cached. If the
+// repository is checked out locally (see Local), its working tree is
+// returned.
+//
+// The clone URL and revision come from toolchains' dependency resolution
+// output, so ones that git would parse as options are rejected.
+func (c *Cache) Get(cloneURL, rev string) (*Source, error) {
+	if strings.HasPrefix(cloneURL, "-") {
+		return nil, fmt.Errorf("invalid clone URL %q (must not begin with \"-\")", cloneURL)
+	}
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q (must not begin with \"-\")", rev)
+	}
+	uri, err := repo.ParseCloneURL(cloneURL)
+	if err != nil {
+		return nil, err
+	}
+	if dir, present := c.Local[uri]; present {
+		return &Source{Repo: uri, CloneURL: cloneURL, RevSpec: rev, Dir: dir}, nil
+	}
+	cacheDir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	// The URI is derived from the clone URL, which may have ".." path
+	// elements that would put the checkout outside of the cache.
+	repoDir := filepath.Join(cacheDir, filepath.FromSlash(string(uri)))
+	if rel, err := filepath.Rel(cacheDir, repoDir); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("invalid clone URL %q (repository %q is outside of the cache)", cloneURL, uri)
+	}
+	if err := os.MkdirAll(repoDir, 0700); err != nil {
+		return nil, err
+	}
+
+	// Only one process clones a repository at a time, so concurrent builds
+	// that depend on the same repository don't all clone it.
+	unlock, err := lockRepo(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	commit := rev
+	if !commitID.MatchString(rev) {
+		commit = c.resolved(repoDir, rev)
+	}
+	if commit != "" {
+		if src := use(repoDir, commit); src != nil {
+			src.RevSpec = rev
+			return src, nil
+		}
+	}
+
+	tmpDir, err := ioutil.TempDir(repoDir, tmpPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if commit, err = checkout(tmpDir, network.Default.Mirror(cloneURL), rev); err != nil {
+		return nil, err
+	}
+	if !commitID.MatchString(rev) {
+		if err := writeRef(repoDir, rev, commit); err != nil {
+			return nil, err
+		}
+	}
+	if src := use(repoDir, commit); src != nil {
+		// The revision resolved to a commit that is already checked out.
+		src.RevSpec = rev
+		return src, nil
+	}
+
+	src := &Source{Repo: uri, CloneURL: cloneURL, CommitID: commit, Dir: filepath.Join(repoDir, "@"+commit)}
+	if err := os.Rename(tmpDir, src.Dir); err != nil {
+		// The checkout was left incomplete by a process that failed.
+		if err := os.RemoveAll(src.Dir); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmpDir, src.Dir); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.MarshalIndent(src, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(src.Dir+".json", data, 0600); err != nil {
+		return nil, err
+	}
+	src.RevSpec = rev
+	return src, nil
+}
+
+// tmpPrefix is the prefix of the names of the temporary directories that
+// repositories are cloned into before they are moved into place.
+const tmpPrefix = ".tmp-"
+
+// use returns the checkout of commit in repoDir and marks it as used now,
+// or returns nil if it isn't checked out.
+func use(repoDir, commit string) *Source {
+	dir := filepath.Join(repoDir, "@"+commit)
+	src, err := readSource(dir + ".json")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: ignoring dependency checkout %s: %s.", dir, err)
+		}
+		return nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := os.Chtimes(dir+".json", now, now); err != nil {
+		log.Printf("Warning: failed to mark dependency checkout %s as used: %s.", dir, err)
+	}
+	return src
+}
+
+func readSource(file string) (*Source, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var src *Source
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, fmt.Errorf("no checkout info in %s", file)
+	}
+	return src, nil
+}
+
+// refFile returns the file in repoDir that holds the commit that rev
+// resolved to.
+func refFile(repoDir, rev string) string {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return filepath.Join(repoDir, "refs", url.PathEscape(rev))
+}
+
+// resolved returns the commit that rev resolved to when it was last
+// checked out, or "" if it hasn't been or if that was more than MaxAge
+// ago.
+func (c *Cache) resolved(repoDir, rev string) string {
+	file := refFile(repoDir, rev)
+	fi, err := os.Stat(file)
+	if err != nil || (c.MaxAge != 0 && time.Since(fi.ModTime()) > c.MaxAge) {
+		return ""
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil || !commitID.Match(data) {
+		return ""
+	}
+	return string(data)
+}
+
+func writeRef(repoDir, rev, commit string) error {
+	file := refFile(repoDir, rev)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte(commit), 0600)
+}
+
+// checkout checks out rev (or the default branch, if rev is empty) of the
+// git repository at cloneURL into dir, and returns the commit ID. It only
+// fetches that commit, if the server allows it.
+func checkout(dir, cloneURL, rev string) (string, error) {
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	if _, err := run("init", "--quiet"); err != nil {
+		return "", err
+	}
+	ref := rev
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := run("fetch", "--quiet", "--depth=1", "--", cloneURL, ref); err == nil {
+		ref = "FETCH_HEAD"
+	} else {
+		// Some servers don't allow fetching commits by ID, and rev may be an
+		// abbreviated commit ID, so fetch everything.
+		if _, err := run("fetch", "--quiet", "--tags", "--", cloneURL, "+refs/heads/*:refs/remotes/origin/*", "+HEAD:refs/remotes/origin/HEAD"); err != nil {
+			return "", err
+		}
+		if rev == "" {
+			ref = "origin/HEAD"
+		} else if _, err := run("rev-parse", "--verify", "--quiet", rev+"^{commit}"); err != nil {
+			ref = "origin/" + rev
+		}
+	}
+	if _, err := run("checkout", "--quiet", "--detach", ref+"^{commit}", "--"); err != nil {
+		return "", err
+	}
+	return run("rev-parse", "HEAD")
+}
+
+// GetAll returns checkouts of the repositories of the dependencies in
+// ress that were resolved to other repositories. Dependencies that can't
+// be checked out are omitted (with a warning), since graphers can usually
+// do without some of them.
+func (c *Cache) GetAll(ress []*dep.Resolution) []*Source {
+	srcs := []*Source{}
+	seen := map[[2]string]bool{}
+	for _, res := range ress {
+		if res == nil || res.Error != "" || res.Target == nil || res.Target.ToRepoCloneURL == "" {
+			continue
+		}
+		key := [2]string{res.Target.ToRepoCloneURL, res.Target.ToRevSpec}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		src, err := c.Get(res.Target.ToRepoCloneURL, res.Target.ToRevSpec)
+		if err != nil {
+			log.Printf("Warning: failed to check out dependency %s at %q: %s.", res.Target.ToRepoCloneURL, res.Target.ToRevSpec, err)
+			continue
+		}
+		srcs = append(srcs, src)
+	}
+	return srcs
+}
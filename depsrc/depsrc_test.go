@@ -0,0 +1,282 @@
+package depsrc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// makeRepo creates a git repository in dir with two commits (the first
+// tagged v1), each of which writes its message to the file "version". It
+// returns the commit IDs.
+func makeRepo(t *testing.T, dir string) (first, second string) {
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %s\n%s", cmd.Args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(msg string) string {
+		if err := ioutil.WriteFile(filepath.Join(dir, "version"), []byte(msg), 0600); err != nil {
+			t.Fatal(err)
+		}
+		run("add", "version")
+		run("commit", "--quiet", "-m", msg)
+		return run("rev-parse", "HEAD")
+	}
+
+	run("init", "--quiet")
+	first = commit("1")
+	run("tag", "v1")
+	second = commit("2")
+	return first, second
+}
+
+func TestCache_Get(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "depsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := os.Mkdir(repoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	first, second := makeRepo(t, repoDir)
+	cloneURL := "file://" + filepath.ToSlash(repoDir)
+
+	c := &Cache{Dir: filepath.Join(tmpDir, "cache")}
+	tests := []struct {
+		rev         string
+		wantCommit  string
+		wantVersion string
+	}{
+		{"", second, "2"},
+		{"v1", first, "1"},
+		{first, first, "1"},
+		{first[:10], first, "1"},
+	}
+	for _, test := range tests {
+		src, err := c.Get(cloneURL, test.rev)
+		if err != nil {
+			t.Errorf("rev %q: %s", test.rev, err)
+			continue
+		}
+		if src.CommitID != test.wantCommit {
+			t.Errorf("rev %q: got commit %s, want %s", test.rev, src.CommitID, test.wantCommit)
+		}
+		if version, err := ioutil.ReadFile(filepath.Join(src.Dir, "version")); err != nil {
+			t.Errorf("rev %q: %s", test.rev, err)
+		} else if string(version) != test.wantVersion {
+			t.Errorf("rev %q: got version %q, want %q", test.rev, version, test.wantVersion)
+		}
+	}
+
+	// Revisions that resolve to the same commit share a checkout.
+	tag, err := c.Get(cloneURL, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(c.Dir, "@"+first); !strings.HasSuffix(tag.Dir, want[len(c.Dir):]) {
+		t.Errorf("got checkout dir %s for v1, want .../@%s", tag.Dir, first)
+	}
+	if tag.RevSpec != "v1" {
+		t.Errorf("got RevSpec %q, want v1", tag.RevSpec)
+	}
+
+	// Checkouts are cached, so they don't need the repository.
+	if err := os.RemoveAll(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	src, err := c.Get(cloneURL, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.CommitID != first {
+		t.Errorf("got cached commit %s, want %s", src.CommitID, first)
+	}
+}
+
+func TestCache_GetAll(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "depsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := os.Mkdir(repoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := makeRepo(t, repoDir)
+	cloneURL := "file://" + filepath.ToSlash(repoDir)
+
+	c := &Cache{Dir: filepath.Join(tmpDir, "cache")}
+	srcs := c.GetAll([]*dep.Resolution{
+		{Raw: "a", Target: &dep.ResolvedTarget{ToRepoCloneURL: cloneURL, ToRevSpec: first}},
+		{Raw: "b", Target: &dep.ResolvedTarget{ToRepoCloneURL: cloneURL, ToRevSpec: first}},
+		{Raw: "same repo", Target: &dep.ResolvedTarget{ToUnit: "u"}},
+		{Raw: "unresolved", Error: "not found"},
+		{Raw: "missing", Target: &dep.ResolvedTarget{ToRepoCloneURL: "file://" + filepath.ToSlash(filepath.Join(tmpDir, "missing"))}},
+	})
+	if len(srcs) != 1 {
+		t.Fatalf("got %d checkouts, want 1: %+v", len(srcs), srcs)
+	}
+	if srcs[0].CommitID != first || srcs[0].CloneURL != cloneURL {
+		t.Errorf("got checkout %+v, want commit %s of %s", srcs[0], first, cloneURL)
+	}
+}
+
+func TestCache_Get_optionLike(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "depsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	marker := filepath.Join(tmpDir, "marker")
+
+	c := &Cache{Dir: filepath.Join(tmpDir, "cache")}
+	tests := []struct{ cloneURL, rev string }{
+		{"--upload-pack=touch " + marker, ""},
+		{"-uupload-pack", ""},
+		{"https://example.com/lib.git", "--upload-pack=touch " + marker},
+	}
+	for _, test := range tests {
+		if _, err := c.Get(test.cloneURL, test.rev); err == nil {
+			t.Errorf("clone URL %q rev %q: got no error", test.cloneURL, test.rev)
+		}
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("option-like clone URL was run (stat marker: %v)", err)
+	}
+}
+
+func TestCache_Get_outsideCache(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "depsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	c := &Cache{Dir: filepath.Join(tmpDir, "a", "cache")}
+	for _, cloneURL := range []string{"github.com/../../../x", "example.com/a/../../../x"} {
+		if _, err := c.Get(cloneURL, ""); err == nil {
+			t.Errorf("clone URL %q: got no error", cloneURL)
+		}
+	}
+	if fis, err := ioutil.ReadDir(tmpDir); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 0 {
+		t.Errorf("got %d files created outside of the cache, want none", len(fis))
+	}
+}
+
+func TestCache_Get_local(t *testing.T) {
+	c := &Cache{Dir: "/nonexistent", Local: map[repo.URI]string{"example.com/lib": "/src/lib"}}
+	src, err := c.Get("https://example.com/lib.git", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Source{Repo: "example.com/lib", CloneURL: "https://example.com/lib.git", RevSpec: "v1", Dir: "/src/lib"}); !reflect.DeepEqual(src, want) {
+		t.Errorf("got %+v, want %+v", src, want)
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "depsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Create checkouts of a, b, and c (of sizes 100, 200, and 300) last used
+	// 3, 2, and 1 days ago, and a recently used checkout of d.
+	c := &Cache{Dir: tmpDir}
+	now := time.Now()
+	for i, name := range []string{"a", "b", "c", "d"} {
+		dir := filepath.Join(tmpDir, "example.com", name, "@"+strings.Repeat(name, 40))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "f"), make([]byte, 100*(i+1)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(dir+".json", []byte(`{"Repo":"example.com/`+name+`"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		lastUsed := now.Add(-time.Duration(3-i) * 24 * time.Hour)
+		if name == "d" {
+			lastUsed = now
+		}
+		if err := os.Chtimes(dir+".json", lastUsed, lastUsed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repos := func(entries []*Entry) []string {
+		var repos []string
+		for _, e := range entries {
+			repos = append(repos, string(e.Repo))
+		}
+		return repos
+	}
+	list := func() []string {
+		entries, err := c.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repos(entries)
+	}
+	if got, want := list(), []string{"example.com/d", "example.com/c", "example.com/b", "example.com/a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got checkouts %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		opt  PruneOptions
+		want []string
+	}{
+		{PruneOptions{MaxAge: 60 * time.Hour, DryRun: true}, []string{"example.com/a"}},
+		{PruneOptions{MaxSize: 750, DryRun: true}, []string{"example.com/a", "example.com/b"}},
+		{PruneOptions{MaxAge: 60 * time.Hour, MaxSize: 700}, []string{"example.com/a", "example.com/b"}},
+	}
+	for _, test := range tests {
+		removed, err := c.Prune(test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := repos(removed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got removed %v, want %v", test.opt, got, test.want)
+		}
+	}
+	if got, want := list(), []string{"example.com/d", "example.com/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after pruning, got checkouts %v, want %v", got, want)
+	}
+
+	// Recently used checkouts are never removed.
+	removed, err := c.Prune(PruneOptions{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := repos(removed), []string{"example.com/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package depsrc
+
+import "sync"
+
+var repoMu sync.Mutex
+
+// lockRepo locks the cache within this process only, since file locks
+// aren't supported on this platform. Concurrent processes may clone the
+// same repository, but checkouts are moved into place atomically, so they
+// never see partial checkouts.
+func lockRepo(dir string) (unlock func(), err error) {
+	repoMu.Lock()
+	return repoMu.Unlock, nil
+}
@@ -0,0 +1,190 @@
+package depsrc
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An Entry is a checkout in a Cache.
+type Entry struct {
+	Source
+
+	// LastUsed is when the checkout was last returned by Get.
+	LastUsed time.Time
+
+	// Size is the total size of the checkout's files, in bytes.
+	Size int64
+}
+
+// List returns the checkouts in the cache, most recently used first.
+func (c *Cache) List() ([]*Entry, error) {
+	entries, _, err := c.list()
+	return entries, err
+}
+
+// list returns the checkouts in the cache (most recently used first) and
+// the temporary directories that clones were left in by processes that
+// failed.
+func (c *Cache) list() (entries []*Entry, tmpDirs []string, err error) {
+	err = filepath.Walk(c.Dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == c.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		name := fi.Name()
+		if fi.IsDir() {
+			if path == c.Dir {
+				return nil
+			}
+			if strings.HasPrefix(name, tmpPrefix) && time.Since(fi.ModTime()) > staleTmpAge {
+				tmpDirs = append(tmpDirs, path)
+			}
+			// Don't walk checkouts (which are listed by their info files).
+			if strings.HasPrefix(name, "@") || strings.HasPrefix(name, tmpPrefix) || name == "refs" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(name, "@") || !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+
+		src, err := readSource(path)
+		if err != nil {
+			log.Printf("Warning: ignoring dependency checkout info in %s: %s.", path, err)
+			return nil
+		}
+		src.Dir = strings.TrimSuffix(path, ".json")
+		size, err := dirSize(src.Dir)
+		if err != nil {
+			log.Printf("Warning: ignoring dependency checkout %s: %s.", src.Dir, err)
+			return nil
+		}
+		entries = append(entries, &Entry{Source: *src, LastUsed: fi.ModTime(), Size: size})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(entriesByLastUsed(entries))
+	return entries, tmpDirs, nil
+}
+
+// staleTmpAge is how old a temporary clone directory must be for Prune to
+// remove it (on platforms where the cache can't be locked, a concurrent
+// process could still be cloning into it).
+const staleTmpAge = 24 * time.Hour
+
+type entriesByLastUsed []*Entry
+
+func (v entriesByLastUsed) Len() int           { return len(v) }
+func (v entriesByLastUsed) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v entriesByLastUsed) Less(i, j int) bool { return v[i].LastUsed.After(v[j].LastUsed) }
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// PruneOptions configures which checkouts Prune removes.
+type PruneOptions struct {
+	// MaxAge is how long a checkout may go unused before it is removed. If
+	// 0, checkouts aren't removed because of their age.
+	MaxAge time.Duration
+
+	// MaxSize is the total size (in bytes) that the cache is reduced to,
+	// by removing the least recently used checkouts. If 0, the cache's
+	// size is unlimited.
+	MaxSize int64
+
+	// DryRun, if true, makes Prune return the checkouts that it would
+	// remove without removing them.
+	DryRun bool
+}
+
+// MinPruneAge is how long a checkout must go unused before Prune removes
+// it, regardless of the PruneOptions, since builds may still be reading
+// recently used checkouts.
+const MinPruneAge = time.Hour
+
+// Prune removes the checkouts that have gone unused for longer than
+// opt.MaxAge, and then the least recently used checkouts until the cache
+// is no larger than opt.MaxSize. It returns the removed checkouts.
+func (c *Cache) Prune(opt PruneOptions) ([]*Entry, error) {
+	entries, tmpDirs, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+	var removed []*Entry
+	// Consider the least recently used checkouts first.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		age := time.Since(e.LastUsed)
+		if age < MinPruneAge {
+			break
+		}
+		if (opt.MaxAge == 0 || age <= opt.MaxAge) && (opt.MaxSize == 0 || size <= opt.MaxSize) {
+			continue
+		}
+		if !opt.DryRun {
+			if ok, err := removeCheckout(e); err != nil {
+				return removed, err
+			} else if !ok {
+				continue
+			}
+		}
+		size -= e.Size
+		removed = append(removed, e)
+	}
+
+	if !opt.DryRun {
+		for _, dir := range tmpDirs {
+			if err := os.RemoveAll(dir); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeCheckout removes the checkout (and its info file), while its
+// repository directory is locked so that it isn't being returned by Get.
+// It doesn't remove the checkout (and returns false) if it has been used
+// since it was listed.
+func removeCheckout(e *Entry) (bool, error) {
+	unlock, err := lockRepo(filepath.Dir(e.Dir))
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if fi, err := os.Stat(e.Dir + ".json"); err == nil && fi.ModTime().After(e.LastUsed) {
+		return false, nil
+	}
+	// Remove the info file first, so the checkout is no longer used even
+	// if it can't be removed entirely.
+	if err := os.Remove(e.Dir + ".json"); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, os.RemoveAll(e.Dir)
+}
@@ -0,0 +1,152 @@
+// Package detect guesses which languages a source tree is written in and
+// where its source units probably are, so that a starter Srcfile can be
+// written for it (see "src init") without reading toolchain-specific docs.
+package detect
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+// A Language is a language that srclib has a standard toolchain for.
+type Language struct {
+	Name string
+
+	// Toolchain is the toolchain path of the standard toolchain for the
+	// language, and UnitType is the type of the source units that its
+	// scanner finds.
+	Toolchain string
+	UnitType  string
+
+	// Extensions are the extensions (including the ".") of the language's
+	// source files.
+	Extensions []string
+
+	// Manifests are the names of files that mark the root directory of a
+	// source unit (such as "package.json"). If Manifests is empty, each
+	// directory that contains source files is a source unit.
+	Manifests []string
+}
+
+// Languages are the languages that Detect detects.
+var Languages = []*Language{
+	{Name: "Go", Toolchain: "sourcegraph.com/sourcegraph/srclib-go", UnitType: "GoPackage", Extensions: []string{".go"}},
+	{Name: "JavaScript", Toolchain: "sourcegraph.com/sourcegraph/srclib-javascript", UnitType: "CommonJSPackage", Extensions: []string{".js"}, Manifests: []string{"package.json"}},
+	{Name: "Python", Toolchain: "sourcegraph.com/sourcegraph/srclib-python", UnitType: "PipPackage", Extensions: []string{".py"}, Manifests: []string{"setup.py"}},
+	{Name: "Ruby", Toolchain: "sourcegraph.com/sourcegraph/srclib-ruby", UnitType: "RubyGem", Extensions: []string{".rb"}, Manifests: []string{"Gemfile", "*.gemspec"}},
+}
+
+// VendorDirs are the names of directories that usually contain vendored
+// dependencies or generated files, which Detect suggests skipping.
+var VendorDirs = []string{"node_modules", "bower_components", "vendor", "third_party", "_workspace"}
+
+// A Detected language is a language that some of a tree's files are
+// written in.
+type Detected struct {
+	*Language
+
+	// Files is the number of the tree's files in the language.
+	Files int
+
+	// UnitDirs are the directories (relative to the root of the tree) that
+	// probably contain a source unit in the language, in lexical order.
+	UnitDirs []string
+}
+
+// A Result describes a tree.
+type Result struct {
+	// Languages are the languages that the tree's files are written in, in
+	// order of decreasing number of files.
+	Languages []*Detected
+
+	// SkipDirs are the directories (relative to the root of the tree) that
+	// probably contain vendored dependencies or generated files, in lexical
+	// order. Their files are not counted.
+	SkipDirs []string
+}
+
+// Detect walks the tree fs and detects the languages that it is written in
+// and the source units that it probably contains. Hidden directories (such
+// as ".git") and "testdata" directories are ignored.
+func Detect(fs vfs.FileSystem) (*Result, error) {
+	res := &Result{}
+	detected := map[*Language]*Detected{}
+	unitDirs := map[*Language]map[string]bool{}
+	addUnitDir := func(lang *Language, dir string) {
+		if unitDirs[lang] == nil {
+			unitDirs[lang] = map[string]bool{}
+		}
+		unitDirs[lang][dir] = true
+	}
+
+	err := vfs.Walk(fs, ".", func(name string, fi os.FileInfo) error {
+		if fi.IsDir() {
+			if name == "." {
+				return nil
+			}
+			base := fi.Name()
+			if strings.HasPrefix(base, ".") || base == "testdata" {
+				return filepath.SkipDir
+			}
+			for _, v := range VendorDirs {
+				if base == v {
+					res.SkipDirs = append(res.SkipDirs, name)
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		dir, base := path.Dir(name), path.Base(name)
+		for _, lang := range Languages {
+			for _, m := range lang.Manifests {
+				if ok, _ := path.Match(m, base); ok {
+					addUnitDir(lang, dir)
+				}
+			}
+			for _, ext := range lang.Extensions {
+				if path.Ext(base) == ext {
+					d := detected[lang]
+					if d == nil {
+						d = &Detected{Language: lang}
+						detected[lang] = d
+					}
+					d.Files++
+					if len(lang.Manifests) == 0 {
+						addUnitDir(lang, dir)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for lang, d := range detected {
+		for dir := range unitDirs[lang] {
+			d.UnitDirs = append(d.UnitDirs, dir)
+		}
+		sort.Strings(d.UnitDirs)
+		res.Languages = append(res.Languages, d)
+	}
+	sort.Sort(byFiles(res.Languages))
+	return res, nil
+}
+
+type byFiles []*Detected
+
+func (v byFiles) Len() int      { return len(v) }
+func (v byFiles) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v byFiles) Less(i, j int) bool {
+	if v[i].Files != v[j].Files {
+		return v[i].Files > v[j].Files
+	}
+	return v[i].Name < v[j].Name
+}
@@ -0,0 +1,49 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func TestDetect(t *testing.T) {
+	fs := vfs.Map(map[string]string{
+		"main.go":                     "",
+		"foo/foo.go":                  "",
+		"foo/foo_test.go":             "",
+		"foo/testdata/x.go":           "",
+		".git/hooks/h.py":             "",
+		"web/package.json":            "",
+		"web/index.js":                "",
+		"web/lib/a.js":                "",
+		"web/node_modules/dep/dep.js": "",
+		"vendor/v/v.go":               "",
+		"gem/x.gemspec":               "",
+		"README.md":                   "",
+	})
+	res, err := Detect(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type lang struct {
+		name     string
+		files    int
+		unitDirs []string
+	}
+	var got []lang
+	for _, d := range res.Languages {
+		got = append(got, lang{d.Name, d.Files, d.UnitDirs})
+	}
+	want := []lang{
+		{"Go", 3, []string{".", "foo"}},
+		{"JavaScript", 2, []string{"web"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got languages %+v, want %+v", got, want)
+	}
+	if want := []string{"vendor", "web/node_modules"}; !reflect.DeepEqual(res.SkipDirs, want) {
+		t.Errorf("got SkipDirs %v, want %v", res.SkipDirs, want)
+	}
+}
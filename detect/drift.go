@@ -0,0 +1,92 @@
+package detect
+
+import (
+	"path"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+)
+
+// A Drift describes what a tree's config (its Srcfile) doesn't cover that
+// Detect found in the tree, such as a language that was added after the
+// Srcfile was written.
+type Drift struct {
+	// Languages are the detected languages whose standard toolchain has no
+	// scanner in the config's Scanners. It is empty if the config doesn't
+	// list Scanners (because then all installed scanners run).
+	Languages []*Detected
+
+	// UnitDirs maps the names of detected languages to the directories that
+	// probably contain a source unit in the language but aren't the Dir of
+	// any of the config's SourceUnits of the language's unit type. It only
+	// has entries for languages whose source units the config lists
+	// explicitly (since otherwise the scanner finds them).
+	UnitDirs map[string][]string
+
+	// SkipDirs are the detected directories of vendored dependencies that
+	// aren't in (or under a directory in) the config's SkipDirs.
+	SkipDirs []string
+}
+
+// Empty is whether the config covers everything that was detected.
+func (d *Drift) Empty() bool {
+	return len(d.Languages) == 0 && len(d.UnitDirs) == 0 && len(d.SkipDirs) == 0
+}
+
+// Compare returns what the tree config cfg doesn't cover in res (the result
+// of running Detect on the tree).
+func Compare(cfg *config.Tree, res *Result) *Drift {
+	d := &Drift{UnitDirs: map[string][]string{}}
+
+	for _, dir := range res.SkipDirs {
+		if !underAny(dir, cfg.SkipDirs) {
+			d.SkipDirs = append(d.SkipDirs, dir)
+		}
+	}
+
+	for _, lang := range res.Languages {
+		if cfg.Scanners != nil && !hasScanner(cfg, lang.Toolchain) {
+			d.Languages = append(d.Languages, lang)
+			continue
+		}
+
+		unitDirs := map[string]bool{}
+		for _, u := range cfg.SourceUnits {
+			if u.Type == lang.UnitType {
+				unitDirs[path.Clean(u.Dir)] = true
+			}
+		}
+		if len(unitDirs) == 0 {
+			continue
+		}
+		for _, dir := range lang.UnitDirs {
+			if !unitDirs[dir] && !underAny(dir, cfg.SkipDirs) {
+				d.UnitDirs[lang.Name] = append(d.UnitDirs[lang.Name], dir)
+			}
+		}
+	}
+	if len(d.UnitDirs) == 0 {
+		d.UnitDirs = nil
+	}
+	return d
+}
+
+func hasScanner(cfg *config.Tree, toolchainPath string) bool {
+	for _, s := range cfg.Scanners {
+		if s.Toolchain == toolchainPath {
+			return true
+		}
+	}
+	return false
+}
+
+// underAny returns whether dir is one of dirs or is under one of them.
+func underAny(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		d = path.Clean(d)
+		if dir == d || d == "." || strings.HasPrefix(dir, d+"/") {
+			return true
+		}
+	}
+	return false
+}
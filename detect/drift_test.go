@@ -0,0 +1,66 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestCompare(t *testing.T) {
+	goLang, jsLang := Languages[0], Languages[1]
+	res := &Result{
+		Languages: []*Detected{
+			{Language: goLang, Files: 3, UnitDirs: []string{".", "foo", "vendor2/x"}},
+			{Language: jsLang, Files: 1, UnitDirs: []string{"web"}},
+		},
+		SkipDirs: []string{"vendor", "web/node_modules"},
+	}
+
+	tests := map[string]struct {
+		cfg          *config.Tree
+		wantLangs    []string
+		wantUnitDirs map[string][]string
+		wantSkipDirs []string
+	}{
+		"default scanners": {
+			cfg:          &config.Tree{SkipDirs: []string{"web"}},
+			wantSkipDirs: []string{"vendor"},
+		},
+		"missing scanner": {
+			cfg: &config.Tree{
+				Scanners: []*toolchain.ToolRef{{Toolchain: goLang.Toolchain, Subcmd: "scan"}},
+				SkipDirs: []string{"vendor", "web/node_modules"},
+			},
+			wantLangs: []string{"JavaScript"},
+		},
+		"explicit source units": {
+			cfg: &config.Tree{
+				SourceUnits: []*unit.SourceUnit{{Name: "x", Type: "GoPackage", Dir: "./foo"}},
+				SkipDirs:    []string{"vendor", "web/node_modules", "vendor2"},
+			},
+			wantUnitDirs: map[string][]string{"Go": {"."}},
+		},
+	}
+	for label, test := range tests {
+		d := Compare(test.cfg, res)
+		var langs []string
+		for _, l := range d.Languages {
+			langs = append(langs, l.Name)
+		}
+		if !reflect.DeepEqual(langs, test.wantLangs) {
+			t.Errorf("%s: got languages %v, want %v", label, langs, test.wantLangs)
+		}
+		if !reflect.DeepEqual(d.UnitDirs, test.wantUnitDirs) {
+			t.Errorf("%s: got unit dirs %v, want %v", label, d.UnitDirs, test.wantUnitDirs)
+		}
+		if !reflect.DeepEqual(d.SkipDirs, test.wantSkipDirs) {
+			t.Errorf("%s: got skip dirs %v, want %v", label, d.SkipDirs, test.wantSkipDirs)
+		}
+		if empty := test.wantLangs == nil && test.wantUnitDirs == nil && test.wantSkipDirs == nil; d.Empty() != empty {
+			t.Errorf("%s: got Empty %v, want %v", label, d.Empty(), empty)
+		}
+	}
+}
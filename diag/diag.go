@@ -0,0 +1,134 @@
+// Package diag represents problems found while building or validating a tree
+// (such as a toolchain failing on a source unit, or invalid graph data) and
+// writes them in formats that CI systems can display inline on the affected
+// files.
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Notice  Severity = "notice"
+)
+
+// A Diagnostic is a problem, optionally located in a file.
+type Diagnostic struct {
+	Severity Severity
+
+	// File is the path of the file (relative to the repository root) that
+	// the problem is in, if any.
+	File string `json:",omitempty"`
+
+	// Line and Column are the 1-based position of the problem in File, if
+	// known.
+	Line   int `json:",omitempty"`
+	Column int `json:",omitempty"`
+
+	// Title is a short summary of the problem.
+	Title string `json:",omitempty"`
+
+	// Rule identifies the kind of problem (such as the ID of a security
+	// advisory), in formats (such as SARIF) that group problems by kind.
+	Rule string `json:",omitempty"`
+
+	Message string
+}
+
+// SetOffset sets d's Line and Column to the position of the byte offset in
+// d.File, which is read from the directory dir. If the file can't be read,
+// Line and Column are left unset.
+func (d *Diagnostic) SetOffset(dir string, offset int) {
+	if d.File == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, d.File))
+	if err != nil || offset < 0 || offset > len(data) {
+		return
+	}
+	before := data[:offset]
+	d.Line = bytes.Count(before, []byte{'\n'}) + 1
+	d.Column = offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+}
+
+// Formats lists the names of the formats supported by Write.
+var Formats = []string{"github", "json", "sarif"}
+
+// Write writes diags to w in the named format:
+//
+// "github" writes GitHub Actions workflow commands (such as
+// "::error file=a.go,line=1::message"), which GitHub displays as annotations
+// on pull requests.
+//
+// "json" writes each diagnostic as a JSON object on its own line.
+//
+// "sarif" writes a SARIF 2.1.0 log (see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/), which code scanning
+// services (such as GitHub's) import.
+func Write(w io.Writer, format string, diags []*Diagnostic) error {
+	switch format {
+	case "github":
+		for _, d := range diags {
+			if _, err := io.WriteString(w, githubCommand(d)+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		for _, d := range diags {
+			if err := enc.Encode(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "sarif":
+		return writeSARIF(w, diags)
+	}
+	return fmt.Errorf("unknown diagnostic format %q (choose from: %s)", format, strings.Join(Formats, ", "))
+}
+
+func githubCommand(d *Diagnostic) string {
+	var props []string
+	if d.File != "" {
+		props = append(props, "file="+githubEscapeProperty(filepath.ToSlash(d.File)))
+		if d.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", d.Line))
+			if d.Column > 0 {
+				props = append(props, fmt.Sprintf("col=%d", d.Column))
+			}
+		}
+	}
+	if d.Title != "" {
+		props = append(props, "title="+githubEscapeProperty(d.Title))
+	}
+
+	severity := d.Severity
+	if severity == "" {
+		severity = Error
+	}
+	cmd := "::" + string(severity)
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	return cmd + "::" + githubEscapeData(d.Message)
+}
+
+var (
+	githubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func githubEscapeData(s string) string     { return githubDataEscaper.Replace(s) }
+func githubEscapeProperty(s string) string { return githubPropertyEscaper.Replace(s) }
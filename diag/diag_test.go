@@ -0,0 +1,102 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWrite_github(t *testing.T) {
+	diags := []*Diagnostic{
+		{Severity: Error, File: "a/b.go", Line: 3, Column: 2, Title: "graph: failed", Message: "line 1\nline 2, 100%"},
+		{Severity: Warning, Message: "no location"},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "github", diags); err != nil {
+		t.Fatal(err)
+	}
+	want := "::error file=a/b.go,line=3,col=2,title=graph%3A failed::line 1%0Aline 2, 100%25\n" +
+		"::warning::no location\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrite_sarif(t *testing.T) {
+	diags := []*Diagnostic{
+		{Severity: Error, File: "a/b.go", Line: 3, Column: 2, Title: "graph: failed", Message: "m1"},
+		{Severity: Warning, Title: "vulnerable dependency x", Rule: "GHSA-1", Message: "m2"},
+		{Severity: Notice, Title: "graph: failed", Message: "m3"},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "sarif", diags); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(log.Runs))
+	}
+	run := log.Runs[0]
+	var rules []string
+	for _, r := range run.Tool.Driver.Rules {
+		rules = append(rules, r.ID)
+	}
+	if want := []string{"graph: failed", "GHSA-1"}; !reflect.DeepEqual(rules, want) {
+		t.Errorf("got rules %v, want %v", rules, want)
+	}
+	var levels []string
+	for _, r := range run.Results {
+		levels = append(levels, r.RuleID+" "+r.Level)
+	}
+	if want := []string{"graph: failed error", "GHSA-1 warning", "graph: failed note"}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("got results %v, want %v", levels, want)
+	}
+	if loc := run.Results[0].Locations; len(loc) != 1 || loc[0].PhysicalLocation.ArtifactLocation.URI != "a/b.go" || *loc[0].PhysicalLocation.Region != (sarifRegion{StartLine: 3, StartColumn: 2}) {
+		t.Errorf("got location %+v", loc)
+	}
+	if loc := run.Results[1].Locations; loc != nil {
+		t.Errorf("got location %+v, want none", loc)
+	}
+}
+
+func TestWrite_unknownFormat(t *testing.T) {
+	if err := Write(ioutil.Discard, "xml", nil); err == nil {
+		t.Error("got no error for unknown format")
+	}
+}
+
+func TestDiagnosticSetOffset(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-diag-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "f"), []byte("ab\ncde\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset       int
+		line, column int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{5, 2, 3},
+		{100, 0, 0},
+	}
+	for _, test := range tests {
+		d := &Diagnostic{File: "f"}
+		d.SetOffset(tmpdir, test.offset)
+		if d.Line != test.line || d.Column != test.column {
+			t.Errorf("offset %d: got %d:%d, want %d:%d", test.offset, d.Line, d.Column, test.line, test.column)
+		}
+	}
+}
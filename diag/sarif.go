@@ -0,0 +1,107 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// These types are the subset of the SARIF 2.1.0 object model that Write
+// uses.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules,omitempty"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *sarifRegion `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+)
+
+// sarifLevels maps severities to SARIF result levels.
+var sarifLevels = map[Severity]string{Error: "error", Warning: "warning", Notice: "note"}
+
+func writeSARIF(w io.Writer, diags []*Diagnostic) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "srclib", InformationURI: "https://srclib.org"}},
+		Results: []sarifResult{},
+	}
+	rules := map[string]bool{}
+	for _, d := range diags {
+		// Each result must have a rule. Diagnostics without one are
+		// grouped by their title.
+		ruleID := d.Rule
+		if ruleID == "" {
+			ruleID = d.Title
+		}
+		if ruleID == "" {
+			ruleID = "srclib"
+		}
+		if !rules[ruleID] {
+			rules[ruleID] = true
+			desc := d.Title
+			if desc == "" {
+				desc = ruleID
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID, ShortDescription: sarifMessage{desc}})
+		}
+
+		level := sarifLevels[d.Severity]
+		if level == "" {
+			level = "error"
+		}
+		r := sarifResult{RuleID: ruleID, Level: level, Message: sarifMessage{d.Message}}
+		if d.File != "" {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(d.File)
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			r.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
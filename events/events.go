@@ -0,0 +1,141 @@
+// Package events writes a stream of structured events that describe the
+// lifecycle of a build (such as its phases starting and finishing, build
+// steps for source units failing, and artifacts being written), so that
+// orchestration systems can track builds without scraping their logs.
+//
+// The stream is a sequence of JSON-encoded Events, one per line.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Type is a type of event.
+type Type string
+
+const (
+	// BuildStarted and BuildFinished are the first and last events of a
+	// build. If the build failed, BuildFinished's Error is set.
+	BuildStarted  Type = "build.started"
+	BuildFinished Type = "build.finished"
+
+	// PhaseStarted and PhaseFinished delimit a phase of the build (such as
+	// "configure", "plan", or "execute").
+	PhaseStarted  Type = "phase.started"
+	PhaseFinished Type = "phase.finished"
+
+	// UnitStarted, UnitFinished, and UnitFailed report the progress of a
+	// build step (identified by Step, its target) for a source unit.
+	UnitStarted  Type = "unit.started"
+	UnitFinished Type = "unit.finished"
+	UnitFailed   Type = "unit.failed"
+
+	// ArtifactWritten reports that a build step wrote the file Path.
+	ArtifactWritten Type = "artifact.written"
+
+	// CacheHit reports that a build step was skipped because its output
+	// (Path) is up to date.
+	CacheHit Type = "cache.hit"
+)
+
+// An Event is an event in a build's lifecycle.
+type Event struct {
+	Time time.Time
+	Type Type
+
+	Phase string `json:",omitempty"`
+
+	UnitName string `json:",omitempty"`
+	UnitType string `json:",omitempty"`
+	Step     string `json:",omitempty"`
+
+	// Path is the path of the artifact that was written or reused.
+	Path string `json:",omitempty"`
+
+	// Duration is how long the phase, build step, or build took (for
+	// events that finish one).
+	Duration time.Duration `json:",omitempty"`
+
+	Error string `json:",omitempty"`
+}
+
+// A Writer writes events to a stream. Its methods may be called
+// concurrently, and a nil *Writer discards events.
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	c   io.Closer // closed by Close, if non-nil
+	err error     // the first write error
+}
+
+// NewWriter returns a writer of events to w.
+func NewWriter(w io.Writer) *Writer { return &Writer{w: w} }
+
+// Open returns a writer of events to dest, which is either the path of a
+// file (which is created or truncated) or
+// "fd:N", to write to the open file descriptor N (such as one that an
+// orchestrator passed to the build process).
+func Open(dest string) (*Writer, error) {
+	if strings.HasPrefix(dest, "fd:") {
+		fd, err := strconv.ParseUint(strings.TrimPrefix(dest, "fd:"), 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event stream file descriptor %q", dest)
+		}
+		f := os.NewFile(uintptr(fd), dest)
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("event stream file descriptor %d is not open: %s", fd, err)
+		}
+		return &Writer{w: f, c: f}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: f, c: f}, nil
+}
+
+// Emit writes e (setting its Time to the current time, if it is zero). If
+// writing fails, the error is returned by Close, and later events are
+// discarded, so that a broken stream doesn't fail the build.
+func (w *Writer) Emit(e *Event) {
+	if w == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		panic(err) // an Event can always be marshaled
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		_, w.err = w.w.Write(append(data, '\n'))
+	}
+}
+
+// Close closes the stream (if Open opened it) and returns the first error
+// that occurred while writing to it.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	if w.c != nil {
+		if cerr := w.c.Close(); err == nil {
+			err = cerr
+		}
+		w.c = nil
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	file := filepath.Join(tmpDir, "events.json")
+
+	w, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []*Event{
+		{Time: t0, Type: PhaseStarted, Phase: "execute"},
+		{Time: t0, Type: UnitFailed, UnitName: "u", UnitType: "t", Step: "u.graph.json", Error: "exit status 1"},
+		{Time: t0, Type: PhaseFinished, Phase: "execute", Duration: time.Second},
+	}
+	for _, e := range want {
+		w.Emit(e)
+	}
+	w.Emit(&Event{Type: BuildFinished})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []*Event
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var e *Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %s", s.Bytes(), err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != len(want)+1 {
+		t.Fatalf("got %d events, want %d", len(got), len(want)+1)
+	}
+	if last := got[len(got)-1]; last.Type != BuildFinished || last.Time.IsZero() {
+		t.Errorf("got last event %+v, want %s with the time set", last, BuildFinished)
+	}
+	for i, e := range got[:len(want)] {
+		e.Time = e.Time.UTC()
+		if !reflect.DeepEqual(e, want[i]) {
+			t.Errorf("event %d: got %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	// A nil Writer discards events.
+	var nilW *Writer
+	nilW.Emit(&Event{Type: BuildStarted})
+	if err := nilW.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpen_fd(t *testing.T) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err := Open("fd:" + strconv.Itoa(int(pw.Fd())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Emit(&Event{Type: BuildStarted})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var e *Event
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != BuildStarted {
+		t.Errorf("got event %+v, want %s", e, BuildStarted)
+	}
+
+	for _, dest := range []string{"fd:x", "fd:12345"} {
+		if _, err := Open(dest); err == nil {
+			t.Errorf("%s: got no error", dest)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package githooks installs commands in git hooks (alongside any commands
+// that the hooks already run) and coalesces the runs that they start.
+package githooks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The lines that delimit the commands that Install adds to a hook.
+const (
+	beginMarker = "# BEGIN srclib (installed by `src hooks install`)"
+	endMarker   = "# END srclib"
+)
+
+// Install adds the shell command cmd to the hook script in hooksDir named
+// hook (such as "post-checkout"), creating the script if needed. If the
+// script already has a command that Install added, it is replaced. Other
+// commands in the script are kept. The command is added right after the
+// "#!" line, so that it runs even if the script exits before its end (as
+// hooks that end with "exit $status" do). Install returns an error if the
+// script exists but isn't a shell script.
+func Install(hooksDir, hook, cmd string) error {
+	file := filepath.Join(hooksDir, hook)
+	script, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		script = []byte("#!/bin/sh\n")
+	} else if err != nil {
+		return err
+	} else if !isShellScript(script) {
+		return fmt.Errorf("%s hook %s isn't a shell script (add this command to it yourself: %s)", hook, file, cmd)
+	}
+
+	script, _ = removeBlock(script)
+	i := bytes.IndexByte(script, '\n') + 1
+	if i == 0 {
+		script = append(script, '\n')
+		i = len(script)
+	}
+	block := fmt.Sprintf("%s\n%s\n%s\n", beginMarker, cmd, endMarker)
+	script = append(script[:i:i], append([]byte(block), script[i:]...)...)
+
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, script, 0755)
+}
+
+// Uninstall removes the command that Install added from the hook script in
+// hooksDir named hook. If the script then runs no other commands, it is
+// removed. Uninstall returns whether the script had a command that Install
+// added.
+func Uninstall(hooksDir, hook string) (bool, error) {
+	file := filepath.Join(hooksDir, hook)
+	script, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	script, found := removeBlock(script)
+	if !found {
+		return false, nil
+	}
+	if isEmptyScript(script) {
+		return true, os.Remove(file)
+	}
+	return true, ioutil.WriteFile(file, script, 0755)
+}
+
+// Installed returns whether the hook script in hooksDir named hook has a
+// command that Install added.
+func Installed(hooksDir, hook string) bool {
+	script, err := ioutil.ReadFile(filepath.Join(hooksDir, hook))
+	if err != nil {
+		return false
+	}
+	_, found := removeBlock(script)
+	return found
+}
+
+// removeBlock removes the lines from beginMarker to endMarker from script,
+// and returns whether they were found.
+func removeBlock(script []byte) ([]byte, bool) {
+	start := bytes.Index(script, []byte(beginMarker+"\n"))
+	if start == -1 {
+		return script, false
+	}
+	end := bytes.Index(script[start:], []byte(endMarker+"\n"))
+	if end == -1 {
+		return script, false
+	}
+	end += start + len(endMarker) + 1
+	return append(script[:start:start], script[end:]...), true
+}
+
+func isShellScript(script []byte) bool {
+	line := string(script)
+	if i := strings.Index(line, "\n"); i != -1 {
+		line = line[:i]
+	}
+	for _, sh := range []string{"sh", "bash", "zsh", "dash", "ksh"} {
+		if strings.HasPrefix(line, "#!") && (strings.HasSuffix(line, "/"+sh) || strings.HasSuffix(line, " "+sh)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyScript returns whether script has only a "#!" line, blank lines,
+// and comments.
+func isEmptyScript(script []byte) bool {
+	for _, line := range strings.Split(string(script), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// RunCoalesced calls run, unless another process is already in a call to
+// RunCoalesced with the same lock file. In that case, it records that
+// another run is pending and returns immediately, and the other process
+// calls run again after its current call returns. So, when hooks start many
+// runs in quick succession (such as during a rebase), they are coalesced
+// into at most two consecutive runs.
+//
+// A lock file older than stale is assumed to have been left by a process
+// that crashed, and is removed.
+func RunCoalesced(lockFile string, stale time.Duration, run func() error) error {
+	pendingFile := lockFile + ".pending"
+	f, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		if fi, err := os.Stat(lockFile); err == nil && time.Since(fi.ModTime()) > stale {
+			os.Remove(lockFile)
+			return RunCoalesced(lockFile, stale, run)
+		}
+		return ioutil.WriteFile(pendingFile, nil, 0644)
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprintln(f, os.Getpid())
+	f.Close()
+	defer os.Remove(lockFile)
+
+	for {
+		os.Remove(pendingFile)
+		if err := run(); err != nil {
+			return err
+		}
+		if _, err := os.Stat(pendingFile); os.IsNotExist(err) {
+			return nil
+		}
+		// Keep the lock file fresh, so that it isn't considered stale.
+		now := time.Now()
+		os.Chtimes(lockFile, now, now)
+	}
+}
@@ -0,0 +1,149 @@
+package githooks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInstallAndUninstall(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "githooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	hooksDir := filepath.Join(tmpDir, "hooks")
+
+	read := func(hook string) string {
+		data, err := ioutil.ReadFile(filepath.Join(hooksDir, hook))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	block := beginMarker + "\nsrc hooks run\n" + endMarker + "\n"
+
+	// A new hook.
+	if err := Install(hooksDir, "post-merge", "src hooks run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-merge"), "#!/bin/sh\n"+block; got != want {
+		t.Errorf("got new hook %q, want %q", got, want)
+	}
+	if !Installed(hooksDir, "post-merge") {
+		t.Error("got Installed false after Install")
+	}
+
+	// Reinstalling replaces the command.
+	if err := Install(hooksDir, "post-merge", "src hooks run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-merge"), "#!/bin/sh\n"+block; got != want {
+		t.Errorf("got reinstalled hook %q, want %q", got, want)
+	}
+
+	// Uninstalling removes a hook that runs nothing else.
+	if removed, err := Uninstall(hooksDir, "post-merge"); err != nil || !removed {
+		t.Fatalf("got Uninstall %v, %v, want true, nil", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(hooksDir, "post-merge")); !os.IsNotExist(err) {
+		t.Errorf("got hook file after Uninstall (err %v), want it removed", err)
+	}
+
+	// An existing hook keeps its commands.
+	existing := "#!/usr/bin/env bash\necho hi"
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "post-checkout"), []byte(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(hooksDir, "post-checkout", "src hooks run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-checkout"), "#!/usr/bin/env bash\n"+block+"echo hi"; got != want {
+		t.Errorf("got existing hook %q, want %q", got, want)
+	}
+	if _, err := Uninstall(hooksDir, "post-checkout"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-checkout"), existing; got != want {
+		t.Errorf("got existing hook after Uninstall %q, want %q", got, want)
+	}
+
+	// The command runs before a hook's trailing exit.
+	existing = "#!/bin/sh\nmake -s\nexit $?\n"
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "post-commit"), []byte(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(hooksDir, "post-commit", "src hooks run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-commit"), "#!/bin/sh\n"+block+"make -s\nexit $?\n"; got != want {
+		t.Errorf("got hook with exit %q, want %q", got, want)
+	}
+
+	// A script with only a "#!" line and no newline.
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "post-applypatch"), []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(hooksDir, "post-applypatch", "src hooks run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := read("post-applypatch"), "#!/bin/sh\n"+block; got != want {
+		t.Errorf("got hook %q, want %q", got, want)
+	}
+
+	// A hook that isn't a shell script isn't modified.
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "post-rewrite"), []byte("#!/usr/bin/python\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(hooksDir, "post-rewrite", "src hooks run"); err == nil {
+		t.Error("got no error installing into a Python hook")
+	}
+}
+
+func TestRunCoalesced(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "githooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	lockFile := filepath.Join(tmpDir, "lock")
+
+	// A run that starts while another run is in progress makes the first
+	// run run again.
+	var runs int
+	err = RunCoalesced(lockFile, time.Hour, func() error {
+		runs++
+		if runs == 1 {
+			return RunCoalesced(lockFile, time.Hour, func() error {
+				t.Error("nested run ran")
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("got %d runs, want 2", runs)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Errorf("got lock file after runs (err %v), want it removed", err)
+	}
+
+	// A stale lock file is ignored.
+	if err := ioutil.WriteFile(lockFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(lockFile, old, old)
+	runs = 0
+	if err := RunCoalesced(lockFile, time.Hour, func() error { runs++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("got %d runs with a stale lock file, want 1", runs)
+	}
+}
@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"fmt"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// START Alias OMIT
+// An Alias records that a def is an alias of another def: for example, a name
+// bound by a Python "from x import y" statement, a JavaScript module that
+// re-exports another module's exports, or a Go type alias. Jump-to-def
+// follows aliases to the canonical def.
+type Alias struct {
+	// The def that is an alias
+	Repo     repo.URI `json:",omitempty"`
+	UnitType string   `json:",omitempty"`
+	Unit     string   `json:",omitempty"`
+	Path     DefPath
+
+	// The def that it is an alias of
+	DefRepo     repo.URI `json:",omitempty"`
+	DefUnitType string   `json:",omitempty"`
+	DefUnit     string   `json:",omitempty"`
+	DefPath     DefPath
+}
+
+// END Alias OMIT
+
+// AliasKey returns the key of the def that is an alias.
+func (a *Alias) AliasKey() DefKey {
+	return DefKey{Repo: a.Repo, UnitType: a.UnitType, Unit: a.Unit, Path: a.Path}
+}
+
+// DefKey returns the key of the def that a is an alias of.
+func (a *Alias) DefKey() DefKey {
+	return DefKey{Repo: a.DefRepo, UnitType: a.DefUnitType, Unit: a.DefUnit, Path: a.DefPath}
+}
+
+// Sorting
+
+type Aliases []*Alias
+
+func (a *Alias) sortKey() string {
+	return string(a.Path) + string(a.Repo) + a.UnitType + a.Unit + string(a.DefPath) + string(a.DefRepo) + a.DefUnitType + a.DefUnit
+}
+func (vs Aliases) Len() int           { return len(vs) }
+func (vs Aliases) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs Aliases) Less(i, j int) bool { return vs[i].sortKey() < vs[j].sortKey() }
+
+// FollowAliases follows the chain of aliases that starts at key to the
+// canonical def. The lookup func returns the alias whose alias def is the
+// given def, or nil if that def isn't an alias. FollowAliases returns the
+// canonical def's key and the keys of the aliases it followed (in order).
+func FollowAliases(key DefKey, lookup func(DefKey) (*Alias, error)) (DefKey, []DefKey, error) {
+	var followed []DefKey
+	seen := map[DefKey]bool{key: true}
+	for {
+		a, err := lookup(key)
+		if err != nil {
+			return DefKey{}, nil, err
+		}
+		if a == nil {
+			return key, followed, nil
+		}
+		followed = append(followed, key)
+		key = a.DefKey()
+		if seen[key] {
+			return DefKey{}, nil, fmt.Errorf("alias cycle involving def %q", key.Path)
+		}
+		seen[key] = true
+	}
+}
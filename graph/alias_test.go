@@ -0,0 +1,28 @@
+package graph
+
+import "testing"
+
+func TestFollowAliases(t *testing.T) {
+	aliases := map[DefPath]*Alias{
+		"a": {Path: "a", DefPath: "b"},
+		"b": {Path: "b", DefPath: "c"},
+		"x": {Path: "x", DefPath: "y"},
+		"y": {Path: "y", DefPath: "x"},
+	}
+	lookup := func(k DefKey) (*Alias, error) { return aliases[k.Path], nil }
+
+	key, followed, err := FollowAliases(DefKey{Path: "a"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Path != "c" {
+		t.Errorf("got canonical def %q, want %q", key.Path, "c")
+	}
+	if len(followed) != 2 || followed[0].Path != "a" || followed[1].Path != "b" {
+		t.Errorf("got followed aliases %v, want a and b", followed)
+	}
+
+	if _, _, err := FollowAliases(DefKey{Path: "x"}, lookup); err == nil {
+		t.Error("got no error for alias cycle, want error")
+	}
+}
@@ -118,6 +118,23 @@ type Def struct {
 	// code). For example, definitions in Go *_test.go files have Test = true.
 	Test bool `elastic:"type:boolean,index:not_analyzed" json:",omitempty"`
 
+	// Local is whether this def is scoped to a function or block (e.g., a
+	// local variable or parameter), so that it can only be referred to from
+	// inside its enclosing def. Local defs (and refs to them) are omitted if
+	// the source unit's IncludeLocals option is false.
+	Local bool `elastic:"type:boolean,index:not_analyzed" json:",omitempty"`
+
+	// Anonymous is whether this def has no name in the source code (e.g., an
+	// anonymous function, class, or closure). Graphers often give such defs
+	// paths that change between builds, so src replaces their paths with
+	// stable ones (see grapher.NameAnonymousDefs).
+	Anonymous bool `elastic:"type:boolean,index:not_analyzed" json:",omitempty"`
+
+	// Snippet is the def's source text (or its first lines). It is only set
+	// if the source unit's Snippets option is set (see
+	// unit.SourceUnit.Snippets).
+	Snippet string `elastic:"type:string,index:no" json:",omitempty"`
+
 	// Data contains additional language- and toolchain-specific information
 	// about the def. Data is used to construct function signatures,
 	// import/require statements, language-specific type descriptions, etc.
@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// The canonical string form of a def key is
+//
+//   REPO[@COMMIT]:UNITTYPE:UNIT:PATH
+//
+// in which each field is percent-encoded so that it contains no '%', ':',
+// '@', whitespace, or control characters. For example, the abstract key of
+// the Go func "bytes.NewBuffer" in the standard library is
+//
+//   :GoPackage:bytes:NewBuffer
+//
+// The URL form is the same, except that every character of a field that is
+// not unreserved in a URL (RFC 3986) or '/' is percent-encoded, so it can be
+// used as is in a URL path or fragment. ParseDefKey accepts either form.
+//
+// Use these (and not DefKey.String, which is JSON) to refer to defs on the
+// command line, in URLs, and in file names.
+
+// Format returns the canonical string form of the def key.
+func (k DefKey) Format() string { return k.format(escapeKeyField) }
+
+// URLString returns the URL form of the def key.
+func (k DefKey) URLString() string { return k.format(escapeKeyURLField) }
+
+func (k DefKey) format(escape func(string) string) string {
+	s := escape(string(k.Repo))
+	if k.CommitID != "" {
+		s += "@" + escape(k.CommitID)
+	}
+	return s + ":" + escape(k.UnitType) + ":" + escape(k.Unit) + ":" + escape(string(k.Path))
+}
+
+// ParseDefKey parses a def key in the canonical or URL form (see
+// DefKey.Format and DefKey.URLString).
+func ParseDefKey(s string) (DefKey, error) {
+	fields := strings.SplitN(s, ":", 4)
+	if len(fields) != 4 {
+		return DefKey{}, fmt.Errorf("invalid def key %q (want REPO[@COMMIT]:UNITTYPE:UNIT:PATH)", s)
+	}
+	repoField, commitID := fields[0], ""
+	if i := strings.Index(repoField, "@"); i != -1 {
+		repoField, commitID = repoField[:i], repoField[i+1:]
+		if commitID == "" {
+			return DefKey{}, fmt.Errorf("invalid def key %q (empty commit ID after '@')", s)
+		}
+	}
+	fields = []string{repoField, commitID, fields[1], fields[2], fields[3]}
+	for i, f := range fields {
+		var err error
+		if fields[i], err = url.PathUnescape(f); err != nil {
+			return DefKey{}, fmt.Errorf("invalid def key %q: %s", s, err)
+		}
+	}
+	return DefKey{Repo: repo.URI(fields[0]), CommitID: fields[1], UnitType: fields[2], Unit: fields[3], Path: DefPath(fields[4])}, nil
+}
+
+// Compare returns -1, 0, or 1 if k sorts before, the same as, or after
+// other. Def keys are ordered by Repo, CommitID, UnitType, Unit, and then
+// Path.
+func (k DefKey) Compare(other DefKey) int {
+	for _, f := range [...][2]string{
+		{string(k.Repo), string(other.Repo)},
+		{k.CommitID, other.CommitID},
+		{k.UnitType, other.UnitType},
+		{k.Unit, other.Unit},
+		{string(k.Path), string(other.Path)},
+	} {
+		if f[0] < f[1] {
+			return -1
+		} else if f[0] > f[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Less returns whether k sorts before other (see DefKey.Compare).
+func (k DefKey) Less(other DefKey) bool { return k.Compare(other) < 0 }
+
+// escapeKeyField percent-encodes the characters of a def key field that
+// would be ambiguous or hard to pass around in its canonical form.
+func escapeKeyField(s string) string {
+	return escapeBytes(s, func(c byte) bool {
+		return c <= ' ' || c >= 0x7f || c == '%' || c == ':' || c == '@'
+	})
+}
+
+// escapeKeyURLField percent-encodes the characters of a def key field that
+// aren't unreserved in a URL (other than '/').
+func escapeKeyURLField(s string) string {
+	return escapeBytes(s, func(c byte) bool {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			return false
+		case c == '-', c == '.', c == '_', c == '~', c == '/':
+			return false
+		}
+		return true
+	})
+}
+
+func escapeBytes(s string, shouldEscape func(byte) bool) string {
+	const hex = "0123456789ABCDEF"
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if shouldEscape(c) {
+			if b == nil {
+				b = append(make([]byte, 0, len(s)+8), s[:i]...)
+			}
+			b = append(b, '%', hex[c>>4], hex[c&15])
+		} else if b != nil {
+			b = append(b, c)
+		}
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
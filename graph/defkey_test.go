@@ -0,0 +1,86 @@
+package graph
+
+import "testing"
+
+func TestDefKey_Format(t *testing.T) {
+	tests := []struct {
+		key       DefKey
+		canonical string
+		url       string
+	}{
+		{
+			key:       DefKey{UnitType: "GoPackage", Unit: "bytes", Path: "NewBuffer"},
+			canonical: ":GoPackage:bytes:NewBuffer",
+			url:       ":GoPackage:bytes:NewBuffer",
+		},
+		{
+			key:       DefKey{Repo: "github.com/a/b", CommitID: "c", UnitType: "GoPackage", Unit: "github.com/a/b/c", Path: "T/M"},
+			canonical: "github.com/a/b@c:GoPackage:github.com/a/b/c:T/M",
+			url:       "github.com/a/b@c:GoPackage:github.com/a/b/c:T/M",
+		},
+		{
+			key:       DefKey{Repo: "r", UnitType: "MavenArtifact", Unit: "g:a", Path: "a b/x@y%z?#&=+"},
+			canonical: "r:MavenArtifact:g%3Aa:a%20b/x%40y%25z?#&=+",
+			url:       "r:MavenArtifact:g%3Aa:a%20b/x%40y%25z%3F%23%26%3D%2B",
+		},
+		{
+			key:       DefKey{Path: "é"},
+			canonical: ":::%C3%A9",
+			url:       ":::%C3%A9",
+		},
+	}
+	for _, test := range tests {
+		if got := test.key.Format(); got != test.canonical {
+			t.Errorf("%+v: got Format %q, want %q", test.key, got, test.canonical)
+		}
+		if got := test.key.URLString(); got != test.url {
+			t.Errorf("%+v: got URLString %q, want %q", test.key, got, test.url)
+		}
+		for _, s := range []string{test.canonical, test.url} {
+			key, err := ParseDefKey(s)
+			if err != nil {
+				t.Errorf("ParseDefKey(%q): %s", s, err)
+				continue
+			}
+			if key != test.key {
+				t.Errorf("ParseDefKey(%q): got %+v, want %+v", s, key, test.key)
+			}
+		}
+	}
+}
+
+func TestParseDefKey_invalid(t *testing.T) {
+	for _, s := range []string{"", "r:t:u", "r@:t:u:p", "r:t:u%zz:p"} {
+		if key, err := ParseDefKey(s); err == nil {
+			t.Errorf("ParseDefKey(%q): got %+v, want error", s, key)
+		}
+	}
+}
+
+func TestDefKey_Compare(t *testing.T) {
+	keys := []DefKey{
+		{Path: "b"},
+		{Unit: "a", Path: "a"},
+		{UnitType: "a"},
+		{CommitID: "a"},
+		{Repo: "a"},
+		{Repo: "a", Path: "a"},
+		{Repo: "b"},
+	}
+	for i, a := range keys {
+		for j, b := range keys {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.Compare(b); got != want {
+				t.Errorf("%+v.Compare(%+v): got %d, want %d", a, b, got, want)
+			}
+			if got := a.Less(b); got != (want < 0) {
+				t.Errorf("%+v.Less(%+v): got %v", a, b, got)
+			}
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package graph
 
-import "fmt"
+import (
+	"fmt"
+	"path"
+	"strings"
+)
 
 // A Qualification specifies how much to qualify names when formatting defs
 // and their type information.
@@ -99,6 +103,67 @@ func RegisterMakeDefFormatter(unitType string, f MakeDefFormatter) {
 	MakeDefFormatters[unitType] = f
 }
 
+// FormatterFor returns the DefFormatter for def that is made by the
+// MakeDefFormatter registered for its unit type. If there is none (or it
+// returns nil), it returns a generic DefFormatter that derives qualified
+// names from the def's path, unit, and repository, so that consumers can
+// display names of defs of any language without special cases.
+func FormatterFor(def *Def) DefFormatter {
+	if mk, present := MakeDefFormatters[def.UnitType]; present {
+		if f := mk(def); f != nil {
+			return f
+		}
+	}
+	return genericFormatter{def}
+}
+
+// genericFormatter is the DefFormatter for defs whose unit type has no
+// registered MakeDefFormatter. Its scope-qualified names are the def's path
+// with "." separators, which is right for many languages.
+type genericFormatter struct{ def *Def }
+
+func (f genericFormatter) Name(qual Qualification) string {
+	scope := strings.Replace(strings.Trim(string(f.def.Path), "/"), "/", ".", -1)
+	if scope == "" || qual == Unqualified {
+		if f.def.Name != "" {
+			return f.def.Name
+		}
+		return path.Base(string(f.def.Path))
+	}
+	unit := f.def.Unit
+	switch qual {
+	case ScopeQualified:
+		return scope
+	case DepQualified:
+		unit = path.Base(unit)
+	case RepositoryWideQualified:
+		if repo := string(f.def.Repo); repo != "" && strings.HasPrefix(unit, repo+"/") {
+			unit = path.Join(path.Base(repo), strings.TrimPrefix(unit, repo+"/"))
+		}
+	case LanguageWideQualified:
+		if f.def.Repo != "" && !strings.HasPrefix(unit, string(f.def.Repo)) {
+			unit = path.Join(string(f.def.Repo), unit)
+		}
+	}
+	if unit == "" || unit == "." {
+		return scope
+	}
+	return unit + "." + scope
+}
+
+func (f genericFormatter) Type(qual Qualification) string { return "" }
+
+func (f genericFormatter) NameAndTypeSeparator() string {
+	if f.def.Callable {
+		return ""
+	}
+	return " "
+}
+
+func (f genericFormatter) Language() string   { return "" }
+func (f genericFormatter) DefKeyword() string { return "" }
+func (f genericFormatter) Kind() string       { return string(f.def.Kind) }
+
 // DefFormatter formats a def.
 type DefFormatter interface {
 	// Name formats the def's name with the specified level of qualification.
@@ -152,17 +217,10 @@ type DefFormatter interface {
 // The flags:
 //   ' '    (in `% t`) prepend the language-specific delimiter between a def's name and type
 //
-// See DefFormatter for more information.
+// See DefFormatter for more information. Defs whose unit type has no
+// registered MakeDefFormatter are formatted generically (see FormatterFor).
 func PrintFormatter(s *Def) DefPrintFormatter {
-	mk, ok := MakeDefFormatters[s.UnitType]
-	if !ok {
-		panic("PrintFormatter: no formatter for unit type " + s.UnitType)
-	}
-	sf := mk(s)
-	if sf == nil {
-		panic("PrintFormatter: nil DefFormatter")
-	}
-	return &printFormatter{sf}
+	return &printFormatter{FormatterFor(s)}
 }
 
 type printFormatter struct{ DefFormatter }
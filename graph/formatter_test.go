@@ -75,3 +75,23 @@ func TestPrintFormatter(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatterFor_generic(t *testing.T) {
+	def := &Def{DefKey: DefKey{Repo: "github.com/user/repo", UnitType: "NoFormatter", Unit: "github.com/user/repo/mypkg", Path: "MyType/MyMethod"}, Name: "MyMethod", Kind: "method", Callable: true}
+	tests := map[Qualification]string{
+		Unqualified:             "MyMethod",
+		ScopeQualified:          "MyType.MyMethod",
+		DepQualified:            "mypkg.MyType.MyMethod",
+		RepositoryWideQualified: "repo/mypkg.MyType.MyMethod",
+		LanguageWideQualified:   "github.com/user/repo/mypkg.MyType.MyMethod",
+	}
+	f := FormatterFor(def)
+	for qual, want := range tests {
+		if name := f.Name(qual); name != want {
+			t.Errorf("Name(%q): got %q, want %q", qual, name, want)
+		}
+	}
+	if got, want := fmt.Sprintf("%k %.1n% t", PrintFormatter(def), PrintFormatter(def), PrintFormatter(def)), "method MyType.MyMethod"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"sort"
 	"strconv"
 
 	"sourcegraph.com/sourcegraph/srclib/repo"
@@ -49,6 +50,11 @@ type Ref struct {
 	// Def is true if this ref is the original definition or a redefinition
 	Def bool
 
+	// Kind is how the ref uses the def (e.g., whether it reads or writes a
+	// variable or calls a function), if the grapher can determine it. Refs
+	// with Def set have kind Declaration.
+	Kind RefKind `db:"kind" json:",omitempty"`
+
 	Repo repo.URI
 
 	// CommitID is the immutable commit ID (not the branch name) of the VCS
@@ -61,10 +67,104 @@ type Ref struct {
 	File  string
 	Start int
 	End   int
+
+	// Candidates lists the defs that this ref may point to, if the grapher
+	// could only narrow it down to several possible defs. The Def* fields
+	// above refer to the best candidate.
+	Candidates []*RefCandidate `db:"-" json:",omitempty"`
 }
 
 // END Ref OMIT
 
+// A RefKind is the way that a ref uses the def that it points to.
+type RefKind string
+
+const (
+	// Read means that the ref reads the value of the def (a variable, field,
+	// constant, etc.).
+	Read RefKind = "read"
+
+	// Write means that the ref assigns to the def.
+	Write RefKind = "write"
+
+	// Call means that the ref calls or invokes the def.
+	Call RefKind = "call"
+
+	// Import means that the ref imports (or includes, requires, etc.) the
+	// def.
+	Import RefKind = "import"
+
+	// Declaration means that the ref is the def's name in its definition or
+	// a redefinition.
+	Declaration RefKind = "declaration"
+)
+
+// RefKinds lists all of the valid ref kinds.
+var RefKinds = []RefKind{Read, Write, Call, Import, Declaration}
+
+// Valid reports whether k is one of RefKinds or is empty (meaning that the
+// kind is unknown).
+func (k RefKind) Valid() bool {
+	if k == "" {
+		return true
+	}
+	for _, k2 := range RefKinds {
+		if k == k2 {
+			return true
+		}
+	}
+	return false
+}
+
+// A RefCandidate is a def that an ambiguous ref may point to.
+type RefCandidate struct {
+	RefDefKey
+
+	// Score is the grapher's confidence (from 0 to 1) that the ref points to
+	// this def.
+	Score float64
+}
+
+// BestCandidate returns the ref's candidate with the highest score, or nil
+// if the ref has no candidates.
+func (r *Ref) BestCandidate() *RefCandidate {
+	var best *RefCandidate
+	for _, c := range r.Candidates {
+		if best == nil || c.Score > best.Score {
+			best = c
+		}
+	}
+	return best
+}
+
+// Alternates returns the ref's candidates other than the def that it refers
+// to (i.e., the candidates that it may point to instead), in order of
+// decreasing score.
+func (r *Ref) Alternates() []*RefCandidate {
+	var alts []*RefCandidate
+	for _, c := range r.Candidates {
+		if c.RefDefKey != r.RefDefKey() {
+			alts = append(alts, c)
+		}
+	}
+	sort.Sort(refCandidatesByScore(alts))
+	return alts
+}
+
+// SortCandidates sorts the ref's candidates in order of decreasing score.
+func (r *Ref) SortCandidates() { sort.Sort(refCandidatesByScore(r.Candidates)) }
+
+type refCandidatesByScore []*RefCandidate
+
+func (vs refCandidatesByScore) Len() int      { return len(vs) }
+func (vs refCandidatesByScore) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs refCandidatesByScore) Less(i, j int) bool {
+	if vs[i].Score != vs[j].Score {
+		return vs[i].Score > vs[j].Score
+	}
+	return string(vs[i].DefPath) < string(vs[j].DefPath)
+}
+
 func (r *Ref) RefKey() RefKey {
 	return RefKey{
 		DefRepo:     r.DefRepo,
@@ -110,8 +210,13 @@ func (r *Ref) SetFromDefKey(k DefKey) {
 
 type Refs []*Ref
 
-func (r *Ref) sortKey() string {
-	return string(r.DefPath) + string(r.DefRepo) + r.DefUnitType + r.DefUnit + string(r.Repo) + r.UnitType + r.Unit + r.File + strconv.Itoa(r.Start) + strconv.Itoa(r.End)
+func (r *Ref) sortKey() string { return string(r.appendSortKey(nil)) }
+func (r *Ref) appendSortKey(b []byte) []byte {
+	for _, s := range [...]string{string(r.DefPath), string(r.DefRepo), r.DefUnitType, r.DefUnit, string(r.Repo), r.UnitType, r.Unit, r.File} {
+		b = append(b, s...)
+	}
+	b = strconv.AppendInt(b, int64(r.Start), 10)
+	return strconv.AppendInt(b, int64(r.End), 10)
 }
 func (vs Refs) Len() int           { return len(vs) }
 func (vs Refs) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
@@ -120,20 +225,23 @@ func (vs Refs) Less(i, j int) bool { return vs[i].sortKey() < vs[j].sortKey() }
 // RefSet is a set of Refs. It can used to determine whether a grapher emits
 // duplicate refs.
 type RefSet struct {
-	refs map[Ref]struct{}
+	refs map[RefKey]struct{}
 }
 
 func NewRefSet() *RefSet {
-	return &RefSet{make(map[Ref]struct{})}
+	return &RefSet{make(map[RefKey]struct{})}
 }
 
 // AddAndCheckUnique adds ref to the set of seen refs, and returns whether the
-// ref already existed in the set.
+// ref already existed in the set. Refs that differ only in their candidates
+// are considered duplicates.
 func (c *RefSet) AddAndCheckUnique(ref Ref) (duplicate bool) {
-	_, present := c.refs[ref]
+	key := ref.RefKey()
+	key.CommitID = ref.CommitID
+	_, present := c.refs[key]
 	if present {
 		return true
 	}
-	c.refs[ref] = struct{}{}
+	c.refs[key] = struct{}{}
 	return false
 }
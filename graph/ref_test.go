@@ -0,0 +1,41 @@
+package graph
+
+import "testing"
+
+func TestRef_Candidates(t *testing.T) {
+	ref := &Ref{
+		DefPath: "b",
+		Candidates: []*RefCandidate{
+			{RefDefKey: RefDefKey{DefPath: "a"}, Score: 0.2},
+			{RefDefKey: RefDefKey{DefPath: "b"}, Score: 0.5},
+			{RefDefKey: RefDefKey{DefPath: "c"}, Score: 0.3},
+		},
+	}
+
+	if best := ref.BestCandidate(); best.DefPath != "b" {
+		t.Errorf("got best candidate %q, want %q", best.DefPath, "b")
+	}
+
+	alts := ref.Alternates()
+	if len(alts) != 2 || alts[0].DefPath != "c" || alts[1].DefPath != "a" {
+		t.Errorf("got alternates %+v, want c and a", alts)
+	}
+
+	if (&Ref{}).BestCandidate() != nil {
+		t.Error("got a best candidate for a ref with no candidates")
+	}
+}
+
+func TestRefKind_Valid(t *testing.T) {
+	tests := map[RefKind]bool{
+		"":          true,
+		Read:        true,
+		Declaration: true,
+		"mention":   false,
+	}
+	for kind, want := range tests {
+		if got := kind.Valid(); got != want {
+			t.Errorf("%q: got valid %v, want %v", kind, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+)
+
+// Sort sorts v, which is a Defs, Refs, Docs, Aliases, or TypeRelations
+// slice, in the order of sort.Sort(v), but faster for large slices: each
+// item's sort key is computed once (instead of on every comparison), and v
+// is left alone if it is already sorted (such as output that a grapher
+// streamed in order). Other types are sorted with sort.Sort.
+func Sort(v sort.Interface) {
+	var appendKey func(b []byte, i int) []byte
+	switch vs := v.(type) {
+	case Defs:
+		appendKey = func(b []byte, i int) []byte { return append(b, vs[i].sortKey()...) }
+	case Refs:
+		appendKey = func(b []byte, i int) []byte { return vs[i].appendSortKey(b) }
+	case Docs:
+		appendKey = func(b []byte, i int) []byte { return append(b, vs[i].sortKey()...) }
+	case Aliases:
+		appendKey = func(b []byte, i int) []byte { return append(b, vs[i].sortKey()...) }
+	case TypeRelations:
+		appendKey = func(b []byte, i int) []byte { return append(b, vs[i].sortKey()...) }
+	default:
+		sort.Sort(v)
+		return
+	}
+
+	// Write the keys into a buffer, and slice them out of a string copy of
+	// each 64 KB of it, so that there are few allocations however many
+	// items there are.
+	buf := sortKeyBufs.Get().(*[]byte)
+	b := (*buf)[:0]
+	keys := make([]string, v.Len())
+	ends := make([]int, len(keys)) // ends of the keys in b
+	first := 0                     // index of the first key in b
+	flush := func(next int) {
+		chunk, start := string(b), 0
+		for j := first; j < next; j++ {
+			keys[j], start = chunk[start:ends[j]], ends[j]
+		}
+		b, first = b[:0], next
+	}
+	for i := range keys {
+		b = appendKey(b, i)
+		ends[i] = len(b)
+		if len(b) >= 64<<10 {
+			flush(i + 1)
+		}
+	}
+	flush(len(keys))
+	*buf = b
+	sortKeyBufs.Put(buf)
+
+	sorted := true
+	for i := 1; i < len(keys); i++ {
+		if keys[i] < keys[i-1] {
+			sorted = false
+			break
+		}
+	}
+	if !sorted {
+		sort.Sort(keyedSort{v, keys})
+	}
+}
+
+// sortKeyBufs holds the buffers that Sort writes sort keys into, which are
+// reused because Sort is called often (and concurrently).
+var sortKeyBufs = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// keyedSort sorts a slice by precomputed sort keys, swapping the keys along
+// with the slice's items.
+type keyedSort struct {
+	sort.Interface
+	keys []string
+}
+
+func (s keyedSort) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s keyedSort) Swap(i, j int) {
+	s.Interface.Swap(i, j)
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	// Enough refs that their keys span several of Sort's buffers.
+	refs := make(Refs, 10000)
+	for i := range refs {
+		refs[i] = &Ref{DefPath: DefPath("p" + strconv.Itoa(rand.Intn(100))), File: "dir/f.go", Start: rand.Intn(1000)}
+	}
+	want := append(Refs{}, refs...)
+	sort.Sort(want)
+
+	Sort(refs)
+	if !sort.IsSorted(refs) {
+		t.Fatal("got unsorted refs")
+	}
+	for i := range refs {
+		if refs[i].sortKey() != want[i].sortKey() {
+			t.Fatalf("got ref %d %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+
+	// Already sorted input is left alone (even the order of equal items).
+	docs := Docs{{DefKey: DefKey{Path: "a"}, Data: "2"}, {DefKey: DefKey{Path: "a"}, Data: "1"}, {DefKey: DefKey{Path: "b"}}}
+	want2 := append(Docs{}, docs...)
+	Sort(docs)
+	if !reflect.DeepEqual(docs, want2) {
+		t.Errorf("got docs %+v, want unchanged", docs)
+	}
+}
@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// A TypeRelationKind is the kind of relationship between two types.
+type TypeRelationKind string
+
+const (
+	// Implements means that the subtype implements the supertype (an
+	// interface).
+	Implements TypeRelationKind = "implements"
+
+	// Extends means that the subtype inherits from the supertype (a class
+	// or interface).
+	Extends TypeRelationKind = "extends"
+)
+
+// START TypeRelation OMIT
+// A TypeRelation records that a def (the subtype) implements or extends
+// another def (the supertype).
+type TypeRelation struct {
+	Kind TypeRelationKind
+
+	// The subtype
+	Repo     repo.URI `json:",omitempty"`
+	UnitType string   `json:",omitempty"`
+	Unit     string   `json:",omitempty"`
+	Path     DefPath
+
+	// The supertype
+	DefRepo     repo.URI `json:",omitempty"`
+	DefUnitType string   `json:",omitempty"`
+	DefUnit     string   `json:",omitempty"`
+	DefPath     DefPath
+}
+
+// END TypeRelation OMIT
+
+// SubtypeKey returns the key of the subtype.
+func (r *TypeRelation) SubtypeKey() DefKey {
+	return DefKey{Repo: r.Repo, UnitType: r.UnitType, Unit: r.Unit, Path: r.Path}
+}
+
+// SupertypeKey returns the key of the supertype.
+func (r *TypeRelation) SupertypeKey() DefKey {
+	return DefKey{Repo: r.DefRepo, UnitType: r.DefUnitType, Unit: r.DefUnit, Path: r.DefPath}
+}
+
+// A TypeHierarchy answers queries about the relationships between types.
+type TypeHierarchy struct {
+	supertypes map[DefKey][]DefKey
+	subtypes   map[DefKey][]DefKey
+}
+
+// NewTypeHierarchy returns a TypeHierarchy of the given relations. The
+// relations' def keys should be complete (i.e., not rely on defaults for
+// empty Repo, UnitType, and Unit fields).
+func NewTypeHierarchy(rels []*TypeRelation) *TypeHierarchy {
+	h := &TypeHierarchy{
+		supertypes: make(map[DefKey][]DefKey),
+		subtypes:   make(map[DefKey][]DefKey),
+	}
+	for _, r := range rels {
+		sub, super := r.SubtypeKey(), r.SupertypeKey()
+		h.supertypes[sub] = append(h.supertypes[sub], super)
+		h.subtypes[super] = append(h.subtypes[super], sub)
+	}
+	return h
+}
+
+// Implementations returns the types that implement or extend the type,
+// directly or indirectly.
+func (h *TypeHierarchy) Implementations(key DefKey) []DefKey {
+	return closure(key, h.subtypes)
+}
+
+// Supertypes returns the types that the type implements or extends, directly
+// or indirectly.
+func (h *TypeHierarchy) Supertypes(key DefKey) []DefKey {
+	return closure(key, h.supertypes)
+}
+
+// closure returns the keys reachable from key in edges (excluding key
+// itself), sorted.
+func closure(key DefKey, edges map[DefKey][]DefKey) []DefKey {
+	seen := map[DefKey]bool{key: true}
+	queue := []DefKey{key}
+	var keys []DefKey
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		for _, k2 := range edges[k] {
+			if !seen[k2] {
+				seen[k2] = true
+				keys = append(keys, k2)
+				queue = append(queue, k2)
+			}
+		}
+	}
+	sort.Sort(defKeys(keys))
+	return keys
+}
+
+type defKeys []DefKey
+
+func (vs defKeys) Len() int           { return len(vs) }
+func (vs defKeys) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs defKeys) Less(i, j int) bool { return vs[i].Less(vs[j]) }
+
+// Sorting
+
+type TypeRelations []*TypeRelation
+
+func (r *TypeRelation) sortKey() string {
+	return string(r.Path) + string(r.Repo) + r.UnitType + r.Unit + string(r.DefPath) + string(r.DefRepo) + r.DefUnitType + r.DefUnit + string(r.Kind)
+}
+func (vs TypeRelations) Len() int           { return len(vs) }
+func (vs TypeRelations) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+func (vs TypeRelations) Less(i, j int) bool { return vs[i].sortKey() < vs[j].sortKey() }
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeHierarchy(t *testing.T) {
+	h := NewTypeHierarchy([]*TypeRelation{
+		{Kind: Implements, Path: "File", DefPath: "Reader"},
+		{Kind: Extends, Path: "Reader", DefPath: "Closer"},
+		{Kind: Extends, Path: "GzipFile", DefPath: "File"},
+		{Kind: Implements, Path: "Conn", DefPath: "Closer"},
+	})
+
+	if got, want := h.Implementations(DefKey{Path: "Reader"}), []DefKey{{Path: "File"}, {Path: "GzipFile"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got implementations %v, want %v", got, want)
+	}
+	if got, want := h.Supertypes(DefKey{Path: "GzipFile"}), []DefKey{{Path: "Closer"}, {Path: "File"}, {Path: "Reader"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got supertypes %v, want %v", got, want)
+	}
+	if got := h.Supertypes(DefKey{Path: "Closer"}); len(got) != 0 {
+		t.Errorf("got supertypes %v, want none", got)
+	}
+}
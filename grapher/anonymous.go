@@ -0,0 +1,176 @@
+package grapher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// anonHashLen is the number of hex digits of the content hash in the paths
+// of anonymous defs.
+const anonHashLen = 8
+
+// NameAnonymousDefs replaces the paths of the anonymous defs (those with
+// Anonymous set) in o with stable paths, so that they keep their identity
+// across builds even if the grapher names them differently (e.g., by a
+// global counter). Refs, docs, etc., that point to the anonymous defs (or to
+// defs nested in them) are updated to use the new paths.
+//
+// An anonymous def's path is the path of its enclosing def (the smallest def
+// in the same file whose extent contains it, or the file itself if there is
+// none) plus a component of the form "$anonN.HASH", where N is the ordinal of
+// the def among the anonymous defs with the same enclosing def (in source
+// order) and HASH is a hash of its source text. The readFile func returns
+// the contents of a file; if it is nil or fails, the hash is of the def's
+// kind and data instead.
+func NameAnonymousDefs(o *Output, readFile func(file string) ([]byte, error)) {
+	var anons []*graph.Def
+	for _, def := range o.Defs {
+		if def.Anonymous {
+			anons = append(anons, def)
+		}
+	}
+	if len(anons) == 0 {
+		return
+	}
+
+	// Name the outermost anonymous defs first, so that the paths of nested
+	// ones are based on the new paths of their enclosing defs.
+	sort.Sort(defsByExtent(anons))
+
+	files := make(map[string][]byte)
+	source := func(def *graph.Def) []byte {
+		if readFile == nil || def.File == "" {
+			return nil
+		}
+		data, present := files[def.File]
+		if !present {
+			var err error
+			if data, err = readFile(def.File); err != nil {
+				data = nil
+			}
+			files[def.File] = data
+		}
+		if def.DefStart < 0 || def.DefEnd > len(data) || def.DefStart >= def.DefEnd {
+			return nil
+		}
+		return data[def.DefStart:def.DefEnd]
+	}
+
+	renamed := make(map[graph.DefPath]graph.DefPath, len(anons))
+	ordinals := make(map[graph.DefPath]int)
+	for _, def := range anons {
+		var parent graph.DefPath
+		if enc := enclosingDef(def, o.Defs); enc != nil {
+			parent = rewriteDefPath(enc.Path, renamed)
+		} else {
+			parent = graph.DefPath(def.File)
+		}
+
+		h := sha1.New()
+		if src := source(def); src != nil {
+			h.Write(src)
+		} else {
+			fmt.Fprintf(h, "%s\x00%s", def.Kind, def.Data)
+		}
+		hash := hex.EncodeToString(h.Sum(nil))[:anonHashLen]
+
+		name := fmt.Sprintf("$anon%d.%s", ordinals[parent], hash)
+		ordinals[parent]++
+		renamed[def.Path] = graph.DefPath(path.Join(string(parent), name))
+	}
+
+	for _, def := range o.Defs {
+		def.Path = rewriteDefPath(def.Path, renamed)
+	}
+	for _, ref := range o.Refs {
+		if ref.DefRepo == "" && ref.DefUnitType == "" && ref.DefUnit == "" {
+			ref.DefPath = rewriteDefPath(ref.DefPath, renamed)
+		}
+		for _, c := range ref.Candidates {
+			if c.DefRepo == "" && c.DefUnitType == "" && c.DefUnit == "" {
+				c.DefPath = rewriteDefPath(c.DefPath, renamed)
+			}
+		}
+	}
+	for _, doc := range o.Docs {
+		if doc.Repo == "" && doc.UnitType == "" && doc.Unit == "" {
+			doc.Path = rewriteDefPath(doc.Path, renamed)
+		}
+	}
+	for _, a := range o.Aliases {
+		if a.Repo == "" && a.UnitType == "" && a.Unit == "" {
+			a.Path = rewriteDefPath(a.Path, renamed)
+		}
+		if a.DefRepo == "" && a.DefUnitType == "" && a.DefUnit == "" {
+			a.DefPath = rewriteDefPath(a.DefPath, renamed)
+		}
+	}
+	for _, r := range o.TypeRelations {
+		if r.Repo == "" && r.UnitType == "" && r.Unit == "" {
+			r.Path = rewriteDefPath(r.Path, renamed)
+		}
+		if r.DefRepo == "" && r.DefUnitType == "" && r.DefUnit == "" {
+			r.DefPath = rewriteDefPath(r.DefPath, renamed)
+		}
+	}
+
+	sortedOutput(o)
+}
+
+// enclosingDef returns the smallest def (other than def) in defs that is in
+// the same file as def and whose extent contains def's, or nil if there is
+// none.
+func enclosingDef(def *graph.Def, defs []*graph.Def) *graph.Def {
+	var enc *graph.Def
+	for _, d := range defs {
+		if d == def || d.File != def.File || d.DefStart > def.DefStart || d.DefEnd < def.DefEnd {
+			continue
+		}
+		if d.DefStart == def.DefStart && d.DefEnd == def.DefEnd {
+			// Defs with identical extents don't enclose each other.
+			continue
+		}
+		if enc == nil || d.DefEnd-d.DefStart < enc.DefEnd-enc.DefStart {
+			enc = d
+		}
+	}
+	return enc
+}
+
+// rewriteDefPath returns p with its longest prefix (of whole path
+// components) that is a key in renamed replaced by the corresponding value.
+func rewriteDefPath(p graph.DefPath, renamed map[graph.DefPath]graph.DefPath) graph.DefPath {
+	s := string(p)
+	for i := len(s); i > 0; i = strings.LastIndex(s[:i], "/") {
+		if n, present := renamed[graph.DefPath(s[:i])]; present {
+			return n + graph.DefPath(s[i:])
+		}
+	}
+	return p
+}
+
+// defsByExtent sorts defs by file and then in source order, with enclosing
+// defs before the defs they enclose.
+type defsByExtent []*graph.Def
+
+func (vs defsByExtent) Len() int      { return len(vs) }
+func (vs defsByExtent) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs defsByExtent) Less(i, j int) bool {
+	a, b := vs[i], vs[j]
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.DefStart != b.DefStart {
+		return a.DefStart < b.DefStart
+	}
+	if a.DefEnd != b.DefEnd {
+		return a.DefEnd > b.DefEnd
+	}
+	return a.Path < b.Path
+}
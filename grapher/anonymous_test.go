@@ -0,0 +1,71 @@
+package grapher
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestNameAnonymousDefs(t *testing.T) {
+	src := "function f() { g(function () { var x; }); h(function () {}); }"
+	readFile := func(file string) ([]byte, error) {
+		if file == "a.js" {
+			return []byte(src), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	inner1 := strings.Index(src, "function ()")
+	inner2 := strings.LastIndex(src, "function ()")
+
+	// The grapher numbered the anonymous functions with a counter, which
+	// would change if (e.g.) another file were graphed first.
+	output := func() *Output {
+		return &Output{
+			Defs: []*graph.Def{
+				{DefKey: graph.DefKey{Path: "f"}, File: "a.js", DefStart: 0, DefEnd: len(src)},
+				{DefKey: graph.DefKey{Path: "f/func17"}, File: "a.js", DefStart: inner1, DefEnd: inner1 + 24, Anonymous: true},
+				{DefKey: graph.DefKey{Path: "f/func17/x"}, File: "a.js", DefStart: inner1 + 20, DefEnd: inner1 + 21},
+				{DefKey: graph.DefKey{Path: "f/func18"}, File: "a.js", DefStart: inner2, DefEnd: inner2 + 14, Anonymous: true},
+				{DefKey: graph.DefKey{Path: "func19"}, File: "b.js", DefStart: 0, DefEnd: 10, Kind: "func", Anonymous: true},
+			},
+			Refs: []*graph.Ref{
+				{DefPath: "f/func17/x", File: "a.js", Start: inner1 + 20, End: inner1 + 21},
+				{DefUnit: "other", DefUnitType: "t", DefPath: "f/func17/x", File: "a.js"},
+			},
+		}
+	}
+
+	o := output()
+	NameAnonymousDefs(o, readFile)
+
+	var paths []string
+	for _, def := range o.Defs {
+		paths = append(paths, string(def.Path))
+	}
+	// Defs are sorted by path after renaming.
+	if len(paths) != 5 || !strings.HasPrefix(paths[0], "b.js/$anon0.") || paths[1] != "f" || !strings.HasPrefix(paths[2], "f/$anon0.") || paths[3] != paths[2]+"/x" || !strings.HasPrefix(paths[4], "f/$anon1.") {
+		t.Fatalf("got def paths %v", paths)
+	}
+	if hashLen := len(paths[2]) - len("f/$anon0."); hashLen != anonHashLen {
+		t.Errorf("got hash length %d, want %d", hashLen, anonHashLen)
+	}
+	for _, ref := range o.Refs {
+		if ref.DefUnit == "other" {
+			if ref.DefPath != "f/func17/x" {
+				t.Errorf("ref to a def in another unit was renamed to %q", ref.DefPath)
+			}
+		} else if string(ref.DefPath) != paths[3] {
+			t.Errorf("got ref to def in anonymous def %q, want %q", ref.DefPath, paths[3])
+		}
+	}
+
+	// Naming is deterministic.
+	o2 := output()
+	NameAnonymousDefs(o2, readFile)
+	if !reflect.DeepEqual(o, o2) {
+		t.Error("got different names when run twice")
+	}
+}
@@ -0,0 +1,100 @@
+package grapher
+
+import (
+	"fmt"
+	"log"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// MergeDocs merges the docs with the same def and format (e.g., a def's
+// comment and its annotation) into one doc, whose Data is their distinct
+// non-empty Data joined by blank lines and whose location is that of the
+// first. Docs with different formats aren't merged, since their Data can't
+// be combined.
+func MergeDocs(docs []*graph.Doc) []*graph.Doc {
+	type docKey struct {
+		graph.DefKey
+		format string
+	}
+	merged := make(map[docKey]*graph.Doc, len(docs))
+	seen := make(map[docKey]map[string]bool) // only for defs with several docs
+	out := docs[:0]
+	for _, doc := range docs {
+		k := docKey{doc.DefKey, doc.Format}
+		d, present := merged[k]
+		if !present {
+			merged[k] = doc
+			out = append(out, doc)
+			continue
+		}
+		if seen[k] == nil {
+			seen[k] = map[string]bool{d.Data: true}
+		}
+		if doc.Data == "" || seen[k][doc.Data] {
+			continue
+		}
+		seen[k][doc.Data] = true
+		if d.Data == "" {
+			d.Data = doc.Data
+		} else {
+			d.Data += "\n\n" + doc.Data
+		}
+	}
+	return out
+}
+
+// ValidateDocs checks that each doc describes one of defs, which has the
+// doc's path (and its repo, unit type, and unit, if they're set), so that it
+// isn't an orphan. Each error in the returned MultiError is a *DocError.
+func ValidateDocs(docs []*graph.Doc, defs []*graph.Def) (errs MultiError) {
+	byPath := make(map[graph.DefPath][]graph.DefKey, len(defs))
+	for _, def := range defs {
+		byPath[def.Path] = append(byPath[def.Path], def.DefKey)
+	}
+	for _, doc := range docs {
+		found := false
+		for _, k := range byPath[doc.Path] {
+			if (doc.Repo == "" || doc.Repo == k.Repo) && (doc.UnitType == "" || doc.UnitType == k.UnitType) && (doc.Unit == "" || doc.Unit == k.Unit) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, &DocError{Doc: doc, Msg: fmt.Sprintf("doc of nonexistent def %s", doc.DefKey.Format())})
+		}
+	}
+	return
+}
+
+// A DocError is a problem with a specific doc.
+type DocError struct {
+	Doc *graph.Doc
+	Msg string
+}
+
+func (e *DocError) Error() string { return e.Msg }
+
+// linkDocs merges the docs in o (see MergeDocs), and removes (and reports)
+// the orphan docs, which describe defs that aren't in o (see ValidateDocs).
+func linkDocs(o *Output, n *unit.Normalize) error {
+	o.Docs = MergeDocs(o.Docs)
+	errs := ValidateDocs(o.Docs, o.Defs)
+	if len(errs) == 0 {
+		return nil
+	}
+	orphans := make(map[*graph.Doc]bool, len(errs))
+	for _, err := range errs {
+		orphans[err.(*DocError).Doc] = true
+	}
+	docs := o.Docs[:0]
+	for _, doc := range o.Docs {
+		if !orphans[doc] {
+			docs = append(docs, doc)
+		}
+	}
+	o.Docs = docs
+	log.Printf("Warning: removing %d docs of defs that aren't in the graph output (the first is the %s)", len(errs), errs[0])
+	return nil
+}
@@ -0,0 +1,45 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestMergeDocs(t *testing.T) {
+	a := graph.DefKey{Path: "a"}
+	docs := MergeDocs([]*graph.Doc{
+		{DefKey: a, Format: "text/plain", Data: "comment", File: "f", Start: 1, End: 2},
+		{DefKey: a, Format: "text/html", Data: "<p>comment</p>"},
+		{DefKey: a, Format: "text/plain", Data: "annotation", File: "f", Start: 3, End: 4},
+		{DefKey: a, Format: "text/plain", Data: "comment"},
+		{DefKey: graph.DefKey{Path: "b"}, Format: "text/plain", Data: "b"},
+	})
+	want := []*graph.Doc{
+		{DefKey: a, Format: "text/plain", Data: "comment\n\nannotation", File: "f", Start: 1, End: 2},
+		{DefKey: a, Format: "text/html", Data: "<p>comment</p>"},
+		{DefKey: graph.DefKey{Path: "b"}, Format: "text/plain", Data: "b"},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("got docs %+v, want %+v", docs, want)
+	}
+}
+
+func TestValidateDocs(t *testing.T) {
+	defs := []*graph.Def{{DefKey: graph.DefKey{UnitType: "t", Unit: "u", Path: "a"}}}
+	docs := []*graph.Doc{
+		{DefKey: graph.DefKey{Path: "a"}},
+		{DefKey: graph.DefKey{UnitType: "t", Unit: "u", Path: "a"}},
+		{DefKey: graph.DefKey{UnitType: "t", Unit: "other", Path: "a"}},
+		{DefKey: graph.DefKey{Path: "b"}},
+	}
+	errs := ValidateDocs(docs, defs)
+	var orphans []*graph.Doc
+	for _, err := range errs {
+		orphans = append(orphans, err.(*DocError).Doc)
+	}
+	if want := docs[2:]; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("got orphans %+v, want %+v", orphans, want)
+	}
+}
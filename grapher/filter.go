@@ -0,0 +1,151 @@
+package grapher
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// ApplyFilters drops or redacts the defs and refs in o, which is the graph
+// output of source unit u, that match u's filters (see unit.Filter). It is
+// run after the output is otherwise normalized, so that filters see the
+// final def paths and redaction removes embedded snippets.
+func ApplyFilters(o *Output, u *unit.SourceUnit) {
+	for _, f := range u.Filters {
+		m := newFilterMatcher(f, u)
+		if f.Action == unit.RedactFiltered {
+			redacted := make(map[graph.DefPath]bool)
+			for _, def := range o.Defs {
+				if m.matchDef(def) {
+					def.Snippet, def.Data = "", nil
+					redacted[def.Path] = true
+				}
+			}
+			docs := o.Docs[:0]
+			for _, doc := range o.Docs {
+				if !inUnit(u, doc.Repo, doc.UnitType, doc.Unit) || !redacted[doc.Path] {
+					docs = append(docs, doc)
+				}
+			}
+			o.Docs = docs
+			continue
+		}
+
+		removeDefs(o, u, m.matchDef)
+		if m.matchesRefs {
+			refs := o.Refs[:0]
+			for _, ref := range o.Refs {
+				if !m.matchRef(ref) {
+					refs = append(refs, ref)
+				}
+			}
+			o.Refs = refs
+		}
+	}
+}
+
+// A filterMatcher matches defs and refs against a filter's conditions.
+type filterMatcher struct {
+	*unit.Filter
+	u                   *unit.SourceUnit
+	paths, units, files []*regexp.Regexp
+
+	// matchesRefs is whether the filter has only conditions that refs
+	// can satisfy.
+	matchesRefs bool
+}
+
+func newFilterMatcher(f *unit.Filter, u *unit.SourceUnit) *filterMatcher {
+	return &filterMatcher{
+		Filter:      f,
+		u:           u,
+		paths:       globRegexps(f.Paths),
+		units:       globRegexps(f.Units),
+		files:       globRegexps(f.Files),
+		matchesRefs: f.Visibility == "" && len(f.Kinds) == 0 && len(f.Labels) == 0,
+	}
+}
+
+func (m *filterMatcher) matchDef(def *graph.Def) bool {
+	if m.Visibility != "" && def.Exported != (m.Visibility == "exported") {
+		return false
+	}
+	if len(m.Kinds) > 0 && !contains(m.Kinds, string(def.Kind)) {
+		return false
+	}
+	labels := map[string]bool{"test": def.Test, "local": def.Local, "anonymous": def.Anonymous, "callable": def.Callable}
+	for _, l := range m.Labels {
+		if !labels[l] {
+			return false
+		}
+	}
+	unitName := def.Unit
+	if unitName == "" {
+		unitName = m.u.Name
+	}
+	return matchAny(m.paths, string(def.Path)) && matchAny(m.units, unitName) && matchAny(m.files, def.File)
+}
+
+func (m *filterMatcher) matchRef(ref *graph.Ref) bool {
+	if !m.matchesRefs {
+		return false
+	}
+	unitName := ref.DefUnit
+	if unitName == "" {
+		unitName = m.u.Name
+	}
+	return matchAny(m.paths, string(ref.DefPath)) && matchAny(m.units, unitName) && matchAny(m.files, ref.File)
+}
+
+// matchAny reports whether s matches any of res, or true if res is empty
+// (so an unset condition is satisfied).
+func matchAny(res []*regexp.Regexp, s string) bool {
+	if len(res) == 0 {
+		return true
+	}
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, s2 := range ss {
+		if s == s2 {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexps returns regexps that match the same strings as the glob
+// patterns, in which "*" and "?" don't match "/", and "**" matches
+// anything.
+func globRegexps(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, pat := range patterns {
+		var re bytes.Buffer
+		re.WriteString("^")
+		for j := 0; j < len(pat); j++ {
+			switch {
+			case strings.HasPrefix(pat[j:], "**"):
+				re.WriteString(".*")
+				j++
+			case pat[j] == '*':
+				re.WriteString("[^/]*")
+			case pat[j] == '?':
+				re.WriteString("[^/]")
+			default:
+				re.WriteString(regexp.QuoteMeta(pat[j : j+1]))
+			}
+		}
+		re.WriteString("$")
+		res[i] = regexp.MustCompile(re.String())
+	}
+	return res
+}
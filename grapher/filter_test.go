@@ -0,0 +1,82 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestApplyFilters(t *testing.T) {
+	output := func() *Output {
+		return &Output{
+			Defs: []*graph.Def{
+				{DefKey: graph.DefKey{Path: "pub"}, Kind: "func", File: "a.go", Exported: true, Snippet: "s"},
+				{DefKey: graph.DefKey{Path: "priv"}, Kind: "func", File: "a.go"},
+				{DefKey: graph.DefKey{Path: "T"}, Kind: "type", File: "a_test.go", Exported: true, Test: true},
+			},
+			Refs: []*graph.Ref{
+				{DefPath: "pub", File: "a.go", Start: 1},
+				{DefPath: "priv", File: "a.go", Start: 2},
+				{DefUnitType: "t", DefUnit: "example.com/x/vendor/y", DefPath: "Z", File: "a.go", Start: 3},
+			},
+			Docs: []*graph.Doc{
+				{DefKey: graph.DefKey{Path: "pub"}, Data: "d"},
+				{DefKey: graph.DefKey{Path: "priv"}, Data: "d"},
+			},
+		}
+	}
+	paths := func(o *Output) []string {
+		var ps []string
+		for _, def := range o.Defs {
+			ps = append(ps, "def "+string(def.Path))
+		}
+		for _, ref := range o.Refs {
+			ps = append(ps, "ref "+string(ref.DefPath))
+		}
+		for _, doc := range o.Docs {
+			ps = append(ps, "doc "+string(doc.Path))
+		}
+		return ps
+	}
+
+	tests := map[string]struct {
+		filters []*unit.Filter
+		want    []string
+	}{
+		"none": {
+			want: []string{"def pub", "def priv", "def T", "ref pub", "ref priv", "ref Z", "doc pub", "doc priv"},
+		},
+		"drop private": {
+			filters: []*unit.Filter{{Visibility: "unexported"}},
+			want:    []string{"def pub", "def T", "ref pub", "ref Z", "doc pub"},
+		},
+		"drop refs into vendor": {
+			filters: []*unit.Filter{{Units: []string{"**/vendor/**"}}},
+			want:    []string{"def pub", "def priv", "def T", "ref pub", "ref priv", "doc pub", "doc priv"},
+		},
+		"drop by kind and label": {
+			filters: []*unit.Filter{{Kinds: []string{"type"}, Labels: []string{"test"}}},
+			want:    []string{"def pub", "def priv", "ref pub", "ref priv", "ref Z", "doc pub", "doc priv"},
+		},
+		"drop by file": {
+			filters: []*unit.Filter{{Files: []string{"*_test.go"}}},
+			want:    []string{"def pub", "def priv", "ref pub", "ref priv", "ref Z", "doc pub", "doc priv"},
+		},
+		"redact by path": {
+			filters: []*unit.Filter{{Action: unit.RedactFiltered, Paths: []string{"p*"}}},
+			want:    []string{"def pub", "def priv", "def T", "ref pub", "ref priv", "ref Z"},
+		},
+	}
+	for label, test := range tests {
+		o := output()
+		ApplyFilters(o, &unit.SourceUnit{Type: "t", Name: "example.com/x", Filters: test.filters})
+		if got := paths(o); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", label, got, test.want)
+		}
+		if label == "redact by path" && o.Defs[0].Snippet != "" {
+			t.Errorf("%s: got snippet %q, want it redacted", label, o.Defs[0].Snippet)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package grapher
+
+import (
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// An interner deduplicates strings, so that the many copies of the same
+// file path, unit name, or repository URI that decoding graph output
+// allocates (one for each def and ref) share one copy and the rest can be
+// freed.
+type interner map[string]string
+
+func (in interner) intern(s string) string {
+	if s == "" {
+		return s
+	}
+	if s2, present := in[s]; present {
+		return s2
+	}
+	in[s] = s
+	return s
+}
+
+func (in interner) uri(u repo.URI) repo.URI { return repo.URI(in.intern(string(u))) }
+
+func (in interner) defKey(k *graph.DefKey) {
+	k.Repo, k.UnitType, k.Unit = in.uri(k.Repo), in.intern(k.UnitType), in.intern(k.Unit)
+}
+
+// item interns the repeated strings of the item that is set in item.
+func (in interner) item(item *OutputItem) {
+	switch {
+	case item.Def != nil:
+		def := item.Def
+		in.defKey(&def.DefKey)
+		def.Kind, def.File = graph.DefKind(in.intern(string(def.Kind))), in.intern(def.File)
+	case item.Ref != nil:
+		ref := item.Ref
+		ref.DefRepo, ref.DefUnitType, ref.DefUnit = in.uri(ref.DefRepo), in.intern(ref.DefUnitType), in.intern(ref.DefUnit)
+		ref.Repo, ref.UnitType, ref.Unit = in.uri(ref.Repo), in.intern(ref.UnitType), in.intern(ref.Unit)
+		ref.Kind, ref.File = graph.RefKind(in.intern(string(ref.Kind))), in.intern(ref.File)
+		for _, c := range ref.Candidates {
+			c.DefRepo, c.DefUnitType, c.DefUnit = in.uri(c.DefRepo), in.intern(c.DefUnitType), in.intern(c.DefUnit)
+		}
+	case item.Doc != nil:
+		doc := item.Doc
+		in.defKey(&doc.DefKey)
+		doc.Format, doc.File = in.intern(doc.Format), in.intern(doc.File)
+	case item.Alias != nil:
+		a := item.Alias
+		a.Repo, a.UnitType, a.Unit = in.uri(a.Repo), in.intern(a.UnitType), in.intern(a.Unit)
+		a.DefRepo, a.DefUnitType, a.DefUnit = in.uri(a.DefRepo), in.intern(a.DefUnitType), in.intern(a.DefUnit)
+	case item.TypeRelation != nil:
+		r := item.TypeRelation
+		r.Kind = graph.TypeRelationKind(in.intern(string(r.Kind)))
+		r.Repo, r.UnitType, r.Unit = in.uri(r.Repo), in.intern(r.UnitType), in.intern(r.Unit)
+		r.DefRepo, r.DefUnitType, r.DefUnit = in.uri(r.DefRepo), in.intern(r.DefUnitType), in.intern(r.DefUnit)
+	}
+}
+
+// internStrings interns the repeated strings of o's items (see interner).
+func internStrings(o *Output, n *unit.Normalize) error {
+	in := make(interner)
+	var item OutputItem
+	for _, def := range o.Defs {
+		item = OutputItem{Def: def}
+		in.item(&item)
+	}
+	for _, ref := range o.Refs {
+		item = OutputItem{Ref: ref}
+		in.item(&item)
+	}
+	for _, doc := range o.Docs {
+		item = OutputItem{Doc: doc}
+		in.item(&item)
+	}
+	for _, a := range o.Aliases {
+		item = OutputItem{Alias: a}
+		in.item(&item)
+	}
+	for _, r := range o.TypeRelations {
+		item = OutputItem{TypeRelation: r}
+		in.item(&item)
+	}
+	return nil
+}
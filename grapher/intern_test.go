@@ -0,0 +1,26 @@
+package grapher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestReadOutput_internsStrings(t *testing.T) {
+	o, err := ReadOutput(strings.NewReader(`{"Defs":[{"Path":"a","File":"f.go","Kind":"func"}],"Refs":[{"DefPath":"a","File":"f.go","DefUnit":"u","Unit":"u"},{"DefPath":"a","File":"f.go"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := func(s string) uintptr { return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data }
+
+	if o.Defs[0].File != "f.go" || o.Refs[0].File != "f.go" || o.Refs[0].DefUnit != "u" {
+		t.Fatalf("got output %+v, want strings unchanged", o)
+	}
+	if data(o.Refs[0].File) != data(o.Defs[0].File) || data(o.Refs[1].File) != data(o.Defs[0].File) {
+		t.Error("got refs and defs with separate copies of the same file")
+	}
+	if data(o.Refs[0].DefUnit) != data(o.Refs[0].Unit) {
+		t.Error("got separate copies of the same unit name")
+	}
+}
@@ -0,0 +1,82 @@
+package grapher
+
+import (
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// RemoveLocals removes the local defs (those with Local set) from o, which is
+// the graph output of source unit u, along with the refs, docs, aliases, and
+// type relations that involve them. It is used for source units whose
+// IncludeLocals option is false, when the grapher doesn't support omitting
+// local defs itself.
+func RemoveLocals(o *Output, u *unit.SourceUnit) {
+	removeDefs(o, u, func(def *graph.Def) bool { return def.Local })
+}
+
+// removeDefs removes the defs for which remove returns true from o, which is
+// the graph output of source unit u, along with the refs, docs, aliases, and
+// type relations that involve them.
+func removeDefs(o *Output, u *unit.SourceUnit, remove func(*graph.Def) bool) {
+	removed := make(map[graph.DefPath]bool)
+	defs := o.Defs[:0]
+	for _, def := range o.Defs {
+		if remove(def) {
+			removed[def.Path] = true
+		} else {
+			defs = append(defs, def)
+		}
+	}
+	o.Defs = defs
+	if len(removed) == 0 {
+		return
+	}
+
+	// isRemoved reports whether the def key refers to a removed def in u.
+	isRemoved := func(defRepo repo.URI, defUnitType, defUnit string, defPath graph.DefPath) bool {
+		return inUnit(u, defRepo, defUnitType, defUnit) && removed[defPath]
+	}
+
+	refs := o.Refs[:0]
+	for _, ref := range o.Refs {
+		if !isRemoved(ref.DefRepo, ref.DefUnitType, ref.DefUnit, ref.DefPath) {
+			refs = append(refs, ref)
+		}
+	}
+	o.Refs = refs
+
+	docs := o.Docs[:0]
+	for _, doc := range o.Docs {
+		if !isRemoved(doc.Repo, doc.UnitType, doc.Unit, doc.Path) {
+			docs = append(docs, doc)
+		}
+	}
+	o.Docs = docs
+
+	aliases := o.Aliases[:0]
+	for _, a := range o.Aliases {
+		if !isRemoved(a.Repo, a.UnitType, a.Unit, a.Path) && !isRemoved(a.DefRepo, a.DefUnitType, a.DefUnit, a.DefPath) {
+			aliases = append(aliases, a)
+		}
+	}
+	o.Aliases = aliases
+
+	rels := o.TypeRelations[:0]
+	for _, r := range o.TypeRelations {
+		if !isRemoved(r.Repo, r.UnitType, r.Unit, r.Path) && !isRemoved(r.DefRepo, r.DefUnitType, r.DefUnit, r.DefPath) {
+			rels = append(rels, r)
+		}
+	}
+	o.TypeRelations = rels
+}
+
+// inUnit reports whether a def key with the given repo, unit type, and unit
+// (any of which may be empty in graph output, meaning u's) refers to a def
+// in source unit u.
+func inUnit(u *unit.SourceUnit, defRepo repo.URI, defUnitType, defUnit string) bool {
+	if defRepo != "" && defRepo != u.Repo {
+		return false
+	}
+	return (defUnitType == "" && defUnit == "") || (defUnitType == u.Type && defUnit == u.Name)
+}
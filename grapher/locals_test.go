@@ -0,0 +1,51 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestRemoveLocals(t *testing.T) {
+	u := &unit.SourceUnit{Type: "t", Name: "u"}
+	o := &Output{
+		Defs: []*graph.Def{
+			{DefKey: graph.DefKey{Path: "f"}},
+			{DefKey: graph.DefKey{Path: "f/x"}, Local: true},
+		},
+		Refs: []*graph.Ref{
+			{DefPath: "f", File: "a", Start: 1},
+			{DefPath: "f/x", File: "a", Start: 2},
+			{DefUnitType: "t", DefUnit: "u", DefPath: "f/x", File: "a", Start: 3},
+			{DefUnitType: "t", DefUnit: "other", DefPath: "f/x", File: "a", Start: 4},
+		},
+		Docs: []*graph.Doc{
+			{DefKey: graph.DefKey{Path: "f"}},
+			{DefKey: graph.DefKey{Path: "f/x"}},
+		},
+		Aliases: []*graph.Alias{
+			{Path: "f/y", DefPath: "f/x"},
+		},
+	}
+
+	RemoveLocals(o, u)
+
+	if len(o.Defs) != 1 || o.Defs[0].Path != "f" {
+		t.Errorf("got defs %+v, want only f", o.Defs)
+	}
+	var refStarts []int
+	for _, ref := range o.Refs {
+		refStarts = append(refStarts, ref.Start)
+	}
+	if want := []int{1, 4}; !reflect.DeepEqual(refStarts, want) {
+		t.Errorf("got refs at %v, want %v", refStarts, want)
+	}
+	if len(o.Docs) != 1 || o.Docs[0].Path != "f" {
+		t.Errorf("got docs %+v, want only f's", o.Docs)
+	}
+	if len(o.Aliases) != 0 {
+		t.Errorf("got aliases %+v, want none", o.Aliases)
+	}
+}
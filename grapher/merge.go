@@ -0,0 +1,226 @@
+package grapher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// A ToolchainOutput is a grapher's output, along with the toolchain that
+// produced it.
+type ToolchainOutput struct {
+	Toolchain string
+	*Output
+}
+
+// mergeDefs returns the defs in outputs, with defs that have the same
+// DefKey merged into one according to m.
+func mergeDefs(outputs []*ToolchainOutput, m *unit.DefMerge) ([]*graph.Def, error) {
+	rank := func(toolchain string) int {
+		if m != nil {
+			for i, tc := range m.Toolchains {
+				if tc == toolchain {
+					return i
+				}
+			}
+			return len(m.Toolchains)
+		}
+		return 0
+	}
+
+	byKey := make(map[graph.DefKey][]emitted)
+	var keys []graph.DefKey // in order of first appearance
+	for _, o := range outputs {
+		for _, def := range o.Defs {
+			if _, seen := byKey[def.DefKey]; !seen {
+				keys = append(keys, def.DefKey)
+			}
+			byKey[def.DefKey] = append(byKey[def.DefKey], emitted{def, o.Toolchain})
+		}
+	}
+
+	defs := make([]*graph.Def, 0, len(keys))
+	for _, key := range keys {
+		dups := byKey[key]
+		if len(dups) == 1 {
+			defs = append(defs, dups[0].def)
+			continue
+		}
+
+		// Order by toolchain preference, keeping the original order among
+		// equally preferred toolchains.
+		sort.Stable(byRank{dups, rank})
+
+		switch policy := m.PolicyFor(string(key.Path)); policy {
+		case unit.ErrorOnDuplicate:
+			toolchains := make([]string, len(dups))
+			for i, d := range dups {
+				toolchains[i] = d.toolchain
+			}
+			return nil, fmt.Errorf("def %q was emitted %d times (by toolchains %s)", key.Path, len(dups), strings.Join(toolchains, ", "))
+		case unit.PreferToolchain:
+			defs = append(defs, dups[0].def)
+		case unit.MergeFields:
+			def := *dups[0].def
+			for _, d := range dups[1:] {
+				mergeDefFields(&def, d.def)
+			}
+			defs = append(defs, &def)
+		default:
+			return nil, fmt.Errorf("unknown def merge policy %q", policy)
+		}
+	}
+	return defs, nil
+}
+
+type emitted struct {
+	def       *graph.Def
+	toolchain string
+}
+
+type byRank struct {
+	defs []emitted
+	rank func(toolchain string) int
+}
+
+func (v byRank) Len() int           { return len(v.defs) }
+func (v byRank) Swap(i, j int)      { v.defs[i], v.defs[j] = v.defs[j], v.defs[i] }
+func (v byRank) Less(i, j int) bool { return v.rank(v.defs[i].toolchain) < v.rank(v.defs[j].toolchain) }
+
+// mergeDefFields sets the empty fields of dst to the values of the
+// corresponding fields in src.
+func mergeDefFields(dst, src *graph.Def) {
+	if dst.TreePath == "" {
+		dst.TreePath = src.TreePath
+	}
+	if dst.Kind == "" {
+		dst.Kind = src.Kind
+	}
+	if dst.Name == "" {
+		dst.Name = src.Name
+	}
+	if dst.File == "" {
+		// The offsets are only meaningful along with the file.
+		dst.File, dst.DefStart, dst.DefEnd = src.File, src.DefStart, src.DefEnd
+	}
+	dst.Callable = dst.Callable || src.Callable
+	dst.Exported = dst.Exported || src.Exported
+	dst.Test = dst.Test || src.Test
+	dst.Local = dst.Local || src.Local
+	dst.Anonymous = dst.Anonymous || src.Anonymous
+	if len(dst.Data) == 0 {
+		dst.Data = src.Data
+	}
+}
+
+// Merge combines partial outputs of the same source unit (e.g., from
+// graphing subsets of its files in parallel, or from graphing only the
+// files that changed) into a single output, sorted like the output of
+// Graph and NormalizeData.
+//
+// Items that appear in more than one output are only included once.
+// Unlike NormalizeData, Merge doesn't merge the fields of different defs
+// with the same key (the outputs are expected to agree on them), so defs or
+// refs with the same key but different fields are an error. The combined
+// refs must also be valid (see ValidateRefs). The returned error is a
+// MultiError of all of the problems. Nil outputs are ignored.
+//
+// The items in the returned output are shared with the outputs, not
+// copied.
+func Merge(outputs ...*Output) (*Output, error) {
+	var (
+		o             Output
+		errs          MultiError
+		defs          = make(map[graph.DefKey]*graph.Def)
+		refs          = make(map[graph.RefKey]*graph.Ref)
+		docs          = make(map[graph.Doc]struct{})
+		aliases       = make(map[graph.Alias]struct{})
+		typeRelations = make(map[graph.TypeRelation]struct{})
+	)
+	for _, o2 := range outputs {
+		if o2 == nil {
+			continue
+		}
+		for _, def := range o2.Defs {
+			if def2, present := defs[def.DefKey]; !present {
+				defs[def.DefKey] = def
+				o.Defs = append(o.Defs, def)
+			} else if !reflect.DeepEqual(def, def2) {
+				errs = append(errs, fmt.Errorf("def %s appears more than once with different fields", def.DefKey.Format()))
+			}
+		}
+		for _, ref := range o2.Refs {
+			key := ref.RefKey()
+			if ref2, present := refs[key]; !present {
+				refs[key] = ref
+				o.Refs = append(o.Refs, ref)
+			} else if !reflect.DeepEqual(ref, ref2) {
+				errs = append(errs, &RefError{Ref: ref, Msg: fmt.Sprintf("ref %+v appears more than once with different fields", key)})
+			}
+		}
+		for _, doc := range o2.Docs {
+			if _, present := docs[*doc]; !present {
+				docs[*doc] = struct{}{}
+				o.Docs = append(o.Docs, doc)
+			}
+		}
+		for _, a := range o2.Aliases {
+			if _, present := aliases[*a]; !present {
+				aliases[*a] = struct{}{}
+				o.Aliases = append(o.Aliases, a)
+			}
+		}
+		for _, r := range o2.TypeRelations {
+			if _, present := typeRelations[*r]; !present {
+				typeRelations[*r] = struct{}{}
+				o.TypeRelations = append(o.TypeRelations, r)
+			}
+		}
+	}
+
+	errs = append(errs, ValidateRefs(o.Refs)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// The graph package's orders aren't total (e.g., docs of the same def
+	// are equal), so ties are broken by the items' JSON encodings. Otherwise
+	// the result would depend on the order of the outputs.
+	sortByJSON(graph.Defs(o.Defs), func(i int) interface{} { return o.Defs[i] })
+	sortByJSON(graph.Refs(o.Refs), func(i int) interface{} { return o.Refs[i] })
+	sortByJSON(graph.Docs(o.Docs), func(i int) interface{} { return o.Docs[i] })
+	sortByJSON(graph.Aliases(o.Aliases), func(i int) interface{} { return o.Aliases[i] })
+	sortByJSON(graph.TypeRelations(o.TypeRelations), func(i int) interface{} { return o.TypeRelations[i] })
+	return &o, nil
+}
+
+// sortByJSON sorts v, breaking ties by the JSON encodings of its items
+// (item(i) is the i'th item).
+func sortByJSON(v sort.Interface, item func(i int) interface{}) {
+	keys := make([]string, v.Len())
+	for i := range keys {
+		b, err := json.Marshal(item(i))
+		if err != nil {
+			panic("sortByJSON: " + err.Error())
+		}
+		keys[i] = string(b)
+	}
+	sort.Sort(byJSON{v, keys})
+	sort.Stable(v)
+}
+
+type byJSON struct {
+	sort.Interface
+	keys []string
+}
+
+func (v byJSON) Less(i, j int) bool { return v.keys[i] < v.keys[j] }
+func (v byJSON) Swap(i, j int) {
+	v.Interface.Swap(i, j)
+	v.keys[i], v.keys[j] = v.keys[j], v.keys[i]
+}
@@ -0,0 +1,103 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestNormalizeData_mergeDefs(t *testing.T) {
+	outputs := func() []*ToolchainOutput {
+		return []*ToolchainOutput{
+			{Toolchain: "generic", Output: &Output{Defs: []*graph.Def{
+				{DefKey: graph.DefKey{Path: "a"}, Name: "a", File: "f", DefStart: 1, DefEnd: 2},
+				{DefKey: graph.DefKey{Path: "b"}, Name: "b"},
+			}}},
+			{Toolchain: "specialized", Output: &Output{Defs: []*graph.Def{
+				{DefKey: graph.DefKey{Path: "a"}, Kind: "func", Exported: true},
+			}}},
+		}
+	}
+
+	tests := map[string]struct {
+		merge   *unit.DefMerge
+		wantA   graph.Def
+		wantErr bool
+	}{
+		"default merges fields": {
+			wantA: graph.Def{DefKey: graph.DefKey{Path: "a"}, Name: "a", Kind: "func", File: "f", DefStart: 1, DefEnd: 2, Exported: true},
+		},
+		"prefer toolchain": {
+			merge: &unit.DefMerge{Policy: unit.PreferToolchain, Toolchains: []string{"specialized"}},
+			wantA: graph.Def{DefKey: graph.DefKey{Path: "a"}, Kind: "func", Exported: true},
+		},
+		"path policy": {
+			merge:   &unit.DefMerge{Policy: unit.PreferToolchain, Paths: map[string]unit.DefMergePolicy{"a*": unit.ErrorOnDuplicate}},
+			wantErr: true,
+		},
+	}
+	for label, test := range tests {
+		o, err := NormalizeData(outputs(), test.merge)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error, want error", label)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if len(o.Defs) != 2 {
+			t.Errorf("%s: got %d defs, want 2", label, len(o.Defs))
+			continue
+		}
+		if a := *o.Defs[0]; a.Name != test.wantA.Name || a.Kind != test.wantA.Kind || a.File != test.wantA.File || a.DefStart != test.wantA.DefStart || a.Exported != test.wantA.Exported {
+			t.Errorf("%s: got def %+v, want %+v", label, a, test.wantA)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := &Output{
+		Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "b"}, Name: "b"}, {DefKey: graph.DefKey{Path: "a"}, Name: "a"}},
+		Refs: []*graph.Ref{{DefPath: "a", File: "f", Start: 1, End: 2, Kind: graph.Read}},
+		Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "a"}, Format: "text/plain", Data: "a"}},
+	}
+	b := &Output{
+		Defs:    []*graph.Def{{DefKey: graph.DefKey{Path: "a"}, Name: "a"}},
+		Refs:    []*graph.Ref{{DefPath: "a", File: "f", Start: 1, End: 2, Kind: graph.Read}, {DefPath: "a", File: "g", Start: 1, End: 2}},
+		Docs:    []*graph.Doc{{DefKey: graph.DefKey{Path: "a"}, Format: "text/html", Data: "<b>a</b>"}, {DefKey: graph.DefKey{Path: "a"}, Format: "text/plain", Data: "a"}},
+		Aliases: []*graph.Alias{{Path: "c", DefPath: "a"}},
+	}
+
+	// The result doesn't depend on the order of the outputs.
+	o1, err := Merge(a, nil, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := Merge(b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o1, o2) {
+		t.Errorf("got different outputs %+v and %+v when merged in different orders", o1, o2)
+	}
+	if len(o1.Defs) != 2 || o1.Defs[0].Path != "a" || len(o1.Refs) != 2 || len(o1.Docs) != 2 || o1.Docs[0].Format != "text/html" || len(o1.Aliases) != 1 {
+		t.Errorf("got merged output %+v", o1)
+	}
+
+	for label, o := range map[string]*Output{
+		"conflicting defs": {Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "a"}, Name: "a2"}}},
+		"conflicting refs": {Refs: []*graph.Ref{{DefPath: "a", File: "f", Start: 1, End: 2, Kind: graph.Call}}},
+		"invalid ref":      {Refs: []*graph.Ref{{DefPath: "a", File: "h", Kind: "x"}}},
+	} {
+		if _, err := Merge(a, o); err == nil {
+			t.Errorf("%s: got no error", label)
+		} else if errs, ok := err.(MultiError); !ok || len(errs) != 1 {
+			t.Errorf("%s: got error %v, want a MultiError of 1 error", label, err)
+		}
+	}
+}
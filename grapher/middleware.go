@@ -0,0 +1,168 @@
+package grapher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+// GrapherFunc is a func that is a Grapher.
+type GrapherFunc func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error)
+
+func (f GrapherFunc) Graph(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+	return f(dir, u, c)
+}
+
+// A Middleware wraps a Grapher to add behavior (such as normalizing or
+// checking its output) that applies to any grapher.
+type Middleware func(Grapher) Grapher
+
+// Chain returns g wrapped by mws. The first middleware is the outermost,
+// so it sees the output of all of the others.
+func Chain(g Grapher, mws ...Middleware) Grapher {
+	for i := len(mws) - 1; i >= 0; i-- {
+		g = mws[i](g)
+	}
+	return g
+}
+
+// outputMiddleware returns a middleware that calls f on each successful
+// output.
+func outputMiddleware(f func(dir string, u *unit.SourceUnit, o *Output) (*Output, error)) Middleware {
+	return func(g Grapher) Grapher {
+		return GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+			o, err := g.Graph(dir, u, c)
+			if err != nil {
+				return nil, err
+			}
+			return f(dir, u, o)
+		})
+	}
+}
+
+// NormalizeOffsets is a middleware that converts the offsets in the output
+// of graphers to byte offsets (reading the files that the output refers to
+// from the source unit's directory), according to the offsets that the
+// toolchain of each source unit's grapher declares (see toolchain.Offsets).
+// Graphers whose toolchain doesn't declare its offsets, or can't be found,
+// are assumed to output Unicode character offsets, except for those of source
+// units of the byteOffsetTypes, which are assumed to output byte offsets.
+func NormalizeOffsets(byteOffsetTypes ...string) Middleware {
+	return outputMiddleware(func(dir string, u *unit.SourceUnit, o *Output) (*Output, error) {
+		offsets := unitOffsets(u)
+		if offsets == "" {
+			offsets = toolchain.RuneOffsets
+			for _, t := range byteOffsetTypes {
+				if u.Type == t {
+					offsets = toolchain.ByteOffsets
+				}
+			}
+		}
+		if err := ConvertOffsets(o, offsets, vfs.OS(dir).ReadFile); err != nil {
+			return nil, fmt.Errorf("graphing source unit %s: %s", u.ID(), err)
+		}
+		return o, nil
+	})
+}
+
+// Sort is a middleware that sorts the output of graphers.
+var Sort Middleware = outputMiddleware(func(dir string, u *unit.SourceUnit, o *Output) (*Output, error) {
+	return sortedOutput(o), nil
+})
+
+// Validate is a middleware that fails if the refs in the output of
+// graphers have problems (see ValidateRefs).
+var Validate Middleware = outputMiddleware(func(dir string, u *unit.SourceUnit, o *Output) (*Output, error) {
+	if errs := ValidateRefs(o.Refs); len(errs) > 0 {
+		return nil, errs
+	}
+	return o, nil
+})
+
+// Filter returns a middleware that removes the defs and refs for which
+// keepDef and keepRef (either of which may be nil to keep all) return false
+// from the output of graphers. The docs of removed defs are removed too.
+func Filter(keepDef func(*graph.Def) bool, keepRef func(*graph.Ref) bool) Middleware {
+	return outputMiddleware(func(dir string, u *unit.SourceUnit, o *Output) (*Output, error) {
+		if keepDef != nil {
+			removed := map[graph.DefPath]bool{}
+			defs := o.Defs[:0]
+			for _, def := range o.Defs {
+				if keepDef(def) {
+					defs = append(defs, def)
+				} else {
+					removed[def.Path] = true
+				}
+			}
+			o.Defs = defs
+			docs := o.Docs[:0]
+			for _, doc := range o.Docs {
+				if !removed[doc.Path] {
+					docs = append(docs, doc)
+				}
+			}
+			o.Docs = docs
+		}
+		if keepRef != nil {
+			refs := o.Refs[:0]
+			for _, ref := range o.Refs {
+				if keepRef(ref) {
+					refs = append(refs, ref)
+				}
+			}
+			o.Refs = refs
+		}
+		return o, nil
+	})
+}
+
+// Timing returns a middleware that calls report with how long each call to
+// a grapher took (and its error, if it failed).
+func Timing(report func(u *unit.SourceUnit, d time.Duration, err error)) Middleware {
+	return func(g Grapher) Grapher {
+		return GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+			start := time.Now()
+			o, err := g.Graph(dir, u, c)
+			report(u, time.Since(start), err)
+			return o, err
+		})
+	}
+}
+
+// Cache returns a middleware that remembers the output of graphers for each
+// directory and source unit, and returns it instead of graphing the same
+// source unit in the same directory again. Callers must not modify the
+// outputs. Failures aren't remembered.
+func Cache() Middleware {
+	type key struct {
+		dir string
+		id  unit.ID
+	}
+	var mu sync.Mutex
+	outputs := map[key]*Output{}
+	return func(g Grapher) Grapher {
+		return GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+			k := key{dir, u.ID()}
+			mu.Lock()
+			o, present := outputs[k]
+			mu.Unlock()
+			if present {
+				return o, nil
+			}
+			o, err := g.Graph(dir, u, c)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			outputs[k] = o
+			mu.Unlock()
+			return o, nil
+		})
+	}
+}
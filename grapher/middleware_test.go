@@ -0,0 +1,117 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(g Grapher) Grapher {
+			return GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+				calls = append(calls, name)
+				return g.Graph(dir, u, c)
+			})
+		}
+	}
+	g := GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+		calls = append(calls, "grapher")
+		return &Output{}, nil
+	})
+	if _, err := Chain(g, mw("a"), mw("b")).Graph("", &unit.SourceUnit{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "grapher"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	output := func() *Output {
+		return &Output{
+			Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "b"}}, {DefKey: graph.DefKey{Path: "a"}, Local: true}},
+			Refs: []*graph.Ref{{DefPath: "b", Kind: "bogus"}, {DefPath: "a"}},
+			Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "a"}}, {DefKey: graph.DefKey{Path: "b"}}},
+		}
+	}
+	var calls int
+	g := GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+		calls++
+		return output(), nil
+	})
+	u := &unit.SourceUnit{Name: "u", Type: "t"}
+
+	o, err := Chain(g, Sort).Graph("", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Defs[0].Path != "a" {
+		t.Errorf("got unsorted defs %v", o.Defs)
+	}
+
+	if _, err := Chain(g, Validate).Graph("", u, nil); err == nil {
+		t.Error("got no error from Validate for an invalid ref kind")
+	}
+
+	notLocal := func(def *graph.Def) bool { return !def.Local }
+	o, err = Chain(g, Filter(notLocal, func(ref *graph.Ref) bool { return ref.DefPath != "a" })).Graph("", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Defs) != 1 || o.Defs[0].Path != "b" || len(o.Docs) != 1 || o.Docs[0].Path != "b" || len(o.Refs) != 1 || o.Refs[0].DefPath != "b" {
+		t.Errorf("got filtered output %+v, want only the def, doc, and ref of b", o)
+	}
+
+	var timed []string
+	timing := Timing(func(u *unit.SourceUnit, d time.Duration, err error) { timed = append(timed, u.Name) })
+	if _, err := Chain(g, timing).Graph("", u, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"u"}; !reflect.DeepEqual(timed, want) {
+		t.Errorf("got timed units %v, want %v", timed, want)
+	}
+
+	calls = 0
+	cached := Chain(g, Cache())
+	for _, dir := range []string{"d", "d", "e"} {
+		if _, err := cached.Graph(dir, u, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls with Cache, want 2", calls)
+	}
+}
+
+func TestRegistry_Graph(t *testing.T) {
+	r := NewRegistry()
+	var order []string
+	mw := func(name string) Middleware {
+		return func(g Grapher) Grapher {
+			return GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+				order = append(order, name)
+				return g.Graph(dir, u, c)
+			})
+		}
+	}
+	r.Use(mw("inner"))
+	r.Use(mw("outer"))
+	if err := r.Register("t", &testGrapher{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Graph("", &unit.SourceUnit{Type: "t"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"outer", "inner"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got middleware order %v, want %v", order, want)
+	}
+	if _, err := r.Graph("", &unit.SourceUnit{Type: "other"}, nil); err == nil {
+		t.Error("got no error graphing a source unit type with no grapher")
+	}
+}
@@ -0,0 +1,245 @@
+package grapher
+
+import (
+	"fmt"
+	"regexp"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// A NormalizePass is a named step in the normalization of a source unit's
+// graph output (see NormalizeDataWith). Passes run on the merged output of
+// the unit's graphers, in the order in which they're listed in
+// NormalizePasses.
+type NormalizePass struct {
+	// Name is how the pass is enabled or disabled in the Srcfile (see
+	// unit.Normalize).
+	Name string
+
+	// Default is whether the pass runs unless it is disabled.
+	Default bool
+
+	// Run normalizes o, according to n (which is never nil).
+	Run func(o *Output, n *unit.Normalize) error
+}
+
+// NormalizePasses are the passes that NormalizeDataWith may run, in order.
+// Programs that embed srclib may add their own passes (which don't run by
+// default unless Default is set) in an init func.
+var NormalizePasses = []*NormalizePass{
+	{Name: "intern", Default: true, Run: internStrings},
+	{Name: "def-ref-kinds", Default: true, Run: setDefRefKinds},
+	{Name: "candidates", Default: true, Run: resolveCandidates},
+	{Name: "kinds", Default: true, Run: mapDefKinds},
+	{Name: "paths", Default: true, Run: rewriteDefPaths},
+	{Name: "docs", Default: true, Run: linkDocs},
+	{Name: "uris", Default: true, Run: normalizeURIs},
+	{Name: "dedup", Run: dedup},
+	{Name: "sort", Default: true, Run: func(o *Output, n *unit.Normalize) error {
+		sortedOutput(o)
+		return nil
+	}},
+}
+
+// NormalizeDataWith is like NormalizeData, but it runs the passes that n
+// enables (or that run by default and n doesn't disable) on the merged
+// output. If n is nil, the default passes run.
+func NormalizeDataWith(outputs []*ToolchainOutput, m *unit.DefMerge, n *unit.Normalize) (*Output, error) {
+	if n == nil {
+		n = &unit.Normalize{}
+	}
+	known := make(map[string]bool, len(NormalizePasses))
+	for _, p := range NormalizePasses {
+		known[p.Name] = true
+	}
+	for _, names := range [][]string{n.Enable, n.Disable} {
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("unknown normalization pass %q", name)
+			}
+		}
+	}
+
+	defs, err := mergeDefs(outputs, m)
+	if err != nil {
+		return nil, err
+	}
+	o := &Output{Defs: defs}
+	for _, o2 := range outputs {
+		o.Refs = append(o.Refs, o2.Refs...)
+		o.Docs = append(o.Docs, o2.Docs...)
+		o.Aliases = append(o.Aliases, o2.Aliases...)
+		o.TypeRelations = append(o.TypeRelations, o2.TypeRelations...)
+	}
+
+	for _, p := range NormalizePasses {
+		if !passEnabled(p, n) {
+			continue
+		}
+		if err := p.Run(o, n); err != nil {
+			return nil, fmt.Errorf("normalization pass %q: %s", p.Name, err)
+		}
+	}
+	return o, nil
+}
+
+func passEnabled(p *NormalizePass, n *unit.Normalize) bool {
+	for _, name := range n.Disable {
+		if name == p.Name {
+			return false
+		}
+	}
+	for _, name := range n.Enable {
+		if name == p.Name {
+			return true
+		}
+	}
+	return p.Default
+}
+
+// setDefRefKinds sets the kind of refs that are def declarations (and have
+// no kind) to graph.Declaration.
+func setDefRefKinds(o *Output, n *unit.Normalize) error {
+	for _, ref := range o.Refs {
+		if ref.Def && ref.Kind == "" {
+			ref.Kind = graph.Declaration
+		}
+	}
+	return nil
+}
+
+// resolveCandidates sorts the candidates of ambiguous refs, and points those
+// that don't name a def at their best candidate.
+func resolveCandidates(o *Output, n *unit.Normalize) error {
+	for _, ref := range o.Refs {
+		if len(ref.Candidates) > 0 {
+			ref.SortCandidates()
+			if ref.DefPath == "" {
+				best := ref.Candidates[0]
+				ref.DefRepo, ref.DefUnitType, ref.DefUnit, ref.DefPath = best.DefRepo, best.DefUnitType, best.DefUnit, best.DefPath
+			}
+		}
+	}
+	return nil
+}
+
+// mapDefKinds replaces the kinds of defs according to n.Kinds.
+func mapDefKinds(o *Output, n *unit.Normalize) error {
+	if len(n.Kinds) == 0 {
+		return nil
+	}
+	for _, def := range o.Defs {
+		if kind, present := n.Kinds[string(def.Kind)]; present {
+			def.Kind = graph.DefKind(kind)
+		}
+	}
+	return nil
+}
+
+// rewriteDefPaths rewrites the def paths in o according to n.Paths.
+func rewriteDefPaths(o *Output, n *unit.Normalize) error {
+	if len(n.Paths) == 0 {
+		return nil
+	}
+	res := make([]*regexp.Regexp, len(n.Paths))
+	for i, r := range n.Paths {
+		var err error
+		if res[i], err = regexp.Compile(r.Pattern); err != nil {
+			return err
+		}
+	}
+	rewrite := func(p *graph.DefPath) {
+		for i, re := range res {
+			if re.MatchString(string(*p)) {
+				*p = graph.DefPath(re.ReplaceAllString(string(*p), n.Paths[i].Replacement))
+				return
+			}
+		}
+	}
+
+	for _, def := range o.Defs {
+		rewrite(&def.Path)
+	}
+	for _, ref := range o.Refs {
+		rewrite(&ref.DefPath)
+		for _, c := range ref.Candidates {
+			rewrite(&c.DefPath)
+		}
+	}
+	for _, doc := range o.Docs {
+		rewrite(&doc.Path)
+	}
+	for _, a := range o.Aliases {
+		rewrite(&a.Path)
+		rewrite(&a.DefPath)
+	}
+	for _, r := range o.TypeRelations {
+		rewrite(&r.Path)
+		rewrite(&r.DefPath)
+	}
+	return nil
+}
+
+// normalizeURIs normalizes the repository URIs that o refers to (see
+// repo.MakeURI). Outputs usually refer to few repositories, so each URI is
+// only normalized once.
+func normalizeURIs(o *Output, n *unit.Normalize) error {
+	uris := make(map[repo.URI]repo.URI)
+	normalize := func(uri *repo.URI) {
+		if *uri == "" {
+			return
+		}
+		u, present := uris[*uri]
+		if !present {
+			u = repo.MakeURI(string(*uri))
+			uris[*uri] = u
+		}
+		*uri = u
+	}
+
+	for _, ref := range o.Refs {
+		normalize(&ref.DefRepo)
+		for _, c := range ref.Candidates {
+			normalize(&c.DefRepo)
+		}
+	}
+	for _, a := range o.Aliases {
+		normalize(&a.DefRepo)
+	}
+	for _, r := range o.TypeRelations {
+		normalize(&r.DefRepo)
+	}
+	return nil
+}
+
+// dedup removes duplicate refs (with the same graph.RefKey) and docs (with
+// the same def and format), which may be emitted when several graphers graph
+// the same source unit. The first of each is kept.
+func dedup(o *Output, n *unit.Normalize) error {
+	seenRefs := make(map[graph.RefKey]bool, len(o.Refs))
+	refs := o.Refs[:0]
+	for _, ref := range o.Refs {
+		if k := ref.RefKey(); !seenRefs[k] {
+			seenRefs[k] = true
+			refs = append(refs, ref)
+		}
+	}
+	o.Refs = refs
+
+	type docKey struct {
+		graph.DefKey
+		format string
+	}
+	seenDocs := make(map[docKey]bool, len(o.Docs))
+	docs := o.Docs[:0]
+	for _, doc := range o.Docs {
+		if k := (docKey{doc.DefKey, doc.Format}); !seenDocs[k] {
+			seenDocs[k] = true
+			docs = append(docs, doc)
+		}
+	}
+	o.Docs = docs
+	return nil
+}
@@ -0,0 +1,88 @@
+package grapher
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestNormalizeDataWith(t *testing.T) {
+	outputs := func() []*ToolchainOutput {
+		return []*ToolchainOutput{
+			{Toolchain: "a", Output: &Output{
+				Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "pkg/b"}, Kind: "function"}, {DefKey: graph.DefKey{Path: "pkg/a"}, Kind: "class"}},
+				Refs: []*graph.Ref{{DefPath: "pkg/b", File: "f", Start: 1, End: 2}},
+				Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "pkg/b"}, Format: "text/plain", Data: "b"}},
+			}},
+			{Toolchain: "b", Output: &Output{
+				Refs: []*graph.Ref{{DefPath: "pkg/b", File: "f", Start: 1, End: 2}},
+				Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "pkg/b"}, Format: "text/plain", Data: "b"}},
+			}},
+		}
+	}
+	paths := func(o *Output) []string {
+		var ps []string
+		for _, def := range o.Defs {
+			ps = append(ps, string(def.Path)+":"+string(def.Kind))
+		}
+		for _, ref := range o.Refs {
+			ps = append(ps, "ref "+string(ref.DefPath))
+		}
+		for _, doc := range o.Docs {
+			ps = append(ps, "doc "+string(doc.Path))
+		}
+		return ps
+	}
+
+	tests := map[string]struct {
+		normalize *unit.Normalize
+		want      []string
+		wantErr   bool
+	}{
+		"default": {
+			want: []string{"pkg/a:class", "pkg/b:function", "ref pkg/b", "ref pkg/b", "doc pkg/b"},
+		},
+		"dedup": {
+			normalize: &unit.Normalize{Enable: []string{"dedup"}},
+			want:      []string{"pkg/a:class", "pkg/b:function", "ref pkg/b", "doc pkg/b"},
+		},
+		"no docs": {
+			normalize: &unit.Normalize{Disable: []string{"docs"}},
+			want:      []string{"pkg/a:class", "pkg/b:function", "ref pkg/b", "ref pkg/b", "doc pkg/b", "doc pkg/b"},
+		},
+		"no sort": {
+			normalize: &unit.Normalize{Disable: []string{"sort"}, Enable: []string{"dedup"}},
+			want:      []string{"pkg/b:function", "pkg/a:class", "ref pkg/b", "doc pkg/b"},
+		},
+		"kinds and paths": {
+			normalize: &unit.Normalize{
+				Enable: []string{"dedup"},
+				Kinds:  map[string]string{"function": "func"},
+				Paths:  []*unit.PathRewrite{{Pattern: "^pkg/(.*)$", Replacement: "lib/$1"}},
+			},
+			want: []string{"lib/a:class", "lib/b:func", "ref lib/b", "doc lib/b"},
+		},
+		"unknown pass": {
+			normalize: &unit.Normalize{Disable: []string{"bogus"}},
+			wantErr:   true,
+		},
+	}
+	for label, test := range tests {
+		o, err := NormalizeDataWith(outputs(), nil, test.normalize)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error, want error", label)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if got := paths(o); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", label, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package grapher
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// ConvertOffsets converts the offsets in o, which are of the given kind, to
+// byte offsets, reading the files that o refers to with readFile. Offsets in
+// files that can't be read are left unchanged, and offsets past the end of
+// their file are logged and left unchanged.
+func ConvertOffsets(o *Output, offsets toolchain.Offsets, readFile func(string) ([]byte, error)) error {
+	var unitLen func(r rune) int
+	switch offsets {
+	case "", toolchain.ByteOffsets:
+		return nil
+	case toolchain.RuneOffsets:
+		unitLen = func(rune) int { return 1 }
+	case toolchain.UTF16Offsets:
+		unitLen = func(r rune) int {
+			if r >= 0x10000 && r <= utf8.MaxRune {
+				return 2 // surrogate pair
+			}
+			return 1
+		}
+	default:
+		return fmt.Errorf("unknown offsets %q (expected %q, %q, or %q)", offsets, toolchain.ByteOffsets, toolchain.RuneOffsets, toolchain.UTF16Offsets)
+	}
+
+	// byteOffsets maps each file to the byte offset of each of its offsets
+	// (and of its end), or to nil if the file can't be read.
+	byteOffsets := map[string][]int{}
+	index := func(filename string) []int {
+		if b, present := byteOffsets[filename]; present {
+			return b
+		}
+		var b []int
+		if data, err := readFile(filename); err == nil {
+			b = make([]int, 0, len(data)+1)
+			for i := 0; i < len(data); {
+				r, size := utf8.DecodeRune(data[i:])
+				for n := unitLen(r); n > 0; n-- {
+					b = append(b, i)
+				}
+				i += size
+			}
+			b = append(b, len(data))
+		}
+		byteOffsets[filename] = b
+		return b
+	}
+
+	fix := func(filename string, offsets ...*int) {
+		if filename == "" {
+			return
+		}
+		b := index(filename)
+		if b == nil {
+			return
+		}
+		for _, offset := range offsets {
+			if *offset < 0 || *offset >= len(b) {
+				log.Printf("Warning: offset %d is past the end of file %s (did the grapher output offsets of the wrong kind?); leaving it unchanged.", *offset, filename)
+				continue
+			}
+			*offset = b[*offset]
+		}
+	}
+
+	for _, s := range o.Defs {
+		fix(s.File, &s.DefStart, &s.DefEnd)
+	}
+	for _, r := range o.Refs {
+		fix(r.File, &r.Start, &r.End)
+	}
+	for _, d := range o.Docs {
+		fix(d.File, &d.Start, &d.End)
+	}
+	return nil
+}
+
+// ConvertToolchainOffsets converts the offsets in each of the outputs to
+// byte offsets (see ConvertOffsets), according to the offsets that its
+// toolchain declares in its Srclibtoolchain. Outputs of toolchains that
+// don't declare their offsets, or that can't be found, are assumed to have
+// byte offsets.
+func ConvertToolchainOffsets(outputs []*ToolchainOutput, readFile func(string) ([]byte, error)) error {
+	for _, o := range outputs {
+		if o.Toolchain == "" || o.Output == nil {
+			continue
+		}
+		offsets, err := toolchain.DeclaredOffsets(o.Toolchain)
+		if err != nil {
+			continue
+		}
+		if err := ConvertOffsets(o.Output, offsets, readFile); err != nil {
+			return fmt.Errorf("toolchain %s: %s", o.Toolchain, err)
+		}
+	}
+	return nil
+}
+
+// unitOffsets returns the offsets that the toolchain of the grapher of u
+// declares, or "" if it doesn't declare them or can't be found.
+func unitOffsets(u *unit.SourceUnit) toolchain.Offsets {
+	toolRef := u.Ops["graph"]
+	if toolRef == nil {
+		var err error
+		if toolRef, err = toolchain.ChooseTool("graph", u.Type); err != nil {
+			return ""
+		}
+	}
+	offsets, err := toolchain.DeclaredOffsets(toolRef.Toolchain)
+	if err != nil {
+		return ""
+	}
+	return offsets
+}
@@ -0,0 +1,83 @@
+package grapher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func TestConvertOffsets(t *testing.T) {
+	// "héllo wörld 𝔵y": é and ö are 2 bytes, 𝔵 is 4 bytes and 2 UTF-16
+	// code units.
+	fs := vfs.Map(map[string]string{"a": "héllo wörld 𝔵y"})
+	tests := []struct {
+		offsets            toolchain.Offsets
+		start, end         int
+		wantStart, wantEnd int
+	}{
+		{offsets: "", start: 6, end: 11, wantStart: 6, wantEnd: 11},
+		{offsets: toolchain.ByteOffsets, start: 6, end: 11, wantStart: 6, wantEnd: 11},
+		{offsets: toolchain.RuneOffsets, start: 6, end: 11, wantStart: 7, wantEnd: 13},
+		{offsets: toolchain.RuneOffsets, start: 12, end: 14, wantStart: 14, wantEnd: 19},
+		{offsets: toolchain.UTF16Offsets, start: 6, end: 11, wantStart: 7, wantEnd: 13},
+		{offsets: toolchain.UTF16Offsets, start: 12, end: 15, wantStart: 14, wantEnd: 19},
+		{offsets: toolchain.UTF16Offsets, start: 14, end: 99, wantStart: 18, wantEnd: 99},
+	}
+	for _, test := range tests {
+		o := &Output{
+			Defs: []*graph.Def{{File: "a", DefStart: test.start, DefEnd: test.end}},
+			Refs: []*graph.Ref{{File: "a", Start: test.start, End: test.end}, {File: "nonexistent", Start: 1, End: 2}},
+		}
+		if err := ConvertOffsets(o, test.offsets, fs.ReadFile); err != nil {
+			t.Errorf("%q: %s", test.offsets, err)
+			continue
+		}
+		if d := o.Defs[0]; d.DefStart != test.wantStart || d.DefEnd != test.wantEnd {
+			t.Errorf("%q %d-%d: got def offsets %d-%d, want %d-%d", test.offsets, test.start, test.end, d.DefStart, d.DefEnd, test.wantStart, test.wantEnd)
+		}
+		if r := o.Refs[0]; r.Start != test.wantStart || r.End != test.wantEnd {
+			t.Errorf("%q %d-%d: got ref offsets %d-%d, want %d-%d", test.offsets, test.start, test.end, r.Start, r.End, test.wantStart, test.wantEnd)
+		}
+		if r := o.Refs[1]; r.Start != 1 || r.End != 2 {
+			t.Errorf("%q: got offsets %d-%d of ref in nonexistent file, want unchanged", test.offsets, r.Start, r.End)
+		}
+	}
+
+	if err := ConvertOffsets(&Output{}, "line-col", fs.ReadFile); err == nil {
+		t.Error("got no error for unknown offsets")
+	}
+}
+
+func TestNormalizeOffsets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclib-offsets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("é=1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	g := GrapherFunc(func(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+		return &Output{Refs: []*graph.Ref{{File: "a", Start: 1, End: 2}}}, nil
+	})
+
+	// Without a toolchain that declares its offsets, the offsets are
+	// guessed by source unit type.
+	tests := map[string]int{"NormalizeOffsetsTestBytes": 1, "NormalizeOffsetsTestRunes": 2}
+	for unitType, wantStart := range tests {
+		o, err := Chain(g, NormalizeOffsets("NormalizeOffsetsTestBytes")).Graph(dir, &unit.SourceUnit{Type: unitType}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start := o.Refs[0].Start; start != wantStart {
+			t.Errorf("%s: got ref start %d, want %d", unitType, start, wantStart)
+		}
+	}
+}
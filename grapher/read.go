@@ -0,0 +1,55 @@
+package grapher
+
+import "io"
+
+// Limits on the graph output that ReadOutput and OutputDecoder read. They
+// protect programs that read build data (such as the browse server and the
+// importer) from corrupted or malicious graph output files.
+var (
+	// MaxOutputSize is the maximum size of graph output, in bytes. The
+	// output is decoded as it's read, without holding its JSON in memory,
+	// so this only limits the memory used by programs that keep all of the
+	// output's items (as ReadOutput does) to about the size of the items
+	// that fit in it. Programs that process large outputs item by item
+	// should use StreamOutput or OutputDecoder, which only hold the item
+	// being decoded.
+	MaxOutputSize int64 = 4 << 30
+
+	// MaxOutputDepth is the maximum nesting depth of arrays and objects in
+	// graph output. The Output struct itself uses 4 levels; the rest are
+	// available to defs' Data.
+	MaxOutputDepth = 128
+)
+
+// ReadOutput reads graph output (as written by graphers and stored in build
+// data) from r. Unlike decoding the JSON directly, it returns an error if the
+// output exceeds MaxOutputSize or MaxOutputDepth or if it contains null
+// defs, refs, docs, aliases, type relations, or ref candidates, which code
+// that uses the output assumes are non-nil. The output is read item by item
+// (see OutputDecoder), so its JSON is never held in memory in full. The
+// output's repeated strings (such as file paths) are interned, so that they
+// are only held in memory once.
+func ReadOutput(r io.Reader) (*Output, error) {
+	var o Output
+	d := NewOutputDecoder(r)
+	for {
+		item, err := d.Next()
+		if err == io.EOF {
+			return &o, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case item.Def != nil:
+			o.Defs = append(o.Defs, item.Def)
+		case item.Ref != nil:
+			o.Refs = append(o.Refs, item.Ref)
+		case item.Doc != nil:
+			o.Docs = append(o.Docs, item.Doc)
+		case item.Alias != nil:
+			o.Aliases = append(o.Aliases, item.Alias)
+		case item.TypeRelation != nil:
+			o.TypeRelations = append(o.TypeRelations, item.TypeRelation)
+		}
+	}
+}
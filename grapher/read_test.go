@@ -0,0 +1,79 @@
+package grapher
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestReadOutput(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr string
+	}{
+		{data: `{}`},
+		{data: `{"Defs":[{"Name":"f","Data":{"a":[1,{"b":2}]}}],"Refs":[{"DefPath":"f","Candidates":[{"DefPath":"f","Score":1}]}]}`},
+		{data: `{"Defs":[null]}`, wantErr: "def 0 is null"},
+		{data: `{"Refs":[{},null]}`, wantErr: "ref 1 is null"},
+		{data: `{"Refs":[{"Candidates":[null]}]}`, wantErr: "ref 0 candidate 0 is null"},
+		{data: `{"Docs":[null]}`, wantErr: "doc 0 is null"},
+		{data: `{"Aliases":[null]}`, wantErr: "alias 0 is null"},
+		{data: `{"TypeRelations":[null]}`, wantErr: "type relation 0 is null"},
+		{data: `{"Defs":[{"Data":` + strings.Repeat("[", MaxOutputDepth) + strings.Repeat("]", MaxOutputDepth) + `}]}`, wantErr: "nested too deeply"},
+		{data: `{"Defs":[{"Name":"` + strings.Repeat("[", 2*MaxOutputDepth) + `"}]}`}, // brackets in strings don't count
+		{data: `{} {}`, wantErr: "invalid data after the output object"},
+		{data: `{"Defs":`, wantErr: "EOF"},
+		{data: `[]`, wantErr: "want an object"},
+	}
+	for _, test := range tests {
+		_, err := ReadOutput(strings.NewReader(test.data))
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: got error %q, want no error", test.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", test.data, err, test.wantErr)
+		}
+	}
+}
+
+func TestReadOutput_maxSize(t *testing.T) {
+	defer func(orig int64) { MaxOutputSize = orig }(MaxOutputSize)
+	MaxOutputSize = 10
+	if _, err := ReadOutput(strings.NewReader(`{"Defs":[]}`)); err == nil || !strings.Contains(err.Error(), "larger than the maximum size") {
+		t.Errorf("got error %v, want size limit error", err)
+	}
+	if _, err := ReadOutput(strings.NewReader(`{"Refs":[]}`[:10])); err == nil || strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("got error %v, want syntax error (data is exactly the maximum size)", err)
+	}
+}
+
+// FuzzReadOutput checks that reading graph output, and processing it as
+// "src make" does, doesn't panic, however malformed the output is.
+func FuzzReadOutput(f *testing.F) {
+	f.Add([]byte(`{"Defs":[{"Path":"f","Name":"f","Kind":"func","File":"a.go","DefStart":0,"DefEnd":11,"Local":true}],"Refs":[{"DefPath":"f","File":"a.go","Start":5,"End":6,"Def":true,"Candidates":[{"DefPath":"g","Score":0.5}]}],"Docs":[{"Path":"f","Format":"text/plain","Data":"f."}]}`))
+	f.Add([]byte(`{"Defs":[{"Path":"f/$anon","Name":"","File":"a.go","DefStart":-1,"DefEnd":99}],"Aliases":[{"Path":"g","DefPath":"f"}],"TypeRelations":[{"Kind":"implements","Path":"T","DefPath":"I"}]}`))
+	f.Add([]byte(`{"Defs":[null]}`))
+	readFile := func(file string) ([]byte, error) {
+		if file == "a.go" {
+			return []byte("func f() {}\n"), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		o, err := ReadOutput(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		ValidateRefs(o.Refs)
+		NameAnonymousDefs(o, readFile)
+		EmbedSnippets(o, 3, readFile)
+		RemoveLocals(o, &unit.SourceUnit{})
+		NormalizeData([]*ToolchainOutput{{Toolchain: "a", Output: o}, {Toolchain: "b", Output: o}}, nil)
+	})
+}
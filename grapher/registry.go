@@ -1,33 +1,180 @@
-// TODO(sqs): remove this file
 package grapher
 
 import (
-	"reflect"
+	"fmt"
+	"sort"
+	"sync"
 
+	"sourcegraph.com/sourcegraph/srclib/config"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 )
 
-// Graphers holds all registered graphers.
-var Graphers = make(map[reflect.Type]Grapher)
+// A Registry holds the graphers for source unit types (such as
+// "GoPackage"). It is safe for concurrent use, so programs that embed
+// srclib can register, replace, and unregister graphers while source units
+// are being graphed.
+type Registry struct {
+	mu         sync.RWMutex
+	graphers   map[string]Grapher
+	middleware []Middleware
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{graphers: make(map[string]Grapher)}
+}
+
+// Graphers is the registry that Graph uses, and that the package-level
+// Register, Unregister, and Lookup funcs operate on. Its graphers' output is
+// sorted, and converted to byte offsets according to the offsets that their
+// toolchains declare (see NormalizeOffsets). If a toolchain doesn't declare
+// its offsets, Go packages' graphers are assumed to output byte offsets and
+// other graphers Unicode character offsets.
+var Graphers = NewRegistry()
+
+func init() {
+	Graphers.Use(Sort, NormalizeOffsets("GoPackage"))
+}
+
+// DefaultUnitType is the pseudo source unit type whose grapher (if any)
+// graphs the source units whose types have no grapher of their own.
+const DefaultUnitType = "*"
 
-// Register sets the grapher to be used for source units of the given type.
-// If Register is called twice with the same name or if grapher is nil, it
-// panics
-func Register(emptySourceUnit unit.SourceUnit, grapher Grapher) {
-	typ := ptrTo(emptySourceUnit)
-	if _, dup := Graphers[typ]; dup {
-		panic("grapher: Register called twice for source unit type " + typ.String())
+// A ConflictError is returned when registering a grapher for a source unit
+// type that already has one.
+type ConflictError struct {
+	UnitType string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("grapher: a grapher is already registered for source unit type %q", e.UnitType)
+}
+
+// Register sets the grapher for source units of the given type. It returns
+// a *ConflictError if the type already has a grapher (use Replace to
+// change it), and an error if unitType is empty or g is nil.
+func (r *Registry) Register(unitType string, g Grapher) error {
+	if err := checkRegistration(unitType, g); err != nil {
+		return err
 	}
-	if grapher == nil {
-		panic("grapher: Register grapher is nil")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.graphers[unitType]; dup {
+		return &ConflictError{UnitType: unitType}
 	}
-	Graphers[typ] = grapher
+	r.graphers[unitType] = g
+	return nil
 }
 
-func ptrTo(v interface{}) reflect.Type {
-	typ := reflect.TypeOf(v)
-	if typ.Kind() != reflect.Ptr {
-		typ = reflect.PtrTo(typ)
+// Replace sets the grapher for source units of the given type, whether or
+// not it already has one, and returns the previous grapher (or nil). It
+// returns an error if unitType is empty or g is nil.
+func (r *Registry) Replace(unitType string, g Grapher) (Grapher, error) {
+	if err := checkRegistration(unitType, g); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.graphers[unitType]
+	r.graphers[unitType] = g
+	return old, nil
+}
+
+func checkRegistration(unitType string, g Grapher) error {
+	if unitType == "" {
+		return fmt.Errorf("grapher: source unit type is empty")
+	}
+	if g == nil {
+		return fmt.Errorf("grapher: grapher for source unit type %q is nil", unitType)
+	}
+	return nil
+}
+
+// Unregister removes the grapher for source units of the given type, and
+// returns whether there was one.
+func (r *Registry) Unregister(unitType string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, present := r.graphers[unitType]
+	delete(r.graphers, unitType)
+	return present
+}
+
+// Lookup returns the grapher for source units of the given type, or else
+// the grapher for DefaultUnitType, or nil if there is neither.
+func (r *Registry) Lookup(unitType string) Grapher {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lookup(unitType)
+}
+
+func (r *Registry) lookup(unitType string) Grapher {
+	if g, present := r.graphers[unitType]; present {
+		return g
+	}
+	return r.graphers[DefaultUnitType]
+}
+
+// Use adds middleware that wraps the registry's graphers when source units
+// are graphed with r.Graph. The middleware is outside of (and sees the
+// output of) any middleware that was added earlier.
+func (r *Registry) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(append([]Middleware{}, mws...), r.middleware...)
+}
+
+// Graph graphs the source unit (whose repository is cloned to dir) with
+// the grapher for its type, wrapped by the registry's middleware.
+func (r *Registry) Graph(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+	r.mu.RLock()
+	g, mws := r.lookup(u.Type), r.middleware
+	r.mu.RUnlock()
+	if g == nil {
+		return nil, fmt.Errorf("no grapher registered for source unit type %q", u.Type)
+	}
+	return Chain(g, mws...).Graph(dir, u, c)
+}
+
+// UnitTypes returns the source unit types that have graphers, in sorted
+// order.
+func (r *Registry) UnitTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.graphers))
+	for t := range r.graphers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Register sets the grapher for source units of the given type in
+// Graphers. See Registry.Register.
+func Register(unitType string, g Grapher) error { return Graphers.Register(unitType, g) }
+
+// Unregister removes the grapher for source units of the given type from
+// Graphers. See Registry.Unregister.
+func Unregister(unitType string) bool { return Graphers.Unregister(unitType) }
+
+// Lookup returns the grapher in Graphers for source units of the given
+// type, or nil if there is none.
+func Lookup(unitType string) Grapher { return Graphers.Lookup(unitType) }
+
+// RegisterSourceUnit sets the grapher in Graphers for source units of the
+// type of emptySourceUnit (whose other fields are ignored), and panics if it
+// can't. It is for programs written for the old registry, which was keyed by
+// the Go type of the source unit and whose Register panicked. Those programs
+// passed a zero source unit, with no Type, so that the grapher was used for
+// all source units; in that case, it is registered for DefaultUnitType.
+//
+// Deprecated: Use Register with the source unit type.
+func RegisterSourceUnit(emptySourceUnit unit.SourceUnit, g Grapher) {
+	unitType := emptySourceUnit.Type
+	if unitType == "" {
+		unitType = DefaultUnitType
+	}
+	if err := Register(unitType, g); err != nil {
+		panic(err)
 	}
-	return typ
 }
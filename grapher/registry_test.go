@@ -0,0 +1,116 @@
+package grapher
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+type testGrapher struct{ name string }
+
+func (g *testGrapher) Graph(dir string, u *unit.SourceUnit, c *config.Repository) (*Output, error) {
+	return &Output{}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	a, b := &testGrapher{"a"}, &testGrapher{"b"}
+
+	if err := r.Register("t", a); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Register("t", b)
+	if e, ok := err.(*ConflictError); !ok || e.UnitType != "t" {
+		t.Errorf("got error %v registering twice, want *ConflictError", err)
+	}
+	if g := r.Lookup("t"); g != a {
+		t.Errorf("got grapher %v after conflict, want %v", g, a)
+	}
+	if err := r.Register("", a); err == nil {
+		t.Error("got no error registering an empty unit type")
+	}
+	if err := r.Register("u", nil); err == nil {
+		t.Error("got no error registering a nil grapher")
+	}
+
+	if old, err := r.Replace("t", b); err != nil || old != a {
+		t.Errorf("got Replace %v, %v, want %v, nil", old, err, a)
+	}
+	if g := r.Lookup("t"); g != b {
+		t.Errorf("got grapher %v after Replace, want %v", g, b)
+	}
+	if err := r.Register("u", a); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.UnitTypes(), []string{"t", "u"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got unit types %v, want %v", got, want)
+	}
+
+	if !r.Unregister("t") {
+		t.Error("got Unregister false for a registered type")
+	}
+	if r.Unregister("t") {
+		t.Error("got Unregister true for an unregistered type")
+	}
+	if g := r.Lookup("t"); g != nil {
+		t.Errorf("got grapher %v after Unregister, want nil", g)
+	}
+}
+
+func TestRegistry_concurrent(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.Replace("t", &testGrapher{})
+			r.Unregister("t")
+		}()
+		go func() {
+			defer wg.Done()
+			r.Lookup("t")
+			r.UnitTypes()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegisterSourceUnit(t *testing.T) {
+	g := &testGrapher{}
+	RegisterSourceUnit(unit.SourceUnit{Type: "RegisterSourceUnitTest"}, g)
+	defer Unregister("RegisterSourceUnitTest")
+	if got := Lookup("RegisterSourceUnitTest"); got != g {
+		t.Errorf("got grapher %v, want %v", got, g)
+	}
+
+	defer func() {
+		if _, ok := recover().(*ConflictError); !ok {
+			t.Error("got no *ConflictError panic registering twice")
+		}
+	}()
+	RegisterSourceUnit(unit.SourceUnit{Type: "RegisterSourceUnitTest"}, g)
+}
+
+func TestRegisterSourceUnit_noType(t *testing.T) {
+	g, gopkg := &testGrapher{"default"}, &testGrapher{"GoPackage"}
+	RegisterSourceUnit(unit.SourceUnit{}, g)
+	defer Unregister(DefaultUnitType)
+	if err := Register("RegisterSourceUnitTest", gopkg); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister("RegisterSourceUnitTest")
+
+	if got := Lookup("OtherType"); got != g {
+		t.Errorf("got grapher %v for an unregistered type, want the default %v", got, g)
+	}
+	if got := Lookup("RegisterSourceUnitTest"); got != gopkg {
+		t.Errorf("got grapher %v for a registered type, want %v", got, gopkg)
+	}
+	if _, err := Graph("", &unit.SourceUnit{Type: "OtherType"}, nil); err != nil {
+		t.Errorf("got error %v graphing with the default grapher", err)
+	}
+}
@@ -3,10 +3,13 @@ package grapher
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/sourcegraph/makex"
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
 	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/depsrc"
 	"sourcegraph.com/sourcegraph/srclib/plan"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
 	"sourcegraph.com/sourcegraph/srclib/unit"
@@ -32,18 +35,38 @@ func makeGraphRules(c *config.Tree, dataDir string, existing []makex.Rule, opt p
 			toolRef = choice
 		}
 
-		rules = append(rules, &GraphUnitRule{dataDir, u, toolRef, opt})
+		rules = append(rules, &GraphUnitRule{dataDir, u, toolRef, opt, readsDepSources(toolRef.Toolchain)})
 	}
 	return rules, nil
 }
 
+// readsDepSources reports whether the toolchain's graphers read the sources
+// of their source units' dependencies (see toolchain.DepSources).
+func readsDepSources(toolchainPath string) bool {
+	tc, err := toolchain.Lookup(toolchainPath)
+	if err != nil {
+		return false
+	}
+	s, err := tc.Negotiate()
+	return err == nil && s.Has(toolchain.DepSources)
+}
+
 type GraphUnitRule struct {
 	dataDir string
 	Unit    *unit.SourceUnit
 	Tool    *toolchain.ToolRef
 	opt     plan.Options
+
+	// DepSources is whether the grapher reads the sources of the unit's
+	// dependencies, which are checked out (using the unit's depresolve
+	// output) before it runs.
+	DepSources bool
 }
 
+func (r *GraphUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *GraphUnitRule) ToolRef() *toolchain.ToolRef { return r.Tool }
+
 func (r *GraphUnitRule) Target() string {
 	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&Output{}, r.Unit))
 }
@@ -51,11 +74,51 @@ func (r *GraphUnitRule) Target() string {
 func (r *GraphUnitRule) Prereqs() []string {
 	ps := []string{filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))}
 	ps = append(ps, r.Unit.Files...)
+	if r.DepSources {
+		ps = append(ps, r.depresolveFile())
+	}
 	return ps
 }
 
+func (r *GraphUnitRule) depresolveFile() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename([]*dep.ResolvedDep{}, r.Unit))
+}
+
 func (r *GraphUnitRule) Recipes() []string {
+	normalizeArgs := ""
+	if normalizeUsesUnit(r.Unit) {
+		normalizeArgs = fmt.Sprintf(" --toolchain %q --unit-data %s", r.Tool.Toolchain, makex.Quote(filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))))
+	} else if convertsOffsets(r.Tool.Toolchain) {
+		normalizeArgs = fmt.Sprintf(" --toolchain %q", r.Tool.Toolchain)
+	}
+	input := "$^"
+	if r.DepSources {
+		// The grapher doesn't read the depresolve output (the last prereq).
+		ps := r.Prereqs()
+		input = strings.Join(ps[:len(ps)-1], " ")
+	}
+	graph := fmt.Sprintf("src tool %s %q %q < %s | src internal normalize-graph-data%s 1> $@", r.opt.ToolchainExecOpt, r.Tool.Toolchain, r.Tool.Subcmd, input, normalizeArgs)
+	if !r.DepSources {
+		return []string{graph}
+	}
+	depSourcesFile := makex.Quote(filepath.Join(r.dataDir, plan.SourceUnitDataFilename([]*depsrc.Source{}, r.Unit)))
 	return []string{
-		fmt.Sprintf("src tool %s %q %q < $^ | src internal normalize-graph-data 1> $@", r.opt.ToolchainExecOpt, r.Tool.Toolchain, r.Tool.Subcmd),
+		fmt.Sprintf("src internal dep-sources < %s 1> %s", makex.Quote(r.depresolveFile()), depSourcesFile),
+		fmt.Sprintf("%s=%s %s", toolchain.DepSourcesEnv, depSourcesFile, graph),
 	}
 }
+
+// normalizeUsesUnit reports whether the normalization of u's graph output
+// depends on options in u's definition, which must then be passed to `src
+// internal normalize-graph-data`.
+func normalizeUsesUnit(u *unit.SourceUnit) bool {
+	return u.DefMerge != nil || (u.IncludeLocals != nil && !*u.IncludeLocals) || u.Snippets != nil || u.Normalize != nil || len(u.Filters) > 0
+}
+
+// convertsOffsets reports whether the toolchain's graphers output offsets
+// other than byte offsets, which `src internal normalize-graph-data` must
+// then convert (so it must be told the toolchain).
+func convertsOffsets(toolchainPath string) bool {
+	offsets, err := toolchain.DeclaredOffsets(toolchainPath)
+	return err == nil && offsets != "" && offsets != toolchain.ByteOffsets
+}
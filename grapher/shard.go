@@ -0,0 +1,207 @@
+package grapher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A ShardIndex is the index of a source unit's graph output that has been
+// split into one shard per file (see Shards), so that the data about a
+// single file can be read without reading the whole output. The shards are
+// stored in the directory ShardDir(indexFile), next to the index.
+type ShardIndex struct {
+	// Files describes the shards, sorted by file. The defs, refs, and docs
+	// that aren't in any file are in the shard whose File is empty.
+	Files []*ShardInfo
+
+	// Aliases and TypeRelations are the output's aliases and type
+	// relations, which aren't sharded.
+	Aliases       []*graph.Alias        `json:",omitempty"`
+	TypeRelations []*graph.TypeRelation `json:",omitempty"`
+}
+
+// ShardInfo describes the shard of a file.
+type ShardInfo struct {
+	File string
+
+	// Shard is the name of the shard's file in the shard directory.
+	Shard string
+
+	// Defs, Refs, and Docs are the numbers of defs, refs, and docs in the
+	// shard.
+	Defs, Refs, Docs int
+}
+
+// A Shard is the part of a source unit's graph output that is about a
+// single file: the defs and refs in the file, and the docs of those defs
+// (or, for docs of defs that aren't in the output, the docs in the file).
+type Shard struct {
+	File string
+	Defs []*graph.Def `json:",omitempty"`
+	Refs []*graph.Ref `json:",omitempty"`
+	Docs []*graph.Doc `json:",omitempty"`
+}
+
+// ShardDir returns the directory of the shards of the graph output whose
+// index is stored in indexFile.
+func ShardDir(indexFile string) string {
+	return strings.TrimSuffix(indexFile, ".json") + ".d"
+}
+
+// shardName returns the name of the shard of file in the shard directory.
+func shardName(file string) string {
+	h := sha1.Sum([]byte(file))
+	return hex.EncodeToString(h[:])[:16] + "." + buildstore.DataTypeSuffix(&Shard{})
+}
+
+// Shards splits o into one shard per file, and returns them along with
+// their index. Each shard's items are sorted like o's would be.
+func Shards(o *Output) (*ShardIndex, []*Shard) {
+	byFile := make(map[string]*Shard)
+	shard := func(file string) *Shard {
+		s, present := byFile[file]
+		if !present {
+			s = &Shard{File: file}
+			byFile[file] = s
+		}
+		return s
+	}
+
+	defFiles := make(map[graph.DefKey]string, len(o.Defs))
+	for _, def := range o.Defs {
+		s := shard(def.File)
+		s.Defs = append(s.Defs, def)
+		defFiles[def.DefKey] = def.File
+	}
+	for _, ref := range o.Refs {
+		s := shard(ref.File)
+		s.Refs = append(s.Refs, ref)
+	}
+	for _, doc := range o.Docs {
+		file, present := defFiles[doc.DefKey]
+		if !present {
+			file = doc.File
+		}
+		s := shard(file)
+		s.Docs = append(s.Docs, doc)
+	}
+
+	idx := &ShardIndex{Aliases: o.Aliases, TypeRelations: o.TypeRelations}
+	shards := make([]*Shard, 0, len(byFile))
+	for _, s := range byFile {
+		sortedOutput(&Output{Defs: s.Defs, Refs: s.Refs, Docs: s.Docs})
+		shards = append(shards, s)
+	}
+	sort.Sort(shardsByFile(shards))
+	for _, s := range shards {
+		idx.Files = append(idx.Files, &ShardInfo{File: s.File, Shard: shardName(s.File), Defs: len(s.Defs), Refs: len(s.Refs), Docs: len(s.Docs)})
+	}
+	return idx, shards
+}
+
+type shardsByFile []*Shard
+
+func (v shardsByFile) Len() int           { return len(v) }
+func (v shardsByFile) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v shardsByFile) Less(i, j int) bool { return v[i].File < v[j].File }
+
+// A ShardedOutput reads a source unit's sharded graph output, reading
+// each shard only when it's first needed. It is safe for concurrent use.
+type ShardedOutput struct {
+	Index *ShardIndex
+
+	dir  string
+	open func(name string) (io.ReadCloser, error)
+
+	mu     sync.Mutex
+	shards map[string]*Shard // by file
+}
+
+// ReadShardedOutput reads the shard index in indexFile (using open, which
+// is also used to read the shards later). If the index doesn't exist, the
+// error satisfies os.IsNotExist.
+func ReadShardedOutput(indexFile string, open func(name string) (io.ReadCloser, error)) (*ShardedOutput, error) {
+	f, err := open(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var idx *ShardIndex
+	if err := json.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("%s: %s", indexFile, err)
+	}
+	if idx == nil {
+		return nil, fmt.Errorf("%s: no shard index", indexFile)
+	}
+	return &ShardedOutput{
+		Index:  idx,
+		dir:    ShardDir(indexFile),
+		open:   open,
+		shards: make(map[string]*Shard),
+	}, nil
+}
+
+// Files returns the files that have shards, sorted. It includes "" if any
+// defs, refs, or docs aren't in a file.
+func (s *ShardedOutput) Files() []string {
+	files := make([]string, len(s.Index.Files))
+	for i, info := range s.Index.Files {
+		files[i] = info.File
+	}
+	return files
+}
+
+// Shard returns the shard of file, or nil if there is none.
+func (s *ShardedOutput) Shard(file string) (*Shard, error) {
+	i := sort.Search(len(s.Index.Files), func(i int) bool { return s.Index.Files[i].File >= file })
+	if i == len(s.Index.Files) || s.Index.Files[i].File != file {
+		return nil, nil
+	}
+	info := s.Index.Files[i]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sh, present := s.shards[file]; present {
+		return sh, nil
+	}
+	name := filepath.Join(s.dir, info.Shard)
+	f, err := s.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sh *Shard
+	if err := json.NewDecoder(f).Decode(&sh); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if sh == nil || sh.File != file {
+		return nil, fmt.Errorf("%s: not the shard of file %q", name, file)
+	}
+	s.shards[file] = sh
+	return sh, nil
+}
+
+// Output reads all of the shards and returns the whole output.
+func (s *ShardedOutput) Output() (*Output, error) {
+	o := &Output{Aliases: s.Index.Aliases, TypeRelations: s.Index.TypeRelations}
+	for _, info := range s.Index.Files {
+		sh, err := s.Shard(info.File)
+		if err != nil {
+			return nil, err
+		}
+		o.Defs = append(o.Defs, sh.Defs...)
+		o.Refs = append(o.Refs, sh.Refs...)
+		o.Docs = append(o.Docs, sh.Docs...)
+	}
+	return sortedOutput(o), nil
+}
@@ -0,0 +1,56 @@
+package grapher
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	plan.RegisterRuleMaker("graph-shards", makeShardRules)
+	buildstore.RegisterDataType("graphindex", &ShardIndex{})
+	buildstore.RegisterDataType("graphshard", &Shard{})
+}
+
+// makeShardRules makes rules for sharding the graph output of each source
+// unit by file, which must wait until graphing completes.
+func makeShardRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	var rules []makex.Rule
+	for _, rule := range existing {
+		gr, ok := rule.(*GraphUnitRule)
+		if !ok {
+			continue
+		}
+		rules = append(rules, &ShardUnitRule{dataDir, gr.Unit, gr.Target()})
+	}
+	return rules, nil
+}
+
+// ShardUnitRule shards a source unit's graph output by file (see Shards).
+// Its target is the shard index; the shards are written to the directory
+// ShardDir(target).
+type ShardUnitRule struct {
+	dataDir     string
+	Unit        *unit.SourceUnit
+	GraphOutput string
+}
+
+func (r *ShardUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *ShardUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&ShardIndex{}, r.Unit))
+}
+
+func (r *ShardUnitRule) Prereqs() []string { return []string{r.GraphOutput} }
+
+func (r *ShardUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src internal unit-graph-shards --graph-data %s --shard-dir %s 1> $@", makex.Quote(r.GraphOutput), makex.Quote(ShardDir(r.Target()))),
+	}
+}
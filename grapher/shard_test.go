@@ -0,0 +1,108 @@
+package grapher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestShards(t *testing.T) {
+	o := sortedOutput(&Output{
+		Defs: []*graph.Def{
+			{DefKey: graph.DefKey{Path: "a"}, File: "a.go"},
+			{DefKey: graph.DefKey{Path: "b"}, File: "b.go"},
+			{DefKey: graph.DefKey{Path: "p"}},
+		},
+		Refs: []*graph.Ref{
+			{DefPath: "a", File: "a.go", Start: 1, End: 2, Def: true},
+			{DefPath: "a", File: "b.go", Start: 3, End: 4},
+			{DefPath: "b", File: "b.go", Start: 1, End: 2, Def: true},
+		},
+		Docs: []*graph.Doc{
+			{DefKey: graph.DefKey{Path: "b"}, Data: "b", File: "c.go"},
+			{DefKey: graph.DefKey{Path: "x"}, Data: "x", File: "c.go"},
+		},
+		Aliases: []*graph.Alias{{Path: "c", DefPath: "a"}},
+	})
+
+	idx, shards := Shards(o)
+	var files []string
+	for i, info := range idx.Files {
+		files = append(files, info.File)
+		if info.File != shards[i].File || info.Defs != len(shards[i].Defs) || info.Refs != len(shards[i].Refs) || info.Docs != len(shards[i].Docs) {
+			t.Errorf("index entry %+v doesn't describe shard %+v", info, shards[i])
+		}
+	}
+	if want := []string{"", "a.go", "b.go", "c.go"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("got shards of files %v, want %v", files, want)
+	}
+	// The doc of b is in b's shard, not in the shard of the file it was
+	// extracted from.
+	if b := shards[2]; len(b.Defs) != 1 || len(b.Refs) != 2 || len(b.Docs) != 1 || b.Docs[0].Data != "b" {
+		t.Errorf("got shard %+v of b.go", b)
+	}
+
+	// Store the index and shards like `src internal unit-graph-shards`, and
+	// read them lazily.
+	stored := make(map[string][]byte)
+	store := func(name string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored[name] = data
+	}
+	store("u/t.graphindex.json", idx)
+	for i, s := range shards {
+		store(filepath.Join("u/t.graphindex.d", idx.Files[i].Shard), s)
+	}
+	var opened []string
+	open := func(name string) (io.ReadCloser, error) {
+		data, present := stored[name]
+		if !present {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		opened = append(opened, name)
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	if _, err := ReadShardedOutput("v/t.graphindex.json", open); !os.IsNotExist(err) {
+		t.Errorf("got error %v for a nonexistent index, want a not-exist error", err)
+	}
+	s, err := ReadShardedOutput("u/t.graphindex.json", open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		a, err := s.Shard("a.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a == nil || len(a.Defs) != 1 || a.Defs[0].Path != "a" {
+			t.Errorf("got shard %+v of a.go", a)
+		}
+	}
+	if len(opened) != 2 {
+		t.Errorf("opened %v, want only the index and the shard of a.go", opened)
+	}
+	if sh, err := s.Shard("d.go"); sh != nil || err != nil {
+		t.Errorf("got shard %+v and error %v of a file with no shard, want nil", sh, err)
+	}
+
+	all, err := s.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(all)
+	wantJSON, _ := json.Marshal(o)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("got output\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+}
@@ -0,0 +1,42 @@
+package grapher
+
+import "bytes"
+
+// EmbedSnippets sets the Snippet field of each def in o to the def's source
+// text, truncated to its first maxLines lines (unless maxLines is 0). The
+// readFile func returns the contents of a file. Defs whose files can't be
+// read, or whose extents are outside of their files, are left unchanged.
+func EmbedSnippets(o *Output, maxLines int, readFile func(file string) ([]byte, error)) {
+	files := make(map[string][]byte)
+	for _, def := range o.Defs {
+		if def.File == "" {
+			continue
+		}
+		data, present := files[def.File]
+		if !present {
+			var err error
+			if data, err = readFile(def.File); err != nil {
+				data = nil
+			}
+			files[def.File] = data
+		}
+		if data == nil || def.DefStart < 0 || def.DefEnd > len(data) || def.DefStart >= def.DefEnd {
+			continue
+		}
+
+		text := data[def.DefStart:def.DefEnd]
+		if maxLines > 0 {
+			end := 0
+			for i := 0; i < maxLines; i++ {
+				j := bytes.IndexByte(text[end:], '\n')
+				if j == -1 {
+					end = len(text)
+					break
+				}
+				end += j + 1
+			}
+			text = bytes.TrimSuffix(text[:end], []byte("\n"))
+		}
+		def.Snippet = string(text)
+	}
+}
@@ -0,0 +1,42 @@
+package grapher
+
+import (
+	"os"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestEmbedSnippets(t *testing.T) {
+	src := "package p\n\nfunc F() {\n\tg()\n}\n"
+	readFile := func(file string) ([]byte, error) {
+		if file == "p.go" {
+			return []byte(src), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	output := func() *Output {
+		return &Output{Defs: []*graph.Def{
+			{DefKey: graph.DefKey{Path: "F"}, File: "p.go", DefStart: 11, DefEnd: len(src) - 1},
+			{DefKey: graph.DefKey{Path: "G"}, File: "missing.go", DefStart: 0, DefEnd: 5},
+			{DefKey: graph.DefKey{Path: "H"}, File: "p.go", DefStart: 10, DefEnd: 1000},
+		}}
+	}
+
+	tests := map[int]string{
+		0: "func F() {\n\tg()\n}",
+		1: "func F() {",
+		2: "func F() {\n\tg()",
+		5: "func F() {\n\tg()\n}",
+	}
+	for maxLines, want := range tests {
+		o := output()
+		EmbedSnippets(o, maxLines, readFile)
+		if got := o.Defs[0].Snippet; got != want {
+			t.Errorf("max lines %d: got snippet %q, want %q", maxLines, got, want)
+		}
+		if o.Defs[1].Snippet != "" || o.Defs[2].Snippet != "" {
+			t.Errorf("max lines %d: got snippets for defs with unreadable source", maxLines)
+		}
+	}
+}
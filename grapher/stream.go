@@ -0,0 +1,279 @@
+package grapher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+// An OutputItem is a single item of graph output. Exactly one of its fields
+// is set.
+type OutputItem struct {
+	Def          *graph.Def
+	Ref          *graph.Ref
+	Doc          *graph.Doc
+	Alias        *graph.Alias
+	TypeRelation *graph.TypeRelation
+}
+
+// outputSections are the fields of Output that hold items, in the order
+// in which they're written, and the names of their items in errors.
+var outputSections = []struct{ field, item string }{
+	{"Defs", "def"},
+	{"Refs", "ref"},
+	{"Docs", "doc"},
+	{"Aliases", "alias"},
+	{"TypeRelations", "type relation"},
+}
+
+// An OutputDecoder reads graph output (as written by graphers and stored in
+// build data) one item at a time, so that programs that process large
+// outputs item by item don't need to hold them in memory. Like ReadOutput,
+// it returns an error if the output exceeds MaxOutputSize or MaxOutputDepth
+// or if it contains null items or ref candidates, and it interns the items'
+// repeated strings.
+type OutputDecoder struct {
+	dec      *json.Decoder
+	started  bool
+	section  int // index in outputSections of the array being read, or -1
+	index    int // index of the next item in the section
+	interned interner
+
+	// skip is whether items of each section are checked but not decoded
+	// (and not returned).
+	skip [5]bool
+}
+
+// NewOutputDecoder returns a decoder that reads graph output from r.
+func NewOutputDecoder(r io.Reader) *OutputDecoder {
+	return &OutputDecoder{
+		dec:      json.NewDecoder(&maxSizeReader{r: r, n: MaxOutputSize + 1}),
+		section:  -1,
+		interned: make(interner),
+	}
+}
+
+// Next returns the next item, in the order in which the items appear in the
+// output, or io.EOF if there are no more.
+func (d *OutputDecoder) Next() (*OutputItem, error) {
+	if !d.started {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("graph output: %s", io.ErrUnexpectedEOF)
+		} else if err != nil {
+			return nil, fmt.Errorf("graph output: %s", err)
+		}
+		d.started = true
+		if tok == nil {
+			return nil, d.end()
+		}
+		if tok != json.Delim('{') {
+			return nil, fmt.Errorf("graph output: got %v, want an object", tok)
+		}
+	}
+	for {
+		if d.section != -1 {
+			if d.dec.More() {
+				if item, err := d.decodeItem(); err != nil || item != nil {
+					return item, err
+				}
+				continue
+			}
+			if err := d.expect(json.Delim(']')); err != nil {
+				return nil, err
+			}
+			d.section = -1
+		}
+
+		if !d.dec.More() {
+			if err := d.expect(json.Delim('}')); err != nil {
+				return nil, err
+			}
+			return nil, d.end()
+		}
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("graph output: %s", err)
+		}
+		key, _ := tok.(string)
+		for i, s := range outputSections {
+			if strings.EqualFold(key, s.field) {
+				d.section, d.index = i, 0
+			}
+		}
+		if d.section == -1 {
+			// Skip unknown fields (checking their depth, which is 1 level
+			// deeper than that of items).
+			var v json.RawMessage
+			if err := d.dec.Decode(&v); err != nil {
+				return nil, fmt.Errorf("graph output: %s", err)
+			}
+			if err := util.CheckJSONDepth(v, MaxOutputDepth-1); err != nil {
+				return nil, fmt.Errorf("graph output: %s", err)
+			}
+			continue
+		}
+
+		tok, err = d.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("graph output: %s", err)
+		}
+		if tok == nil {
+			d.section = -1
+		} else if tok != json.Delim('[') {
+			return nil, fmt.Errorf("graph output: %s is not an array", key)
+		}
+	}
+}
+
+// decodeItem decodes the next item of the current section. It returns a nil
+// item if the section is skipped.
+func (d *OutputDecoder) decodeItem() (*OutputItem, error) {
+	s := outputSections[d.section]
+	i := d.index
+	d.index++
+
+	// The item's JSON is only needed until it's decoded, so its buffer is
+	// reused.
+	buf := itemBufs.Get().(*json.RawMessage)
+	defer itemBufs.Put(buf)
+	if err := d.dec.Decode(buf); err != nil {
+		return nil, fmt.Errorf("graph output: %s", err)
+	}
+	data := *buf
+	// Items are nested in the output object and an array.
+	if err := util.CheckJSONDepth(data, MaxOutputDepth-2); err != nil {
+		return nil, fmt.Errorf("graph output: %s", err)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, fmt.Errorf("graph output: %s %d is null", s.item, i)
+	}
+	if d.skip[d.section] {
+		return nil, nil
+	}
+
+	var item OutputItem
+	var v interface{}
+	switch d.section {
+	case 0:
+		v = &item.Def
+	case 1:
+		v = &item.Ref
+	case 2:
+		v = &item.Doc
+	case 3:
+		v = &item.Alias
+	case 4:
+		v = &item.TypeRelation
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("graph output: %s %d: %s", s.item, i, err)
+	}
+	if item.Ref != nil {
+		for j, c := range item.Ref.Candidates {
+			if c == nil {
+				return nil, fmt.Errorf("graph output: ref %d candidate %d is null", i, j)
+			}
+		}
+	}
+	d.interned.item(&item)
+	return &item, nil
+}
+
+// itemBufs holds the buffers that items are read into before they're
+// decoded.
+var itemBufs = sync.Pool{New: func() interface{} { return new(json.RawMessage) }}
+
+func (d *OutputDecoder) expect(want json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return fmt.Errorf("graph output: %s", err)
+	}
+	if tok != want {
+		return fmt.Errorf("graph output: got %v, want %v", tok, want)
+	}
+	return nil
+}
+
+// end returns io.EOF if there is no data after the output, or an error if
+// there is.
+func (d *OutputDecoder) end() error {
+	if _, err := d.dec.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("invalid data after the output object")
+		}
+		return fmt.Errorf("graph output: %s", err)
+	}
+	return io.EOF
+}
+
+// OutputHandlers are the funcs that StreamOutput calls with each item of
+// graph output. Items without a handler are skipped.
+type OutputHandlers struct {
+	Def          func(*graph.Def) error
+	Ref          func(*graph.Ref) error
+	Doc          func(*graph.Doc) error
+	Alias        func(*graph.Alias) error
+	TypeRelation func(*graph.TypeRelation) error
+}
+
+// StreamOutput reads graph output from r (see OutputDecoder) and calls the
+// handler of each item with it, in the order in which the items appear. It
+// stops at the first error returned by a handler, and returns it.
+//
+// Items without a handler are skipped without being decoded, so reading
+// only some kinds of items (such as the defs, which are usually far
+// outnumbered by refs) uses little memory and time. Skipped items are only
+// checked for the limits and for being null.
+func StreamOutput(r io.Reader, h OutputHandlers) error {
+	d := NewOutputDecoder(r)
+	d.skip = [5]bool{h.Def == nil, h.Ref == nil, h.Doc == nil, h.Alias == nil, h.TypeRelation == nil}
+	for {
+		item, err := d.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case item.Def != nil:
+			err = h.Def(item.Def)
+		case item.Ref != nil:
+			err = h.Ref(item.Ref)
+		case item.Doc != nil:
+			err = h.Doc(item.Doc)
+		case item.Alias != nil:
+			err = h.Alias(item.Alias)
+		case item.TypeRelation != nil:
+			err = h.TypeRelation(item.TypeRelation)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// A maxSizeReader reads from r, but returns an error instead of reading
+// more than n-1 bytes.
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	if r.n <= 0 {
+		return n, fmt.Errorf("JSON data is larger than the maximum size (%d bytes)", MaxOutputSize)
+	}
+	return n, err
+}
@@ -0,0 +1,107 @@
+package grapher
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestStreamOutput(t *testing.T) {
+	var got []string
+	h := OutputHandlers{
+		Def:          func(def *graph.Def) error { got = append(got, "def "+string(def.Path)); return nil },
+		Ref:          func(ref *graph.Ref) error { got = append(got, "ref "+string(ref.DefPath)); return nil },
+		Doc:          func(doc *graph.Doc) error { got = append(got, "doc "+string(doc.Path)); return nil },
+		Alias:        func(a *graph.Alias) error { got = append(got, "alias "+string(a.Path)); return nil },
+		TypeRelation: func(r *graph.TypeRelation) error { got = append(got, "rel "+string(r.Path)); return nil },
+	}
+	data := `{"Defs":[{"Path":"a","Data":{"x":[1]}},{"Path":"b"}],"Other":{"y":[2]},"refs":[{"DefPath":"a"}],"Docs":null,"Aliases":[{"Path":"c"}],"TypeRelations":[{"Path":"d"}]}`
+
+	tests := map[string]struct {
+		handlers OutputHandlers
+		want     []string
+	}{
+		"all": {
+			handlers: h,
+			want:     []string{"def a", "def b", "ref a", "alias c", "rel d"},
+		},
+		"defs only": {
+			handlers: OutputHandlers{Def: h.Def},
+			want:     []string{"def a", "def b"},
+		},
+		"none": {},
+	}
+	for label, test := range tests {
+		got = nil
+		if err := StreamOutput(strings.NewReader(data), test.handlers); err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", label, got, test.want)
+		}
+	}
+}
+
+func TestStreamOutput_errors(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr string
+
+		decodedOnly bool // whether the error is only found in decoded items
+	}{
+		{data: `{}`},
+		{data: `null`},
+		{data: `{"Defs":[null]}`, wantErr: "def 0 is null"},
+		{data: `{"Refs":[{},null]}`, wantErr: "ref 1 is null"},
+		{data: `{"Refs":[{"Candidates":[null]}]}`, wantErr: "ref 0 candidate 0 is null", decodedOnly: true},
+		{data: `{"TypeRelations":[null]}`, wantErr: "type relation 0 is null"},
+		{data: `{"Defs":[{"Data":` + strings.Repeat("[", MaxOutputDepth) + strings.Repeat("]", MaxOutputDepth) + `}]}`, wantErr: "nested too deeply"},
+		{data: `{"Other":` + strings.Repeat("[", MaxOutputDepth) + strings.Repeat("]", MaxOutputDepth) + `}`, wantErr: "nested too deeply"},
+		{data: `{"Defs":{}}`, wantErr: "Defs is not an array"},
+		{data: `[]`, wantErr: "want an object"},
+		{data: `{} {}`, wantErr: "invalid data after the output object"},
+		{data: `{"Defs":`, wantErr: "EOF"},
+		{data: ``, wantErr: "unexpected EOF"},
+	}
+	for _, test := range tests {
+		// Skipped items must be checked too (except for their contents).
+		for _, h := range []OutputHandlers{{Def: func(*graph.Def) error { return nil }, Ref: func(*graph.Ref) error { return nil }}, {}} {
+			if test.decodedOnly && h.Ref == nil {
+				continue
+			}
+			err := StreamOutput(strings.NewReader(test.data), h)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("%s: got error %q, want no error", test.data, err)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", test.data, err, test.wantErr)
+			}
+		}
+	}
+
+	// Errors returned by handlers stop reading.
+	errStop := errors.New("stop")
+	var n int
+	err := StreamOutput(strings.NewReader(`{"Defs":[{},{}]}`), OutputHandlers{Def: func(*graph.Def) error { n++; return errStop }})
+	if err != errStop || n != 1 {
+		t.Errorf("got error %v after %d defs, want %v after 1", err, n, errStop)
+	}
+}
+
+func TestStreamOutput_maxSize(t *testing.T) {
+	defer func(orig int64) { MaxOutputSize = orig }(MaxOutputSize)
+	MaxOutputSize = 11
+	if err := StreamOutput(strings.NewReader(`{"Defs":[{}]}`), OutputHandlers{}); err == nil || !strings.Contains(err.Error(), "larger than the maximum size") {
+		t.Errorf("got error %v, want size limit error", err)
+	}
+	if err := StreamOutput(strings.NewReader(`{"Defs":[]}`), OutputHandlers{}); err != nil {
+		t.Errorf("got error %v, want no error (data is exactly the maximum size)", err)
+	}
+}
@@ -8,19 +8,57 @@ import (
 	"sourcegraph.com/sourcegraph/srclib/graph"
 )
 
+// ValidateRefs checks refs for problems. Each error in the returned
+// MultiError is a *RefError.
 func ValidateRefs(refs []*graph.Ref) (errs MultiError) {
-	refKeys := make(map[graph.RefKey]struct{})
+	v := NewRefValidator()
 	for _, ref := range refs {
-		key := ref.RefKey()
-		if _, in := refKeys[key]; in {
-			errs = append(errs, fmt.Errorf("duplicate ref key: %+v", key))
-		} else {
-			refKeys[key] = struct{}{}
+		errs = append(errs, v.Validate(ref)...)
+	}
+	return
+}
+
+// A RefValidator checks refs for problems one at a time, so that refs that
+// are read incrementally (see StreamOutput) can be checked without holding
+// all of them in memory. It only retains the keys of the refs.
+type RefValidator struct {
+	refKeys map[graph.RefKey]struct{}
+}
+
+// NewRefValidator returns a validator of a set of refs.
+func NewRefValidator() *RefValidator {
+	return &RefValidator{refKeys: make(map[graph.RefKey]struct{})}
+}
+
+// Validate checks ref for problems, including whether it has the same key
+// as any ref previously checked by v. Each error in the returned MultiError
+// is a *RefError.
+func (v *RefValidator) Validate(ref *graph.Ref) (errs MultiError) {
+	key := ref.RefKey()
+	if _, in := v.refKeys[key]; in {
+		errs = append(errs, &RefError{Ref: ref, Msg: fmt.Sprintf("duplicate ref key: %+v", key)})
+	} else {
+		v.refKeys[key] = struct{}{}
+	}
+	if !ref.Kind.Valid() {
+		errs = append(errs, &RefError{Ref: ref, Msg: fmt.Sprintf("invalid ref kind %q (must be one of %v)", ref.Kind, graph.RefKinds)})
+	}
+	for _, c := range ref.Candidates {
+		if c.Score < 0 || c.Score > 1 {
+			errs = append(errs, &RefError{Ref: ref, Msg: fmt.Sprintf("candidate %s has score %g (must be between 0 and 1)", c.DefPath, c.Score)})
 		}
 	}
 	return
 }
 
+// A RefError is a problem with a specific ref.
+type RefError struct {
+	Ref *graph.Ref
+	Msg string
+}
+
+func (e *RefError) Error() string { return e.Msg }
+
 type MultiError []error
 
 func (e MultiError) Error() string {
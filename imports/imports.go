@@ -0,0 +1,121 @@
+// Package imports represents the file-level import graph of a source unit:
+// which files import which other files and source units. It is produced by a
+// cheap pass (the "imports" op) that doesn't need to build the full def/ref
+// graph, so consumers can determine which files are affected by a change
+// without loading the graph.
+package imports
+
+import (
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// START Import OMIT
+// An Import is an import (or include, require, etc.) of a file or source unit
+// by a file in the source unit that was analyzed.
+type Import struct {
+	// File is the importing file (relative to the repository root).
+	File string
+
+	// ToRepo is the repository containing the imported file or source unit.
+	// It is empty if it is the same repository as File's.
+	ToRepo repo.URI `json:",omitempty"`
+
+	// ToUnitType and ToUnit identify the imported source unit. They are
+	// empty if the import is of a file in the same source unit as File.
+	ToUnitType string `json:",omitempty"`
+	ToUnit     string `json:",omitempty"`
+
+	// ToFile is the imported file (relative to its repository's root), if
+	// known. For imports of whole source units (e.g., Go packages), it is
+	// empty.
+	ToFile string `json:",omitempty"`
+}
+
+// END Import OMIT
+
+// Output is produced by tools that perform the "imports" op.
+type Output struct {
+	Imports []*Import `json:",omitempty"`
+}
+
+// A UnitKey identifies a source unit in a repository.
+type UnitKey struct {
+	Repo     repo.URI `json:",omitempty"`
+	UnitType string
+	Unit     string
+}
+
+// Units returns the source units (other than the analyzed unit itself)
+// imported by files in the output, sorted and without duplicates.
+func (o *Output) Units() []UnitKey {
+	seen := make(map[UnitKey]bool)
+	var units []UnitKey
+	for _, imp := range o.Imports {
+		if imp.ToUnit == "" && imp.ToUnitType == "" {
+			continue
+		}
+		k := UnitKey{Repo: imp.ToRepo, UnitType: imp.ToUnitType, Unit: imp.ToUnit}
+		if !seen[k] {
+			seen[k] = true
+			units = append(units, k)
+		}
+	}
+	sort.Sort(unitKeys(units))
+	return units
+}
+
+type unitKeys []UnitKey
+
+func (vs unitKeys) Len() int      { return len(vs) }
+func (vs unitKeys) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs unitKeys) Less(i, j int) bool {
+	a, b := vs[i], vs[j]
+	if a.Repo != b.Repo {
+		return a.Repo < b.Repo
+	}
+	if a.UnitType != b.UnitType {
+		return a.UnitType < b.UnitType
+	}
+	return a.Unit < b.Unit
+}
+
+// Importers returns the files in the same repository that import file,
+// directly or indirectly, according to imports (which should include the
+// imports of all source units in the repository). Files that import a source
+// unit are considered to import all of the files in unitFiles for that unit.
+// The returned files are sorted.
+func Importers(file string, imports []*Import, unitFiles map[UnitKey][]string) []string {
+	// Map each file to the files that import it.
+	importers := make(map[string][]string)
+	for _, imp := range imports {
+		if imp.ToRepo != "" {
+			continue
+		}
+		if imp.ToFile != "" {
+			importers[imp.ToFile] = append(importers[imp.ToFile], imp.File)
+		} else {
+			for _, f := range unitFiles[UnitKey{UnitType: imp.ToUnitType, Unit: imp.ToUnit}] {
+				importers[f] = append(importers[f], imp.File)
+			}
+		}
+	}
+
+	seen := map[string]bool{file: true}
+	queue := []string{file}
+	var files []string
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		for _, imp := range importers[f] {
+			if !seen[imp] {
+				seen[imp] = true
+				files = append(files, imp)
+				queue = append(queue, imp)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,38 @@
+package imports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImporters(t *testing.T) {
+	imps := []*Import{
+		{File: "a.py", ToFile: "b.py"},
+		{File: "b.py", ToFile: "c.py"},
+		{File: "d.py", ToUnitType: "PipPackage", ToUnit: "lib"},
+		{File: "e.py", ToRepo: "github.com/alice/other", ToFile: "c.py"},
+	}
+	unitFiles := map[UnitKey][]string{
+		{UnitType: "PipPackage", Unit: "lib"}: {"c.py"},
+	}
+
+	if got, want := Importers("c.py", imps, unitFiles), []string{"a.py", "b.py", "d.py"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got importers %v, want %v", got, want)
+	}
+	if got := Importers("a.py", imps, unitFiles); len(got) != 0 {
+		t.Errorf("got importers %v, want none", got)
+	}
+}
+
+func TestOutput_Units(t *testing.T) {
+	o := &Output{Imports: []*Import{
+		{File: "a.go", ToUnitType: "GoPackage", ToUnit: "y"},
+		{File: "b.go", ToUnitType: "GoPackage", ToUnit: "x"},
+		{File: "b.go", ToUnitType: "GoPackage", ToUnit: "y"},
+		{File: "b.go", ToFile: "a.go"},
+	}}
+	want := []UnitKey{{UnitType: "GoPackage", Unit: "x"}, {UnitType: "GoPackage", Unit: "y"}}
+	if got := o.Units(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got units %v, want %v", got, want)
+	}
+}
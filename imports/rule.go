@@ -0,0 +1,71 @@
+package imports
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+const importsOp = "imports"
+
+func init() {
+	plan.RegisterRuleMaker(importsOp, makeImportsRules)
+	buildstore.RegisterDataType("imports", &Output{})
+}
+
+func makeImportsRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	const op = importsOp
+	var rules []makex.Rule
+	for _, u := range c.SourceUnits {
+		toolRef := u.Ops[op]
+		if toolRef == nil {
+			choice, err := toolchain.ChooseTool(importsOp, u.Type)
+			if toolchain.IsNotFound(err) {
+				// The imports op is optional; not all toolchains support
+				// it.
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			toolRef = choice
+		}
+
+		rules = append(rules, &ImportsUnitRule{dataDir, u, toolRef, opt})
+	}
+	return rules, nil
+}
+
+// ImportsUnitRule extracts the import graph of a source unit's files.
+type ImportsUnitRule struct {
+	dataDir string
+	Unit    *unit.SourceUnit
+	Tool    *toolchain.ToolRef
+	opt     plan.Options
+}
+
+func (r *ImportsUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *ImportsUnitRule) ToolRef() *toolchain.ToolRef { return r.Tool }
+
+func (r *ImportsUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&Output{}, r.Unit))
+}
+
+func (r *ImportsUnitRule) Prereqs() []string {
+	ps := []string{filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))}
+	ps = append(ps, r.Unit.Files...)
+	return ps
+}
+
+func (r *ImportsUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src tool %s %q %q < $^ 1> $@", r.opt.ToolchainExecOpt, r.Tool.Toolchain, r.Tool.Subcmd),
+	}
+}
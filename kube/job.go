@@ -0,0 +1,456 @@
+// Package kube runs toolchains as Kubernetes Jobs.
+//
+// Each Job runs a sequence of init containers that (1) clone the repository
+// (unless it is mounted from a persistent volume), (2) write the tool's input,
+// and (3) run the tool, writing its output to a shared volume. The Job's main
+// container then uploads the output to object storage (using HTTP PUTs, in
+// chunks if it is large), from where Run retrieves it once the Job completes.
+//
+// Jobs are created and monitored using kubectl, which must be in the PATH and
+// configured to talk to the cluster.
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/network"
+)
+
+// DefaultTimeout is the default for JobSpec.Timeout.
+const DefaultTimeout = time.Hour
+
+// Default images for the helper containers in a Job.
+const (
+	DefaultGitImage    = "alpine/git"
+	DefaultShellImage  = "busybox"
+	DefaultUploadImage = "curlimages/curl"
+)
+
+// A JobSpec describes a tool run to execute as a Kubernetes Job.
+type JobSpec struct {
+	// Name is the name of the Job. It must be unique in the namespace.
+	Name string
+
+	// Namespace is the Kubernetes namespace to create the Job in. If empty,
+	// kubectl's current namespace is used.
+	Namespace string
+
+	// Image is the toolchain's Docker image. It must contain /bin/sh.
+	Image string
+
+	// Command is the toolchain command (and arguments) to run in Image.
+	Command []string
+
+	// Input is sent to Command on stdin.
+	Input []byte
+
+	// Env holds environment variables (as "KEY=value") to set for Command
+	// and for cloning Source.
+	Env []string
+
+	// Source specifies how the repository is made available to the tool (at
+	// /src, which is the working directory of Command).
+	Source Source
+
+	// ArtifactURL is the base URL of the object storage location that the
+	// tool's output is uploaded to (as ArtifactURL/Name) and then retrieved
+	// from. The Job's pods must be able to PUT to it and this process must be
+	// able to GET from it (e.g., a bucket that allows authenticated writes
+	// from the cluster).
+	ArtifactURL string
+
+	// MaxChunkSize is the maximum size, in bytes, of the objects that the
+	// tool's output is uploaded as. Larger output is uploaded in chunks
+	// (see buildstore.PutChunked), which Run reassembles. It is rounded down
+	// to a whole number of MB. If zero, buildstore.DefaultMaxChunkSize is
+	// used.
+	MaxChunkSize int64
+
+	// Timeout is how long Run waits for the Job to finish (including the
+	// time its pod spends pending, e.g., while its images are pulled)
+	// before failing. If zero, DefaultTimeout is used.
+	Timeout time.Duration
+
+	// GitImage, ShellImage, and UploadImage are the images for the helper
+	// containers that clone the repository, write the tool's input, and
+	// upload the tool's output. If empty, the defaults are used.
+	GitImage, ShellImage, UploadImage string
+}
+
+// Source specifies the repository that a Job runs on. Either VolumeClaim or
+// CloneURL must be set.
+type Source struct {
+	// VolumeClaim is the name of a PersistentVolumeClaim that contains the
+	// repository (at SubPath, if set).
+	VolumeClaim string
+	SubPath     string
+
+	// CloneURL and CommitID specify a git repository to clone in an init
+	// container.
+	CloneURL string
+	CommitID string
+}
+
+func (s *JobSpec) timeout() time.Duration {
+	if s.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return s.Timeout
+}
+
+func (s *JobSpec) artifactURL() string {
+	return strings.TrimSuffix(s.ArtifactURL, "/") + "/" + s.Name
+}
+
+// chunkUnit is the unit of chunk sizes, in which the upload container copies
+// the tool's output into chunks (see uploadScript).
+const chunkUnit = 1 << 20
+
+func (s *JobSpec) maxChunkSize() int64 {
+	n := s.MaxChunkSize
+	if n == 0 {
+		n = buildstore.DefaultMaxChunkSize
+	}
+	if n < chunkUnit {
+		return chunkUnit
+	}
+	return n / chunkUnit * chunkUnit
+}
+
+// uploadScript uploads /srclib/output to the URL $2, in chunks of at most $1
+// bytes (followed by their manifest) if it is larger than that. Each chunk
+// is copied to a file first, since object storage may reject uploads
+// without a Content-Length.
+const uploadScript = `set -e
+upload() { curl --silent --show-error --fail --upload-file "$1" "$2"; }
+size=$(wc -c < /srclib/output)
+if [ "$size" -le "$1" ]; then
+	upload /srclib/output "$2"
+	exit
+fi
+i=0
+off=0
+sizes=
+while [ "$off" -lt "$size" ]; do
+	dd if=/srclib/output of=/srclib/chunk bs=1048576 skip=$((off / 1048576)) count=$(($1 / 1048576)) 2>/dev/null
+	n=$(wc -c < /srclib/chunk)
+	upload /srclib/chunk "$2.chunk$i"
+	sizes="$sizes${sizes:+,}$n"
+	i=$((i + 1))
+	off=$((off + n))
+done
+rm /srclib/chunk
+printf '{"Size":%s,"ChunkSizes":[%s]}' "$size" "$sizes" > /srclib/chunks
+upload /srclib/chunks "$2.chunks"`
+
+// Manifest returns the JSON Kubernetes manifest of the Job.
+func (s *JobSpec) Manifest() ([]byte, error) {
+	if s.Name == "" || s.Image == "" || len(s.Command) == 0 {
+		return nil, fmt.Errorf("kube: job name, image, and command are required")
+	}
+	if s.ArtifactURL == "" {
+		return nil, fmt.Errorf("kube: no artifact URL specified for job %s", s.Name)
+	}
+	gitImage, shellImage, uploadImage := s.GitImage, s.ShellImage, s.UploadImage
+	if gitImage == "" {
+		gitImage = DefaultGitImage
+	}
+	if shellImage == "" {
+		shellImage = DefaultShellImage
+	}
+	if uploadImage == "" {
+		uploadImage = DefaultUploadImage
+	}
+
+	srcMount := object{"name": "src", "mountPath": "/src"}
+	workMount := object{"name": "work", "mountPath": "/srclib"}
+	volumes := []object{{"name": "work", "emptyDir": object{}}}
+
+	var env []object
+	for _, kv := range s.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("kube: job %s has invalid environment variable %q (must be KEY=value)", s.Name, kv)
+		}
+		env = append(env, object{"name": parts[0], "value": parts[1]})
+	}
+
+	var initContainers []object
+	switch {
+	case s.Source.VolumeClaim != "":
+		volumes = append(volumes, object{"name": "src", "persistentVolumeClaim": object{"claimName": s.Source.VolumeClaim, "readOnly": true}})
+		if s.Source.SubPath != "" {
+			srcMount["subPath"] = s.Source.SubPath
+		}
+		srcMount["readOnly"] = true
+	case s.Source.CloneURL != "":
+		volumes = append(volumes, object{"name": "src", "emptyDir": object{}})
+		clone := object{
+			"name":         "clone",
+			"image":        gitImage,
+			"command":      []string{"sh", "-c", `git clone --quiet "$0" /src && cd /src && git checkout --quiet "$1"`, s.Source.CloneURL, s.Source.CommitID},
+			"volumeMounts": []object{srcMount},
+		}
+		if env != nil {
+			// The environment may configure proxies for cloning.
+			clone["env"] = env
+		}
+		initContainers = append(initContainers, clone)
+	default:
+		return nil, fmt.Errorf("kube: job %s has no source volume claim or clone URL", s.Name)
+	}
+
+	tool := object{
+		"name":         "tool",
+		"image":        s.Image,
+		"workingDir":   "/src",
+		"command":      append([]string{"sh", "-c", `"$@" < /srclib/input > /srclib/output`, "sh"}, s.Command...),
+		"volumeMounts": []object{srcMount, workMount},
+	}
+	if env != nil {
+		tool["env"] = env
+	}
+
+	initContainers = append(initContainers,
+		object{
+			"name":         "input",
+			"image":        shellImage,
+			"command":      []string{"sh", "-c", `printf '%s' "$SRCLIB_INPUT" > /srclib/input`},
+			"env":          []object{{"name": "SRCLIB_INPUT", "value": string(s.Input)}},
+			"volumeMounts": []object{workMount},
+		},
+		tool,
+	)
+
+	job := object{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": object{
+			"name":   s.Name,
+			"labels": object{"app": "srclib"},
+		},
+		"spec": object{
+			"backoffLimit":            0,
+			"ttlSecondsAfterFinished": 3600,
+			"template": object{
+				"metadata": object{"labels": object{"app": "srclib", "job-name": s.Name}},
+				"spec": object{
+					"restartPolicy":  "Never",
+					"initContainers": initContainers,
+					"containers": []object{{
+						"name":         "upload",
+						"image":        uploadImage,
+						"command":      []string{"sh", "-c", uploadScript, "sh", strconv.FormatInt(s.maxChunkSize(), 10), s.artifactURL()},
+						"volumeMounts": []object{workMount},
+					}},
+					"volumes": volumes,
+				},
+			},
+		},
+	}
+	if s.Namespace != "" {
+		job["metadata"].(object)["namespace"] = s.Namespace
+	}
+	return json.MarshalIndent(job, "", "  ")
+}
+
+type object map[string]interface{}
+
+// PollInterval is how often Run checks whether a Job has finished.
+var PollInterval = 2 * time.Second
+
+// Run creates the Job, waits for it to finish, and writes the tool's output to
+// stdout. If the Job fails, its logs are written to stderr. If it doesn't
+// finish within the spec's Timeout, Run fails with the status of its pods
+// (e.g., that an image can't be pulled). The Job is deleted before Run
+// returns.
+func Run(s *JobSpec, stdout, stderr io.Writer) error {
+	manifest, err := s.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := s.kubectl(bytes.NewReader(manifest), nil, "create", "-f", "-"); err != nil {
+		return err
+	}
+	defer s.kubectl(nil, nil, "delete", "job", s.Name, "--ignore-not-found")
+
+	deadline := time.Now().Add(s.timeout())
+	for {
+		if time.Now().After(deadline) {
+			var pods bytes.Buffer
+			if err := s.kubectl(nil, &pods, "get", "pods", "--selector", "job-name="+s.Name, "-o", "json"); err != nil {
+				return fmt.Errorf("kube: job %s didn't finish within %s (and getting the status of its pods failed: %s)", s.Name, s.timeout(), err)
+			}
+			return fmt.Errorf("kube: job %s didn't finish within %s: %s", s.Name, s.timeout(), describePods(pods.Bytes()))
+		}
+		var status bytes.Buffer
+		if err := s.kubectl(nil, &status, "get", "job", s.Name, "-o", "jsonpath={.status.succeeded},{.status.failed}"); err != nil {
+			return err
+		}
+		succeeded, failed := parseJobStatus(status.String())
+		if succeeded > 0 {
+			break
+		}
+		if failed > 0 {
+			s.kubectl(nil, stderr, "logs", "job/"+s.Name, "--all-containers")
+			return fmt.Errorf("kube: job %s failed", s.Name)
+		}
+		time.Sleep(PollInterval)
+	}
+
+	return s.retrieveOutput(stdout)
+}
+
+// retrieveOutput writes the tool's output, which the Job uploaded (possibly
+// in chunks), to w.
+func (s *JobSpec) retrieveOutput(w io.Writer) error {
+	url := s.artifactURL()
+	err := s.get(url, w)
+	if err != errNotFound {
+		return err
+	}
+
+	// The output was too large to upload as one object.
+	var buf bytes.Buffer
+	if err := s.get(buildstore.ManifestPath(url), &buf); err == errNotFound {
+		return fmt.Errorf("kube: retrieving output of job %s: no output or chunk manifest found at %s", s.Name, url)
+	} else if err != nil {
+		return err
+	}
+	var m buildstore.ChunkManifest
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		return fmt.Errorf("kube: chunk manifest of job %s: %s", s.Name, err)
+	}
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("kube: chunk manifest of job %s: %s", s.Name, err)
+	}
+	for i, size := range m.ChunkSizes {
+		cw := &countingWriter{w: w}
+		if err := s.get(buildstore.ChunkPath(url, i), cw); err != nil {
+			return err
+		}
+		if cw.n != size {
+			return fmt.Errorf("kube: chunk %d of the output of job %s has %d bytes (want %d)", i, s.Name, cw.n, size)
+		}
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+// get writes the object at url to w. It returns errNotFound if there is no
+// such object.
+func (s *JobSpec) get(url string, w io.Writer) error {
+	resp, err := network.Client().Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("kube: retrieving output of job %s from %s: %s: %s", s.Name, url, resp.Status, bytes.TrimSpace(body))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// parseJobStatus parses the "SUCCEEDED,FAILED" counts printed by kubectl
+// (either of which may be empty).
+func parseJobStatus(s string) (succeeded, failed int) {
+	parts := strings.SplitN(strings.TrimSpace(s), ",", 2)
+	succeeded, _ = strconv.Atoi(parts[0])
+	if len(parts) == 2 {
+		failed, _ = strconv.Atoi(parts[1])
+	}
+	return succeeded, failed
+}
+
+// describePods describes the status of the pods in a PodList (in JSON, as
+// printed by kubectl), including why their containers are waiting or were
+// terminated, e.g., "pod p is Pending (container tool is waiting:
+// ImagePullBackOff: Back-off pulling image)".
+func describePods(data []byte) string {
+	type containerStatus struct {
+		Name  string
+		State map[string]struct{ Reason, Message string }
+	}
+	var list struct {
+		Items []struct {
+			Metadata struct{ Name string }
+			Status   struct {
+				Phase, Reason, Message string
+				InitContainerStatuses  []containerStatus
+				ContainerStatuses      []containerStatus
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Sprintf("invalid pod list: %s", err)
+	}
+	if len(list.Items) == 0 {
+		return "no pods were created"
+	}
+
+	var descs []string
+	for _, pod := range list.Items {
+		desc := fmt.Sprintf("pod %s is %s", pod.Metadata.Name, pod.Status.Phase)
+		var details []string
+		if reason := strings.Trim(pod.Status.Reason+": "+pod.Status.Message, ": "); reason != "" {
+			details = append(details, reason)
+		}
+		for _, c := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			for _, state := range []string{"waiting", "terminated"} {
+				st, present := c.State[state]
+				if !present || (st.Reason == "" && st.Message == "") || st.Reason == "Completed" {
+					continue
+				}
+				details = append(details, fmt.Sprintf("container %s is %s: %s", c.Name, state, strings.Trim(st.Reason+": "+st.Message, ": ")))
+			}
+		}
+		if len(details) > 0 {
+			desc += " (" + strings.Join(details, "; ") + ")"
+		}
+		descs = append(descs, desc)
+	}
+	return strings.Join(descs, ", ")
+}
+
+func (s *JobSpec) kubectl(stdin io.Reader, stdout io.Writer, args ...string) error {
+	if s.Namespace != "" {
+		args = append([]string{"--namespace", s.Namespace}, args...)
+	}
+	var errOut bytes.Buffer
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, errOut.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJobSpecManifest(t *testing.T) {
+	s := &JobSpec{
+		Name:        "srclib-test",
+		Image:       "example.com/srclib-go",
+		Command:     []string{"srclib-go", "graph"},
+		Input:       []byte(`{"Name":"foo"}`),
+		Source:      Source{CloneURL: "https://example.com/foo.git", CommitID: "abc"},
+		ArtifactURL: "https://storage.example.com/bucket/",
+		Env:         []string{"SRCLIB_PROTOCOL_VERSION=2", "SRCLIB_CAPABILITIES=docs,ref-kinds"},
+	}
+	data, err := s.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var job struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					InitContainers []struct {
+						Name, Image string
+						Env         []struct{ Name, Value string }
+					}
+					Containers []struct {
+						Name    string
+						Command []string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		t.Fatal(err)
+	}
+	pod := job.Spec.Template.Spec
+
+	var names []string
+	for _, c := range pod.InitContainers {
+		names = append(names, c.Name)
+	}
+	if want := []string{"clone", "input", "tool"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("got init containers %v, want %v", names, want)
+	}
+	if pod.InitContainers[2].Image != s.Image {
+		t.Errorf("got tool image %q, want %q", pod.InitContainers[2].Image, s.Image)
+	}
+
+	if env := pod.InitContainers[2].Env; len(env) != 2 || env[1].Name != "SRCLIB_CAPABILITIES" || env[1].Value != "docs,ref-kinds" {
+		t.Errorf("got tool env %+v, want the spec's Env", env)
+	}
+	if env := pod.InitContainers[0].Env; len(env) != 2 {
+		t.Errorf("got clone env %+v, want the spec's Env", env)
+	}
+
+	if len(pod.Containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(pod.Containers))
+	}
+	cmd := pod.Containers[0].Command
+	if got, want := cmd[len(cmd)-1], "https://storage.example.com/bucket/srclib-test"; got != want {
+		t.Errorf("got upload URL %q, want %q", got, want)
+	}
+
+	s.Env = []string{"FOO"}
+	if _, err := s.Manifest(); err == nil {
+		t.Error("got no error for job with invalid env")
+	}
+
+	s.Env = nil
+	s.Source = Source{}
+	if _, err := s.Manifest(); err == nil {
+		t.Error("got no error for job with no source")
+	}
+}
+
+func TestParseJobStatus(t *testing.T) {
+	tests := []struct {
+		in                string
+		succeeded, failed int
+	}{
+		{"", 0, 0},
+		{",", 0, 0},
+		{"1,", 1, 0},
+		{",1", 0, 1},
+		{"1,2\n", 1, 2},
+	}
+	for _, test := range tests {
+		succeeded, failed := parseJobStatus(test.in)
+		if succeeded != test.succeeded || failed != test.failed {
+			t.Errorf("%q: got %d,%d, want %d,%d", test.in, succeeded, failed, test.succeeded, test.failed)
+		}
+	}
+}
+
+func TestDescribePods(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"items":[]}`, "no pods were created"},
+		{
+			`{"items":[{"metadata":{"name":"p"},"status":{"phase":"Pending",
+				"initContainerStatuses":[
+					{"name":"clone","state":{"terminated":{"reason":"Completed"}}},
+					{"name":"tool","state":{"waiting":{"reason":"ImagePullBackOff","message":"Back-off pulling image"}}}],
+				"containerStatuses":[{"name":"upload","state":{"waiting":{"reason":"PodInitializing"}}}]}}]}`,
+			"pod p is Pending (container tool is waiting: ImagePullBackOff: Back-off pulling image; container upload is waiting: PodInitializing)",
+		},
+		{
+			`{"items":[{"metadata":{"name":"p"},"status":{"phase":"Pending","reason":"Unschedulable"}},
+				{"metadata":{"name":"q"},"status":{"phase":"Running"}}]}`,
+			"pod p is Pending (Unschedulable), pod q is Running",
+		},
+	}
+	for _, test := range tests {
+		if got := describePods([]byte(test.in)); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRetrieveOutput(t *testing.T) {
+	objects := map[string]string{
+		"/whole":          "output",
+		"/chunked.chunk0": "out",
+		"/chunked.chunk1": "put",
+		"/chunked.chunks": `{"Size":6,"ChunkSizes":[3,3]}`,
+		"/short.chunk0":   "ou",
+		"/short.chunks":   `{"Size":3,"ChunkSizes":[3]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, present := objects[r.URL.Path]
+		if !present {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		want, wantErr string
+	}{
+		"whole":   {want: "output"},
+		"chunked": {want: "output"},
+		"short":   {wantErr: "has 2 bytes (want 3)"},
+		"missing": {wantErr: "no output or chunk manifest"},
+	}
+	for name, test := range tests {
+		var buf bytes.Buffer
+		err := (&JobSpec{Name: name, ArtifactURL: srv.URL}).retrieveOutput(&buf)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if buf.String() != test.want {
+			t.Errorf("%s: got output %q, want %q", name, buf.String(), test.want)
+		}
+	}
+}
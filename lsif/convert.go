@@ -0,0 +1,382 @@
+package lsif
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// Convert converts the dump to srclib graph data, and returns the paths of
+// the dump's documents along with it. The dir argument is the path of the
+// dump's project root relative to the repository root ("." if they are the
+// same); file paths in the output are relative to the repository root.
+//
+// The readFile func returns the contents of a file given its path relative
+// to the repository root. It is used to convert the dump's positions to
+// byte offsets, so ranges in files that can't be read are omitted.
+func (d *Dump) Convert(dir string, readFile func(file string) ([]byte, error)) ([]string, *grapher.Output, error) {
+	c := &converter{
+		Dump:      d,
+		fileCache: newFileCache(readFile),
+		docs:      make(map[string]string),
+		rangeDoc:  make(map[string]string),
+		next:      make(map[string]string),
+		defResult: make(map[string]string),
+		hover:     make(map[string]string),
+		monikers:  make(map[string][]string),
+		pkgInfo:   make(map[string]string),
+		items:     make(map[string][]string),
+	}
+
+	var files []string
+	for id, v := range d.vertices {
+		if v.Label == "document" {
+			if p := d.documentPath(v.URI); p != "" {
+				c.docs[id] = path.Join(dir, p)
+				files = append(files, c.docs[id])
+			}
+		}
+	}
+	sort.Strings(files)
+
+	for _, e := range d.edges {
+		out, in := string(e.OutV), string(e.InV)
+		switch e.Label {
+		case "contains":
+			if _, isDoc := c.docs[out]; isDoc {
+				for _, rangeID := range e.InVs {
+					c.rangeDoc[string(rangeID)] = out
+				}
+			}
+		case "item":
+			if v := d.vertices[out]; v != nil && v.Label == "definitionResult" {
+				for _, rangeID := range e.InVs {
+					c.items[out] = append(c.items[out], string(rangeID))
+				}
+			}
+		case "next":
+			c.next[out] = in
+		case "textDocument/definition":
+			c.defResult[out] = in
+		case "textDocument/hover":
+			c.hover[out] = in
+		case "moniker":
+			c.monikers[out] = append(c.monikers[out], in)
+		case "packageInformation":
+			c.pkgInfo[out] = in
+		}
+	}
+
+	o := &grapher.Output{}
+	defPaths := c.convertDefs(o)
+	c.convertRefs(o, defPaths)
+	out, err := grapher.NormalizeData([]*grapher.ToolchainOutput{{Toolchain: "lsif", Output: o}}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, out, nil
+}
+
+type converter struct {
+	*Dump
+	*fileCache
+
+	docs      map[string]string   // document ID -> file
+	rangeDoc  map[string]string   // range ID -> document ID
+	next      map[string]string   // range or result set ID -> result set ID
+	defResult map[string]string   // range or result set ID -> definition result ID
+	hover     map[string]string   // range or result set ID -> hover result ID
+	monikers  map[string][]string // range or result set ID -> moniker IDs
+	pkgInfo   map[string]string   // moniker ID -> package information ID
+	items     map[string][]string // definition result ID -> range IDs
+}
+
+// A location is a range in a file, with byte offsets.
+type location struct {
+	rangeID    string
+	file       string
+	start, end int
+}
+
+// location returns the location of the range with the given ID, or false
+// if its offsets can't be determined.
+func (c *converter) location(rangeID string) (location, bool) {
+	v := c.vertices[rangeID]
+	file := c.docs[c.rangeDoc[rangeID]]
+	if v == nil || v.Start == nil || v.End == nil || file == "" {
+		return location{}, false
+	}
+	start, ok1 := c.offset(file, *v.Start)
+	end, ok2 := c.offset(file, *v.End)
+	if !ok1 || !ok2 || end < start {
+		return location{}, false
+	}
+	return location{rangeID: rangeID, file: file, start: start, end: end}, true
+}
+
+// offset returns the byte offset of the position in the file.
+func (c *converter) offset(name string, pos position) (int, bool) {
+	f := c.file(name)
+	if f == nil {
+		return 0, false
+	}
+	return f.offset(pos, c.utf8)
+}
+
+// text returns the source text at loc.
+func (c *converter) text(loc location) string {
+	return string(c.file(loc.file).src[loc.start:loc.end])
+}
+
+// results returns the definition and hover results and monikers of the
+// range or result set with the given ID, following next edges.
+func (c *converter) results(id string) (defResult, hover string, monikers []*element) {
+	seen := make(map[string]bool)
+	for ; id != "" && !seen[id]; id = c.next[id] {
+		seen[id] = true
+		if defResult == "" {
+			defResult = c.defResult[id]
+		}
+		if hover == "" {
+			hover = c.hover[id]
+		}
+		for _, m := range c.monikers[id] {
+			if v := c.vertices[m]; v != nil {
+				monikers = append(monikers, v)
+			}
+		}
+	}
+	return defResult, hover, monikers
+}
+
+// convertDefs adds the defs (with their def refs and docs) to o, and
+// returns the paths of the defs, keyed by definition result ID.
+func (c *converter) convertDefs(o *grapher.Output) map[string]graph.DefPath {
+	var defs []*defLocations
+	for id, rangeIDs := range c.items {
+		d := &defLocations{defResID: id}
+		for _, rangeID := range rangeIDs {
+			if loc, ok := c.location(rangeID); ok {
+				d.locs = append(d.locs, loc)
+			}
+		}
+		if len(d.locs) > 0 {
+			sort.Sort(locationsByPosition(d.locs))
+			defs = append(defs, d)
+		}
+	}
+	// Sort the defs so that their paths are assigned deterministically.
+	sort.Sort(defsByPosition(defs))
+
+	paths := make(map[string]graph.DefPath)
+	used := make(map[graph.DefPath]bool)
+	for _, d := range defs {
+		loc := d.locs[0]
+		v := c.vertices[loc.rangeID]
+		def := &graph.Def{File: loc.file, DefStart: loc.start, DefEnd: loc.end, Name: c.text(loc)}
+		if tag := v.Tag; tag != nil {
+			if tag.Text != "" {
+				def.Name = tag.Text
+			}
+			def.Kind = symbolKinds[tag.Kind]
+			if tag.FullRange != nil {
+				start, ok1 := c.offset(loc.file, tag.FullRange.Start)
+				end, ok2 := c.offset(loc.file, tag.FullRange.End)
+				if ok1 && ok2 && start <= loc.start && loc.end <= end {
+					def.DefStart, def.DefEnd = start, end
+				}
+			}
+		}
+		if def.Name == "" || strings.ContainsAny(def.Name, "\r\n") {
+			continue
+		}
+		def.Callable = def.Kind == graph.Func
+
+		_, hover, monikers := c.results(loc.rangeID)
+		var exportPath, srclibPath graph.DefPath
+		for _, m := range monikers {
+			switch {
+			case m.Scheme == MonikerScheme && srclibPath == "":
+				srclibPath = graph.DefPath(m.Identifier)
+			case m.Kind == "export" && exportPath == "":
+				exportPath = graph.DefPath(m.Identifier)
+			}
+			switch m.Kind {
+			case "export":
+				def.Exported = true
+			case "local":
+				def.Local = true
+			}
+		}
+		if def.Exported {
+			def.Local = false
+		}
+		for _, p := range []graph.DefPath{srclibPath, exportPath} {
+			if p != "" && !used[p] && def.Path == "" {
+				def.Path = p
+			}
+		}
+		if def.Path == "" {
+			def.Path = graph.DefPath(fmt.Sprintf("%s/%s@%d", loc.file, def.Name, loc.start))
+		}
+		used[def.Path] = true
+		paths[d.defResID] = def.Path
+		o.Defs = append(o.Defs, def)
+
+		for _, loc := range d.locs {
+			o.Refs = append(o.Refs, &graph.Ref{DefPath: def.Path, Def: true, File: loc.file, Start: loc.start, End: loc.end})
+		}
+		if v := c.vertices[hover]; v != nil {
+			if format, data := hoverDoc(v.Result); data != "" {
+				o.Docs = append(o.Docs, &graph.Doc{DefKey: graph.DefKey{Path: def.Path}, Format: format, Data: data})
+			}
+		}
+	}
+	return paths
+}
+
+// convertRefs adds the refs that aren't def refs to o. The paths of the
+// defs in the dump are keyed by definition result ID.
+func (c *converter) convertRefs(o *grapher.Output, defPaths map[string]graph.DefPath) {
+	isDef := make(map[string]bool)
+	for defResID := range defPaths {
+		for _, rangeID := range c.items[defResID] {
+			isDef[rangeID] = true
+		}
+	}
+
+	for id, v := range c.vertices {
+		if v.Label != "range" || isDef[id] {
+			continue
+		}
+		loc, ok := c.location(id)
+		if !ok {
+			continue
+		}
+		defResult, _, monikers := c.results(id)
+		if p, present := defPaths[defResult]; present {
+			o.Refs = append(o.Refs, &graph.Ref{DefPath: p, File: loc.file, Start: loc.start, End: loc.end})
+			continue
+		}
+		for _, m := range monikers {
+			if m.Kind != "import" || m.Identifier == "" {
+				continue
+			}
+			if pkg := c.vertices[c.pkgInfo[string(m.ID)]]; pkg != nil && pkg.Name != "" && pkg.Repository != nil && isRepoURL(pkg.Repository.URL) {
+				unitType := UnitType
+				if m.Scheme == MonikerScheme && pkg.Manager != "" {
+					unitType = pkg.Manager
+				}
+				o.Refs = append(o.Refs, &graph.Ref{
+					DefRepo:     repo.URI(pkg.Repository.URL),
+					DefUnitType: unitType,
+					DefUnit:     pkg.Name,
+					DefPath:     graph.DefPath(m.Identifier),
+					File:        loc.file,
+					Start:       loc.start,
+					End:         loc.end,
+				})
+				break
+			}
+		}
+	}
+}
+
+// isRepoURL reports whether u is a URL that can be converted to a
+// repository URI (see repo.MakeURI).
+func isRepoURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.Host != ""
+}
+
+// hoverDoc returns the format and data of the contents of a hover result.
+// Its contents are either a MarkupContent, or one or more MarkedStrings.
+func hoverDoc(result json.RawMessage) (format, data string) {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := json.Unmarshal(result, &hover); err != nil {
+		return "", ""
+	}
+	var markup struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if err := json.Unmarshal(hover.Contents, &markup); err == nil && markup.Kind != "" {
+		if markup.Kind == "plaintext" {
+			return "text/plain", markup.Value
+		}
+		return "text/x-markdown", markup.Value
+	}
+
+	var strs []json.RawMessage
+	if err := json.Unmarshal(hover.Contents, &strs); err != nil {
+		strs = []json.RawMessage{hover.Contents}
+	}
+	var parts []string
+	for _, s := range strs {
+		var str string
+		if err := json.Unmarshal(s, &str); err == nil {
+			parts = append(parts, str)
+		} else if err := json.Unmarshal(s, &markup); err == nil && markup.Value != "" {
+			parts = append(parts, "```"+markup.Language+"\n"+markup.Value+"\n```")
+		}
+	}
+	return "text/x-markdown", strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// symbolKinds maps LSP SymbolKinds to def kinds.
+var symbolKinds = map[int]graph.DefKind{
+	1:  graph.Module,  // File
+	2:  graph.Module,  // Module
+	3:  graph.Package, // Namespace
+	4:  graph.Package, // Package
+	5:  graph.Type,    // Class
+	6:  graph.Func,    // Method
+	7:  graph.Field,   // Property
+	8:  graph.Field,   // Field
+	9:  graph.Func,    // Constructor
+	10: graph.Type,    // Enum
+	11: graph.Type,    // Interface
+	12: graph.Func,    // Function
+	13: graph.Var,     // Variable
+	14: graph.Const,   // Constant
+	22: graph.Const,   // EnumMember
+	23: graph.Type,    // Struct
+	24: graph.Field,   // Event
+	25: graph.Func,    // Operator
+	26: graph.Type,    // TypeParameter
+}
+
+// defLocations are the locations of a def, sorted by position.
+type defLocations struct {
+	locs     []location
+	defResID string // the definition result's ID
+}
+
+type defsByPosition []*defLocations
+
+func (v defsByPosition) Len() int      { return len(v) }
+func (v defsByPosition) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v defsByPosition) Less(i, j int) bool {
+	return locationsByPosition{v[i].locs[0], v[j].locs[0]}.Less(0, 1)
+}
+
+type locationsByPosition []location
+
+func (v locationsByPosition) Len() int      { return len(v) }
+func (v locationsByPosition) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v locationsByPosition) Less(i, j int) bool {
+	if v[i].file != v[j].file {
+		return v[i].file < v[j].file
+	}
+	return v[i].start < v[j].start
+}
@@ -0,0 +1,84 @@
+package lsif
+
+import (
+	"sort"
+	"unicode/utf16"
+)
+
+// A file is the contents of a source file, with the offsets of its lines,
+// for converting between byte offsets and LSIF positions.
+type file struct {
+	src        []byte
+	lineStarts []int
+}
+
+func newFile(src []byte) *file {
+	f := &file{src: src, lineStarts: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			f.lineStarts = append(f.lineStarts, i+1)
+		}
+	}
+	return f
+}
+
+// line returns the contents of the given line, without its newline.
+func (f *file) line(n int) []byte {
+	end := len(f.src)
+	if n+1 < len(f.lineStarts) {
+		end = f.lineStarts[n+1] - 1
+	}
+	return f.src[f.lineStarts[n]:end]
+}
+
+// offset returns the byte offset of the position. Its character offset is
+// in bytes if utf8 is true, and in UTF-16 code units otherwise.
+func (f *file) offset(pos position, utf8 bool) (int, bool) {
+	if pos.Line < 0 || pos.Line >= len(f.lineStarts) || pos.Character < 0 {
+		return 0, false
+	}
+	start, line := f.lineStarts[pos.Line], f.line(pos.Line)
+	if utf8 {
+		return start + pos.Character, pos.Character <= len(line)
+	}
+	n := 0 // UTF-16 code units
+	for i, r := range string(line) {
+		if n >= pos.Character {
+			return start + i, n == pos.Character
+		}
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return start + len(line), n == pos.Character
+}
+
+// position returns the position (with its character offset in UTF-16 code
+// units) of the byte offset.
+func (f *file) position(offset int) (position, bool) {
+	if offset < 0 || offset > len(f.src) {
+		return position{}, false
+	}
+	line := sort.Search(len(f.lineStarts), func(i int) bool { return f.lineStarts[i] > offset }) - 1
+	return position{Line: line, Character: len(utf16.Encode([]rune(string(f.src[f.lineStarts[line]:offset]))))}, true
+}
+
+// A fileCache reads files on demand and caches them.
+type fileCache struct {
+	readFile func(name string) ([]byte, error)
+	files    map[string]*file // nil if the file can't be read
+}
+
+func newFileCache(readFile func(name string) ([]byte, error)) *fileCache {
+	return &fileCache{readFile: readFile, files: make(map[string]*file)}
+}
+
+// file returns the named file, or nil if it can't be read.
+func (c *fileCache) file(name string) *file {
+	f, read := c.files[name]
+	if !read {
+		if src, err := c.readFile(name); err == nil {
+			f = newFile(src)
+		}
+		c.files[name] = f
+	}
+	return f
+}
@@ -0,0 +1,227 @@
+// Package lsif converts between dumps in the Language Server Index Format
+// (LSIF) and srclib graph data, so that repositories in languages that have
+// an LSIF indexer but no srclib toolchain can still be analyzed by srclib,
+// and so that srclib's graph data can be used by tools that consume LSIF.
+//
+// When reading a dump, the defs, refs, and docs are derived from its
+// definition results, hover results, and monikers. Defs are named after
+// the text of their ranges (or their ranges' definition tags), and their
+// paths are the identifiers of their srclib (see MonikerScheme) or export
+// monikers (or are derived from their locations, for defs that have
+// neither). Refs to defs in other packages are only output if the package
+// information of their import monikers names the package's repository.
+package lsif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MonikerScheme is the scheme of the monikers that identify defs by their
+// srclib def paths (and, for import monikers, refer to defs in other source
+// units by their paths). The package information of such import monikers
+// has the def's unit type as its manager. Dumps written by Writer use these
+// monikers, so that defs keep their paths when the dumps are read back.
+const MonikerScheme = "srclib"
+
+// UnitType is the type of the source units whose graph data is converted
+// from LSIF dumps.
+const UnitType = "LSIF"
+
+// A Dump is a parsed LSIF dump.
+type Dump struct {
+	// ProjectRoot is the URI of the directory that the dump's documents'
+	// URIs are relative to.
+	ProjectRoot string
+
+	// Package is the name of the package that the dump's exported symbols
+	// are in (from the package information of the export monikers), or ""
+	// if there is none or more than one.
+	Package string
+
+	utf8     bool // whether character offsets are in bytes (not UTF-16 code units)
+	vertices map[string]*element
+	edges    []*element
+}
+
+// An element is a vertex or edge in an LSIF dump. Only the fields that are
+// used in the conversion are decoded.
+type element struct {
+	ID    lsifID `json:"id"`
+	Type  string `json:"type"` // "vertex" or "edge"
+	Label string `json:"label"`
+
+	// Vertex fields.
+	URI              string          `json:"uri"`              // document
+	ProjectRoot      string          `json:"projectRoot"`      // metaData
+	PositionEncoding string          `json:"positionEncoding"` // metaData
+	Start            *position       `json:"start"`            // range
+	End              *position       `json:"end"`              // range
+	Tag              *rangeTag       `json:"tag"`              // range
+	Result           json.RawMessage `json:"result"`           // hoverResult
+	Scheme           string          `json:"scheme"`           // moniker
+	Identifier       string          `json:"identifier"`       // moniker
+	Kind             string          `json:"kind"`             // moniker
+	Name             string          `json:"name"`             // packageInformation
+	Manager          string          `json:"manager"`          // packageInformation
+	Repository       *struct {
+		URL string `json:"url"`
+	} `json:"repository"` // packageInformation
+
+	// Edge fields.
+	OutV lsifID   `json:"outV"`
+	InV  lsifID   `json:"inV"`
+	InVs []lsifID `json:"inVs"`
+}
+
+// An lsifID is the ID of an element, which is either a number or a string.
+type lsifID string
+
+func (id *lsifID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = lsifID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid LSIF element ID %s", data)
+	}
+	*id = lsifID(n)
+	return nil
+}
+
+// A position is a zero-based line and character offset. Character offsets
+// are in UTF-16 code units unless the dump's position encoding is
+// "utf-8".
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// A rangeTag describes the symbol at a range.
+type rangeTag struct {
+	Type      string    `json:"type"` // "definition", "declaration", "reference", or "unknown"
+	Text      string    `json:"text"`
+	Kind      int       `json:"kind"` // LSP SymbolKind
+	FullRange *lspRange `json:"fullRange"`
+}
+
+// Read reads an LSIF dump, in either the JSON lines format (one element per
+// line) or the older JSON array format.
+func Read(r io.Reader) (*Dump, error) {
+	d := &Dump{vertices: make(map[string]*element)}
+	add := func(e *element) error {
+		if e == nil {
+			return fmt.Errorf("LSIF dump contains a null element")
+		}
+		switch e.Type {
+		case "vertex":
+			d.vertices[string(e.ID)] = e
+			if e.Label == "metaData" {
+				d.ProjectRoot = strings.TrimSuffix(e.ProjectRoot, "/")
+				d.utf8 = e.PositionEncoding == "utf-8"
+			}
+		case "edge":
+			d.edges = append(d.edges, e)
+		default:
+			return fmt.Errorf("LSIF element %s has invalid type %q", e.ID, e.Type)
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading LSIF dump: %s", err)
+		}
+		var elems []*element
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &elems); err != nil {
+				return nil, fmt.Errorf("reading LSIF dump: %s", err)
+			}
+		} else {
+			var e element
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return nil, fmt.Errorf("reading LSIF dump: %s", err)
+			}
+			elems = []*element{&e}
+		}
+		for _, e := range elems {
+			if err := add(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(d.vertices) == 0 {
+		return nil, fmt.Errorf("empty LSIF dump")
+	}
+
+	// Find the package of the export monikers.
+	pkgs := make(map[string]bool)
+	for _, e := range d.edges {
+		if e.Label == "packageInformation" {
+			m, p := d.vertices[string(e.OutV)], d.vertices[string(e.InV)]
+			if m != nil && p != nil && m.Kind == "export" && p.Name != "" {
+				pkgs[p.Name] = true
+			}
+		}
+	}
+	if len(pkgs) == 1 {
+		for name := range pkgs {
+			d.Package = name
+		}
+	}
+	return d, nil
+}
+
+// ProjectDir returns the path of the dump's project root relative to the
+// directory with the given URI (usually that of the repository root), or
+// "." if the project root isn't in the directory (e.g., because the dump
+// was produced on another machine).
+func (d *Dump) ProjectDir(rootURI string) string {
+	root, err1 := url.Parse(rootURI)
+	project, err2 := url.Parse(d.ProjectRoot)
+	if err1 != nil || err2 != nil || root.Scheme != project.Scheme || root.Host != project.Host {
+		return "."
+	}
+	rootPath, projectPath := strings.TrimSuffix(root.Path, "/"), strings.TrimSuffix(project.Path, "/")
+	if projectPath == rootPath {
+		return "."
+	}
+	if !strings.HasPrefix(projectPath, rootPath+"/") {
+		return "."
+	}
+	return strings.TrimPrefix(projectPath, rootPath+"/")
+}
+
+// documentPath returns the path of the document with the given URI relative
+// to the project root, or "" if it is not in the project root.
+func (d *Dump) documentPath(uri string) string {
+	if !strings.HasPrefix(uri, d.ProjectRoot+"/") {
+		return ""
+	}
+	p, err := url.PathUnescape(strings.TrimPrefix(uri, d.ProjectRoot+"/"))
+	if err != nil || p == "" {
+		return ""
+	}
+	p = path.Clean(p)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return p
+}
@@ -0,0 +1,257 @@
+package lsif
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+const testSrc = "var ü = f()\nfunc f() { fmt.Println() }\n"
+
+func pos(line, char int) map[string]int { return map[string]int{"line": line, "character": char} }
+
+// testDump is an LSIF dump of a.go (whose contents are testSrc), which
+// defines an exported func f and a local var ü and refers to fmt.Println.
+var testDump = []map[string]interface{}{
+	{"id": 1, "type": "vertex", "label": "metaData", "version": "0.4.0", "projectRoot": "file:///p"},
+	{"id": 2, "type": "vertex", "label": "project", "kind": "go"},
+	{"id": 3, "type": "vertex", "label": "document", "uri": "file:///p/a.go"},
+	{"id": 4, "type": "vertex", "label": "range", "start": pos(0, 8), "end": pos(0, 9)},
+	{"id": 5, "type": "vertex", "label": "range", "start": pos(1, 5), "end": pos(1, 6), "tag": map[string]interface{}{"type": "definition", "text": "f", "kind": 12, "fullRange": map[string]interface{}{"start": pos(1, 0), "end": pos(1, 26)}}},
+	{"id": 6, "type": "vertex", "label": "resultSet"},
+	{"id": 7, "type": "vertex", "label": "definitionResult"},
+	{"id": 8, "type": "vertex", "label": "hoverResult", "result": map[string]interface{}{"contents": []interface{}{map[string]string{"language": "go", "value": "func f()"}, "f does nothing."}}},
+	{"id": 9, "type": "vertex", "label": "moniker", "kind": "export", "scheme": "gomod", "identifier": "example.com/p:f"},
+	{"id": 10, "type": "vertex", "label": "packageInformation", "name": "example.com/p", "manager": "gomod"},
+	{"id": 11, "type": "vertex", "label": "range", "start": pos(0, 4), "end": pos(0, 5)},
+	{"id": 12, "type": "vertex", "label": "range", "start": pos(1, 15), "end": pos(1, 22)},
+	{"id": 13, "type": "vertex", "label": "resultSet"},
+	{"id": 14, "type": "vertex", "label": "moniker", "kind": "import", "scheme": "gomod", "identifier": "fmt:Println"},
+	{"id": 15, "type": "vertex", "label": "packageInformation", "name": "fmt", "manager": "gomod", "repository": map[string]string{"type": "git", "url": "https://github.com/golang/go"}},
+	{"id": 20, "type": "vertex", "label": "resultSet"},
+	{"id": 21, "type": "vertex", "label": "definitionResult"},
+	{"id": 22, "type": "vertex", "label": "moniker", "kind": "local", "scheme": "gomod", "identifier": "ü"},
+	{"id": 30, "type": "vertex", "label": "document", "uri": "file:///elsewhere/x.go"},
+
+	{"id": 40, "type": "edge", "label": "contains", "outV": 2, "inVs": []int{3, 30}},
+	{"id": 41, "type": "edge", "label": "contains", "outV": 3, "inVs": []int{4, 5, 11, 12}},
+	{"id": 42, "type": "edge", "label": "next", "outV": 4, "inV": 6},
+	{"id": 43, "type": "edge", "label": "next", "outV": 5, "inV": 6},
+	{"id": 44, "type": "edge", "label": "textDocument/definition", "outV": 6, "inV": 7},
+	{"id": 45, "type": "edge", "label": "item", "outV": 7, "inVs": []int{5}, "document": 3},
+	{"id": 46, "type": "edge", "label": "textDocument/hover", "outV": 6, "inV": 8},
+	{"id": 47, "type": "edge", "label": "moniker", "outV": 6, "inV": 9},
+	{"id": 48, "type": "edge", "label": "packageInformation", "outV": 9, "inV": 10},
+	{"id": 49, "type": "edge", "label": "next", "outV": 11, "inV": 20},
+	{"id": 50, "type": "edge", "label": "textDocument/definition", "outV": 20, "inV": 21},
+	{"id": 51, "type": "edge", "label": "item", "outV": 21, "inVs": []int{11}, "document": 3},
+	{"id": 52, "type": "edge", "label": "moniker", "outV": 20, "inV": 22},
+	{"id": 53, "type": "edge", "label": "next", "outV": 12, "inV": 13},
+	{"id": 54, "type": "edge", "label": "moniker", "outV": 13, "inV": 14},
+	{"id": 55, "type": "edge", "label": "packageInformation", "outV": 14, "inV": 15},
+}
+
+func readFile(file string) ([]byte, error) {
+	if file == "src/a.go" {
+		return []byte(testSrc), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func TestConvert(t *testing.T) {
+	// Write the dump in the JSON lines format.
+	var buf bytes.Buffer
+	for _, e := range testDump {
+		if err := json.NewEncoder(&buf).Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dump, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.ProjectRoot != "file:///p" || dump.Package != "example.com/p" {
+		t.Errorf("got project root %q and package %q", dump.ProjectRoot, dump.Package)
+	}
+
+	files, out, err := dump.Convert("src", readFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != "src/a.go" {
+		t.Errorf("got files %v, want [src/a.go]", files)
+	}
+
+	fStart := strings.Index(testSrc, "func")
+	wantDefs := []*graph.Def{
+		{DefKey: graph.DefKey{Path: "example.com/p:f"}, Name: "f", Kind: graph.Func, Callable: true, File: "src/a.go", DefStart: fStart, DefEnd: fStart + 26, Exported: true},
+		{DefKey: graph.DefKey{Path: "src/a.go/ü@4"}, Name: "ü", File: "src/a.go", DefStart: 4, DefEnd: 6, Local: true},
+	}
+	if len(out.Defs) != len(wantDefs) {
+		t.Fatalf("got %d defs, want %d", len(out.Defs), len(wantDefs))
+	}
+	for i, def := range out.Defs {
+		if !reflect.DeepEqual(def, wantDefs[i]) {
+			t.Errorf("got def %+v, want %+v", def, wantDefs[i])
+		}
+	}
+
+	// The ref to f is after the ü, which is 1 UTF-16 code unit but 2 bytes
+	// long.
+	wantRefs := map[string]graph.Ref{
+		"example.com/p:f@9":  {DefPath: "example.com/p:f", File: "src/a.go", Start: 9, End: 10},
+		"example.com/p:f@18": {DefPath: "example.com/p:f", Def: true, Kind: graph.Declaration, File: "src/a.go", Start: 18, End: 19},
+		"src/a.go/ü@4@4":     {DefPath: "src/a.go/ü@4", Def: true, Kind: graph.Declaration, File: "src/a.go", Start: 4, End: 6},
+		"fmt:Println@28":     {DefRepo: "github.com/golang/go", DefUnitType: UnitType, DefUnit: "fmt", DefPath: "fmt:Println", File: "src/a.go", Start: 28, End: 35},
+	}
+	if len(out.Refs) != len(wantRefs) {
+		t.Errorf("got %d refs, want %d", len(out.Refs), len(wantRefs))
+	}
+	for _, ref := range out.Refs {
+		if want, present := wantRefs[fmt.Sprintf("%s@%d", ref.DefPath, ref.Start)]; !present || !reflect.DeepEqual(*ref, want) {
+			t.Errorf("got unexpected ref %+v", ref)
+		}
+	}
+
+	if len(out.Docs) != 1 || out.Docs[0].Path != "example.com/p:f" || out.Docs[0].Format != "text/x-markdown" || out.Docs[0].Data != "```go\nfunc f()\n```\n\nf does nothing." {
+		t.Errorf("got docs %+v", out.Docs)
+	}
+}
+
+func TestRead_array(t *testing.T) {
+	// Older dumps are JSON arrays, and IDs may be strings.
+	elems := make([]map[string]interface{}, len(testDump))
+	for i, e := range testDump {
+		elems[i] = make(map[string]interface{})
+		for k, v := range e {
+			if k == "id" || k == "outV" || k == "inV" {
+				v = fmt.Sprint(v)
+			}
+			elems[i][k] = v
+		}
+	}
+	data, err := json.Marshal(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, out, err := dump.Convert(".", func(file string) ([]byte, error) { return readFile("src/" + file) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Defs) != 2 || len(out.Refs) != 4 {
+		t.Errorf("got %d defs and %d refs, want 2 and 4", len(out.Defs), len(out.Refs))
+	}
+}
+
+func TestRead_errors(t *testing.T) {
+	for _, dump := range []string{``, `{"id": 1, "type": "x"}`, `{"id": true, "type": "vertex"}`, `{`, `[null]`} {
+		if _, err := Read(strings.NewReader(dump)); err == nil {
+			t.Errorf("%q: got no error", dump)
+		}
+	}
+}
+
+// FuzzRead checks that reading and converting an LSIF dump doesn't panic,
+// however malformed the dump is.
+func FuzzRead(f *testing.F) {
+	var lines bytes.Buffer
+	for _, e := range testDump {
+		data, err := json.Marshal(e)
+		if err != nil {
+			f.Fatal(err)
+		}
+		lines.Write(append(data, '\n'))
+	}
+	f.Add(lines.Bytes())
+	array, err := json.Marshal(testDump)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(array)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dump, err := Read(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		dump.ProjectDir("file:///p")
+		dump.Convert(".", func(file string) ([]byte, error) { return readFile("src/" + file) })
+	})
+}
+
+func TestWriter(t *testing.T) {
+	fStart := strings.Index(testSrc, "func")
+	defs := []*graph.Def{
+		{DefKey: graph.DefKey{Path: "p/f"}, Name: "f", Kind: graph.Func, File: "a.go", DefStart: fStart, DefEnd: fStart + 26, Exported: true},
+		{DefKey: graph.DefKey{Path: "p/ü"}, Name: "ü", Kind: graph.Var, File: "a.go", DefStart: 4, DefEnd: 6, Local: true},
+	}
+	refs := []*graph.Ref{
+		{DefPath: "p/f", Def: true, File: "a.go", Start: 18, End: 19},
+		{DefPath: "p/f", File: "a.go", Start: 9, End: 10},
+		{DefRepo: "github.com/golang/go", DefUnitType: "GoPackage", DefUnit: "fmt", DefPath: "Println", File: "a.go", Start: 28, End: 35},
+		{DefPath: "p/f", File: "missing.go", Start: 0, End: 1},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "file:///p/", func(file string) ([]byte, error) { return readFile("src/" + file) })
+	for _, def := range defs {
+		if err := w.WriteDef(def); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ref := range refs {
+		if err := w.WriteRef(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteDoc(&graph.Doc{DefKey: graph.DefKey{Path: "p/f"}, Format: "text/plain", Data: "f does nothing."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading the dump back should yield the same defs and refs.
+	dump, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.ProjectDir("file:///") != "p" {
+		t.Errorf("got project dir %q, want p", dump.ProjectDir("file:///"))
+	}
+	_, out, err := dump.Convert(".", func(file string) ([]byte, error) { return readFile("src/" + file) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range defs {
+		def.Callable = def.Kind == graph.Func
+	}
+	if !reflect.DeepEqual(out.Defs, defs) {
+		t.Errorf("got defs %+v, want %+v", out.Defs, defs)
+	}
+	wantRefs := map[string]graph.Ref{
+		"p/f@18":     {DefPath: "p/f", Def: true, Kind: graph.Declaration, File: "a.go", Start: 18, End: 19},
+		"p/f@9":      {DefPath: "p/f", File: "a.go", Start: 9, End: 10},
+		"p/ü@4":      {DefPath: "p/ü", Def: true, Kind: graph.Declaration, File: "a.go", Start: 4, End: 6},
+		"Println@28": {DefRepo: "github.com/golang/go", DefUnitType: "GoPackage", DefUnit: "fmt", DefPath: "Println", File: "a.go", Start: 28, End: 35},
+	}
+	if len(out.Refs) != len(wantRefs) {
+		t.Errorf("got %d refs, want %d", len(out.Refs), len(wantRefs))
+	}
+	for _, ref := range out.Refs {
+		if want, present := wantRefs[fmt.Sprintf("%s@%d", ref.DefPath, ref.Start)]; !present || !reflect.DeepEqual(*ref, want) {
+			t.Errorf("got unexpected ref %+v", ref)
+		}
+	}
+	if len(out.Docs) != 1 || out.Docs[0].Path != "p/f" || out.Docs[0].Format != "text/plain" || out.Docs[0].Data != "f does nothing." {
+		t.Errorf("got docs %+v", out.Docs)
+	}
+}
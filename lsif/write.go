@@ -0,0 +1,330 @@
+package lsif
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A Writer writes srclib graph data as an LSIF dump in the JSON lines
+// format. Defs must be written before the refs and docs that refer to them
+// (as they are ordered in graph data); refs to defs that haven't been
+// written are treated as refs to defs in other packages.
+//
+// Each def has a result set with a definition result, a reference result,
+// and a srclib moniker (see MonikerScheme). Its definition ranges are the
+// locations of its def refs (or its extent, if it has none), tagged with
+// its name, kind, and extent. Each ref is a range that is an item of its
+// def's reference result. The first doc of each def is its hover result.
+//
+// The source files are read to convert byte offsets to LSIF positions, so
+// refs and defs in files that can't be read are omitted.
+type Writer struct {
+	enc         *json.Encoder
+	projectRoot string
+	files       *fileCache
+	err         error
+
+	lastID    int
+	project   int
+	docs      map[string]int // file -> document ID
+	docRanges map[int][]int  // document ID -> range IDs
+	defs      map[graph.DefKey]*writtenDef
+	external  map[graph.DefKey]int // def key -> result set ID, for defs not in the dump
+	pkgs      map[[3]string]int    // (repo, unit type, unit) -> package information ID
+}
+
+type writtenDef struct {
+	def       *graph.Def
+	resultSet int
+	defResult int
+	refResult int
+	hasRange  bool
+	hasHover  bool
+}
+
+// outElement is an element written to a dump.
+type outElement struct {
+	ID    int    `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+
+	Version          string      `json:"version,omitempty"`
+	ProjectRoot      string      `json:"projectRoot,omitempty"`
+	PositionEncoding string      `json:"positionEncoding,omitempty"`
+	ToolInfo         interface{} `json:"toolInfo,omitempty"`
+	URI              string      `json:"uri,omitempty"`
+	Start            *position   `json:"start,omitempty"`
+	End              *position   `json:"end,omitempty"`
+	Tag              *rangeTag   `json:"tag,omitempty"`
+	Result           interface{} `json:"result,omitempty"`
+	Scheme           string      `json:"scheme,omitempty"`
+	Identifier       string      `json:"identifier,omitempty"`
+	Kind             string      `json:"kind,omitempty"`
+	Name             string      `json:"name,omitempty"`
+	Manager          string      `json:"manager,omitempty"`
+	Repository       interface{} `json:"repository,omitempty"`
+
+	OutV     int    `json:"outV,omitempty"`
+	InV      int    `json:"inV,omitempty"`
+	InVs     []int  `json:"inVs,omitempty"`
+	Document int    `json:"document,omitempty"`
+	Property string `json:"property,omitempty"`
+}
+
+// NewWriter returns a writer that writes a dump to w. The projectRoot is
+// the URI of the directory that file paths in the graph data are relative
+// to (usually the repository root), and readFile returns the contents of a
+// file given such a path.
+func NewWriter(w io.Writer, projectRoot string, readFile func(file string) ([]byte, error)) *Writer {
+	lw := &Writer{
+		enc:         json.NewEncoder(w),
+		projectRoot: strings.TrimSuffix(projectRoot, "/"),
+		files:       newFileCache(readFile),
+		docs:        make(map[string]int),
+		docRanges:   make(map[int][]int),
+		defs:        make(map[graph.DefKey]*writtenDef),
+		external:    make(map[graph.DefKey]int),
+		pkgs:        make(map[[3]string]int),
+	}
+	lw.vertex(&outElement{
+		Label:            "metaData",
+		Version:          "0.4.3",
+		ProjectRoot:      lw.projectRoot,
+		PositionEncoding: "utf-16",
+		ToolInfo:         map[string]string{"name": "srclib"},
+	})
+	lw.project = lw.vertex(&outElement{Label: "project"})
+	return lw
+}
+
+// emit writes the element, assigning it an ID, and returns the ID.
+func (w *Writer) emit(typ string, e *outElement) int {
+	w.lastID++
+	e.ID, e.Type = w.lastID, typ
+	if w.err == nil {
+		w.err = w.enc.Encode(e)
+	}
+	return e.ID
+}
+
+func (w *Writer) vertex(e *outElement) int { return w.emit("vertex", e) }
+
+func (w *Writer) edge(label string, outV, inV int) {
+	w.emit("edge", &outElement{Label: label, OutV: outV, InV: inV})
+}
+
+// symbolKindsByDefKind maps def kinds to LSP SymbolKinds (the inverse of
+// symbolKinds).
+var symbolKindsByDefKind = map[graph.DefKind]int{
+	graph.Module:  2,
+	graph.Package: 4,
+	graph.Type:    5,
+	graph.Func:    12,
+	graph.Field:   8,
+	graph.Var:     13,
+	graph.Const:   14,
+}
+
+// WriteDef writes a def.
+func (w *Writer) WriteDef(def *graph.Def) error {
+	if _, present := w.defs[def.DefKey]; present {
+		return w.err
+	}
+	d := &writtenDef{def: def}
+	d.resultSet = w.vertex(&outElement{Label: "resultSet"})
+	d.defResult = w.vertex(&outElement{Label: "definitionResult"})
+	w.edge("textDocument/definition", d.resultSet, d.defResult)
+	d.refResult = w.vertex(&outElement{Label: "referenceResult"})
+	w.edge("textDocument/references", d.resultSet, d.refResult)
+
+	var kind string
+	if def.Exported {
+		kind = "export"
+	} else if def.Local {
+		kind = "local"
+	}
+	moniker := w.vertex(&outElement{Label: "moniker", Scheme: MonikerScheme, Identifier: string(def.Path), Kind: kind})
+	w.edge("moniker", d.resultSet, moniker)
+	w.defs[def.DefKey] = d
+	return w.err
+}
+
+// WriteRef writes a ref.
+func (w *Writer) WriteRef(ref *graph.Ref) error {
+	doc, start, end, ok := w.location(ref.File, ref.Start, ref.End)
+	if !ok {
+		return w.err
+	}
+	rng := &outElement{Label: "range", Start: &start, End: &end}
+
+	d := w.defs[ref.DefKey()]
+	if d == nil {
+		rangeID := w.vertex(rng)
+		w.docRanges[doc] = append(w.docRanges[doc], rangeID)
+		w.edge("next", rangeID, w.externalResultSet(ref))
+		return w.err
+	}
+
+	property := "references"
+	if ref.Def {
+		property = "definitions"
+		if !d.hasRange {
+			rng.Tag = w.definitionTag(d.def)
+		}
+	}
+	rangeID := w.vertex(rng)
+	w.docRanges[doc] = append(w.docRanges[doc], rangeID)
+	w.edge("next", rangeID, d.resultSet)
+	if ref.Def {
+		d.hasRange = true
+		w.emit("edge", &outElement{Label: "item", OutV: d.defResult, InVs: []int{rangeID}, Document: doc})
+	}
+	w.emit("edge", &outElement{Label: "item", OutV: d.refResult, InVs: []int{rangeID}, Document: doc, Property: property})
+	return w.err
+}
+
+// externalResultSet returns the ID of the result set for the def that ref
+// refers to, which is not in the dump. The result set has a srclib import
+// moniker, whose package information names the def's repository and unit.
+func (w *Writer) externalResultSet(ref *graph.Ref) int {
+	key := ref.DefKey()
+	if id, present := w.external[key]; present {
+		return id
+	}
+	id := w.vertex(&outElement{Label: "resultSet"})
+	moniker := w.vertex(&outElement{Label: "moniker", Scheme: MonikerScheme, Identifier: string(ref.DefPath), Kind: "import"})
+	w.edge("moniker", id, moniker)
+	if ref.DefRepo != "" {
+		pkgKey := [3]string{string(ref.DefRepo), ref.DefUnitType, ref.DefUnit}
+		pkg, present := w.pkgs[pkgKey]
+		if !present {
+			pkg = w.vertex(&outElement{
+				Label:      "packageInformation",
+				Name:       ref.DefUnit,
+				Manager:    ref.DefUnitType,
+				Repository: map[string]string{"type": "git", "url": ref.DefRepo.CloneURL()},
+			})
+			w.pkgs[pkgKey] = pkg
+		}
+		w.edge("packageInformation", moniker, pkg)
+	}
+	w.external[key] = id
+	return id
+}
+
+// definitionTag returns the tag of the def's definition range.
+func (w *Writer) definitionTag(def *graph.Def) *rangeTag {
+	tag := &rangeTag{Type: "definition", Text: def.Name, Kind: symbolKindsByDefKind[def.Kind]}
+	if f := w.files.file(def.File); f != nil {
+		start, ok1 := f.position(def.DefStart)
+		end, ok2 := f.position(def.DefEnd)
+		if ok1 && ok2 {
+			tag.FullRange = &lspRange{Start: start, End: end}
+		}
+	}
+	return tag
+}
+
+// WriteDoc writes a doc. Only the first doc of each def is written, as its
+// hover result.
+func (w *Writer) WriteDoc(doc *graph.Doc) error {
+	d := w.defs[doc.DefKey]
+	if d == nil || d.hasHover {
+		return w.err
+	}
+	kind := "markdown"
+	if doc.Format == "text/plain" {
+		kind = "plaintext"
+	}
+	hover := w.vertex(&outElement{Label: "hoverResult", Result: map[string]interface{}{
+		"contents": map[string]string{"kind": kind, "value": doc.Data},
+	}})
+	w.edge("textDocument/hover", d.resultSet, hover)
+	d.hasHover = true
+	return w.err
+}
+
+// location returns the document ID and positions of the byte range in the
+// file, writing the document if it hasn't been written.
+func (w *Writer) location(file string, start, end int) (doc int, startPos, endPos position, ok bool) {
+	f := w.files.file(file)
+	if f == nil {
+		return 0, position{}, position{}, false
+	}
+	startPos, ok1 := f.position(start)
+	endPos, ok2 := f.position(end)
+	if !ok1 || !ok2 || end < start {
+		return 0, position{}, position{}, false
+	}
+	doc, present := w.docs[file]
+	if !present {
+		doc = w.vertex(&outElement{Label: "document", URI: w.projectRoot + "/" + (&url.URL{Path: file}).EscapedPath()})
+		w.docs[file] = doc
+	}
+	return doc, startPos, endPos, true
+}
+
+// Close writes the definition ranges of defs that had no def refs, and the
+// edges from the project to its documents and from the documents to their
+// ranges. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	var keys []graph.DefKey
+	for key, d := range w.defs {
+		if !d.hasRange {
+			keys = append(keys, key)
+		}
+	}
+	sort.Sort(defKeysByPosition{keys, w.defs})
+	for _, key := range keys {
+		d := w.defs[key]
+		doc, start, end, ok := w.location(d.def.File, d.def.DefStart, d.def.DefEnd)
+		if !ok {
+			continue
+		}
+		rangeID := w.vertex(&outElement{Label: "range", Start: &start, End: &end, Tag: w.definitionTag(d.def)})
+		w.docRanges[doc] = append(w.docRanges[doc], rangeID)
+		w.edge("next", rangeID, d.resultSet)
+		w.emit("edge", &outElement{Label: "item", OutV: d.defResult, InVs: []int{rangeID}, Document: doc})
+		w.emit("edge", &outElement{Label: "item", OutV: d.refResult, InVs: []int{rangeID}, Document: doc, Property: "definitions"})
+	}
+
+	var docs []int
+	for _, doc := range w.docs {
+		docs = append(docs, doc)
+	}
+	sort.Ints(docs)
+	if len(docs) > 0 {
+		w.emit("edge", &outElement{Label: "contains", OutV: w.project, InVs: docs})
+	}
+	for _, doc := range docs {
+		if ranges := w.docRanges[doc]; len(ranges) > 0 {
+			w.emit("edge", &outElement{Label: "contains", OutV: doc, InVs: ranges})
+		}
+	}
+	return w.err
+}
+
+// defKeysByPosition sorts the keys of written defs by their defs'
+// positions.
+type defKeysByPosition struct {
+	keys []graph.DefKey
+	defs map[graph.DefKey]*writtenDef
+}
+
+func (v defKeysByPosition) Len() int      { return len(v.keys) }
+func (v defKeysByPosition) Swap(i, j int) { v.keys[i], v.keys[j] = v.keys[j], v.keys[i] }
+func (v defKeysByPosition) Less(i, j int) bool {
+	a, b := v.defs[v.keys[i]].def, v.defs[v.keys[j]].def
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.DefStart != b.DefStart {
+		return a.DefStart < b.DefStart
+	}
+	return a.Path < b.Path
+}
@@ -0,0 +1,68 @@
+// Package manifest records the content hashes of the files that contribute to
+// a source unit's build data, so that consumers can tell which build data is
+// stale after files in the working tree are edited.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A Manifest lists the files that a source unit's build data was built from,
+// along with the content hash of each file at the time.
+type Manifest struct {
+	// Files maps each file's path (relative to the repository root) to the
+	// SHA-256 hash of its contents.
+	Files map[string]string
+}
+
+// Compute returns a manifest of files, whose paths are relative to dir.
+func Compute(dir string, files []string) (*Manifest, error) {
+	m := &Manifest{Files: make(map[string]string, len(files))}
+	for _, file := range files {
+		h, err := hashFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		m.Files[filepath.ToSlash(file)] = h
+	}
+	return m, nil
+}
+
+// Changed returns the files in the manifest (sorted by path) whose contents
+// in dir differ from their recorded hashes, including files that no longer
+// exist.
+func (m *Manifest) Changed(dir string) ([]string, error) {
+	var changed []string
+	for file, want := range m.Files {
+		h, err := hashFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			changed = append(changed, file)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if h != want {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
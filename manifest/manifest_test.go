@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest_Changed(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-manifest-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	writeFile := func(name, data string) {
+		if err := ioutil.WriteFile(filepath.Join(tmpdir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("a", "a")
+	writeFile("b", "b")
+	writeFile("c", "c")
+	m, err := Compute(tmpdir, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 3 {
+		t.Fatalf("got %d files in manifest, want 3", len(m.Files))
+	}
+
+	changed, err := m.Changed(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("got changed files %v, want none", changed)
+	}
+
+	writeFile("b", "b2")
+	if err := os.Remove(filepath.Join(tmpdir, "c")); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = m.Changed(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("got changed files %v, want %v", changed, want)
+	}
+}
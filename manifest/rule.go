@@ -0,0 +1,50 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	plan.RegisterRuleMaker("manifest", makeManifestRules)
+	buildstore.RegisterDataType("manifest", &Manifest{})
+}
+
+func makeManifestRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	var rules []makex.Rule
+	for _, u := range c.SourceUnits {
+		rules = append(rules, &ManifestUnitRule{dataDir, u})
+	}
+	return rules, nil
+}
+
+// ManifestUnitRule computes the manifest of a source unit's files.
+type ManifestUnitRule struct {
+	dataDir string
+	Unit    *unit.SourceUnit
+}
+
+func (r *ManifestUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *ManifestUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&Manifest{}, r.Unit))
+}
+
+func (r *ManifestUnitRule) Prereqs() []string {
+	ps := []string{filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))}
+	ps = append(ps, r.Unit.Files...)
+	return ps
+}
+
+func (r *ManifestUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src internal unit-manifest --unit-data %s 1> $@", makex.Quote(filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit)))),
+	}
+}
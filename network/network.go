@@ -0,0 +1,277 @@
+// Package network configures srclib's network access, so that srclib works
+// in networks that require a proxy or that can only reach mirrors of
+// external hosts.
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MirrorsEnv is the environment variable that lists mirrors, as
+// space-separated PREFIX=MIRROR pairs (e.g.,
+// "https://github.com/=https://mirror.example.com/github/").
+const MirrorsEnv = "SRCLIB_MIRRORS"
+
+// EnvNames are the environment variables that carry network settings to
+// subprocesses (see Settings.Env). Tools that access the network (such as
+// dependency resolvers) should honor them.
+var EnvNames = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", MirrorsEnv}
+
+// Settings configures network access. They can be set in the SRCLIBPATH
+// .srclibconfig file, in a repository's Srcfile, and in the environment
+// (see FromEnv).
+type Settings struct {
+	// HTTPProxy and HTTPSProxy are the URLs of the proxies to use for
+	// http and https URLs, respectively (e.g.,
+	// "http://proxy.example.com:3128").
+	HTTPProxy  string `json:",omitempty"`
+	HTTPSProxy string `json:",omitempty"`
+
+	// NoProxy is a comma-separated list of hosts that are accessed
+	// directly, as in the NO_PROXY environment variable. Each entry is a
+	// hostname (which also matches its subdomains), a hostname and port,
+	// an IP address or CIDR range, or "*" (all hosts).
+	NoProxy string `json:",omitempty"`
+
+	// Mirrors maps URL prefixes to the URL prefixes of mirrors that
+	// replace them (e.g., "https://github.com/" to
+	// "https://mirror.example.com/github/"). Mirrors apply to HTTP(S)
+	// requests and to the URLs of repositories that srclib clones. The
+	// longest matching prefix is used.
+	Mirrors map[string]string `json:",omitempty"`
+}
+
+// FromEnv returns the settings in the environment: the proxies in the
+// standard HTTP_PROXY, HTTPS_PROXY, and NO_PROXY variables (or their
+// lowercase equivalents) and the mirrors in MirrorsEnv.
+func FromEnv() *Settings {
+	getenv := func(name string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return os.Getenv(strings.ToLower(name))
+	}
+	s := &Settings{
+		HTTPProxy:  getenv("HTTP_PROXY"),
+		HTTPSProxy: getenv("HTTPS_PROXY"),
+		NoProxy:    getenv("NO_PROXY"),
+	}
+	for _, pair := range strings.Fields(os.Getenv(MirrorsEnv)) {
+		if i := strings.Index(pair, "="); i > 0 {
+			if s.Mirrors == nil {
+				s.Mirrors = map[string]string{}
+			}
+			s.Mirrors[pair[:i]] = pair[i+1:]
+		}
+	}
+	return s
+}
+
+// Override sets the fields of s to the nonempty fields of o, and adds o's
+// mirrors to s's (replacing mirrors of the same prefixes).
+func (s *Settings) Override(o *Settings) {
+	if o.HTTPProxy != "" {
+		s.HTTPProxy = o.HTTPProxy
+	}
+	if o.HTTPSProxy != "" {
+		s.HTTPSProxy = o.HTTPSProxy
+	}
+	if o.NoProxy != "" {
+		s.NoProxy = o.NoProxy
+	}
+	for prefix, mirror := range o.Mirrors {
+		if s.Mirrors == nil {
+			s.Mirrors = map[string]string{}
+		}
+		s.Mirrors[prefix] = mirror
+	}
+}
+
+// Validate returns an error if s is invalid.
+func (s *Settings) Validate() error {
+	for _, proxy := range []string{s.HTTPProxy, s.HTTPSProxy} {
+		if proxy == "" {
+			continue
+		}
+		if _, err := parseProxy(proxy); err != nil {
+			return err
+		}
+	}
+	for prefix, mirror := range s.Mirrors {
+		if prefix == "" || mirror == "" {
+			return fmt.Errorf("invalid mirror %q for %q (prefix and mirror must be nonempty)", mirror, prefix)
+		}
+	}
+	return nil
+}
+
+// Mirror returns rawURL with its longest prefix in Mirrors replaced by the
+// prefix's mirror. If no prefix matches, it returns rawURL.
+func (s *Settings) Mirror(rawURL string) string {
+	var prefix string
+	for p := range s.Mirrors {
+		if strings.HasPrefix(rawURL, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return rawURL
+	}
+	return s.Mirrors[prefix] + strings.TrimPrefix(rawURL, prefix)
+}
+
+// Env returns the environment variables (as "KEY=value") that carry s to
+// subprocesses. Both the uppercase and lowercase proxy variables are
+// set, since programs differ in which they read.
+func (s *Settings) Env() []string {
+	var env []string
+	for _, v := range []struct{ name, value string }{
+		{"HTTP_PROXY", s.HTTPProxy},
+		{"HTTPS_PROXY", s.HTTPSProxy},
+		{"NO_PROXY", s.NoProxy},
+	} {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value, strings.ToLower(v.name)+"="+v.value)
+		}
+	}
+	if len(s.Mirrors) > 0 {
+		pairs := make([]string, 0, len(s.Mirrors))
+		for prefix, mirror := range s.Mirrors {
+			pairs = append(pairs, prefix+"="+mirror)
+		}
+		sort.Strings(pairs)
+		env = append(env, MirrorsEnv+"="+strings.Join(pairs, " "))
+	}
+	return env
+}
+
+// ProxyURL returns the URL of the proxy to use for a request to u, or nil
+// if the request should be sent directly.
+func (s *Settings) ProxyURL(u *url.URL) (*url.URL, error) {
+	var proxy string
+	switch u.Scheme {
+	case "http":
+		proxy = s.HTTPProxy
+	case "https":
+		proxy = s.HTTPSProxy
+	}
+	if proxy == "" || !s.useProxy(u) {
+		return nil, nil
+	}
+	return parseProxy(proxy)
+}
+
+// useProxy returns whether requests to u should use a proxy, according to
+// NoProxy. Requests to localhost never do.
+func (s *Settings) useProxy(u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+	for _, entry := range strings.FieldsFunc(strings.ToLower(s.NoProxy), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if entry == "*" {
+			return false
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseProxy parses a proxy URL. A URL with no scheme (e.g.,
+// "proxy.example.com:3128") is an http URL.
+func parseProxy(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %s", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q (scheme must be http, https, or socks5)", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q (no host)", proxy)
+	}
+	return u, nil
+}
+
+// Default holds the settings that Transport and Client use. It is
+// initialized from the environment; programs may replace it with Apply.
+var Default = FromEnv()
+
+// Apply validates s, makes it the Default, and sets the environment
+// variables in s.Env, so that subprocesses use s too.
+func Apply(s *Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	for _, kv := range s.Env() {
+		parts := strings.SplitN(kv, "=", 2)
+		if err := os.Setenv(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+	Default = s
+	return nil
+}
+
+// Transport is an http.RoundTripper that sends requests to the mirrors and
+// through the proxies in Default (as it is when each request is made).
+var Transport http.RoundTripper = &transport{base: &http.Transport{
+	Proxy:               func(req *http.Request) (*url.URL, error) { return Default.ProxyURL(req.URL) },
+	DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout: 10 * time.Second,
+}}
+
+type transport struct{ base http.RoundTripper }
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if mirror := Default.Mirror(req.URL.String()); mirror != req.URL.String() {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror URL %q for %s: %s", mirror, req.URL, err)
+		}
+		// RoundTrippers mustn't modify the request.
+		req2 := new(http.Request)
+		*req2 = *req
+		req2.URL = u
+		req2.Host = ""
+		req = req2
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Client returns an HTTP client that uses Transport.
+func Client() *http.Client {
+	return &http.Client{Transport: Transport}
+}
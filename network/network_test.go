@@ -0,0 +1,183 @@
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettings_ProxyURL(t *testing.T) {
+	s := &Settings{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "proxy.example.com:3129",
+		NoProxy:    "internal.example.com, .corp.example.com,git.example.com:8443,10.0.0.0/8",
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://github.com/foo", "http://proxy.example.com:3128"},
+		{"https://github.com/foo", "http://proxy.example.com:3129"},
+		{"https://internal.example.com/foo", ""},
+		{"https://a.internal.example.com/foo", ""},
+		{"https://a.corp.example.com/foo", ""},
+		{"https://git.example.com:8443/foo", ""},
+		{"https://git.example.com/foo", "http://proxy.example.com:3129"},
+		{"http://10.1.2.3/foo", ""},
+		{"http://localhost:8080/foo", ""},
+		{"http://127.0.0.1:8080/foo", ""},
+		{"ftp://github.com/foo", ""},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy, err := s.ProxyURL(u)
+		if err != nil {
+			t.Errorf("%s: %s", test.url, err)
+			continue
+		}
+		var got string
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != test.want {
+			t.Errorf("%s: got proxy %q, want %q", test.url, got, test.want)
+		}
+	}
+
+	s.NoProxy = "*"
+	if proxy, _ := s.ProxyURL(&url.URL{Scheme: "https", Host: "github.com"}); proxy != nil {
+		t.Errorf("got proxy %s with NoProxy *, want none", proxy)
+	}
+}
+
+func TestSettings_Mirror(t *testing.T) {
+	s := &Settings{Mirrors: map[string]string{
+		"https://github.com/":     "https://mirror.example.com/github/",
+		"https://github.com/foo/": "https://foo-mirror.example.com/",
+	}}
+	tests := map[string]string{
+		"https://github.com/bar/baz.git": "https://mirror.example.com/github/bar/baz.git",
+		"https://github.com/foo/baz.git": "https://foo-mirror.example.com/baz.git",
+		"https://example.com/bar":        "https://example.com/bar",
+	}
+	for rawURL, want := range tests {
+		if got := s.Mirror(rawURL); got != want {
+			t.Errorf("%s: got %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestSettings_Override(t *testing.T) {
+	s := &Settings{HTTPProxy: "http://a", NoProxy: "a", Mirrors: map[string]string{"x": "1", "y": "2"}}
+	s.Override(&Settings{HTTPProxy: "http://b", HTTPSProxy: "http://c", Mirrors: map[string]string{"y": "3"}})
+	want := &Settings{HTTPProxy: "http://b", HTTPSProxy: "http://c", NoProxy: "a", Mirrors: map[string]string{"x": "1", "y": "3"}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+}
+
+func TestSettings_Validate(t *testing.T) {
+	tests := []struct {
+		s       Settings
+		wantErr bool
+	}{
+		{Settings{}, false},
+		{Settings{HTTPProxy: "http://proxy.example.com:3128", HTTPSProxy: "socks5://proxy.example.com"}, false},
+		{Settings{HTTPProxy: "proxy.example.com:3128"}, false},
+		{Settings{HTTPProxy: "ftp://proxy.example.com"}, true},
+		{Settings{HTTPSProxy: "http://"}, true},
+		{Settings{Mirrors: map[string]string{"https://github.com/": ""}}, true},
+	}
+	for _, test := range tests {
+		if err := test.s.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("%+v: got error %v, want error %v", test.s, err, test.wantErr)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range EnvNames {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, "")
+	}
+
+	want := &Settings{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "http://proxy.example.com:3129",
+		NoProxy:    "internal.example.com",
+		Mirrors:    map[string]string{"https://a/": "https://b/", "https://c/": "https://d/"},
+	}
+	for _, kv := range want.Env() {
+		parts := strings.SplitN(kv, "=", 2)
+		os.Setenv(parts[0], parts[1])
+	}
+	if got := FromEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Lowercase variables are read, too.
+	for _, name := range EnvNames {
+		os.Setenv(name, "")
+	}
+	os.Setenv("https_proxy", "http://lower.example.com")
+	if got := FromEnv(); got.HTTPSProxy != "http://lower.example.com" {
+		t.Errorf("got HTTPSProxy %q from https_proxy", got.HTTPSProxy)
+	}
+}
+
+func TestTransport_mirror(t *testing.T) {
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "mirror %s", r.URL.Path)
+	}))
+	defer mirror.Close()
+
+	defer func(orig *Settings) { Default = orig }(Default)
+	Default = &Settings{Mirrors: map[string]string{"https://registry.example.com/": mirror.URL + "/registry/"}}
+
+	resp, err := Client().Get("https://registry.example.com/index.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "mirror /registry/index.json"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTransport_proxy(t *testing.T) {
+	// Requests to localhost bypass the proxy, so request another host
+	// (which the proxy answers for).
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	defer proxy.Close()
+
+	defer func(orig *Settings) { Default = orig }(Default)
+	Default = &Settings{HTTPProxy: proxy.URL}
+
+	resp, err := Client().Get("http://example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "proxied http://example.com/foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+// Package pgimport imports srclib graph data (defs, refs, and docs) and
+// resolved dependencies into a PostgreSQL database, so that they can be
+// queried with SQL.
+//
+// An Importer writes a psql script that creates the tables of Schema (if
+// they don't already exist), deletes the rows previously imported for the
+// repository's commit, and then loads the new rows with COPY statements of
+// at most a given number of rows each. The whole script runs in a single
+// transaction, so a failed import leaves the database as it was.
+//
+// The script uses psql's support for COPY data inline in the script, so it
+// must be run by psql (not by other PostgreSQL clients):
+//
+//	psql --no-psqlrc --quiet --file=script.sql DBURL
+package pgimport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// DefaultBatchSize is the default maximum number of rows loaded by each
+// COPY statement.
+const DefaultBatchSize = 10000
+
+// An Importer writes a psql script that imports the graph data and resolved
+// dependencies of a repository's commit. The rows of each table are
+// buffered until there are enough of them for a COPY statement (or until
+// Close is called).
+type Importer struct {
+	w         io.Writer
+	repo      repo.URI
+	commitID  string
+	batchSize int
+
+	batches [numTables]batch
+	err     error
+}
+
+// A batch holds the rows of a table that have yet to be written.
+type batch struct {
+	buf   bytes.Buffer
+	n     int // number of rows in buf
+	total int // number of rows written or in buf
+}
+
+// NewImporter returns an importer that writes to w a script that imports
+// the data of commitID of the repository repo, with at most batchSize rows
+// per COPY statement. It writes the start of the script, which creates the
+// schema and deletes the repository commit's existing rows.
+func NewImporter(w io.Writer, repo repo.URI, commitID string, batchSize int) (*Importer, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("invalid batch size %d (must be at least 1)", batchSize)
+	}
+	im := &Importer{w: w, repo: repo, commitID: commitID, batchSize: batchSize}
+
+	var b bytes.Buffer
+	b.WriteString("\\set ON_ERROR_STOP on\n")
+	b.WriteString("BEGIN;\n")
+	b.WriteString(Schema)
+	for _, t := range tables {
+		fmt.Fprintf(&b, "DELETE FROM %s WHERE %s = %s AND %s = %s;\n", t.name, t.columns[0], quoteLiteral(string(repo)), t.columns[1], quoteLiteral(commitID))
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+// Def adds a row for a def of the source unit u.
+func (im *Importer) Def(u *unit.SourceUnit, d *graph.Def) error {
+	var data interface{}
+	if len(d.Data) > 0 {
+		data = string(d.Data)
+	}
+	return im.add(defsTable,
+		string(im.repo), im.commitID, or(d.UnitType, u.Type), or(d.Unit, u.Name), string(d.Path),
+		string(d.TreePath), string(d.Kind), d.Name, d.File, d.DefStart, d.DefEnd,
+		d.Exported, d.Callable, d.Test, d.Local, data,
+	)
+}
+
+// Ref adds a row for a ref in the source unit u.
+func (im *Importer) Ref(u *unit.SourceUnit, r *graph.Ref) error {
+	return im.add(refsTable,
+		string(im.repo), im.commitID, or(r.UnitType, u.Type), or(r.Unit, u.Name),
+		or(string(r.DefRepo), string(im.repo)), or(r.DefUnitType, u.Type), or(r.DefUnit, u.Name), string(r.DefPath),
+		r.Def, string(r.Kind), r.File, r.Start, r.End,
+	)
+}
+
+// Doc adds a row for a doc in the source unit u.
+func (im *Importer) Doc(u *unit.SourceUnit, d *graph.Doc) error {
+	return im.add(docsTable,
+		string(im.repo), im.commitID, or(d.UnitType, u.Type), or(d.Unit, u.Name), string(d.Path),
+		d.Format, d.Data, d.File, d.Start, d.End,
+	)
+}
+
+// Dep adds a row for a resolved dependency. Its From fields must be set (as
+// they are by dep.ResolutionsToResolvedDeps).
+func (im *Importer) Dep(rd *dep.ResolvedDep) error {
+	return im.add(depsTable,
+		string(rd.FromRepo), rd.FromCommitID, rd.FromUnitType, rd.FromUnit,
+		string(rd.ToRepo), rd.ToUnitType, rd.ToUnit, rd.ToVersionString, rd.ToRevSpec,
+	)
+}
+
+// Rows returns the number of rows added to each table, keyed by table
+// name.
+func (im *Importer) Rows() map[string]int {
+	rows := make(map[string]int, len(tables))
+	for i, t := range tables {
+		rows[t.name] = im.batches[i].total
+	}
+	return rows
+}
+
+// Close writes the remaining rows and the end of the script, which commits
+// the transaction. It does not close the underlying io.Writer.
+func (im *Importer) Close() error {
+	for i := range im.batches {
+		im.flush(i)
+	}
+	if im.err != nil {
+		return im.err
+	}
+	_, im.err = io.WriteString(im.w, "COMMIT;\n")
+	return im.err
+}
+
+// add buffers a row of the table, and writes the table's buffered rows if
+// there are enough of them for a COPY statement.
+func (im *Importer) add(table int, values ...interface{}) error {
+	if im.err != nil {
+		return im.err
+	}
+	b := &im.batches[table]
+	for i, v := range values {
+		if i > 0 {
+			b.buf.WriteByte('\t')
+		}
+		writeCopyValue(&b.buf, v)
+	}
+	b.buf.WriteByte('\n')
+	b.n++
+	b.total++
+	if b.n >= im.batchSize {
+		im.flush(table)
+	}
+	return im.err
+}
+
+// flush writes a COPY statement with the table's buffered rows, if there
+// are any.
+func (im *Importer) flush(table int) {
+	b := &im.batches[table]
+	if im.err != nil || b.n == 0 {
+		return
+	}
+	t := tables[table]
+	if _, err := fmt.Fprintf(im.w, "COPY %s (%s) FROM stdin;\n", t.name, strings.Join(t.columns, ", ")); err != nil {
+		im.err = err
+		return
+	}
+	if _, err := b.buf.WriteTo(im.w); err != nil {
+		im.err = err
+		return
+	}
+	if _, err := io.WriteString(im.w, "\\.\n"); err != nil {
+		im.err = err
+		return
+	}
+	b.buf.Reset()
+	b.n = 0
+}
+
+// writeCopyValue writes v as a column value in the text format of COPY. A
+// nil v is written as NULL.
+func writeCopyValue(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString(`\N`)
+	case bool:
+		if v {
+			b.WriteByte('t')
+		} else {
+			b.WriteByte('f')
+		}
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case string:
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; c {
+			case '\\':
+				b.WriteString(`\\`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			default:
+				b.WriteByte(c)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("pgimport: unsupported column value type %T", v))
+	}
+}
+
+// quoteLiteral returns s as an SQL string literal (assuming
+// standard_conforming_strings, the default since PostgreSQL 9.1).
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func or(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
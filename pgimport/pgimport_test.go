@@ -0,0 +1,174 @@
+package pgimport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestWriteCopyValue(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{nil, `\N`},
+		{true, `t`},
+		{false, `f`},
+		{-12, `-12`},
+		{"", ``},
+		{"a b", `a b`},
+		{"a\tb\nc\r\\d", `a\tb\nc\r\\d`},
+		{`\N`, `\\N`},
+		{`\.`, `\\.`},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		writeCopyValue(&b, test.v)
+		if got := b.String(); got != test.want {
+			t.Errorf("%#v: got %q, want %q", test.v, got, test.want)
+		}
+	}
+}
+
+func TestImporter(t *testing.T) {
+	u := &unit.SourceUnit{Name: "u", Type: "t"}
+
+	tests := map[string]struct {
+		batchSize int
+		add       func(im *Importer) error
+		want      []string // the lines of the script after the schema
+		wantRows  map[string]int
+	}{
+		"empty": {
+			batchSize: 10,
+			add:       func(im *Importer) error { return nil },
+			want: []string{
+				"DELETE FROM defs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM refs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM docs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM deps WHERE from_repo = 'r.com/x''y' AND from_commit_id = 'c';",
+				"COMMIT;",
+			},
+			wantRows: map[string]int{"defs": 0, "refs": 0, "docs": 0, "deps": 0},
+		},
+		"all tables": {
+			batchSize: 10,
+			add: func(im *Importer) error {
+				if err := im.Def(u, &graph.Def{DefKey: graph.DefKey{Path: "p"}, TreePath: "p", Kind: "func", Name: "f", File: "f.go", DefStart: 1, DefEnd: 2, Exported: true, Data: []byte(`{"a":"b\tc"}`)}); err != nil {
+					return err
+				}
+				if err := im.Def(u, &graph.Def{DefKey: graph.DefKey{UnitType: "t2", Unit: "u2", Path: "q"}, Name: "g"}); err != nil {
+					return err
+				}
+				if err := im.Ref(u, &graph.Ref{DefPath: "p", Def: true, File: "f.go", Start: 1, End: 2}); err != nil {
+					return err
+				}
+				if err := im.Ref(u, &graph.Ref{DefRepo: "o.com/z", DefUnitType: "t3", DefUnit: "u3", DefPath: "x", Kind: graph.Call, File: "f.go", Start: 3, End: 4}); err != nil {
+					return err
+				}
+				if err := im.Doc(u, &graph.Doc{DefKey: graph.DefKey{Path: "p"}, Format: "text/plain", Data: "line 1\nline 2", File: "f.go"}); err != nil {
+					return err
+				}
+				return im.Dep(&dep.ResolvedDep{FromRepo: "r.com/x'y", FromCommitID: "c", FromUnitType: "t", FromUnit: "u", ToRepo: "o.com/z", ToUnitType: "t3", ToUnit: "u3", ToVersionString: "1.0"})
+			},
+			want: []string{
+				"DELETE FROM defs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM refs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM docs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM deps WHERE from_repo = 'r.com/x''y' AND from_commit_id = 'c';",
+				"COPY defs (repo, commit_id, unit_type, unit, path, tree_path, kind, name, file, def_start, def_end, exported, callable, test, local, data) FROM stdin;",
+				`r.com/x'y	c	t	u	p	p	func	f	f.go	1	2	t	f	f	f	{"a":"b\\tc"}`,
+				`r.com/x'y	c	t2	u2	q			g		0	0	f	f	f	f	\N`,
+				`\.`,
+				"COPY refs (repo, commit_id, unit_type, unit, def_repo, def_unit_type, def_unit, def_path, is_def, kind, file, ref_start, ref_end) FROM stdin;",
+				`r.com/x'y	c	t	u	r.com/x'y	t	u	p	t		f.go	1	2`,
+				`r.com/x'y	c	t	u	o.com/z	t3	u3	x	f	call	f.go	3	4`,
+				`\.`,
+				"COPY docs (repo, commit_id, unit_type, unit, path, format, data, file, doc_start, doc_end) FROM stdin;",
+				`r.com/x'y	c	t	u	p	text/plain	line 1\nline 2	f.go	0	0`,
+				`\.`,
+				"COPY deps (from_repo, from_commit_id, from_unit_type, from_unit, to_repo, to_unit_type, to_unit, to_version_string, to_rev_spec) FROM stdin;",
+				`r.com/x'y	c	t	u	o.com/z	t3	u3	1.0	`,
+				`\.`,
+				"COMMIT;",
+			},
+			wantRows: map[string]int{"defs": 2, "refs": 2, "docs": 1, "deps": 1},
+		},
+		"batches": {
+			batchSize: 2,
+			add: func(im *Importer) error {
+				for _, name := range []string{"a", "b", "c"} {
+					if err := im.Def(u, &graph.Def{DefKey: graph.DefKey{Path: graph.DefPath(name)}}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			want: []string{
+				"DELETE FROM defs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM refs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM docs WHERE repo = 'r.com/x''y' AND commit_id = 'c';",
+				"DELETE FROM deps WHERE from_repo = 'r.com/x''y' AND from_commit_id = 'c';",
+				"COPY defs (repo, commit_id, unit_type, unit, path, tree_path, kind, name, file, def_start, def_end, exported, callable, test, local, data) FROM stdin;",
+				`r.com/x'y	c	t	u	a					0	0	f	f	f	f	\N`,
+				`r.com/x'y	c	t	u	b					0	0	f	f	f	f	\N`,
+				`\.`,
+				"COPY defs (repo, commit_id, unit_type, unit, path, tree_path, kind, name, file, def_start, def_end, exported, callable, test, local, data) FROM stdin;",
+				`r.com/x'y	c	t	u	c					0	0	f	f	f	f	\N`,
+				`\.`,
+				"COMMIT;",
+			},
+			wantRows: map[string]int{"defs": 3, "refs": 0, "docs": 0, "deps": 0},
+		},
+	}
+	for label, test := range tests {
+		var buf bytes.Buffer
+		im, err := NewImporter(&buf, "r.com/x'y", "c", test.batchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := test.add(im); err != nil {
+			t.Errorf("%s: add: %s", label, err)
+			continue
+		}
+		if err := im.Close(); err != nil {
+			t.Errorf("%s: Close: %s", label, err)
+			continue
+		}
+
+		const header = "\\set ON_ERROR_STOP on\nBEGIN;\n" + Schema
+		script := buf.String()
+		if !strings.HasPrefix(script, header) {
+			t.Errorf("%s: script doesn't start with the header and schema:\n%s", label, script)
+			continue
+		}
+		if got, want := strings.TrimPrefix(script, header), strings.Join(test.want, "\n")+"\n"; got != want {
+			t.Errorf("%s: got script\n%s\nwant\n%s", label, got, want)
+		}
+		if got := im.Rows(); !equalRows(got, test.wantRows) {
+			t.Errorf("%s: got rows %v, want %v", label, got, test.wantRows)
+		}
+	}
+}
+
+func TestNewImporter_badBatchSize(t *testing.T) {
+	if _, err := NewImporter(&bytes.Buffer{}, "r", "c", 0); err == nil {
+		t.Error("got no error for batch size 0")
+	}
+}
+
+func equalRows(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
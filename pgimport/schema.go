@@ -0,0 +1,107 @@
+package pgimport
+
+// Schema creates the tables that graph data is imported into, and their
+// indexes, if they don't already exist. Each table holds the rows of many
+// repository commits, which are identified by the first 2 columns (repo
+// and commit_id, or from_repo and from_commit_id for deps).
+//
+// The defs, refs, and docs tables hold the graph.Def, graph.Ref, and
+// graph.Doc items of graph data, with empty def key fields (meaning the
+// repository or source unit that the item is in) filled in. Byte offsets
+// in files are in the *_start and *_end columns (not start and end, which
+// are reserved words). The deps table holds dep.ResolvedDep items.
+//
+// The indexes are for the common queries: looking up defs by key, name, or
+// file; finding the refs to a def or in a file; looking up docs by def
+// key; and finding a source unit's dependencies or its dependents. The
+// columns are documented in docs/sources/api/postgres.md.
+const Schema = `CREATE TABLE IF NOT EXISTS defs (
+	repo text NOT NULL,
+	commit_id text NOT NULL,
+	unit_type text NOT NULL,
+	unit text NOT NULL,
+	path text NOT NULL,
+	tree_path text NOT NULL,
+	kind text NOT NULL,
+	name text NOT NULL,
+	file text NOT NULL,
+	def_start integer NOT NULL,
+	def_end integer NOT NULL,
+	exported boolean NOT NULL,
+	callable boolean NOT NULL,
+	test boolean NOT NULL,
+	local boolean NOT NULL,
+	data jsonb
+);
+CREATE INDEX IF NOT EXISTS defs_key_idx ON defs (repo, commit_id, unit_type, unit, path);
+CREATE INDEX IF NOT EXISTS defs_name_idx ON defs (name);
+CREATE INDEX IF NOT EXISTS defs_file_idx ON defs (repo, commit_id, file, def_start);
+
+CREATE TABLE IF NOT EXISTS refs (
+	repo text NOT NULL,
+	commit_id text NOT NULL,
+	unit_type text NOT NULL,
+	unit text NOT NULL,
+	def_repo text NOT NULL,
+	def_unit_type text NOT NULL,
+	def_unit text NOT NULL,
+	def_path text NOT NULL,
+	is_def boolean NOT NULL,
+	kind text NOT NULL,
+	file text NOT NULL,
+	ref_start integer NOT NULL,
+	ref_end integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS refs_def_idx ON refs (def_repo, def_unit_type, def_unit, def_path);
+CREATE INDEX IF NOT EXISTS refs_file_idx ON refs (repo, commit_id, file, ref_start);
+
+CREATE TABLE IF NOT EXISTS docs (
+	repo text NOT NULL,
+	commit_id text NOT NULL,
+	unit_type text NOT NULL,
+	unit text NOT NULL,
+	path text NOT NULL,
+	format text NOT NULL,
+	data text NOT NULL,
+	file text NOT NULL,
+	doc_start integer NOT NULL,
+	doc_end integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS docs_key_idx ON docs (repo, commit_id, unit_type, unit, path);
+
+CREATE TABLE IF NOT EXISTS deps (
+	from_repo text NOT NULL,
+	from_commit_id text NOT NULL,
+	from_unit_type text NOT NULL,
+	from_unit text NOT NULL,
+	to_repo text NOT NULL,
+	to_unit_type text NOT NULL,
+	to_unit text NOT NULL,
+	to_version_string text NOT NULL,
+	to_rev_spec text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS deps_from_idx ON deps (from_repo, from_commit_id, from_unit_type, from_unit);
+CREATE INDEX IF NOT EXISTS deps_to_idx ON deps (to_repo, to_unit_type, to_unit);
+`
+
+// The indexes in tables of the tables in Schema.
+const (
+	defsTable = iota
+	refsTable
+	docsTable
+	depsTable
+	numTables
+)
+
+// tables are the tables in Schema and their columns, in the order in which
+// the Importer writes their values. The first 2 columns identify the
+// repository commit that a row was imported from.
+var tables = [numTables]struct {
+	name    string
+	columns []string
+}{
+	defsTable: {"defs", []string{"repo", "commit_id", "unit_type", "unit", "path", "tree_path", "kind", "name", "file", "def_start", "def_end", "exported", "callable", "test", "local", "data"}},
+	refsTable: {"refs", []string{"repo", "commit_id", "unit_type", "unit", "def_repo", "def_unit_type", "def_unit", "def_path", "is_def", "kind", "file", "ref_start", "ref_end"}},
+	docsTable: {"docs", []string{"repo", "commit_id", "unit_type", "unit", "path", "format", "data", "file", "doc_start", "doc_end"}},
+	depsTable: {"deps", []string{"from_repo", "from_commit_id", "from_unit_type", "from_unit", "to_repo", "to_unit_type", "to_unit", "to_version_string", "to_rev_spec"}},
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/sourcegraph/makex"
 	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
 )
 
 type Options struct {
@@ -40,6 +42,11 @@ func CreateMakefile(buildDataDir string, c *config.Tree, opt Options) (*makex.Ma
 		name := ruleMakerNames[i]
 		rules, err := r(c, buildDataDir, allRules, opt)
 		if err != nil {
+			if toolchain.IsNotFound(err) {
+				// Preserve the error type so callers can tell that a
+				// toolchain is missing.
+				return nil, &toolchain.NotFoundError{Msg: fmt.Sprintf("rule maker %s: %s", name, err)}
+			}
 			return nil, fmt.Errorf("rule maker %s: %s", name, err)
 		}
 		allRules = append(allRules, rules...)
@@ -63,3 +70,30 @@ func CreateMakefile(buildDataDir string, c *config.Tree, opt Options) (*makex.Ma
 
 	return mf, nil
 }
+
+// A SourceUnitRule is a rule whose target contains data about a single source
+// unit.
+type SourceUnitRule interface {
+	makex.Rule
+	SourceUnit() *unit.SourceUnit
+}
+
+// A ToolRule is a rule whose recipe runs a toolchain tool.
+type ToolRule interface {
+	makex.Rule
+	ToolRef() *toolchain.ToolRef
+}
+
+// SourceUnitTargets returns the targets of all rules in mf that build data
+// for the source unit with the given name and type.
+func SourceUnitTargets(mf *makex.Makefile, unitName, unitType string) []string {
+	var targets []string
+	for _, rule := range mf.Rules {
+		if r, ok := rule.(SourceUnitRule); ok {
+			if u := r.SourceUnit(); u.Name == unitName && u.Type == unitType {
+				targets = append(targets, r.Target())
+			}
+		}
+	}
+	return targets
+}
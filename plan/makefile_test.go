@@ -39,14 +39,20 @@ func TestCreateMakefile(t *testing.T) {
 	}
 
 	want := `
-all: testdata/n/t.blame.json testdata/n/t.graph.json testdata/n/t.depresolve.json testdata/n/t.authorship.json
+all: testdata/build.blame.json testdata/n/t.blame.json testdata/n/t.graph.json testdata/n/t.graphindex.json testdata/n/t.depresolve.json testdata/n/t.authorship.json
 
-testdata/n/t.blame.json: testdata/n/t.unit.json f
-	src internal unit-blame --unit-data testdata/n/t.unit.json 1> $@
+testdata/build.blame.json: testdata/n/t.unit.json f
+	src internal blame testdata/n/t.unit.json 1> $@
+
+testdata/n/t.blame.json: testdata/n/t.unit.json testdata/build.blame.json
+	src internal unit-blame --unit-data testdata/n/t.unit.json --blame-data testdata/build.blame.json 1> $@
 
 testdata/n/t.graph.json: testdata/n/t.unit.json f
 	src tool  "tc" "t" < $^ | src internal normalize-graph-data 1> $@
 
+testdata/n/t.graphindex.json: testdata/n/t.graph.json
+	src internal unit-graph-shards --graph-data testdata/n/t.graph.json --shard-dir testdata/n/t.graphindex.d 1> $@
+
 testdata/n/t.depresolve.json: testdata/n/t.unit.json
 	src tool  "tc" "t" < $^ 1> $@
 
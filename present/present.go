@@ -0,0 +1,194 @@
+// Package present renders the human-readable output of commands: tables,
+// trees, and colored text. Color is only used when the output is a terminal
+// (see IsTerminal), so that output that is piped or redirected is plain text.
+package present
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Color is a text color (or other style) of output to a terminal.
+type Color int
+
+const (
+	Plain Color = iota
+	Bold
+	Dim
+	Red
+	Green
+	Yellow
+	Cyan
+)
+
+// sgr is the ANSI "select graphic rendition" parameter of each color.
+var sgr = map[Color]string{
+	Bold:   "1",
+	Dim:    "2",
+	Red:    "31",
+	Green:  "32",
+	Yellow: "33",
+	Cyan:   "36",
+}
+
+// IsTerminal reports whether f is a terminal (a character device). It
+// returns false if the NO_COLOR environment variable is set or TERM is
+// "dumb", since callers use it to decide whether to color their output.
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// A Printer writes human-readable output to a writer, coloring it if Color
+// is set.
+type Printer struct {
+	W     io.Writer
+	Color bool
+}
+
+// NewPrinter returns a printer to f, which colors its output if f is a
+// terminal.
+func NewPrinter(f *os.File) *Printer {
+	return &Printer{W: f, Color: IsTerminal(f)}
+}
+
+// Paint returns s in color c (or s itself, if p doesn't color its output).
+func (p *Printer) Paint(c Color, s string) string {
+	if !p.Color || c == Plain || s == "" {
+		return s
+	}
+	return "\x1b[" + sgr[c] + "m" + s + "\x1b[0m"
+}
+
+// Printf writes a formatted line (a newline is appended) in color c.
+func (p *Printer) Printf(c Color, format string, a ...interface{}) {
+	fmt.Fprintln(p.W, p.Paint(c, fmt.Sprintf(format, a...)))
+}
+
+// A Table is a list of rows with aligned columns.
+type Table struct {
+	// Header is the names of the columns, or nil for no header row.
+	Header []string
+
+	// Colors is the color of each column's cells (which is Plain for
+	// columns past its end).
+	Colors []Color
+
+	// Align is the alignment of each column: "r" to right-align its cells
+	// (as for numbers), or "" (or past its end) to left-align them.
+	Align []string
+
+	Rows [][]string
+}
+
+// Table writes t with its columns separated by two spaces. Lines have no
+// trailing spaces, and columns that are empty in every row are omitted.
+func (p *Printer) Table(t *Table) {
+	rows := t.Rows
+	if t.Header != nil {
+		rows = append([][]string{t.Header}, rows...)
+	}
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if t.Header != nil {
+		// Columns with values only in the header are still empty.
+		for i := range widths {
+			empty := true
+			for _, row := range t.Rows {
+				if i < len(row) && row[i] != "" {
+					empty = false
+					break
+				}
+			}
+			if empty {
+				widths[i] = 0
+			}
+		}
+	}
+	last := len(widths) - 1
+	for last >= 0 && widths[last] == 0 {
+		last--
+	}
+
+	for r, row := range rows {
+		var line []string
+		for i := 0; i <= last; i++ {
+			if widths[i] == 0 {
+				continue
+			}
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			color := Plain
+			if r == 0 && t.Header != nil {
+				color = Bold
+			} else if i < len(t.Colors) {
+				color = t.Colors[i]
+			}
+			cell = p.Paint(color, cell)
+			switch {
+			case i < len(t.Align) && t.Align[i] == "r":
+				cell = pad + cell
+			case i < last:
+				cell += pad
+			}
+			line = append(line, cell)
+		}
+		fmt.Fprintln(p.W, strings.TrimRight(strings.Join(line, "  "), " "))
+	}
+}
+
+// A Tree is a node in a tree, such as a dependency tree.
+type Tree struct {
+	Label string
+
+	// Note is printed (dimmed) after the label.
+	Note string
+
+	Children []*Tree
+}
+
+// Tree writes the trees, drawing the branches from each node to its
+// children. Root labels are in color c.
+func (p *Printer) Tree(c Color, trees []*Tree) {
+	for _, t := range trees {
+		fmt.Fprintln(p.W, p.label(c, t))
+		p.children(t.Children, "")
+	}
+}
+
+func (p *Printer) label(c Color, t *Tree) string {
+	s := p.Paint(c, t.Label)
+	if t.Note != "" {
+		s += " " + p.Paint(Dim, t.Note)
+	}
+	return s
+}
+
+func (p *Printer) children(trees []*Tree, indent string) {
+	for i, t := range trees {
+		branch, next := "├── ", "│   "
+		if i == len(trees)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintln(p.W, p.Paint(Dim, indent+branch)+p.label(Plain, t))
+		p.children(t.Children, indent+next)
+	}
+}
@@ -0,0 +1,65 @@
+package present
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrinter_Table(t *testing.T) {
+	tests := []struct {
+		table *Table
+		color bool
+		want  string
+	}{
+		{
+			table: &Table{Rows: [][]string{{"a", "bb", "c"}, {"ddd", "e", ""}}},
+			want:  "a    bb  c\nddd  e\n",
+		},
+		{
+			table: &Table{
+				Header: []string{"NAME", "N", "EMPTY"},
+				Align:  []string{"", "r"},
+				Rows:   [][]string{{"é", "1", ""}, {"b", "100", ""}},
+			},
+			want: "NAME    N\né       1\nb     100\n",
+		},
+		{
+			table: &Table{Colors: []Color{Red}, Rows: [][]string{{"a", "b"}, {"cc", "d"}}},
+			color: true,
+			want:  "\x1b[31ma\x1b[0m   b\n\x1b[31mcc\x1b[0m  d\n",
+		},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		p := &Printer{W: &buf, Color: test.color}
+		p.Table(test.table)
+		if got := buf.String(); got != test.want {
+			t.Errorf("#%d: got\n%q\nwant\n%q", i, got, test.want)
+		}
+	}
+}
+
+func TestPrinter_Tree(t *testing.T) {
+	trees := []*Tree{{
+		Label: "r",
+		Children: []*Tree{
+			{Label: "a", Children: []*Tree{{Label: "b"}}},
+			{Label: "c", Note: "(*)"},
+		},
+	}}
+	var buf bytes.Buffer
+	p := &Printer{W: &buf}
+	p.Tree(Cyan, trees)
+	want := "r\n├── a\n│   └── b\n└── c (*)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	p.Color = true
+	p.Tree(Cyan, []*Tree{{Label: "r", Children: []*Tree{{Label: "a", Note: "(*)"}}}})
+	want = "\x1b[36mr\x1b[0m\n\x1b[2m└── \x1b[0ma \x1b[2m(*)\x1b[0m\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
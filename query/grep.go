@@ -0,0 +1,76 @@
+package query
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A Grep filters defs and refs by regular expressions on their fields. A
+// nil expression matches anything. An item matches if all of the non-nil
+// expressions match (a part of) the corresponding fields.
+type Grep struct {
+	// Name matches the def's name (for a ref, the last component of the
+	// path of the def that it points to).
+	Name *regexp.Regexp
+
+	// Path matches the def's path (for a ref, the path of the def that it
+	// points to).
+	Path *regexp.Regexp
+
+	// File matches the file that the def or ref is in.
+	File *regexp.Regexp
+
+	// Kind matches the def's or ref's kind.
+	Kind *regexp.Regexp
+}
+
+// NewGrep compiles the regular expressions (any of which may be "" to
+// match anything) of a Grep.
+func NewGrep(name, path, file, kind string) (*Grep, error) {
+	g := &Grep{}
+	for _, e := range []struct {
+		flag string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"name", name, &g.Name},
+		{"path", path, &g.Path},
+		{"file", file, &g.File},
+		{"kind", kind, &g.Kind},
+	} {
+		if e.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(e.expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regexp: %s", e.flag, err)
+		}
+		*e.re = re
+	}
+	return g, nil
+}
+
+// MatchDef returns whether def matches g.
+func (g *Grep) MatchDef(def *graph.Def) bool {
+	return g.match(def.Name, string(def.Path), def.File, string(def.Kind))
+}
+
+// MatchRef returns whether ref matches g.
+func (g *Grep) MatchRef(ref *graph.Ref) bool {
+	return g.match(path.Base(string(ref.DefPath)), string(ref.DefPath), ref.File, string(ref.Kind))
+}
+
+func (g *Grep) match(name, path, file, kind string) bool {
+	for _, m := range []struct {
+		re *regexp.Regexp
+		s  string
+	}{{g.Name, name}, {g.Path, path}, {g.File, file}, {g.Kind, kind}} {
+		if m.re != nil && !m.re.MatchString(m.s) {
+			return false
+		}
+	}
+	return true
+}
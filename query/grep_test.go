@@ -0,0 +1,40 @@
+package query
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestGrep(t *testing.T) {
+	def := &graph.Def{DefKey: graph.DefKey{Path: "Server/ServeHTTP"}, Name: "ServeHTTP", Kind: graph.Func, File: "http/server.go"}
+	ref := &graph.Ref{DefPath: "Server/ServeHTTP", Kind: graph.Call, File: "main.go"}
+	tests := []struct {
+		name, path, file, kind string
+		wantDef, wantRef       bool
+	}{
+		{wantDef: true, wantRef: true},
+		{name: "^Serve", wantDef: true, wantRef: true},
+		{name: "^Server$", wantDef: false, wantRef: false},
+		{path: "^Server/", wantDef: true, wantRef: true},
+		{file: `^http/`, wantDef: true, wantRef: false},
+		{kind: "^(func|call)$", wantDef: true, wantRef: true},
+		{name: "HTTP", kind: "^call$", wantDef: false, wantRef: true},
+	}
+	for _, test := range tests {
+		g, err := NewGrep(test.name, test.path, test.file, test.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.MatchDef(def); got != test.wantDef {
+			t.Errorf("%+v: got MatchDef %v, want %v", test, got, test.wantDef)
+		}
+		if got := g.MatchRef(ref); got != test.wantRef {
+			t.Errorf("%+v: got MatchRef %v, want %v", test, got, test.wantRef)
+		}
+	}
+
+	if _, err := NewGrep("(", "", "", ""); err == nil {
+		t.Error("got no error for an invalid regexp")
+	}
+}
@@ -0,0 +1,228 @@
+// Package query implements the pagination, filtering, and field selection
+// options that are shared by the APIs that list defs and refs (the JSON and
+// GraphQL APIs of `src browse` and the JSON output of commands such as
+// `src api list`), so that a client can page through a long list (such as
+// the refs to a popular def) without the whole list being encoded at once.
+//
+// A page is selected by a cursor (the After option, which is the cursor of
+// the last item of the previous page) and a maximum number of items (the
+// First option). Cursors are opaque strings that encode the position of an
+// item in the unfiltered list, so they remain valid for the same list with
+// the same filters.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// NoLimit is the value of Options.First that selects all of the (matching)
+// items after the cursor.
+const NoLimit = -1
+
+// Options select a page of a list of defs or refs, filtered by kind and
+// file, and the fields of each item to return.
+type Options struct {
+	// First is the maximum number of items in the page, or NoLimit.
+	First int
+
+	// After is the cursor of the item after which the page starts, or ""
+	// to start at the beginning of the list.
+	After string
+
+	// Kinds, if set, filters the items to those of these kinds (the
+	// def's Kind, or the ref's Kind).
+	Kinds []string
+
+	// File, if set, filters the items to those in this file.
+	File string
+
+	// Fields, if set, selects the fields (by their JSON names) of the
+	// items to return. See Select.
+	Fields []string
+}
+
+// MatchDef returns whether def satisfies the options' filters.
+func (o *Options) MatchDef(def *graph.Def) bool {
+	return o.match(string(def.Kind), def.File)
+}
+
+// MatchRef returns whether ref satisfies the options' filters.
+func (o *Options) MatchRef(ref *graph.Ref) bool {
+	return o.match(string(ref.Kind), ref.File)
+}
+
+func (o *Options) match(kind, file string) bool {
+	if o.File != "" && file != o.File {
+		return false
+	}
+	if len(o.Kinds) == 0 {
+		return true
+	}
+	for _, k := range o.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// A Page is a page of a list.
+type Page struct {
+	// Indexes are the positions in the list of the items in the page.
+	Indexes []int
+
+	// HasNext is whether there are more matching items after the page.
+	HasNext bool
+
+	// Total is the number of matching items in the whole list.
+	Total int
+}
+
+// Next returns the cursor to pass as the After option to get the next
+// page, or "" if there is none.
+func (p *Page) Next() string {
+	if !p.HasNext || len(p.Indexes) == 0 {
+		return ""
+	}
+	return EncodeCursor(p.Indexes[len(p.Indexes)-1])
+}
+
+// Page returns the page selected by the options of a list of n items, of
+// which match(i) reports whether the i'th satisfies the filters (e.g.,
+// MatchDef). The items themselves are not needed.
+func (o *Options) Page(n int, match func(i int) bool) (*Page, error) {
+	if o.First < NoLimit {
+		return nil, fmt.Errorf("invalid page size %d", o.First)
+	}
+	start := 0
+	if o.After != "" {
+		i, err := DecodeCursor(o.After)
+		if err != nil {
+			return nil, err
+		}
+		start = i + 1
+	}
+
+	p := &Page{}
+	for i := 0; i < n; i++ {
+		if !match(i) {
+			continue
+		}
+		p.Total++
+		if i < start {
+			continue
+		}
+		if o.First == NoLimit || len(p.Indexes) < o.First {
+			p.Indexes = append(p.Indexes, i)
+		} else {
+			p.HasNext = true
+		}
+	}
+	return p, nil
+}
+
+// EncodeCursor returns the cursor of the i'th item of a list.
+func EncodeCursor(i int) string {
+	return base64.URLEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(i)))
+}
+
+// DecodeCursor returns the position of the item whose cursor is cursor.
+func DecodeCursor(cursor string) (int, error) {
+	b, err := base64.URLEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(b), "cursor:") {
+		if n, err := strconv.Atoi(strings.TrimPrefix(string(b), "cursor:")); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+// ParseFields parses a comma-separated list of field names of the JSON
+// encoding of v (a struct or pointer to a struct), such as "File,Start".
+// The names are matched case-insensitively and returned as they are
+// encoded.
+func ParseFields(s string, v interface{}) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	names := jsonFields(reflect.TypeOf(v))
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		var name string
+		for _, n := range names {
+			if strings.EqualFold(n, f) {
+				name = n
+				break
+			}
+		}
+		if name == "" {
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown field %q (must be one of %s)", f, strings.Join(names, ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// jsonFields returns the names of the fields of the JSON encoding of the
+// struct type t (or pointer to it), including those of embedded structs.
+func jsonFields(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			names = append(names, jsonFields(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Select returns a value whose JSON encoding is that of v with only the
+// given fields (which ParseFields returns), or v itself if fields is
+// empty. Fields that are omitted from v's encoding (because they are
+// empty) are also omitted from the result.
+func Select(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if val, present := all[f]; present {
+			selected[f] = val
+		}
+	}
+	return selected, nil
+}
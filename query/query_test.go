@@ -0,0 +1,87 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestOptions_Page(t *testing.T) {
+	refs := []*graph.Ref{
+		{File: "a", Kind: graph.Call},
+		{File: "a", Kind: graph.Read},
+		{File: "b", Kind: graph.Call},
+		{File: "b", Kind: graph.Call},
+		{File: "c", Kind: graph.Call},
+	}
+	tests := []struct {
+		opt         Options
+		wantIndexes []int
+		wantHasNext bool
+		wantTotal   int
+	}{
+		{opt: Options{First: NoLimit}, wantIndexes: []int{0, 1, 2, 3, 4}, wantTotal: 5},
+		{opt: Options{First: 2}, wantIndexes: []int{0, 1}, wantHasNext: true, wantTotal: 5},
+		{opt: Options{First: 0}, wantHasNext: true, wantTotal: 5},
+		{opt: Options{First: 2, After: EncodeCursor(1)}, wantIndexes: []int{2, 3}, wantHasNext: true, wantTotal: 5},
+		{opt: Options{First: 2, After: EncodeCursor(2)}, wantIndexes: []int{3, 4}, wantTotal: 5},
+		{opt: Options{First: NoLimit, Kinds: []string{"call"}, File: "b"}, wantIndexes: []int{2, 3}, wantTotal: 2},
+		{opt: Options{First: 1, Kinds: []string{"call"}, After: EncodeCursor(0)}, wantIndexes: []int{2}, wantHasNext: true, wantTotal: 4},
+		{opt: Options{First: NoLimit, Kinds: []string{"write"}}, wantTotal: 0},
+	}
+	for _, test := range tests {
+		opt := test.opt
+		page, err := opt.Page(len(refs), func(i int) bool { return opt.MatchRef(refs[i]) })
+		if err != nil {
+			t.Errorf("%+v: %s", opt, err)
+			continue
+		}
+		if !reflect.DeepEqual(page.Indexes, test.wantIndexes) || page.HasNext != test.wantHasNext || page.Total != test.wantTotal {
+			t.Errorf("%+v: got page %+v, want indexes %v, has next %v, total %d", opt, page, test.wantIndexes, test.wantHasNext, test.wantTotal)
+		}
+		if wantNext := test.wantHasNext && len(test.wantIndexes) > 0; (page.Next() != "") != wantNext {
+			t.Errorf("%+v: got next cursor %q", opt, page.Next())
+		}
+	}
+
+	for _, opt := range []Options{{First: -2}, {First: NoLimit, After: "x"}, {First: NoLimit, After: "Y3Vyc29yOi0x"}} {
+		if _, err := opt.Page(len(refs), func(int) bool { return true }); err == nil {
+			t.Errorf("%+v: got no error", opt)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	fields, err := ParseFields("file, START,path", &graph.Def{})
+	if err == nil {
+		t.Errorf("got fields %v, want error for unknown field START", fields)
+	}
+
+	fields, err = ParseFields("file,defstart,path,exported,treepath", &graph.Def{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"File", "DefStart", "Path", "Exported", "TreePath"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+
+	// Name and Unit aren't selected, and TreePath is empty (so it's omitted).
+	def := &graph.Def{DefKey: graph.DefKey{Unit: "u", Path: "p"}, Name: "n", File: "f", DefStart: 3}
+	v, err := Select(def, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"DefStart":3,"Exported":false,"File":"f","Path":"p"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	if v, err := Select(def, nil); v != def || err != nil {
+		t.Errorf("got %v (error %v) with no fields, want the def", v, err)
+	}
+}
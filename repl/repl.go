@@ -0,0 +1,280 @@
+// Package repl implements an interactive session for querying a
+// repository's build data (its source units, defs, refs, docs, and search
+// index), which is loaded once and then queried by a series of commands.
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"sourcegraph.com/sourcegraph/srclib/browse"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/search"
+)
+
+// ErrQuit is returned by Exec for the quit command.
+var ErrQuit = errors.New("quit")
+
+// A Session is an interactive query session over a repository's build
+// data.
+type Session struct {
+	Site    *browse.Site
+	Indexes []*search.Index
+
+	// Limit is the maximum number of search results (0 for no limit).
+	Limit int
+
+	// JSON is whether command output is printed as JSON (instead of text).
+	JSON bool
+
+	results []*search.Result // results of the last search
+}
+
+// A command is a REPL command.
+type command struct {
+	name, args, desc string
+	run              func(s *Session, arg string, w io.Writer) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"units", "", "list the source units", (*Session).units},
+		{"search", "QUERY", "search for defs by name (results are numbered)", (*Session).search},
+		{"def", "KEY|N", "show a def (by def key, or by number in the last search results)", (*Session).def},
+		{"refs", "KEY|N", "list the refs to a def", (*Session).refs},
+		{"docs", "KEY|N", "show a def's docs", (*Session).docs},
+		{"format", "text|json", "set the output format", (*Session).format},
+		{"help", "", "list the commands", (*Session).help},
+		{"quit", "", "end the session (also: exit, or EOF)", func(*Session, string, io.Writer) error { return ErrQuit }},
+	}
+}
+
+// Run reads commands from in, one per line, and executes them until EOF or
+// the quit command. Before reading each command, it writes prompt to out.
+// Command errors are written to errOut and don't end the session.
+func (s *Session) Run(in io.Reader, out, errOut io.Writer, prompt string) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		if err := s.Exec(scanner.Text(), out); err == ErrQuit {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(errOut, "error: %s\n", err)
+		}
+	}
+}
+
+// Exec executes a single command line, writing its output to w. If the
+// line contains "|", the command's output is piped to the shell command
+// after it (e.g., "refs 1 | grep foo.go") instead of written to w directly.
+func (s *Session) Exec(line string, w io.Writer) error {
+	var pipe string
+	if i := strings.Index(line, "|"); i != -1 {
+		line, pipe = line[:i], strings.TrimSpace(line[i+1:])
+		if pipe == "" {
+			return errors.New("missing shell command after |")
+		}
+	}
+	name, arg := strings.TrimSpace(line), ""
+	if i := strings.IndexAny(name, " \t"); i != -1 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+	if name == "" {
+		return nil
+	}
+	if name == "exit" {
+		name = "quit"
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+			break
+		}
+	}
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q (type \"help\" for a list of commands)", name)
+	}
+	if (cmd.args == "") != (arg == "") {
+		if arg == "" {
+			return fmt.Errorf("usage: %s %s", cmd.name, cmd.args)
+		}
+		return fmt.Errorf("%s takes no arguments", cmd.name)
+	}
+
+	if pipe == "" {
+		return cmd.run(s, arg, w)
+	}
+	var buf bytes.Buffer
+	if err := cmd.run(s, arg, &buf); err != nil {
+		return err
+	}
+	sh := exec.Command("sh", "-c", pipe)
+	sh.Stdin, sh.Stdout, sh.Stderr = &buf, w, w
+	if err := sh.Run(); err != nil {
+		return fmt.Errorf("%s: %s", pipe, err)
+	}
+	return nil
+}
+
+// print writes v to w as JSON if s.JSON is set, and otherwise calls
+// printText.
+func (s *Session) print(w io.Writer, v interface{}, printText func()) error {
+	if !s.JSON {
+		printText()
+		return nil
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// defKey returns the key of the def identified by arg: either a def key
+// (whose repo may be omitted, as in ":GoPackage:foo:Bar") or the 1-based
+// number of a result of the last search.
+func (s *Session) defKey(arg string) (graph.DefKey, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(s.results) {
+			return graph.DefKey{}, fmt.Errorf("no search result %d (the last search had %d results)", n, len(s.results))
+		}
+		r := s.results[n-1]
+		return graph.DefKey{Repo: s.Site.RepoURI, UnitType: r.UnitType, Unit: r.Unit, Path: r.Path}, nil
+	}
+	key, err := graph.ParseDefKey(arg)
+	if err != nil {
+		return graph.DefKey{}, err
+	}
+	if key.Repo == "" {
+		key.Repo = s.Site.RepoURI
+	}
+	return key, nil
+}
+
+func (s *Session) units(_ string, w io.Writer) error {
+	units := s.Site.Units()
+	return s.print(w, units, func() {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, u := range units {
+			fmt.Fprintf(tw, "%s\t%s\t%d files\t%d defs\t%d refs\n", u.Type, u.Name, len(u.Files), len(u.Defs), len(u.Refs))
+		}
+		tw.Flush()
+	})
+}
+
+func (s *Session) search(query string, w io.Writer) error {
+	s.results = search.Search(s.Indexes, query, s.Limit)
+	results := s.results
+	if results == nil {
+		results = []*search.Result{}
+	}
+	return s.print(w, results, func() {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for i, r := range results {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, r.Kind, r.Name, r.Path, r.Unit, location(r.File, r.DefStart, r.DefEnd))
+		}
+		tw.Flush()
+	})
+}
+
+func (s *Session) def(arg string, w io.Writer) error {
+	key, err := s.defKey(arg)
+	if err != nil {
+		return err
+	}
+	def := s.Site.Def(key)
+	if def == nil {
+		return fmt.Errorf("def %s not found", key.Format())
+	}
+	return s.print(w, def, func() {
+		fmt.Fprintln(w, key.Format())
+		fmt.Fprintf(w, "  %s %s\n", def.Kind, def.Name)
+		if def.File != "" {
+			fmt.Fprintf(w, "  %s\n", location(def.File, def.DefStart, def.DefEnd))
+		}
+		if doc := s.Site.Doc(key); doc != "" {
+			fmt.Fprintf(w, "  %s\n", strings.SplitN(doc, "\n", 2)[0])
+		}
+	})
+}
+
+func (s *Session) refs(arg string, w io.Writer) error {
+	key, err := s.defKey(arg)
+	if err != nil {
+		return err
+	}
+	refs := s.Site.Refs(key)
+	if refs == nil {
+		refs = []*graph.Ref{}
+	}
+	return s.print(w, refs, func() {
+		for _, ref := range refs {
+			fmt.Fprintln(w, location(ref.File, ref.Start, ref.End))
+		}
+	})
+}
+
+func (s *Session) docs(arg string, w io.Writer) error {
+	key, err := s.defKey(arg)
+	if err != nil {
+		return err
+	}
+	docs := s.Site.Docs(key)
+	if docs == nil {
+		docs = []*graph.Doc{}
+	}
+	return s.print(w, docs, func() {
+		if doc := s.Site.Doc(key); doc != "" {
+			fmt.Fprintln(w, doc)
+		} else {
+			fmt.Fprintf(w, "(no docs for %s)\n", key.Format())
+		}
+	})
+}
+
+func (s *Session) format(arg string, w io.Writer) error {
+	switch arg {
+	case "text":
+		s.JSON = false
+	case "json":
+		s.JSON = true
+	default:
+		return fmt.Errorf("unknown format %q (want text or json)", arg)
+	}
+	return nil
+}
+
+func (s *Session) help(_ string, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "%s %s\t%s\n", c.name, c.args, c.desc)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, `Append "| SHELLCMD" to a command to pipe its output to a shell command.`)
+	return nil
+}
+
+// location returns "FILE:START-END", or "" if file is "".
+func location(file string, start, end int) string {
+	if file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d-%d", file, start, end)
+}
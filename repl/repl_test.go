@@ -0,0 +1,115 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/browse"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/search"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func newTestSession() *Session {
+	defs := []*graph.Def{
+		{DefKey: graph.DefKey{Path: "NewReader"}, Name: "NewReader", Kind: "func", File: "a.go", DefStart: 0, DefEnd: 20},
+		{DefKey: graph.DefKey{Path: "Reader/Read"}, Name: "Read", Kind: "method", File: "a.go", DefStart: 30, DefEnd: 50},
+	}
+	site := browse.NewSite("r", []*browse.Unit{{
+		Type:  "t",
+		Name:  "u",
+		Files: []string{"a.go", "b.go"},
+		Output: &grapher.Output{
+			Defs: defs,
+			Refs: []*graph.Ref{
+				{DefPath: "NewReader", File: "a.go", Start: 5, End: 14, Def: true},
+				{DefPath: "NewReader", File: "b.go", Start: 3, End: 12},
+			},
+			Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "NewReader"}, Format: "text/plain", Data: "NewReader returns a reader."}},
+		},
+	}}, nil)
+	return &Session{
+		Site:    site,
+		Indexes: []*search.Index{search.NewIndex(&unit.SourceUnit{Type: "t", Name: "u"}, defs)},
+	}
+}
+
+func TestSession_Exec(t *testing.T) {
+	s := newTestSession()
+	tests := []struct {
+		line    string
+		want    []string // substrings of the output
+		wantErr bool
+	}{
+		{line: "", want: nil},
+		{line: "units", want: []string{"t  u  2 files  2 defs  2 refs"}},
+		{line: "search newreader", want: []string{"1  func  NewReader  NewReader  u  a.go:0-20"}},
+		{line: "def 1", want: []string{"r:t:u:NewReader", "func NewReader", "a.go:0-20", "NewReader returns a reader."}},
+		{line: "def :t:u:Reader/Read", want: []string{"method Read"}},
+		{line: "refs 1", want: []string{"a.go:5-14\nb.go:3-12\n"}},
+		{line: "refs 1 | grep b.go", want: []string{"b.go:3-12"}},
+		{line: "docs r:t:u:NewReader", want: []string{"NewReader returns a reader."}},
+		{line: "docs :t:u:Reader/Read", want: []string{"(no docs for r:t:u:Reader/Read)"}},
+		{line: "format json", want: nil},
+		{line: "refs 1", want: []string{`"File": "b.go"`}},
+		{line: "format text", want: nil},
+		{line: "help", want: []string{"search QUERY"}},
+
+		{line: "def 5", wantErr: true},
+		{line: "def :t:u:x", wantErr: true},
+		{line: "def x", wantErr: true},
+		{line: "def", wantErr: true},
+		{line: "units x", wantErr: true},
+		{line: "format xml", wantErr: true},
+		{line: "foo", wantErr: true},
+		{line: "units |", wantErr: true},
+		{line: "units | false", wantErr: true},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := s.Exec(test.line, &out)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: got no error", test.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.line, err)
+			continue
+		}
+		if test.want == nil && out.Len() != 0 {
+			t.Errorf("%q: got output %q, want none", test.line, out.String())
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%q: got output %q, want it to contain %q", test.line, out.String(), want)
+			}
+		}
+		if test.line == "refs 1 | grep b.go" && strings.Contains(out.String(), "a.go") {
+			t.Errorf("%q: got unfiltered output %q", test.line, out.String())
+		}
+	}
+}
+
+func TestSession_Run(t *testing.T) {
+	s := newTestSession()
+	var out, errOut bytes.Buffer
+	if err := s.Run(strings.NewReader("search read\nfoo\nquit\nunits\n"), &out, &errOut, "> "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "NewReader") {
+		t.Errorf("got output %q, want search results", out.String())
+	}
+	if strings.Contains(out.String(), "2 files") {
+		t.Errorf("got output %q after quit", out.String())
+	}
+	if want := "error: unknown command \"foo\""; !strings.HasPrefix(errOut.String(), want) {
+		t.Errorf("got error output %q, want %q", errOut.String(), want)
+	}
+	if got := strings.Count(out.String(), "> "); got != 3 {
+		t.Errorf("got %d prompts, want 3", got)
+	}
+}
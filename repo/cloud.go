@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// cloudURI returns the URI of the repository at path on host, if host is
+// a cloud code host (Azure DevOps or AWS CodeCommit) whose URLs have
+// several shapes for the same repository. The URI is chosen so that
+// "https://" + URI is a valid clone URL.
+func cloudURI(host, path string) (URI, bool) {
+	host = strings.ToLower(host)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case host == "dev.azure.com":
+		// https://dev.azure.com/ORG/PROJECT/_git/REPO
+		if len(parts) < 1 {
+			return "", false
+		}
+		return azureDevOpsURI(parts[0], parts[1:])
+	case host == "ssh.dev.azure.com" || host == "vs-ssh.visualstudio.com":
+		// git@ssh.dev.azure.com:v3/ORG/PROJECT/REPO
+		if len(parts) < 4 || parts[0] != "v3" {
+			return "", false
+		}
+		return azureDevOpsURI(parts[1], []string{parts[2], "_git", parts[3]})
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		// https://ORG.visualstudio.com/[DefaultCollection/]PROJECT/_git/REPO
+		if len(parts) > 0 && strings.EqualFold(parts[0], "DefaultCollection") {
+			parts = parts[1:]
+		}
+		return azureDevOpsURI(strings.TrimSuffix(host, ".visualstudio.com"), parts)
+	}
+	if m := codeCommitHost.FindStringSubmatch(host); m != nil {
+		// https://git-codecommit.REGION.amazonaws.com/v1/repos/REPO
+		if len(parts) < 3 || parts[0] != "v1" || parts[1] != "repos" {
+			return "", false
+		}
+		return codeCommitURI(m[1], m[2], parts[2]), true
+	}
+	return "", false
+}
+
+// azureDevOpsURI returns the URI of an Azure DevOps repository in org,
+// given the rest of its path: PROJECT/_git/REPO (followed by any web URL
+// page, such as "pullrequest/1"), or _git/REPO if the project has the same
+// name as the repository.
+func azureDevOpsURI(org string, parts []string) (URI, bool) {
+	var project, repo string
+	switch {
+	case len(parts) >= 3 && parts[1] == "_git":
+		project, repo = parts[0], parts[2]
+	case len(parts) >= 2 && parts[0] == "_git":
+		project, repo = parts[1], parts[1]
+	default:
+		return "", false
+	}
+	// Go import paths of Azure DevOps repositories end the repository
+	// name with ".git".
+	repo = strings.TrimSuffix(repo, ".git")
+	if org == "" || project == "" || repo == "" {
+		return "", false
+	}
+	return URI("dev.azure.com/" + org + "/" + project + "/_git/" + repo), true
+}
+
+// codeCommitHost matches the hostnames of CodeCommit's HTTPS and SSH clone
+// URLs, including FIPS endpoints.
+var codeCommitHost = regexp.MustCompile(`^git-codecommit(?:-fips)?\.([a-z0-9-]+)\.(amazonaws\.com(?:\.cn)?)$`)
+
+func codeCommitURI(region, domain, repo string) URI {
+	return URI("git-codecommit." + region + "." + domain + "/v1/repos/" + repo)
+}
+
+// codeCommitRemoteURI returns the URI of a CodeCommit repository named by
+// a git-remote-codecommit URL (codecommit://[PROFILE@]REPO or
+// codecommit::REGION://[PROFILE@]REPO). It returns false if cloneURL isn't
+// such a URL. If the URL has no region, the region is taken from the
+// AWS_REGION or AWS_DEFAULT_REGION environment variable.
+func codeCommitRemoteURI(cloneURL string) (URI, bool, error) {
+	if !strings.HasPrefix(cloneURL, "codecommit:") {
+		return "", false, nil
+	}
+	rest := strings.TrimPrefix(cloneURL, "codecommit:")
+	var region string
+	if strings.HasPrefix(rest, ":") {
+		i := strings.Index(rest, "://")
+		if i == -1 {
+			return "", true, fmt.Errorf("invalid CodeCommit URL %q", cloneURL)
+		}
+		region, rest = rest[1:i], rest[i+1:]
+	}
+	if !strings.HasPrefix(rest, "//") {
+		return "", true, fmt.Errorf("invalid CodeCommit URL %q", cloneURL)
+	}
+	repo := strings.TrimPrefix(rest, "//")
+	if i := strings.Index(repo, "@"); i != -1 {
+		repo = repo[i+1:] // remove the AWS profile
+	}
+	if repo == "" || strings.Contains(repo, "/") {
+		return "", true, fmt.Errorf("invalid CodeCommit URL %q (bad repository name)", cloneURL)
+	}
+	if region == "" {
+		if region = os.Getenv("AWS_REGION"); region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if region == "" {
+			return "", true, fmt.Errorf("CodeCommit URL %q has no region (and neither AWS_REGION nor AWS_DEFAULT_REGION is set)", cloneURL)
+		}
+	}
+	domain := "amazonaws.com"
+	if strings.HasPrefix(region, "cn-") {
+		domain = "amazonaws.com.cn"
+	}
+	return codeCommitURI(region, domain, repo), true, nil
+}
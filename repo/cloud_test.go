@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMakeURI_cloud(t *testing.T) {
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	os.Setenv("AWS_REGION", "eu-west-1")
+
+	tests := []struct {
+		cloneURL string
+		want     URI
+	}{
+		// Azure DevOps
+		{"https://dev.azure.com/org/project/_git/repo", "dev.azure.com/org/project/_git/repo"},
+		{"https://org@dev.azure.com/org/project/_git/repo", "dev.azure.com/org/project/_git/repo"},
+		{"https://dev.azure.com/org/project/_git/repo/pullrequest/1", "dev.azure.com/org/project/_git/repo"},
+		{"https://dev.azure.com/org/_git/repo", "dev.azure.com/org/repo/_git/repo"},
+		{"git@ssh.dev.azure.com:v3/org/project/repo", "dev.azure.com/org/project/_git/repo"},
+		{"org@vs-ssh.visualstudio.com:v3/org/project/repo", "dev.azure.com/org/project/_git/repo"},
+		{"https://org.visualstudio.com/project/_git/repo", "dev.azure.com/org/project/_git/repo"},
+		{"https://org.visualstudio.com/DefaultCollection/project/_git/repo", "dev.azure.com/org/project/_git/repo"},
+		{"dev.azure.com/org/project/_git/repo.git/pkg", "dev.azure.com/org/project/_git/repo"},
+
+		// AWS CodeCommit
+		{"https://git-codecommit.us-east-1.amazonaws.com/v1/repos/MyRepo", "git-codecommit.us-east-1.amazonaws.com/v1/repos/MyRepo"},
+		{"ssh://APKAEIBAERJR2EXAMPLE@git-codecommit.us-east-1.amazonaws.com/v1/repos/MyRepo", "git-codecommit.us-east-1.amazonaws.com/v1/repos/MyRepo"},
+		{"https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/MyRepo", "git-codecommit.us-east-1.amazonaws.com/v1/repos/MyRepo"},
+		{"codecommit::us-west-2://MyRepo", "git-codecommit.us-west-2.amazonaws.com/v1/repos/MyRepo"},
+		{"codecommit::cn-north-1://profile@MyRepo", "git-codecommit.cn-north-1.amazonaws.com.cn/v1/repos/MyRepo"},
+		{"codecommit://MyRepo", "git-codecommit.eu-west-1.amazonaws.com/v1/repos/MyRepo"},
+
+		// URLs on these hosts that aren't repositories are unaffected.
+		{"https://dev.azure.com/org/project", "dev.azure.com/org/project"},
+	}
+	for _, test := range tests {
+		got := MakeURI(test.cloneURL)
+		if test.want != got {
+			t.Errorf("%s: want URI %s, got %s", test.cloneURL, test.want, got)
+		}
+		if got, want := MakeURI(got.CloneURL()), test.want; got != want {
+			t.Errorf("%s: clone URL %s has URI %s, want %s", test.cloneURL, test.want.CloneURL(), got, want)
+		}
+	}
+}
+
+func TestParseCloneURL_invalid(t *testing.T) {
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	defer os.Setenv("AWS_DEFAULT_REGION", os.Getenv("AWS_DEFAULT_REGION"))
+	os.Setenv("AWS_REGION", "")
+	os.Setenv("AWS_DEFAULT_REGION", "")
+
+	for _, cloneURL := range []string{
+		"",
+		"codecommit://MyRepo", // no region
+		"codecommit::us-east-1:MyRepo",
+		"codecommit::us-east-1://a/b",
+		"http://[::1",
+	} {
+		if uri, err := ParseCloneURL(cloneURL); err == nil {
+			t.Errorf("%q: got URI %s, want error", cloneURL, uri)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// A HostKind is the kind of software that a code host runs. It determines
+// how the host's URLs map to repository URIs.
+type HostKind string
+
+const (
+	// GitHubHost is a GitHub Enterprise host, whose repositories are at
+	// OWNER/REPO.
+	GitHubHost HostKind = "github"
+
+	// GitLabHost is a GitLab host, whose repositories are at GROUP/REPO
+	// (with any number of subgroups, as in GROUP/SUBGROUP/REPO).
+	GitLabHost HostKind = "gitlab"
+
+	// GiteaHost is a Gitea (or Gogs) host, whose repositories are at
+	// OWNER/REPO.
+	GiteaHost HostKind = "gitea"
+
+	// BitbucketServerHost is a Bitbucket Server host, whose repositories
+	// are cloned from scm/PROJECT/REPO and browsed at
+	// projects/PROJECT/repos/REPO.
+	BitbucketServerHost HostKind = "bitbucket-server"
+)
+
+// A Host is a self-hosted code host. MakeURI normalizes all of the URLs
+// of a repository on the host (HTTP(S) and SSH clone URLs and web URLs) to
+// the same URI: the hostname and path of the host's URL, followed by the
+// repository's path on the host (e.g., "gitlab.example.com/group/repo").
+type Host struct {
+	// Kind is the kind of software that the host runs.
+	Kind HostKind
+
+	// URL is the base URL of the host's web interface and HTTP(S) clone
+	// URLs (e.g., "https://gitlab.example.com" or
+	// "https://example.com/gitlab").
+	URL string
+
+	// Aliases are other hostnames that the host's clone URLs use, such
+	// as a separate SSH hostname. An alias with a port (e.g.,
+	// "ssh.example.com:7999") only matches URLs with that port.
+	Aliases []string `json:",omitempty"`
+}
+
+// Hosts are the self-hosted code hosts that MakeURI and URI.CloneURL
+// recognize.
+var Hosts []*Host
+
+// Validate returns an error if h is invalid.
+func (h *Host) Validate() error {
+	switch h.Kind {
+	case GitHubHost, GitLabHost, GiteaHost, BitbucketServerHost:
+	default:
+		return fmt.Errorf("host %s has unknown kind %q (must be %q, %q, %q, or %q)", h.URL, h.Kind, GitHubHost, GitLabHost, GiteaHost, BitbucketServerHost)
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("host has invalid URL %q: %s", h.URL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("host URL %q must be an absolute http or https URL", h.URL)
+	}
+	return nil
+}
+
+// uriPrefix returns the prefix of the URIs of repositories on h.
+func (h *Host) uriPrefix() string {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname()) + strings.TrimSuffix(u.Path, "/")
+}
+
+// matches returns whether u is a URL on h. HTTP(S) URLs must be under the
+// path of h's URL, but SSH URLs needn't be.
+func (h *Host) matches(u *url.URL) bool {
+	if base, err := url.Parse(h.URL); err == nil && strings.EqualFold(base.Hostname(), u.Hostname()) {
+		bp := strings.TrimSuffix(base.Path, "/")
+		return bp == "" || (u.Scheme != "http" && u.Scheme != "https") || pathHasPrefix(u.Path, bp)
+	}
+	for _, alias := range h.Aliases {
+		if strings.Contains(alias, ":") {
+			if strings.EqualFold(alias, u.Host) {
+				return true
+			}
+		} else if strings.EqualFold(alias, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoPath returns the path of the repository (e.g., "/group/repo") that
+// the URL path p (whose ".git" suffix is already removed) refers to.
+func (h *Host) repoPath(p string) string {
+	if base, err := url.Parse(h.URL); err == nil {
+		if bp := strings.TrimSuffix(base.Path, "/"); bp != "" && pathHasPrefix(p, bp) {
+			p = strings.TrimPrefix(p, bp)
+		}
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	switch h.Kind {
+	case GitLabHost:
+		// Web URLs put the page after a "-" (e.g.,
+		// GROUP/REPO/-/tree/master).
+		for i, part := range parts {
+			if part == "-" {
+				parts = parts[:i]
+				break
+			}
+		}
+	case BitbucketServerHost:
+		if len(parts) >= 4 && parts[0] == "projects" && parts[2] == "repos" {
+			parts = []string{parts[1], parts[3]}
+		} else if len(parts) > 0 && parts[0] == "scm" {
+			parts = parts[1:]
+		}
+		if len(parts) > 2 {
+			parts = parts[:2]
+		}
+		// Project keys are uppercase in web URLs but lowercase in clone
+		// URLs.
+		for i := range parts {
+			parts[i] = strings.ToLower(parts[i])
+		}
+	default:
+		if len(parts) > 2 {
+			parts = parts[:2]
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// cloneURL returns the HTTP(S) clone URL of the repository at repoPath
+// (e.g., "/group/repo") on h.
+func (h *Host) cloneURL(repoPath string) string {
+	base := strings.TrimSuffix(h.URL, "/")
+	if h.Kind == BitbucketServerHost {
+		return base + "/scm" + repoPath + ".git"
+	}
+	return base + repoPath
+}
+
+// lookupHost returns the host in Hosts that u is on, or nil if there is
+// none.
+func lookupHost(u *url.URL) *Host {
+	for _, h := range Hosts {
+		if h.matches(u) {
+			return h
+		}
+	}
+	return nil
+}
+
+// CloneURL returns the URL used to clone the repository. For repositories
+// on Hosts, it is the host's HTTP(S) clone URL; otherwise it is
+// "https://" + u.
+func (u URI) CloneURL() string {
+	for _, h := range Hosts {
+		prefix := h.uriPrefix()
+		if s := string(u); prefix != "" && strings.HasPrefix(strings.ToLower(s), prefix+"/") {
+			return h.cloneURL(path.Clean(s[len(prefix):]))
+		}
+	}
+	return "https://" + string(u)
+}
+
+// scpURL matches scp-like SSH clone URLs (e.g.,
+// "git@example.com:user/repo.git").
+var scpURL = regexp.MustCompile(`^([a-zA-Z0-9_]+)@([a-zA-Z0-9._-]+):(.*)$`)
+
+// scpToURL converts an scp-like SSH clone URL to an ssh:// URL, so that it
+// can be parsed. Other URLs are returned unchanged.
+func scpToURL(cloneURL string) string {
+	if strings.Contains(cloneURL, "://") {
+		return cloneURL
+	}
+	if m := scpURL.FindStringSubmatch(cloneURL); m != nil {
+		return "ssh://" + m[1] + "@" + m[2] + "/" + strings.TrimPrefix(m[3], "/")
+	}
+	return cloneURL
+}
@@ -0,0 +1,100 @@
+package repo
+
+import "testing"
+
+var testHosts = []*Host{
+	{Kind: GitLabHost, URL: "https://gitlab.example.com", Aliases: []string{"ssh.gitlab.example.com"}},
+	{Kind: GitLabHost, URL: "https://example.com/gitlab/"},
+	{Kind: GiteaHost, URL: "http://gitea.example.com:3000"},
+	{Kind: BitbucketServerHost, URL: "https://bitbucket.example.com", Aliases: []string{"bitbucket.example.com:7999"}},
+	{Kind: GitHubHost, URL: "https://ghe.example.com"},
+}
+
+func TestMakeURI_hosts(t *testing.T) {
+	defer func(orig []*Host) { Hosts = orig }(Hosts)
+	Hosts = testHosts
+
+	tests := []struct {
+		cloneURL string
+		want     URI
+	}{
+		// GitLab (with subgroups, SSH ports, and web URLs)
+		{"https://gitlab.example.com/group/repo.git", "gitlab.example.com/group/repo"},
+		{"https://gitlab.example.com:8443/group/sub/repo.git", "gitlab.example.com/group/sub/repo"},
+		{"git@gitlab.example.com:group/sub/repo.git", "gitlab.example.com/group/sub/repo"},
+		{"ssh://git@ssh.gitlab.example.com:2222/group/sub/repo.git", "gitlab.example.com/group/sub/repo"},
+		{"https://gitlab.example.com/group/sub/repo/-/tree/master/dir", "gitlab.example.com/group/sub/repo"},
+		{"https://example.com/gitlab/group/repo.git", "example.com/gitlab/group/repo"},
+		{"git@example.com:group/repo.git", "example.com/gitlab/group/repo"},
+
+		// Gitea
+		{"http://gitea.example.com:3000/owner/repo.git", "gitea.example.com/owner/repo"},
+		{"ssh://git@gitea.example.com:2222/owner/repo.git", "gitea.example.com/owner/repo"},
+		{"http://gitea.example.com:3000/owner/repo/src/branch/master", "gitea.example.com/owner/repo"},
+
+		// Bitbucket Server
+		{"https://bitbucket.example.com/scm/proj/repo.git", "bitbucket.example.com/proj/repo"},
+		{"ssh://git@bitbucket.example.com:7999/proj/repo.git", "bitbucket.example.com/proj/repo"},
+		{"https://bitbucket.example.com/projects/PROJ/repos/repo/browse", "bitbucket.example.com/proj/repo"},
+		{"https://bitbucket.example.com/scm/~user/repo.git", "bitbucket.example.com/~user/repo"},
+
+		// GitHub Enterprise
+		{"git@ghe.example.com:owner/repo.git", "ghe.example.com/owner/repo"},
+		{"https://ghe.example.com/owner/repo/blob/master/README.md", "ghe.example.com/owner/repo"},
+
+		// Other hosts are unaffected.
+		{"https://github.com/user/repo", "github.com/user/repo"},
+		{"git@github.com:user/repo.git", "github.com/user/repo"},
+		{"https://other.example.com:8443/a/b/c", "other.example.com:8443/a/b/c"},
+	}
+	for _, test := range tests {
+		got := MakeURI(test.cloneURL)
+		if test.want != got {
+			t.Errorf("%s: want URI %s, got %s", test.cloneURL, test.want, got)
+		}
+	}
+}
+
+func TestURI_CloneURL(t *testing.T) {
+	defer func(orig []*Host) { Hosts = orig }(Hosts)
+	Hosts = testHosts
+
+	tests := []struct {
+		uri  URI
+		want string
+	}{
+		{"gitlab.example.com/group/sub/repo", "https://gitlab.example.com/group/sub/repo"},
+		{"example.com/gitlab/group/repo", "https://example.com/gitlab/group/repo"},
+		{"gitea.example.com/owner/repo", "http://gitea.example.com:3000/owner/repo"},
+		{"bitbucket.example.com/proj/repo", "https://bitbucket.example.com/scm/proj/repo.git"},
+		{"github.com/user/repo", "https://github.com/user/repo"},
+		{"example.com/other/repo", "https://example.com/other/repo"},
+	}
+	for _, test := range tests {
+		got := test.uri.CloneURL()
+		if got != test.want {
+			t.Errorf("%s: want clone URL %s, got %s", test.uri, test.want, got)
+		}
+		if uri := MakeURI(got); uri != test.uri {
+			t.Errorf("%s: clone URL %s has URI %s", test.uri, got, uri)
+		}
+	}
+}
+
+func TestHost_Validate(t *testing.T) {
+	tests := []struct {
+		host    Host
+		wantErr bool
+	}{
+		{Host{Kind: GitLabHost, URL: "https://gitlab.example.com"}, false},
+		{Host{Kind: "svn", URL: "https://svn.example.com"}, true},
+		{Host{Kind: GiteaHost, URL: "gitea.example.com"}, true},
+		{Host{Kind: GiteaHost, URL: "ssh://gitea.example.com"}, true},
+	}
+	for _, test := range tests {
+		err := test.host.Validate()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%+v: got error %v, want error %v", test.host, err, test.wantErr)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package repo
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
+	"log"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -29,21 +31,74 @@ func (u URI) GitHubURL() string {
 
 // MakeURI converts a repository clone URL, such as
 // "git://github.com/user/repo.git", to a normalized URI string, such as
-// "github.com/user/repo".
+// "github.com/user/repo". It panics if cloneURL is invalid.
+//
+// Clone URLs and web URLs of repositories on self-hosted code hosts (in
+// Hosts), Azure DevOps, and AWS CodeCommit are normalized to the same URI
+// (see Host).
+//
+// If Vanity is set, a clone URL with no scheme is treated as a Go import
+// path, and if it is a vanity import path (such as "mycorp.dev/x"), the
+// URI of the repository that it resolves to is returned.
 func MakeURI(cloneURL string) URI {
+	uri, err := ParseCloneURL(cloneURL)
+	if err != nil {
+		panic(fmt.Sprintf("MakeURI(%q): %s", cloneURL, err))
+	}
+	return uri
+}
+
+// ParseCloneURL is like MakeURI, but it returns an error (instead of
+// panicking) if cloneURL is invalid.
+func ParseCloneURL(cloneURL string) (URI, error) {
 	if cloneURL == "" {
-		panic("MakeURI: empty clone URL")
+		return "", errors.New("empty clone URL")
+	}
+	cloneURL = scpToURL(cloneURL)
+	u, err := makeURI(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	if Vanity != nil && !strings.Contains(cloneURL, "://") && !strings.HasPrefix(cloneURL, "codecommit:") {
+		root, cached, err := Vanity.resolve(string(u))
+		if err != nil {
+			if !cached {
+				log.Printf("Warning: failed to resolve Go import path %q: %s.", u, err)
+			}
+		} else if root != nil {
+			return makeURI(root.CloneURL)
+		}
+	}
+	return u, nil
+}
+
+func makeURI(cloneURL string) (URI, error) {
+	if uri, ok, err := codeCommitRemoteURI(cloneURL); ok {
+		return uri, err
 	}
 
 	url, err := url.Parse(cloneURL)
 	if err != nil {
-		panic(fmt.Sprintf("MakeURI(%q): %s", cloneURL, err))
+		return "", err
 	}
 
 	path := strings.TrimSuffix(url.Path, ".git")
 	path = filepath.Clean(path)
 	path = strings.TrimSuffix(path, "/")
-	return URI(strings.ToLower(url.Host) + path)
+	if h := lookupHost(url); h != nil {
+		return URI(h.uriPrefix() + h.repoPath(path)), nil
+	}
+	host, hostPath := url.Hostname(), path
+	if url.Scheme == "" {
+		// Go import paths have no scheme, but they begin with the host.
+		if i := strings.Index(path, "/"); i > 0 {
+			host, hostPath = path[:i], path[i:]
+		}
+	}
+	if uri, ok := cloudURI(host, hostPath); ok {
+		return uri, nil
+	}
+	return URI(strings.ToLower(url.Host) + path), nil
 }
 
 // URIEqual returns true if a and b are equal, based on a case insensitive
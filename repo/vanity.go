@@ -0,0 +1,332 @@
+package repo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/network"
+)
+
+// VanityOfflineEnv is the environment variable that, if set, prevents
+// vanity import paths from being resolved over the network. Only
+// overrides and previously cached resolutions are used.
+const VanityOfflineEnv = "SRCLIB_VANITY_OFFLINE"
+
+// DefaultVanityCacheTTL is how long a VanityResolver uses a cached
+// resolution before fetching it again, if its CacheTTL is zero.
+const DefaultVanityCacheTTL = 24 * time.Hour
+
+// Vanity resolves vanity Go import paths (such as "mycorp.dev/x") in
+// MakeURI, so that refs to packages with custom import paths point to the
+// repositories that contain them. If it is nil (the default), MakeURI
+// doesn't resolve vanity import paths.
+var Vanity *VanityResolver
+
+// An ImportRoot is the repository that a Go import path prefix maps to.
+type ImportRoot struct {
+	// Prefix is the import path prefix (e.g., "mycorp.dev/x") that
+	// corresponds to the root of the repository.
+	Prefix string
+
+	// VCS is the repository's VCS type (e.g., "git"), if known.
+	VCS string `json:",omitempty"`
+
+	// CloneURL is the URL used to clone the repository.
+	CloneURL string
+
+	// Fetched is when the import root was fetched. It is zero for import
+	// roots that come from overrides.
+	Fetched time.Time `json:",omitempty"`
+}
+
+// A VanityResolver resolves vanity Go import paths to repositories using
+// the go-import meta tags that their hosts serve (see "go help
+// importpath"), in the same way that "go get" does.
+type VanityResolver struct {
+	// Overrides maps import path prefixes to clone URLs. They take
+	// precedence over the network, so they can be used to resolve
+	// import paths whose hosts are unreachable.
+	Overrides map[string]string
+
+	// Client is the HTTP client used to fetch go-import meta tags. If
+	// nil, network.Client (with a timeout of ExternalHostTimeout) is used.
+	Client *http.Client
+
+	// CacheFile, if set, is a file in which resolved import roots are
+	// cached between runs.
+	CacheFile string
+
+	// CacheTTL is how long cached import roots are used. If zero,
+	// DefaultVanityCacheTTL is used.
+	CacheTTL time.Duration
+
+	// Offline is whether to only use overrides and cached import roots.
+	Offline bool
+
+	mu     sync.Mutex
+	loaded bool                   // whether CacheFile has been read
+	roots  map[string]*ImportRoot // cached import roots, by prefix
+	failed map[string]error       // import paths that failed to resolve
+}
+
+// NewVanityResolver creates a VanityResolver that reads overrides from
+// overridesFile (a JSON object mapping import path prefixes to clone URLs)
+// and caches resolutions in cacheFile. Either file may be empty to disable
+// it. It is offline if the VanityOfflineEnv environment variable is set.
+func NewVanityResolver(overridesFile, cacheFile string) (*VanityResolver, error) {
+	r := &VanityResolver{CacheFile: cacheFile, Offline: os.Getenv(VanityOfflineEnv) != ""}
+	if overridesFile != "" {
+		data, err := ioutil.ReadFile(overridesFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &r.Overrides); err != nil {
+				return nil, fmt.Errorf("invalid vanity import overrides file %s: %s", overridesFile, err)
+			}
+			for prefix, cloneURL := range r.Overrides {
+				// Check the URL, since MakeURI panics if it's invalid.
+				if _, err := makeURI(cloneURL); err != nil || cloneURL == "" {
+					return nil, fmt.Errorf("invalid clone URL %q for %s in vanity import overrides file %s", cloneURL, prefix, overridesFile)
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// Resolve returns the import root of the repository that contains the
+// package with the given import path. If importPath isn't a vanity import
+// path (e.g., it is on a well-known host such as github.com, or it can't
+// be resolved offline), it returns nil.
+func (r *VanityResolver) Resolve(importPath string) (*ImportRoot, error) {
+	root, _, err := r.resolve(importPath)
+	return root, err
+}
+
+// resolve is like Resolve, but it also returns whether the result was
+// cached (so that callers can report each failure only once).
+func (r *VanityResolver) resolve(importPath string) (*ImportRoot, bool, error) {
+	importPath = strings.TrimSuffix(importPath, "/")
+	if !isVanityImportPath(importPath) {
+		return nil, false, nil
+	}
+	if root := matchOverride(r.Overrides, importPath); root != nil {
+		return root, false, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.loaded {
+		r.loadCache()
+	}
+	if err, failed := r.failed[importPath]; failed {
+		return nil, true, err
+	}
+	// Offline, expired import roots are better than none.
+	if root := r.cachedRoot(importPath, r.Offline); root != nil {
+		return root, true, nil
+	}
+	if r.Offline {
+		return nil, false, nil
+	}
+
+	root, err := r.fetch(importPath)
+	if err != nil {
+		r.failed[importPath] = err
+		return nil, false, err
+	}
+	r.roots[root.Prefix] = root
+	if err := r.saveCache(); err != nil {
+		log.Printf("Warning: failed to save vanity import cache %s: %s.", r.CacheFile, err)
+	}
+	return root, false, nil
+}
+
+// cachedRoot returns the cached import root whose prefix contains
+// importPath, or nil if there is none. Unless expired is true, it ignores
+// import roots older than the cache TTL.
+func (r *VanityResolver) cachedRoot(importPath string, expired bool) *ImportRoot {
+	ttl := r.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultVanityCacheTTL
+	}
+	for p := importPath; p != "."; p = path.Dir(p) {
+		if root, ok := r.roots[p]; ok && (expired || time.Since(root.Fetched) < ttl) {
+			return root
+		}
+	}
+	return nil
+}
+
+func (r *VanityResolver) loadCache() {
+	r.loaded = true
+	r.roots = map[string]*ImportRoot{}
+	r.failed = map[string]error{}
+	if r.CacheFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(r.CacheFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &r.roots)
+	}
+	if err != nil {
+		log.Printf("Warning: ignoring vanity import cache %s: %s.", r.CacheFile, err)
+		r.roots = map[string]*ImportRoot{}
+	}
+}
+
+func (r *VanityResolver) saveCache() error {
+	if r.CacheFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.roots, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.CacheFile), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.CacheFile, data, 0644)
+}
+
+// fetch fetches the go-import meta tags for importPath and returns the
+// import root that matches it.
+func (r *VanityResolver) fetch(importPath string) (*ImportRoot, error) {
+	client := r.Client
+	if client == nil {
+		client = network.Client()
+		client.Timeout = ExternalHostTimeout
+	}
+	pageURL := "https://" + importPath + "?go-get=1"
+	resp, err := client.Get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	imports, err := parseMetaGoImports(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", pageURL, err)
+	}
+
+	var match *ImportRoot
+	for _, root := range imports {
+		if root.VCS == "mod" || !pathHasPrefix(importPath, root.Prefix) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%s has multiple go-import meta tags for %s", pageURL, importPath)
+		}
+		match = root
+	}
+	if match == nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: HTTP %s", pageURL, resp.Status)
+		}
+		return nil, fmt.Errorf("%s has no go-import meta tag for %s", pageURL, importPath)
+	}
+	match.Fetched = time.Now()
+	return match, nil
+}
+
+// parseMetaGoImports returns the import roots declared by the go-import
+// meta tags (<meta name="go-import" content="PREFIX VCS CLONEURL">) in the
+// head of an HTML document.
+func parseMetaGoImports(r io.Reader) ([]*ImportRoot, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var imports []*ImportRoot
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				return imports, nil
+			}
+			return nil, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		if f := strings.Fields(attrValue(e.Attr, "content")); len(f) == 3 {
+			imports = append(imports, &ImportRoot{Prefix: f[0], VCS: f[1], CloneURL: f[2]})
+		}
+	}
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// matchOverride returns the import root for the longest prefix of
+// importPath in overrides, or nil if there is none.
+func matchOverride(overrides map[string]string, importPath string) *ImportRoot {
+	for p := importPath; p != "."; p = path.Dir(p) {
+		if cloneURL, ok := overrides[p]; ok {
+			return &ImportRoot{Prefix: p, CloneURL: cloneURL}
+		}
+	}
+	return nil
+}
+
+// knownHosts are hosts whose import paths are the same as their
+// repository URIs, so they needn't be resolved.
+var knownHosts = map[string]struct{}{
+	"github.com":        struct{}{},
+	"bitbucket.org":     struct{}{},
+	"code.google.com":   struct{}{},
+	"launchpad.net":     struct{}{},
+	"hub.jazz.net":      struct{}{},
+	"git.apache.org":    struct{}{},
+	"git.openstack.org": struct{}{},
+}
+
+// isVanityImportPath returns whether importPath may be a vanity import
+// path: its first element must look like a hostname (as in "go get") that
+// isn't one of the knownHosts, Hosts, or cloud code hosts.
+func isVanityImportPath(importPath string) bool {
+	host := strings.SplitN(importPath, "/", 2)[0]
+	if !strings.Contains(host, ".") || strings.Contains(host, ":") {
+		return false
+	}
+	if _, known := knownHosts[strings.ToLower(host)]; known {
+		return false
+	}
+	if _, cloud := cloudURI(host, strings.TrimPrefix(importPath, host)); cloud {
+		return false
+	}
+	return lookupHost(&url.URL{Host: host}) == nil
+}
+
+func pathHasPrefix(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetaGoImports(t *testing.T) {
+	tests := []struct {
+		html string
+		want []*ImportRoot
+	}{
+		{
+			html: `<html><head><meta name="go-import" content="mycorp.dev/x git https://github.com/mycorp/x"></head></html>`,
+			want: []*ImportRoot{{Prefix: "mycorp.dev/x", VCS: "git", CloneURL: "https://github.com/mycorp/x"}},
+		},
+		{
+			html: `<!DOCTYPE html><html><head>
+<meta charset="utf-8">
+<META NAME="go-import" CONTENT="mycorp.dev/x hg https://hg.mycorp.dev/x">
+<meta name="go-import" content="mycorp.dev/x mod https://proxy.mycorp.dev">
+<meta name="go-source" content="mycorp.dev/x _ _ _">
+</head>`,
+			want: []*ImportRoot{
+				{Prefix: "mycorp.dev/x", VCS: "hg", CloneURL: "https://hg.mycorp.dev/x"},
+				{Prefix: "mycorp.dev/x", VCS: "mod", CloneURL: "https://proxy.mycorp.dev"},
+			},
+		},
+		{
+			// Meta tags in the body are ignored.
+			html: `<html><head></head><body><meta name="go-import" content="mycorp.dev/x git https://github.com/mycorp/x"></body></html>`,
+		},
+		{
+			// Malformed contents are ignored.
+			html: `<meta name="go-import" content="mycorp.dev/x git">`,
+		},
+	}
+	for _, test := range tests {
+		got, err := parseMetaGoImports(strings.NewReader(test.html))
+		if err != nil {
+			t.Errorf("%s: %s", test.html, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.html, got, test.want)
+		}
+	}
+}
+
+// newVanityServer starts a server that serves go-import meta tags for
+// pages (keyed by import path), and returns an HTTP client that sends all
+// requests to it. It counts the requests in *n.
+func newVanityServer(t *testing.T, pages map[string]string, n *int) (*httptest.Server, *http.Client) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*n++
+		if r.URL.Query().Get("go-get") != "1" {
+			t.Errorf("request %s lacks go-get=1", r.URL)
+		}
+		content, ok := pages[r.Host+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s"></head></html>`, content)
+	}))
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+	transport.TLSClientConfig.ServerName = "example.com" // the test certificate's name
+	client.Transport = transport
+	return ts, client
+}
+
+func TestVanityResolver_Resolve(t *testing.T) {
+	var n int
+	ts, client := newVanityServer(t, map[string]string{
+		"mycorp.dev/x":     "mycorp.dev/x git https://github.com/mycorp/x",
+		"mycorp.dev/x/sub": "mycorp.dev/x git https://github.com/mycorp/x",
+		"mycorp.dev/y":     "mycorp.dev/other git https://github.com/mycorp/other",
+	}, &n)
+	defer ts.Close()
+	r := &VanityResolver{
+		Client:    client,
+		Overrides: map[string]string{"mycorp.dev/offline": "https://git.mycorp.dev/offline"},
+	}
+
+	tests := []struct {
+		importPath   string
+		wantCloneURL string
+		wantErr      bool
+	}{
+		{importPath: "github.com/user/repo"},
+		{importPath: "localhost/x"},
+		{importPath: "mycorp.dev/x", wantCloneURL: "https://github.com/mycorp/x"},
+		{importPath: "mycorp.dev/x/sub", wantCloneURL: "https://github.com/mycorp/x"},
+		{importPath: "mycorp.dev/offline/a/b", wantCloneURL: "https://git.mycorp.dev/offline"},
+		{importPath: "mycorp.dev/y", wantErr: true},
+		{importPath: "mycorp.dev/404", wantErr: true},
+	}
+	for _, test := range tests {
+		root, err := r.Resolve(test.importPath)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %v", test.importPath, err, test.wantErr)
+			continue
+		}
+		var cloneURL string
+		if root != nil {
+			cloneURL = root.CloneURL
+		}
+		if cloneURL != test.wantCloneURL {
+			t.Errorf("%s: got clone URL %q, want %q", test.importPath, cloneURL, test.wantCloneURL)
+		}
+	}
+
+	// Only mycorp.dev/{x,y,404} should have been fetched; mycorp.dev/x/sub
+	// is in mycorp.dev/x's cached import root.
+	if want := 3; n != want {
+		t.Errorf("got %d requests, want %d", n, want)
+	}
+	if _, err := r.Resolve("mycorp.dev/404"); err == nil || n != 3 {
+		t.Errorf("got error %v after %d requests, want cached error after 3 requests", err, n)
+	}
+}
+
+func TestVanityResolver_cacheFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "srclib-vanity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	cacheFile := filepath.Join(tmpDir, "cache", "vanity-imports.json")
+
+	var n int
+	ts, client := newVanityServer(t, map[string]string{
+		"mycorp.dev/x": "mycorp.dev/x git https://github.com/mycorp/x",
+	}, &n)
+	defer ts.Close()
+	r := &VanityResolver{Client: client, CacheFile: cacheFile}
+	if _, err := r.Resolve("mycorp.dev/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new resolver should use the cache file, even offline.
+	r = &VanityResolver{CacheFile: cacheFile, Offline: true}
+	root, err := r.Resolve("mycorp.dev/x/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == nil || root.CloneURL != "https://github.com/mycorp/x" {
+		t.Errorf("got import root %+v, want cached mycorp.dev/x", root)
+	}
+	if root, err := r.Resolve("mycorp.dev/z"); root != nil || err != nil {
+		t.Errorf("got import root %+v and error %v offline, want neither", root, err)
+	}
+	if n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestMakeURI_vanity(t *testing.T) {
+	defer func(orig *VanityResolver) { Vanity = orig }(Vanity)
+	Vanity = &VanityResolver{
+		Overrides: map[string]string{"mycorp.dev/x": "https://github.com/mycorp/x.git"},
+		Offline:   true,
+	}
+
+	tests := []struct {
+		cloneURL string
+		want     URI
+	}{
+		{"mycorp.dev/x", "github.com/mycorp/x"},
+		{"mycorp.dev/x/sub/pkg", "github.com/mycorp/x"},
+		{"mycorp.dev/unknown", "mycorp.dev/unknown"},
+		{"https://mycorp.dev/x", "mycorp.dev/x"},
+		{"github.com/user/repo", "github.com/user/repo"},
+	}
+	for _, test := range tests {
+		got := MakeURI(test.cloneURL)
+		if test.want != got {
+			t.Errorf("%s: want URI %s, got %s", test.cloneURL, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package report collects information about the toolchain invocations that
+// make up a build (such as the CPU and memory each one used) so that it can be
+// summarized and saved after the build completes.
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+func init() {
+	buildstore.RegisterDataType("resources", &Report{})
+}
+
+// Filename is the name of the file (in the build data directory for a
+// commit) that the Report for the most recent build is written to.
+const Filename = "build.resources.json"
+
+// LogEnv is the environment variable that holds the path of the file that
+// ToolRuns are appended to during a build. If it is empty, no runs are
+// recorded.
+const LogEnv = "SRCLIB_REPORT_LOG"
+
+// A ToolRun records a single execution of a toolchain tool.
+type ToolRun struct {
+	Toolchain string
+	Tool      string
+
+	// UnitName and UnitType identify the source unit that the tool was run
+	// on, if any.
+	UnitName string `json:",omitempty"`
+	UnitType string `json:",omitempty"`
+
+	Start time.Time
+	Wall  time.Duration
+
+	UserTime   time.Duration
+	SystemTime time.Duration
+
+	// MaxRSS is the peak resident set size of the tool process, in bytes.
+	MaxRSS int64
+
+	// Error is the error message if the tool failed.
+	Error string `json:",omitempty"`
+
+	// LogFile is the path of the run's log file (see RunLog), relative to
+	// the build data directory, if one was written.
+	LogFile string `json:",omitempty"`
+}
+
+// SetUsage sets r's resource usage fields from u.
+func (r *ToolRun) SetUsage(u util.ResourceUsage) {
+	r.UserTime = u.UserTime
+	r.SystemTime = u.SystemTime
+	r.MaxRSS = u.MaxRSS
+}
+
+// Append appends r (as a single line of JSON) to the log file.
+func Append(file string, r *ToolRun) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadLog reads all ToolRuns that were appended to the log file. A missing
+// file is treated as an empty log.
+func ReadLog(file string) ([]*ToolRun, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []*ToolRun
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var r *ToolRun
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, s.Err()
+}
+
+// Report summarizes the toolchain invocations of a build.
+type Report struct {
+	// Runs is the list of tool runs, sorted by decreasing MaxRSS.
+	Runs []*ToolRun
+
+	// TotalCPU is the total user+system CPU time of all runs.
+	TotalCPU time.Duration
+
+	// MaxRSS is the largest MaxRSS of any run.
+	MaxRSS int64
+}
+
+// New creates a Report from a list of tool runs.
+func New(runs []*ToolRun) *Report {
+	rep := &Report{Runs: runs}
+	sort.Sort(byMaxRSS(rep.Runs))
+	for _, r := range runs {
+		rep.TotalCPU += r.UserTime + r.SystemTime
+		if r.MaxRSS > rep.MaxRSS {
+			rep.MaxRSS = r.MaxRSS
+		}
+	}
+	return rep
+}
+
+type byMaxRSS []*ToolRun
+
+func (v byMaxRSS) Len() int           { return len(v) }
+func (v byMaxRSS) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byMaxRSS) Less(i, j int) bool { return v[i].MaxRSS > v[j].MaxRSS }
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendAndReadLog(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-report-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	file := filepath.Join(tmpdir, "log")
+
+	runs, err := ReadLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("got %d runs from nonexistent log, want 0", len(runs))
+	}
+
+	want := []*ToolRun{
+		{Toolchain: "tc", Tool: "graph", UnitName: "a", UnitType: "t", MaxRSS: 10, UserTime: time.Second},
+		{Toolchain: "tc", Tool: "graph", UnitName: "b", UnitType: "t", MaxRSS: 30, SystemTime: time.Second},
+	}
+	for _, r := range want {
+		if err := Append(file, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err = ReadLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("got %d runs, want %d", len(runs), len(want))
+	}
+
+	rep := New(runs)
+	if rep.Runs[0].UnitName != "b" {
+		t.Errorf("got first run for unit %q, want the unit with the largest MaxRSS (b)", rep.Runs[0].UnitName)
+	}
+	if rep.MaxRSS != 30 {
+		t.Errorf("got MaxRSS %d, want 30", rep.MaxRSS)
+	}
+	if rep.TotalCPU != 2*time.Second {
+		t.Errorf("got TotalCPU %s, want 2s", rep.TotalCPU)
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogDirEnv is the environment variable that holds the path of the
+// directory that the log of each tool run (see RunLog) is written to during
+// a build. It is the LogsDirName subdirectory of the build data directory. If
+// it is empty, no logs are written.
+const LogDirEnv = "SRCLIB_TOOL_LOG_DIR"
+
+// LogsDirName is the name of the directory (in the build data directory for
+// a commit) that tool run logs are written to.
+const LogsDirName = "logs"
+
+// maxStdoutTail is the number of bytes at the end of a tool's stdout that a
+// RunLog keeps, to write to the log if the tool fails.
+const maxStdoutTail = 64 * 1024
+
+// LogFilename returns the name of the log file for r, relative to the logs
+// directory: UNITNAME/UNITTYPE.TOOL.log for a run on a source unit, and
+// TOOLCHAIN/TOOL.log otherwise.
+func LogFilename(r *ToolRun) string {
+	var name string
+	if r.UnitName != "" {
+		name = fmt.Sprintf("%s/%s.%s.log", r.UnitName, r.UnitType, r.Tool)
+	} else {
+		name = fmt.Sprintf("%s/%s.log", r.Toolchain, r.Tool)
+	}
+	// Cleaning the path from the root keeps it in the logs directory, even
+	// if the unit name contains "..".
+	return strings.TrimPrefix(filepath.Clean("/"+name), "/")
+}
+
+// A RunLog is the log file of a tool run. It records the command and
+// everything the tool wrote to stderr, and, if the tool failed, the end of
+// what it wrote to stdout. (The tool's stdout is its output, which is saved
+// in the build data if the tool succeeds.)
+type RunLog struct {
+	f      *os.File
+	stdout tailBuffer
+}
+
+// CreateRunLog creates (or truncates) the log file for r (see LogFilename)
+// in dir, writes the command line args to it, and sets r.LogFile.
+func CreateRunLog(dir string, r *ToolRun, args []string) (*RunLog, error) {
+	name := LogFilename(r)
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(f, "$ %s\n", strings.Join(args, " ")); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.LogFile = filepath.Join(LogsDirName, name)
+	return &RunLog{f: f}, nil
+}
+
+// Stderr returns a writer that writes the tool's stderr to the log.
+func (l *RunLog) Stderr() io.Writer { return l.f }
+
+// Stdout returns a writer that keeps the end of the tool's stdout, which
+// Close writes to the log if the tool failed.
+func (l *RunLog) Stdout() io.Writer { return &l.stdout }
+
+// Close writes the end of the tool's stdout and runErr to the log if runErr
+// is non-nil, and closes the log.
+func (l *RunLog) Close(runErr error) error {
+	if runErr != nil {
+		if out := l.stdout.Bytes(); len(out) > 0 {
+			fmt.Fprintf(l.f, "--- stdout (last %d bytes) ---\n%s", len(out), out)
+			if out[len(out)-1] != '\n' {
+				fmt.Fprintln(l.f)
+			}
+		}
+		fmt.Fprintf(l.f, "--- failed: %s\n", runErr)
+	}
+	return l.f.Close()
+}
+
+// A tailBuffer is a writer that keeps the last maxStdoutTail bytes written
+// to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	// Let the buffer grow to twice its limit before trimming it, so that
+	// each write doesn't copy the whole buffer.
+	if len(b.buf) > 2*maxStdoutTail {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-maxStdoutTail:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns the bytes kept.
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf) > maxStdoutTail {
+		return b.buf[len(b.buf)-maxStdoutTail:]
+	}
+	return b.buf
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFilename(t *testing.T) {
+	tests := []struct {
+		run  *ToolRun
+		want string
+	}{
+		{&ToolRun{Toolchain: "tc", Tool: "graph", UnitName: "a/b", UnitType: "t"}, "a/b/t.graph.log"},
+		{&ToolRun{Toolchain: "tc", Tool: "graph", UnitName: "../../x", UnitType: "t"}, "x/t.graph.log"},
+		{&ToolRun{Toolchain: "example.com/tc", Tool: "scan"}, "example.com/tc/scan.log"},
+	}
+	for _, test := range tests {
+		if got := LogFilename(test.run); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.run, got, test.want)
+		}
+	}
+}
+
+func TestRunLog(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "srclib-report-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	tests := []struct {
+		runErr error
+		want   string
+	}{
+		{nil, "$ t graph\nwarning\n"},
+		{errors.New("exit status 1"), "$ t graph\nwarning\n--- stdout (last 6 bytes) ---\n{\"a\":\n--- failed: exit status 1\n"},
+	}
+	for _, test := range tests {
+		r := &ToolRun{Toolchain: "tc", Tool: "graph", UnitName: "u", UnitType: "t"}
+		l, err := CreateRunLog(tmpdir, r, []string{"t", "graph"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Stderr().Write([]byte("warning\n"))
+		l.Stdout().Write([]byte(`{"a":`))
+		l.Stdout().Write([]byte("\n"))
+		if err := l.Close(test.runErr); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := filepath.Join(LogsDirName, "u/t.graph.log"); r.LogFile != want {
+			t.Errorf("got LogFile %q, want %q", r.LogFile, want)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(tmpdir, "u/t.graph.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.want {
+			t.Errorf("runErr %v: got log %q, want %q", test.runErr, data, test.want)
+		}
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var b tailBuffer
+	chunk := strings.Repeat("x", 1000)
+	for i := 0; i < 3*maxStdoutTail/len(chunk); i++ {
+		b.Write([]byte(chunk))
+	}
+	b.Write([]byte("end"))
+	got := b.Bytes()
+	if len(got) != maxStdoutTail {
+		t.Errorf("got %d bytes, want %d", len(got), maxStdoutTail)
+	}
+	if !bytes.HasSuffix(got, []byte("xend")) {
+		t.Errorf("got bytes ending in %q, want the last bytes written", got[len(got)-10:])
+	}
+}
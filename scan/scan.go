@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"encoding/json"
 	"fmt"
 	"runtime"
 	"sync"
@@ -8,12 +9,17 @@ import (
 	"code.google.com/p/rog-go/parallel"
 	"sourcegraph.com/sourcegraph/srclib/config"
 	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/schema"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 )
 
 type Options struct {
 	config.Options
+
+	// Validate is how the scanners' output is validated against the scan
+	// output schema.
+	Validate schema.Mode
 }
 
 // ScanMulti runs multiple scanner tools in parallel. It passes command-line
@@ -59,8 +65,20 @@ func Scan(scanner toolchain.Tool, opt Options, treeConfig map[string]interface{}
 		return nil, err
 	}
 
+	var output json.RawMessage
+	if err := scanner.Run(args, treeConfig, &output); err != nil {
+		return nil, err
+	}
+	name := "scanner"
+	if cmd, err := scanner.Command(); err == nil {
+		name = fmt.Sprintf("scanner %v", cmd.Args)
+	}
+	if err := schema.CheckOutput(name, "scan", output, opt.Validate); err != nil {
+		return nil, err
+	}
+
 	var units []*unit.SourceUnit
-	if err := scanner.Run(args, treeConfig, &units); err != nil {
+	if err := json.Unmarshal(output, &units); err != nil {
 		return nil, err
 	}
 
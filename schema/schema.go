@@ -0,0 +1,276 @@
+// Package schema defines JSON Schemas for the input and output of the
+// operations that toolchain tools perform (scan, graph, depresolve, and
+// imports), and validates tool output against them, so that toolchain
+// authors get precise feedback on malformed output instead of obscure
+// decoding errors (or silently missing data) later in the build.
+//
+// The schemas are generated from the Go types that src decodes tool I/O
+// into. Properties that src always writes (that aren't omitted when empty)
+// are required. Because encoding/json is lenient, validation has two
+// levels: the default (used by Warn mode) only reports problems that would
+// cause decoding to fail or data to be lost, such as values of the wrong
+// type or invalid enum values; strict validation also reports missing
+// required properties, unknown properties, and property names that differ
+// in case from the schema's.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/imports"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// Schema is a JSON Schema (draft-07) document.
+type Schema map[string]interface{}
+
+// An op describes the I/O of tools that perform an operation.
+type op struct {
+	input, output interface{} // values of the Go types that are sent and received
+}
+
+var ops = map[string]op{
+	"scan":       {input: map[string]interface{}{}, output: []*unit.SourceUnit{}},
+	"graph":      {input: &unit.SourceUnit{}, output: &grapher.Output{}},
+	"depresolve": {input: &unit.SourceUnit{}, output: []*dep.Resolution{}},
+	"imports":    {input: &unit.SourceUnit{}, output: &imports.Output{}},
+}
+
+// Ops returns the names of the operations that have schemas, sorted.
+func Ops() []string {
+	var names []string
+	for name := range ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Input returns the schema of the input (on stdin) of tools that perform
+// the named operation, or nil if there is no such operation.
+func Input(opName string) Schema {
+	o, present := ops[opName]
+	if !present {
+		return nil
+	}
+	return generate(o.input, opName+" input")
+}
+
+// Output returns the schema of the output (on stdout) of tools that
+// perform the named operation, or nil if there is no such operation.
+func Output(opName string) Schema {
+	o, present := ops[opName]
+	if !present {
+		return nil
+	}
+	return generate(o.output, opName+" output")
+}
+
+// Files returns the schemas of the input and output of all operations,
+// JSON-encoded and keyed by file names of the form OP-input.json and
+// OP-output.json.
+func Files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, name := range Ops() {
+		for dir, s := range map[string]Schema{"input": Input(name), "output": Output(name)} {
+			data, err := json.MarshalIndent(s, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			files[name+"-"+dir+".json"] = append(data, '\n')
+		}
+	}
+	return files, nil
+}
+
+// enums lists the valid values of string types whose values are
+// enumerated. The empty string is valid for all of them, since the fields
+// of these types are omitted or left empty when unset.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(graph.RefKind("")):          refKinds(),
+	reflect.TypeOf(graph.TypeRelationKind("")): {"", string(graph.Implements), string(graph.Extends)},
+	reflect.TypeOf(unit.DefMergePolicy("")):    {"", string(unit.PreferToolchain), string(unit.MergeFields), string(unit.ErrorOnDuplicate)},
+	reflect.TypeOf(unit.FilterAction("")):      {"", string(unit.DropFiltered), string(unit.RedactFiltered)},
+}
+
+func refKinds() []string {
+	kinds := []string{""}
+	for _, k := range graph.RefKinds {
+		kinds = append(kinds, string(k))
+	}
+	return kinds
+}
+
+// generate returns the schema of the JSON encoding of v.
+func generate(v interface{}, title string) Schema {
+	defs := make(map[string]interface{})
+	t := reflect.TypeOf(v)
+	var s map[string]interface{}
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		// Inline the top-level struct (instead of referring to it), so
+		// that the schema's keywords aren't siblings of a $ref.
+		s = structSchema(t.Elem(), defs)
+	} else {
+		s = typeSchema(t, defs)
+	}
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = title
+	if len(defs) > 0 {
+		s["definitions"] = defs
+	}
+	return s
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// typeSchema returns the schema of the JSON encoding of values of type t.
+// Named struct types are added to defs (keyed by their type names) and
+// referred to by reference. Pointers, slices, and maps may be null.
+func typeSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		s := typeSchema(t.Elem(), defs)
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]interface{}{"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}}}
+		}
+		return s
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		// The encoding is custom, so it could be anything.
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		if values, present := enums[t]; present {
+			return map[string]interface{}{"type": "string", "enum": values}
+		}
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": []string{"string", "null"}, "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+		if _, present := defs[t.Name()]; !present {
+			defs[t.Name()] = nil // break cycles
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the schema of the JSON encoding of the struct type
+// t. Fields that aren't omitted when empty are required.
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue // unexported
+			}
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if i := strings.Index(tag, ","); i != -1 {
+				name, opts = tag[:i], tag[i+1:]
+			}
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				addFields(f.Type) // embedded struct's fields are promoted
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = typeSchema(f.Type, defs)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	s := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// Mode is how tool output is validated. The zero value is equivalent to
+// Warn.
+type Mode string
+
+const (
+	// Off disables validation.
+	Off Mode = "off"
+
+	// Warn logs the problems found by (non-strict) validation.
+	Warn Mode = "warn"
+
+	// Strict fails on the problems found by strict validation.
+	Strict Mode = "strict"
+)
+
+// ParseMode parses a validation mode name.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case Off, Warn, Strict:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid validation mode %q (must be off, warn, or strict)", s)
+}
+
+// CheckOutput validates data, the output of the named tool, which performs
+// the operation opName. In Warn mode, problems are logged and nil is
+// returned; in Strict mode, they are returned as an error. Output of
+// operations that have no schema isn't validated.
+func CheckOutput(tool, opName string, data []byte, mode Mode) error {
+	if mode == Off {
+		return nil
+	}
+	s := Output(opName)
+	if s == nil {
+		return nil
+	}
+	err := Validate(s, data, mode == Strict)
+	if err == nil {
+		return nil
+	}
+	if mode == Strict {
+		return fmt.Errorf("output of %s doesn't match the %s output schema: %s", tool, opName, err)
+	}
+	log.Printf("Warning: output of %s doesn't match the %s output schema (use --validate=strict to treat this as an error): %s", tool, opName, err)
+	return nil
+}
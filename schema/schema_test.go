@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestValidate_goOutput(t *testing.T) {
+	// Output written by encoding/json from the Go types should be valid
+	// even in strict mode.
+	outputs := map[string]interface{}{
+		"scan": []*unit.SourceUnit{{Name: "p", Type: "GoPackage", Files: []string{"p.go"}}},
+		"graph": &grapher.Output{
+			Defs: []*graph.Def{{DefKey: graph.DefKey{UnitType: "GoPackage", Unit: "p", Path: "F"}, Name: "F", Kind: "func", File: "p.go", DefStart: 10, DefEnd: 20}},
+			Refs: []*graph.Ref{{DefUnitType: "GoPackage", DefUnit: "p", DefPath: "F", Kind: graph.Call, File: "p.go", Start: 30, End: 31}},
+			Docs: []*graph.Doc{{DefKey: graph.DefKey{Path: "F"}, Format: "text/plain", Data: "F does nothing."}},
+		},
+		"depresolve": []*dep.Resolution{{Raw: map[string]string{"a": "b"}, Error: "x"}},
+	}
+	for op, v := range outputs {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(Output(op), data, true); err != nil {
+			t.Errorf("%s: %s\n%s", op, err, data)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		data string
+
+		wantErr       string // in non-strict mode
+		wantStrictErr string
+	}{
+		{data: `{}`},
+		{data: `{"Defs":null,"Refs":[]}`},
+		{
+			data:          `{"defs":[]}`,
+			wantStrictErr: `/defs: property name should be "Defs"`,
+		},
+		{
+			data:          `{"Foo":1}`,
+			wantStrictErr: `/Foo: unknown property`,
+		},
+		{
+			data:    `{"Defs":{}}`,
+			wantErr: `/Defs: got object, want array or null`,
+		},
+		{
+			data:    `{"Defs":[{"DefStart":"1"}]}`,
+			wantErr: `/Defs/0/DefStart: got string, want integer`,
+		},
+		{
+			data:    `{"Defs":[{"DefStart":1.5}]}`,
+			wantErr: `/Defs/0/DefStart: got number, want integer`,
+		},
+		{
+			data:    `{"Refs":[{"Kind":"bogus"}]}`,
+			wantErr: `/Refs/0/Kind: invalid value "bogus"`,
+		},
+		{
+			data:    `{"Defs":[7]}`,
+			wantErr: `/Defs/0: got integer, want object or null`,
+		},
+		{
+			data:          `{"Docs":[{"Format":"text/plain"}]}`,
+			wantStrictErr: `/Docs/0: missing required property "Data"`,
+		},
+		{
+			data:    `[]`,
+			wantErr: `(root): got array, want object`,
+		},
+		{
+			data:    `{`,
+			wantErr: `unexpected EOF`,
+		},
+	}
+	s := Output("graph")
+	for _, test := range tests {
+		for _, strict := range []bool{false, true} {
+			want := test.wantErr
+			if strict && want == "" {
+				want = test.wantStrictErr
+			}
+			err := Validate(s, []byte(test.data), strict)
+			if want == "" {
+				if err != nil {
+					t.Errorf("%s (strict=%v): got error %q, want no error", test.data, strict, err)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s (strict=%v): got error %v, want it to contain %q", test.data, strict, err, want)
+			}
+		}
+	}
+}
+
+func TestValidate_maxProblems(t *testing.T) {
+	refs := make([]string, MaxProblems+5)
+	for i := range refs {
+		refs[i] = `{"Start":"x"}`
+	}
+	err := Validate(Output("graph"), []byte(`{"Refs":[`+strings.Join(refs, ",")+`]}`), false)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("got error %v, want *ValidationError", err)
+	}
+	if len(verr.Problems) != MaxProblems || verr.Total != MaxProblems+5 {
+		t.Errorf("got %d problems (total %d), want %d (total %d)", len(verr.Problems), verr.Total, MaxProblems, MaxProblems+5)
+	}
+	if want := "(and 5 more)"; !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %q, want suffix %q", err, want)
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	bad := []byte(`{"Defs":{}}`)
+	if err := CheckOutput("t", "graph", bad, Warn); err != nil {
+		t.Errorf("Warn: got error %v, want nil", err)
+	}
+	if err := CheckOutput("t", "graph", bad, Off); err != nil {
+		t.Errorf("Off: got error %v, want nil", err)
+	}
+	if err := CheckOutput("t", "graph", bad, Strict); err == nil {
+		t.Error("Strict: got nil error, want non-nil")
+	}
+	if err := CheckOutput("t", "bogus", bad, Strict); err != nil {
+		t.Errorf("Strict (op with no schema): got error %v, want nil", err)
+	}
+}
+
+// TestFiles checks that the schemas published in the docs are up to date.
+// To update them, run "src toolchain schema -o docs/sources/toolchains/schemas".
+func TestFiles(t *testing.T) {
+	files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join("..", "docs", "sources", "toolchains", "schemas")
+	for name, want := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date", name)
+		}
+	}
+}
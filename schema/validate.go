@@ -0,0 +1,275 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxProblems is the maximum number of problems that a ValidationError
+// lists. (Graph output with a systematic problem may have one per ref.)
+const MaxProblems = 20
+
+// A ValidationError lists the problems found when validating a JSON
+// document against a schema.
+type ValidationError struct {
+	Problems []*Problem // the first MaxProblems problems
+	Total    int        // the total number of problems
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.String())
+	}
+	if omitted := e.Total - len(e.Problems); omitted > 0 {
+		msgs = append(msgs, fmt.Sprintf("(and %d more)", omitted))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// A Problem is a place where a JSON document doesn't match its schema.
+type Problem struct {
+	Path string // JSON Pointer to the value (e.g., "/Defs/3/Kind")
+	Msg  string
+}
+
+func (p *Problem) String() string {
+	path := p.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + p.Msg
+}
+
+// Validate validates the JSON document data against the schema. If strict
+// is false, only problems that would prevent data from being decoded into
+// the Go types the schema was generated from are reported (see the package
+// documentation). If data isn't valid JSON, the syntax error is returned;
+// otherwise, if there are problems, a *ValidationError is returned.
+//
+// Validate supports the subset of JSON Schema that the schemas generated
+// by this package use: type, enum, properties, required,
+// additionalProperties, items, anyOf, and $ref to #/definitions.
+func Validate(s Schema, data []byte, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	vr := &validator{root: s, strict: strict, err: &ValidationError{}}
+	vr.validate(v, s, "")
+	if vr.err.Total > 0 {
+		return vr.err
+	}
+	return nil
+}
+
+type validator struct {
+	root   Schema
+	strict bool
+	err    *ValidationError
+}
+
+func (vr *validator) problem(path, format string, args ...interface{}) {
+	vr.err.Total++
+	if len(vr.err.Problems) < MaxProblems {
+		vr.err.Problems = append(vr.err.Problems, &Problem{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+}
+
+// resolve follows s's $ref, if it has one.
+func (vr *validator) resolve(s map[string]interface{}) map[string]interface{} {
+	for {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return s
+		}
+		defs, _ := vr.root["definitions"].(map[string]interface{})
+		def, _ := defs[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+		if def == nil {
+			return map[string]interface{}{}
+		}
+		s = def
+	}
+}
+
+func (vr *validator) validate(v interface{}, s map[string]interface{}, path string) {
+	s = vr.resolve(s)
+
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		// Validate against the first alternative that allows v's type.
+		var allowed []string
+		for _, alt := range anyOf {
+			alt := vr.resolve(alt.(map[string]interface{}))
+			types := schemaTypes(alt)
+			if types == nil || allowsType(types, v) {
+				vr.validate(v, alt, path)
+				return
+			}
+			allowed = append(allowed, types...)
+		}
+		vr.problem(path, "got %s, want %s", jsonType(v), strings.Join(allowed, " or "))
+		return
+	}
+
+	if types := schemaTypes(s); types != nil && !allowsType(types, v) {
+		vr.problem(path, "got %s, want %s", jsonType(v), strings.Join(types, " or "))
+		return
+	}
+
+	if enum := stringList(s["enum"]); enum != nil {
+		if str, ok := v.(string); ok && !containsString(enum, str) {
+			vr.problem(path, "invalid value %q (must be one of %s)", str, strings.Join(quoteAll(enum), ", "))
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		vr.validateObject(v, s, path)
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				vr.validate(item, items, path+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+}
+
+func (vr *validator) validateObject(v map[string]interface{}, s map[string]interface{}, path string) {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	props, _ := s["properties"].(map[string]interface{})
+	additional, _ := s["additionalProperties"].(map[string]interface{})
+	present := make(map[string]bool)
+	for _, key := range keys {
+		keyPath := path + "/" + escapePointer(key)
+		name, propSchema := matchProperty(props, key)
+		if propSchema == nil {
+			if additional != nil {
+				vr.validate(v[key], additional, keyPath)
+			} else if props != nil && vr.strict {
+				vr.problem(keyPath, "unknown property")
+			}
+			continue
+		}
+		if name != key && vr.strict {
+			vr.problem(keyPath, "property name should be %q", name)
+		}
+		present[name] = true
+		vr.validate(v[key], propSchema.(map[string]interface{}), keyPath)
+	}
+
+	if vr.strict {
+		for _, name := range stringList(s["required"]) {
+			if !present[name] {
+				vr.problem(path, "missing required property %q", name)
+			}
+		}
+	}
+}
+
+// matchProperty returns the name and schema of the property that key
+// refers to. As in encoding/json, keys match property names exactly or
+// (failing that) case-insensitively.
+func matchProperty(props map[string]interface{}, key string) (string, interface{}) {
+	if s, present := props[key]; present {
+		return key, s
+	}
+	for name, s := range props {
+		if strings.EqualFold(name, key) {
+			return name, s
+		}
+	}
+	return "", nil
+}
+
+// schemaTypes returns the types that s allows, or nil if it doesn't
+// constrain the type.
+func schemaTypes(s map[string]interface{}) []string {
+	if t, ok := s["type"].(string); ok {
+		return []string{t}
+	}
+	return stringList(s["type"])
+}
+
+// stringList returns x as a []string if it is a list of strings (as in a
+// generated schema or one decoded from JSON), or nil otherwise.
+func stringList(x interface{}) []string {
+	switch x := x.(type) {
+	case []string:
+		return x
+	case []interface{}:
+		ss := make([]string, 0, len(x))
+		for _, v := range x {
+			if s, ok := v.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+func allowsType(types []string, v interface{}) bool {
+	vt := jsonType(v)
+	for _, t := range types {
+		if t == vt || (t == "number" && vt == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a value decoded with
+// UseNumber.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// escapePointer escapes a JSON Pointer reference token.
+func escapePointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, s2 := range ss {
+		if s2 == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(ss []string) []string {
+	q := make([]string, len(ss))
+	for i, s := range ss {
+		q[i] = strconv.Quote(s)
+	}
+	return q
+}
@@ -0,0 +1,232 @@
+package search
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// A DocIndex is a full-text (inverted) index over the docs and identifiers
+// of a source unit's defs. It lets users find defs by describing them (e.g.,
+// "read a line from a buffered reader") instead of by name.
+//
+// Building a DocIndex is more expensive than building an Index, so it is
+// only built for source units whose Config has FullTextConfigKey set to
+// true.
+type DocIndex struct {
+	Entries []*Entry
+
+	// Lengths holds the number of terms indexed for each entry.
+	Lengths []int
+
+	// Postings maps each term to the entries that contain it, in increasing
+	// order of entry.
+	Postings map[string][]Posting
+}
+
+// A Posting records the number of times a term occurs in an entry.
+type Posting struct {
+	Entry int // index in Entries
+	Freq  int
+}
+
+// FullTextConfigKey is the source unit Config key that enables building a
+// DocIndex for the source unit. It is usually set for all source units by
+// adding it to the Config in the repository's Srcfile.
+const FullTextConfigKey = "FullTextSearch"
+
+// FullTextEnabled reports whether a DocIndex should be built for u.
+func FullTextEnabled(u *unit.SourceUnit) bool {
+	v, _ := u.Config[FullTextConfigKey].(bool)
+	return v
+}
+
+// maxSummaryLen is the maximum length of an entry's Doc summary.
+const maxSummaryLen = 120
+
+// NewDocIndex returns a full-text index of the defs and their docs, which
+// were defined in the source unit u.
+func NewDocIndex(u *unit.SourceUnit, defs []*graph.Def, docs []*graph.Doc) *DocIndex {
+	// Use one doc per def, preferring plain text.
+	defDocs := make(map[graph.DefPath]*graph.Doc, len(docs))
+	for _, doc := range docs {
+		if d, present := defDocs[doc.Path]; !present || (d.Format != "text/plain" && doc.Format == "text/plain") {
+			defDocs[doc.Path] = doc
+		}
+	}
+
+	ix := &DocIndex{Postings: make(map[string][]Posting)}
+	for _, def := range defs {
+		e := newEntry(u, def)
+
+		// Weight the def's name more heavily than its path and doc.
+		terms := tokenize(def.Name)
+		terms = append(terms, terms...)
+		terms = append(terms, tokenize(string(def.Path))...)
+		if doc := defDocs[def.Path]; doc != nil {
+			text := docText(doc)
+			terms = append(terms, tokenize(text)...)
+			e.Doc = summary(text)
+		}
+
+		i := len(ix.Entries)
+		ix.Entries = append(ix.Entries, e)
+		ix.Lengths = append(ix.Lengths, len(terms))
+
+		freqs := make(map[string]int)
+		for _, t := range terms {
+			freqs[t]++
+		}
+		for t, n := range freqs {
+			ix.Postings[t] = append(ix.Postings[t], Posting{Entry: i, Freq: n})
+		}
+	}
+	return ix
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// docText returns the plain text of doc.
+func docText(doc *graph.Doc) string {
+	if doc.Format == "text/html" {
+		return html.UnescapeString(htmlTag.ReplaceAllString(doc.Data, ""))
+	}
+	return doc.Data
+}
+
+// summary returns the first sentence of text, truncated to maxSummaryLen.
+func summary(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if i := strings.Index(text, ". "); i != -1 {
+		text = text[:i+1]
+	}
+	if len(text) > maxSummaryLen {
+		text = text[:maxSummaryLen-3] + "..."
+	}
+	return text
+}
+
+// stopWords are common words that aren't indexed.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "if": true, "in": true,
+	"is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "with": true,
+}
+
+// tokenize splits text into lowercased terms. Identifiers are split into
+// their parts (e.g., "NewReader" and "new_reader" yield "new" and "reader")
+// and are also indexed whole.
+func tokenize(text string) []string {
+	var terms []string
+	add := func(t string) {
+		t = stem(strings.ToLower(t))
+		if t != "" && !stopWords[t] {
+			terms = append(terms, t)
+		}
+	}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		parts := splitIdent(word)
+		for _, p := range parts {
+			add(p)
+		}
+		if len(parts) > 1 {
+			add(strings.Replace(word, "_", "", -1))
+		}
+	}
+	return terms
+}
+
+// splitIdent splits an identifier at underscores and lower-to-upper case
+// transitions (e.g., "parseHTTPRequest" yields "parse", "HTTP", and
+// "Request").
+func splitIdent(s string) []string {
+	var parts []string
+	for _, w := range strings.Split(s, "_") {
+		rs := []rune(w)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			if unicode.IsUpper(rs[i]) && (unicode.IsLower(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1]))) {
+				parts = append(parts, string(rs[start:i]))
+				start = i
+			}
+		}
+		if start < len(rs) {
+			parts = append(parts, string(rs[start:]))
+		}
+	}
+	return parts
+}
+
+// stem removes a plural "s" suffix from t, so that (e.g.) "reads" and
+// "read" are the same term.
+func stem(t string) string {
+	if len(t) > 3 && strings.HasSuffix(t, "s") && !strings.HasSuffix(t, "ss") {
+		return t[:len(t)-1]
+	}
+	return t
+}
+
+// BM25 ranking parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// SearchDocs returns the entries in the indexes that match the query (a
+// description or keywords), ranked by relevance (using BM25). If limit is
+// positive, at most limit results are returned.
+func SearchDocs(indexes []*DocIndex, query string, limit int) []*Result {
+	// Compute corpus statistics over all of the indexes.
+	var n, totalLen int
+	df := make(map[string]int)
+	terms := tokenize(query)
+	for _, ix := range indexes {
+		n += len(ix.Entries)
+		for _, l := range ix.Lengths {
+			totalLen += l
+		}
+		for _, t := range terms {
+			df[t] += len(ix.Postings[t])
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	avgLen := float64(totalLen) / float64(n)
+
+	var results []*Result
+	for _, ix := range indexes {
+		scores := make(map[int]float64)
+		seen := make(map[string]bool)
+		for _, t := range terms {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			idf := math.Log(1 + (float64(n)-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			for _, p := range ix.Postings[t] {
+				f := float64(p.Freq)
+				norm := 1 - bm25B + bm25B*float64(ix.Lengths[p.Entry])/avgLen
+				scores[p.Entry] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+			}
+		}
+		for i, score := range scores {
+			results = append(results, &Result{Entry: ix.Entries[i], Score: score})
+		}
+	}
+
+	sort.Sort(resultsByScore(results))
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestSplitIdent(t *testing.T) {
+	tests := map[string][]string{
+		"NewReader":        {"New", "Reader"},
+		"parseHTTPRequest": {"parse", "HTTP", "Request"},
+		"read_line":        {"read", "line"},
+		"x":                {"x"},
+	}
+	for ident, want := range tests {
+		if got := splitIdent(ident); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %q, want %q", ident, got, want)
+		}
+	}
+}
+
+func TestSearchDocs(t *testing.T) {
+	u := &unit.SourceUnit{Type: "t", Name: "u"}
+	defs := []*graph.Def{
+		{DefKey: graph.DefKey{Path: "bufio/Reader/ReadLine"}, Name: "ReadLine"},
+		{DefKey: graph.DefKey{Path: "bufio/Reader/ReadByte"}, Name: "ReadByte"},
+		{DefKey: graph.DefKey{Path: "os/Open"}, Name: "Open"},
+	}
+	docs := []*graph.Doc{
+		{DefKey: graph.DefKey{Path: "bufio/Reader/ReadLine"}, Format: "text/plain", Data: "ReadLine is a low-level line-reading primitive. Most callers should use ReadString."},
+		{DefKey: graph.DefKey{Path: "bufio/Reader/ReadByte"}, Format: "text/html", Data: "<p>ReadByte reads and returns a single byte.</p>"},
+		{DefKey: graph.DefKey{Path: "os/Open"}, Format: "text/plain", Data: "Open opens the named file for reading."},
+	}
+	ix := NewDocIndex(u, defs, docs)
+
+	if got, want := ix.Entries[0].Doc, "ReadLine is a low-level line-reading primitive."; got != want {
+		t.Errorf("got doc summary %q, want %q", got, want)
+	}
+	if got, want := ix.Entries[1].Doc, "ReadByte reads and returns a single byte."; got != want {
+		t.Errorf("got HTML doc summary %q, want %q", got, want)
+	}
+
+	tests := map[string][]graph.DefPath{
+		"reading lines":    {"bufio/Reader/ReadLine", "os/Open"},
+		"single byte":      {"bufio/Reader/ReadByte"},
+		"open a file":      {"os/Open"},
+		"read":             {"bufio/Reader/ReadByte", "bufio/Reader/ReadLine"},
+		"the":              nil,
+		"nonexistentthing": nil,
+	}
+	for q, want := range tests {
+		var got []graph.DefPath
+		for _, r := range SearchDocs([]*DocIndex{ix}, q, 0) {
+			got = append(got, r.Path)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", q, got, want)
+		}
+	}
+}
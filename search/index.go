@@ -0,0 +1,256 @@
+// Package search provides fuzzy search over the names and paths of defs. A
+// trigram index of each source unit's defs is built during `src make`, so
+// that searches don't need to load the full graph output.
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// An Entry is a def in a search index.
+type Entry struct {
+	Name     string
+	Path     graph.DefPath
+	Kind     graph.DefKind `json:",omitempty"`
+	UnitType string
+	Unit     string
+	File     string `json:",omitempty"`
+	DefStart int    `json:",omitempty"`
+	DefEnd   int    `json:",omitempty"`
+	Exported bool   `json:",omitempty"`
+
+	// QualifiedName is the def's scope-qualified name (see
+	// graph.ScopeQualified), formatted for its language, if it differs from
+	// Name.
+	QualifiedName string `json:",omitempty"`
+
+	// Doc is a summary of the def's documentation. It is only set in
+	// entries of a DocIndex.
+	Doc string `json:",omitempty"`
+}
+
+// newEntry returns an entry for def, which was defined in the source unit
+// u.
+func newEntry(u *unit.SourceUnit, def *graph.Def) *Entry {
+	e := &Entry{
+		Name:     def.Name,
+		Path:     def.Path,
+		Kind:     def.Kind,
+		UnitType: def.UnitType,
+		Unit:     def.Unit,
+		File:     def.File,
+		DefStart: def.DefStart,
+		DefEnd:   def.DefEnd,
+		Exported: def.Exported,
+	}
+	if e.UnitType == "" && e.Unit == "" {
+		e.UnitType, e.Unit = u.Type, u.Name
+	}
+	d := *def
+	d.UnitType, d.Unit = e.UnitType, e.Unit
+	if name := graph.FormatterFor(&d).Name(graph.ScopeQualified); name != e.Name {
+		e.QualifiedName = name
+	}
+	return e
+}
+
+// An Index is a trigram index over the names and paths of a source unit's
+// defs.
+type Index struct {
+	Entries []*Entry
+
+	// Trigrams maps each trigram of an entry's (lowercased) name or path to
+	// the indexes (in Entries) of the entries that contain it, in increasing
+	// order.
+	Trigrams map[string][]int
+
+	// Prefixes maps the first character of each entry's (lowercased) name to
+	// the indexes of the entries whose names start with it, in increasing
+	// order. It is used to find candidates for short and abbreviated queries
+	// (such as "nwrd" for "NewReader"), which may not share any trigrams with
+	// the names they match.
+	Prefixes map[string][]int
+}
+
+// NewIndex returns an index of the defs, which were defined in the source
+// unit u.
+func NewIndex(u *unit.SourceUnit, defs []*graph.Def) *Index {
+	ix := &Index{Trigrams: make(map[string][]int), Prefixes: make(map[string][]int)}
+	for _, def := range defs {
+		e := newEntry(u, def)
+		i := len(ix.Entries)
+		ix.Entries = append(ix.Entries, e)
+
+		if e.Name != "" {
+			p := strings.ToLower(e.Name[:1])
+			ix.Prefixes[p] = append(ix.Prefixes[p], i)
+		}
+
+		seen := make(map[string]bool)
+		for _, s := range []string{e.Name, string(e.Path)} {
+			for _, t := range trigrams(strings.ToLower(s)) {
+				if !seen[t] {
+					seen[t] = true
+					ix.Trigrams[t] = append(ix.Trigrams[t], i)
+				}
+			}
+		}
+	}
+	return ix
+}
+
+// trigrams returns the trigrams of s (with duplicates).
+func trigrams(s string) []string {
+	if len(s) < 3 {
+		return nil
+	}
+	ts := make([]string, 0, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		ts = append(ts, s[i:i+3])
+	}
+	return ts
+}
+
+// uniqueTrigrams returns the trigrams of s (without duplicates).
+func uniqueTrigrams(s string) []string {
+	seen := make(map[string]bool)
+	var ts []string
+	for _, t := range trigrams(s) {
+		if !seen[t] {
+			seen[t] = true
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// A Result is an entry that matches a query.
+type Result struct {
+	*Entry
+
+	// Score is how well the entry matches the query. Higher is better.
+	Score float64
+}
+
+// Search returns the entries in the indexes that match the query, in order
+// of decreasing score. If limit is positive, at most limit results are
+// returned.
+func Search(indexes []*Index, query string, limit int) []*Result {
+	q := strings.ToLower(query)
+	var results []*Result
+	for _, ix := range indexes {
+		for _, i := range ix.candidates(q) {
+			e := ix.Entries[i]
+			if score := matchScore(e, q); score > 0 {
+				results = append(results, &Result{Entry: e, Score: score})
+			}
+		}
+	}
+	sort.Sort(resultsByScore(results))
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// candidates returns the indexes of the entries that might match q (which
+// is lowercased): those that share a trigram with q or whose names start with
+// the same character as q.
+func (ix *Index) candidates(q string) []int {
+	if q == "" {
+		return nil
+	}
+	seen := make(map[int]bool)
+	var cands []int
+	add := func(is []int) {
+		for _, i := range is {
+			if !seen[i] {
+				seen[i] = true
+				cands = append(cands, i)
+			}
+		}
+	}
+	for _, t := range trigrams(q) {
+		add(ix.Trigrams[t])
+	}
+	add(ix.Prefixes[q[:1]])
+	sort.Ints(cands)
+	return cands
+}
+
+// matchScore returns how well e matches q (which is lowercased), or 0 if it
+// doesn't match.
+func matchScore(e *Entry, q string) float64 {
+	name, path := strings.ToLower(e.Name), strings.ToLower(string(e.Path))
+
+	var score float64
+	switch {
+	case name == q:
+		score = 100
+	case strings.HasPrefix(name, q):
+		score = 80
+	case strings.Contains(name, q):
+		score = 60
+	case name != "" && name[0] == q[0] && isSubsequence(q, name):
+		score = 40
+	case strings.Contains(path, q):
+		score = 30
+	default:
+		// Fuzzy match (e.g., for misspellings): the fraction of the query's
+		// trigrams that occur in the name.
+		qts := uniqueTrigrams(q)
+		if len(qts) == 0 {
+			return 0
+		}
+		var n int
+		for _, t := range qts {
+			if strings.Contains(name, t) {
+				n++
+			}
+		}
+		frac := float64(n) / float64(len(qts))
+		if frac < 2.0/3 {
+			return 0
+		}
+		score = 30 * frac
+	}
+
+	// Prefer shorter names (which are closer matches) and exported defs.
+	score -= float64(len(name)-len(q)) / 10
+	if e.Exported {
+		score += 5
+	}
+	if score <= 0 {
+		score = 0.1
+	}
+	return score
+}
+
+// isSubsequence reports whether the characters of q occur in s in order
+// (e.g., "nwrd" in "newreader").
+func isSubsequence(q, s string) bool {
+	for i := 0; i < len(s) && len(q) > 0; i++ {
+		if s[i] == q[0] {
+			q = q[1:]
+		}
+	}
+	return len(q) == 0
+}
+
+type resultsByScore []*Result
+
+func (vs resultsByScore) Len() int      { return len(vs) }
+func (vs resultsByScore) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs resultsByScore) Less(i, j int) bool {
+	if vs[i].Score != vs[j].Score {
+		return vs[i].Score > vs[j].Score
+	}
+	if vs[i].Name != vs[j].Name {
+		return vs[i].Name < vs[j].Name
+	}
+	return vs[i].Path < vs[j].Path
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestSearch(t *testing.T) {
+	u := &unit.SourceUnit{Type: "t", Name: "u"}
+	ix := NewIndex(u, []*graph.Def{
+		{DefKey: graph.DefKey{Path: "bufio/NewReader"}, Name: "NewReader", Kind: "func", Exported: true},
+		{DefKey: graph.DefKey{Path: "bufio/Reader"}, Name: "Reader", Kind: "type", Exported: true},
+		{DefKey: graph.DefKey{Path: "bufio/Reader/ReadString"}, Name: "ReadString", Kind: "method", Exported: true},
+		{DefKey: graph.DefKey{Path: "bufio/readerPool"}, Name: "readerPool", Kind: "var"},
+		{DefKey: graph.DefKey{Path: "bufio/Writer"}, Name: "Writer", Kind: "type", Exported: true},
+	})
+
+	if got := ix.Entries[0]; got.UnitType != "t" || got.Unit != "u" {
+		t.Errorf("got entry unit %q type %q, want unit from source unit", got.Unit, got.UnitType)
+	}
+	if got, want := ix.Entries[2].QualifiedName, "bufio.Reader.ReadString"; got != want {
+		t.Errorf("got qualified name %q, want %q", got, want)
+	}
+
+	tests := map[string][]graph.DefPath{
+		// Exact matches come first, then prefix matches, then substring
+		// matches, then path matches.
+		"reader": {"bufio/Reader", "bufio/readerPool", "bufio/NewReader", "bufio/Reader/ReadString"},
+
+		// Subsequence (abbreviation) match.
+		"nwrdr": {"bufio/NewReader"},
+
+		// Path match.
+		"reader/read": {"bufio/Reader/ReadString"},
+
+		// Fuzzy (misspelling) match.
+		"writerr": {"bufio/Writer"},
+
+		"zzz": nil,
+	}
+	for q, want := range tests {
+		var got []graph.DefPath
+		for _, r := range Search([]*Index{ix}, q, 0) {
+			got = append(got, r.Path)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", q, got, want)
+		}
+	}
+
+	if got := Search([]*Index{ix}, "reader", 1); len(got) != 1 || got[0].Path != "bufio/Reader" {
+		t.Errorf("with limit 1: got %v, want only bufio/Reader", got)
+	}
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	plan.RegisterRuleMaker("search", makeSearchRules)
+	buildstore.RegisterDataType("search", &Index{})
+	buildstore.RegisterDataType("fulltext", &DocIndex{})
+}
+
+// makeSearchRules makes rules for building the search index (and, if
+// enabled, the full-text index) of each source unit, which must wait until
+// graphing completes.
+func makeSearchRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
+	var rules []makex.Rule
+	for _, rule := range existing {
+		gr, ok := rule.(*grapher.GraphUnitRule)
+		if !ok {
+			continue
+		}
+		rules = append(rules, &IndexUnitRule{
+			dataDir:     dataDir,
+			Unit:        gr.Unit,
+			GraphOutput: gr.Target(),
+		})
+		if FullTextEnabled(gr.Unit) {
+			rules = append(rules, &DocIndexUnitRule{
+				dataDir:     dataDir,
+				Unit:        gr.Unit,
+				GraphOutput: gr.Target(),
+			})
+		}
+	}
+	return rules, nil
+}
+
+// IndexUnitRule builds the search index of a source unit's defs.
+type IndexUnitRule struct {
+	dataDir     string
+	Unit        *unit.SourceUnit
+	GraphOutput string
+}
+
+func (r *IndexUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *IndexUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&Index{}, r.Unit))
+}
+
+func (r *IndexUnitRule) unitDataFile() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))
+}
+
+func (r *IndexUnitRule) Prereqs() []string { return []string{r.unitDataFile(), r.GraphOutput} }
+
+func (r *IndexUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src internal unit-search-index --unit-data %s --graph-data %s 1> $@", makex.Quote(r.unitDataFile()), makex.Quote(r.GraphOutput)),
+	}
+}
+
+// DocIndexUnitRule builds the full-text index of a source unit's defs and
+// docs.
+type DocIndexUnitRule struct {
+	dataDir     string
+	Unit        *unit.SourceUnit
+	GraphOutput string
+}
+
+func (r *DocIndexUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
+func (r *DocIndexUnitRule) Target() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&DocIndex{}, r.Unit))
+}
+
+func (r *DocIndexUnitRule) unitDataFile() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))
+}
+
+func (r *DocIndexUnitRule) Prereqs() []string { return []string{r.unitDataFile(), r.GraphOutput} }
+
+func (r *DocIndexUnitRule) Recipes() []string {
+	return []string{
+		fmt.Sprintf("src internal unit-search-index --full-text --unit-data %s --graph-data %s 1> $@", makex.Quote(r.unitDataFile()), makex.Quote(r.GraphOutput)),
+	}
+}
@@ -0,0 +1,229 @@
+// Package semtok computes semantic tokens for a file from its refs, in the
+// format of the Language Server Protocol's semanticTokens response, so that
+// editors can highlight identifiers by what they refer to using srclib's
+// output alone.
+package semtok
+
+import (
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// Token types, in the order of the legend.
+const (
+	Namespace = "namespace"
+	Type      = "type"
+	Function  = "function"
+	Property  = "property"
+	Variable  = "variable"
+)
+
+// Token modifiers, in the order of the legend.
+const (
+	Declaration  = "declaration"
+	Readonly     = "readonly"
+	Modification = "modification"
+	Local        = "local"
+)
+
+// A Legend lists the token types and modifiers that the integers in
+// semantic token data refer to.
+type Legend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// DefaultLegend is the legend of the semantic tokens computed by this
+// package.
+var DefaultLegend = Legend{
+	TokenTypes:     []string{Namespace, Type, Function, Property, Variable},
+	TokenModifiers: []string{Declaration, Readonly, Modification, Local},
+}
+
+// A Token is a classified identifier in a file. Line and Char are 0-based,
+// and Char and Length are in UTF-16 code units (as in the Language Server
+// Protocol).
+type Token struct {
+	Line      int
+	Char      int
+	Length    int
+	Type      string
+	Modifiers []string `json:",omitempty"`
+}
+
+// SemanticTokens is a file's semantic tokens, encoded as in the Language
+// Server Protocol.
+type SemanticTokens struct {
+	Legend Legend `json:"legend"`
+
+	// Data holds 5 integers for each token: the token's line (relative to
+	// the previous token's line), start character (relative to the previous
+	// token's start character, if they are on the same line), length, type
+	// (an index in Legend.TokenTypes), and modifiers (a bit set of indexes in
+	// Legend.TokenModifiers).
+	Data []int `json:"data"`
+}
+
+// Tokens returns the semantic tokens for the refs in a file whose contents
+// are src, sorted by position. The lookup func returns the def that a ref
+// points to, or nil if it is unknown; the token type is determined by the
+// def's kind if it is known, and by the ref's kind otherwise. Refs that span
+// multiple lines, are outside of src, or overlap an earlier token are
+// skipped, as are refs whose type can't be determined.
+func Tokens(src []byte, refs []*graph.Ref, lookup func(*graph.Ref) *graph.Def) []*Token {
+	sorted := make([]*graph.Ref, len(refs))
+	copy(sorted, refs)
+	sort.Sort(refsByStart(sorted))
+
+	var tokens []*Token
+	line, lineStart := 0, 0 // the current line and its byte offset
+	prevEnd := 0
+	for _, ref := range sorted {
+		if ref.Start < prevEnd || ref.End > len(src) || ref.Start >= ref.End {
+			// Out of range, or overlaps the previous token.
+			continue
+		}
+		for i := lineStart; i < ref.Start; i++ {
+			if src[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		}
+		text := src[ref.Start:ref.End]
+		if containsNewline(text) {
+			continue
+		}
+
+		def := lookup(ref)
+		typ, mods := Classify(ref, def)
+		if typ == "" {
+			continue
+		}
+		tokens = append(tokens, &Token{
+			Line:      line,
+			Char:      utf16Len(src[lineStart:ref.Start]),
+			Length:    utf16Len(text),
+			Type:      typ,
+			Modifiers: mods,
+		})
+		prevEnd = ref.End
+	}
+	return tokens
+}
+
+// Classify returns the token type and modifiers for a ref to def (which is
+// nil if unknown). The type is "" if it can't be determined.
+func Classify(ref *graph.Ref, def *graph.Def) (typ string, mods []string) {
+	if def != nil {
+		switch def.Kind {
+		case graph.Package, graph.Module:
+			typ = Namespace
+		case graph.Type:
+			typ = Type
+		case graph.Func:
+			typ = Function
+		case graph.Field:
+			typ = Property
+		case graph.Const:
+			typ = Variable
+			mods = append(mods, Readonly)
+		case graph.Var:
+			typ = Variable
+		}
+		if typ == "" && def.Callable {
+			typ = Function
+		}
+		if def.Local {
+			mods = append(mods, Local)
+		}
+	}
+	if typ == "" {
+		switch ref.Kind {
+		case graph.Call:
+			typ = Function
+		case graph.Import:
+			typ = Namespace
+		case graph.Read, graph.Write:
+			typ = Variable
+		}
+	}
+
+	if ref.Def || ref.Kind == graph.Declaration {
+		mods = append(mods, Declaration)
+	}
+	if ref.Kind == graph.Write {
+		mods = append(mods, Modification)
+	}
+	return typ, mods
+}
+
+// Encode encodes tokens (which must be sorted by position) using the legend.
+// Types and modifiers that aren't in the legend are omitted.
+func Encode(tokens []*Token, legend Legend) *SemanticTokens {
+	types := make(map[string]int, len(legend.TokenTypes))
+	for i, t := range legend.TokenTypes {
+		types[t] = i
+	}
+	mods := make(map[string]uint, len(legend.TokenModifiers))
+	for i, m := range legend.TokenModifiers {
+		mods[m] = uint(i)
+	}
+
+	st := &SemanticTokens{Legend: legend, Data: []int{}}
+	prevLine, prevChar := 0, 0
+	for _, t := range tokens {
+		typ, present := types[t.Type]
+		if !present {
+			continue
+		}
+		var modBits int
+		for _, m := range t.Modifiers {
+			if i, present := mods[m]; present {
+				modBits |= 1 << i
+			}
+		}
+
+		deltaLine, deltaChar := t.Line-prevLine, t.Char
+		if deltaLine == 0 {
+			deltaChar = t.Char - prevChar
+		}
+		st.Data = append(st.Data, deltaLine, deltaChar, t.Length, typ, modBits)
+		prevLine, prevChar = t.Line, t.Char
+	}
+	return st
+}
+
+func containsNewline(b []byte) bool {
+	for _, c := range b {
+		if c == '\n' {
+			return true
+		}
+	}
+	return false
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode b (which
+// is UTF-8).
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += len(utf16.Encode([]rune{r}))
+		b = b[size:]
+	}
+	return n
+}
+
+type refsByStart []*graph.Ref
+
+func (vs refsByStart) Len() int      { return len(vs) }
+func (vs refsByStart) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs refsByStart) Less(i, j int) bool {
+	if vs[i].Start != vs[j].Start {
+		return vs[i].Start < vs[j].Start
+	}
+	return vs[i].End < vs[j].End
+}
@@ -0,0 +1,63 @@
+package semtok
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestTokens(t *testing.T) {
+	src := "const c = 1\nfunc f(é, x int) {\n\tx = c\n\tg()\n}\n"
+	// ref returns a ref to path of the 1-byte identifier at the given offset
+	// from the start of context.
+	ref := func(context string, offset int, kind graph.RefKind, path string, isDef bool) *graph.Ref {
+		start := strings.Index(src, context) + offset
+		return &graph.Ref{DefPath: graph.DefPath(path), Start: start, End: start + 1, Kind: kind, Def: isDef}
+	}
+	defs := map[graph.DefPath]*graph.Def{
+		"c":   {Kind: graph.Const},
+		"f":   {Kind: graph.Func},
+		"f/x": {Kind: graph.Var, Local: true},
+	}
+	refs := []*graph.Ref{
+		ref("g()", 0, graph.Call, "g", false), // unknown def
+		ref("x = c", 4, graph.Read, "c", false),
+		ref("x = c", 0, graph.Write, "f/x", false),
+		ref("x int", 0, graph.Declaration, "f/x", true),
+		ref("f(", 0, "", "f", true),
+		ref("c =", 0, "", "c", true),
+		{Start: 1, End: 20}, // spans lines
+	}
+	lookup := func(ref *graph.Ref) *graph.Def { return defs[ref.DefPath] }
+
+	tokens := Tokens([]byte(src), refs, lookup)
+	want := []*Token{
+		{Line: 0, Char: 6, Length: 1, Type: Variable, Modifiers: []string{Readonly, Declaration}},
+		{Line: 1, Char: 5, Length: 1, Type: Function, Modifiers: []string{Declaration}},
+		{Line: 1, Char: 10, Length: 1, Type: Variable, Modifiers: []string{Local, Declaration}}, // é is 1 UTF-16 code unit
+		{Line: 2, Char: 1, Length: 1, Type: Variable, Modifiers: []string{Local, Modification}},
+		{Line: 2, Char: 5, Length: 1, Type: Variable, Modifiers: []string{Readonly}},
+		{Line: 3, Char: 1, Length: 1, Type: Function},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		for _, tok := range tokens {
+			t.Logf("%+v", tok)
+		}
+		t.Fatalf("got tokens above, want %d tokens", len(want))
+	}
+
+	st := Encode(tokens, DefaultLegend)
+	wantData := []int{
+		0, 6, 1, 4, 1<<1 | 1<<0,
+		1, 5, 1, 2, 1 << 0,
+		0, 5, 1, 4, 1<<3 | 1<<0,
+		1, 1, 1, 4, 1<<3 | 1<<2,
+		0, 4, 1, 4, 1 << 1,
+		1, 1, 1, 2, 0,
+	}
+	if !reflect.DeepEqual(st.Data, wantData) {
+		t.Errorf("got data %v, want %v", st.Data, wantData)
+	}
+}
@@ -0,0 +1,142 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"sourcegraph.com/sourcegraph/srclib/annotate"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/present"
+)
+
+func init() {
+	_, err := CLI.AddCommand("annotate",
+		"show the refs on each line of a file",
+		`Show each line of a file followed by the refs on it (according to the build data built by "src make"): their positions, whether they are definitions, and the keys (REPO:UNITTYPE:UNIT:PATH) of the defs that they point to. Lines with no refs are those that the file's graphers didn't cover.
+
+With -o json, prints a JSON array of the lines, each with its number, text, and refs (with their start and end byte offsets in the line), for rendering the file with links in other programs.`,
+		&annotateCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type AnnotateCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		File string `name:"FILE" description:"path of the file, relative to the current directory"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var annotateCmd AnnotateCmd
+
+func (c *AnnotateCmd) Execute(args []string) error {
+	file, err := filepath.Abs(c.Args.File)
+	if err != nil {
+		return err
+	}
+
+	repo, err := OpenRepo(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	file, err = filepath.Rel(repo.RootDir, file)
+	if err != nil {
+		return err
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join(repo.RootDir, file))
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnitsWithFile(buildStore, repo, file)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return fmt.Errorf("file %s is not in any source unit of the build of commit %s (run `src make` first)", file, repo.CommitID)
+	}
+
+	// Fill in the empty fields of the refs' def keys, so that they are
+	// complete.
+	var refs []*graph.Ref
+	for _, u := range units {
+		fileRefs, err := readFileRefs(buildStore, repo, u, file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, ref := range fileRefs {
+			if ref.DefRepo == "" {
+				ref.DefRepo = repo.URI()
+			}
+			if ref.DefUnitType == "" {
+				ref.DefUnitType = u.Type
+			}
+			if ref.DefUnit == "" {
+				ref.DefUnit = u.Name
+			}
+			refs = append(refs, ref)
+		}
+	}
+
+	lines, skipped := annotate.Lines(src, refs)
+	if skipped > 0 {
+		log.Printf("Warning: skipped %d refs that are outside of %s (has it changed since it was built?).", skipped, file)
+	}
+
+	if c.Output.Output == "json" {
+		if lines == nil {
+			lines = []*annotate.Line{}
+		}
+		PrintJSON(lines, "")
+		return nil
+	}
+	printAnnotatedLines(newPrinter(os.Stdout), lines)
+	return nil
+}
+
+// printAnnotatedLines prints each line followed by a line for each ref on
+// it, which marks the ref's position and shows the key of its def.
+func printAnnotatedLines(p *present.Printer, lines []*annotate.Line) {
+	width := len(fmt.Sprint(len(lines)))
+	for _, line := range lines {
+		p.Printf(present.Plain, "%s  %s", p.Paint(present.Dim, fmt.Sprintf("%*d", width, line.Number)), line.Text)
+		for _, a := range line.Annotations {
+			// Keep the tabs before the ref, so that the marker lines up.
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, line.Text[:a.StartCol])
+			marker := strings.Repeat("^", utf8.RuneCountInString(line.Text[a.StartCol:a.EndCol]))
+			desc := "ref"
+			if a.Def {
+				desc = "def"
+			} else if a.Kind != "" {
+				desc = string(a.Kind)
+			}
+			key := graph.DefKey{Repo: a.DefRepo, UnitType: a.DefUnitType, Unit: a.DefUnit, Path: a.DefPath}
+			p.Printf(present.Plain, "%s  %s%s %s %s", strings.Repeat(" ", width), indent, p.Paint(present.Yellow, marker), desc, p.Paint(present.Cyan, key.Format()))
+		}
+	}
+}
@@ -3,20 +3,31 @@ package src
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/kr/fs"
 	"sourcegraph.com/sourcegraph/go-sourcegraph/sourcegraph"
+	"sourcegraph.com/sourcegraph/srclib/binindex"
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
 	"sourcegraph.com/sourcegraph/srclib/config"
 	"sourcegraph.com/sourcegraph/srclib/graph"
 	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/imports"
+	"sourcegraph.com/sourcegraph/srclib/manifest"
 	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/schema"
+	"sourcegraph.com/sourcegraph/srclib/semtok"
+	"sourcegraph.com/sourcegraph/srclib/textnav"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
 	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
 )
 
 func init() {
@@ -46,6 +57,69 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	_, err = c.AddCommand("refs",
+		"list refs to the def under the cursor",
+		"Return a list of the references to the definition referred to by the cursor's current position in a file: those in the current repository, sorted by file and position, and then those in the other roots of its workspace (see \"src workspace\"), if any. Use --first and --after to page through a long list.",
+		&apiRefsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("stale",
+		"list source units whose build data is stale",
+		"Return a list of the source units whose build data was built from files that have since changed in the working tree, along with the changed files.",
+		&apiStaleCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("importers",
+		"list files that import a given file",
+		"Return a list of the files in the repository that import the given file, directly or indirectly (i.e., the files that may be affected by a change to it), according to the import graphs extracted by `src make`.",
+		&apiImportersCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("implementations",
+		"list implementations of the type under the cursor",
+		"Return a list of the types that implement or extend (directly or indirectly) the type referred to by the cursor's current position in a file.",
+		&apiImplementationsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("supertypes",
+		"list supertypes of the type under the cursor",
+		"Return a list of the types that are implemented or extended (directly or indirectly) by the type referred to by the cursor's current position in a file.",
+		&apiSupertypesCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("semantic-tokens",
+		"list semantic tokens for highlighting a file",
+		"Return the semantic tokens of a file (the identifiers in it, classified by the kinds of their defs and refs) in the format of the Language Server Protocol's semanticTokens response, for editors to use in syntax highlighting.",
+		&apiSemanticTokensCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("graph-file",
+		"graph the contents of a file read from stdin",
+		"Graph a single file whose current contents (which may not be saved) are read from stdin, and return the defs, refs, and docs in it. The file's source units are those of the last build of the current commit (or of its nearest built ancestor, or else of the latest build on the current branch), and it is graphed by their graphers, which must support the single-file capability. Unlike the other api commands, it never runs a build, so it is fast enough to run as the file is edited.",
+		&apiGraphFileCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 type APICmd struct{}
@@ -62,11 +136,56 @@ type APIDescribeCmd struct {
 }
 
 type APIListCmd struct {
+	File  string   `long:"file" required:"yes" value-name:"FILE"`
+	Kinds []string `long:"kind" description:"only list refs of this kind (read, write, call, import, or declaration; can be specified multiple times)" value-name:"KIND"`
+
+	QueryOpt `group:"query"`
+}
+
+type APIRefsCmd struct {
+	File      string   `long:"file" required:"yes" value-name:"FILE"`
+	StartByte int      `long:"start-byte" required:"yes" value-name:"BYTE"`
+	Kinds     []string `long:"kind" description:"only list refs of this kind (read, write, call, import, or declaration; can be specified multiple times)" value-name:"KIND"`
+	RefFile   string   `long:"ref-file" description:"only list refs in this file" value-name:"FILE"`
+
+	QueryOpt `group:"query"`
+}
+
+type APIStaleCmd struct{}
+
+type APIImportersCmd struct {
+	File string `long:"file" required:"yes" value-name:"FILE"`
+}
+
+type APIImplementationsCmd struct {
+	File      string `long:"file" required:"yes" value-name:"FILE"`
+	StartByte int    `long:"start-byte" required:"yes" value-name:"BYTE"`
+}
+
+type APISupertypesCmd struct {
+	File      string `long:"file" required:"yes" value-name:"FILE"`
+	StartByte int    `long:"start-byte" required:"yes" value-name:"BYTE"`
+}
+
+type APISemanticTokensCmd struct {
 	File string `long:"file" required:"yes" value-name:"FILE"`
 }
 
+type APIGraphFileCmd struct {
+	File string `long:"file" required:"yes" description:"file whose contents are read from stdin" value-name:"FILE"`
+
+	ToolchainExecOpt `group:"execution"`
+}
+
 var apiDescribeCmd APIDescribeCmd
 var apiListCmd APIListCmd
+var apiRefsCmd APIRefsCmd
+var apiStaleCmd APIStaleCmd
+var apiImportersCmd APIImportersCmd
+var apiImplementationsCmd APIImplementationsCmd
+var apiSupertypesCmd APISupertypesCmd
+var apiSemanticTokensCmd APISemanticTokensCmd
+var apiGraphFileCmd APIGraphFileCmd
 
 // Invokes the build process on the given repository
 func ensureBuild(buildStore *buildstore.RepositoryStore, repo *Repo) error {
@@ -107,6 +226,27 @@ func getSourceUnitsWithFile(buildStore *buildstore.RepositoryStore, repo *Repo,
 	// TODO(sqs): This whole lookup is totally inefficient. The storage format
 	// is not optimized for lookups.
 
+	allUnits, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find which source units the file belongs to.
+	var units []*unit.SourceUnit
+	for _, u := range allUnits {
+		for _, f2 := range u.Files {
+			if f2 == filename {
+				units = append(units, u)
+				break
+			}
+		}
+	}
+
+	return units, nil
+}
+
+// Get a list of all source units that were built for the repo's commit
+func getSourceUnits(buildStore *buildstore.RepositoryStore, repo *Repo) ([]*unit.SourceUnit, error) {
 	// Find all source unit definition files.
 	var unitFiles []string
 	unitSuffix := buildstore.DataTypeSuffix(unit.SourceUnit{})
@@ -117,30 +257,53 @@ func getSourceUnitsWithFile(buildStore *buildstore.RepositoryStore, repo *Repo,
 		}
 	}
 
-	// Find which source units the file belongs to.
 	var units []*unit.SourceUnit
 	for _, unitFile := range unitFiles {
 		var u *unit.SourceUnit
-		f, err := buildStore.Open(unitFile)
-		if err != nil {
+		if err := readBuildDataJSON(buildStore, unitFile, &u); err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		if err := json.NewDecoder(f).Decode(&u); err != nil {
-			return nil, fmt.Errorf("%s: %s", unitFile, err)
-		}
-		for _, f2 := range u.Files {
-			if f2 == filename {
-				units = append(units, u)
-				break
-			}
-		}
+		units = append(units, u)
 	}
 
 	return units, nil
 }
 
+// readBuildDataJSON decodes the JSON build data file at path in buildStore
+// into v. If the file doesn't exist, the error satisfies os.IsNotExist.
+func readBuildDataJSON(buildStore *buildstore.RepositoryStore, path string, v interface{}) error {
+	f, err := buildStore.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// checkRefKinds returns a usage error if any of kinds is not a valid ref
+// kind.
+func checkRefKinds(kinds []string) error {
+	for _, k := range kinds {
+		kind := graph.RefKind(k)
+		if kind == "" || !kind.Valid() {
+			return withKind(UsageError, fmt.Errorf("invalid ref kind %q (must be one of %v)", k, graph.RefKinds))
+		}
+	}
+	return nil
+}
+
 func (c *APIListCmd) Execute(args []string) error {
+	if err := checkRefKinds(c.Kinds); err != nil {
+		return err
+	}
+	opt, err := c.QueryOpt.options(&graph.Ref{}, c.Kinds, "")
+	if err != nil {
+		return err
+	}
+
 	repo, err := OpenRepo(filepath.Dir(c.File))
 	if err != nil {
 		return err
@@ -169,7 +332,7 @@ func (c *APIListCmd) Execute(args []string) error {
 		return err
 	}
 
-	if GlobalOpt.Verbose {
+	if verbose() {
 		if len(units) > 0 {
 			ids := make([]string, len(units))
 			for i, u := range units {
@@ -184,30 +347,25 @@ func (c *APIListCmd) Execute(args []string) error {
 	// Find the ref(s) at the character position.
 	var refs []*graph.Ref
 	for _, u := range units {
-		var g grapher.Output
-		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
-		f, err := buildStore.Open(graphFile)
+		fileRefs, err := readFileRefs(buildStore, repo, u, c.File)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		if err := json.NewDecoder(f).Decode(&g); err != nil {
-			return fmt.Errorf("%s: %s", graphFile, err)
-		}
-		for _, ref := range g.Refs {
-			if c.File == ref.File {
-				refs = append(refs, ref)
-			}
-		}
+		refs = append(refs, fileRefs...)
 	}
 
-	if err := json.NewEncoder(os.Stdout).Encode(refs); err != nil {
+	return printQueryPage(opt, len(refs), func(i int) bool { return opt.MatchRef(refs[i]) }, func(i int) interface{} { return refs[i] })
+}
+
+func (c *APIRefsCmd) Execute(args []string) error {
+	if err := checkRefKinds(c.Kinds); err != nil {
+		return err
+	}
+	opt, err := c.QueryOpt.options(&graph.Ref{}, c.Kinds, c.RefFile)
+	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func (c *APIDescribeCmd) Execute(args []string) error {
 	repo, err := OpenRepo(filepath.Dir(c.File))
 	if err != nil {
 		return err
@@ -231,96 +389,211 @@ func (c *APIDescribeCmd) Execute(args []string) error {
 		return err
 	}
 
-	units, err := getSourceUnitsWithFile(buildStore, repo, c.File)
+	ref, err := findRefAt(buildStore, repo, c.File, c.StartByte)
 	if err != nil {
 		return err
 	}
+	var refs []*graph.Ref
+	if ref != nil {
+		if refs, err = readDefRefs(buildStore, repo, ref.DefKey()); err != nil {
+			return err
+		}
 
-	if GlobalOpt.Verbose {
-		if len(units) > 0 {
-			ids := make([]string, len(units))
-			for i, u := range units {
-				ids[i] = string(u.ID())
+		// Add the refs in the other roots of the workspace.
+		w, err := currentWorkspace(repo.RootDir)
+		if err != nil {
+			return err
+		}
+		if w != nil {
+			for _, r := range w.Roots {
+				if r.Repo == repo.URI() {
+					continue
+				}
+				rootRepo, rootStore, err := openBuiltWorkspaceRoot(r)
+				if err != nil {
+					return err
+				}
+				if rootRepo == nil {
+					continue
+				}
+				rootRefs, err := readDefRefs(rootStore, rootRepo, ref.DefKey())
+				if err != nil {
+					return err
+				}
+				refs = append(refs, rootRefs...)
 			}
-			log.Printf("Position %s:%d is in %d source units %v.", c.File, c.StartByte, len(units), ids)
-		} else {
-			log.Printf("Position %s:%d is not in any source units.", c.File, c.StartByte)
 		}
 	}
 
-	// Find the ref(s) at the character position.
-	var ref *graph.Ref
-OuterLoop:
+	return printQueryPage(opt, len(refs), func(i int) bool { return opt.MatchRef(refs[i]) }, func(i int) interface{} { return refs[i] })
+}
+
+// readDefRefs returns the refs in the repo to the def with the given key,
+// sorted by file and position. Empty fields in the refs' def keys (and
+// their Repo, UnitType, and Unit) are filled in from the source unit and
+// repo that contain them. It uses the binary index, if it has been built.
+func readDefRefs(buildStore *buildstore.RepositoryStore, repo *Repo, key graph.DefKey) ([]*graph.Ref, error) {
+	x, err := openBinaryIndex(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+	if x != nil {
+		defer x.Close()
+		return x.DefRefs(key)
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+	graphs := make(map[unit.ID]*grapher.Output)
+	var refs []*graph.Ref
 	for _, u := range units {
-		var g grapher.Output
-		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
-		f, err := buildStore.Open(graphFile)
+		g, err := readCachedGraph(buildStore, repo, graphs, u.Type, u.Name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		defer f.Close()
-		if err := json.NewDecoder(f).Decode(&g); err != nil {
-			return fmt.Errorf("%s: %s", graphFile, err)
+		if g == nil {
+			continue
 		}
-		for _, ref2 := range g.Refs {
-			if c.File == ref2.File && c.StartByte >= ref2.Start && c.StartByte <= ref2.End {
-				ref = ref2
-				if ref.DefUnit == "" {
-					ref.DefUnit = u.Name
+		for _, ref := range g.Refs {
+			defKey := ref.DefKey()
+			if defKey.Repo == "" {
+				defKey.Repo = repo.URI()
+			}
+			if defKey.UnitType == "" {
+				defKey.UnitType = u.Type
+			}
+			if defKey.Unit == "" {
+				defKey.Unit = u.Name
+			}
+			if defKey == key {
+				ref.SetFromDefKey(defKey)
+				if ref.Repo == "" {
+					ref.Repo = repo.URI()
 				}
-				if ref.DefUnitType == "" {
-					ref.DefUnitType = u.Type
+				if ref.UnitType == "" {
+					ref.UnitType = u.Type
 				}
-				break OuterLoop
+				if ref.Unit == "" {
+					ref.Unit = u.Name
+				}
+				refs = append(refs, ref)
 			}
 		}
 	}
+	sort.Sort(refsByPosition(refs))
+	return refs, nil
+}
 
-	if ref == nil {
-		if GlobalOpt.Verbose {
-			log.Printf("No ref found at %s:%d.", c.File, c.StartByte)
-		}
-		fmt.Println(`{}`)
-		return nil
+type refsByPosition []*graph.Ref
+
+func (v refsByPosition) Len() int      { return len(v) }
+func (v refsByPosition) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v refsByPosition) Less(i, j int) bool {
+	a, b := v[i], v[j]
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Start != b.Start {
+		return a.Start < b.Start
 	}
+	return a.End < b.End
+}
 
-	if ref.DefRepo == "" {
-		ref.DefRepo = repo.URI()
+func (c *APIDescribeCmd) Execute(args []string) error {
+	repo, err := OpenRepo(filepath.Dir(c.File))
+	if err != nil {
+		return err
+	}
+
+	c.File, err = filepath.Rel(repo.RootDir, c.File)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(repo.RootDir); err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureBuild(buildStore, repo); err != nil {
+		return err
+	}
+
+	ref, err := findRefAt(buildStore, repo, c.File, c.StartByte)
+	if err != nil {
+		return err
+	}
+	if ref == nil {
+		// Guess instead, so that navigation still works.
+		defs, err := approximateDefs(repo.RootDir, c.File, c.StartByte, maxApproximateDefs)
+		if err != nil {
+			return err
+		}
+		if len(defs) == 0 {
+			fmt.Println(`{}`)
+			return nil
+		}
+		return json.NewEncoder(os.Stdout).Encode(struct {
+			// Approximate are the probable defs of the identifier at the
+			// position, found by searching the source files textually
+			// (since there is no graph data for it).
+			Approximate []*textnav.Def
+		}{defs})
 	}
 
 	var resp struct {
 		Def      *sourcegraph.Def
 		Examples []*sourcegraph.Example
+
+		// Alternates are the other defs that the ref may point to, if it
+		// is ambiguous (see graph.Ref.Candidates).
+		Alternates []*graph.RefCandidate `json:",omitempty"`
+
+		// Aliases are the defs (e.g., re-exports) that were followed to get
+		// from the def that the ref points to to Def.
+		Aliases []graph.DefKey `json:",omitempty"`
 	}
+	resp.Alternates = ref.Alternates()
 
-	// Now find the def for this ref.
-	defInCurrentRepo := ref.DefRepo == repo.URI()
-	if defInCurrentRepo {
-		// Def is in the current repo.
-		var g grapher.Output
-		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", &unit.SourceUnit{Name: ref.DefUnit, Type: ref.DefUnitType}))
-		f, err := buildStore.Open(graphFile)
+	// The def is in the current repo or another root of its workspace, if
+	// defRepo is set.
+	defRepo, defStore, err := localDefRepo(buildStore, repo, ref.DefRepo)
+	if err != nil {
+		return err
+	}
+
+	// Follow aliases to the canonical def.
+	if defRepo != nil {
+		graphs := make(map[unit.ID]*grapher.Output)
+		key, aliases, err := graph.FollowAliases(ref.DefKey(), func(k graph.DefKey) (*graph.Alias, error) {
+			return lookupAlias(defStore, defRepo, graphs, k)
+		})
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		if err := json.NewDecoder(f).Decode(&g); err != nil {
-			return fmt.Errorf("%s: %s", graphFile, err)
-		}
-		for _, def2 := range g.Defs {
-			if def2.Path == ref.DefPath {
-				resp.Def = &sourcegraph.Def{Def: *def2}
-				break
-			}
+		ref.SetFromDefKey(key)
+		resp.Aliases = aliases
+	}
+
+	// Now find the def for this ref.
+	if defRepo != nil && ref.DefRepo == defRepo.URI() {
+		def, docs, err := readDef(defStore, defRepo, ref.DefKey())
+		if err != nil {
+			return err
 		}
-		if resp.Def != nil {
-			for _, doc := range g.Docs {
-				if doc.Path == ref.DefPath {
-					resp.Def.DocHTML = doc.Data
-				}
+		if def != nil {
+			resp.Def = &sourcegraph.Def{Def: *def}
+			for _, doc := range docs {
+				resp.Def.DocHTML = doc.Data
 			}
 		}
-		if resp.Def == nil && GlobalOpt.Verbose {
+		if resp.Def == nil && verbose() {
 			log.Printf("No definition found with path %q in unit %q type %q.", ref.DefPath, ref.DefUnit, ref.DefUnitType)
 		}
 	}
@@ -335,14 +608,14 @@ OuterLoop:
 	var wg sync.WaitGroup
 
 	if resp.Def == nil {
-		// Def is not in the current repo. Try looking it up using the
-		// Sourcegraph API.
+		// Def is not in the current repo or workspace. Try looking it up
+		// using the Sourcegraph API.
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			var err error
 			resp.Def, _, err = apiclient.Defs.Get(spec, &sourcegraph.DefGetOptions{Doc: true})
-			if err != nil && GlobalOpt.Verbose {
+			if err != nil && verbose() {
 				log.Printf("Couldn't fetch definition %v: %s.", spec, err)
 			}
 		}()
@@ -357,7 +630,7 @@ OuterLoop:
 				Formatted:   true,
 				ListOptions: sourcegraph.ListOptions{PerPage: 4},
 			})
-			if err != nil && GlobalOpt.Verbose {
+			if err != nil && verbose() {
 				log.Printf("Couldn't fetch examples for %v: %s.", spec, err)
 			}
 		}()
@@ -370,3 +643,732 @@ OuterLoop:
 	}
 	return nil
 }
+
+// findRefAt returns the ref at the given position in file, or nil if there
+// is none. Empty fields in the returned ref's def key (and those of its
+// candidates) are filled in from the source unit and repo that contain the
+// ref.
+func findRefAt(buildStore *buildstore.RepositoryStore, repo *Repo, file string, startByte int) (*graph.Ref, error) {
+	units, err := getSourceUnitsWithFile(buildStore, repo, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if verbose() {
+		if len(units) > 0 {
+			ids := make([]string, len(units))
+			for i, u := range units {
+				ids[i] = string(u.ID())
+			}
+			log.Printf("Position %s:%d is in %d source units %v.", file, startByte, len(units), ids)
+		} else {
+			log.Printf("Position %s:%d is not in any source units.", file, startByte)
+		}
+	}
+
+	x, err := openBinaryIndex(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+	if x != nil {
+		defer x.Close()
+		ref, err := x.RefAt(file, startByte)
+		if err != nil || ref == nil {
+			return nil, err
+		}
+		// The index's refs' def keys are complete.
+		return ref, nil
+	}
+
+	// Find the ref(s) at the character position.
+	var ref *graph.Ref
+OuterLoop:
+	for _, u := range units {
+		refs, err := readFileRefs(buildStore, repo, u, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref2 := range refs {
+			if startByte >= ref2.Start && startByte <= ref2.End {
+				ref = ref2
+				if ref.DefUnit == "" {
+					ref.DefUnit = u.Name
+				}
+				if ref.DefUnitType == "" {
+					ref.DefUnitType = u.Type
+				}
+				for _, c := range ref.Candidates {
+					if c.DefUnit == "" {
+						c.DefUnit = u.Name
+					}
+					if c.DefUnitType == "" {
+						c.DefUnitType = u.Type
+					}
+				}
+				break OuterLoop
+			}
+		}
+	}
+
+	if ref == nil {
+		if verbose() {
+			log.Printf("No ref found at %s:%d.", file, startByte)
+		}
+		return nil, nil
+	}
+
+	if ref.DefRepo == "" {
+		ref.DefRepo = repo.URI()
+	}
+	for _, c := range ref.Candidates {
+		if c.DefRepo == "" {
+			c.DefRepo = repo.URI()
+		}
+	}
+	return ref, nil
+}
+
+// maxApproximateDefs is the maximum number of defs that "src api describe"
+// guesses for a position with no graph data.
+const maxApproximateDefs = 10
+
+// approximateDefs guesses the defs of the identifier at startByte in file
+// (relative to rootDir) by searching the source files in rootDir textually
+// (see package textnav), for positions with no graph data. It returns at
+// most limit defs, nearest first.
+func approximateDefs(rootDir, file string, startByte, limit int) ([]*textnav.Def, error) {
+	src, err := ioutil.ReadFile(filepath.Join(rootDir, file))
+	if err != nil {
+		return nil, err
+	}
+	name, _ := textnav.IdentAt(src, startByte)
+	if name == "" {
+		return nil, nil
+	}
+	if verbose() {
+		log.Printf("No graph data at %s:%d; searching for defs of %q textually.", file, startByte, name)
+	}
+	return textnav.FindDefs(vfs.OS(rootDir), name, filepath.ToSlash(file), limit)
+}
+
+// lookupAlias returns the alias in the current repo whose alias def is key,
+// or nil if there is none. Empty fields in the returned alias's def key are
+// filled in from the alias def's key. Graph outputs that are read are cached
+// in graphs.
+func lookupAlias(buildStore *buildstore.RepositoryStore, repo *Repo, graphs map[unit.ID]*grapher.Output, key graph.DefKey) (*graph.Alias, error) {
+	if key.Repo != repo.URI() {
+		return nil, nil
+	}
+
+	g, err := readCachedGraph(buildStore, repo, graphs, key.UnitType, key.Unit)
+	if g == nil || err != nil {
+		return nil, err
+	}
+
+	for _, a := range g.Aliases {
+		if a.Path == key.Path {
+			a2 := *a
+			if a2.DefRepo == "" {
+				a2.DefRepo = key.Repo
+			}
+			if a2.DefUnitType == "" {
+				a2.DefUnitType = key.UnitType
+			}
+			if a2.DefUnit == "" {
+				a2.DefUnit = key.Unit
+			}
+			return &a2, nil
+		}
+	}
+	return nil, nil
+}
+
+// readCachedGraph returns the graph output of the source unit in the current
+// repo with the given type and name, or nil if it has none. Graph outputs
+// that are read are cached in graphs.
+func readCachedGraph(buildStore *buildstore.RepositoryStore, repo *Repo, graphs map[unit.ID]*grapher.Output, unitType, unitName string) (*grapher.Output, error) {
+	u := &unit.SourceUnit{Name: unitName, Type: unitType}
+	if g, present := graphs[u.ID()]; present {
+		return g, nil
+	}
+
+	graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+	f, err := buildStore.Open(graphFile)
+	if os.IsNotExist(err) {
+		graphs[u.ID()] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := grapher.ReadOutput(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", graphFile, err)
+	}
+	graphs[u.ID()] = g
+	return g, nil
+}
+
+// openBinaryIndex opens the index of the current commit's graph outputs (see
+// package binindex), or returns nil if it hasn't been built.
+func openBinaryIndex(buildStore *buildstore.RepositoryStore, repo *Repo) (*binindex.Index, error) {
+	dir, err := buildstore.BuildDir(buildStore, repo.CommitID)
+	if err != nil {
+		return nil, err
+	}
+	x, err := binindex.Open(filepath.Join(dir, binindex.Filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return x, err
+}
+
+// readDef returns the def in the current repo with the given key (whose
+// Repo, UnitType, and Unit must be set) and its docs, or nil if there is no
+// such def. It uses the binary index, if it has been built.
+func readDef(buildStore *buildstore.RepositoryStore, repo *Repo, key graph.DefKey) (*graph.Def, []*graph.Doc, error) {
+	x, err := openBinaryIndex(buildStore, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if x != nil {
+		defer x.Close()
+		def, err := x.Def(key)
+		if err != nil || def == nil {
+			return nil, nil, err
+		}
+		docs, err := x.Docs(key)
+		return def, docs, err
+	}
+
+	graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", &unit.SourceUnit{Name: key.Unit, Type: key.UnitType}))
+	f, err := buildStore.Open(graphFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	g, err := grapher.ReadOutput(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", graphFile, err)
+	}
+	var def *graph.Def
+	for _, def2 := range g.Defs {
+		if def2.Path == key.Path {
+			def = def2
+			break
+		}
+	}
+	if def == nil {
+		return nil, nil, nil
+	}
+	var docs []*graph.Doc
+	for _, doc := range g.Docs {
+		if doc.Path == key.Path {
+			docs = append(docs, doc)
+		}
+	}
+	return def, docs, nil
+}
+
+// readFileRefs returns the refs in file in the graph output of the source
+// unit u. If the output is sharded (see grapher.Shards), only the file's
+// shard is read.
+func readFileRefs(buildStore *buildstore.RepositoryStore, repo *Repo, u *unit.SourceUnit, file string) ([]*graph.Ref, error) {
+	indexFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(&grapher.ShardIndex{}, u))
+	s, err := grapher.ReadShardedOutput(indexFile, func(name string) (io.ReadCloser, error) { return buildStore.Open(name) })
+	if err == nil {
+		shard, err := s.Shard(file)
+		if err != nil || shard == nil {
+			return nil, err
+		}
+		return shard.Refs, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The output was built before outputs were sharded.
+	graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+	f, err := buildStore.Open(graphFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := grapher.ReadOutput(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", graphFile, err)
+	}
+	var refs []*graph.Ref
+	for _, ref := range g.Refs {
+		if ref.File == file {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// StaleUnit is a source unit whose build data is stale.
+type StaleUnit struct {
+	UnitType string
+	Unit     string
+
+	// Files are the unit's files that have changed since its build data was
+	// built. It is empty if the unit's build data has no manifest (in which
+	// case it's not known which files changed).
+	Files []string `json:",omitempty"`
+}
+
+func (c *APIStaleCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	stale := []*StaleUnit{}
+	for _, u := range units {
+		var m manifest.Manifest
+		manifestFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(&manifest.Manifest{}, u))
+		if err := readBuildDataJSON(buildStore, manifestFile, &m); os.IsNotExist(err) {
+			stale = append(stale, &StaleUnit{UnitType: u.Type, Unit: u.Name})
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		changed, err := m.Changed(repo.RootDir)
+		if err != nil {
+			return err
+		}
+		if len(changed) > 0 {
+			stale = append(stale, &StaleUnit{UnitType: u.Type, Unit: u.Name, Files: changed})
+		}
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(stale); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *APIImportersCmd) Execute(args []string) error {
+	repo, err := OpenRepo(filepath.Dir(c.File))
+	if err != nil {
+		return err
+	}
+
+	c.File, err = filepath.Rel(repo.RootDir, c.File)
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	var allImports []*imports.Import
+	unitFiles := make(map[imports.UnitKey][]string, len(units))
+	for _, u := range units {
+		unitFiles[imports.UnitKey{UnitType: u.Type, Unit: u.Name}] = u.Files
+
+		var o imports.Output
+		importsFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(&imports.Output{}, u))
+		if err := readBuildDataJSON(buildStore, importsFile, &o); os.IsNotExist(err) {
+			// The unit's toolchain doesn't support the imports op.
+			if verbose() {
+				log.Printf("No import graph for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		allImports = append(allImports, o.Imports...)
+	}
+
+	importers := imports.Importers(c.File, allImports, unitFiles)
+	if importers == nil {
+		importers = []string{}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(importers); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *APIImplementationsCmd) Execute(args []string) error {
+	return queryTypeHierarchy(c.File, c.StartByte, (*graph.TypeHierarchy).Implementations)
+}
+
+func (c *APISupertypesCmd) Execute(args []string) error {
+	return queryTypeHierarchy(c.File, c.StartByte, (*graph.TypeHierarchy).Supertypes)
+}
+
+// queryTypeHierarchy prints the result of calling query with the repo's type
+// hierarchy and the def referred to by the given position in file.
+func queryTypeHierarchy(file string, startByte int, query func(*graph.TypeHierarchy, graph.DefKey) []graph.DefKey) error {
+	repo, err := OpenRepo(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	file, err = filepath.Rel(repo.RootDir, file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(repo.RootDir); err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureBuild(buildStore, repo); err != nil {
+		return err
+	}
+
+	ref, err := findRefAt(buildStore, repo, file, startByte)
+	if err != nil {
+		return err
+	}
+	keys := []graph.DefKey{}
+	if ref != nil {
+		h, err := readTypeHierarchy(buildStore, repo)
+		if err != nil {
+			return err
+		}
+		if keys = query(h, ref.DefKey()); keys == nil {
+			keys = []graph.DefKey{}
+		}
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(keys); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readTypeHierarchy returns the type hierarchy of all of the repo's source
+// units. Only the relations emitted by the repo's own graphers are known, so
+// it does not include, e.g., types in other repos that implement the repo's
+// interfaces.
+func readTypeHierarchy(buildStore *buildstore.RepositoryStore, repo *Repo) (*graph.TypeHierarchy, error) {
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var rels []*graph.TypeRelation
+	for _, u := range units {
+		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+		f, err := buildStore.Open(graphFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		g, err := grapher.ReadOutput(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", graphFile, err)
+		}
+		for _, r := range g.TypeRelations {
+			if r.Repo == "" {
+				r.Repo = repo.URI()
+			}
+			if r.UnitType == "" && r.Unit == "" {
+				r.UnitType, r.Unit = u.Type, u.Name
+			}
+			if r.DefRepo == "" {
+				r.DefRepo = repo.URI()
+			}
+			if r.DefUnitType == "" && r.DefUnit == "" {
+				r.DefUnitType, r.DefUnit = u.Type, u.Name
+			}
+			rels = append(rels, r)
+		}
+	}
+	return graph.NewTypeHierarchy(rels), nil
+}
+
+func (c *APISemanticTokensCmd) Execute(args []string) error {
+	repo, err := OpenRepo(filepath.Dir(c.File))
+	if err != nil {
+		return err
+	}
+
+	c.File, err = filepath.Rel(repo.RootDir, c.File)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(repo.RootDir); err != nil {
+		return err
+	}
+
+	src, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureBuild(buildStore, repo); err != nil {
+		return err
+	}
+
+	units, err := getSourceUnitsWithFile(buildStore, repo, c.File)
+	if err != nil {
+		return err
+	}
+
+	// Collect the file's refs, and look up the defs they point to (in the
+	// current repo) to classify them.
+	graphs := make(map[unit.ID]*grapher.Output)
+	var refs []*graph.Ref
+	refUnits := make(map[*graph.Ref]*unit.SourceUnit)
+	for _, u := range units {
+		fileRefs, err := readFileRefs(buildStore, repo, u, c.File)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, ref := range fileRefs {
+			refs = append(refs, ref)
+			refUnits[ref] = u
+		}
+	}
+
+	var lookupErr error
+	lookup := func(ref *graph.Ref) *graph.Def {
+		if ref.DefRepo != "" && ref.DefRepo != repo.URI() {
+			return nil
+		}
+		unitType, unitName := ref.DefUnitType, ref.DefUnit
+		if unitType == "" && unitName == "" {
+			u := refUnits[ref]
+			unitType, unitName = u.Type, u.Name
+		}
+		g, err := readCachedGraph(buildStore, repo, graphs, unitType, unitName)
+		if err != nil {
+			lookupErr = err
+		}
+		if g == nil {
+			return nil
+		}
+		for _, def := range g.Defs {
+			if def.Path == ref.DefPath {
+				return def
+			}
+		}
+		return nil
+	}
+	tokens := semtok.Tokens(src, refs, lookup)
+	if lookupErr != nil {
+		return lookupErr
+	}
+
+	PrintJSON(semtok.Encode(tokens, semtok.DefaultLegend), "")
+	return nil
+}
+
+func (c *APIGraphFileCmd) Execute(args []string) error {
+	contents, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	repo, err := OpenRepo(filepath.Dir(c.File))
+	if err != nil {
+		return err
+	}
+
+	c.File, err = filepath.Rel(repo.RootDir, c.File)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(repo.RootDir); err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	// Use the source units of the last build, even if it's of an ancestor
+	// commit, since the file is probably still in the same units.
+	built, err := lastBuild(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnitsWithFile(buildStore, built, c.File)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return fmt.Errorf("file %s is not in any source unit of the build of commit %s", c.File, built.CommitID)
+	}
+
+	// Tell the graphers which file to graph, and where its contents are.
+	f, err := ioutil.TempFile("", "srclib-graph-file")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	for name, v := range map[string]string{toolchain.SingleFileEnv: filepath.ToSlash(c.File), toolchain.SingleFileContentsEnv: f.Name()} {
+		if err := os.Setenv(name, v); err != nil {
+			return err
+		}
+		defer os.Unsetenv(name)
+	}
+
+	// Read the file's contents (for naming anonymous defs and embedding
+	// snippets) from stdin, and the other files from the working tree.
+	tree := vfs.OS(".")
+	readFile := func(file string) ([]byte, error) {
+		if filepath.Clean(file) == c.File {
+			return contents, nil
+		}
+		return tree.ReadFile(file)
+	}
+
+	out := &grapher.Shard{File: filepath.ToSlash(c.File)}
+	for _, u := range units {
+		o, err := graphUnitFile(u, c.ToolchainExecOpt, readFile)
+		if err != nil {
+			return fmt.Errorf("graphing %s in source unit %s: %s", c.File, u.ID(), err)
+		}
+		_, shards := grapher.Shards(o)
+		for _, s := range shards {
+			if s.File == out.File {
+				out.Defs = append(out.Defs, s.Defs...)
+				out.Refs = append(out.Refs, s.Refs...)
+				out.Docs = append(out.Docs, s.Docs...)
+			}
+		}
+	}
+
+	PrintJSON(out, "")
+	return nil
+}
+
+// lastBuild returns a copy of repo whose CommitID is that of the last
+// build: the current commit's, if it has been built, or else its nearest
+// built ancestor's, or else (e.g., if the current branch was rebased since
+// it was built) that of the latest build on the current branch.
+func lastBuild(buildStore *buildstore.RepositoryStore, repo *Repo) (*Repo, error) {
+	built := *repo
+	if _, err := buildStore.Stat(buildStore.CommitPath(repo.CommitID)); os.IsNotExist(err) {
+		if built.CommitID, err = previousBuild(buildStore, repo); err != nil {
+			return nil, err
+		}
+		if built.CommitID == "" {
+			branch, err := currentBranch(repo.VCSType, repo.RootDir)
+			if err != nil {
+				return nil, err
+			}
+			if branch != "" {
+				if built.CommitID, err = buildStore.LatestBranchBuild(branch); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if built.CommitID == "" {
+			return nil, fmt.Errorf("neither commit %s nor any of its ancestors has been built (run `src make`)", repo.CommitID)
+		}
+		if verbose() {
+			log.Printf("Using the build of commit %s.", built.CommitID)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &built, nil
+}
+
+// graphUnitFile runs the grapher of source unit u to graph the single file
+// named by the toolchain.SingleFileEnv environment variable, and normalizes
+// its output as `src make` would (reading source files with readFile).
+func graphUnitFile(u *unit.SourceUnit, execOpt ToolchainExecOpt, readFile func(string) ([]byte, error)) (*grapher.Output, error) {
+	toolRef := u.Ops["graph"]
+	if toolRef == nil {
+		var err error
+		if toolRef, err = toolchain.ChooseTool("graph", u.Type); err != nil {
+			return nil, err
+		}
+	}
+	session, err := toolSession(toolRef.Toolchain)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Has(toolchain.SingleFile) {
+		return nil, fmt.Errorf("toolchain %s can't graph a single file (it doesn't have the %s capability)", toolRef.Toolchain, toolchain.SingleFile)
+	}
+	tool, err := toolchain.OpenTool(toolRef.Toolchain, toolRef.Subcmd, execOpt.ToolchainMode())
+	if err != nil {
+		return nil, err
+	}
+
+	var data json.RawMessage
+	if err := tool.Run(nil, u, &data); err != nil {
+		return nil, err
+	}
+	mode, err := execOpt.ValidationMode()
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.CheckOutput("grapher "+toolRef.String(), "graph", data, mode); err != nil {
+		return nil, err
+	}
+	var o *grapher.Output
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+
+	outputs := []*grapher.ToolchainOutput{{Toolchain: toolRef.Toolchain, Output: o}}
+	if err := grapher.ConvertToolchainOffsets(outputs, readFile); err != nil {
+		return nil, err
+	}
+	o, err = grapher.NormalizeDataWith(outputs, u.DefMerge, u.Normalize)
+	if err != nil {
+		return nil, err
+	}
+	if u.IncludeLocals != nil && !*u.IncludeLocals {
+		grapher.RemoveLocals(o, u)
+	}
+	grapher.NameAnonymousDefs(o, readFile)
+	if u.Snippets != nil {
+		grapher.EmbedSnippets(o, u.Snippets.MaxLines, readFile)
+	}
+	grapher.ApplyFilters(o, u)
+	return o, nil
+}
@@ -0,0 +1,258 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/apisurface"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("api-surface",
+		"show the public API of source units",
+		`Shows the API surface (the exported defs, with their signatures and docs) of each source unit in the current repository. The API surfaces are extracted by "src make" and saved alongside the other build data, so they can be collected by documentation pipelines and compared across builds.`,
+		&apiSurfaceCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = CLI.AddCommand("apidiff",
+		"compare the public APIs of two builds",
+		`Compares the API surfaces of two builds of the current repository and lists the defs that were added, removed, or whose signatures changed.
+
+OLD and NEW are either revisions (commit IDs, branches, tags, etc.) that have been built with "src make", or files containing the output of "src api-surface -o json" (e.g., saved by a previous CI run).
+
+Removed defs and changed signatures are breaking changes. If there are any, apidiff exits with status 7 (after printing the changes), so it can be used to gate releases in CI.`,
+		&apiDiffCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type APISurfaceCmd struct {
+	UnitType string   `long:"unit-type" description:"only show source units of this type" value-name:"TYPE"`
+	Unit     UnitName `long:"unit" description:"only show the source unit with this name" value-name:"NAME"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+}
+
+var apiSurfaceCmd APISurfaceCmd
+
+func (c *APISurfaceCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	surfaces, _, err := readAPISurfaces(buildStore, repo, repo.CommitID, c.UnitType, string(c.Unit))
+	if err != nil {
+		return err
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(surfaces, "")
+	} else {
+		for _, s := range surfaces {
+			printAPISurface(os.Stdout, s)
+		}
+	}
+	return nil
+}
+
+// readAPISurfaces reads the API surfaces of the source units (optionally
+// only those with the given type and name) built for the given commit. It
+// also returns the source units whose API surfaces are missing.
+func readAPISurfaces(buildStore *buildstore.RepositoryStore, repo *Repo, commitID, unitType, unitName string) (surfaces []*apisurface.Surface, missing []*unit.SourceUnit, err error) {
+	commitRepo := *repo
+	commitRepo.CommitID = commitID
+	units, err := getSourceUnits(buildStore, &commitRepo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(units) == 0 {
+		return nil, nil, fmt.Errorf("no build data found for commit %s (run `src config` and `src make` at that commit)", commitID)
+	}
+
+	surfaces = []*apisurface.Surface{}
+	for _, u := range units {
+		if (unitType != "" && u.Type != unitType) || (unitName != "" && u.Name != unitName) {
+			continue
+		}
+
+		var s *apisurface.Surface
+		surfaceFile := buildStore.FilePath(commitID, plan.SourceUnitDataFilename(&apisurface.Surface{}, u))
+		if err := readBuildDataJSON(buildStore, surfaceFile, &s); os.IsNotExist(err) {
+			log.Printf("No API surface for source unit %q type %q at commit %s (run `src make` to extract it).", u.Name, u.Type, commitID)
+			missing = append(missing, u)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		surfaces = append(surfaces, s)
+	}
+	return surfaces, missing, nil
+}
+
+func printAPISurface(w io.Writer, s *apisurface.Surface) {
+	fmt.Fprintf(w, "%s %s\n", s.UnitType, s.Unit)
+	for _, d := range s.Defs {
+		sig := d.Signature
+		if sig == "" {
+			sig = fmt.Sprintf("%s %s", d.Kind, d.Name)
+		}
+		fmt.Fprintf(w, "\t%s\n", sig)
+		if d.Doc != "" {
+			for _, line := range strings.Split(strings.TrimSpace(d.Doc), "\n") {
+				fmt.Fprintf(w, "\t\t%s\n", line)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+type APIDiffCmd struct {
+	UnitType string   `long:"unit-type" description:"only compare source units of this type" value-name:"TYPE"`
+	Unit     UnitName `long:"unit" description:"only compare the source unit with this name" value-name:"NAME"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Old string `name:"OLD" description:"revision or API surface JSON file to compare from"`
+		New string `name:"NEW" description:"revision or API surface JSON file to compare to"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var apiDiffCmd APIDiffCmd
+
+func (c *APIDiffCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	oldSurfaces, oldMissing, err := c.readSurfaces(buildStore, repo, c.Args.Old)
+	if err != nil {
+		return err
+	}
+	newSurfaces, newMissing, err := c.readSurfaces(buildStore, repo, c.Args.New)
+	if err != nil {
+		return err
+	}
+	if err := checkMissingSurfaces(c.Args.Old, oldSurfaces, oldMissing, c.Args.New, newSurfaces, newMissing); err != nil {
+		return err
+	}
+
+	changes := apisurface.Diff(oldSurfaces, newSurfaces)
+	if c.Output.Output == "json" {
+		if changes == nil {
+			changes = []*apisurface.Change{}
+		}
+		PrintJSON(changes, "")
+	} else {
+		printAPIChanges(os.Stdout, changes)
+	}
+
+	var breaking int
+	for _, ch := range changes {
+		if ch.Breaking {
+			breaking++
+		}
+	}
+	if breaking > 0 {
+		return withKind(BreakingChanges, fmt.Errorf("%d breaking API change(s) between %s and %s", breaking, c.Args.Old, c.Args.New))
+	}
+	return nil
+}
+
+// readSurfaces reads the API surfaces identified by arg, which is either a
+// file containing the JSON output of `src api-surface` or a revision. For a
+// revision, it also returns the source units whose API surfaces are
+// missing.
+func (c *APIDiffCmd) readSurfaces(buildStore *buildstore.RepositoryStore, repo *Repo, arg string) ([]*apisurface.Surface, []*unit.SourceUnit, error) {
+	if fi, err := os.Stat(arg); err == nil && fi.Mode().IsRegular() {
+		var surfaces []*apisurface.Surface
+		if err := readJSONFile(arg, &surfaces); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", arg, err)
+		}
+		var filtered []*apisurface.Surface
+		for _, s := range surfaces {
+			if (c.UnitType == "" || s.UnitType == c.UnitType) && (c.Unit == "" || s.Unit == string(c.Unit)) {
+				filtered = append(filtered, s)
+			}
+		}
+		return filtered, nil, nil
+	}
+
+	commitID, err := resolveRevision(repo.VCSType, repo.RootDir, arg)
+	if err != nil {
+		return nil, nil, withKind(UsageError, fmt.Errorf("%q is neither a file nor a revision: %s", arg, err))
+	}
+	return readAPISurfaces(buildStore, repo, commitID, c.UnitType, string(c.Unit))
+}
+
+// checkMissingSurfaces returns an error if a source unit's API surface is
+// missing from one side of the comparison but not the other. Otherwise,
+// the diff would report all of the source unit's defs as added or removed
+// (and the removals as breaking changes) because of missing build data,
+// not because its API changed.
+func checkMissingSurfaces(oldArg string, oldSurfaces []*apisurface.Surface, oldMissing []*unit.SourceUnit, newArg string, newSurfaces []*apisurface.Surface, newMissing []*unit.SourceUnit) error {
+	has := func(surfaces []*apisurface.Surface, u *unit.SourceUnit) bool {
+		for _, s := range surfaces {
+			if s.UnitType == u.Type && s.Unit == u.Name {
+				return true
+			}
+		}
+		return false
+	}
+	var msgs []string
+	for _, u := range oldMissing {
+		if has(newSurfaces, u) {
+			msgs = append(msgs, fmt.Sprintf("source unit %q type %q has no API surface at %s", u.Name, u.Type, oldArg))
+		}
+	}
+	for _, u := range newMissing {
+		if has(oldSurfaces, u) {
+			msgs = append(msgs, fmt.Sprintf("source unit %q type %q has no API surface at %s", u.Name, u.Type, newArg))
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("can't compare API surfaces: %s (run `src make` at those revisions to extract them)", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func printAPIChanges(w io.Writer, changes []*apisurface.Change) {
+	for _, ch := range changes {
+		mark := " "
+		if ch.Breaking {
+			mark = "!"
+		}
+		d := ch.Def()
+		fmt.Fprintf(w, "%s %-17s %s %s %s\n", mark, ch.Kind, ch.UnitType, ch.Unit, d.Path)
+		if ch.Kind == apisurface.SignatureChanged {
+			fmt.Fprintf(w, "\t- %s\n\t+ %s\n", ch.Old.Signature, ch.New.Signature)
+		}
+	}
+}
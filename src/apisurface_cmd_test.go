@@ -0,0 +1,65 @@
+package src
+
+import (
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/apisurface"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestCheckMissingSurfaces(t *testing.T) {
+	a := &apisurface.Surface{UnitType: "t", Unit: "a", Defs: []*apisurface.Def{{Path: "F", Name: "F"}}}
+	b := &apisurface.Surface{UnitType: "t", Unit: "b"}
+	missingA := []*unit.SourceUnit{{Type: "t", Name: "a"}}
+
+	tests := map[string]struct {
+		oldSurfaces, newSurfaces []*apisurface.Surface
+		oldMissing, newMissing   []*unit.SourceUnit
+		wantErr                  string
+	}{
+		"none missing": {
+			oldSurfaces: []*apisurface.Surface{a, b},
+			newSurfaces: []*apisurface.Surface{a, b},
+		},
+		"missing at new only": {
+			oldSurfaces: []*apisurface.Surface{a, b},
+			newSurfaces: []*apisurface.Surface{b},
+			newMissing:  missingA,
+			wantErr:     `source unit "a" type "t" has no API surface at v2`,
+		},
+		"missing at old only": {
+			oldSurfaces: []*apisurface.Surface{b},
+			oldMissing:  missingA,
+			newSurfaces: []*apisurface.Surface{a, b},
+			wantErr:     `source unit "a" type "t" has no API surface at v1`,
+		},
+		"missing at both": {
+			oldSurfaces: []*apisurface.Surface{b},
+			oldMissing:  missingA,
+			newSurfaces: []*apisurface.Surface{b},
+			newMissing:  missingA,
+		},
+		"missing at new, unit added": {
+			oldSurfaces: []*apisurface.Surface{b},
+			newSurfaces: []*apisurface.Surface{b},
+			newMissing:  missingA,
+		},
+		// A source unit that was removed (rather than not extracted) is a
+		// breaking change that Diff reports.
+		"unit removed": {
+			oldSurfaces: []*apisurface.Surface{a, b},
+			newSurfaces: []*apisurface.Surface{b},
+		},
+	}
+	for label, test := range tests {
+		err := checkMissingSurfaces("v1", test.oldSurfaces, test.oldMissing, "v2", test.newSurfaces, test.newMissing)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: got error %q, want none", label, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", label, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+func init() {
+	_, err := CLI.AddCommand("bench",
+		"benchmark the analysis pipeline",
+		`Runs the analysis pipeline (config and make) on the repository rooted at DIR several times and reports timing and memory statistics.
+
+To compare two src programs, specify --bin twice (e.g., --bin src --bin /tmp/src-new). To compare two sets of toolchains, specify --srclibpath twice. Each combination of program and SRCLIBPATH is benchmarked separately.
+
+Unless --keep-cache is given, the build cache for the current commit is removed before each run so that every run does the full amount of work.`,
+		&benchCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type BenchCmd struct {
+	ToolchainExecOpt `group:"execution"`
+
+	Runs       int      `short:"n" long:"runs" default:"5" description:"number of times to run the pipeline" value-name:"N"`
+	PerPhase   bool     `long:"per-phase" description:"time the config and make phases separately (instead of do-all)"`
+	KeepCache  bool     `long:"keep-cache" description:"don't remove the build cache before each run"`
+	Bins       []string `long:"bin" description:"src program to benchmark (can be specified multiple times to compare)" value-name:"PROG"`
+	SrclibPath []string `long:"srclibpath" description:"SRCLIBPATH to use (can be specified multiple times to compare toolchains)" value-name:"PATH"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Dir Directory `name:"DIR" default:"." description:"root directory of tree to benchmark"`
+	} `positional-args:"yes"`
+}
+
+var benchCmd BenchCmd
+
+// BenchResult holds the statistics for all runs of a single phase with a
+// single program and SRCLIBPATH.
+type BenchResult struct {
+	Bin        string
+	SrclibPath string
+	Phase      string
+	Runs       int
+
+	Mean, P50, P90, Min, Max time.Duration
+
+	// MeanCPU is the mean user+system CPU time of the phase's process tree.
+	MeanCPU time.Duration
+
+	// MaxRSS is the largest peak resident set size (in bytes) observed in
+	// any run.
+	MaxRSS int64
+}
+
+type benchPhase struct {
+	name string
+	args []string
+
+	// clearCache is whether the build cache should be removed before this
+	// phase runs.
+	clearCache bool
+}
+
+func (c *BenchCmd) Execute(args []string) error {
+	if c.Runs <= 0 {
+		return fmt.Errorf("--runs must be positive (got %d)", c.Runs)
+	}
+	if c.Args.Dir == "" {
+		c.Args.Dir = "."
+	}
+	if len(c.Bins) == 0 {
+		c.Bins = []string{"src"}
+	}
+	if len(c.SrclibPath) == 0 {
+		c.SrclibPath = []string{srclib.Path}
+	}
+
+	currentRepo, err := OpenRepo(string(c.Args.Dir))
+	if err != nil {
+		return err
+	}
+	buildDataDir := filepath.Join(currentRepo.RootDir, buildstore.BuildDataDirName, currentRepo.CommitID)
+
+	execArgs := []string{"-m", c.ExeMethods}
+	var phases []benchPhase
+	if c.PerPhase {
+		phases = []benchPhase{
+			{name: "config", args: append([]string{"config"}, execArgs...), clearCache: true},
+			{name: "make", args: append([]string{"make"}, execArgs...)},
+		}
+	} else {
+		phases = []benchPhase{{name: "do-all", args: append([]string{"do-all"}, execArgs...), clearCache: true}}
+	}
+
+	var results []*BenchResult
+	for _, bin := range c.Bins {
+		for _, srclibPath := range c.SrclibPath {
+			durations := make([][]time.Duration, len(phases))
+			usages := make([][]util.ResourceUsage, len(phases))
+			for run := 0; run < c.Runs; run++ {
+				for i, phase := range phases {
+					if phase.clearCache && !c.KeepCache {
+						if err := os.RemoveAll(buildDataDir); err != nil {
+							return err
+						}
+					}
+					if verbose() {
+						log.Printf("Run %d/%d: %s %v (SRCLIBPATH=%s)", run+1, c.Runs, bin, phase.args, srclibPath)
+					}
+					d, u, err := benchRun(currentRepo.RootDir, bin, srclibPath, phase.args)
+					if err != nil {
+						return err
+					}
+					durations[i] = append(durations[i], d)
+					usages[i] = append(usages[i], u)
+				}
+			}
+			for i, phase := range phases {
+				r := summarizeBenchRuns(durations[i], usages[i])
+				r.Bin, r.SrclibPath, r.Phase = bin, srclibPath, phase.name
+				results = append(results, r)
+			}
+		}
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(results, "")
+	} else {
+		printBenchResults(os.Stdout, results)
+	}
+	return nil
+}
+
+// benchRun runs the src program bin once in dir and returns the wall-clock
+// time and resources it consumed.
+func benchRun(dir, bin, srclibPath string, args []string) (time.Duration, util.ResourceUsage, error) {
+	var buf bytes.Buffer
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "SRCLIBPATH="+srclibPath)
+	if verbose() {
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	} else {
+		cmd.Stdout, cmd.Stderr = &buf, &buf
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, util.ResourceUsage{}, fmt.Errorf("command %v failed: %s\n\nOutput was:\n%s", cmd.Args, err, buf.String())
+	}
+	return elapsed, util.ProcessResourceUsage(cmd.ProcessState), nil
+}
+
+func summarizeBenchRuns(durations []time.Duration, usages []util.ResourceUsage) *BenchResult {
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Sort(durationSlice(sorted))
+
+	r := &BenchResult{
+		Runs: len(sorted),
+		P50:  percentile(sorted, 50),
+		P90:  percentile(sorted, 90),
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+	}
+	var total, totalCPU time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	for _, u := range usages {
+		totalCPU += u.CPUTime()
+		if u.MaxRSS > r.MaxRSS {
+			r.MaxRSS = u.MaxRSS
+		}
+	}
+	r.Mean = total / time.Duration(len(sorted))
+	r.MeanCPU = totalCPU / time.Duration(len(usages))
+	return r
+}
+
+// percentile returns the pth percentile (using the nearest-rank method) of
+// sorted, which must be sorted in increasing order and non-empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func printBenchResults(w io.Writer, results []*BenchResult) {
+	fmtStr := "%-30s  %-8s  %4s  %10s  %10s  %10s  %10s  %10s  %10s  %9s\n"
+	fmt.Fprintf(w, fmtStr, "PROGRAM (SRCLIBPATH)", "PHASE", "RUNS", "MEAN", "MIN", "P50", "P90", "MAX", "CPU", "MAXRSS")
+	for _, r := range results {
+		fmt.Fprintf(w, fmtStr, benchVariant(r), r.Phase, fmt.Sprint(r.Runs),
+			roundDuration(r.Mean), roundDuration(r.Min), roundDuration(r.P50), roundDuration(r.P90), roundDuration(r.Max), roundDuration(r.MeanCPU),
+			fmt.Sprintf("%.1fMB", float64(r.MaxRSS)/(1024*1024)))
+	}
+	printBenchComparison(w, results)
+}
+
+// printBenchComparison prints how the results of each program and
+// SRCLIBPATH differ from those of the first (the baseline) for the same
+// phase. It prints nothing if only one was benchmarked.
+func printBenchComparison(w io.Writer, results []*BenchResult) {
+	fmtStr := "%-30s  %-8s  %10s  %10s  %10s  %10s\n"
+	baselines := map[string]*BenchResult{}
+	printedHeader := false
+	for _, r := range results {
+		base, present := baselines[r.Phase]
+		if !present {
+			baselines[r.Phase] = r
+			continue
+		}
+		if !printedHeader {
+			fmt.Fprintf(w, "\nCompared to %s:\n", benchVariant(base))
+			fmt.Fprintf(w, fmtStr, "PROGRAM (SRCLIBPATH)", "PHASE", "MEAN", "P90", "CPU", "MAXRSS")
+			printedHeader = true
+		}
+		fmt.Fprintf(w, fmtStr, benchVariant(r), r.Phase,
+			percentChange(float64(base.Mean), float64(r.Mean)),
+			percentChange(float64(base.P90), float64(r.P90)),
+			percentChange(float64(base.MeanCPU), float64(r.MeanCPU)),
+			percentChange(float64(base.MaxRSS), float64(r.MaxRSS)))
+	}
+}
+
+// benchVariant describes the program and SRCLIBPATH of r.
+func benchVariant(r *BenchResult) string {
+	if r.SrclibPath != srclib.Path {
+		return r.Bin + " (" + r.SrclibPath + ")"
+	}
+	return r.Bin
+}
+
+// percentChange formats the change from old to new as a percentage of old
+// (e.g., "+12.5%"), or "-" if old is 0.
+func percentChange(old, new float64) string {
+	if old == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (new-old)/old*100)
+}
+
+func roundDuration(d time.Duration) string {
+	return (d / time.Millisecond * time.Millisecond).String()
+}
+
+type durationSlice []time.Duration
+
+func (s durationSlice) Len() int           { return len(s) }
+func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
@@ -0,0 +1,123 @@
+package src
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := func(ns ...int) []time.Duration {
+		ds := make([]time.Duration, len(ns))
+		for i, n := range ns {
+			ds[i] = time.Duration(n) * time.Millisecond
+		}
+		return ds
+	}
+	tests := []struct {
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{ms(7), 50, 7 * time.Millisecond},
+		{ms(7), 90, 7 * time.Millisecond},
+		{ms(1, 2), 50, 1 * time.Millisecond},
+		{ms(1, 2), 90, 2 * time.Millisecond},
+		{ms(1, 2, 3, 4, 5), 0, 1 * time.Millisecond},
+		{ms(1, 2, 3, 4, 5), 50, 3 * time.Millisecond},
+		{ms(1, 2, 3, 4, 5), 90, 5 * time.Millisecond},
+		{ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 90, 9 * time.Millisecond},
+		{ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 100, 10 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := percentile(test.sorted, test.p); got != test.want {
+			t.Errorf("percentile(%v, %v): got %v, want %v", test.sorted, test.p, got, test.want)
+		}
+	}
+}
+
+func TestSummarizeBenchRuns(t *testing.T) {
+	tests := []struct {
+		durations []time.Duration
+		usages    []util.ResourceUsage
+		want      BenchResult
+	}{
+		{
+			durations: []time.Duration{3 * time.Second},
+			usages:    []util.ResourceUsage{{UserTime: time.Second, SystemTime: time.Second, MaxRSS: 100}},
+			want: BenchResult{
+				Runs: 1,
+				Mean: 3 * time.Second, P50: 3 * time.Second, P90: 3 * time.Second, Min: 3 * time.Second, Max: 3 * time.Second,
+				MeanCPU: 2 * time.Second,
+				MaxRSS:  100,
+			},
+		},
+		{
+			durations: []time.Duration{4 * time.Second, 1 * time.Second, 3 * time.Second, 2 * time.Second},
+			usages: []util.ResourceUsage{
+				{UserTime: 4 * time.Second, MaxRSS: 300},
+				{UserTime: 1 * time.Second, MaxRSS: 100},
+				{UserTime: 2 * time.Second, SystemTime: time.Second, MaxRSS: 400},
+				{UserTime: 2 * time.Second, MaxRSS: 200},
+			},
+			want: BenchResult{
+				Runs: 4,
+				Mean: 2500 * time.Millisecond, P50: 2 * time.Second, P90: 4 * time.Second, Min: 1 * time.Second, Max: 4 * time.Second,
+				MeanCPU: 2500 * time.Millisecond,
+				MaxRSS:  400,
+			},
+		},
+	}
+	for _, test := range tests {
+		got := summarizeBenchRuns(test.durations, test.usages)
+		if *got != test.want {
+			t.Errorf("summarizeBenchRuns(%v): got %+v, want %+v", test.durations, *got, test.want)
+		}
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		old, new float64
+		want     string
+	}{
+		{100, 125, "+25.0%"},
+		{100, 50, "-50.0%"},
+		{100, 100, "+0.0%"},
+		{0, 100, "-"},
+	}
+	for _, test := range tests {
+		if got := percentChange(test.old, test.new); got != test.want {
+			t.Errorf("percentChange(%v, %v): got %q, want %q", test.old, test.new, got, test.want)
+		}
+	}
+}
+
+func TestPrintBenchResults_comparison(t *testing.T) {
+	results := []*BenchResult{
+		{Bin: "src", SrclibPath: srclib.Path, Phase: "do-all", Runs: 1, Mean: 2 * time.Second, P90: 2 * time.Second, MeanCPU: time.Second, MaxRSS: 1000},
+		{Bin: "src-new", SrclibPath: srclib.Path, Phase: "do-all", Runs: 1, Mean: time.Second, P90: 3 * time.Second, MeanCPU: time.Second, MaxRSS: 1500},
+	}
+	var buf bytes.Buffer
+	printBenchResults(&buf, results)
+	out := buf.String()
+	if !strings.Contains(out, "Compared to src:") {
+		t.Fatalf("got no comparison to the baseline in output:\n%s", out)
+	}
+	comparison := out[strings.Index(out, "Compared to src:"):]
+	for _, want := range []string{"src-new", "-50.0%", "+50.0%", "+0.0%"} {
+		if !strings.Contains(comparison, want) {
+			t.Errorf("got comparison without %q:\n%s", want, comparison)
+		}
+	}
+
+	buf.Reset()
+	printBenchResults(&buf, results[:1])
+	if strings.Contains(buf.String(), "Compared to") {
+		t.Errorf("got a comparison with only one program:\n%s", buf.String())
+	}
+}
@@ -1,57 +1,113 @@
-//+build off
-
 package src
 
 import (
-	"flag"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
-	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
 	"sourcegraph.com/sourcegraph/srclib/vcsutil"
 )
 
-func blame(args []string) {
-	fs := flag.NewFlagSet("blame", flag.ExitOnError)
-	fs.Usage = func() {
-		fmt.Fprintln(os.Stderr, `usage: `+Name+` blame [options] [unit...]
+func init() {
+	_, err := CLI.AddCommand("blame",
+		"show who last changed each part of a file",
+		`Show the commit, author, and author date that last changed each range of lines (hunk) of a file, according to the blame data built by "src make". Only the files of source units are blamed.
+
+With --line, only the hunk that contains the line is shown.`,
+		&blameCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type BlameCmd struct {
+	Line int `long:"line" description:"only show the hunk that contains this line (1-based)" value-name:"N"`
 
-Blames the files in a source unit. If no source units are specified, all source
-units are blamed.
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
 
-The options are:
-`)
-		fs.PrintDefaults()
-		os.Exit(1)
+	Args struct {
+		File string `name:"FILE" description:"path of the file, relative to the current directory"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var blameCmd BlameCmd
+
+func (c *BlameCmd) Execute(args []string) error {
+	file, err := filepath.Abs(c.Args.File)
+	if err != nil {
+		return err
 	}
-	fs.Parse(args)
-	sourceUnitSpecs := fs.Args()
 
-	repoConf, err := OpenAndConfigureRepo(Dir)
+	repo, err := OpenRepo(filepath.Dir(file))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	for _, u := range repoConf.Config.SourceUnits {
-		if !SourceUnitMatchesArgs(sourceUnitSpecs, u) {
-			continue
-		}
+	file, err = filepath.Rel(repo.RootDir, file)
+	if err != nil {
+		return err
+	}
 
-		files, err := unit.ExpandPaths(repoConf.RootDir, u.Files)
-		if err != nil {
-			log.Fatal(err)
-		}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+	b, err := readBlame(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return fmt.Errorf("no blame data found for commit %s (run `src make` first)", repo.CommitID)
+	}
 
-		var out *vcsutil.BlameOutput
-		if files == nil {
-			out, err = vcsutil.BlameRepository(repoConf.RootDir, repoConf.CommitID, repoConf.Config)
-		} else {
-			out, err = vcsutil.BlameFiles(repoConf.RootDir, files, repoConf.CommitID, repoConf.Config)
+	hunks := b.File(file)
+	if hunks == nil {
+		return fmt.Errorf("file %s was not blamed (it isn't in any source unit)", file)
+	}
+	if c.Line != 0 {
+		h := b.Line(file, c.Line)
+		if h == nil {
+			return withKind(UsageError, fmt.Errorf("file %s has no line %d", file, c.Line))
 		}
-		if err != nil {
-			log.Fatal(err)
+		hunks = []*vcsutil.BlamedHunk{h}
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(hunks, "")
+		return nil
+	}
+	fmtStr := "%-11s  %-12s  %-10s  %s\n"
+	fmt.Printf(fmtStr, "LINES", "COMMIT", "DATE", "AUTHOR")
+	for _, h := range hunks {
+		commitID := h.CommitID
+		if len(commitID) > 12 {
+			commitID = commitID[:12]
 		}
-		PrintJSON(out, "")
+		fmt.Printf(fmtStr, fmt.Sprintf("%d-%d", h.StartLine, h.EndLine-1), commitID, h.AuthorDate.Format("2006-01-02"), fmt.Sprintf("%s <%s>", h.Author, h.AuthorEmail))
+	}
+	return nil
+}
+
+// readBlame reads the blame of the repository's files from the build data
+// built by `src make`. If there is none, it returns nil.
+func readBlame(buildStore *buildstore.RepositoryStore, repo *Repo) (*vcsutil.BlameOutput, error) {
+	blameFile := buildStore.FilePath(repo.CommitID, vcsutil.BlameFilename)
+	f, err := buildStore.Open(blameFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var b *vcsutil.BlameOutput
+	if err := json.NewDecoder(f).Decode(&b); err != nil {
+		return nil, fmt.Errorf("%s: %s", blameFile, err)
 	}
+	return b, nil
 }
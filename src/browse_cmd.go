@@ -0,0 +1,191 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/browse"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+)
+
+func init() {
+	_, err := CLI.AddCommand("browse",
+		"browse build data in a web browser",
+		`Serves a web UI for exploring the current repository's build data (built by "src make"). It shows the repository's files with each ref hyperlinked to its def (with the def's documentation shown on hover), and lists the references to each def.
+
+It also serves a versioned JSON API for querying the build data under /api/v1/. The API's OpenAPI specification is served at /api/v1/openapi.json, and printed by "src browse --openapi".
+
+A GraphQL endpoint at /api/v1/graphql allows querying the repository's units, files, defs, refs, and docs in a single request. Its schema is printed by "src browse --graphql-schema".
+
+With --watch, the build data is reloaded when it changes (e.g., after running "src make" again), and the changes (source units rebuilt or removed, and files whose defs or refs changed) are streamed as server-sent events to clients of /api/v1/events, so that editors and other UIs can refresh incrementally.`,
+		&browseCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type BrowseCmd struct {
+	HTTPAddr string `long:"http" description:"HTTP listen address" default:"localhost:7080" value-name:"ADDR"`
+
+	Watch         bool          `long:"watch" description:"reload the build data when it changes, and stream the changes to clients of /api/v1/events"`
+	WatchInterval time.Duration `long:"watch-interval" description:"how often to check for changes to the build data in watch mode" default:"1s" value-name:"DURATION"`
+
+	OpenAPI       bool `long:"openapi" description:"print the JSON API's OpenAPI specification and exit"`
+	GraphQLSchema bool `long:"graphql-schema" description:"print the GraphQL endpoint's schema and exit"`
+}
+
+var browseCmd BrowseCmd
+
+func (c *BrowseCmd) Execute(args []string) error {
+	if c.OpenAPI {
+		PrintJSON(browse.OpenAPISpec(), "")
+		return nil
+	}
+	if c.GraphQLSchema {
+		fmt.Print(browse.GraphQLSchema())
+		return nil
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	graphFiles, err := readBrowseGraphFiles(buildStore, repo, nil)
+	if err != nil {
+		return err
+	}
+	site := newBrowseSite(repo, graphFiles)
+	if site.Blame, err = readBlame(buildStore, repo); err != nil {
+		return err
+	}
+	srv := browse.NewServer(site)
+	if c.Watch {
+		go func() {
+			for range time.Tick(c.WatchInterval) {
+				graphFiles2, err := readBrowseGraphFiles(buildStore, repo, graphFiles)
+				if err != nil {
+					log.Printf("Error reloading build data: %s.", err)
+					continue
+				}
+				if !graphFilesChanged(graphFiles, graphFiles2) {
+					continue
+				}
+				graphFiles = graphFiles2
+				site := newBrowseSite(repo, graphFiles)
+				if site.Blame, err = readBlame(buildStore, repo); err != nil {
+					log.Printf("Error reloading blame data: %s.", err)
+				}
+				events := srv.Update(site)
+				log.Printf("Reloaded build data (%d changes).", len(events))
+			}
+		}()
+	}
+
+	log.Printf("Serving %s on http://%s/", repo.URI(), c.HTTPAddr)
+	return http.ListenAndServe(c.HTTPAddr, srv)
+}
+
+// A browseGraphFile is the graph data of a source unit, read from a file in
+// the build store.
+type browseGraphFile struct {
+	modTime time.Time
+	unit    *browse.Unit
+}
+
+// readBrowseSite returns a site for browsing the graph data of the repo's
+// source units.
+func readBrowseSite(buildStore *buildstore.RepositoryStore, repo *Repo) (*browse.Site, error) {
+	graphFiles, err := readBrowseGraphFiles(buildStore, repo, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newBrowseSite(repo, graphFiles), nil
+}
+
+// readBrowseGraphFiles reads the graph data of the repo's source units,
+// keyed by graph file path. Graph files that haven't been modified since
+// they were read into prev are not read again.
+func readBrowseGraphFiles(buildStore *buildstore.RepositoryStore, repo *Repo, prev map[string]*browseGraphFile) (map[string]*browseGraphFile, error) {
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	graphFiles := make(map[string]*browseGraphFile)
+	for _, u := range units {
+		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+		fi, err := buildStore.Stat(graphFile)
+		if os.IsNotExist(err) {
+			if verbose() && prev == nil {
+				log.Printf("No graph data for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if gf := prev[graphFile]; gf != nil && gf.modTime.Equal(fi.ModTime()) {
+			graphFiles[graphFile] = gf
+			continue
+		}
+
+		f, err := buildStore.Open(graphFile)
+		if err != nil {
+			return nil, err
+		}
+		g, err := grapher.ReadOutput(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", graphFile, err)
+		}
+		graphFiles[graphFile] = &browseGraphFile{
+			modTime: fi.ModTime(),
+			unit:    &browse.Unit{Type: u.Type, Name: u.Name, Files: u.Files, Output: g},
+		}
+	}
+	if len(graphFiles) == 0 {
+		return nil, fmt.Errorf("no graph data found for commit %s (run `src make` first)", repo.CommitID)
+	}
+	return graphFiles, nil
+}
+
+// graphFilesChanged reports whether any graph files were added, removed, or
+// reread between a and b.
+func graphFilesChanged(a, b map[string]*browseGraphFile) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for path, gf := range a {
+		if b[path] != gf {
+			return true
+		}
+	}
+	return false
+}
+
+func newBrowseSite(repo *Repo, graphFiles map[string]*browseGraphFile) *browse.Site {
+	units := make([]*browse.Unit, 0, len(graphFiles))
+	for _, gf := range graphFiles {
+		units = append(units, gf.unit)
+	}
+	readFile := func(file string) ([]byte, error) {
+		path, err := joinInDir(repo.RootDir, file)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadFile(path)
+	}
+	return browse.NewSite(repo.URI(), units, readFile)
+}
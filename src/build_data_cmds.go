@@ -137,7 +137,7 @@ func fetchFile(repoStore *buildstore.RepositoryStore, repoURI string, fi *builds
 	}
 
 	kb := float64(fi.Size) / 1024
-	if GlobalOpt.Verbose {
+	if verbose() {
 		log.Printf("Fetching %s (%.1fkb)", path, kb)
 	}
 
@@ -146,7 +146,7 @@ func fetchFile(repoStore *buildstore.RepositoryStore, repoURI string, fi *builds
 		log.Fatal(err)
 	}
 
-	if GlobalOpt.Verbose {
+	if verbose() {
 		log.Printf("Fetched %s (%.1fkb)", path, kb)
 	}
 
@@ -166,7 +166,7 @@ func fetchFile(repoStore *buildstore.RepositoryStore, repoURI string, fi *builds
 		log.Fatal(err)
 	}
 
-	if GlobalOpt.Verbose {
+	if verbose() {
 		log.Printf("Saved %s", path)
 	}
 }
@@ -225,14 +225,14 @@ func uploadFile(repoStore *buildstore.RepositoryStore, file *buildstore.BuildDat
 
 	fi, err := repoStore.Stat(path)
 	if err != nil || !fi.Mode().IsRegular() {
-		if GlobalOpt.Verbose {
+		if verbose() {
 			log.Printf("upload: skipping nonexistent file %s", path)
 		}
 		return
 	}
 
 	kb := float64(fi.Size()) / 1024
-	if GlobalOpt.Verbose {
+	if verbose() {
 		log.Printf("Uploading %s (%.1fkb)", path, kb)
 	}
 
@@ -246,7 +246,7 @@ func uploadFile(repoStore *buildstore.RepositoryStore, file *buildstore.BuildDat
 		log.Fatal(err)
 	}
 
-	if GlobalOpt.Verbose {
+	if verbose() {
 		log.Printf("Uploaded %s (%.1fkb)", path, kb)
 	}
 }
@@ -0,0 +1,101 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+)
+
+func init() {
+	_, err := CLI.AddCommand("builds",
+		"list builds by branch",
+		`Lists the builds of the current repository on each branch (or only on BRANCH), most recent first. "src make" records each successful build on the branch that is checked out; builds whose build data has since been removed are omitted.
+
+With --latest, only the latest build on each branch is listed (so "src builds --latest --branch BRANCH" prints the commit whose build data queries about BRANCH use). With --last, the build that commands querying the current working tree (such as the editor commands) use is printed instead: that of the current commit, if it has been built, or else of its nearest built ancestor, or else the latest build on the current branch.`,
+		&buildsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type BuildsCmd struct {
+	Branch string `long:"branch" description:"only list builds on this branch" value-name:"BRANCH"`
+	Latest bool   `long:"latest" description:"only list the latest build on each branch"`
+	Last   bool   `long:"last" description:"print the commit of the build that queries about the current working tree use"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+}
+
+var buildsCmd BuildsCmd
+
+// branchBuilds lists the builds on a branch in the output of "src builds".
+type branchBuilds struct {
+	Branch string
+	Builds []*buildstore.BranchBuild
+}
+
+func (c *BuildsCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	if c.Last {
+		if c.Branch != "" || c.Latest {
+			return withKind(UsageError, fmt.Errorf("--last can't be given with --branch or --latest"))
+		}
+		built, err := lastBuild(buildStore, repo)
+		if err != nil {
+			return err
+		}
+		if c.Output.Output == "json" {
+			PrintJSON(struct{ CommitID string }{built.CommitID}, "")
+		} else {
+			fmt.Println(built.CommitID)
+		}
+		return nil
+	}
+
+	branches := []string{c.Branch}
+	if c.Branch == "" {
+		if branches, err = buildStore.Branches(); err != nil {
+			return err
+		}
+	}
+	out := []*branchBuilds{}
+	for _, branch := range branches {
+		builds, err := buildStore.BranchBuilds(branch)
+		if err != nil {
+			return err
+		}
+		if len(builds) == 0 {
+			continue
+		}
+		if c.Latest {
+			builds = builds[:1]
+		}
+		out = append(out, &branchBuilds{Branch: branch, Builds: builds})
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(out, "")
+		return nil
+	}
+	fmtStr := "%-30s  %-40s  %s\n"
+	fmt.Printf(fmtStr, "BRANCH", "COMMIT", "BUILT")
+	for _, b := range out {
+		for _, build := range b.Builds {
+			fmt.Printf(fmtStr, b.Branch, build.CommitID, build.Time.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/depsrc"
+)
+
+func init() {
+	c, err := CLI.AddCommand("cache",
+		"manage the dependency source cache",
+		"Manage the cache of dependency repository checkouts (in the deps directory of SRCLIBCACHE) that graphers with the dep-sources capability read.",
+		&cacheCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("list",
+		"list cached dependency checkouts",
+		"List cached dependency checkouts, most recently used first.",
+		&cacheListCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("prune",
+		"remove old cached dependency checkouts",
+		"Remove dependency checkouts that haven't been used recently, and then the least recently used checkouts until the cache is small enough. Checkouts used in the last hour are never removed.",
+		&cachePruneCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type CacheCmd struct{}
+
+var cacheCmd CacheCmd
+
+func (c *CacheCmd) Execute(args []string) error { return nil }
+
+type CacheListCmd struct{}
+
+var cacheListCmd CacheListCmd
+
+func (c *CacheListCmd) Execute(args []string) error {
+	entries, err := depsrc.DefaultCache().List()
+	if err != nil {
+		return err
+	}
+
+	fmtStr := "%-50s  %-40s  %10s  %s\n"
+	fmt.Printf(fmtStr, "REPO", "COMMIT", "SIZE", "LAST USED")
+	var total int64
+	for _, e := range entries {
+		fmt.Printf(fmtStr, e.Repo, e.CommitID, formatByteSize(e.Size), e.LastUsed.Format(time.RFC3339))
+		total += e.Size
+	}
+	fmt.Printf("%d checkouts, %s\n", len(entries), formatByteSize(total))
+	return nil
+}
+
+type CachePruneCmd struct {
+	MaxAge  time.Duration `long:"max-age" description:"remove checkouts that haven't been used for this long (0 for no limit)" default:"720h" value-name:"DURATION"`
+	MaxSize string        `long:"max-size" description:"then remove the least recently used checkouts until the cache is no larger than SIZE (e.g., 512M or 10G)" value-name:"SIZE"`
+	DryRun  bool          `short:"n" long:"dry-run" description:"list the checkouts that would be removed, but don't remove them"`
+}
+
+var cachePruneCmd CachePruneCmd
+
+func (c *CachePruneCmd) Execute(args []string) error {
+	opt := depsrc.PruneOptions{MaxAge: c.MaxAge, DryRun: c.DryRun}
+	if c.MaxSize != "" {
+		var err error
+		if opt.MaxSize, err = parseByteSize(c.MaxSize); err != nil {
+			return withKind(UsageError, err)
+		}
+	}
+
+	removed, err := depsrc.DefaultCache().Prune(opt)
+	verb := "Removed"
+	if c.DryRun {
+		verb = "Would remove"
+	}
+	var total int64
+	for _, e := range removed {
+		fmt.Printf("%s %s@%s (%s)\n", verb, e.Repo, e.CommitID, formatByteSize(e.Size))
+		total += e.Size
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %d checkouts, %s\n", verb, len(removed), formatByteSize(total))
+	return nil
+}
+
+// formatByteSize formats a size in bytes with the largest unit (K, M, G,
+// or T, which are powers of 1024, as in parseByteSize) that it has at
+// least one of.
+func formatByteSize(n int64) string {
+	const units = "KMGT"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f := float64(n)
+	i := -1
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", f, units[i])
+}
@@ -0,0 +1,131 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/detect"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func init() {
+	_, err := CLI.AddCommand("check-config",
+		"check that the Srcfile covers the tree",
+		`Detects the languages, source units, and vendored directories in the tree in the current directory (as "src init" does) and reports what the Srcfile doesn't cover: languages whose toolchain has no scanner in its Scanners, likely source units that aren't in its SourceUnits (if it lists them), and vendored directories that aren't in its SkipDirs. It exits with a non-zero status if the Srcfile doesn't cover everything.
+
+With --write, adds the missing scanners and vendored directories to the Srcfile. (Missing source units must be added by hand.)
+
+"src config" also warns about these.`,
+		&checkConfigCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type CheckConfigCmd struct {
+	Write bool `short:"w" long:"write" description:"add the missing scanners and vendored directories to the Srcfile"`
+}
+
+var checkConfigCmd CheckConfigCmd
+
+func (c *CheckConfigCmd) Execute(args []string) error {
+	fs := vfs.OS(".")
+	cfg, drift, err := configDrift(fs)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return withKind(ConfigError, fmt.Errorf("no %s found (run `src init` to write one)", config.Filename))
+	}
+	if drift.Empty() {
+		log.Printf("%s covers all detected languages, source units, and vendored directories.", config.Filename)
+		return nil
+	}
+
+	if c.Write {
+		for _, lang := range drift.Languages {
+			scanners, installed := languageScanners(lang.Language)
+			cfg.Scanners = append(cfg.Scanners, scanners...)
+			if !installed {
+				log.Printf("Warning: the toolchain %s isn't installed (run `src toolchain get %s`).", lang.Toolchain, lang.Toolchain)
+			}
+		}
+		cfg.SkipDirs = append(cfg.SkipDirs, drift.SkipDirs...)
+		data, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(config.Filename, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		log.Printf("Added %d scanners and %d skipped directories to %s.", len(drift.Languages), len(drift.SkipDirs), config.Filename)
+		drift.Languages, drift.SkipDirs = nil, nil
+		if drift.Empty() {
+			return nil
+		}
+	}
+
+	for _, msg := range driftMessages(drift) {
+		fmt.Println(msg)
+	}
+	return withKind(ConfigError, fmt.Errorf("%s doesn't cover the whole tree", config.Filename))
+}
+
+// readSrcfile reads the Srcfile at the root of the tree fs, without
+// overrides or defaults. If there is no Srcfile, it returns nil.
+func readSrcfile(fs vfs.FileSystem) (*config.Repository, error) {
+	data, err := fs.ReadFile(config.Filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cfg, err := config.ParseSrcfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", config.Filename, err)
+	}
+	return cfg, nil
+}
+
+// configDrift reads the Srcfile at the root of the tree fs and compares it
+// to what detect.Detect finds in the tree. If there is no Srcfile, cfg and
+// drift are nil.
+func configDrift(fs vfs.FileSystem) (cfg *config.Repository, drift *detect.Drift, err error) {
+	cfg, err = readSrcfile(fs)
+	if cfg == nil || err != nil {
+		return nil, nil, err
+	}
+	res, err := detect.Detect(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, detect.Compare(&cfg.Tree, res), nil
+}
+
+// driftMessages describes what the Srcfile doesn't cover, one message per
+// language, set of source units, or directory.
+func driftMessages(drift *detect.Drift) []string {
+	var msgs []string
+	for _, lang := range drift.Languages {
+		msgs = append(msgs, fmt.Sprintf("%s has no scanner for %s (%d files); add the scanner of %s to its Scanners.", config.Filename, lang.Name, lang.Files, lang.Toolchain))
+	}
+	var langs []string
+	for lang := range drift.UnitDirs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		msgs = append(msgs, fmt.Sprintf("%s lists no %s source units in these directories, which probably contain some: %s.", config.Filename, lang, strings.Join(drift.UnitDirs[lang], ", ")))
+	}
+	for _, dir := range drift.SkipDirs {
+		msgs = append(msgs, fmt.Sprintf("%s doesn't skip %s, which looks like a directory of vendored dependencies.", config.Filename, dir))
+	}
+	return msgs
+}
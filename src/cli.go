@@ -1,35 +1,162 @@
 package src
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/sourcegraph/httpcache"
 	"github.com/sourcegraph/httpcache/diskcache"
 	"github.com/sqs/go-flags"
 	client "sourcegraph.com/sourcegraph/go-sourcegraph/sourcegraph"
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/repo"
 	"sourcegraph.com/sourcegraph/srclib/task2"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
 )
 
-var CLI = flags.NewNamedParser("src", flags.Default)
+// CLI is the src command-line parser. It doesn't print errors itself; Main
+// prints them in the format specified by --error-format.
+var CLI = flags.NewNamedParser("src", flags.Default&^flags.PrintErrors)
 
 // GlobalOpt contains global options.
 var GlobalOpt struct {
-	Verbose bool `short:"v" description:"show verbose output"`
+	Verbose     func()    `short:"v" description:"show verbose output (repeat, as in -vv, for more)"`
+	Quiet       func()    `short:"q" long:"quiet" description:"only show warnings and errors (and the output of tools that fail)"`
+	ErrorFormat string    `long:"error-format" description:"format of the error printed (to stderr) if src fails" default:"text" value-name:"text|json"`
+	Record      recordDir `long:"record" description:"record the exact input and output of each tool run in DIR (to reproduce the build with --replay)" value-name:"DIR"`
+	Replay      replayDir `long:"replay" description:"instead of running tools, replay the tool runs recorded (with --record) in DIR" value-name:"DIR"`
+	Color       colorMode `long:"color" description:"when to color human-readable output (auto: only when writing to a terminal)" default:"auto" value-name:"auto|always|never"`
+}
+
+// colorMode is the --color mode (see newPrinter).
+type colorMode string
+
+func (m *colorMode) UnmarshalFlag(value string) error {
+	switch value {
+	case "auto", "always", "never":
+		*m = colorMode(value)
+		return nil
+	}
+	return fmt.Errorf("invalid --color %q (must be auto, always, or never)", value)
+}
+
+// recordDir is the --record directory. Tool runs (including those in
+// subprocesses, such as "src tool" in Makefile recipes) find it in the
+// environment.
+type recordDir string
+
+func (d *recordDir) UnmarshalFlag(value string) error {
+	return setEnvDir(toolchain.RecordEnv, value, (*string)(d))
+}
+
+// replayDir is the --replay directory. Like recordDir, it is passed to
+// tool runs in the environment.
+type replayDir string
+
+func (d *replayDir) UnmarshalFlag(value string) error {
+	return setEnvDir(toolchain.ReplayEnv, value, (*string)(d))
+}
+
+// setEnvDir sets *dir and the environment variable env to the absolute
+// path of the directory dirPath (since tools may run in other
+// directories).
+func setEnvDir(env, dirPath string, dir *string) error {
+	abs, err := filepath.Abs(dirPath)
+	if err != nil {
+		return err
+	}
+	*dir = abs
+	return os.Setenv(env, abs)
 }
 
 func init() {
+	GlobalOpt.Verbose = func() { setVerbosity(toolchain.Verbosity() + 1) }
+	GlobalOpt.Quiet = func() { setVerbosity(toolchain.Quiet) }
+
 	CLI.LongDescription = "src builds projects, analyzes source code, and queries Sourcegraph."
 	CLI.AddGroup("Global options", "", &GlobalOpt)
 }
 
+// setVerbosity sets the verbosity level (see toolchain.Verbosity), which is
+// passed to subprocesses in the environment, and configures logging for
+// it.
+func setVerbosity(level int) {
+	if err := toolchain.SetVerbosity(level); err != nil {
+		log.Fatal(err)
+	}
+	applyVerbosity()
+}
+
+// applyVerbosity configures logging for the verbosity level: log messages
+// are timestamped at toolchain.VeryVerbose.
+func applyVerbosity() {
+	if toolchain.Verbosity() == toolchain.VeryVerbose {
+		log.SetFlags(log.Lmicroseconds)
+	} else {
+		log.SetFlags(0)
+	}
+}
+
+// verbose reports whether verbose output was requested (with -v, or by the
+// verbosity level in the environment).
+func verbose() bool { return toolchain.Verbosity() >= toolchain.Verbose }
+
+// quiet reports whether quiet output was requested (with -q, or by the
+// verbosity level in the environment). Progress messages aren't shown, but
+// warnings and errors are.
+func quiet() bool { return toolchain.Verbosity() == toolchain.Quiet }
+
+// vanityImportsFile is the file in the first SRCLIBPATH directory that
+// overrides the resolution of vanity Go import paths (see
+// repo.VanityResolver).
+const vanityImportsFile = "vanity-imports.json"
+
+func init() {
+	overrides := filepath.Join(strings.SplitN(srclib.Path, ":", 2)[0], vanityImportsFile)
+	r, err := repo.NewVanityResolver(overrides, filepath.Join(srclib.CacheDir, vanityImportsFile))
+	if err != nil {
+		log.Printf("Warning: not resolving vanity Go import paths: %s.", err)
+		return
+	}
+	repo.Vanity = r
+}
+
 // TODO(sqs): add base URL flag for apiclient
 var (
-	httpClient = http.Client{Transport: httpcache.NewTransport(diskcache.New("/tmp/srclib-cache"))}
+	httpClient = http.Client{Transport: cachingTransport()}
 	apiclient  = client.NewClient(&httpClient)
 )
 
+func cachingTransport() http.RoundTripper {
+	t := httpcache.NewTransport(diskcache.New("/tmp/srclib-cache"))
+	t.Transport = network.Transport
+	return t
+}
+
+// init applies the network settings, which come from (in increasing order
+// of precedence) the SRCLIBPATH config, the Srcfile in the current
+// directory, and the environment.
+func init() {
+	s := new(network.Settings)
+	if config.SrclibPathConfig.Network != nil {
+		s.Override(config.SrclibPathConfig.Network)
+	}
+	// An invalid Srcfile is reported by the commands that use it.
+	if c, err := config.ReadRepository(".", ""); err == nil && c.Network != nil {
+		s.Override(c.Network)
+	}
+	s.Override(network.FromEnv())
+	if err := network.Apply(s); err != nil {
+		log.Printf("Warning: ignoring invalid network settings: %s.", err)
+	}
+}
+
 var (
 	absDir string
 )
@@ -43,12 +170,17 @@ func init() {
 }
 
 func Main() {
-	log.SetFlags(0)
 	log.SetPrefix("")
+	applyVerbosity()
 	defer task2.FlushAll()
 
 	if _, err := CLI.Parse(); err != nil {
-		os.Exit(1)
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			fmt.Println(err)
+			return
+		}
+		task2.FlushAll()
+		os.Exit(reportError(os.Stderr, GlobalOpt.ErrorFormat, err))
 	}
 }
 
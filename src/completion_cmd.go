@@ -0,0 +1,87 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sqs/go-flags"
+)
+
+func init() {
+	_, err := CLI.AddCommand("completion",
+		"print a shell completion script",
+		`Prints a script that sets up completion of src's commands, options, and arguments in the given shell (bash, zsh, or fish). To enable it, add one of the following to your shell's startup file:
+
+    source <(src completion bash)    # ~/.bashrc
+    source <(src completion zsh)     # ~/.zshrc
+    src completion fish | source     # ~/.config/fish/config.fish
+
+The script calls src itself to complete each word, so the completions are always those of the installed version of src. In addition to command and option names, it completes the names of installed toolchains, and the names of the source units in the current repository (found by the last "src config" or "src make").`,
+		&completionCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type CompletionCmd struct {
+	Args struct {
+		Shell completionShell `name:"SHELL" description:"shell to print the completion script for (bash, zsh, or fish)"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var completionCmd CompletionCmd
+
+// completionScripts are the completion scripts for each supported shell.
+// They run src with the GO_FLAGS_COMPLETION environment variable set, which
+// makes the command-line parser print the completions of the last word
+// (using the flags.Completer implementations of argument types, such as
+// ToolchainPath and UnitName) instead of running the command.
+var completionScripts = map[string]string{
+	"bash": `_src() {
+	local IFS=$'\n'
+	COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${COMP_WORDS[0]}" "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+	return 0
+}
+complete -o default -F _src src
+`,
+	"zsh": `#compdef src
+_src() {
+	local -a completions
+	completions=("${(@f)$(GO_FLAGS_COMPLETION=1 ${words[1]} "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+	compadd -a completions
+}
+compdef _src src
+`,
+	"fish": `function __src_complete
+	set -l args (commandline -opc) (commandline -ct)
+	set -e args[1]
+	env GO_FLAGS_COMPLETION=1 src $args 2>/dev/null
+end
+complete -c src -f -a '(__src_complete)'
+`,
+}
+
+// completionShell is the name of a shell that has a completion script.
+type completionShell string
+
+func (s completionShell) Complete(match string) []flags.Completion {
+	var comps []flags.Completion
+	for _, shell := range []string{"bash", "fish", "zsh"} {
+		if strings.HasPrefix(shell, match) {
+			comps = append(comps, flags.Completion{Item: shell})
+		}
+	}
+	return comps
+}
+
+func (c *CompletionCmd) Execute(args []string) error {
+	script, present := completionScripts[string(c.Args.Shell)]
+	if !present {
+		return withKind(UsageError, fmt.Errorf("unsupported shell %q (must be bash, zsh, or fish)", c.Args.Shell))
+	}
+	_, err := fmt.Fprint(os.Stdout, script)
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/sourcegraph/rwvfs"
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
@@ -17,6 +18,7 @@ import (
 	"sourcegraph.com/sourcegraph/srclib/plan"
 	"sourcegraph.com/sourcegraph/srclib/repo"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
 
 	"sourcegraph.com/sourcegraph/srclib/unit"
 )
@@ -48,8 +50,8 @@ The default values for --repo and --subdir are determined by detecting the curre
 
 // getInitialConfig gets the initial config (i.e., the config that comes solely
 // from the Srcfile, if any, and the external user config, before running the
-// scanners).
-func getInitialConfig(opt config.Options, dir Directory) (*config.Repository, error) {
+// scanners). The Srcfile is read from the root of the tree fs.
+func getInitialConfig(opt config.Options, dir Directory, fs vfs.FileSystem) (*config.Repository, error) {
 	if dir != "" && dir != "." {
 		log.Fatalf("Currently, only configuring the current directory tree is supported (i.e., no DIR argument). You provided %q.\n\nTo configure that directory, `cd %s` in your shell and rerun this command.", dir, dir)
 	}
@@ -62,9 +64,9 @@ func getInitialConfig(opt config.Options, dir Directory) (*config.Repository, er
 		log.Fatalf("Configuration is currently only supported at the root (top-level directory) of a repository, not in a subdirectory (%q).", opt.Subdir)
 	}
 
-	cfg, err := config.ReadRepository(string(dir), repo.URI(opt.Repo))
+	cfg, err := config.ReadRepositoryFS(fs, repo.URI(opt.Repo))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read repository at %s: %s", dir, err)
+		return nil, fmt.Errorf("failed to read repository at %s: %s", fs, err)
 	}
 
 	if cfg.Scanners == nil {
@@ -76,6 +78,7 @@ func getInitialConfig(opt config.Options, dir Directory) (*config.Repository, er
 
 type ConfigCmd struct {
 	config.Options
+	TreeOpt
 
 	ToolchainExecOpt `group:"execution"`
 	BuildCacheOpt    `group:"build cache"`
@@ -102,25 +105,31 @@ func (c *ConfigCmd) Execute(args []string) error {
 		c.Args.Dir = "."
 	}
 
-	cfg, err := getInitialConfig(c.Options, c.Args.Dir)
+	fs, err := c.fileSystem(string(c.Args.Dir))
 	if err != nil {
 		return err
 	}
-
-	if len(cfg.PreConfigCommands) > 0 {
-		if err := runPreConfigCommands(string(c.Args.Dir), cfg.PreConfigCommands, c.ToolchainExecOpt); err != nil {
-			return fmt.Errorf("PreConfigCommands: %s", err)
-		}
+	cfg, err := getInitialConfig(c.Options, c.Args.Dir, fs)
+	if err != nil {
+		return withKind(ConfigError, err)
+	}
+	if !quiet() {
+		warnOldSrcfile(fs)
+		warnConfigDrift(fs)
 	}
 
-	if err := scanUnitsIntoConfig(cfg, c.Options, c.ToolchainExecOpt); err != nil {
-		return fmt.Errorf("failed to scan for source units: %s", err)
+	if err := c.scan(cfg, fs); err != nil {
+		return err
 	}
 
 	currentRepo, err := OpenRepo(string(c.Args.Dir))
 	if err != nil {
 		return fmt.Errorf("failed to open repo: %s", err)
 	}
+	if c.Rev != "" {
+		// Store the source units of the revision's commit.
+		currentRepo.CommitID = vfs.GitCommitID(fs)
+	}
 	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
 	if err != nil {
 		return err
@@ -184,6 +193,65 @@ func (c *ConfigCmd) Execute(args []string) error {
 	return nil
 }
 
+// scan runs the PreConfigCommands and scanners on the tree fs, and adds
+// the source units that they find to cfg. If the tree isn't the working
+// tree, they run in a temporary export of it.
+func (c *ConfigCmd) scan(cfg *config.Repository, fs vfs.FileSystem) error {
+	dir := string(c.Args.Dir)
+	if c.Rev != "" {
+		restore, err := chdirToTree(fs)
+		if err != nil {
+			return err
+		}
+		defer restore()
+		dir = "."
+	}
+
+	if len(cfg.PreConfigCommands) > 0 {
+		if err := runPreConfigCommands(dir, cfg.PreConfigCommands, c.ToolchainExecOpt); err != nil {
+			return withKind(ConfigError, fmt.Errorf("PreConfigCommands: %s", err))
+		}
+	}
+
+	if err := scanUnitsIntoConfig(cfg, c.Options, c.ToolchainExecOpt); err != nil {
+		kind := ConfigError
+		if toolchain.IsNotFound(err) {
+			kind = ToolchainMissing
+		}
+		return withKind(kind, fmt.Errorf("failed to scan for source units: %s", err))
+	}
+	return nil
+}
+
+// warnOldSrcfile warns if the Srcfile (if any) at the root of the tree fs
+// uses old forms of the config schema (see "src migrate-config").
+func warnOldSrcfile(fs vfs.FileSystem) {
+	data, err := fs.ReadFile(config.Filename)
+	if err != nil {
+		return
+	}
+	if _, applied, err := config.Migrate(data); err == nil && len(applied) > 0 {
+		log.Printf("Warning: %s uses old forms of the config schema (%s). Run `src migrate-config` to update it.", config.Filename, strings.Join(applied, "; "))
+	}
+}
+
+// warnConfigDrift warns about what the Srcfile (if any) at the root of the
+// tree fs doesn't cover (see "src check-config").
+func warnConfigDrift(fs vfs.FileSystem) {
+	_, drift, err := configDrift(fs)
+	if err != nil {
+		log.Printf("Warning: failed to check whether %s covers the tree: %s.", config.Filename, err)
+		return
+	}
+	if drift == nil || drift.Empty() {
+		return
+	}
+	for _, msg := range driftMessages(drift) {
+		log.Printf("Warning: %s", msg)
+	}
+	log.Println("Run `src check-config --write` to update it.")
+}
+
 func sortedMap(m map[string]interface{}) [][2]interface{} {
 	keys := make([]string, len(m))
 	i := 0
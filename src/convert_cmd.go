@@ -0,0 +1,115 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/convert"
+)
+
+func init() {
+	_, err := CLI.AddCommand("convert",
+		"convert graph data between formats",
+		`Converts graph data (defs, refs, docs, aliases, and type relations) from one format to another. It reads FILE (or stdin) and writes to the --out file (or stdout).
+
+The formats are json (the format of the graph data in build data, as written by "src make" and graphers), ndjson (one {"Def": ...}, {"Ref": ...}, etc. object per line), protobuf (length-delimited Record messages, whose schema is srclib.proto in the convert package), lsif (an LSIF dump), and ctags (a tags file of the defs). Formats that can't represent some kinds of graph data (such as ctags, which has only defs) omit them.
+
+Except for reading LSIF, records are converted one at a time, so large graph data can be converted without reading it all into memory. The json format must list defs, then refs, docs, aliases, and type relations, as build data does.
+
+The lsif and ctags formats locate defs and refs by lines instead of byte offsets, so converting to or from them reads the source files. File paths are resolved relative to the root of the repository containing the current directory (or the current directory, if it's not in a repository).`,
+		&convertCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ConvertCmd struct {
+	From string `long:"from" description:"input format" default:"json" value-name:"json|ndjson|protobuf|lsif|ctags"`
+	To   string `long:"to" description:"output format" required:"yes" value-name:"json|ndjson|protobuf|lsif|ctags"`
+	Out  string `short:"o" long:"out" description:"file to write the output to (defaults to stdout)" value-name:"FILE"`
+
+	Args struct {
+		File string `name:"FILE" description:"file to convert (defaults to stdin)"`
+	} `positional-args:"yes"`
+}
+
+var convertCmd ConvertCmd
+
+func (c *ConvertCmd) Execute(args []string) error {
+	for _, format := range []string{c.From, c.To} {
+		if !isConvertFormat(format) {
+			return withKind(UsageError, fmt.Errorf("unsupported format %q (must be one of %s)", format, strings.Join(convert.Formats, ", ")))
+		}
+	}
+
+	dir := "."
+	if repo, err := OpenRepo("."); err == nil {
+		dir = repo.RootDir
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	opt := convert.Options{
+		ReadFile: func(file string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		},
+		RootURI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String(),
+	}
+
+	var in io.Reader = os.Stdin
+	if c.Args.File != "" {
+		f, err := os.Open(c.Args.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := convert.NewReader(c.From, in, opt)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if c.Out != "" {
+		f, err := os.Create(c.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w, err := convert.NewWriter(c.To, out, opt)
+	if err != nil {
+		return err
+	}
+
+	n, err := convert.Copy(w, r)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if verbose() {
+		log.Printf("Converted %d records from %s to %s.", n, c.From, c.To)
+	}
+	return nil
+}
+
+func isConvertFormat(format string) bool {
+	for _, f := range convert.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
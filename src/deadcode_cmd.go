@@ -0,0 +1,115 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/deadcode"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+)
+
+func init() {
+	_, err := CLI.AddCommand("deadcode",
+		"list unreferenced defs",
+		`Lists the defs (both exported and private) in the current repository that have no references from non-test code, according to the graph data built by "src make".
+
+Defs that are only used by code outside of the repository (such as public API entry points) are reported too. To exclude them, list their def paths (or path.Match patterns) in an allowlist file, one per line, and pass it with --allowlist. Blank lines and lines starting with "#" are ignored.`,
+		&deadcodeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type DeadcodeCmd struct {
+	Allowlist string   `long:"allowlist" description:"file listing def path patterns to never report" value-name:"FILE"`
+	Allow     []string `long:"allow" description:"def path pattern to never report (can be specified multiple times)" value-name:"PATTERN"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+}
+
+var deadcodeCmd DeadcodeCmd
+
+func (c *DeadcodeCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	var allow deadcode.Allowlist
+	if c.Allowlist != "" {
+		f, err := os.Open(c.Allowlist)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		allow, err = deadcode.ReadAllowlist(f)
+		if err != nil {
+			return fmt.Errorf("%s: %s", c.Allowlist, err)
+		}
+	}
+	allow = append(allow, c.Allow...)
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	var graphs []*deadcode.Unit
+	for _, u := range units {
+		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+		f, err := buildStore.Open(graphFile)
+		if os.IsNotExist(err) {
+			if verbose() {
+				log.Printf("No graph data for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		g, err := grapher.ReadOutput(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", graphFile, err)
+		}
+		graphs = append(graphs, &deadcode.Unit{Type: u.Type, Name: u.Name, Output: g})
+	}
+	if len(graphs) == 0 {
+		return fmt.Errorf("no graph data found for commit %s (run `src make` first)", repo.CommitID)
+	}
+
+	defs := deadcode.Find(repo.URI(), graphs, allow)
+	if c.Output.Output == "json" {
+		if defs == nil {
+			defs = []*graph.Def{}
+		}
+		PrintJSON(defs, "")
+	} else {
+		printUnreferencedDefs(newPrinter(os.Stdout), defs)
+	}
+	return nil
+}
+
+func printUnreferencedDefs(p *present.Printer, defs []*graph.Def) {
+	t := &present.Table{Colors: []present.Color{present.Cyan, present.Dim, present.Yellow, present.Dim, present.Bold}}
+	for _, def := range defs {
+		vis := "private"
+		if def.Exported {
+			vis = "exported"
+		}
+		t.Rows = append(t.Rows, []string{fmt.Sprintf("%s:%d-%d", def.File, def.DefStart, def.DefEnd), vis, string(def.Kind), def.Unit, string(def.Path)})
+	}
+	p.Table(t)
+}
@@ -0,0 +1,400 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/defmap"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func init() {
+	c, err := CLI.AddCommand("defs",
+		"track defs across commits",
+		`Track the current repository's defs across commits (and renames), using the graph data built by "src make" at each commit.`,
+		&defsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("map",
+		"match the defs of a previous build to the current build",
+		`Match the defs of the build of OLD (by default, the nearest ancestor of the current commit that has been built) to the defs of the current commit's build, and save the map alongside the current commit's build data ("src make --track-defs" does this after building).
+
+Defs are matched by their keys (unit type, unit, and path), then by their kinds and names within files that the VCS detects were renamed, then by the similarity of their source text, and then by their kinds and names anywhere. The defs whose keys changed, and the defs that were added and removed, are printed.`,
+		&defsMapCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("history",
+		"show a def's keys at previous commits",
+		`Show the key of a def at each previous commit, by following the maps saved by "src defs map" (or "src make --track-defs") back from the current commit. The history ends at the commit that added the def, or at the oldest commit whose defs were mapped.`,
+		&defsHistoryCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type DefsCmd struct{}
+
+var defsCmd DefsCmd
+
+func (c *DefsCmd) Execute(args []string) error { return nil }
+
+type DefsMapCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Old string `name:"OLD" description:"revision whose build to match the current build's defs to"`
+	} `positional-args:"yes"`
+}
+
+var defsMapCmd DefsMapCmd
+
+func (c *DefsMapCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	var oldCommitID string
+	if c.Args.Old != "" {
+		oldCommitID, err = resolveRevision(repo.VCSType, repo.RootDir, c.Args.Old)
+		if err != nil {
+			return withKind(UsageError, err)
+		}
+	} else {
+		oldCommitID, err = previousBuild(buildStore, repo)
+		if err != nil {
+			return err
+		}
+		if oldCommitID == "" {
+			return fmt.Errorf("no ancestor of commit %s has been built (run `src make` at a previous commit, or specify OLD)", repo.CommitID)
+		}
+	}
+
+	m, err := trackDefs(buildStore, repo, oldCommitID)
+	if err != nil {
+		return err
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(m, "")
+	} else {
+		printDefMap(newPrinter(os.Stdout), m)
+	}
+	return nil
+}
+
+func printDefMap(p *present.Printer, m *defmap.Map) {
+	p.Printf(present.Bold, "%s..%s: %d unchanged, %d renamed or moved, %d added, %d removed", m.OldCommitID, m.NewCommitID, m.Unchanged, len(m.Matches), len(m.Added), len(m.Removed))
+	oldFiles := make([]string, 0, len(m.RenamedFiles))
+	for f := range m.RenamedFiles {
+		oldFiles = append(oldFiles, f)
+	}
+	sort.Strings(oldFiles)
+	for _, f := range oldFiles {
+		p.Printf(present.Cyan, "R %s -> %s", f, m.RenamedFiles[f])
+	}
+	for _, mt := range m.Matches {
+		p.Printf(present.Yellow, "~ %s -> %s (by %s)", mt.Old.Format(), mt.New.Format(), mt.By)
+	}
+	for _, k := range m.Added {
+		p.Printf(present.Green, "+ %s", k.Format())
+	}
+	for _, k := range m.Removed {
+		p.Printf(present.Red, "- %s", k.Format())
+	}
+}
+
+type DefsHistoryCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Def string `name:"DEF" description:"key of the def, as [REPO[@COMMIT]]:UNITTYPE:UNIT:PATH"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var defsHistoryCmd DefsHistoryCmd
+
+func (c *DefsHistoryCmd) Execute(args []string) error {
+	key, err := graph.ParseDefKey(c.Args.Def)
+	if err != nil {
+		return withKind(UsageError, err)
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	history, err := defmap.History(repo.CommitID, key, func(commitID string) (*defmap.Map, error) {
+		return readDefMap(buildStore, commitID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(history, "")
+		return nil
+	}
+	p := newPrinter(os.Stdout)
+	for _, e := range history {
+		var by string
+		if e.By != "" {
+			by = " " + p.Paint(present.Dim, fmt.Sprintf("(renamed or moved; matched by %s)", e.By))
+		}
+		fmt.Fprintf(p.W, "%s  %s%s\n", p.Paint(present.Yellow, e.CommitID), e.Def.Format(), by)
+	}
+	return nil
+}
+
+// trackDefs matches the defs of the build of oldCommitID to the defs of
+// the current commit's build, and saves the map (see defmap.Filename).
+func trackDefs(buildStore *buildstore.RepositoryStore, repo *Repo, oldCommitID string) (*defmap.Map, error) {
+	oldDefs, err := readBuildDefs(buildStore, repo, oldCommitID)
+	if err != nil {
+		return nil, err
+	}
+	newDefs, err := readBuildDefs(buildStore, repo, repo.CommitID)
+	if err != nil {
+		return nil, err
+	}
+
+	renames, err := renamedFiles(repo, oldCommitID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := defmap.Compute(
+		&defmap.Build{CommitID: oldCommitID, Defs: oldDefs, ReadFile: commitFileReader(repo, oldCommitID)},
+		&defmap.Build{CommitID: repo.CommitID, Defs: newDefs, ReadFile: commitFileReader(repo, repo.CommitID)},
+		renames,
+	)
+
+	w, err := buildStore.Create(buildStore.FilePath(repo.CommitID, defmap.Filename))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readDefMap reads the def map saved for a commit. If there is none, it
+// returns nil.
+func readDefMap(buildStore *buildstore.RepositoryStore, commitID string) (*defmap.Map, error) {
+	mapFile := buildStore.FilePath(commitID, defmap.Filename)
+	f, err := buildStore.Open(mapFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m *defmap.Map
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %s", mapFile, err)
+	}
+	return m, nil
+}
+
+// readBuildDefs reads the defs in the graph data of the source units built
+// for a commit, with their unit fields filled in.
+func readBuildDefs(buildStore *buildstore.RepositoryStore, repo *Repo, commitID string) ([]*graph.Def, error) {
+	commitRepo := *repo
+	commitRepo.CommitID = commitID
+	units, err := getSourceUnits(buildStore, &commitRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []*graph.Def
+	var found bool
+	for _, u := range units {
+		graphFile := buildStore.FilePath(commitID, plan.SourceUnitDataFilename(&grapher.Output{}, u))
+		f, err := buildStore.Open(graphFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		// Only the defs are needed, so the refs (which are usually most of
+		// the graph data) are skipped.
+		err = grapher.StreamOutput(f, grapher.OutputHandlers{Def: func(d *graph.Def) error {
+			if d.UnitType == "" && d.Unit == "" {
+				d.UnitType, d.Unit = u.Type, u.Name
+			}
+			defs = append(defs, d)
+			return nil
+		}})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", graphFile, err)
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no graph data found for commit %s (run `src make` at that commit)", commitID)
+	}
+	return defs, nil
+}
+
+// maxAncestors is the number of ancestors of a commit that previousBuild
+// looks for builds of.
+const maxAncestors = 100
+
+// previousBuild returns the nearest ancestor of the current commit (along
+// first parents) that has build data, or "" if there is none.
+func previousBuild(buildStore *buildstore.RepositoryStore, repo *Repo) (string, error) {
+	commits, err := buildStore.ListCommits()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	built := make(map[string]bool, len(commits))
+	for _, c := range commits {
+		built[c] = true
+	}
+
+	var cmd *exec.Cmd
+	switch repo.VCSType {
+	case "git":
+		cmd = exec.Command("git", "rev-list", "--first-parent", fmt.Sprintf("--max-count=%d", maxAncestors), repo.CommitID+"^")
+	case "hg":
+		cmd = exec.Command("hg", "--config", "trusted.users=root", "log", "--template", "{node}\n", fmt.Sprintf("--limit=%d", maxAncestors), "--rev", fmt.Sprintf("reverse(ancestors(%s)) - %s", repo.CommitID, repo.CommitID))
+	default:
+		return "", fmt.Errorf("unknown vcs type: %q", repo.VCSType)
+	}
+	cmd.Dir = repo.RootDir
+	out, err := cmd.Output()
+	if err != nil {
+		// The commit has no parent.
+		return "", nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if built[s.Text()] {
+			return s.Text(), nil
+		}
+	}
+	return "", nil
+}
+
+// renamedFiles returns the files that were renamed between oldCommitID and
+// the current commit, mapping their old paths to their new paths (relative
+// to the repository root), as detected by the VCS.
+func renamedFiles(repo *Repo, oldCommitID string) (map[string]string, error) {
+	var cmd *exec.Cmd
+	switch repo.VCSType {
+	case "git":
+		cmd = exec.Command("git", "diff", "--name-status", "-z", "-M", oldCommitID, repo.CommitID)
+	case "hg":
+		// Mercurial records renames as copies of removed files.
+		cmd = exec.Command("hg", "--config", "trusted.users=root", "status", "--copies", "--added", "--removed", "--print0", "--rev", oldCommitID, "--rev", repo.CommitID)
+	default:
+		return nil, fmt.Errorf("unknown vcs type: %q", repo.VCSType)
+	}
+	cmd.Dir = repo.RootDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec %v failed: %s", cmd.Args, err)
+	}
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+
+	renames := make(map[string]string)
+	switch repo.VCSType {
+	case "git":
+		// Each rename is "Rscore", old path, new path; other changes are
+		// a status and one path.
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "R") && i+2 < len(fields) {
+				renames[fields[i+1]] = fields[i+2]
+				i += 2
+			} else if strings.HasPrefix(fields[i], "C") {
+				i += 2
+			} else {
+				i++
+			}
+		}
+	case "hg":
+		// Each added file ("A path") is followed by its copy source (" path"),
+		// if any. A copy whose source was removed is a rename.
+		removed := make(map[string]bool)
+		for _, f := range fields {
+			if strings.HasPrefix(f, "R ") {
+				removed[f[2:]] = true
+			}
+		}
+		for i, f := range fields {
+			if strings.HasPrefix(f, "A ") && i+1 < len(fields) && strings.HasPrefix(fields[i+1], "  ") {
+				if src := fields[i+1][2:]; removed[src] {
+					renames[src] = f[2:]
+				}
+			}
+		}
+	}
+	return renames, nil
+}
+
+// commitFileReader returns a function that reads files (given their paths
+// relative to the repository root) at a commit. Files at the current
+// commit are read from the working tree.
+func commitFileReader(repo *Repo, commitID string) func(string) ([]byte, error) {
+	if commitID == repo.CommitID {
+		return vfs.OS(repo.RootDir).ReadFile
+	}
+	if repo.VCSType == "git" {
+		fs, err := vfs.Git(repo.RootDir, commitID)
+		if err != nil {
+			return func(string) ([]byte, error) { return nil, err }
+		}
+		return fs.ReadFile
+	}
+	return func(file string) ([]byte, error) {
+		var cmd *exec.Cmd
+		switch repo.VCSType {
+		case "hg":
+			cmd = exec.Command("hg", "--config", "trusted.users=root", "cat", "--rev", commitID, file)
+		default:
+			return nil, fmt.Errorf("unknown vcs type: %q", repo.VCSType)
+		}
+		cmd.Dir = repo.RootDir
+		return cmd.Output()
+	}
+}
@@ -0,0 +1,203 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	c, err := CLI.AddCommand("deps",
+		"show the dependency graph",
+		`Show the dependencies of the current repository's source units, according to the dependency resolution data built by "src make".`,
+		&depsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("tree",
+		"print the dependency tree",
+		`Print the dependency tree of each of the repository's source units (or only of the named ones). Dependencies of source units in other repositories aren't known, so they're leaves of the tree. Source units whose dependencies appear earlier in the tree are marked with "(*)".`,
+		&depsTreeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("why",
+		"explain why a dependency is needed",
+		`Print the shortest chain of dependencies from each of the repository's source units to each source unit that it depends on (directly or indirectly) whose repository URI or name matches PATTERN (a path.Match pattern, such as "github.com/foo/*").`,
+		&depsWhyCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type DepsCmd struct{}
+
+var depsCmd DepsCmd
+
+func (c *DepsCmd) Execute(args []string) error { return nil }
+
+type DepsTreeCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Units []UnitName `name:"UNITS" description:"only print the trees of these source units (names or IDs)"`
+	} `positional-args:"yes"`
+}
+
+var depsTreeCmd DepsTreeCmd
+
+func (c *DepsTreeCmd) Execute(args []string) error {
+	g, err := readDepGraph()
+	if err != nil {
+		return err
+	}
+
+	specs := make([]string, len(c.Args.Units))
+	for i, u := range c.Args.Units {
+		specs[i] = string(u)
+	}
+	var roots []dep.Node
+	for _, n := range g.Roots {
+		if SourceUnitMatchesArgs(specs, &unit.SourceUnit{Name: n.Unit, Type: n.UnitType}) {
+			roots = append(roots, n)
+		}
+	}
+	if len(roots) == 0 {
+		return withKind(UsageError, fmt.Errorf("no source units match %v", c.Args.Units))
+	}
+
+	trees := g.Tree(roots)
+	if c.Output.Output == "json" {
+		PrintJSON(trees, "")
+	} else {
+		newPrinter(os.Stdout).Tree(present.Bold, depTrees(trees))
+	}
+	return nil
+}
+
+// depTrees returns the dependency trees for printing.
+func depTrees(trees []*dep.TreeNode) []*present.Tree {
+	pts := make([]*present.Tree, len(trees))
+	for i, t := range trees {
+		pts[i] = &present.Tree{Label: t.Node.String(), Children: depTrees(t.Deps)}
+		if t.Repeated {
+			pts[i].Note = "(*)"
+		}
+	}
+	return pts
+}
+
+type DepsWhyCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Pattern string `name:"PATTERN" description:"repository URI or source unit name (or path.Match pattern) of the dependency"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var depsWhyCmd DepsWhyCmd
+
+func (c *DepsWhyCmd) Execute(args []string) error {
+	g, err := readDepGraph()
+	if err != nil {
+		return err
+	}
+
+	paths := g.Why(c.Args.Pattern)
+	if c.Output.Output == "json" {
+		if paths == nil {
+			paths = [][]dep.Node{}
+		}
+		PrintJSON(paths, "")
+		return nil
+	}
+	p := newPrinter(os.Stdout)
+	if len(paths) == 0 {
+		p.Printf(present.Dim, "(no source unit depends on %q)", c.Args.Pattern)
+		return nil
+	}
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Fprintln(p.W)
+		}
+		p.Printf(present.Bold, "# %s", path[len(path)-1])
+		for _, n := range path {
+			fmt.Fprintln(p.W, n)
+		}
+	}
+	return nil
+}
+
+// readDepGraph reads the dependency graph of the current repository's
+// source units from the dependency resolution data built by `src make`.
+func readDepGraph() (*dep.Graph, error) {
+	repo, units, deps, err := readResolvedDeps()
+	if err != nil {
+		return nil, err
+	}
+	return dep.NewGraph(repo.URI(), units, deps), nil
+}
+
+// readResolvedDeps reads the current repository's source units and their
+// resolved dependencies from the build data built by `src make`.
+func readResolvedDeps() (*Repo, []*unit.SourceUnit, []*dep.ResolvedDep, error) {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var deps []*dep.ResolvedDep
+	var found bool
+	for _, u := range units {
+		depsFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename([]*dep.ResolvedDep{}, u))
+		f, err := buildStore.Open(depsFile)
+		if os.IsNotExist(err) {
+			if verbose() {
+				log.Printf("No dependency resolution data for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return nil, nil, nil, err
+		}
+		ress, err := dep.ReadResolutions(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %s", depsFile, err)
+		}
+		rds, err := dep.ResolutionsToResolvedDeps(ress, u, repo.URI(), repo.CommitID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %s", depsFile, err)
+		}
+		deps = append(deps, rds...)
+		found = true
+	}
+	if !found {
+		return nil, nil, nil, fmt.Errorf("no dependency resolution data found for commit %s (run `src make` first)", repo.CommitID)
+	}
+	return repo, units, deps, nil
+}
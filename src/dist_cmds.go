@@ -0,0 +1,759 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/workqueue"
+)
+
+func init() {
+	c, err := CLI.AddCommand("dist",
+		"distributed builds",
+		`Distributes the analysis of many repositories across many machines.
+
+A coordinator (started with "src dist coordinator") holds a queue of tasks, one per source unit of a repository commit. "src dist enqueue" adds the source units of a local repository to the queue. Workers (started with "src dist worker") lease tasks, clone the repositories, run the toolchains, and upload the resulting build data to the coordinator, which stores each distinct file once (by the hash of its contents) and which "src dist pull" fetches it from.
+
+Tasks that fail (or whose worker stops responding) are retried on another worker. Adding a task that is already queued, running, or done has no effect.`,
+		&distCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("coordinator",
+		"run a coordinator",
+		"Run a coordinator, which serves the work queue to workers over HTTP and stores the build data they upload. The queue is held in memory, so it is lost when the coordinator exits. With --blob-store, the blobs that hold the contents of build data files are stored in cloud object storage (Amazon S3, Google Cloud Storage, or Azure Blob Storage) or another directory, instead of in the data directory. Credentials are read from the environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN (and AWS_REGION) for S3, GOOGLE_OAUTH_ACCESS_TOKEN for Cloud Storage, and AZURE_STORAGE_SAS_TOKEN (a shared access signature for the container) for Azure.",
+		&distCoordinatorCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("enqueue",
+		"add a repository to the queue",
+		"Add a task for each source unit of the repository rooted at DIR (at its current commit) to the coordinator's queue. Run `src config` first.",
+		&distEnqueueCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("worker",
+		"run a worker",
+		"Run a worker, which repeatedly leases a task from the coordinator, builds it, and uploads the build data. Build data files larger than --max-chunk-size are uploaded in chunks, along with a manifest that ties them together, for coordinators whose storage limits object sizes; commands that read the coordinator's build data reassemble them. With --compress, files are compressed with zstd before they are uploaded (and chunked), and decompressed transparently when they are read.",
+		&distWorkerCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("pull",
+		"fetch build data from the coordinator",
+		"Fetch the build data that the coordinator has for the repository rooted at DIR (at its current commit) into the repository's local build data directory. Files are stored as they are on the coordinator (e.g., chunked or compressed); commands that read build data reassemble and decompress them. Files that are already present locally with the same contents, at any path, are copied instead of being downloaded.",
+		&distPullCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("status",
+		"show the queue",
+		"Show all tasks in the coordinator's queue and their states.",
+		&distStatusCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type DistCmd struct{}
+
+var distCmd DistCmd
+
+func (c *DistCmd) Execute(args []string) error { return nil }
+
+type CoordinatorOpt struct {
+	Coordinator string `long:"coordinator" description:"base URL of coordinator" default:"http://localhost:7070" value-name:"URL"`
+}
+
+func (o *CoordinatorOpt) client() *workqueue.Client {
+	return &workqueue.Client{URL: o.Coordinator}
+}
+
+type DistCoordinatorCmd struct {
+	HTTPAddr    string        `long:"http" description:"HTTP listen address" default:":7070" value-name:"ADDR"`
+	DataDir     string        `long:"data" description:"directory to store uploaded build data in" default:"srclib-dist-data" value-name:"DIR"`
+	MaxAttempts int           `long:"max-attempts" description:"number of times to attempt each task" default:"3" value-name:"N"`
+	Lease       time.Duration `long:"lease" description:"time a worker has to finish a task before it is retried" default:"30m" value-name:"DURATION"`
+	BlobStore   string        `long:"blob-store" description:"store blobs in the blob store at URL (s3://BUCKET/PREFIX, gs://BUCKET/PREFIX, azblob://ACCOUNT/CONTAINER/PREFIX, or a directory) instead of the data directory" value-name:"URL"`
+}
+
+var distCoordinatorCmd DistCoordinatorCmd
+
+func (c *DistCoordinatorCmd) Execute(args []string) error {
+	dataDir, err := filepath.Abs(c.DataDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+
+	q := workqueue.NewQueue()
+	q.MaxAttempts = c.MaxAttempts
+	q.LeaseDuration = c.Lease
+
+	store, err := openArtifactStore(dataDir, c.BlobStore)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Coordinator listening on %s (build data stored in %s)", c.HTTPAddr, dataDir)
+	return http.ListenAndServe(c.HTTPAddr, workqueue.NewHandler(q, store))
+}
+
+// openArtifactStore opens the artifact store (such as a coordinator's data
+// directory) in dir. If blobStoreURL is non-empty, the store's blobs are
+// stored in the blob store at that URL (see buildstore.OpenBlobStore)
+// instead of in dir.
+func openArtifactStore(dir, blobStoreURL string) (*buildstore.MultiStore, error) {
+	if blobStoreURL == "" {
+		return buildstore.New(rwvfs.OS(dir)), nil
+	}
+	blobs, err := buildstore.OpenBlobStore(blobStoreURL)
+	if err != nil {
+		return nil, err
+	}
+	return buildstore.NewWithBlobs(rwvfs.OS(dir), blobs), nil
+}
+
+type DistEnqueueCmd struct {
+	CoordinatorOpt
+
+	Args struct {
+		Dir Directory `name:"DIR" default:"." description:"root directory of repository to enqueue"`
+	} `positional-args:"yes"`
+}
+
+var distEnqueueCmd DistEnqueueCmd
+
+func (c *DistEnqueueCmd) Execute(args []string) error {
+	if c.Args.Dir == "" {
+		c.Args.Dir = "."
+	}
+	currentRepo, err := OpenRepo(string(c.Args.Dir))
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+	treeConfig, err := config.ReadCached(buildStore, currentRepo.CommitID)
+	if err != nil {
+		return err
+	}
+
+	tasks := make([]*workqueue.Task, len(treeConfig.SourceUnits))
+	for i, u := range treeConfig.SourceUnits {
+		tasks[i] = &workqueue.Task{
+			RepoURI:  string(currentRepo.URI()),
+			CloneURL: currentRepo.CloneURL,
+			CommitID: currentRepo.CommitID,
+			UnitName: u.Name,
+			UnitType: u.Type,
+		}
+	}
+
+	n, err := c.client().Add(tasks)
+	if err != nil {
+		return err
+	}
+	log.Printf("Added %d tasks (%d were already queued).", n, len(tasks)-n)
+	return nil
+}
+
+type DistWorkerCmd struct {
+	CoordinatorOpt
+
+	ToolchainExecOpt `group:"execution"`
+	ExecLimitOpt     `group:"execution limits"`
+
+	Name         string        `long:"name" description:"worker name (default: HOSTNAME-PID)"`
+	WorkDir      string        `long:"work-dir" description:"directory to clone repositories into" default:"srclib-dist-work" value-name:"DIR"`
+	Poll         time.Duration `long:"poll" description:"how long to wait before checking for new tasks when the queue is empty" default:"10s" value-name:"DURATION"`
+	ExitWhenIdle bool          `long:"exit-when-idle" description:"exit when the queue is empty instead of waiting for new tasks"`
+	MaxChunkSize string        `long:"max-chunk-size" description:"upload build data files larger than SIZE (e.g., 512M or 1G) in chunks of at most SIZE" default:"1G" value-name:"SIZE"`
+	Compress     string        `long:"compress" description:"compress uploaded build data files with METHOD: none, zstd, or zstd-dict (zstd with a dictionary trained on graph data, which helps most for small files)" default:"none" value-name:"METHOD"`
+	Delta        bool          `long:"delta" description:"upload build data files as deltas against the parent commit's (if the coordinator has them) when that is less than half the size"`
+}
+
+var distWorkerCmd DistWorkerCmd
+
+func (c *DistWorkerCmd) Execute(args []string) error {
+	if c.Name == "" {
+		hostname, _ := os.Hostname()
+		c.Name = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	workDir, err := filepath.Abs(c.WorkDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return err
+	}
+
+	maxChunkSize, err := parseByteSize(c.MaxChunkSize)
+	if err != nil {
+		return withKind(UsageError, err)
+	}
+	switch c.Compress {
+	case "none", "zstd", "zstd-dict":
+	default:
+		return withKind(UsageError, fmt.Errorf("unknown compression method %q (want none, zstd, or zstd-dict)", c.Compress))
+	}
+
+	cl := c.client()
+	for {
+		t, err := cl.Lease(c.Name)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			if c.ExitWhenIdle {
+				return nil
+			}
+			time.Sleep(c.Poll)
+			continue
+		}
+
+		log.Printf("Building %s (attempt %d)", t.ID, t.Attempts)
+		if err := c.build(cl, workDir, t, maxChunkSize); err != nil {
+			log.Printf("Task %s failed: %s", t.ID, err)
+			if err := cl.Fail(c.Name, t.ID, err); err != nil {
+				log.Printf("Warning: failed to report failure of task %s: %s.", t.ID, err)
+			}
+			continue
+		}
+		if err := cl.Complete(c.Name, t.ID); err != nil {
+			log.Printf("Warning: failed to mark task %s as done: %s.", t.ID, err)
+		}
+	}
+}
+
+// build checks out the task's repository, builds the task's source unit, and
+// uploads the build data (in chunks of at most maxChunkSize bytes).
+func (c *DistWorkerCmd) build(cl *workqueue.Client, workDir string, t *workqueue.Task, maxChunkSize int64) error {
+	dir := filepath.Join(workDir, filepath.FromSlash(t.RepoURI))
+	if err := checkoutCommit(dir, t.CloneURL, t.CommitID); err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(dir)
+	if err != nil {
+		return err
+	}
+
+	// Other units of this commit may have been built (and configured) on
+	// this worker before.
+	if _, err := config.ReadCached(buildStore, t.CommitID); err != nil {
+		cmd := exec.Command(os.Args[0], "config", "-m", c.ExeMethods)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("src config failed: %s", err)
+		}
+	}
+
+	_, mf, err := CreateMaker(c.ToolchainExecOpt, c.ExecLimitOpt, nil)
+	if err != nil {
+		return err
+	}
+	goals := plan.SourceUnitTargets(mf, t.UnitName, t.UnitType)
+	if len(goals) == 0 {
+		return fmt.Errorf("source unit %s %q not found in commit %s", t.UnitType, t.UnitName, t.CommitID)
+	}
+	mk, _, err := CreateMaker(c.ToolchainExecOpt, c.ExecLimitOpt, goals)
+	if err != nil {
+		return err
+	}
+	if err := mk.Run(); err != nil {
+		return err
+	}
+
+	var base *deltaBase
+	if c.Delta {
+		if base, err = findDeltaBase(cl, buildStore, dir, t); err != nil {
+			log.Printf("Warning: not uploading deltas for task %s: %s.", t.ID, err)
+		}
+	}
+
+	// Upload the unit definition along with the data built for it.
+	buildDataDir, err := buildstore.BuildDir(buildStore, t.CommitID)
+	if err != nil {
+		return err
+	}
+	u := &unit.SourceUnit{Name: t.UnitName, Type: t.UnitType}
+	files := []string{filepath.Join(buildDataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, u))}
+	for _, goal := range goals {
+		files = append(files, filepath.Join(dir, goal))
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(buildDataDir, file)
+		if err != nil {
+			return err
+		}
+		if err := c.upload(cl, t, file, rel, maxChunkSize, base); err != nil {
+			return fmt.Errorf("uploading %s: %s", rel, err)
+		}
+	}
+	return nil
+}
+
+// upload uploads the build data file at file (whose path in the build data
+// directory is rel). If base is non-nil, it uploads the file as a delta
+// against base if that is small enough; otherwise it compresses the file if
+// c.Compress says to, and splits it into chunks of at most maxChunkSize
+// bytes.
+func (c *DistWorkerCmd) upload(cl *workqueue.Client, t *workqueue.Task, file, rel string, maxChunkSize int64, base *deltaBase) error {
+	if base != nil {
+		if ok, err := c.uploadDelta(cl, t, file, rel, base); err != nil || ok {
+			return err
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+
+	if c.Compress != "none" {
+		// Compress to a temporary file, since chunking needs to know the
+		// compressed size up front.
+		tmp, err := ioutil.TempFile("", "srclib-upload-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if err := buildstore.Compress(tmp, f, size, c.Compress == "zstd-dict"); err != nil {
+			return err
+		}
+		if fi, err = tmp.Stat(); err != nil {
+			return err
+		}
+		f, rel, size = tmp, buildstore.CompressedPath(rel), fi.Size()
+	}
+
+	// Files are stored by content on the coordinator, so only upload the
+	// contents of those that it doesn't already have (e.g., because the
+	// source unit was built at another commit or on another branch with
+	// the same results).
+	return buildstore.PutChunked(rel, f, size, maxChunkSize, func(path string, data io.ReadSeeker) error {
+		hash, err := buildstore.HashBlob(data)
+		if err != nil {
+			return err
+		}
+		missing, err := cl.MissingBlobs([]string{hash})
+		if err != nil {
+			return err
+		}
+		if len(missing) != 0 {
+			if _, err := data.Seek(0, 0); err != nil {
+				return err
+			}
+			if err := cl.UploadBlob(c.Name, t.ID, hash, data); err != nil {
+				return err
+			}
+		}
+		return cl.LinkArtifact(c.Name, t.ID, path, hash)
+	})
+}
+
+// A deltaBase describes the build data of the parent of a task's commit,
+// against which the task's build data files may be uploaded as deltas.
+type deltaBase struct {
+	commitID string
+	dir      string                                // the parent's local build data directory
+	files    map[string]*buildstore.StoredFileInfo // the parent's files on the coordinator
+}
+
+// findDeltaBase returns the deltaBase for t, whose repository is cloned at
+// dir. It returns nil if the commit has no parent, or if the coordinator has
+// no build data for it.
+func findDeltaBase(cl *workqueue.Client, buildStore *buildstore.RepositoryStore, dir string, t *workqueue.Task) (*deltaBase, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", t.CommitID+"^")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil // a root commit
+	}
+	base := &deltaBase{commitID: strings.TrimSpace(string(out)), files: map[string]*buildstore.StoredFileInfo{}}
+	if base.dir, err = buildstore.BuildDir(buildStore, base.commitID); err != nil {
+		return nil, err
+	}
+	files, err := cl.ListArtifacts(t.RepoURI, base.commitID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	for _, f := range files {
+		base.files[f.Path] = f
+	}
+	return base, nil
+}
+
+// uploadDelta uploads the build data file at file (whose path in the build
+// data directory is rel) as a delta against the same file at base's commit,
+// which it reads from the parent's local build data if it was built here,
+// and downloads from the coordinator otherwise. It returns false if it
+// didn't upload the file, because the coordinator doesn't have the parent's
+// file (stored whole, so that its hash is known) or the delta isn't less
+// than half the size of the file.
+func (c *DistWorkerCmd) uploadDelta(cl *workqueue.Client, t *workqueue.Task, file, rel string, base *deltaBase) (bool, error) {
+	parent := base.files[filepath.ToSlash(rel)]
+	if parent == nil || parent.Hash == "" || parent.Size > buildstore.MaxDeltaFileSize {
+		return false, nil
+	}
+	if fi, err := os.Stat(file); err != nil {
+		return false, err
+	} else if fi.Size() > buildstore.MaxDeltaFileSize {
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	parentData, err := ioutil.ReadFile(filepath.Join(base.dir, rel))
+	if err != nil || !hasHash(parentData, parent.Hash) {
+		r, err := cl.GetArtifact(t.RepoURI, base.commitID, filepath.ToSlash(rel))
+		if err != nil {
+			return false, err
+		}
+		parentData, err = ioutil.ReadAll(io.LimitReader(r, buildstore.MaxDeltaFileSize+1))
+		r.Close()
+		if err != nil {
+			return false, err
+		}
+		if !hasHash(parentData, parent.Hash) {
+			return false, nil // changed on the coordinator since it was listed
+		}
+	}
+
+	d := buildstore.NewDelta(parentData, data)
+	d.BaseCommit = base.commitID
+	b, err := json.Marshal(d)
+	if err != nil {
+		return false, err
+	}
+	if len(b) >= len(data)/2 {
+		return false, nil
+	}
+	return true, cl.UploadArtifact(c.Name, t.ID, buildstore.DeltaPath(rel), bytes.NewReader(b))
+}
+
+func hasHash(data []byte, hash string) bool {
+	h, _ := buildstore.HashBlob(bytes.NewReader(data))
+	return h == hash
+}
+
+// fullCommitID matches full git commit IDs.
+var fullCommitID = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// checkoutCommit clones the git repository at cloneURL to dir (if it isn't
+// already there) and checks out commitID. Tasks are added to the
+// coordinator by anyone who can reach it, so cloneURL and commitID are
+// checked before they are passed to git: commitID must be a full commit
+// ID, and cloneURL must not look like an option.
+func checkoutCommit(dir, cloneURL, commitID string) error {
+	if !fullCommitID.MatchString(commitID) {
+		return fmt.Errorf("invalid commit ID %q (must be 40 hex digits)", commitID)
+	}
+	if cloneURL == "" || strings.HasPrefix(cloneURL, "-") {
+		return fmt.Errorf("invalid clone URL %q", cloneURL)
+	}
+
+	run := func(dir string, args ...string) error {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+			return err
+		}
+		if err := run(filepath.Dir(dir), "clone", "--quiet", "--", network.Default.Mirror(cloneURL), dir); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := run(dir, "cat-file", "-e", commitID+"^{commit}"); err != nil {
+		if err := run(dir, "fetch", "--quiet", "origin"); err != nil {
+			return err
+		}
+	}
+	return run(dir, "checkout", "--quiet", "--force", commitID, "--")
+}
+
+type DistPullCmd struct {
+	CoordinatorOpt
+
+	Args struct {
+		Dir Directory `name:"DIR" default:"." description:"root directory of repository to fetch build data for"`
+	} `positional-args:"yes"`
+}
+
+var distPullCmd DistPullCmd
+
+func (c *DistPullCmd) Execute(args []string) error {
+	if c.Args.Dir == "" {
+		c.Args.Dir = "."
+	}
+	currentRepo, err := OpenRepo(string(c.Args.Dir))
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+	rootDataDir, err := buildstore.RootDir(buildStore)
+	if err != nil {
+		return err
+	}
+	buildDataDir, err := buildstore.BuildDir(buildStore, currentRepo.CommitID)
+	if err != nil {
+		return err
+	}
+
+	repoURI := string(currentRepo.URI())
+	cl := c.client()
+	files, err := cl.ListArtifacts(repoURI, currentRepo.CommitID)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("the coordinator has no build data for %s at commit %s", repoURI, currentRepo.CommitID)
+	}
+	if err := checkArtifactPaths(files); err != nil {
+		return err
+	}
+
+	local, err := hashLocalFiles(rootDataDir, files)
+	if err != nil {
+		return err
+	}
+
+	var downloaded, copied, present int
+	pulled := make(map[string]bool, len(files))
+	for _, file := range files {
+		dst := filepath.Join(buildDataDir, filepath.FromSlash(file.Path))
+		pulled[dst] = true
+		if file.Hash != "" && local[dst] == file.Hash {
+			present++
+			continue
+		}
+		delete(local, dst) // it is about to be overwritten
+
+		var src string
+		if file.Hash != "" {
+			for path, hash := range local {
+				if hash == file.Hash {
+					src = path
+					break
+				}
+			}
+		}
+		var r io.ReadCloser
+		if src != "" {
+			r, err = os.Open(src)
+			copied++
+		} else {
+			r, err = cl.GetArtifact(repoURI, currentRepo.CommitID, file.Path)
+			downloaded++
+		}
+		if err != nil {
+			return err
+		}
+		err = writeBuildDataFile(dst, r, file.Hash)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("fetching %s: %s", file.Path, err)
+		}
+		if file.Hash != "" {
+			local[dst] = file.Hash
+		}
+	}
+
+	// Remove the local files that are stored in other forms than the pulled
+	// ones (e.g., uncompressed), since they would be read instead.
+	for _, file := range files {
+		path, ok := buildstore.DataFilePath(file.Path)
+		if !ok {
+			continue
+		}
+		for _, stale := range buildstore.StoredPaths(path) {
+			stale = filepath.Join(buildDataDir, filepath.FromSlash(stale))
+			if pulled[stale] {
+				continue
+			}
+			if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	log.Printf("Pulled %d build data files (%d downloaded, %d copied from identical local files, %d already present).", len(files), downloaded, copied, present)
+	return nil
+}
+
+// checkArtifactPaths returns an error if any of the files listed by the
+// coordinator has a path that isn't a clean relative path within the build
+// data directory (e.g., "../../.bashrc"), so that a malicious coordinator
+// can't make `src dist pull` write or remove files outside of it.
+func checkArtifactPaths(files []*buildstore.StoredFileInfo) error {
+	for _, file := range files {
+		if !validArtifactPath(file.Path) {
+			return fmt.Errorf("the coordinator listed a build data file with invalid path %q", file.Path)
+		}
+	}
+	return nil
+}
+
+func validArtifactPath(p string) bool {
+	if p == "" || p == "." || path.Clean(p) != p || path.IsAbs(p) || strings.Contains(p, `\`) {
+		return false
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return false
+	}
+	native := filepath.FromSlash(p)
+	return !filepath.IsAbs(native) && filepath.VolumeName(native) == ""
+}
+
+// hashLocalFiles returns the hashes of the local build data files (in any
+// commit), keyed by their path, so that files with the same contents as
+// those being fetched can be copied instead. Only the local files whose
+// sizes match one of files are hashed.
+func hashLocalFiles(rootDataDir string, files []*buildstore.StoredFileInfo) (map[string]string, error) {
+	sizes := make(map[int64]bool)
+	for _, file := range files {
+		if file.Hash != "" {
+			sizes[file.Size] = true
+		}
+	}
+	hashes := make(map[string]string)
+	err := filepath.Walk(rootDataDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !sizes[fi.Size()] {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hashes[path], err = buildstore.HashBlob(f)
+		return err
+	})
+	return hashes, err
+}
+
+// writeBuildDataFile writes the data read from r to the file at path. If
+// hash is nonempty, it returns an error (and removes the file) if the data
+// doesn't have that hash.
+func writeBuildDataFile(path string, r io.Reader, hash string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	got, err := buildstore.HashBlob(io.TeeReader(r, f))
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil && hash != "" && got != hash {
+		err = fmt.Errorf("contents have hash %s, want %s", got, hash)
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+type DistStatusCmd struct {
+	CoordinatorOpt
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+}
+
+var distStatusCmd DistStatusCmd
+
+func (c *DistStatusCmd) Execute(args []string) error {
+	statuses, err := c.client().Status()
+	if err != nil {
+		return err
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(statuses, "")
+		return nil
+	}
+
+	counts := make(map[workqueue.TaskState]int)
+	for _, s := range statuses {
+		counts[s.State]++
+		var info []string
+		if s.Worker != "" {
+			info = append(info, "worker "+s.Worker)
+		}
+		if s.Attempts > 1 {
+			info = append(info, fmt.Sprintf("%d attempts", s.Attempts))
+		}
+		if s.Error != "" {
+			info = append(info, "error: "+s.Error)
+		}
+		fmt.Printf("%-8s %s  %s\n", s.State, s.ID, strings.Join(info, "; "))
+	}
+	fmt.Printf("\n%d pending, %d leased, %d done, %d failed\n", counts[workqueue.Pending], counts[workqueue.Leased], counts[workqueue.Done], counts[workqueue.Failed])
+	return nil
+}
@@ -0,0 +1,73 @@
+package src
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+)
+
+func TestCheckArtifactPaths(t *testing.T) {
+	valid := []string{
+		"u/GoPackage.graph.json",
+		"buildmanifest.v0.json",
+		".logs/a.log",
+		"a..b/c",
+	}
+	hostile := []string{
+		"",
+		".",
+		"..",
+		"../../../.bashrc",
+		"u/../../x",
+		"/etc/passwd",
+		"./u/GoPackage.graph.json",
+		"u//GoPackage.graph.json",
+		"u/",
+		`..\\x`,
+	}
+	for _, p := range valid {
+		if err := checkArtifactPaths([]*buildstore.StoredFileInfo{{Path: p}}); err != nil {
+			t.Errorf("%q: got error %v, want valid", p, err)
+		}
+	}
+	for _, p := range hostile {
+		files := []*buildstore.StoredFileInfo{{Path: "u/GoPackage.graph.json"}, {Path: p}}
+		if err := checkArtifactPaths(files); err == nil {
+			t.Errorf("%q: got no error for a hostile listing", p)
+		}
+	}
+}
+
+func TestCheckoutCommit_invalid(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	marker := filepath.Join(tmpDir, "marker")
+
+	const commitID = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct{ cloneURL, commitID string }{
+		{"--upload-pack=touch " + marker, commitID},
+		{"-u", commitID},
+		{"", commitID},
+		{"https://example.com/r.git", "--output=" + marker},
+		{"https://example.com/r.git", "HEAD"},
+		{"https://example.com/r.git", commitID[:10]},
+		{"https://example.com/r.git", strings.ToUpper(commitID)},
+	}
+	for _, test := range tests {
+		if err := checkoutCommit(filepath.Join(tmpDir, "repo"), test.cloneURL, test.commitID); err == nil {
+			t.Errorf("clone URL %q commit %q: got no error", test.cloneURL, test.commitID)
+		}
+	}
+	if fis, err := ioutil.ReadDir(tmpDir); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 0 {
+		t.Errorf("got %d files in %s, want none (git was run)", len(fis), tmpDir)
+	}
+}
@@ -25,7 +25,9 @@ type DoAllCmd struct {
 	config.Options
 
 	ToolchainExecOpt `group:"execution"`
+	ExecLimitOpt     `group:"execution limits"`
 	BuildCacheOpt    `group:"build cache"`
+	ProfileOpt       `group:"profiling"`
 
 	Dir Directory `short:"C" long:"directory" description:"change to DIR before doing anything" value-name:"DIR"`
 }
@@ -39,6 +41,16 @@ func (c *DoAllCmd) Execute(args []string) error {
 		}
 	}
 
+	stopProfiling, err := c.ProfileOpt.Start()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stopProfiling(); err != nil {
+			log.Printf("Warning: failed to write profiles: %s.", err)
+		}
+	}()
+
 	// config
 	configCmd := &ConfigCmd{
 		Options:          c.Options,
@@ -53,6 +65,7 @@ func (c *DoAllCmd) Execute(args []string) error {
 	makeCmd := &MakeCmd{
 		Options:          c.Options,
 		ToolchainExecOpt: c.ToolchainExecOpt,
+		ExecLimitOpt:     c.ExecLimitOpt,
 		BuildCacheOpt:    c.BuildCacheOpt,
 	}
 	if err := makeCmd.Execute(nil); err != nil {
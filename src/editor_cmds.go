@@ -0,0 +1,351 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/search"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	c, err := CLI.AddCommand("editor",
+		"fast queries for editor plugins",
+		`Fast queries for editor plugins. Each command prints a single line of JSON (see the docs for its format) and only reads the build data of the last build of the current commit (or of its nearest built ancestor, or else of the latest build on the current branch): unlike the api commands, they never run a build or access the network. Run "src make" (or install the hooks with "src hooks install") to keep the build data up to date.
+
+Files in the output are absolute paths, and positions are byte offsets. To get errors as JSON too, use --error-format=json.`,
+		&editorCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("describe",
+		"describe the def at a position",
+		"Print the def that the ref at byte offset BYTE in FILE refers to (following aliases). If there is no graph data for the position (because the build is stale or the file's language isn't supported), the def of the identifier there is guessed by searching the source files textually, and Approximate is true. Def is null if none is found.",
+		&editorDescribeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("refs",
+		"list refs to the def at a position",
+		"Print the refs to the def that the ref at byte offset BYTE in FILE refers to, sorted by file and position: those in the current repository and then those in the other roots of its workspace (see \"src workspace\"), if any.",
+		&editorRefsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("search",
+		"search for defs by name",
+		"Print the defs in the current repository whose names or paths best match NAME, as \"src search\" does.",
+		&editorSearchCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// EditorDef is a def in the output of the editor commands.
+type EditorDef struct {
+	Repo     string
+	UnitType string
+	Unit     string
+	Path     string
+	Name     string
+	Kind     string
+
+	// File and Start and End are the location of the def. File is empty if
+	// the def is not in the current repository or a root of its workspace.
+	File  string
+	Start int
+	End   int
+
+	// Doc is the def's documentation (preferably plain text), and DocFormat
+	// is its MIME type. They are empty if the def has no documentation.
+	Doc       string
+	DocFormat string
+}
+
+// EditorRef is a ref in the output of "src editor refs".
+type EditorRef struct {
+	File  string
+	Start int
+	End   int
+
+	// Def is whether the ref is the def's definition.
+	Def bool
+}
+
+type EditorCmd struct{}
+
+var editorCmd EditorCmd
+
+func (c *EditorCmd) Execute(args []string) error { return nil }
+
+// EditorPosArgs are the positional arguments of the editor commands that
+// query the ref at a position.
+type EditorPosArgs struct {
+	File string `name:"FILE" description:"file containing the position"`
+	Byte int    `name:"BYTE" description:"byte offset of the position in FILE"`
+}
+
+type EditorDescribeCmd struct {
+	Args EditorPosArgs `positional-args:"yes" required:"yes"`
+}
+
+var editorDescribeCmd EditorDescribeCmd
+
+func (c *EditorDescribeCmd) Execute(args []string) error {
+	q, err := editorRefAt(c.Args)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Def *EditorDef
+
+		// Approximate is whether Def was guessed by searching the source
+		// files textually, because there is no graph data for the position.
+		Approximate bool
+	}
+	if q.ref == nil {
+		defs, err := approximateDefs(q.repo.RootDir, q.file, c.Args.Byte, 1)
+		if err != nil {
+			return err
+		}
+		if len(defs) > 0 {
+			d := defs[0]
+			resp.Def = &EditorDef{Name: d.Name, Kind: d.Kind, File: filepath.Join(q.repo.RootDir, d.File), Start: d.Start, End: d.End}
+			resp.Approximate = true
+		}
+		return printEditorJSON(resp)
+	}
+
+	key := q.ref.DefKey()
+	defRepo, defStore, err := localDefRepo(q.buildStore, q.repo, key.Repo)
+	if err != nil {
+		return err
+	}
+	if defRepo == nil {
+		resp.Def = newEditorDef(key, nil, nil, "")
+		return printEditorJSON(resp)
+	}
+
+	graphs := make(map[unit.ID]*grapher.Output)
+	key, _, err = graph.FollowAliases(key, func(k graph.DefKey) (*graph.Alias, error) {
+		return lookupAlias(defStore, defRepo, graphs, k)
+	})
+	if err != nil {
+		return err
+	}
+	def, docs, err := readDef(defStore, defRepo, key)
+	if err != nil {
+		return err
+	}
+	resp.Def = newEditorDef(key, def, docs, defRepo.RootDir)
+	return printEditorJSON(resp)
+}
+
+type EditorRefsCmd struct {
+	Args EditorPosArgs `positional-args:"yes" required:"yes"`
+}
+
+var editorRefsCmd EditorRefsCmd
+
+func (c *EditorRefsCmd) Execute(args []string) error {
+	q, err := editorRefAt(c.Args)
+	if err != nil {
+		return err
+	}
+	resp := struct{ Refs []*EditorRef }{Refs: []*EditorRef{}}
+	if q.ref == nil {
+		return printEditorJSON(resp)
+	}
+	repo, ref := q.repo, q.ref
+
+	add := func(buildStore *buildstore.RepositoryStore, repo *Repo) error {
+		refs, err := readDefRefs(buildStore, repo, ref.DefKey())
+		if err != nil {
+			return err
+		}
+		for _, r := range refs {
+			resp.Refs = append(resp.Refs, &EditorRef{
+				File:  filepath.Join(repo.RootDir, r.File),
+				Start: r.Start,
+				End:   r.End,
+				Def:   r.Def,
+			})
+		}
+		return nil
+	}
+	if err := add(q.buildStore, repo); err != nil {
+		return err
+	}
+
+	// Add the refs in the other roots of the workspace.
+	w, err := currentWorkspace(repo.RootDir)
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		for _, r := range w.Roots {
+			if r.Repo == repo.URI() {
+				continue
+			}
+			rootRepo, rootStore, err := openBuiltWorkspaceRoot(r)
+			if err != nil {
+				return err
+			}
+			if rootRepo == nil {
+				continue
+			}
+			if err := add(rootStore, rootRepo); err != nil {
+				return err
+			}
+		}
+	}
+
+	return printEditorJSON(resp)
+}
+
+type EditorSearchCmd struct {
+	Limit int `short:"n" long:"limit" default:"20" description:"maximum number of results (0 for no limit)" value-name:"N"`
+
+	Args struct {
+		Name string `name:"NAME" description:"name (or part of a name or path) of the defs to search for"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var editorSearchCmd EditorSearchCmd
+
+func (c *EditorSearchCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+	if repo, err = lastBuild(buildStore, repo); err != nil {
+		return err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	var indexes []*search.Index
+	for _, u := range units {
+		var ix *search.Index
+		if found, err := readSearchIndex(buildStore, repo, u, &search.Index{}, &ix); err != nil {
+			return err
+		} else if found {
+			indexes = append(indexes, ix)
+		}
+	}
+
+	resp := struct{ Results []*EditorDef }{Results: []*EditorDef{}}
+	for _, r := range search.Search(indexes, c.Args.Name, c.Limit) {
+		d := &EditorDef{
+			Repo:     string(repo.URI()),
+			UnitType: r.UnitType,
+			Unit:     r.Unit,
+			Path:     string(r.Path),
+			Name:     r.Name,
+			Kind:     string(r.Kind),
+			Start:    r.DefStart,
+			End:      r.DefEnd,
+		}
+		if r.File != "" {
+			d.File = filepath.Join(repo.RootDir, r.File)
+		}
+		resp.Results = append(resp.Results, d)
+	}
+	return printEditorJSON(resp)
+}
+
+// An editorPos is a position in a file that an editor command queries.
+type editorPos struct {
+	repo       *Repo // as of its last build
+	buildStore *buildstore.RepositoryStore
+
+	// file is the path of the file relative to the repo's root directory.
+	file string
+
+	// ref is the ref at the position, or nil if there is none.
+	ref *graph.Ref
+}
+
+// editorRefAt finds the ref at the position given by args.
+func editorRefAt(args EditorPosArgs) (*editorPos, error) {
+	file, err := filepath.Abs(args.File)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := OpenRepo(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	q := &editorPos{}
+	if q.file, err = filepath.Rel(repo.RootDir, file); err != nil {
+		return nil, err
+	}
+	if q.buildStore, err = buildstore.NewRepositoryStore(repo.RootDir); err != nil {
+		return nil, err
+	}
+	if q.repo, err = lastBuild(q.buildStore, repo); err != nil {
+		return nil, err
+	}
+	if q.ref, err = findRefAt(q.buildStore, q.repo, q.file, args.Byte); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// newEditorDef returns the EditorDef for the def with the given key. If
+// def is nil (because the def isn't in a local repo), only the key's
+// fields are set. rootDir is the root directory of the repo containing
+// def.
+func newEditorDef(key graph.DefKey, def *graph.Def, docs []*graph.Doc, rootDir string) *EditorDef {
+	d := &EditorDef{
+		Repo:     string(key.Repo),
+		UnitType: key.UnitType,
+		Unit:     key.Unit,
+		Path:     string(key.Path),
+	}
+	if def == nil {
+		return d
+	}
+	d.Name = def.Name
+	d.Kind = string(def.Kind)
+	if def.File != "" {
+		d.File = filepath.Join(rootDir, def.File)
+	}
+	d.Start, d.End = def.DefStart, def.DefEnd
+	for _, doc := range docs {
+		if d.DocFormat == "" || doc.Format == "text/plain" {
+			d.Doc, d.DocFormat = doc.Data, doc.Format
+		}
+	}
+	return d
+}
+
+// printEditorJSON prints v as a single line of JSON, as the editor commands
+// do.
+func printEditorJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", data)
+	return err
+}
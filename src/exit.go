@@ -0,0 +1,131 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sqs/go-flags"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+)
+
+// ErrorKind classifies the errors that cause src to exit with a non-zero
+// status. Each kind has a stable exit code (see ExitCode) so that programs
+// that run src can react to failures without parsing its output.
+type ErrorKind string
+
+const (
+	// InternalError is an unexpected error (or one that hasn't been
+	// classified). Its exit code is 1.
+	InternalError ErrorKind = "internal"
+
+	// UsageError means that the command line was invalid. Its exit code is 2.
+	UsageError ErrorKind = "usage"
+
+	// ConfigError means that the repository's configuration (Srcfile, cached
+	// source unit definitions, etc.) was missing or invalid. Its exit code is
+	// 3.
+	ConfigError ErrorKind = "config"
+
+	// ToolchainMissing means that a required toolchain or tool could not be
+	// found. Its exit code is 4.
+	ToolchainMissing ErrorKind = "toolchain-missing"
+
+	// UnitFailure means that the build failed and no source units were built
+	// successfully. Its exit code is 5.
+	UnitFailure ErrorKind = "unit-failure"
+
+	// PartialSuccess means that some source units failed to build but others
+	// were built successfully. Its exit code is 6.
+	PartialSuccess ErrorKind = "partial-success"
+
+	// BreakingChanges means that `src apidiff` found breaking changes to the
+	// API. Its exit code is 7.
+	BreakingChanges ErrorKind = "breaking-changes"
+)
+
+var exitCodes = map[ErrorKind]int{
+	InternalError:    1,
+	UsageError:       2,
+	ConfigError:      3,
+	ToolchainMissing: 4,
+	UnitFailure:      5,
+	PartialSuccess:   6,
+	BreakingChanges:  7,
+}
+
+// ExitCode returns the exit status that src uses for errors of kind k.
+func (k ErrorKind) ExitCode() int {
+	if code, present := exitCodes[k]; present {
+		return code
+	}
+	return exitCodes[InternalError]
+}
+
+// kindError is an error that has been classified.
+type kindError struct {
+	kind ErrorKind
+	err  error
+
+	// failedUnits lists the source units that failed to build (as
+	// "TYPE:NAME"), for UnitFailure and PartialSuccess errors.
+	failedUnits []string
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+
+// withKind classifies err as kind (unless err is nil or already classified).
+func withKind(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*kindError); ok {
+		return err
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// errorKind returns the kind of err.
+func errorKind(err error) ErrorKind {
+	switch e := err.(type) {
+	case *kindError:
+		return e.kind
+	case *flags.Error:
+		return UsageError
+	}
+	if toolchain.IsNotFound(err) {
+		return ToolchainMissing
+	}
+	return InternalError
+}
+
+// ErrorRecord is the JSON object that src prints (to stderr) before exiting
+// because of an error, when run with --error-format=json.
+type ErrorRecord struct {
+	Error    string
+	Kind     ErrorKind
+	ExitCode int
+
+	// FailedUnits lists the source units (as "TYPE:NAME") that failed to
+	// build, if any.
+	FailedUnits []string `json:",omitempty"`
+}
+
+// reportError writes err to w in the given format ("text" or "json") and
+// returns the exit code to use.
+func reportError(w io.Writer, format string, err error) int {
+	kind := errorKind(err)
+	rec := ErrorRecord{Error: err.Error(), Kind: kind, ExitCode: kind.ExitCode()}
+	if e, ok := err.(*kindError); ok {
+		rec.FailedUnits = e.failedUnits
+	}
+
+	if format == "json" {
+		if err := json.NewEncoder(w).Encode(rec); err != nil {
+			fmt.Fprintln(w, rec.Error)
+		}
+	} else {
+		fmt.Fprintln(w, rec.Error)
+	}
+	return rec.ExitCode
+}
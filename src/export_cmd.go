@@ -0,0 +1,88 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/srclib/apisurface"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+)
+
+func init() {
+	_, err := CLI.AddCommand("export",
+		"export build data as a static site or docs",
+		`Exports the current repository's build data (built by "src make") in the given format.
+
+The html format is a static site of the repository's files, with each ref hyperlinked to its def and identifiers highlighted by the kinds of their defs, plus an index of the repository's defs with pages listing the references to each. The pages link to each other by relative URLs, so the site can be deployed to any static web host.
+
+The markdown format is Markdown reference documentation of each source unit's exported defs (with their signatures and docs), plus an index of the source units. Names of defs quoted in docs are linked to the defs' docs. It uses the API surfaces extracted by "src make".`,
+		&exportCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ExportCmd struct {
+	Format string `long:"format" description:"export format" default:"html" value-name:"html|markdown"`
+	Out    string `short:"d" long:"out" description:"directory to write the export to" default:"srclib-export" value-name:"DIR"`
+}
+
+var exportCmd ExportCmd
+
+func (c *ExportCmd) Execute(args []string) error {
+	if c.Format != "html" && c.Format != "markdown" {
+		return withKind(UsageError, fmt.Errorf("unsupported export format %q (must be html or markdown)", c.Format))
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	writeFile := func(path string, data []byte) error {
+		path, err := joinInDir(c.Out, path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, data, 0644)
+	}
+
+	switch c.Format {
+	case "html":
+		site, err := readBrowseSite(buildStore, repo)
+		if err != nil {
+			return err
+		}
+		if err := site.WriteStatic(writeFile); err != nil {
+			return err
+		}
+
+	case "markdown":
+		surfaces, _, err := readAPISurfaces(buildStore, repo, repo.CommitID, "", "")
+		if err != nil {
+			return err
+		}
+		for path, data := range apisurface.Markdown(surfaces) {
+			if err := writeFile(path, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if verbose() {
+		log.Printf("Wrote %s export to %s.", c.Format, c.Out)
+	}
+	return nil
+}
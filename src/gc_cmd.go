@@ -0,0 +1,162 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/buildmanifest"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+func init() {
+	_, err := CLI.AddCommand("gc",
+		"remove orphaned build data",
+		`Removes stored build data that no build refers to: the files left behind by builds that were interrupted, the build data files of a commit that its build manifest doesn't list, and the pieces of chunked uploads that were never completed.
+
+Builds in progress hold a lease on their commit's build data, which gc leaves alone, and files modified less recently than --min-age ago are kept, so gc is safe to run while builds (and uploads to a coordinator) are in progress. The build data of commits without a build manifest (such as those built by older versions of srclib) is kept unless their build was interrupted.
+
+Retention options remove whole builds too: with any of them, only the builds that they retain are kept (along with those in progress, and files modified less than --min-age ago). --keep-per-branch keeps the latest builds on each branch (as "src builds" lists them), --keep-tags and --keep keep the builds of tagged and other given commits, and --expire-after keeps the other builds until they are that old. For example, "src gc --keep-per-branch 5 --keep-tags --expire-after 720h" keeps the 5 latest builds on each branch and the builds of tags, and removes other builds after 30 days. The local build data of the current commit is always kept.
+
+By default, the local build data of the current repository is collected. Use --store to collect an artifact store (such as the data directory of src dist coordinator) instead: the blobs that no file refers to are removed, along with the orphaned build data of each repository given with --repo. Artifact stores have no VCS metadata, so --keep-tags can't be used with --store; use --keep with the output of "git rev-list --no-walk --tags" instead.`,
+		&gcCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type GCCmd struct {
+	Store     string        `long:"store" description:"collect the artifact store in DIR (such as a coordinator's data directory) instead of the local build data" value-name:"DIR"`
+	BlobStore string        `long:"blob-store" description:"the blobs of --store are stored in the blob store at URL (see src dist coordinator --blob-store)" value-name:"URL"`
+	Repos     []string      `long:"repo" description:"also collect the build data of this repository in --store (may be given multiple times)" value-name:"URI"`
+	MinAge    time.Duration `long:"min-age" description:"keep files modified less than this long ago" default:"1h" value-name:"DURATION"`
+	DryRun    bool          `short:"n" long:"dry-run" description:"list the files that would be removed, but don't remove them"`
+
+	KeepPerBranch int           `long:"keep-per-branch" description:"keep the latest N builds on each branch" value-name:"N"`
+	KeepTags      bool          `long:"keep-tags" description:"keep the builds of tagged commits"`
+	Keep          []string      `long:"keep" description:"keep the build of this commit (may be given multiple times)" value-name:"COMMIT"`
+	ExpireAfter   time.Duration `long:"expire-after" description:"keep other builds until they are this old" value-name:"DURATION"`
+}
+
+// retention returns the retention policy that c's options specify, or nil
+// if they specify none. repo is the current repository, if the local build
+// data is being collected.
+func (c *GCCmd) retention(repo *Repo) (*buildstore.RetentionPolicy, error) {
+	if c.KeepPerBranch == 0 && !c.KeepTags && len(c.Keep) == 0 && c.ExpireAfter == 0 {
+		return nil, nil
+	}
+	p := &buildstore.RetentionPolicy{
+		KeepPerBranch: c.KeepPerBranch,
+		KeepCommits:   map[string]bool{},
+		ExpireAfter:   c.ExpireAfter,
+	}
+	for _, commitID := range c.Keep {
+		p.KeepCommits[commitID] = true
+	}
+	if repo != nil {
+		p.KeepCommits[repo.CommitID] = true
+		if c.KeepTags {
+			tagged, err := taggedCommits(repo.VCSType, repo.RootDir)
+			if err != nil {
+				return nil, err
+			}
+			for commitID := range tagged {
+				p.KeepCommits[commitID] = true
+			}
+		}
+	}
+	return p, nil
+}
+
+var gcCmd GCCmd
+
+func (c *GCCmd) Execute(args []string) error {
+	opt := buildstore.GCOptions{MinAge: c.MinAge, ManifestFiles: buildmanifest.ListedFiles, DryRun: c.DryRun}
+
+	if c.KeepPerBranch < 0 {
+		return withKind(UsageError, fmt.Errorf("--keep-per-branch must not be negative"))
+	}
+
+	var results []*buildstore.GCResult
+	if c.Store != "" {
+		if c.KeepTags {
+			return withKind(UsageError, fmt.Errorf("--keep-tags can't be given with --store"))
+		}
+		var err error
+		if opt.Retention, err = c.retention(nil); err != nil {
+			return err
+		}
+		if fi, err := os.Stat(c.Store); err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return withKind(UsageError, fmt.Errorf("not a directory: %s", c.Store))
+		}
+		store, err := openArtifactStore(c.Store, c.BlobStore)
+		if err != nil {
+			return err
+		}
+		for _, repoURI := range c.Repos {
+			repoStore, err := store.RepositoryStore(repo.URI(repoURI))
+			if err != nil {
+				return err
+			}
+			res, err := repoStore.GC(opt)
+			if err != nil {
+				return fmt.Errorf("collecting %s: %s", repoURI, err)
+			}
+			for i, p := range res.Removed {
+				res.Removed[i] = repoURI + "/" + p
+			}
+			results = append(results, res)
+		}
+		// Collect blobs last, since removing files may orphan them.
+		res, err := store.GCBlobs(opt)
+		if err != nil {
+			return fmt.Errorf("collecting blobs: %s", err)
+		}
+		results = append(results, res)
+	} else {
+		if len(c.Repos) > 0 || c.BlobStore != "" {
+			return withKind(UsageError, fmt.Errorf("--repo and --blob-store may only be given with --store"))
+		}
+		currentRepo, err := OpenRepo(".")
+		if err != nil {
+			return err
+		}
+		if opt.Retention, err = c.retention(currentRepo); err != nil {
+			return err
+		}
+		buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+		if err != nil {
+			return err
+		}
+		res, err := buildStore.GC(opt)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+
+	var n int
+	var size int64
+	for _, res := range results {
+		for _, p := range res.Removed {
+			if c.DryRun || verbose() {
+				fmt.Println(p)
+			}
+		}
+		n += len(res.Removed)
+		size += res.Size
+	}
+	if !quiet() {
+		verb := "Removed"
+		if c.DryRun {
+			verb = "Would remove"
+		}
+		log.Printf("%s %d files (%s).", verb, n, formatByteSize(size))
+	}
+	return nil
+}
@@ -44,7 +44,7 @@ The options are:
 			log.Fatal(err)
 		}
 
-		if *summary || GlobalOpt.Verbose {
+		if *summary || verbose() {
 			log.Printf("%s output summary:", u.ID())
 			log.Printf(" - %d defs", len(output.Defs))
 			log.Printf(" - %d refs", len(output.Refs))
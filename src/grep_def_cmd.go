@@ -0,0 +1,190 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/query"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("grep-def",
+		"filter the built defs or refs by regexps",
+		`Print the defs in the graph data built by "src make" for the current commit whose names match REGEXP (if given) and whose fields match the --name, --path, --file, and --kind regexps (if given). With --refs, print the refs instead; a ref's name and path are those of the def that it points to.
+
+Matches are printed as each source unit's graph data is read, so the output starts right away even for large repositories. With -o json, each match is printed as a JSON object on its own line (with only the --fields, if given), for processing with tools like jq. With -c, only the number of matches is printed.`,
+		&grepDefCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type GrepDefCmd struct {
+	Name string `long:"name" description:"regexp that names must match" value-name:"REGEXP"`
+	Path string `long:"path" description:"regexp that def paths must match" value-name:"REGEXP"`
+	File string `long:"file" description:"regexp that files must match" value-name:"REGEXP"`
+	Kind string `long:"kind" description:"regexp that def (or ref) kinds must match" value-name:"REGEXP"`
+	Refs bool   `long:"refs" description:"filter the refs instead of the defs"`
+
+	Count bool `short:"c" long:"count" description:"only print the number of matches"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+		Fields string `long:"fields" description:"comma-separated names of the fields of each match to print with -o json (e.g., File,Start,End)" value-name:"FIELDS"`
+	} `group:"output"`
+
+	Args struct {
+		Regexp string `name:"REGEXP" description:"regexp that names must match (the same as --name)"`
+	} `positional-args:"yes"`
+}
+
+var grepDefCmd GrepDefCmd
+
+func (c *GrepDefCmd) Execute(args []string) error {
+	name := c.Name
+	if c.Args.Regexp != "" {
+		if name != "" {
+			return withKind(UsageError, fmt.Errorf("specify either REGEXP or --name, not both"))
+		}
+		name = c.Args.Regexp
+	}
+	g, err := query.NewGrep(name, c.Path, c.File, c.Kind)
+	if err != nil {
+		return withKind(UsageError, err)
+	}
+	var item interface{} = &graph.Def{}
+	if c.Refs {
+		item = &graph.Ref{}
+	}
+	fields, err := query.ParseFields(c.Output.Fields, item)
+	if err != nil {
+		return withKind(UsageError, err)
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return fmt.Errorf("no build data found for commit %s (run `src make` first)", repo.CommitID)
+	}
+	sort.Sort(unitsByID(units))
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	p := newPrinter(os.Stdout)
+	p.W = w
+	enc := json.NewEncoder(w)
+
+	var n int
+	emit := func(v interface{}, file string, start, end int, kind string, key graph.DefKey) error {
+		n++
+		switch {
+		case c.Count:
+			return nil
+		case c.Output.Output == "json":
+			v, err := query.Select(v, fields)
+			if err != nil {
+				return err
+			}
+			return enc.Encode(v)
+		default:
+			fmt.Fprintf(w, "%s  %s  %s\n", p.Paint(present.Dim, fmt.Sprintf("%s:%d-%d", file, start, end)), p.Paint(present.Yellow, kind), p.Paint(present.Cyan, key.Format()))
+			return nil
+		}
+	}
+
+	for _, u := range units {
+		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+		f, err := buildStore.Open(graphFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		o, err := grapher.ReadOutput(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", graphFile, err)
+		}
+
+		// Fill in the empty fields of the def keys, so that they are
+		// complete.
+		if c.Refs {
+			for _, ref := range o.Refs {
+				if !g.MatchRef(ref) {
+					continue
+				}
+				if ref.DefRepo == "" {
+					ref.DefRepo = repo.URI()
+				}
+				if ref.DefUnitType == "" {
+					ref.DefUnitType = u.Type
+				}
+				if ref.DefUnit == "" {
+					ref.DefUnit = u.Name
+				}
+				kind := string(ref.Kind)
+				if ref.Def {
+					kind = "def"
+				} else if kind == "" {
+					kind = "ref"
+				}
+				if err := emit(ref, ref.File, ref.Start, ref.End, kind, ref.DefKey()); err != nil {
+					return err
+				}
+			}
+		} else {
+			for _, def := range o.Defs {
+				if !g.MatchDef(def) {
+					continue
+				}
+				if def.Repo == "" {
+					def.Repo = repo.URI()
+				}
+				if def.UnitType == "" {
+					def.UnitType = u.Type
+				}
+				if def.Unit == "" {
+					def.Unit = u.Name
+				}
+				if err := emit(def, def.File, def.DefStart, def.DefEnd, string(def.Kind), def.DefKey); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if c.Count {
+		fmt.Fprintln(w, n)
+	}
+	return nil
+}
+
+type unitsByID []*unit.SourceUnit
+
+func (v unitsByID) Len() int           { return len(v) }
+func (v unitsByID) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v unitsByID) Less(i, j int) bool { return v[i].ID() < v[j].ID() }
@@ -0,0 +1,169 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/githooks"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+)
+
+func init() {
+	c, err := CLI.AddCommand("hooks",
+		"manage git hooks that keep build data warm",
+		`Manage git hooks that build the current commit in the background after each checkout and merge, so that the build data is ready when an editor plugin asks for definitions.`,
+		&hooksCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("install",
+		"install the hooks",
+		fmt.Sprintf(`Install the %s hooks in the current git repository. Commands that the hooks already run are kept. The background builds run "src config" and "src make" (which only rebuild what changed) and log to srclib-hooks.log in the .git directory.`, strings.Join(warmHooks, " and ")),
+		&hooksInstallCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("uninstall",
+		"uninstall the hooks",
+		"Remove the commands that `src hooks install` added to the current git repository's hooks.",
+		&hooksUninstallCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("run",
+		"build in the background (run by the hooks)",
+		`Build the current commit, as the hooks installed by "src hooks install" do (they run this command in the background). If a build started by a hook is already running, it builds again when it's done instead.`,
+		&hooksRunCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// warmHooks are the git hooks that "src hooks install" installs.
+var warmHooks = []string{"post-checkout", "post-merge"}
+
+// hooksLogFilename is the name of the file (in the .git directory) that
+// builds started by hooks log to.
+const hooksLogFilename = "srclib-hooks.log"
+
+type HooksCmd struct{}
+
+var hooksCmd HooksCmd
+
+func (c *HooksCmd) Execute(args []string) error { return nil }
+
+type HooksInstallCmd struct{}
+
+var hooksInstallCmd HooksInstallCmd
+
+func (c *HooksInstallCmd) Execute(args []string) error {
+	hooksDir, err := gitPath("hooks")
+	if err != nil {
+		return err
+	}
+	for _, hook := range warmHooks {
+		cmd := fmt.Sprintf(`src hooks run %s "$@" </dev/null >/dev/null 2>&1 &`, hook)
+		if err := githooks.Install(hooksDir, hook, cmd); err != nil {
+			return err
+		}
+		log.Printf("Installed %s hook in %s.", hook, hooksDir)
+	}
+	if _, err := exec.LookPath("src"); err != nil {
+		log.Printf("Warning: the hooks run `src`, but it isn't in your PATH.")
+	}
+	return nil
+}
+
+type HooksUninstallCmd struct{}
+
+var hooksUninstallCmd HooksUninstallCmd
+
+func (c *HooksUninstallCmd) Execute(args []string) error {
+	hooksDir, err := gitPath("hooks")
+	if err != nil {
+		return err
+	}
+	for _, hook := range warmHooks {
+		removed, err := githooks.Uninstall(hooksDir, hook)
+		if err != nil {
+			return err
+		}
+		if removed {
+			log.Printf("Uninstalled %s hook from %s.", hook, hooksDir)
+		}
+	}
+	return nil
+}
+
+type HooksRunCmd struct {
+	Args struct {
+		Hook     string   `name:"HOOK" description:"the hook that is running this command"`
+		HookArgs []string `name:"HOOK-ARGS" description:"the hook's arguments"`
+	} `positional-args:"yes"`
+}
+
+var hooksRunCmd HooksRunCmd
+
+func (c *HooksRunCmd) Execute(args []string) error {
+	// post-checkout's third argument is 0 for checkouts of files (which
+	// don't change the commit).
+	if c.Args.Hook == "post-checkout" && len(c.Args.HookArgs) == 3 && c.Args.HookArgs[2] == "0" {
+		return nil
+	}
+
+	// Git runs hooks in the top-level directory of the working tree, so
+	// build there.
+	logFile, err := gitPath(hooksLogFilename)
+	if err != nil {
+		return err
+	}
+	lockFile, err := gitPath("srclib-hooks.lock")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return githooks.RunCoalesced(lockFile, 2*time.Hour, func() error {
+		fmt.Fprintf(f, "%s: building after %s\n", time.Now().Format(time.RFC3339), c.Args.Hook)
+		for _, args := range [][]string{{"config"}, {"make"}} {
+			cmd := exec.Command("src", args...)
+			cmd.Stdout, cmd.Stderr = f, f
+			// Don't fill the log with progress; only show errors.
+			cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", toolchain.VerbosityEnv, toolchain.Quiet))
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(f, "%s: src %s failed: %s\n", time.Now().Format(time.RFC3339), args[0], err)
+				return nil
+			}
+		}
+		fmt.Fprintf(f, "%s: done\n", time.Now().Format(time.RFC3339))
+		return nil
+	})
+}
+
+// gitPath returns the absolute path of name in the current git repository's
+// .git directory (as "git rev-parse --git-path" resolves it, so that
+// core.hooksPath and worktrees are respected).
+func gitPath(name string) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository (git rev-parse --git-path failed: %s)", err)
+	}
+	return filepath.Abs(strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,112 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/lsif"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("import",
+		"import build data from another format",
+		`Imports build data for the current repository from a file in another format, and stores it as the build data of a source unit for the repository's current commit. Other commands then use it as though it had been built by "src make".
+
+The lsif format is an LSIF (Language Server Index Format) dump, as produced by the LSIF indexers of many languages. Its definitions, references, and hover documentation are converted to defs, refs, and docs in a source unit of type LSIF, whose name is the name of the dump's package (or the --unit option). The dump's project root should be the repository root or a directory in it; the repository's files are read to convert the dump's positions to byte offsets.`,
+		&importCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ImportCmd struct {
+	Format string `long:"format" description:"import format" default:"lsif" value-name:"lsif"`
+	Unit   string `long:"unit" description:"name of the source unit to store the imported build data as (defaults to the name of the dump's package)" value-name:"NAME"`
+
+	Args struct {
+		File string `name:"FILE" description:"file to import"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var importCmd ImportCmd
+
+func (c *ImportCmd) Execute(args []string) error {
+	if c.Format != "lsif" {
+		return withKind(UsageError, fmt.Errorf("unsupported import format %q (must be lsif)", c.Format))
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.Args.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dump, err := lsif.Read(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", c.Args.File, err)
+	}
+
+	dir := dump.ProjectDir((&url.URL{Scheme: "file", Path: filepath.ToSlash(repo.RootDir)}).String())
+	readFile := func(file string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(repo.RootDir, filepath.FromSlash(file)))
+	}
+	files, output, err := dump.Convert(dir, readFile)
+	if err != nil {
+		return fmt.Errorf("%s: %s", c.Args.File, err)
+	}
+
+	name := c.Unit
+	if name == "" {
+		name = dump.Package
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(c.Args.File), filepath.Ext(c.Args.File))
+	}
+	u := &unit.SourceUnit{Name: name, Type: lsif.UnitType, Repo: repo.URI(), Files: files, Dir: dir}
+
+	if err := writeBuildData(buildStore, buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(unit.SourceUnit{}, u)), u); err != nil {
+		return err
+	}
+	if err := writeBuildData(buildStore, buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(&grapher.Output{}, u)), output); err != nil {
+		return err
+	}
+	log.Printf("Imported %d defs, %d refs, and %d docs in %d files into source unit %q type %q.", len(output.Defs), len(output.Refs), len(output.Docs), len(files), u.Name, u.Type)
+	return nil
+}
+
+// writeBuildData writes v as JSON to the file at path in the build store.
+func writeBuildData(buildStore *buildstore.RepositoryStore, path string, v interface{}) error {
+	if err := rwvfs.MkdirAll(buildStore, filepath.Dir(path)); err != nil {
+		return err
+	}
+	f, err := buildStore.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
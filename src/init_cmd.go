@@ -0,0 +1,190 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/detect"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func init() {
+	_, err := CLI.AddCommand("init",
+		"write a starter Srcfile",
+		`Scans the tree in the current directory, detects the languages it's written in (and the source units it probably contains), and writes a starter Srcfile that uses the scanners of those languages' toolchains and skips directories of vendored dependencies.
+
+With -i, asks which languages to build and which directories to skip before writing the Srcfile.
+
+Run "src config" afterwards to check which source units the scanners find.`,
+		&initCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type InitCmd struct {
+	Interactive bool `short:"i" long:"interactive" description:"ask which languages to build and which directories to skip"`
+	Force       bool `short:"f" long:"force" description:"overwrite an existing Srcfile"`
+	DryRun      bool `short:"n" long:"dry-run" description:"print the Srcfile instead of writing it"`
+
+	Dir Directory `short:"C" long:"directory" description:"change to DIR before doing anything" value-name:"DIR"`
+}
+
+var initCmd InitCmd
+
+func (c *InitCmd) Execute(args []string) error {
+	if c.Dir != "" {
+		if err := os.Chdir(string(c.Dir)); err != nil {
+			return err
+		}
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	if _, err := os.Stat(config.Filename); err == nil && !c.Force && !c.DryRun {
+		overwrite := false
+		if c.Interactive {
+			if overwrite, err = confirm(in, os.Stderr, fmt.Sprintf("A %s already exists. Overwrite it?", config.Filename), false); err != nil {
+				return err
+			}
+		}
+		if !overwrite {
+			return fmt.Errorf("%s already exists (use --force to overwrite it)", config.Filename)
+		}
+	}
+
+	res, err := detect.Detect(vfs.OS("."))
+	if err != nil {
+		return err
+	}
+	if len(res.Languages) == 0 {
+		var names []string
+		for _, lang := range detect.Languages {
+			names = append(names, lang.Name)
+		}
+		return fmt.Errorf("no source files in a supported language (%s) found", strings.Join(names, ", "))
+	}
+
+	p := newPrinter(os.Stderr)
+	printDetected(p, res)
+
+	var cfg config.Repository
+	var missing []string
+	for _, d := range res.Languages {
+		if c.Interactive {
+			q := fmt.Sprintf("Build %s (%d files, %d likely source units)?", d.Name, d.Files, len(d.UnitDirs))
+			if ok, err := confirm(in, os.Stderr, q, true); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+		scanners, installed := languageScanners(d.Language)
+		cfg.Scanners = append(cfg.Scanners, scanners...)
+		if !installed {
+			missing = append(missing, d.Toolchain)
+		}
+	}
+	if len(cfg.Scanners) == 0 {
+		return fmt.Errorf("no languages selected")
+	}
+	for _, dir := range res.SkipDirs {
+		if c.Interactive {
+			if ok, err := confirm(in, os.Stderr, fmt.Sprintf("Skip %s?", dir), true); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+		cfg.SkipDirs = append(cfg.SkipDirs, dir)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if c.DryRun {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := ioutil.WriteFile(config.Filename, data, 0644); err != nil {
+		return err
+	}
+
+	p.Printf(present.Green, "Wrote %s.", config.Filename)
+	for _, tc := range missing {
+		p.Printf(present.Yellow, "The toolchain %s isn't installed. Run `src toolchain install-std` (or `src toolchain get %s`) to install it.", tc, tc)
+	}
+	fmt.Fprintln(os.Stderr, "Run `src config` to see the source units that the scanners find, and `src make` to build them.")
+	return nil
+}
+
+// printDetected prints the languages and vendored directories that were
+// detected.
+func printDetected(p *present.Printer, res *detect.Result) {
+	t := &present.Table{
+		Header: []string{"LANGUAGE", "FILES", "UNITS", "TOOLCHAIN"},
+		Colors: []present.Color{present.Bold},
+		Align:  []string{"", "r", "r"},
+	}
+	for _, d := range res.Languages {
+		t.Rows = append(t.Rows, []string{d.Name, fmt.Sprint(d.Files), fmt.Sprint(len(d.UnitDirs)), d.Toolchain})
+	}
+	p.Table(t)
+	if len(res.SkipDirs) > 0 {
+		p.Printf(present.Dim, "Vendored directories: %s", strings.Join(res.SkipDirs, ", "))
+	}
+}
+
+// languageScanners returns the scanners of the standard toolchain for lang,
+// and whether the toolchain is installed. If it isn't, the scanner is
+// assumed to be the toolchain's "scan" tool.
+func languageScanners(lang *detect.Language) (scanners []*toolchain.ToolRef, installed bool) {
+	if tc, err := toolchain.Lookup(lang.Toolchain); err == nil {
+		if cfg, err := tc.ReadConfig(); err == nil {
+			for _, tool := range cfg.Tools {
+				if tool.Op == "scan" {
+					scanners = append(scanners, &toolchain.ToolRef{Toolchain: tc.Path, Subcmd: tool.Subcmd})
+				}
+			}
+			if len(scanners) > 0 {
+				return scanners, true
+			}
+		}
+	}
+	return []*toolchain.ToolRef{{Toolchain: lang.Toolchain, Subcmd: "scan"}}, false
+}
+
+// confirm asks a yes/no question on w and reads the answer from r. An empty
+// answer means def.
+func confirm(r *bufio.Reader, w io.Writer, question string, def bool) (bool, error) {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	for {
+		fmt.Fprintf(w, "%s %s ", question, choices)
+		answer, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
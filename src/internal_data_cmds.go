@@ -1,16 +1,29 @@
 package src
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/sqs/go-flags"
 
+	"sourcegraph.com/sourcegraph/srclib/apisurface"
 	"sourcegraph.com/sourcegraph/srclib/authorship"
+	"sourcegraph.com/sourcegraph/srclib/binindex"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/depsrc"
+	"sourcegraph.com/sourcegraph/srclib/graph"
 	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/manifest"
+	"sourcegraph.com/sourcegraph/srclib/search"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 	"sourcegraph.com/sourcegraph/srclib/vcsutil"
+	"sourcegraph.com/sourcegraph/srclib/workspace"
 )
 
 func init() {
@@ -29,27 +42,120 @@ func init() {
 		log.Fatal(err)
 	}
 
+	_, err = c.AddCommand("blame", "", "", &repoBlameCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = c.AddCommand("unit-blame", "", "", &unitBlameCmd)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	_, err = c.AddCommand("unit-manifest", "", "", &unitManifestCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("unit-search-index", "", "", &unitSearchIndexCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("unit-api-surface", "", "", &unitAPISurfaceCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("unit-graph-shards", "", "", &unitGraphShardsCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("binary-index", "", "", &binaryIndexCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("kube-job", "", "", &kubeJobCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("dep-sources", "", "", &depSourcesCmd)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
-type NormalizeGraphDataCmd struct{}
+type NormalizeGraphDataCmd struct {
+	TreeOpt
+
+	UnitData  flags.Filename `long:"unit-data" description:"source unit definition JSON file whose options (DefMerge, IncludeLocals, etc.) determine how the graph output is normalized" value-name:"FILE"`
+	Toolchain string         `long:"toolchain" description:"toolchain that produced the graph output read from stdin" value-name:"TOOLCHAIN"`
+
+	Args struct {
+		Outputs []string `name:"TOOLCHAIN=FILE" description:"graph outputs to merge (default: read a single graph output from stdin)"`
+	} `positional-args:"yes"`
+}
 
 var normalizeGraphDataCmd NormalizeGraphDataCmd
 
 func (c *NormalizeGraphDataCmd) Execute(args []string) error {
-	in := os.Stdin
+	var outputs []*grapher.ToolchainOutput
+	if len(c.Args.Outputs) == 0 {
+		var o *grapher.Output
+		if err := json.NewDecoder(os.Stdin).Decode(&o); err != nil {
+			return err
+		}
+		outputs = append(outputs, &grapher.ToolchainOutput{Toolchain: c.Toolchain, Output: o})
+	}
+	for _, arg := range c.Args.Outputs {
+		i := strings.Index(arg, "=")
+		if i == -1 {
+			return fmt.Errorf("invalid graph output %q (expected TOOLCHAIN=FILE)", arg)
+		}
+		var o *grapher.Output
+		if err := readJSONFile(arg[i+1:], &o); err != nil {
+			return err
+		}
+		outputs = append(outputs, &grapher.ToolchainOutput{Toolchain: arg[:i], Output: o})
+	}
 
-	var o *grapher.Output
-	if err := json.NewDecoder(in).Decode(&o); err != nil {
+	var u *unit.SourceUnit
+	if c.UnitData != "" {
+		if err := readJSONFile(string(c.UnitData), &u); err != nil {
+			return err
+		}
+	}
+
+	fs, err := c.fileSystem(".")
+	if err != nil {
+		return err
+	}
+	if err := grapher.ConvertToolchainOffsets(outputs, fs.ReadFile); err != nil {
 		return err
 	}
 
-	if err := grapher.NormalizeData(o); err != nil {
+	var defMerge *unit.DefMerge
+	var normalize *unit.Normalize
+	if u != nil {
+		defMerge, normalize = u.DefMerge, u.Normalize
+	}
+	o, err := grapher.NormalizeDataWith(outputs, defMerge, normalize)
+	if err != nil {
 		return err
 	}
+	if u != nil && u.IncludeLocals != nil && !*u.IncludeLocals {
+		grapher.RemoveLocals(o, u)
+	}
+	grapher.NameAnonymousDefs(o, fs.ReadFile)
+	if u != nil && u.Snippets != nil {
+		grapher.EmbedSnippets(o, u.Snippets.MaxLines, fs.ReadFile)
+	}
+	if u != nil {
+		grapher.ApplyFilters(o, u)
+	}
 
 	data, err := json.MarshalIndent(o, "", "  ")
 	if err != nil {
@@ -98,8 +204,71 @@ func (c *UnitAuthorshipCmd) Execute(args []string) error {
 	return nil
 }
 
+type RepoBlameCmd struct {
+	Args struct {
+		UnitData []string `name:"UNIT-DATA" description:"source unit definition JSON files"`
+	} `positional-args:"yes"`
+}
+
+var repoBlameCmd RepoBlameCmd
+
+func (c *RepoBlameCmd) Execute(args []string) error {
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	// Blame each file once, even if it's in multiple source units. If any
+	// source unit doesn't list its files, blame the whole repository.
+	var paths []string
+	seen := make(map[string]bool)
+	wholeRepo := false
+	for _, unitData := range c.Args.UnitData {
+		var u *unit.SourceUnit
+		if err := readJSONFile(unitData, &u); err != nil {
+			return err
+		}
+		if u.Files == nil {
+			wholeRepo = true
+			break
+		}
+		unitPaths, err := unit.ExpandPaths(currentRepo.RootDir, u.Files)
+		if err != nil {
+			return err
+		}
+		for _, p := range unitPaths {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	var out0 *vcsutil.BlameOutput
+	if wholeRepo {
+		out0, err = vcsutil.BlameRepository(currentRepo.RootDir, currentRepo.CommitID)
+	} else {
+		out0, err = vcsutil.BlameFiles(currentRepo.RootDir, paths, currentRepo.CommitID)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(out0, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 type UnitBlameCmd struct {
-	UnitData flags.Filename `long:"unit-data" required:"yes" description:"source unit definition JSON file" value-name:"FILE"`
+	UnitData  flags.Filename `long:"unit-data" required:"yes" description:"source unit definition JSON file" value-name:"FILE"`
+	BlameData flags.Filename `long:"blame-data" required:"yes" description:"blame output JSON file for the repository's source units" value-name:"FILE"`
 }
 
 var unitBlameCmd UnitBlameCmd
@@ -110,27 +279,306 @@ func (c *UnitBlameCmd) Execute(args []string) error {
 		return err
 	}
 
+	var b *vcsutil.BlameOutput
+	if err := readJSONFile(string(c.BlameData), &b); err != nil {
+		return err
+	}
+
+	out0 := b
+	if u.Files != nil {
+		currentRepo, err := OpenRepo(".")
+		if err != nil {
+			return err
+		}
+
+		paths, err := unit.ExpandPaths(currentRepo.RootDir, u.Files)
+		if err != nil {
+			return err
+		}
+		for i, p := range paths {
+			if paths[i], err = filepath.Rel(currentRepo.RootDir, p); err != nil {
+				return err
+			}
+		}
+		out0 = b.Filter(paths)
+	}
+
+	out, err := json.MarshalIndent(out0, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type UnitManifestCmd struct {
+	UnitData flags.Filename `long:"unit-data" required:"yes" description:"source unit definition JSON file" value-name:"FILE"`
+}
+
+var unitManifestCmd UnitManifestCmd
+
+func (c *UnitManifestCmd) Execute(args []string) error {
+	var u *unit.SourceUnit
+	if err := readJSONFile(string(c.UnitData), &u); err != nil {
+		return err
+	}
+
 	currentRepo, err := OpenRepo(".")
 	if err != nil {
 		return err
 	}
 
-	paths, err := unit.ExpandPaths(currentRepo.RootDir, u.Files)
+	out0, err := manifest.Compute(currentRepo.RootDir, u.Files)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	var out0 *vcsutil.BlameOutput
-	if paths == nil {
-		out0, err = vcsutil.BlameRepository(currentRepo.RootDir, currentRepo.CommitID)
+	out, err := json.MarshalIndent(out0, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type UnitSearchIndexCmd struct {
+	UnitData  flags.Filename `long:"unit-data" required:"yes" description:"source unit definition JSON file" value-name:"FILE"`
+	GraphData flags.Filename `long:"graph-data" required:"yes" description:"graph data JSON file" value-name:"FILE"`
+	FullText  bool           `long:"full-text" description:"build a full-text index of the defs' docs (instead of a name index)"`
+}
+
+var unitSearchIndexCmd UnitSearchIndexCmd
+
+func (c *UnitSearchIndexCmd) Execute(args []string) error {
+	var u *unit.SourceUnit
+	if err := readJSONFile(string(c.UnitData), &u); err != nil {
+		return err
+	}
+
+	// Only the defs (and docs, for a full-text index) are needed, so the
+	// rest of the graph data is skipped.
+	f, err := os.Open(string(c.GraphData))
+	if err != nil {
+		return err
+	}
+	var defs []*graph.Def
+	var docs []*graph.Doc
+	h := grapher.OutputHandlers{Def: func(def *graph.Def) error {
+		defs = append(defs, def)
+		return nil
+	}}
+	if c.FullText {
+		h.Doc = func(doc *graph.Doc) error {
+			docs = append(docs, doc)
+			return nil
+		}
+	}
+	err = grapher.StreamOutput(f, h)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", c.GraphData, err)
+	}
+
+	var ix interface{}
+	if c.FullText {
+		ix = search.NewDocIndex(u, defs, docs)
 	} else {
-		out0, err = vcsutil.BlameFiles(currentRepo.RootDir, paths, currentRepo.CommitID)
+		ix = search.NewIndex(u, defs)
 	}
+
+	out, err := json.MarshalIndent(ix, "", "  ")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	out, err := json.MarshalIndent(out0, "", "  ")
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type UnitAPISurfaceCmd struct {
+	UnitData  flags.Filename `long:"unit-data" required:"yes" description:"source unit definition JSON file" value-name:"FILE"`
+	GraphData flags.Filename `long:"graph-data" required:"yes" description:"graph data JSON file" value-name:"FILE"`
+}
+
+var unitAPISurfaceCmd UnitAPISurfaceCmd
+
+func (c *UnitAPISurfaceCmd) Execute(args []string) error {
+	var u *unit.SourceUnit
+	if err := readJSONFile(string(c.UnitData), &u); err != nil {
+		return err
+	}
+
+	var g *grapher.Output
+	if err := readJSONFile(string(c.GraphData), &g); err != nil {
+		return err
+	}
+
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	readFile := func(file string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(currentRepo.RootDir, file))
+	}
+
+	out, err := json.MarshalIndent(apisurface.Extract(u, g, readFile), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type UnitGraphShardsCmd struct {
+	GraphData flags.Filename `long:"graph-data" required:"yes" description:"graph data JSON file" value-name:"FILE"`
+	ShardDir  flags.Filename `long:"shard-dir" required:"yes" description:"directory to write the shards to (its existing contents are removed)" value-name:"DIR"`
+}
+
+var unitGraphShardsCmd UnitGraphShardsCmd
+
+func (c *UnitGraphShardsCmd) Execute(args []string) error {
+	var g *grapher.Output
+	if err := readJSONFile(string(c.GraphData), &g); err != nil {
+		return err
+	}
+
+	// Remove the shards of files that are no longer in the output.
+	dir := string(c.ShardDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	idx, shards := grapher.Shards(g)
+	for i, s := range shards {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, idx.Files[i].Shard), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	out, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type BinaryIndexCmd struct {
+	UnitData  []string `long:"unit-data" required:"yes" description:"source unit definition JSON file (one for each --graph-data)" value-name:"FILE"`
+	GraphData []string `long:"graph-data" required:"yes" description:"graph data JSON file of the source unit of the corresponding --unit-data" value-name:"FILE"`
+}
+
+var binaryIndexCmd BinaryIndexCmd
+
+func (c *BinaryIndexCmd) Execute(args []string) error {
+	if len(c.UnitData) != len(c.GraphData) {
+		return fmt.Errorf("got %d --unit-data and %d --graph-data files (want the same number)", len(c.UnitData), len(c.GraphData))
+	}
+
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	var o grapher.Output
+	for i, unitData := range c.UnitData {
+		var u *unit.SourceUnit
+		if err := readJSONFile(unitData, &u); err != nil {
+			return err
+		}
+		var g *grapher.Output
+		if err := readJSONFile(c.GraphData[i], &g); err != nil {
+			return err
+		}
+
+		// Fill in the empty fields of the def keys, so that the index can
+		// be queried by complete keys.
+		defKey := func(key graph.DefKey) graph.DefKey {
+			if key.Repo == "" {
+				key.Repo = currentRepo.URI()
+			}
+			if key.UnitType == "" && key.Unit == "" {
+				key.UnitType, key.Unit = u.Type, u.Name
+			}
+			return key
+		}
+		for _, def := range g.Defs {
+			def.DefKey = defKey(def.DefKey)
+		}
+		for _, doc := range g.Docs {
+			doc.DefKey = defKey(doc.DefKey)
+		}
+		for _, ref := range g.Refs {
+			ref.SetFromDefKey(defKey(ref.DefKey()))
+			if ref.Repo == "" {
+				ref.Repo = currentRepo.URI()
+			}
+			if ref.UnitType == "" && ref.Unit == "" {
+				ref.UnitType, ref.Unit = u.Type, u.Name
+			}
+		}
+		o.Defs = append(o.Defs, g.Defs...)
+		o.Refs = append(o.Refs, g.Refs...)
+		o.Docs = append(o.Docs, g.Docs...)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := binindex.Write(w, o.Defs, o.Refs, o.Docs); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// DepSourcesCmd checks out the repositories of the dependencies in the
+// depresolve output read from stdin, and writes the list of checkouts.
+type DepSourcesCmd struct{}
+
+var depSourcesCmd DepSourcesCmd
+
+func (c *DepSourcesCmd) Execute(args []string) error {
+	ress, err := dep.ReadResolutions(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cache := depsrc.DefaultCache()
+	if file := os.Getenv(workspace.Env); file != "" {
+		// Use the working trees of the other roots of the workspace that is
+		// being built.
+		w, err := readWorkspace(file)
+		if err != nil {
+			return err
+		}
+		cache.Local = w.Dirs()
+	}
+
+	out, err := json.MarshalIndent(cache.GetAll(ress), "", "  ")
 	if err != nil {
 		return err
 	}
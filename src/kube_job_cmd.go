@@ -0,0 +1,90 @@
+package src
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/kube"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+)
+
+// These environment variables configure the Kubernetes Jobs that run
+// toolchains when the "kubernetes" execution method is used. Only
+// SRCLIB_KUBE_ARTIFACT_URL is required. If SRCLIB_KUBE_SOURCE_CLAIM is
+// empty, each Job clones the current repository at the current commit.
+const (
+	kubeNamespaceEnv     = "SRCLIB_KUBE_NAMESPACE"
+	kubeArtifactURLEnv   = "SRCLIB_KUBE_ARTIFACT_URL"
+	kubeSourceClaimEnv   = "SRCLIB_KUBE_SOURCE_CLAIM"
+	kubeSourceSubPathEnv = "SRCLIB_KUBE_SOURCE_SUBPATH"
+	kubeMaxChunkSizeEnv  = "SRCLIB_KUBE_MAX_CHUNK_SIZE"
+	kubeTimeoutEnv       = "SRCLIB_KUBE_TIMEOUT" // e.g., "30m"
+)
+
+type KubeJobCmd struct {
+	Image string `long:"image" required:"yes" description:"toolchain Docker image"`
+
+	Args struct {
+		Command []string `name:"COMMAND" description:"toolchain command and args to run in the image"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var kubeJobCmd KubeJobCmd
+
+func (c *KubeJobCmd) Execute(args []string) error {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	spec := &kube.JobSpec{
+		Name:        "srclib-" + hex.EncodeToString(suffix),
+		Namespace:   os.Getenv(kubeNamespaceEnv),
+		Image:       c.Image,
+		Command:     c.Args.Command,
+		Input:       input,
+		ArtifactURL: os.Getenv(kubeArtifactURLEnv),
+	}
+	for _, name := range append([]string{toolchain.ProtocolVersionEnv, toolchain.CapabilitiesEnv}, network.EnvNames...) {
+		if v, set := os.LookupEnv(name); set {
+			spec.Env = append(spec.Env, name+"="+v)
+		}
+	}
+	if spec.ArtifactURL == "" {
+		return fmt.Errorf("%s must be set to the object storage URL to upload toolchain output to", kubeArtifactURLEnv)
+	}
+
+	if size := os.Getenv(kubeMaxChunkSizeEnv); size != "" {
+		if spec.MaxChunkSize, err = parseByteSize(size); err != nil {
+			return fmt.Errorf("%s: %s", kubeMaxChunkSizeEnv, err)
+		}
+	}
+
+	if timeout := os.Getenv(kubeTimeoutEnv); timeout != "" {
+		if spec.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return fmt.Errorf("%s: %s", kubeTimeoutEnv, err)
+		}
+	}
+
+	if claim := os.Getenv(kubeSourceClaimEnv); claim != "" {
+		spec.Source = kube.Source{VolumeClaim: claim, SubPath: os.Getenv(kubeSourceSubPathEnv)}
+	} else {
+		currentRepo, err := OpenRepo(".")
+		if err != nil {
+			return err
+		}
+		spec.Source = kube.Source{CloneURL: network.Default.Mirror(currentRepo.CloneURL), CommitID: currentRepo.CommitID}
+	}
+
+	return kube.Run(spec, os.Stdout, os.Stderr)
+}
@@ -0,0 +1,197 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib/dag"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+// These environment variables pass the memory budget from `src make` to the
+// `src tool` processes that run each toolchain.
+const (
+	maxMemoryEnv     = "SRCLIB_MAX_MEMORY"
+	memoryRootPIDEnv = "SRCLIB_MEMORY_ROOT_PID"
+	memoryDirEnv     = "SRCLIB_MEMORY_DIR" // the build data directory
+)
+
+// memoryLockFilename is the name of the file (in the build data directory)
+// that `src tool` processes lock while they check the memory budget, and
+// memoryWaitPrefix is the prefix of the names of the files that they create
+// (suffixed with their PIDs) while they wait to start. Both begin with "."
+// so that they aren't treated as build data.
+const (
+	memoryLockFilename = ".memory.lock"
+	memoryWaitPrefix   = ".memory-wait."
+)
+
+type ExecLimitOpt struct {
+	Jobs      int    `short:"j" long:"jobs" description:"number of toolchain processes to run concurrently (default: number of CPUs)" value-name:"N"`
+	MaxMemory string `long:"max-memory" description:"don't start toolchain processes while the running ones use more than SIZE of memory (e.g., 512M or 4G)" value-name:"SIZE"`
+}
+
+// apply configures e to use the options' concurrency limit and sets up the
+// environment so that toolchain processes started by `src tool` respect the
+// memory budget.
+func (o *ExecLimitOpt) apply(e *dag.Engine) error {
+	e.ParallelJobs = o.Jobs
+	if e.ParallelJobs <= 0 {
+		e.ParallelJobs = runtime.NumCPU()
+	}
+
+	if o.MaxMemory != "" {
+		maxMem, err := parseByteSize(o.MaxMemory)
+		if err != nil {
+			return fmt.Errorf("invalid --max-memory: %s", err)
+		}
+		if err := os.Setenv(maxMemoryEnv, strconv.FormatInt(maxMem, 10)); err != nil {
+			return err
+		}
+		if err := os.Setenv(memoryRootPIDEnv, strconv.Itoa(os.Getpid())); err != nil {
+			return err
+		}
+		dir, err := filepath.Abs(filepath.Dir(e.StateFile))
+		if err != nil {
+			return err
+		}
+		if err := os.Setenv(memoryDirEnv, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as "4096", "512M", or "4G" (with K, M, G,
+// and T being powers of 1024).
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mult := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i != -1 {
+			mult = 1 << (10 * uint(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return n * mult, nil
+}
+
+// waitForMemoryBudget blocks while the memory used by the other running
+// processes of the build exceeds the budget set by `src make --max-memory`.
+// If no budget is set, it returns immediately.
+//
+// The current process, its ancestors, and the other `src tool` processes
+// that are waiting here (and their ancestors) are not counted, since they
+// haven't started their toolchains. It never waits if no other processes
+// are counted, so a single toolchain that exceeds the budget on its own will
+// still run (rather than wait forever).
+//
+// Only one process at a time checks the budget. If it had to wait, it keeps
+// the others waiting for a moment after it returns, so that the memory used
+// by the toolchain it starts is counted before the next one starts.
+func waitForMemoryBudget() {
+	maxMem, _ := strconv.ParseInt(os.Getenv(maxMemoryEnv), 10, 64)
+	rootPID, _ := strconv.Atoi(os.Getenv(memoryRootPIDEnv))
+	dir := os.Getenv(memoryDirEnv)
+	if maxMem <= 0 || rootPID <= 0 || dir == "" {
+		return
+	}
+
+	waitFile := filepath.Join(dir, memoryWaitPrefix+strconv.Itoa(os.Getpid()))
+	if err := ioutil.WriteFile(waitFile, nil, 0600); err != nil {
+		log.Printf("Warning: ignoring memory budget: %s.", err)
+		return
+	}
+	defer os.Remove(waitFile)
+
+	unlock, err := lockFile(filepath.Join(dir, memoryLockFilename))
+	if err != nil {
+		log.Printf("Warning: ignoring memory budget: %s.", err)
+		return
+	}
+
+	const pollInterval = 500 * time.Millisecond
+	var waited time.Duration
+	for {
+		used, others, err := buildMemoryUsage(rootPID, dir)
+		if err != nil {
+			log.Printf("Warning: ignoring memory budget: %s.", err)
+			unlock()
+			return
+		}
+		if others == 0 || used <= maxMem {
+			if waited > 0 {
+				if verbose() {
+					log.Printf("Waited %s for memory usage to drop below budget.", waited)
+				}
+				time.AfterFunc(2*pollInterval, unlock)
+			} else {
+				unlock()
+			}
+			return
+		}
+		if waited == 0 && verbose() {
+			log.Printf("Other toolchain processes are using %.1fMB (budget is %.1fMB); waiting to start.", float64(used)/(1024*1024), float64(maxMem)/(1024*1024))
+		}
+		time.Sleep(pollInterval)
+		waited += pollInterval
+	}
+}
+
+// buildMemoryUsage returns the total RSS of the processes descended from
+// rootPID that are counted toward the memory budget (see
+// waitForMemoryBudget), and the number of such processes. The waiting
+// processes are listed in dir.
+func buildMemoryUsage(rootPID int, dir string) (used int64, others int, err error) {
+	tree, err := util.ProcessTree(rootPID)
+	if err != nil {
+		return 0, 0, err
+	}
+	waitFiles, err := filepath.Glob(filepath.Join(dir, memoryWaitPrefix+"*"))
+	if err != nil {
+		return 0, 0, err
+	}
+	waiting := []int{os.Getpid()}
+	for _, f := range waitFiles {
+		if pid, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(f), memoryWaitPrefix)); err == nil {
+			waiting = append(waiting, pid)
+		}
+	}
+	used, others = countedMemoryUsage(tree, waiting)
+	return used, others, nil
+}
+
+// countedMemoryUsage returns the total RSS of the processes in tree that
+// are not in waiting or ancestors of them, and the number of such
+// processes.
+func countedMemoryUsage(tree []util.ProcessStat, waiting []int) (used int64, others int) {
+	ppids := make(map[int]int, len(tree))
+	for _, p := range tree {
+		ppids[p.PID] = p.PPID
+	}
+	uncounted := make(map[int]bool)
+	for _, pid := range waiting {
+		for ; pid > 0 && !uncounted[pid]; pid = ppids[pid] {
+			uncounted[pid] = true
+		}
+	}
+
+	for _, p := range tree {
+		if !uncounted[p.PID] {
+			used += p.RSS
+			others++
+		}
+	}
+	return used, others
+}
@@ -0,0 +1,36 @@
+package src
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+func TestCountedMemoryUsage(t *testing.T) {
+	// src make (1) runs 3 shells (2, 4, 6), each running `src tool` (3, 5,
+	// 7). Only the first `src tool` has started its toolchain (8).
+	tree := []util.ProcessStat{
+		{PID: 1, PPID: 0, RSS: 1000},
+		{PID: 2, PPID: 1, RSS: 1}, {PID: 3, PPID: 2, RSS: 10}, {PID: 8, PPID: 3, RSS: 500},
+		{PID: 4, PPID: 1, RSS: 1}, {PID: 5, PPID: 4, RSS: 10},
+		{PID: 6, PPID: 1, RSS: 1}, {PID: 7, PPID: 6, RSS: 10},
+	}
+	tests := []struct {
+		waiting    []int
+		wantUsed   int64
+		wantOthers int
+	}{
+		// The 2nd `src tool` is checking, and the 3rd is waiting.
+		{waiting: []int{5, 7}, wantUsed: 511, wantOthers: 3},
+		// The 3rd `src tool` isn't counted once it has no marker file.
+		{waiting: []int{5}, wantUsed: 522, wantOthers: 5},
+		// Unknown PIDs (e.g., from stale files) are ignored.
+		{waiting: []int{5, 7, 99}, wantUsed: 511, wantOthers: 3},
+	}
+	for _, test := range tests {
+		used, others := countedMemoryUsage(tree, test.waiting)
+		if used != test.wantUsed || others != test.wantOthers {
+			t.Errorf("waiting %v: got used %d, others %d, want %d, %d", test.waiting, used, others, test.wantUsed, test.wantOthers)
+		}
+	}
+}
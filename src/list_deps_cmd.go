@@ -45,14 +45,14 @@ The options are:
 			log.Fatal(err)
 		}
 
-		if GlobalOpt.Verbose {
+		if verbose() {
 			log.Printf("%s", u.ID())
 		}
 
 		allRawDeps = append(allRawDeps, rawDeps...)
 
 		for _, rawDep := range rawDeps {
-			if GlobalOpt.Verbose {
+			if verbose() {
 				log.Printf("%+v", rawDep)
 			}
 
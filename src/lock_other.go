@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package src
+
+// lockFile does nothing, since file locks aren't supported on this
+// platform. (Neither is the process listing that the memory budget, its
+// only user, needs.)
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
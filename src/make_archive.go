@@ -0,0 +1,124 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+// ArchiveOpt are the options of `src make` for building a source tree that
+// is given as an archive, instead of a repository's working tree.
+type ArchiveOpt struct {
+	Archive      string `long:"archive" description:"build the source tree in this archive (tar, tar.gz, tar.bz2, or zip) instead of the current repository (requires --repo and --commit)" value-name:"FILE"`
+	Commit       string `long:"commit" description:"with --archive, the commit ID of the archived tree" value-name:"COMMIT"`
+	BuildDataDir string `long:"build-data-dir" description:"with --archive, the directory to write the build data to (default: .srclib-cache/COMMIT)" value-name:"DIR"`
+}
+
+// makeArchive configures and builds the tree in the archive c.Archive, as
+// the tree of commit c.Commit of repository c.Repo. The tree is exported to
+// a temporary directory (in the parent of the build data directory), which
+// is removed after the build; the build data is then moved to the build data
+// directory.
+func (c *MakeCmd) makeArchive() error {
+	if c.Repo == "" || c.Commit == "" {
+		return withKind(UsageError, fmt.Errorf("--archive requires --repo and --commit"))
+	}
+	if c.TrackDefs {
+		return withKind(UsageError, fmt.Errorf("--track-defs can't be used with --archive (an archive has no history)"))
+	}
+
+	fs, err := vfs.Archive(c.Archive)
+	if err != nil {
+		return err
+	}
+
+	dest := c.BuildDataDir
+	if dest == "" {
+		dest = filepath.Join(buildstore.BuildDataDirName, c.Commit)
+	}
+	if dest, err = filepath.Abs(dest); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("build data directory %s already exists", dest)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dest), ".srclib-archive")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if verbose() {
+		log.Printf("Exporting %s to %s.", c.Archive, tmpDir)
+	}
+	if err := vfs.Export(fs, tmpDir); err != nil {
+		return err
+	}
+
+	// The exported tree has no VCS metadata, so tell OpenRepo (in this
+	// process and in the processes of the build) which repository and
+	// commit it is.
+	rc := &Repo{RootDir: tmpDir, CommitID: c.Commit, CloneURL: "https://" + c.Repo}
+	data, err := json.Marshal(rc)
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv(archiveRepoEnv, string(data)); err != nil {
+		return err
+	}
+	defer os.Unsetenv(archiveRepoEnv)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+
+	configCmd := &ConfigCmd{
+		Options:          config.Options{Repo: c.Repo, Subdir: "."},
+		ToolchainExecOpt: c.ToolchainExecOpt,
+		BuildCacheOpt:    c.BuildCacheOpt,
+		w:                os.Stderr,
+	}
+	done := c.phase("configure")
+	err = configCmd.Execute(nil)
+	done(err)
+	if err != nil {
+		return err
+	}
+
+	makeErr := c.make()
+	if c.PrintMakefile || c.DryRun || c.Plan {
+		return makeErr
+	}
+
+	// Keep the build data even if the build failed, since some source
+	// units may have been built.
+	buildStore, err := buildstore.NewRepositoryStore(tmpDir)
+	if err != nil {
+		return err
+	}
+	buildDir, err := buildstore.BuildDir(buildStore, c.Commit)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(buildDir, dest); err != nil {
+		return err
+	}
+	log.Printf("Wrote build data for %s@%s to %s.", c.Repo, c.Commit, dest)
+	return makeErr
+}
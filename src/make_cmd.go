@@ -1,9 +1,14 @@
 package src
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/sourcegraph/makex"
 
@@ -11,8 +16,14 @@ import (
 
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
 	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/dag"
+	"sourcegraph.com/sourcegraph/srclib/diag"
+	"sourcegraph.com/sourcegraph/srclib/events"
 	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/report"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
 )
 
 func init() {
@@ -32,16 +43,32 @@ type MakeCmd struct {
 	config.Options
 
 	ToolchainExecOpt `group:"execution"`
+	ExecLimitOpt     `group:"execution limits"`
 	BuildCacheOpt    `group:"build cache"`
+	ProfileOpt       `group:"profiling"`
+	VulnOpt          `group:"vulnerabilities"`
+	ArchiveOpt       `group:"archive"`
 
 	PrintMakefile bool `short:"p" long:"print" description:"print planned Makefile and exit"`
 	DryRun        bool `short:"n" long:"dry-run" description:"print what would be done and exit"`
+	Plan          bool `long:"plan" description:"print the steps that would run (and the cached targets that would be reused) and exit"`
+
+	ResourceReport bool   `long:"resource-report" description:"record the CPU and memory used by each toolchain process and print a summary"`
+	Annotations    string `long:"annotations" description:"print build failures (and, with --vulns, vulnerable dependencies) to stdout as CI annotations in this format" value-name:"github|json|sarif"`
+	TrackDefs      bool   `long:"track-defs" description:"after a successful build, match the defs of the nearest previously built ancestor commit to the new build's defs (see \"src defs map\")"`
+	Events         string `long:"events" description:"write a stream of build lifecycle events (as JSON, one per line) to this file or to file descriptor N (fd:N)" value-name:"PATH|fd:N"`
 
 	Dir Directory `short:"C" long:"directory" description:"change to DIR before doing anything" value-name:"DIR"`
 
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format (for --plan)" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
 	Args struct {
 		Goals []string `name:"GOALS..." description:"Makefile targets to build (default: all)"`
 	} `positional-args:"yes"`
+
+	events *events.Writer // the --events stream (or nil)
 }
 
 var makeCmd MakeCmd
@@ -53,7 +80,50 @@ func (c *MakeCmd) Execute(args []string) error {
 		}
 	}
 
-	mk, mf, err := CreateMaker(c.ToolchainExecOpt, c.Args.Goals)
+	stopProfiling, err := c.ProfileOpt.Start()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stopProfiling(); err != nil {
+			log.Printf("Warning: failed to write profiles: %s.", err)
+		}
+	}()
+
+	if c.Events != "" {
+		w, err := events.Open(c.Events)
+		if err != nil {
+			return fmt.Errorf("opening event stream: %s", err)
+		}
+		c.events = w
+		defer func() {
+			if err := w.Close(); err != nil {
+				log.Printf("Warning: failed to write event stream: %s.", err)
+			}
+		}()
+	}
+
+	start := time.Now()
+	c.events.Emit(&events.Event{Type: events.BuildStarted})
+	if c.Archive != "" {
+		err = c.makeArchive()
+	} else {
+		err = c.make()
+	}
+	e := &events.Event{Type: events.BuildFinished, Duration: time.Since(start)}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	c.events.Emit(e)
+	return err
+}
+
+// make plans and runs the build of the tree in the current directory (or,
+// depending on the options, prints the plan).
+func (c *MakeCmd) make() error {
+	done := c.phase("plan")
+	mk, mf, err := CreateMaker(c.ToolchainExecOpt, c.ExecLimitOpt, c.Args.Goals)
+	done(err)
 	if err != nil {
 		return err
 	}
@@ -71,13 +141,276 @@ func (c *MakeCmd) Execute(args []string) error {
 		return mk.DryRun(os.Stdout)
 	}
 
-	return mk.Run()
+	if c.Plan {
+		steps, err := makePlan(mk, mf)
+		if err != nil {
+			return err
+		}
+		if c.Output.Output == "json" {
+			PrintJSON(steps, "")
+		} else {
+			printPlan(os.Stdout, steps)
+		}
+		return nil
+	}
+
+	return c.runAndReport(mk, mf)
+}
+
+// runAndReport runs mk, recording each toolchain process that `src tool`
+// runs, and writes the build's manifest (see buildmanifest.Manifest).
+// Depending on the options, it then prints and saves a report of the
+// resources they used (see report.Report), checks the resolved dependencies
+// for vulnerabilities, prints CI annotations for the failed runs and
+// vulnerable dependencies, records a successful build on the current
+// branch, and tracks defs from the previous build. If the
+// build fails, the returned error is classified
+// according to which source units failed (see UnitFailure and
+// PartialSuccess).
+func (c *MakeCmd) runAndReport(mk *dag.Engine, mf *makex.Makefile) error {
+	f, err := ioutil.TempFile("", "srclib-tool-runs")
+	if err != nil {
+		return err
+	}
+	logFile := f.Name()
+	f.Close()
+	defer os.Remove(logFile)
+	if err := os.Setenv(report.LogEnv, logFile); err != nil {
+		return err
+	}
+	defer os.Unsetenv(report.LogEnv)
+
+	// Have `src tool` keep a log of each run in the build data directory
+	// (which the state file is in).
+	buildDataDir := filepath.Dir(mk.StateFile)
+	logDir, err := filepath.Abs(filepath.Join(buildDataDir, report.LogsDirName))
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv(report.LogDirEnv, logDir); err != nil {
+		return err
+	}
+	defer os.Unsetenv(report.LogDirEnv)
+
+	// Report on the runs even if the build failed, since a failure (e.g.,
+	// an OOM kill) is often why the report was requested.
+	if c.events != nil {
+		emitStepEvents(c.events, mk)
+	}
+	// Hold a lease on the build data while building, so that garbage
+	// collection doesn't remove the partial build data.
+	release, err := holdBuildLease()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	start := time.Now()
+	done := c.phase("execute")
+	runErr := mk.Run()
+	done(runErr)
+
+	runs, err := report.ReadLog(logFile)
+	if err != nil {
+		return err
+	}
+	if err := saveBuildManifest(mf, buildDataDir, runs, start, runErr); err != nil {
+		return fmt.Errorf("writing build manifest: %s", err)
+	}
+
+	diags := toolRunDiagnostics(mf, runs, buildDataDir)
+	if c.Vulns {
+		vulnDiags, err := c.checkVulns()
+		if err != nil {
+			return fmt.Errorf("checking dependencies for vulnerabilities: %s", err)
+		}
+		diags = append(diags, vulnDiags...)
+	}
+
+	if c.Annotations != "" {
+		if err := diag.Write(os.Stdout, c.Annotations, diags); err != nil {
+			return err
+		}
+	}
+
+	if c.ResourceReport {
+		if err := saveResourceReport(report.New(runs)); err != nil {
+			return err
+		}
+	}
+
+	if runErr != nil {
+		for _, r := range runs {
+			if r.Error != "" && r.LogFile != "" {
+				log.Printf("%s failed; see its log in %s.", runDescription(r), filepath.Join(buildDataDir, r.LogFile))
+			}
+		}
+		return buildFailure(mf, runs, runErr)
+	}
+
+	if err := recordBranchBuild(); err != nil {
+		return fmt.Errorf("recording the build's branch: %s", err)
+	}
+
+	if c.TrackDefs {
+		if err := c.trackDefs(); err != nil {
+			return fmt.Errorf("tracking defs: %s", err)
+		}
+	}
+	return nil
+}
+
+// trackDefs matches the defs of the nearest previously built ancestor of the
+// current commit to the defs of the current commit's build (see
+// defmap.Compute), if there is such an ancestor.
+func (c *MakeCmd) trackDefs() error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+	oldCommitID, err := previousBuild(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	if oldCommitID == "" {
+		if !quiet() {
+			log.Printf("Not tracking defs: no ancestor of commit %s has been built.", repo.CommitID)
+		}
+		return nil
+	}
+	m, err := trackDefs(buildStore, repo, oldCommitID)
+	if err != nil {
+		return err
+	}
+	if !quiet() {
+		log.Printf("Tracked defs from %s: %d unchanged, %d renamed or moved, %d added, %d removed.", oldCommitID, m.Unchanged, len(m.Matches), len(m.Added), len(m.Removed))
+	}
+	return nil
+}
+
+// buildFailure classifies the error from a failed build as UnitFailure or
+// PartialSuccess, depending on whether any source units were built
+// successfully.
+func buildFailure(mf *makex.Makefile, runs []*report.ToolRun, err error) error {
+	failed := make(map[string]bool)
+	for _, r := range runs {
+		if r.Error != "" && r.UnitName != "" {
+			failed[r.UnitType+":"+r.UnitName] = true
+		}
+	}
+
+	units := make(map[string]bool)
+	for _, rule := range mf.Rules {
+		if r, ok := rule.(plan.SourceUnitRule); ok {
+			u := r.SourceUnit()
+			units[u.Type+":"+u.Name] = true
+		}
+	}
+
+	e := &kindError{kind: UnitFailure, err: err}
+	for u := range failed {
+		e.failedUnits = append(e.failedUnits, u)
+	}
+	sort.Strings(e.failedUnits)
+	if len(failed) > 0 && len(failed) < len(units) {
+		e.kind = PartialSuccess
+	}
+	return e
+}
+
+// toolRunDiagnostics returns a diagnostic for each failed tool run, which
+// refers to the run's log file (in buildDataDir).
+func toolRunDiagnostics(mf *makex.Makefile, runs []*report.ToolRun, buildDataDir string) []*diag.Diagnostic {
+	units := make(map[[2]string]*unit.SourceUnit)
+	for _, rule := range mf.Rules {
+		if r, ok := rule.(plan.SourceUnitRule); ok {
+			u := r.SourceUnit()
+			units[[2]string{u.Name, u.Type}] = u
+		}
+	}
+
+	var diags []*diag.Diagnostic
+	for _, r := range runs {
+		if r.Error == "" {
+			continue
+		}
+		msg := fmt.Sprintf("%s %s failed: %s", r.Toolchain, r.Tool, r.Error)
+		if r.LogFile != "" {
+			msg += fmt.Sprintf(" (log: %s)", filepath.Join(buildDataDir, r.LogFile))
+		}
+		if u, present := units[[2]string{r.UnitName, r.UnitType}]; present {
+			diags = append(diags, unitDiagnostic(u, r.Tool+" failed", msg))
+		} else {
+			diags = append(diags, &diag.Diagnostic{Severity: diag.Error, Title: r.Tool + " failed", Message: msg})
+		}
+	}
+	return diags
 }
 
-// CreateMaker creates a Makefile and a Maker. The cwd should be the root of the
-// tree you want to make (due to some probably unnecessary assumptions that
-// CreateMaker makes).
-func CreateMaker(execOpt ToolchainExecOpt, goals []string) (*makex.Maker, *makex.Makefile, error) {
+// runDescription describes the tool run r, such as "graph for
+// example.com/foo (GoPackage)".
+func runDescription(r *report.ToolRun) string {
+	if r.UnitName == "" {
+		return fmt.Sprintf("%s %s", r.Toolchain, r.Tool)
+	}
+	return fmt.Sprintf("%s for %s (%s)", r.Tool, r.UnitName, r.UnitType)
+}
+
+// saveResourceReport prints a summary of rep and writes it to the build data
+// directory.
+func saveResourceReport(rep *report.Report) error {
+	printResourceReport(newPrinter(os.Stderr), rep)
+
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+	w, err := buildStore.Create(buildStore.FilePath(currentRepo.CommitID, report.Filename))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return json.NewEncoder(w).Encode(rep)
+}
+
+func printResourceReport(p *present.Printer, rep *report.Report) {
+	t := &present.Table{
+		Header: []string{"UNIT", "TOOL", "WALL", "CPU", "MAXRSS", ""},
+		Colors: []present.Color{present.Plain, present.Plain, present.Plain, present.Plain, present.Plain, present.Red},
+		Align:  []string{"", "", "r", "r", "r"},
+	}
+	for _, r := range rep.Runs {
+		unit := r.UnitName
+		if r.UnitType != "" {
+			unit += " (" + r.UnitType + ")"
+		}
+		var status string
+		if r.Error != "" {
+			status = "FAILED: " + r.Error
+		}
+		t.Rows = append(t.Rows, []string{unit, r.Tool, roundDuration(r.Wall), roundDuration(r.UserTime + r.SystemTime),
+			fmt.Sprintf("%.1fMB", float64(r.MaxRSS)/(1024*1024)), status})
+	}
+	p.Table(t)
+	p.Printf(present.Bold, "%d toolchain processes; total CPU %s; largest MAXRSS %.1fMB", len(rep.Runs), roundDuration(rep.TotalCPU), float64(rep.MaxRSS)/(1024*1024))
+}
+
+// CreateMaker creates a Makefile and a build engine that builds goals (or
+// the Makefile's default rule, if goals is empty) from it. The cwd should be
+// the root of the tree you want to make (due to some probably unnecessary
+// assumptions that CreateMaker makes).
+//
+// The engine decides which steps need to run based on the content hashes of
+// their inputs and outputs, which it records in the build data directory.
+func CreateMaker(execOpt ToolchainExecOpt, limitOpt ExecLimitOpt, goals []string) (*dag.Engine, *makex.Makefile, error) {
 	currentRepo, err := OpenRepo(".")
 	if err != nil {
 		return nil, nil, err
@@ -89,9 +422,9 @@ func CreateMaker(execOpt ToolchainExecOpt, goals []string) (*makex.Maker, *makex
 
 	treeConfig, err := config.ReadCached(buildStore, currentRepo.CommitID)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, withKind(ConfigError, err)
 	}
-	if len(treeConfig.SourceUnits) == 0 {
+	if len(treeConfig.SourceUnits) == 0 && !quiet() {
 		log.Println("No source unit files found. Did you mean to run `src config`? (This is not an error; it just means that src didn't find anything to build or analyze here.)")
 	}
 
@@ -121,8 +454,24 @@ func CreateMaker(execOpt ToolchainExecOpt, goals []string) (*makex.Maker, *makex
 		}
 	}
 
-	mkConf := &makex.Default
-	mk := mkConf.NewMaker(mf, goals...)
+	mk := &dag.Engine{
+		Rules:     mf.Rules,
+		Goals:     goals,
+		StateFile: filepath.Join(buildDataDir, buildStateFilename),
+		Log:       log.New(os.Stderr, "", log.Flags()),
+		Verbose:   verbose(),
+	}
+	if quiet() {
+		// Don't show progress.
+		mk.Log = nil
+	}
+	if err := limitOpt.apply(mk); err != nil {
+		return nil, nil, err
+	}
 
 	return mk, mf, nil
 }
+
+// buildStateFilename is the name of the file (in the build data directory for
+// a commit) that records the fingerprints of the build steps that have run.
+const buildStateFilename = ".build-state.json"
@@ -0,0 +1,69 @@
+package src
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/dag"
+	"sourcegraph.com/sourcegraph/srclib/events"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+)
+
+// phase emits a PhaseStarted event for the named phase of the build and
+// returns a func that emits the corresponding PhaseFinished event (with the
+// error the phase failed with, if any).
+func (c *MakeCmd) phase(name string) func(err error) {
+	start := time.Now()
+	c.events.Emit(&events.Event{Type: events.PhaseStarted, Phase: name})
+	return func(err error) {
+		e := &events.Event{Type: events.PhaseFinished, Phase: name, Duration: time.Since(start)}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		c.events.Emit(e)
+	}
+}
+
+// emitStepEvents sets mk's OnStep func to emit an event to w whenever a
+// build step starts, finishes, fails, or is skipped because its output is
+// up to date.
+func emitStepEvents(w *events.Writer, mk *dag.Engine) {
+	var mu sync.Mutex
+	started := map[string]time.Time{}
+
+	mk.OnStep = func(rule makex.Rule, status dag.StepStatus, err error) {
+		e := &events.Event{Step: rule.Target()}
+		if r, ok := rule.(plan.SourceUnitRule); ok {
+			u := r.SourceUnit()
+			e.UnitName, e.UnitType = u.Name, u.Type
+		}
+
+		mu.Lock()
+		if status == dag.StepStarted {
+			started[e.Step] = time.Now()
+		} else if t, ok := started[e.Step]; ok {
+			e.Duration = time.Since(t)
+			delete(started, e.Step)
+		}
+		mu.Unlock()
+
+		switch status {
+		case dag.StepStarted:
+			e.Type = events.UnitStarted
+		case dag.StepSucceeded:
+			e.Type = events.UnitFinished
+			w.Emit(e)
+			w.Emit(&events.Event{Type: events.ArtifactWritten, UnitName: e.UnitName, UnitType: e.UnitType, Step: e.Step, Path: e.Step})
+			return
+		case dag.StepFailed:
+			e.Type = events.UnitFailed
+			e.Error = err.Error()
+		case dag.StepUpToDate:
+			e.Type = events.CacheHit
+			e.Path = e.Step
+		}
+		w.Emit(e)
+	}
+}
@@ -0,0 +1,175 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildmanifest"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/report"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// saveBuildManifest writes the manifest (see buildmanifest.Manifest) of the
+// build of the current commit that ran the rules in mf (whose targets are
+// in buildDataDir), started at start, ran the tools in runs, and finished
+// with runErr.
+func saveBuildManifest(mf *makex.Makefile, buildDataDir string, runs []*report.ToolRun, start time.Time, runErr error) error {
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	m := &buildmanifest.Manifest{
+		Repo:          string(currentRepo.URI()),
+		CommitID:      currentRepo.CommitID,
+		SrclibVersion: Version,
+		Start:         start,
+		Duration:      time.Since(start),
+		Runs:          runs,
+	}
+	if runErr != nil {
+		m.Error = runErr.Error()
+	}
+
+	// List the toolchains that the plan uses, even if their steps were up
+	// to date, since they determined the build data.
+	toolchains := map[string]bool{}
+	for _, r := range runs {
+		toolchains[r.Toolchain] = true
+	}
+
+	units := map[[2]string]*buildmanifest.Unit{}
+	unitFiles := map[*buildmanifest.Unit][]string{}
+	for _, rule := range mf.Rules {
+		if r, ok := rule.(plan.ToolRule); ok {
+			toolchains[r.ToolRef().Toolchain] = true
+		}
+		r, ok := rule.(plan.SourceUnitRule)
+		if !ok {
+			continue
+		}
+		u := r.SourceUnit()
+		mu, present := units[[2]string{u.Type, u.Name}]
+		if !present {
+			mu = &buildmanifest.Unit{Name: u.Name, Type: u.Type}
+			units[[2]string{u.Type, u.Name}] = mu
+			m.Units = append(m.Units, mu)
+			unitFiles[mu] = append(unitFiles[mu], plan.SourceUnitDataFilename(unit.SourceUnit{}, u))
+		}
+		rel, err := filepath.Rel(buildDataDir, rule.Target())
+		if err != nil {
+			return err
+		}
+		unitFiles[mu] = append(unitFiles[mu], rel)
+	}
+	for _, r := range runs {
+		if mu, present := units[[2]string{r.UnitType, r.UnitName}]; present {
+			mu.Wall += r.Wall
+			mu.Failed = mu.Failed || r.Error != ""
+		}
+	}
+	for mu, files := range unitFiles {
+		for _, path := range files {
+			f, err := buildmanifest.StatFile(buildStore, m.CommitID, path)
+			if os.IsNotExist(err) {
+				continue // its step failed
+			} else if err != nil {
+				return err
+			}
+			mu.Files = append(mu.Files, f)
+		}
+	}
+	if err := m.AddOtherFiles(buildStore); err != nil {
+		return err
+	}
+
+	for path := range toolchains {
+		t := &buildmanifest.Toolchain{Path: path}
+		if info, err := toolchain.Lookup(path); err == nil {
+			t.Version = info.Version()
+		}
+		m.Toolchains = append(m.Toolchains, t)
+	}
+
+	return buildmanifest.Write(buildStore, m)
+}
+
+// buildLeaseDuration is how long the lease that a build holds on its build
+// data lasts (see buildstore.BuildLease). The build renews it every third
+// of that, so that the lease of a build that is interrupted soon expires.
+const buildLeaseDuration = 10 * time.Minute
+
+// holdBuildLease acquires the lease on the build data of the current
+// commit, so that garbage collection (see src gc) leaves it alone, and
+// renews it until the returned func is called, which releases it.
+func holdBuildLease() (release func(), err error) {
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return nil, err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	if err := buildStore.AcquireBuildLease(currentRepo.CommitID, holder, buildLeaseDuration); err != nil {
+		return nil, err
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(buildLeaseDuration / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := buildStore.AcquireBuildLease(currentRepo.CommitID, holder, buildLeaseDuration); err != nil {
+					log.Printf("Warning: failed to renew the lease on the build data: %s.", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if err := buildStore.ReleaseBuildLease(currentRepo.CommitID, holder); err != nil {
+			log.Printf("Warning: failed to release the lease on the build data: %s.", err)
+		}
+	}, nil
+}
+
+// recordBranchBuild records the build of the current commit in the branch
+// index of the build data (see buildstore.BranchBuild), if a branch is
+// checked out, so that the latest build on the branch can be found after
+// its history is rewritten (e.g., by a rebase).
+func recordBranchBuild() error {
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	branch, err := currentBranch(currentRepo.VCSType, currentRepo.RootDir)
+	if err != nil || branch == "" {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+	return buildStore.RecordBranchBuild(branch, currentRepo.CommitID, time.Now())
+}
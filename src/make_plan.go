@@ -0,0 +1,121 @@
+package src
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/dag"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+)
+
+// A PlanStep describes a rule in the build plan (as printed by
+// `src make --plan`).
+type PlanStep struct {
+	// Target is the file that the step produces.
+	Target string
+
+	// DataType is the build data type of Target (e.g., "graph").
+	DataType string `json:",omitempty"`
+
+	// UnitName and UnitType identify the source unit that the step is for,
+	// if any.
+	UnitName string `json:",omitempty"`
+	UnitType string `json:",omitempty"`
+
+	// Toolchain and Tool identify the toolchain tool that the step runs, if
+	// any.
+	Toolchain string `json:",omitempty"`
+	Tool      string `json:",omitempty"`
+
+	Prereqs []string
+
+	// Commands are the step's recipes (with $@, $^, etc., expanded).
+	Commands []string
+
+	// Cached is whether Target is up to date (i.e., the step's commands,
+	// inputs, and output haven't changed since it last ran), in which case
+	// the step would not run and the existing Target would be reused.
+	Cached bool
+}
+
+// makePlan describes the steps that mk would take to build its goals (in
+// build order), without running any of them. Rules in mf that aren't needed
+// to build the goals are omitted.
+func makePlan(mk *dag.Engine, mf *makex.Makefile) ([]*PlanStep, error) {
+	needed, err := mk.Needed()
+	if err != nil {
+		return nil, err
+	}
+	staleTargets, err := mk.Stale()
+	if err != nil {
+		return nil, err
+	}
+	stale := make(map[string]bool, len(staleTargets))
+	for _, target := range staleTargets {
+		stale[target] = true
+	}
+	rules := make(map[string]makex.Rule, len(mf.Rules))
+	for _, rule := range mf.Rules {
+		rules[rule.Target()] = rule
+	}
+
+	steps := make([]*PlanStep, 0, len(needed))
+	for _, target := range needed {
+		rule := rules[target]
+		s := &PlanStep{
+			Target:  target,
+			Prereqs: rule.Prereqs(),
+			Cached:  !stale[target],
+		}
+		s.DataType, _ = buildstore.DataType(target)
+		if r, ok := rule.(plan.SourceUnitRule); ok {
+			u := r.SourceUnit()
+			s.UnitName, s.UnitType = u.Name, u.Type
+		}
+		if r, ok := rule.(plan.ToolRule); ok {
+			t := r.ToolRef()
+			s.Toolchain, s.Tool = t.Toolchain, t.Subcmd
+		}
+		for _, recipe := range rule.Recipes() {
+			s.Commands = append(s.Commands, makex.ExpandAutoVars(rule, recipe))
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+func printPlan(w io.Writer, steps []*PlanStep) {
+	var numRun int
+	fmtStr := "%-6s  %-12s  %-40s  %s\n"
+	fmt.Fprintf(w, fmtStr, "STATUS", "DATA", "UNIT", "TOOL")
+	for _, s := range steps {
+		status := "run"
+		if s.Cached {
+			status = "cached"
+		} else {
+			numRun++
+		}
+		unit := s.UnitName
+		if s.UnitType != "" {
+			unit += " (" + s.UnitType + ")"
+		}
+		tool := "(internal)"
+		if s.Tool != "" {
+			tool = s.Toolchain + " " + s.Tool
+		}
+		fmt.Fprintf(w, fmtStr, status, s.DataType, unit, tool)
+		if verbose() {
+			fmt.Fprintf(w, "        target: %s\n", s.Target)
+			for _, cmd := range s.Commands {
+				fmt.Fprintf(w, "        $ %s\n", cmd)
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%d steps would run; %d cached targets would be reused.\n", numRun, len(steps)-numRun)
+	if !verbose() && numRun > 0 {
+		fmt.Fprintln(w, "Run with -v to show the commands for each step.")
+	}
+}
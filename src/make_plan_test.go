@@ -0,0 +1,68 @@
+package src
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/makex"
+
+	"sourcegraph.com/sourcegraph/srclib/dag"
+)
+
+func TestMakePlan(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "srclib-make-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := func(name string) string { return filepath.Join(tmpDir, name) }
+	for _, name := range []string{"src1", "src2"} {
+		if err := ioutil.WriteFile(path(name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mf := &makex.Makefile{Rules: []makex.Rule{
+		&makex.BasicRule{TargetFile: "all", PrereqFiles: []string{path("b"), path("c")}},
+		&makex.BasicRule{TargetFile: path("a"), PrereqFiles: []string{path("src1")}, RecipeCmds: []string{"cp " + path("src1") + " " + path("a")}},
+		&makex.BasicRule{TargetFile: path("b"), PrereqFiles: []string{path("a")}, RecipeCmds: []string{"cp " + path("a") + " " + path("b")}},
+		&makex.BasicRule{TargetFile: path("c"), PrereqFiles: []string{path("src2")}, RecipeCmds: []string{"cp " + path("src2") + " " + path("c")}},
+	}}
+	mk := &dag.Engine{Rules: mf.Rules, Goals: []string{"all"}, StateFile: path("state.json")}
+	if err := mk.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path("src1"), []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	type step struct {
+		Target string
+		Cached bool
+	}
+	tests := []struct {
+		goals []string
+		want  []step
+	}{
+		{[]string{"all"}, []step{{path("a"), false}, {path("b"), false}, {path("c"), true}}},
+		{[]string{path("b")}, []step{{path("a"), false}, {path("b"), false}}},
+		{[]string{path("c")}, []step{{path("c"), true}}},
+	}
+	for _, test := range tests {
+		mk := &dag.Engine{Rules: mf.Rules, Goals: test.goals, StateFile: path("state.json")}
+		steps, err := makePlan(mk, mf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []step
+		for _, s := range steps {
+			got = append(got, step{s.Target, s.Cached})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("goals %v: got steps %+v, want %+v", test.goals, got, test.want)
+		}
+	}
+}
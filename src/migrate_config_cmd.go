@@ -0,0 +1,80 @@
+package src
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func init() {
+	_, err := CLI.AddCommand("migrate-config",
+		"rewrite a Srcfile to the current config schema",
+		`Rewrites the parts of the Srcfile in the current directory that use old forms of the config schema (such as scanners given as "TOOLCHAIN:TOOL" strings) to the current form. The rest of the Srcfile, including its comments and formatting, is kept.
+
+Old forms are still read (by "src config" and other commands), but they may stop being supported in a future version.
+
+With --check, doesn't rewrite the Srcfile, but exits with a non-zero status if it needs to be migrated (e.g., to check many repositories in CI).`,
+		&migrateConfigCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type MigrateConfigCmd struct {
+	Check  bool `long:"check" description:"exit with a non-zero status if the Srcfile needs to be migrated, without rewriting it"`
+	DryRun bool `short:"n" long:"dry-run" description:"print the migrated Srcfile instead of writing it"`
+
+	Dir Directory `short:"C" long:"directory" description:"change to DIR before doing anything" value-name:"DIR"`
+}
+
+var migrateConfigCmd MigrateConfigCmd
+
+func (c *MigrateConfigCmd) Execute(args []string) error {
+	if c.Dir != "" {
+		if err := os.Chdir(string(c.Dir)); err != nil {
+			return err
+		}
+	}
+
+	data, err := ioutil.ReadFile(config.Filename)
+	if err != nil {
+		return withKind(ConfigError, err)
+	}
+	migrated, applied, err := config.Migrate(data)
+	if err != nil {
+		return withKind(ConfigError, fmt.Errorf("%s: %s", config.Filename, err))
+	}
+	if len(applied) == 0 {
+		if !quiet() {
+			log.Printf("%s already uses the current config schema.", config.Filename)
+		}
+		return nil
+	}
+	for _, desc := range applied {
+		log.Printf("Migrating %s.", desc)
+	}
+
+	switch {
+	case c.Check:
+		return withKind(ConfigError, fmt.Errorf("%s needs to be migrated (run `src migrate-config`)", config.Filename))
+	case c.DryRun:
+		_, err := os.Stdout.Write(migrated)
+		return err
+	}
+
+	// Check that the migrated Srcfile is valid before replacing the old
+	// one.
+	if _, err := config.ReadRepositoryFS(vfs.Map(map[string]string{config.Filename: string(migrated)}), ""); err != nil {
+		return fmt.Errorf("migrated %s is invalid: %s", config.Filename, err)
+	}
+	fi, err := os.Stat(config.Filename)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(config.Filename, migrated, fi.Mode())
+}
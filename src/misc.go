@@ -4,8 +4,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/sqs/go-flags"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
 )
 
 // Directory is flags.Completer that provides directory name completion.
@@ -31,3 +33,38 @@ func (d Directory) Complete(match string) []flags.Completion {
 	}
 	return dirs
 }
+
+// UnitName is a flags.Completer that provides completion of the names of
+// the source units in the current repository's scan data (stored by "src
+// config").
+type UnitName string
+
+// Complete implements flags.Completer and returns the names of the source
+// units that have the given prefix.
+func (u UnitName) Complete(match string) []flags.Completion {
+	// If the current directory isn't in a repository, or the repository
+	// hasn't been configured yet, there are no source units to complete.
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return nil
+	}
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return nil
+	}
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var comps []flags.Completion
+	for _, u := range units {
+		if strings.HasPrefix(u.Name, match) && !seen[u.Name] {
+			seen[u.Name] = true
+			comps = append(comps, flags.Completion{Item: u.Name, Description: u.Type})
+		}
+	}
+	return comps
+}
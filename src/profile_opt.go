@@ -0,0 +1,107 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// ToolchainProfileDirEnv is the environment variable that tells toolchains
+// that support profiling where to write their profiles. It is set (and
+// inherited by all toolchain subprocesses) when --profile-toolchains is used.
+const ToolchainProfileDirEnv = "SRCLIB_PROFILE_DIR"
+
+type ProfileOpt struct {
+	CPUProfile        string `long:"cpuprofile" description:"write CPU profile to FILE" value-name:"FILE"`
+	MemProfile        string `long:"memprofile" description:"write heap profile to FILE before exiting" value-name:"FILE"`
+	Trace             string `long:"trace" description:"write execution trace to FILE" value-name:"FILE"`
+	ProfileToolchains string `long:"profile-toolchains" description:"ask toolchains that support it to write profiles to DIR" value-name:"DIR"`
+}
+
+// Start begins any profiling requested by the options. The returned function
+// stops profiling and writes the profiles; it must be called before the
+// command exits (even if the command fails).
+func (o *ProfileOpt) Start() (func() error, error) {
+	var stops []func() error
+	stop := func() error {
+		var firstErr error
+		for i := len(stops) - 1; i >= 0; i-- {
+			if err := stops[i](); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			stop()
+		}
+	}()
+
+	if o.CPUProfile != "" {
+		f, err := os.Create(o.CPUProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting CPU profile: %s", err)
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+
+	if o.Trace != "" {
+		f, err := os.Create(o.Trace)
+		if err != nil {
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting trace: %s", err)
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+
+	if o.MemProfile != "" {
+		memProfile := o.MemProfile
+		stops = append(stops, func() error {
+			f, err := os.Create(memProfile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			runtime.GC() // get up-to-date statistics
+			return pprof.WriteHeapProfile(f)
+		})
+	}
+
+	if o.ProfileToolchains != "" {
+		dir, err := filepath.Abs(o.ProfileToolchains)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		if err := os.Setenv(ToolchainProfileDirEnv, dir); err != nil {
+			return nil, err
+		}
+		if verbose() {
+			log.Printf("Toolchains that support profiling will write profiles to %s.", dir)
+		}
+	}
+
+	ok = true
+	return stop, nil
+}
@@ -0,0 +1,48 @@
+package src
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileOpt_Start_error(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "srclib-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	missing := filepath.Join(tmpDir, "missing", "f")
+
+	tests := map[string]ProfileOpt{
+		"cpuprofile": {CPUProfile: missing},
+		"trace":      {Trace: missing},
+		"trace after cpuprofile": {
+			CPUProfile: filepath.Join(tmpDir, "cpu"),
+			Trace:      missing,
+		},
+	}
+	for label, opt := range tests {
+		stop, err := opt.Start()
+		if err == nil {
+			stop()
+			t.Errorf("%s: got no error starting profiling with an unwritable path", label)
+			continue
+		}
+		if stop != nil {
+			t.Errorf("%s: got a stop func with error %v", label, err)
+		}
+	}
+
+	// The CPU profile that was started before the error must have been
+	// stopped.
+	opt := ProfileOpt{CPUProfile: filepath.Join(tmpDir, "cpu")}
+	stop, err := opt.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/query"
+)
+
+// QueryOpt are the options of commands that list defs or refs as JSON,
+// which select a page of the list and the fields of its items (see package
+// query).
+type QueryOpt struct {
+	First  int    `long:"first" description:"maximum number of items to list (default: all)" value-name:"N"`
+	After  string `long:"after" description:"list the items after this cursor (which is printed after a page that isn't the last)" value-name:"CURSOR"`
+	Fields string `long:"fields" description:"comma-separated names of the fields of each item to list (e.g., File,Start,End)" value-name:"FIELDS"`
+}
+
+// options returns the query options selected by o (and filtering by the
+// given kinds and file), for a list whose items are like item.
+func (o *QueryOpt) options(item interface{}, kinds []string, file string) (*query.Options, error) {
+	if o.First < 0 {
+		return nil, withKind(UsageError, fmt.Errorf("invalid --first %d (must be non-negative)", o.First))
+	}
+	opt := &query.Options{First: o.First, After: o.After, Kinds: kinds, File: file}
+	if opt.First == 0 {
+		opt.First = query.NoLimit
+	}
+	if o.After != "" {
+		if _, err := query.DecodeCursor(o.After); err != nil {
+			return nil, withKind(UsageError, err)
+		}
+	}
+	fields, err := query.ParseFields(o.Fields, item)
+	if err != nil {
+		return nil, withKind(UsageError, err)
+	}
+	opt.Fields = fields
+	return opt, nil
+}
+
+// printQueryPage prints the page selected by opt of a list of n items
+// (where match(i) reports whether the i'th item satisfies opt's filters,
+// and item(i) returns it) as a JSON array. If there are more items, the
+// cursor of the next page is logged.
+func printQueryPage(opt *query.Options, n int, match func(i int) bool, item func(i int) interface{}) error {
+	page, err := opt.Page(n, match)
+	if err != nil {
+		return err
+	}
+	items := make([]interface{}, len(page.Indexes))
+	for i, j := range page.Indexes {
+		if items[i], err = query.Select(item(j), opt.Fields); err != nil {
+			return err
+		}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(items); err != nil {
+		return err
+	}
+	if next := page.Next(); next != "" {
+		log.Printf("Listed %d of %d items. To list the next page, use --after %s.", len(items), page.Total, next)
+	}
+	return nil
+}
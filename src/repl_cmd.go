@@ -0,0 +1,74 @@
+package src
+
+import (
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/repl"
+	"sourcegraph.com/sourcegraph/srclib/search"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("repl",
+		"interactively query build data",
+		`Loads the current repository's build data (built by "src make") once, and then reads and executes query commands interactively: "units" lists the source units, "search QUERY" searches for defs by name, and "def", "refs", and "docs" show a def, the refs to it, and its docs. Defs are identified by def key (REPO[@COMMIT]:UNITTYPE:UNIT:PATH, where REPO may be omitted) or by their number in the last search's results. Type "help" for a list of commands.
+
+A command's output may be piped to a shell command by appending "| SHELLCMD" (e.g., "refs 1 | grep _test.go"). "format json" switches the output to JSON.
+
+For line editing and history, run it under a readline wrapper, as in "rlwrap src repl".`,
+		&replCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ReplCmd struct {
+	Limit  int    `short:"n" long:"limit" default:"20" description:"maximum number of search results (0 for no limit)" value-name:"N"`
+	JSON   bool   `long:"json" description:"print command output as JSON (same as the \"format json\" command)"`
+	Prompt string `long:"prompt" default:"src> " description:"prompt to print before reading each command" value-name:"PROMPT"`
+}
+
+var replCmd ReplCmd
+
+func (c *ReplCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	site, err := readBrowseSite(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+	var indexes []*search.Index
+	for _, u := range units {
+		var ix *search.Index
+		if found, err := readSearchIndex(buildStore, repo, u, &search.Index{}, &ix); err != nil {
+			return err
+		} else if found {
+			indexes = append(indexes, ix)
+		}
+	}
+	if len(indexes) == 0 {
+		// Index the graph data in memory (e.g., if the build data was
+		// built before search indexes were).
+		for _, u := range site.Units() {
+			indexes = append(indexes, search.NewIndex(&unit.SourceUnit{Type: u.Type, Name: u.Name}, u.Defs))
+		}
+	}
+
+	s := &repl.Session{Site: site, Indexes: indexes, Limit: c.Limit, JSON: c.JSON}
+	return s.Run(os.Stdin, os.Stdout, os.Stderr, c.Prompt)
+}
@@ -41,7 +41,7 @@ The options are:
 			log.Fatalf("Error creating repository with %q: %s", urlStr, err)
 		}
 		log.Printf("%-45s Repository #%d", repo.URI, repo.RID)
-		if GlobalOpt.Verbose {
+		if verbose() {
 			PrintJSON(repo, "")
 			log.Println()
 		}
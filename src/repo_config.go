@@ -2,10 +2,12 @@ package src
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib/repo"
@@ -25,6 +27,10 @@ func OpenRepo(dir string) (*Repo, error) {
 		return nil, fmt.Errorf("not a directory: %q", dir)
 	}
 
+	if rc, err := archiveRepo(dir); rc != nil || err != nil {
+		return rc, err
+	}
+
 	// VCS and root directory
 	rc := new(Repo)
 	for _, vcsType := range []string{"git", "hg"} {
@@ -55,13 +61,52 @@ func OpenRepo(dir string) (*Repo, error) {
 	return rc, nil
 }
 
+// archiveRepoEnv is the environment variable that `src make --archive` sets
+// (to the JSON representation of a Repo) for the processes of its build, to
+// describe the repository of the tree that it exported from the archive
+// (which has no VCS metadata).
+const archiveRepoEnv = "SRCLIB_ARCHIVE_REPO"
+
+// archiveRepo returns the repository described by archiveRepoEnv, if dir is
+// in its tree, or else nil.
+func archiveRepo(dir string) (*Repo, error) {
+	v := os.Getenv(archiveRepoEnv)
+	if v == "" {
+		return nil, nil
+	}
+	var rc *Repo
+	if err := json.Unmarshal([]byte(v), &rc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", archiveRepoEnv, err)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(rc.RootDir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, nil
+	}
+	return rc, nil
+}
+
 func resolveWorkingTreeRevision(vcsType string, dir string) (string, error) {
+	switch vcsType {
+	case "git":
+		return resolveRevision(vcsType, dir, "HEAD")
+	case "hg":
+		return resolveRevision(vcsType, dir, "tip")
+	}
+	return "", fmt.Errorf("unknown vcs type: %q", vcsType)
+}
+
+// resolveRevision returns the commit ID of rev (a branch, tag, abbreviated
+// commit ID, etc.) in the repository at dir.
+func resolveRevision(vcsType string, dir string, rev string) (string, error) {
 	var cmd *exec.Cmd
 	switch vcsType {
 	case "git":
-		cmd = exec.Command("git", "rev-parse", "HEAD")
+		cmd = exec.Command("git", "rev-parse", "--verify", rev+"^{commit}")
 	case "hg":
-		cmd = exec.Command("hg", "--config", "trusted.users=root", "identify", "--debug", "-i", "--rev=tip")
+		cmd = exec.Command("hg", "--config", "trusted.users=root", "identify", "--debug", "-i", "--rev="+rev)
 	default:
 		return "", fmt.Errorf("unknown vcs type: %q", vcsType)
 	}
@@ -74,6 +119,54 @@ func resolveWorkingTreeRevision(vcsType string, dir string) (string, error) {
 	return string(bytes.TrimSpace(out)), nil
 }
 
+// currentBranch returns the name of the branch that is checked out in the
+// repository at dir, or "" if there is none (e.g., because git's HEAD is
+// detached, or the tree was exported from an archive).
+func currentBranch(vcsType string, dir string) (string, error) {
+	var cmd *exec.Cmd
+	switch vcsType {
+	case "git":
+		cmd = exec.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	case "hg":
+		cmd = exec.Command("hg", "--config", "trusted.users=root", "branch")
+	default:
+		return "", fmt.Errorf("unknown vcs type: %q", vcsType)
+	}
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// taggedCommits returns the set of commits that tags point to in the
+// repository at dir.
+func taggedCommits(vcsType string, dir string) (map[string]bool, error) {
+	var cmd *exec.Cmd
+	switch vcsType {
+	case "git":
+		cmd = exec.Command("git", "rev-list", "--no-walk", "--tags")
+	case "hg":
+		cmd = exec.Command("hg", "--config", "trusted.users=root", "log", "--template", "{node}\n", "--rev", "tag() - tip")
+	default:
+		return nil, fmt.Errorf("unknown vcs type: %q", vcsType)
+	}
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec %v failed: %s", cmd.Args, err)
+	}
+	commits := map[string]bool{}
+	for _, c := range strings.Fields(string(out)) {
+		commits[c] = true
+	}
+	return commits, nil
+}
+
 func getRootDir(vcsType string, dir string) (string, error) {
 	var cmd *exec.Cmd
 	switch vcsType {
@@ -38,7 +38,7 @@ The options are:
 
 	var allRawDeps []*dep.RawDependency
 	for name, input := range inputs {
-		if GlobalOpt.Verbose {
+		if verbose() {
 			log.Printf("Reading raw deps from %q", name)
 		}
 		var rawDeps []*dep.RawDependency
@@ -0,0 +1,139 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/present"
+	"sourcegraph.com/sourcegraph/srclib/search"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("search",
+		"search for defs by name",
+		`Searches the names and paths of the defs in the current repository for QUERY and lists the best matches (which need not match exactly).
+
+With --docs, searches the docs and identifiers of the defs for the words in QUERY (e.g., "read a line from a file") instead. This uses a full-text index, which "src make" only builds for source units whose config sets FullTextSearch to true (e.g., by adding "Config": {"FullTextSearch": true} to the Srcfile).
+
+Search uses the indexes built by "src make", so the repository must have been built first.`,
+		&searchCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type SearchCmd struct {
+	Limit int  `short:"n" long:"limit" default:"20" description:"maximum number of results (0 for no limit)" value-name:"N"`
+	Docs  bool `long:"docs" description:"search the full text of the defs' docs (requires the FullTextSearch config)"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+
+	Args struct {
+		Query string `name:"QUERY" description:"name (or part of a name or path) of the defs to search for"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var searchCmd SearchCmd
+
+func (c *SearchCmd) Execute(args []string) error {
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	var results []*search.Result
+	if c.Docs {
+		var indexes []*search.DocIndex
+		for _, u := range units {
+			var ix *search.DocIndex
+			if found, err := readSearchIndex(buildStore, repo, u, &search.DocIndex{}, &ix); err != nil {
+				return err
+			} else if found {
+				indexes = append(indexes, ix)
+			}
+		}
+		if len(indexes) == 0 {
+			return fmt.Errorf("no full-text search indexes found for commit %s (set \"FullTextSearch\": true in the Srcfile's Config and run `src config` and `src make`)", repo.CommitID)
+		}
+		results = search.SearchDocs(indexes, c.Args.Query, c.Limit)
+	} else {
+		var indexes []*search.Index
+		for _, u := range units {
+			var ix *search.Index
+			if found, err := readSearchIndex(buildStore, repo, u, &search.Index{}, &ix); err != nil {
+				return err
+			} else if found {
+				indexes = append(indexes, ix)
+			}
+		}
+		if len(indexes) == 0 {
+			return fmt.Errorf("no search indexes found for commit %s (run `src make` first)", repo.CommitID)
+		}
+		results = search.Search(indexes, c.Args.Query, c.Limit)
+	}
+
+	if c.Output.Output == "json" {
+		if results == nil {
+			results = []*search.Result{}
+		}
+		PrintJSON(results, "")
+	} else {
+		printSearchResults(newPrinter(os.Stdout), results)
+	}
+	return nil
+}
+
+// readSearchIndex reads source unit u's search index of the given type (an
+// empty *search.Index or *search.DocIndex) into v. If the index doesn't
+// exist, it returns false.
+func readSearchIndex(buildStore *buildstore.RepositoryStore, repo *Repo, u *unit.SourceUnit, emptyIndex, v interface{}) (bool, error) {
+	indexFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename(emptyIndex, u))
+	f, err := buildStore.Open(indexFile)
+	if os.IsNotExist(err) {
+		if verbose() {
+			log.Printf("No search index %s for source unit %q type %q.", indexFile, u.Name, u.Type)
+		}
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return false, fmt.Errorf("%s: %s", indexFile, err)
+	}
+	return true, nil
+}
+
+func printSearchResults(p *present.Printer, results []*search.Result) {
+	t := &present.Table{Colors: []present.Color{present.Yellow, present.Bold, present.Plain, present.Dim, present.Cyan, present.Dim}}
+	for _, r := range results {
+		loc := r.File
+		if loc != "" {
+			loc = fmt.Sprintf("%s:%d-%d", r.File, r.DefStart, r.DefEnd)
+		}
+		name := r.Name
+		if r.QualifiedName != "" {
+			name = r.QualifiedName
+		}
+		t.Rows = append(t.Rows, []string{string(r.Kind), name, string(r.Path), r.Unit, loc, r.Doc})
+	}
+	p.Table(t)
+}
@@ -0,0 +1,183 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/pgimport"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	c, err := CLI.AddCommand("store",
+		"store build data in a database",
+		"Store the current repository's build data (built by \"src make\") in a database, so that it can be queried with tools other than src.",
+		&storeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("import-pg",
+		"import build data into PostgreSQL",
+		`Imports the defs, refs, and docs of the current repository's build data and its resolved dependencies into the defs, refs, docs, and deps tables of a PostgreSQL database, so that they can be queried with SQL. The tables and their indexes are created if they don't exist (see docs/sources/api/postgres.md for the schema).
+
+The rows previously imported for the repository's commit are replaced, so importing a commit again updates it. The import runs in a single transaction, and loads the rows with COPY statements of at most --batch-size rows each.
+
+The import is run by psql, which must be installed. The database is given by --db (a connection string or postgres:// URL) or, if --db is empty, by the PG* environment variables that psql reads (such as PGHOST and PGDATABASE). With --script, the psql script is written to stdout instead, so it can be run later by "psql --file=FILE".`,
+		&storeImportPGCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type StoreCmd struct{}
+
+var storeCmd StoreCmd
+
+func (c *StoreCmd) Execute(args []string) error { return nil }
+
+type StoreImportPGCmd struct {
+	DB        string `long:"db" description:"PostgreSQL connection string or URL (defaults to the PG* environment variables)" value-name:"URL"`
+	BatchSize int    `long:"batch-size" description:"maximum number of rows loaded by each COPY statement" default:"10000" value-name:"N"`
+	Script    bool   `long:"script" description:"write the psql script to stdout instead of running psql"`
+}
+
+var storeImportPGCmd StoreImportPGCmd
+
+func (c *StoreImportPGCmd) Execute(args []string) error {
+	if c.BatchSize < 1 {
+		return withKind(UsageError, fmt.Errorf("invalid --batch-size %d (must be at least 1)", c.BatchSize))
+	}
+
+	repo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return err
+	}
+
+	units, err := getSourceUnits(buildStore, repo)
+	if err != nil {
+		return err
+	}
+
+	if c.Script {
+		_, err := importPG(os.Stdout, buildStore, repo, units, c.BatchSize)
+		return err
+	}
+
+	// The errors below don't include cmd.Args, because the database URL
+	// may contain a password.
+	psqlArgs := []string{"--no-psqlrc", "--quiet", "--file=-"}
+	if c.DB != "" {
+		psqlArgs = append(psqlArgs, "--dbname="+c.DB)
+	}
+	var out bytes.Buffer
+	cmd := exec.Command("psql", psqlArgs...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("exec psql failed: %s", err)
+	}
+	// If the import fails, closing stdin before the script's COMMIT makes
+	// psql exit, which rolls back the transaction.
+	rows, importErr := importPG(stdin, buildStore, repo, units, c.BatchSize)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		// psql's error explains why writing the script to it failed.
+		return fmt.Errorf("exec psql failed: %s. Output was:\n\n%s", err, out.Bytes())
+	}
+	if importErr != nil {
+		return importErr
+	}
+
+	if !quiet() {
+		log.Printf("Imported %d defs, %d refs, %d docs, and %d deps of commit %s.", rows["defs"], rows["refs"], rows["docs"], rows["deps"], repo.CommitID)
+	}
+	return nil
+}
+
+// importPG writes to w a psql script that imports the graph data and
+// resolved dependencies of the repo's source units (see package pgimport),
+// and returns the number of rows imported into each table.
+func importPG(w io.Writer, buildStore *buildstore.RepositoryStore, repo *Repo, units []*unit.SourceUnit, batchSize int) (map[string]int, error) {
+	im, err := pgimport.NewImporter(w, repo.URI(), repo.CommitID, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var found bool
+	for _, u := range units {
+		graphFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename("graph", u))
+		f, err := buildStore.Open(graphFile)
+		if os.IsNotExist(err) {
+			if verbose() {
+				log.Printf("No graph data for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		err = grapher.StreamOutput(f, grapher.OutputHandlers{
+			Def: func(d *graph.Def) error { return im.Def(u, d) },
+			Ref: func(r *graph.Ref) error { return im.Ref(u, r) },
+			Doc: func(d *graph.Doc) error { return im.Doc(u, d) },
+		})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", graphFile, err)
+		}
+		found = true
+
+		depsFile := buildStore.FilePath(repo.CommitID, plan.SourceUnitDataFilename([]*dep.ResolvedDep{}, u))
+		f, err = buildStore.Open(depsFile)
+		if os.IsNotExist(err) {
+			if verbose() {
+				log.Printf("No dependency resolution data for source unit %q type %q.", u.Name, u.Type)
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ress, err := dep.ReadResolutions(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", depsFile, err)
+		}
+		rds, err := dep.ResolutionsToResolvedDeps(ress, u, repo.URI(), repo.CommitID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", depsFile, err)
+		}
+		for _, rd := range rds {
+			if err := im.Dep(rd); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no graph data found for commit %s (run `src make` first)", repo.CommitID)
+	}
+
+	if err := im.Close(); err != nil {
+		return nil, err
+	}
+	return im.Rows(), nil
+}
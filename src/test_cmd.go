@@ -15,7 +15,9 @@ import (
 	"github.com/aybabtme/color/brush"
 	"github.com/kr/fs"
 	"sourcegraph.com/sourcegraph/srclib/authorship"
+	"sourcegraph.com/sourcegraph/srclib/binindex"
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
 	"sourcegraph.com/sourcegraph/srclib/util"
 	"sourcegraph.com/sourcegraph/srclib/vcsutil"
 )
@@ -28,14 +30,18 @@ used (except those whose name begins with "_").
 
 Expected and actual outputs for a tree are stored in TREE/../../{expected,actual}/TREEBASE, respectively, where TREEBASE is the basename of TREE.
 
-After making the tree, "src test" compares the actual test output against the expected test output. Any differences trigger a test failure, and the differinglines are printed.
+After making the tree, "src test" compares the actual test output against the expected test output. Any differences trigger a test failure, and a unified diff of the differing files is printed. JSON files are compared ignoring whitespace and are diffed after being indented, so changes to (single-line) build data files are shown line by line. All trees are tested, even if some fail.
 
-If the --gen flag is used, the expected test output is removed and regenerated. You should regenerate the expected output whenever you make changes to the toolchain that alter the desired output. Be sure to check the new expected output for errors manually; it's easy to accidentally commit new expected output that is incorrect.
+If the --update flag is used, the expected output of each test case whose actual output differs is replaced by the actual output (and the diff is printed, for review). You should update the expected output whenever you make changes to the toolchain that alter the desired output. Be sure to check the new expected output for errors manually; it's easy to accidentally commit new expected output that is incorrect.
+
+The --gen flag removes and regenerates the expected output of all test cases without comparing it, and then exits with a nonzero status.
 
 CONFIGURING TESTS
 
 Use a Srcfile in trees whose tests you want to configure (e.g., by only running a scanner). There is no special configuration for testing beyond what's possible with Srcfile.
 
+A tree may be the root directory of a repository (e.g., a git submodule) or a plain directory. Plain directories are copied into a temporary git repository, with a fixed commit ID and the clone URL https://example.com/testcase/TREEBASE, and built there.
+
 EXAMPLE
 
 For example, suppose you run "src test" in a directory with the following files:
@@ -59,6 +65,7 @@ And the actual test output is written to:
 
 type TestCmd struct {
 	GenerateExpected bool `long:"gen" description:"(re)generate expected output for all test cases and exit"`
+	Update           bool `long:"update" description:"replace the expected output of test cases that fail with their actual output"`
 
 	CheckInternalTargets bool `long:"check-internal-targets" description:"also produce and check internal command outputs (ex: blame, authorship)"`
 
@@ -78,7 +85,7 @@ func (c *TestCmd) Execute(args []string) error {
 	}
 
 	for _, exeMethod := range exeMethods {
-		if GlobalOpt.Verbose {
+		if verbose() {
 			log.Printf("Executing tests using method: %s", exeMethod)
 		}
 
@@ -100,20 +107,28 @@ func (c *TestCmd) Execute(args []string) error {
 			}
 		}
 
-		if GlobalOpt.Verbose {
+		if verbose() {
 			log.Printf("Testing trees: %v", trees)
 		}
 
+		var failed []string
 		for _, tree := range trees {
-			if GlobalOpt.Verbose {
+			if verbose() {
 				log.Printf("Testing tree %v...", tree)
 			}
 			expectedDir := filepath.Join(tree, "../../expected", exeMethod, filepath.Base(tree))
 			actualDir := filepath.Join(tree, "../../actual", exeMethod, filepath.Base(tree))
-			if err := testTree(tree, expectedDir, actualDir, exeMethod, c.GenerateExpected, c.CheckInternalTargets); err != nil {
-				return fmt.Errorf("testing tree %q: %s", tree, err)
+			if err := testTree(tree, expectedDir, actualDir, exeMethod, c.GenerateExpected, c.Update, c.CheckInternalTargets); err != nil {
+				if c.GenerateExpected {
+					return fmt.Errorf("testing tree %q: %s", tree, err)
+				}
+				log.Printf("Testing tree %q failed: %s", tree, err)
+				failed = append(failed, tree)
 			}
 		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%d of %d test cases failed (using method %s): %s", len(failed), len(trees), exeMethod, strings.Join(failed, " "))
+		}
 	}
 
 	if c.GenerateExpected {
@@ -123,7 +138,7 @@ func (c *TestCmd) Execute(args []string) error {
 	return nil
 }
 
-func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generateExpected, checkInternalTargets bool) error {
+func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generateExpected, update, checkInternalTargets bool) error {
 	treeName := filepath.Base(treeDir)
 	if treeName == "." {
 		absTreeDir, err := filepath.Abs(treeDir)
@@ -148,6 +163,17 @@ func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generate
 		return err
 	}
 
+	// src only builds the root directories of repositories, so build test
+	// cases that are plain directories in a temporary repository.
+	if !isRepoRoot(treeDir) {
+		repoDir, err := fixtureRepo(treeDir, treeName)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(repoDir)
+		treeDir = repoDir
+	}
+
 	// Symlink ${treeDir}/.srclib-cache/${commitID} to the desired output dir.
 	//
 	// TODO(sqs): make `src make` not necessarily write to a .srclib-cache/...
@@ -175,7 +201,7 @@ func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generate
 	// Run `src make`.
 	var w io.Writer
 	var buf bytes.Buffer
-	if GlobalOpt.Verbose {
+	if verbose() {
 		w = io.MultiWriter(&buf, os.Stderr)
 	} else {
 		w = &buf
@@ -190,12 +216,26 @@ func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generate
 
 	if !checkInternalTargets {
 		// remove all internal target output files
-		internalOutputs := []interface{}{&authorship.SourceUnitOutput{}, &vcsutil.BlameOutput{}}
+		internalOutputs := []interface{}{&authorship.SourceUnitOutput{}, &vcsutil.BlameOutput{}, &grapher.ShardIndex{}}
+		shardDirSuffix := grapher.ShardDir(buildstore.DataTypeSuffix(&grapher.ShardIndex{}))
 		w := fs.Walk(outputDir)
 		for w.Step() {
 			if w.Err() != nil {
 				return w.Err()
 			}
+			if w.Stat().IsDir() && strings.HasSuffix(w.Path(), shardDirSuffix) {
+				if err := os.RemoveAll(w.Path()); err != nil {
+					return err
+				}
+				w.SkipDir()
+				continue
+			}
+			if filepath.Base(w.Path()) == binindex.Filename {
+				if err := os.Remove(w.Path()); err != nil {
+					return err
+				}
+				continue
+			}
 			for _, o := range internalOutputs {
 				if strings.HasSuffix(w.Path(), buildstore.DataTypeSuffix(o)) {
 					if err := os.Remove(w.Path()); err != nil {
@@ -211,23 +251,94 @@ func testTree(treeDir, expectedDir, actualDir string, exeMethod string, generate
 		log.Printf("Successfully generated expected output for %s in %s.", treeName, expectedDir)
 		return nil
 	}
-	return checkResults(buf, treeDir, actualDir, expectedDir)
+	return checkResults(buf, treeDir, actualDir, expectedDir, update)
 }
 
-func checkResults(output bytes.Buffer, treeDir, actualDir, expectedDir string) error {
-	treeName := filepath.Base(treeDir)
-	out, err := exec.Command("diff", "-ur", expectedDir, actualDir).CombinedOutput()
-	if err != nil || len(out) > 0 {
-		fmt.Println(brush.Red(treeName + " FAIL").String())
-		fmt.Printf("Diff failed for %s: %s.", treeName, err)
-		if len(out) > 0 {
-			fmt.Println(brush.Red(treeName + " FAIL"))
-			fmt.Println(output.String())
-			fmt.Println(string(util.ColorizeDiff(out)))
+// checkResults compares the actual output of a tree to the expected
+// output. If they differ and update is true, the expected output is
+// replaced by the actual output.
+// isRepoRoot returns whether dir is the root directory of a git or hg
+// repository.
+func isRepoRoot(dir string) bool {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, vcsType := range []string{"git", "hg"} {
+		if root, err := getRootDir(vcsType, dir); err == nil {
+			root, err = filepath.EvalSymlinks(root)
+			return err == nil && root == dir
 		}
-		return fmt.Errorf("Output for %s differed from expected.", treeName)
-	} else {
+	}
+	return false
+}
+
+// fixtureRepo copies the test case in treeDir into a new temporary git
+// repository and returns its directory. The repository's commit ID and
+// clone URL (https://example.com/testcase/NAME) only depend on the test
+// case's files and name, so its build output is the same on every run.
+func fixtureRepo(treeDir, name string) (string, error) {
+	dir, err := ioutil.TempDir("", "srclib-test-"+name)
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("cp", "-R", filepath.Join(treeDir, "."), dir).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("copying test case %s: %s\n%s", treeDir, err, out)
+	}
+
+	env := os.Environ()
+	for _, who := range []string{"AUTHOR", "COMMITTER"} {
+		env = append(env, "GIT_"+who+"_NAME=srclib", "GIT_"+who+"_EMAIL=srclib@example.com", "GIT_"+who+"_DATE=2000-01-01T00:00:00Z")
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"commit", "-q", "--allow-empty", "--no-gpg-sign", "--no-verify", "-m", "test case " + name},
+		{"remote", "add", "origin", "https://example.com/testcase/" + name},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir, cmd.Env = dir, env
+		if out, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("creating repository for test case %s: git %s: %s\n%s", treeDir, strings.Join(args, " "), err, out)
+		}
+	}
+	return dir, nil
+}
+
+func checkResults(output bytes.Buffer, treeDir, actualDir, expectedDir string, update bool) error {
+	treeName := filepath.Base(treeDir)
+	diff, err := util.DiffTrees(expectedDir, actualDir)
+	if err != nil {
+		return err
+	}
+	if diff == nil {
 		fmt.Println(brush.Green(treeName + " PASS").String())
+		return nil
 	}
-	return nil
+
+	if update {
+		if err := os.RemoveAll(expectedDir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(expectedDir), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(actualDir, expectedDir); err != nil {
+			return err
+		}
+		fmt.Println(brush.Yellow(treeName + " UPDATED").String())
+		fmt.Println(string(util.ColorizeDiff(diff)))
+		return nil
+	}
+
+	fmt.Println(brush.Red(treeName + " FAIL").String())
+	fmt.Println(output.String())
+	fmt.Println(string(util.ColorizeDiff(diff)))
+	return fmt.Errorf("Output for %s differed from expected.", treeName)
 }
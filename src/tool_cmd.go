@@ -1,13 +1,22 @@
 package src
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/sqs/go-flags"
+	"sourcegraph.com/sourcegraph/srclib/report"
+	"sourcegraph.com/sourcegraph/srclib/schema"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/util"
 )
 
 func init() {
@@ -35,6 +44,50 @@ type ToolCmd struct {
 var toolCmd ToolCmd
 
 func (c *ToolCmd) Execute(args []string) error {
+	mode, err := c.ValidationMode()
+	if err != nil {
+		return err
+	}
+
+	// Read the input first if this run is being recorded or replayed. If
+	// we're running as part of a build that is recording the runs, or if the
+	// input isn't interactive, read just the name and type of the source
+	// unit that it usually is, which identify the run in the build's records
+	// (and name its log file) and tag the tool's stderr. The tool reads the
+	// rest of the input as it runs.
+	recordDir, replayDir := os.Getenv(toolchain.RecordEnv), os.Getenv(toolchain.ReplayEnv)
+	reportLog, logDir := os.Getenv(report.LogEnv), os.Getenv(report.LogDirEnv)
+	var input []byte
+	var unitName, unitType string
+	var stdin io.Reader = os.Stdin
+	if recordDir != "" || replayDir != "" {
+		input, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		unitName, unitType, _ = peekUnit(bytes.NewReader(input))
+	} else if reportLog != "" || logDir != "" || !isInteractive(os.Stdin) {
+		unitName, unitType, stdin = peekUnit(os.Stdin)
+	}
+
+	if replayDir != "" {
+		// Don't open the toolchain, since it might not be runnable here.
+		r, err := toolchain.FindRecording(replayDir, string(c.Args.Toolchain), string(c.Args.Tool), c.Args.ToolArgs, input)
+		if err != nil {
+			return err
+		}
+		if verbose() {
+			log.Printf("Replaying tool run from %s: %s %s %v", replayDir, c.Args.Toolchain, c.Args.Tool, c.Args.ToolArgs)
+		}
+		stderr := toolchain.NewStderrWriter(os.Stderr, toolchain.UnitRunTag(string(c.Args.Tool), unitName))
+		stderr.Write(r.Stderr)
+		stderr.Close(r.Error != "")
+		if r.Error != "" {
+			log.Fatal(r.Error)
+		}
+		return c.writeOutput(r.Stdout, mode)
+	}
+
 	tc, err := toolchain.Open(string(c.Args.Toolchain), c.ToolchainMode())
 	if err != nil {
 		log.Fatal(err)
@@ -43,8 +96,15 @@ func (c *ToolCmd) Execute(args []string) error {
 	var cmder interface {
 		Command() (*exec.Cmd, error)
 	}
+	var session *toolchain.Session
 	if c.Args.Tool != "" {
 		cmder, err = toolchain.OpenTool(string(c.Args.Toolchain), string(c.Args.Tool), c.ToolchainMode())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if session, err = toolSession(string(c.Args.Toolchain)); err != nil {
+			log.Fatal(err)
+		}
 	} else {
 		cmder = tc
 	}
@@ -54,20 +114,160 @@ func (c *ToolCmd) Execute(args []string) error {
 		log.Fatal(err)
 	}
 
+	// Tools that speak protocol version 2 or later read and write messages
+	// that must be adapted, so read their input first.
+	if session.Adapts() && input == nil {
+		if input, err = ioutil.ReadAll(stdin); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	cmd.Args = append(cmd.Args, c.Args.ToolArgs...)
-	cmd.Stderr = os.Stderr
+	tagged := toolchain.NewStderrWriter(os.Stderr, toolchain.UnitRunTag(string(c.Args.Tool), unitName))
+	cmd.Stderr = tagged
 	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-	if GlobalOpt.Verbose {
+	cmd.Stdin = stdin
+	if input != nil {
+		stdin, err := session.EncodeInput(string(c.Args.Tool), c.Args.ToolArgs, input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	// If the output is recorded, validated, or adapted, buffer it (and
+	// record stderr, too).
+	var output, stderr bytes.Buffer
+	bufferOutput := recordDir != "" || (mode != schema.Off && schema.Output(c.op()) != nil) || session.Adapts()
+	if bufferOutput {
+		cmd.Stdout = &output
+	}
+	if recordDir != "" {
+		cmd.Stderr = io.MultiWriter(tagged, &stderr)
+	}
+	if verbose() {
 		log.Printf("Running tool: %v", cmd.Args)
 	}
-	if err := cmd.Run(); err != nil {
+
+	var run *report.ToolRun
+	if reportLog != "" || logDir != "" {
+		run = &report.ToolRun{Toolchain: string(c.Args.Toolchain), Tool: string(c.Args.Tool), UnitName: unitName, UnitType: unitType}
+	}
+
+	// Keep a log of the run in the build data, so that failures can be
+	// diagnosed after the build.
+	var runLog *report.RunLog
+	if logDir != "" {
+		if runLog, err = report.CreateRunLog(logDir, run, cmd.Args); err != nil {
+			log.Printf("Warning: failed to create log file for %v: %s.", cmd.Args, err)
+		} else {
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, runLog.Stdout())
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, runLog.Stderr())
+		}
+	}
+
+	waitForMemoryBudget()
+	if run != nil {
+		run.Start = time.Now()
+	}
+	err = cmd.Run()
+	tagged.Close(err != nil)
+	if runLog != nil {
+		if err := runLog.Close(err); err != nil {
+			log.Printf("Warning: failed to write log file for %v: %s.", cmd.Args, err)
+		}
+	}
+	if session.Adapts() {
+		out, decodeErr := session.DecodeOutput(c.op(), output.Bytes())
+		if decodeErr != nil {
+			err = decodeErr
+		} else {
+			output.Reset()
+			output.Write(out)
+		}
+	}
+	if run != nil {
+		run.Wall = time.Since(run.Start)
+		if cmd.ProcessState != nil {
+			run.SetUsage(util.ProcessResourceUsage(cmd.ProcessState))
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		if reportLog != "" {
+			if err := report.Append(reportLog, run); err != nil {
+				log.Printf("Warning: failed to record resource usage of %v: %s.", cmd.Args, err)
+			}
+		}
+	}
+	if recordDir != "" {
+		r := &toolchain.Recording{Toolchain: string(c.Args.Toolchain), Tool: string(c.Args.Tool), Args: c.Args.ToolArgs, Stdin: input, Stdout: output.Bytes(), Stderr: stderr.Bytes()}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if err := r.Save(recordDir); err != nil {
+			log.Printf("Warning: failed to record tool run %v in %s: %s.", cmd.Args, recordDir, err)
+		}
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 
+	if bufferOutput {
+		return c.writeOutput(output.Bytes(), mode)
+	}
 	return nil
 }
 
+// writeOutput validates the tool's output (if its operation has an output
+// schema) and writes it to stdout.
+func (c *ToolCmd) writeOutput(output []byte, mode schema.Mode) error {
+	if op := c.op(); op != "" {
+		if err := schema.CheckOutput(fmt.Sprintf("%s %s", c.Args.Toolchain, c.Args.Tool), op, output, mode); err != nil {
+			return err
+		}
+	}
+	_, err := os.Stdout.Write(output)
+	return err
+}
+
+// toolSession negotiates the toolchain protocol with the toolchain.
+func toolSession(toolchainPath string) (*toolchain.Session, error) {
+	tc, err := toolchain.Lookup(toolchainPath)
+	if err != nil {
+		return nil, err
+	}
+	return tc.Negotiate()
+}
+
+// op returns the operation that the tool performs, or "" if it can't be
+// determined.
+func (c *ToolCmd) op() string {
+	if c.Args.Tool == "" {
+		return ""
+	}
+	return toolOp(string(c.Args.Toolchain), string(c.Args.Tool))
+}
+
+// toolOp returns the operation that the toolchain's tool performs, or "" if
+// it can't be determined.
+func toolOp(toolchainPath, subcmd string) string {
+	tc, err := toolchain.Lookup(toolchainPath)
+	if err != nil {
+		return ""
+	}
+	cfg, err := tc.ReadConfig()
+	if err != nil {
+		return ""
+	}
+	for _, tool := range cfg.Tools {
+		if tool.Subcmd == subcmd {
+			return tool.Op
+		}
+	}
+	return ""
+}
+
 type ToolName string
 
 func (t ToolName) Complete(match string) []flags.Completion {
@@ -91,3 +291,43 @@ func (t ToolName) Complete(match string) []flags.Completion {
 	}
 	return comps
 }
+
+// peekUnit reads the Name and Type of the source unit (if any) that is the
+// JSON object at the start of r, reading only as far as it needs to, instead
+// of all of r (which may be large). The returned reader reads all of r.
+func peekUnit(r io.Reader) (name, typ string, all io.Reader) {
+	var head bytes.Buffer
+	dec := json.NewDecoder(io.TeeReader(r, &head))
+	defer func() { all = io.MultiReader(&head, r) }()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", "", nil
+	}
+	var foundName, foundType bool
+	for dec.More() && !(foundName && foundType) {
+		key, err := dec.Token()
+		if err != nil {
+			return "", "", nil
+		}
+		var v interface{}
+		switch {
+		case strings.EqualFold(key.(string), "Name"):
+			v, foundName = &name, true
+		case strings.EqualFold(key.(string), "Type"):
+			v, foundType = &typ, true
+		default:
+			v = new(json.RawMessage)
+		}
+		if err := dec.Decode(v); err != nil {
+			return "", "", nil
+		}
+	}
+	return name, typ, nil
+}
+
+// isInteractive reports whether f is a terminal (rather than a file or
+// pipe).
+func isInteractive(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,54 @@
+package src
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPeekUnit(t *testing.T) {
+	big := strings.Repeat("x", 100000)
+	tests := []struct {
+		input              string
+		wantName, wantType string
+	}{
+		{`{"Name": "foo", "Type": "GoPackage", "Files": ["` + big + `"]}`, "foo", "GoPackage"},
+		{`{"Dir": "` + big + `", "Type": "GoPackage", "name": "foo"}`, "foo", "GoPackage"},
+		{`{"Type": "GoPackage"}`, "", "GoPackage"},
+		{`{"Name": 1, "Type": "GoPackage"}`, "", ""},
+		{`[{"Name": "foo"}]`, "", ""},
+		{`{"Name": "foo", `, "", ""},
+		{``, "", ""},
+	}
+	for _, test := range tests {
+		name, typ, all := peekUnit(strings.NewReader(test.input))
+		if name != test.wantName || typ != test.wantType {
+			t.Errorf("%.40q: got name %q, type %q, want %q, %q", test.input, name, typ, test.wantName, test.wantType)
+		}
+		if data, err := ioutil.ReadAll(all); err != nil || string(data) != test.input {
+			t.Errorf("%.40q: got %d bytes of input (error %v), want all %d", test.input, len(data), err, len(test.input))
+		}
+	}
+}
+
+func TestPeekUnit_readsOnlyHead(t *testing.T) {
+	r := &countingReader{r: strings.NewReader(`{"Name": "foo", "Type": "GoPackage", "Files": ["` + strings.Repeat("x", 1<<20) + `"]}`)}
+	if name, _, _ := peekUnit(r); name != "foo" {
+		t.Fatalf("got name %q, want foo", name)
+	}
+	if r.n > 64*1024 {
+		t.Errorf("read %d bytes to find the unit's name and type, want only the start of the input", r.n)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
@@ -1,11 +1,15 @@
 package src
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 
 	"strings"
@@ -14,7 +18,11 @@ import (
 	"github.com/aybabtme/color/brush"
 	"github.com/sqs/go-flags"
 	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/schema"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/toolchain/bundle"
+	"sourcegraph.com/sourcegraph/srclib/toolchain/scaffold"
 )
 
 func init() {
@@ -28,6 +36,8 @@ func init() {
 	}
 	c.Aliases = []string{"tc"}
 
+	toolchain.Verify = verifyToolchain
+
 	_, err = c.AddCommand("list",
 		"list available toolchains",
 		"List available toolchains.",
@@ -73,6 +83,89 @@ func init() {
 		log.Fatal(err)
 	}
 
+	_, err = c.AddCommand("bundle",
+		"package a toolchain for distribution",
+		`Packages a toolchain (by default, the one in the current directory) as a bundle: a gzipped tar file of the toolchain's files and a manifest (named `+bundle.ManifestFilename+`) that records the toolchain path, version, supported platforms, and the SHA-256 hash of each file. Build the toolchain's program (in .bin/) before bundling it. The SHA-256 hash of the bundle file is printed; publish it (e.g., in a registry index) along with the bundle, so that "src toolchain install" can verify the bundle.
+
+If the toolchain's program only runs on some platforms (e.g., because it's a compiled binary), specify them with --platform; otherwise the bundle may be installed on any platform.`,
+		&toolchainBundleCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("install",
+		"install toolchain bundles",
+		`Installs toolchains from bundles (see "src toolchain bundle") into the first directory in SRCLIBPATH, replacing any existing toolchain at the same toolchain path.
+
+Each SOURCE is either the URL or file path of a bundle, or a toolchain path (optionally followed by @VERSION), which is looked up in the registry index at the --registry URL (default: $`+bundle.RegistryEnv+`). A registry index is a JSON file that lists toolchains' bundles and their SHA-256 hashes:
+
+  {"Toolchains": {"github.com/alice/srclib-foo": [
+    {"Version": "1.0", "Platforms": ["linux/amd64"], "URL": "srclib-foo-1.0-linux-amd64.tar.gz", "SHA256": "..."}
+  ]}}
+
+Bundles are verified before being installed: each file must match the hash in the bundle's manifest, and the bundle itself must match the SHA-256 hash from the registry or the --sha256 option, if any.`,
+		&toolchainInstallCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("upgrade",
+		"upgrade toolchains installed from bundles",
+		`Upgrades toolchains that were installed from bundles (with "src toolchain install") to the newest compatible versions in the registry at the --registry URL (default: $`+bundle.RegistryEnv+`). If no TOOLCHAINs are given, all toolchains installed from bundles in the first directory in SRCLIBPATH are upgraded.
+
+Versions are compared as semantic versions (MAJOR.MINOR.PATCH). A compatible version is one with the same major version (or the same minor version, if the major version is 0); use --major to allow upgrades to any newer version. If the Srcfile in the current directory pins a toolchain's version (in its "Toolchains" property, which maps toolchain paths to version constraints such as "^1.2" or ">=1.0 <1.5"), the toolchain is only upgraded to versions that satisfy the pin.
+
+The changelogs of the new versions (from the registry) are shown before each toolchain is upgraded. Each toolchain is upgraded atomically: if the new version can't be installed or fails verification, the previous version is restored.`,
+		&toolchainUpgradeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("keygen",
+		"generate a key pair for signing bundles",
+		`Generates an Ed25519 key pair for signing toolchain bundles (with "src toolchain bundle --sign"). The private key is written to NAME.key (readable only by you) and the public key to NAME.pub. Publish the public key so that users can trust it (with "src toolchain trust").`,
+		&toolchainKeygenCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("trust",
+		"trust a bundle signing key",
+		`Adds public keys (created by "src toolchain keygen") to the trusted keys in the first directory in SRCLIBPATH. Bundles signed by trusted keys are verified when they are installed, and toolchains installed from them are verified whenever they are run.
+
+The `+bundle.PolicyEnv+` environment variable determines how toolchains that aren't signed by a trusted key are treated: "warn" (the default) installs unsigned bundles with a warning; "require" refuses to install unsigned bundles or to run toolchains that weren't installed from signed bundles; and "off" disables signature verification.`,
+		&toolchainTrustCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("new",
+		"create a new toolchain",
+		`Creates a new toolchain in a directory (by default, the last component of the toolchain path, in the current directory). The toolchain has stubs of a scanner, grapher, and dependency resolver written in the chosen language (one of `+strings.Join(scaffold.Languages, ", ")+`), a Dockerfile, and a sample test case for "src test".
+
+The generated scanner reports all files with the given extensions as a single source unit, and the other tools output nothing; fill in the stubs to implement the toolchain. See the generated README.md for next steps.`,
+		&toolchainNewCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("schema",
+		"print the JSON Schemas of tool I/O",
+		`Prints the JSON Schema of the input (on stdin) or output (on stdout) of tools that perform an operation (one of `+strings.Join(schema.Ops(), ", ")+`). Tool output is validated against these schemas when tools are run (see the --validate option).
+
+With --out, the schemas of the input and output of all operations are written to files named OP-input.json and OP-output.json in a directory instead.`,
+		&toolchainSchemaCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = c.AddCommand("install-std",
 		"install standard toolchains",
 		"Install standard toolchains (sourcegraph.com/sourcegraph/srclib-* toolchains).",
@@ -101,7 +194,20 @@ func (t ToolchainPath) Complete(match string) []flags.Completion {
 }
 
 type ToolchainExecOpt struct {
-	ExeMethods string `short:"m" long:"methods" default:"program,docker" description:"toolchain execution methods" value-name:"METHODS"`
+	ExeMethods string `short:"m" long:"methods" default:"program,docker" description:"toolchain execution methods (program, docker, kubernetes)" value-name:"METHODS"`
+	Validate   string `long:"validate" default:"warn" description:"validate tool output against the JSON Schema of its operation (off, warn about problems, or fail on them, more strictly)" value-name:"off|warn|strict"`
+}
+
+// ValidationMode returns how tool output should be validated.
+func (o *ToolchainExecOpt) ValidationMode() (schema.Mode, error) {
+	if o.Validate == "" {
+		return schema.Warn, nil
+	}
+	mode, err := schema.ParseMode(o.Validate)
+	if err != nil {
+		return "", withKind(UsageError, err)
+	}
+	return mode, nil
 }
 
 func (o *ToolchainExecOpt) ToolchainMode() toolchain.Mode {
@@ -115,6 +221,9 @@ func (o *ToolchainExecOpt) ToolchainMode() toolchain.Mode {
 		if method == "docker" {
 			mode |= toolchain.AsDockerContainer
 		}
+		if method == "kubernetes" {
+			mode |= toolchain.AsKubernetesJob
+		}
 	}
 	return mode
 }
@@ -248,7 +357,7 @@ var toolchainGetCmd ToolchainGetCmd
 
 func (c *ToolchainGetCmd) Execute(args []string) error {
 	for _, tc := range c.Args.Toolchains {
-		if GlobalOpt.Verbose {
+		if verbose() {
 			fmt.Println(tc)
 		}
 		_, err := toolchain.Get(string(tc), c.Update)
@@ -272,6 +381,489 @@ func (c *ToolchainAddCmd) Execute(args []string) error {
 	return toolchain.Add(c.Dir, c.Args.ToolchainPath)
 }
 
+type ToolchainBundleCmd struct {
+	Dir       string   `long:"dir" default:"." description:"directory containing the toolchain to bundle" value-name:"DIR"`
+	Version   string   `long:"version" description:"version of the toolchain" value-name:"VERSION"`
+	Platforms []string `long:"platform" description:"platform that the toolchain runs on, as GOOS/GOARCH (may be repeated; default: all platforms)" value-name:"PLATFORM"`
+	Out       string   `short:"o" long:"out" description:"bundle file to write (default: NAME[-VERSION].tar.gz)" value-name:"FILE"`
+	Sign      string   `long:"sign" description:"private key file to sign the bundle with (see \"src toolchain keygen\")" value-name:"KEYFILE"`
+
+	Args struct {
+		ToolchainPath string `name:"TOOLCHAIN" description:"toolchain path of the toolchain (e.g., github.com/alice/srclib-foo)"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var toolchainBundleCmd ToolchainBundleCmd
+
+func (c *ToolchainBundleCmd) Execute(args []string) error {
+	out := c.Out
+	if out == "" {
+		out = path.Base(c.Args.ToolchainPath)
+		if c.Version != "" {
+			out += "-" + c.Version
+		}
+		out += ".tar.gz"
+	}
+
+	var key *bundle.PrivateKey
+	if c.Sign != "" {
+		var err error
+		if key, err = bundle.ReadPrivateKey(c.Sign); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	m := bundle.Manifest{Path: c.Args.ToolchainPath, Version: c.Version, Platforms: c.Platforms}
+	if err := bundle.Create(f, c.Dir, m, key); err != nil {
+		f.Close()
+		os.Remove(out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	h, err := bundle.HashFile(out)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		fmt.Printf("Wrote %s (SHA-256 %s), signed by key %s\n", out, h, key.KeyID)
+	} else {
+		fmt.Printf("Wrote %s (SHA-256 %s)\n", out, h)
+	}
+	return nil
+}
+
+type ToolchainInstallCmd struct {
+	Registry string `long:"registry" description:"URL of the registry index to look up toolchain paths in (default: $SRCLIB_REGISTRY)" value-name:"URL"`
+	SHA256   string `long:"sha256" description:"expected SHA-256 hash of the bundle (only with a single bundle URL or file)" value-name:"HASH"`
+
+	Args struct {
+		Sources []string `name:"SOURCE" description:"bundle URLs or files, or toolchain paths (with optional @VERSION) to look up in the registry"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var toolchainInstallCmd ToolchainInstallCmd
+
+func (c *ToolchainInstallCmd) Execute(args []string) error {
+	if c.SHA256 != "" && len(c.Args.Sources) != 1 {
+		return withKind(UsageError, errors.New("--sha256 can only be used when installing a single bundle"))
+	}
+	registry := c.Registry
+	if registry == "" {
+		registry = os.Getenv(bundle.RegistryEnv)
+	}
+	var idx *bundle.Index
+
+	srclibDir := strings.SplitN(srclib.Path, ":", 2)[0]
+	verifier, err := bundle.NewVerifier(srclibDir)
+	if err != nil {
+		return err
+	}
+	for _, src := range c.Args.Sources {
+		bundleURL, sha256 := src, c.SHA256
+		if !isBundleSource(src) {
+			if registry == "" {
+				return withKind(UsageError, fmt.Errorf("can't install %s: no registry specified (use --registry or set %s), and it isn't a bundle URL or file", src, bundle.RegistryEnv))
+			}
+			if idx == nil {
+				var err error
+				if idx, err = bundle.ReadIndex(registry); err != nil {
+					return err
+				}
+			}
+			tc, version := src, ""
+			if i := strings.LastIndex(src, "@"); i != -1 {
+				tc, version = src[:i], src[i+1:]
+			}
+			e, err := idx.Find(registry, tc, version, bundle.Platform)
+			if err != nil {
+				return err
+			}
+			if sha256 != "" && !strings.EqualFold(sha256, e.SHA256) {
+				return fmt.Errorf("registry lists SHA-256 hash %s for %s, not %s", e.SHA256, src, sha256)
+			}
+			bundleURL, sha256 = e.URL, e.SHA256
+		} else if sha256 == "" {
+			log.Printf("Warning: not verifying the SHA-256 hash of bundle %s (use --sha256 to verify it)", src)
+		}
+
+		data, err := bundle.Fetch(bundleURL, sha256)
+		if err != nil {
+			return err
+		}
+		m, err := bundle.Install(bytes.NewReader(data), srclibDir, verifier)
+		if err != nil {
+			return err
+		}
+		version := m.Version
+		if version == "" {
+			version = "unversioned"
+		}
+		fmt.Println(brush.Green(fmt.Sprintf("Installed %s (%s) from %s", m.Path, version, bundleURL)).String())
+	}
+	return nil
+}
+
+// isBundleSource returns whether src (an argument to "src toolchain
+// install") is the URL or file path of a bundle, instead of a toolchain path.
+func isBundleSource(src string) bool {
+	if strings.Contains(src, "://") || strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz") {
+		return true
+	}
+	fi, err := os.Stat(src)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+type ToolchainUpgradeCmd struct {
+	Registry string `long:"registry" description:"URL of the registry index (default: $SRCLIB_REGISTRY)" value-name:"URL"`
+	Major    bool   `long:"major" description:"allow upgrades to new major versions (which may be incompatible) of toolchains that aren't pinned"`
+	DryRun   bool   `short:"n" long:"dry-run" description:"only show the available upgrades and their changelogs"`
+
+	Args struct {
+		Toolchains []string `name:"TOOLCHAIN" description:"toolchain paths (default: all toolchains installed from bundles)"`
+	} `positional-args:"yes"`
+}
+
+var toolchainUpgradeCmd ToolchainUpgradeCmd
+
+func (c *ToolchainUpgradeCmd) Execute(args []string) error {
+	registry := c.Registry
+	if registry == "" {
+		registry = os.Getenv(bundle.RegistryEnv)
+	}
+	if registry == "" {
+		return withKind(UsageError, fmt.Errorf("no registry specified (use --registry or set %s)", bundle.RegistryEnv))
+	}
+
+	cfg, err := config.ReadRepository(".", "")
+	if err != nil {
+		return fmt.Errorf("reading %s: %s", config.Filename, err)
+	}
+
+	srclibDir := strings.SplitN(srclib.Path, ":", 2)[0]
+	verifier, err := bundle.NewVerifier(srclibDir)
+	if err != nil {
+		return err
+	}
+
+	tcPaths := c.Args.Toolchains
+	if len(tcPaths) == 0 {
+		tcs, err := toolchain.List()
+		if err != nil {
+			return err
+		}
+		for _, tc := range tcs {
+			if tc.Dir != filepath.Join(srclibDir, filepath.FromSlash(tc.Path)) {
+				continue
+			}
+			if m, err := bundle.Installed(tc.Dir); err != nil {
+				return err
+			} else if m != nil {
+				tcPaths = append(tcPaths, tc.Path)
+			}
+		}
+		if len(tcPaths) == 0 {
+			log.Printf("No toolchains in %s were installed from bundles.", srclibDir)
+			return nil
+		}
+	}
+
+	idx, err := bundle.ReadIndex(registry)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, tcPath := range tcPaths {
+		if err := c.upgrade(idx, registry, srclibDir, verifier, cfg.Toolchains[tcPath], tcPath); err != nil {
+			fmt.Println(brush.Red(fmt.Sprintf("Failed to upgrade %s: %s", tcPath, err)).String())
+			failed = append(failed, tcPath)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to upgrade %d toolchain(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// upgrade upgrades a single toolchain, whose version is pinned by pin (a
+// version constraint, or "" if it isn't pinned).
+func (c *ToolchainUpgradeCmd) upgrade(idx *bundle.Index, registry, srclibDir string, verifier *bundle.Verifier, pin, tcPath string) error {
+	m, err := bundle.Installed(filepath.Join(srclibDir, filepath.FromSlash(tcPath)))
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("it was not installed from a bundle in %s (use \"src toolchain install\")", srclibDir)
+	}
+	installed, err := bundle.ParseVersion(m.Version)
+	if err != nil {
+		return fmt.Errorf("installed version %q is not a semantic version (reinstall it with \"src toolchain install\")", m.Version)
+	}
+	var constraint *bundle.Constraint
+	if pin != "" {
+		if constraint, err = bundle.ParseConstraint(pin); err != nil {
+			return err
+		}
+		if !constraint.Allows(installed) {
+			log.Printf("Warning: installed version %s of %s doesn't satisfy its pin (%q) in %s.", m.Version, tcPath, pin, config.Filename)
+		}
+	}
+
+	e, changes, err := idx.FindUpgrade(registry, tcPath, installed, constraint, c.Major, bundle.Platform)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		msg := fmt.Sprintf("%s %s is up to date", tcPath, m.Version)
+		if constraint != nil {
+			msg += fmt.Sprintf(" (pinned to %q)", pin)
+		} else if !c.Major {
+			if e, _, err := idx.FindUpgrade(registry, tcPath, installed, nil, true, bundle.Platform); err == nil && e != nil {
+				msg += fmt.Sprintf(" (incompatible version %s is available; use --major to upgrade to it)", e.Version)
+			}
+		}
+		fmt.Println(msg)
+		return nil
+	}
+
+	fmt.Println(brush.Cyan(fmt.Sprintf("%s: %s -> %s", tcPath, m.Version, e.Version)).String())
+	for _, ch := range changes {
+		if ch.Changelog == "" {
+			continue
+		}
+		fmt.Printf("  %s:\n", ch.Version)
+		for _, line := range strings.Split(strings.TrimRight(ch.Changelog, "\n"), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	if c.DryRun {
+		return nil
+	}
+
+	data, err := bundle.Fetch(e.URL, e.SHA256)
+	if err != nil {
+		return err
+	}
+	newM, err := bundle.Upgrade(bytes.NewReader(data), srclibDir, verifier, func(m *bundle.Manifest, dir string) error {
+		if m.Path != tcPath {
+			return fmt.Errorf("bundle %s contains toolchain %s, not %s", e.URL, m.Path, tcPath)
+		}
+		if m.Version != e.Version {
+			return fmt.Errorf("bundle %s contains version %q, not version %q (listed in the registry)", e.URL, m.Version, e.Version)
+		}
+		return checkInstalledToolchain(verifier, m, dir)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(brush.Green(fmt.Sprintf("Upgraded %s to %s", newM.Path, newM.Version)).String())
+	return nil
+}
+
+// checkInstalledToolchain checks that the toolchain installed in dir from
+// a bundle (with manifest m) can be used.
+func checkInstalledToolchain(verifier *bundle.Verifier, m *bundle.Manifest, dir string) error {
+	tc, err := toolchain.Lookup(m.Path)
+	if err != nil {
+		return err
+	}
+	if tc.Dir != dir {
+		return fmt.Errorf("toolchain %s was found in %s, not in %s", m.Path, tc.Dir, dir)
+	}
+	if _, err := tc.ReadConfig(); err != nil {
+		return fmt.Errorf("reading %s: %s", tc.ConfigFile, err)
+	}
+	if tc.Program == "" && tc.Dockerfile == "" {
+		return fmt.Errorf("toolchain %s has neither a program nor a Dockerfile", m.Path)
+	}
+	return verifier.VerifyInstalled(m.Path, dir)
+}
+
+type ToolchainKeygenCmd struct {
+	Out string `short:"o" long:"out" default:"srclib-bundle" description:"write the keys to NAME.key and NAME.pub" value-name:"NAME"`
+}
+
+var toolchainKeygenCmd ToolchainKeygenCmd
+
+func (c *ToolchainKeygenCmd) Execute(args []string) error {
+	pub, priv, err := bundle.GenerateKey()
+	if err != nil {
+		return err
+	}
+	for _, k := range []struct {
+		file string
+		key  interface{}
+		mode os.FileMode
+	}{
+		{c.Out + ".key", priv, 0600},
+		{c.Out + ".pub", pub, 0644},
+	} {
+		data, err := json.MarshalIndent(k.key, "", "  ")
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(k.file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, k.mode)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Wrote key %s to %s.key (private) and %s.pub (public)\n", pub.KeyID, c.Out, c.Out)
+	return nil
+}
+
+type ToolchainTrustCmd struct {
+	Args struct {
+		KeyFiles []string `name:"KEYFILE" description:"public key files to trust"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var toolchainTrustCmd ToolchainTrustCmd
+
+func (c *ToolchainTrustCmd) Execute(args []string) error {
+	dir := bundle.TrustedKeysDir(strings.SplitN(srclib.Path, ":", 2)[0])
+	for _, file := range c.Args.KeyFiles {
+		k, err := bundle.ReadPublicKey(file)
+		if err != nil {
+			return err
+		}
+		if err := bundle.Trust(dir, k); err != nil {
+			return err
+		}
+		fmt.Printf("Trusted key %s\n", k.KeyID)
+	}
+	return nil
+}
+
+var (
+	toolchainVerifierOnce sync.Once
+	toolchainVerifier     *bundle.Verifier
+	toolchainVerifierErr  error
+
+	verifiedToolchainsMu sync.Mutex
+	verifiedToolchains   = map[string]error{} // toolchain dir -> verification result
+)
+
+// verifyToolchain checks that a toolchain may be run, according to the
+// signature policy. It is called (via toolchain.Verify) whenever a
+// toolchain is opened, so it only verifies each toolchain once per process.
+func verifyToolchain(tc *toolchain.Info) error {
+	toolchainVerifierOnce.Do(func() {
+		toolchainVerifier, toolchainVerifierErr = bundle.NewVerifier(strings.SplitN(srclib.Path, ":", 2)[0])
+	})
+	if toolchainVerifierErr != nil {
+		return toolchainVerifierErr
+	}
+
+	verifiedToolchainsMu.Lock()
+	defer verifiedToolchainsMu.Unlock()
+	if err, verified := verifiedToolchains[tc.Dir]; verified {
+		return err
+	}
+	err := toolchainVerifier.VerifyInstalled(tc.Path, tc.Dir)
+	verifiedToolchains[tc.Dir] = err
+	return err
+}
+
+type ToolchainNewCmd struct {
+	Dir        string   `long:"dir" description:"directory to create the toolchain in (default: last component of the toolchain path)" value-name:"DIR"`
+	Language   string   `short:"l" long:"lang" default:"sh" description:"language to write the tools in (go, python, sh)" value-name:"LANG"`
+	UnitType   string   `long:"unit-type" description:"type of the source units that the toolchain handles (default: derived from the toolchain path, e.g., Foo for srclib-foo)" value-name:"TYPE"`
+	Extensions []string `short:"e" long:"ext" description:"file extension of source files to scan for (may be repeated; default: lowercased unit type)" value-name:"EXT"`
+
+	Args struct {
+		ToolchainPath string `name:"TOOLCHAIN" description:"toolchain path of the new toolchain (e.g., github.com/alice/srclib-foo)"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var toolchainNewCmd ToolchainNewCmd
+
+func (c *ToolchainNewCmd) Execute(args []string) error {
+	dir := c.Dir
+	if dir == "" {
+		dir = path.Base(c.Args.ToolchainPath)
+	}
+	opt := scaffold.Options{
+		Path:       c.Args.ToolchainPath,
+		Language:   c.Language,
+		UnitType:   c.UnitType,
+		Extensions: c.Extensions,
+	}
+	files, err := scaffold.Write(dir, opt)
+	if err != nil {
+		return withKind(UsageError, err)
+	}
+	for _, f := range files {
+		fmt.Println(filepath.Join(dir, f))
+	}
+	fmt.Println()
+	fmt.Printf("Created toolchain %s in %s. To use it, add it to your SRCLIBPATH:\n\n", c.Args.ToolchainPath, dir)
+	if c.Language == "go" {
+		fmt.Printf("  (cd %s && make)\n", dir)
+	}
+	fmt.Printf("  src toolchain add --dir %s %s\n", dir, c.Args.ToolchainPath)
+	return nil
+}
+
+type ToolchainSchemaCmd struct {
+	Out string `short:"o" long:"out" description:"directory to write the schemas of all operations to" value-name:"DIR"`
+
+	Args struct {
+		Op        string `name:"OP" description:"operation whose tools' I/O schema to print"`
+		Direction string `name:"input|output" description:"whether to print the schema of the input or output (default: output)"`
+	} `positional-args:"yes"`
+}
+
+var toolchainSchemaCmd ToolchainSchemaCmd
+
+func (c *ToolchainSchemaCmd) Execute(args []string) error {
+	if c.Out != "" {
+		files, err := schema.Files()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.Out, 0755); err != nil {
+			return err
+		}
+		for name, data := range files {
+			if err := ioutil.WriteFile(filepath.Join(c.Out, name), data, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if c.Args.Op == "" {
+		return withKind(UsageError, fmt.Errorf("no operation specified (must be one of %s)", strings.Join(schema.Ops(), ", ")))
+	}
+	var s schema.Schema
+	switch c.Args.Direction {
+	case "input":
+		s = schema.Input(c.Args.Op)
+	case "", "output":
+		s = schema.Output(c.Args.Op)
+	default:
+		return withKind(UsageError, fmt.Errorf("invalid direction %q (must be input or output)", c.Args.Direction))
+	}
+	if s == nil {
+		return withKind(UsageError, fmt.Errorf("no schema for operation %q (must be one of %s)", c.Args.Op, strings.Join(schema.Ops(), ", ")))
+	}
+	PrintJSON(s, "")
+	return nil
+}
+
 type ToolchainInstallStdCmd struct {
 	Skip []string `long:"skip" description:"skip installing matching toolchains (can be specified multiple times; e.g., --skip go --skip ruby)" value-name:"NAME"`
 }
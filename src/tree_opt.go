@@ -0,0 +1,58 @@
+package src
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+// TreeOpt selects the source tree that a command reads: the working tree, or
+// a commit of the git repository (read from the repository's object
+// database, so it needn't be checked out).
+type TreeOpt struct {
+	Rev string `long:"rev" description:"read the source tree of this git revision (a branch, tag, or commit ID) instead of the working tree" value-name:"REV"`
+}
+
+// fileSystem returns the source tree selected by o, for the repository (or
+// working tree) in dir.
+func (o *TreeOpt) fileSystem(dir string) (vfs.FileSystem, error) {
+	if o.Rev == "" {
+		return vfs.OS(dir), nil
+	}
+	return vfs.Git(dir, o.Rev)
+}
+
+// chdirToTree exports the tree fs to a new temporary directory and changes
+// the current directory to it, so that external tools (such as scanners,
+// which run in the current directory) can read it. The returned func
+// changes back to the original directory and removes the temporary
+// directory.
+func chdirToTree(fs vfs.FileSystem) (restore func(), err error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir("", "src-tree")
+	if err != nil {
+		return nil, err
+	}
+	if verbose() {
+		log.Printf("Exporting source tree %s to %s.", fs, tmpDir)
+	}
+	if err := vfs.Export(fs, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	return func() {
+		if err := os.Chdir(wd); err != nil {
+			log.Fatal(err)
+		}
+		os.RemoveAll(tmpDir)
+	}, nil
+}
@@ -1,13 +1,14 @@
 package src
 
 import (
-	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/present"
 	"sourcegraph.com/sourcegraph/srclib/scan"
 	"sourcegraph.com/sourcegraph/srclib/toolchain"
 	"sourcegraph.com/sourcegraph/srclib/unit"
@@ -39,7 +40,11 @@ func scanUnitsIntoConfig(cfg *config.Repository, configOpt config.Options, execO
 		scanners[i] = scanner
 	}
 
-	units, err := scan.ScanMulti(scanners, scan.Options{configOpt}, cfg.Config)
+	mode, err := execOpt.ValidationMode()
+	if err != nil {
+		return err
+	}
+	units, err := scan.ScanMulti(scanners, scan.Options{Options: configOpt, Validate: mode}, cfg.Config)
 	if err != nil {
 		return err
 	}
@@ -61,6 +66,66 @@ func scanUnitsIntoConfig(cfg *config.Repository, configOpt config.Options, execO
 		}
 	}
 
+	// Apply the repo/tree def merge config to source units that don't have
+	// their own.
+	if cfg.DefMerge != nil {
+		for _, us := range [][]*unit.SourceUnit{units, cfg.SourceUnits} {
+			for _, u := range us {
+				if u.DefMerge == nil {
+					u.DefMerge = cfg.DefMerge
+				}
+			}
+		}
+	}
+
+	// Apply the repo/tree IncludeLocals option to source units that don't
+	// have their own.
+	if cfg.IncludeLocals != nil {
+		for _, us := range [][]*unit.SourceUnit{units, cfg.SourceUnits} {
+			for _, u := range us {
+				if u.IncludeLocals == nil {
+					u.IncludeLocals = cfg.IncludeLocals
+				}
+			}
+		}
+	}
+
+	// Apply the repo/tree Snippets option to source units that don't have
+	// their own.
+	if cfg.Snippets != nil {
+		for _, us := range [][]*unit.SourceUnit{units, cfg.SourceUnits} {
+			for _, u := range us {
+				if u.Snippets == nil {
+					u.Snippets = cfg.Snippets
+				}
+			}
+		}
+	}
+
+	// Apply the repo/tree Normalize config to source units that don't have
+	// their own.
+	if cfg.Normalize != nil {
+		for _, us := range [][]*unit.SourceUnit{units, cfg.SourceUnits} {
+			for _, u := range us {
+				if u.Normalize == nil {
+					u.Normalize = cfg.Normalize
+				}
+			}
+		}
+	}
+
+	// Apply the repo/tree Filters to source units that don't have their
+	// own.
+	if cfg.Filters != nil {
+		for _, us := range [][]*unit.SourceUnit{units, cfg.SourceUnits} {
+			for _, u := range us {
+				if u.Filters == nil {
+					u.Filters = cfg.Filters
+				}
+			}
+		}
+	}
+
 	// collect manually specified source units by ID
 	manualUnits := make(map[unit.ID]*unit.SourceUnit, len(cfg.SourceUnits))
 	for _, u := range cfg.SourceUnits {
@@ -98,6 +163,7 @@ func scanUnitsIntoConfig(cfg *config.Repository, configOpt config.Options, execO
 
 type UnitsCmd struct {
 	config.Options
+	TreeOpt
 
 	ToolchainExecOpt `group:"execution"`
 
@@ -117,11 +183,22 @@ func (c *UnitsCmd) Execute(args []string) error {
 		c.Args.Dir = "."
 	}
 
-	cfg, err := getInitialConfig(c.Options, c.Args.Dir)
+	fs, err := c.fileSystem(string(c.Args.Dir))
+	if err != nil {
+		return err
+	}
+	cfg, err := getInitialConfig(c.Options, c.Args.Dir, fs)
 	if err != nil {
 		return err
 	}
 
+	if c.Rev != "" {
+		restore, err := chdirToTree(fs)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
 	if err := scanUnitsIntoConfig(cfg, c.Options, c.ToolchainExecOpt); err != nil {
 		return err
 	}
@@ -129,9 +206,11 @@ func (c *UnitsCmd) Execute(args []string) error {
 	if c.Output.Output == "json" {
 		PrintJSON(cfg.SourceUnits, "")
 	} else {
+		t := &present.Table{Colors: []present.Color{present.Bold, present.Dim}}
 		for _, u := range cfg.SourceUnits {
-			fmt.Printf("%-50s  %s\n", u.Name, u.Type)
+			t.Rows = append(t.Rows, []string{u.Name, u.Type})
 		}
+		newPrinter(os.Stdout).Table(t)
 	}
 
 	return nil
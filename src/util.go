@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/present"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 	"sourcegraph.com/sourcegraph/srclib/util"
 )
@@ -80,6 +81,19 @@ func PrintJSON(v interface{}, prefix string) {
 	fmt.Println(string(data))
 }
 
+// newPrinter returns a printer for a command's human-readable output to f
+// (os.Stdout or os.Stderr), which is colored according to --color.
+func newPrinter(f *os.File) *present.Printer {
+	p := present.NewPrinter(f)
+	switch GlobalOpt.Color {
+	case "always":
+		p.Color = true
+	case "never":
+		p.Color = false
+	}
+	return p
+}
+
 func OpenInputFiles(extraArgs []string) map[string]io.ReadCloser {
 	inputs := make(map[string]io.ReadCloser)
 	if len(extraArgs) == 0 {
@@ -133,3 +147,13 @@ func readJSONFile(file string, v interface{}) error {
 	defer f.Close()
 	return json.NewDecoder(f).Decode(v)
 }
+
+// joinInDir returns the slash-separated relative path p joined onto dir. It
+// returns an error if the result wouldn't be in dir (as when p is absolute
+// or begins with ".."), because p comes from build data.
+func joinInDir(dir, p string) (string, error) {
+	if !validArtifactPath(p) {
+		return "", fmt.Errorf("path %q is outside of %s", p, dir)
+	}
+	return filepath.Join(dir, filepath.FromSlash(p)), nil
+}
@@ -0,0 +1,33 @@
+package src
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinInDir(t *testing.T) {
+	dir := filepath.Join("out", "dir")
+	tests := map[string]string{
+		"a.html":         filepath.Join(dir, "a.html"),
+		"file/a.go.html": filepath.Join(dir, "file", "a.go.html"),
+		"../x":           "",
+		"file/../../x":   "",
+		"/etc/passwd":    "",
+		"..":             "",
+		"":               "",
+	}
+	for p, want := range tests {
+		got, err := joinInDir(dir, p)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: got %q, want an error", p, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", p, err)
+		} else if got != want {
+			t.Errorf("%q: got %q, want %q", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/diag"
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/plan"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := CLI.AddCommand("validate",
+		"validate build data",
+		`Checks the build data for the current commit (produced by src make) for problems, such as source units that failed to build and invalid graph data.
+
+Use "-o github" to print the problems as GitHub Actions workflow commands, which makes them appear as annotations on pull requests, or "-o json" to print them as JSON objects (one per line) for other CI systems.
+
+The exit status is non-zero if any errors are found.`,
+		&validateCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ValidateCmd struct {
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|github|json|sarif"`
+	} `group:"output"`
+}
+
+var validateCmd ValidateCmd
+
+func (c *ValidateCmd) Execute(args []string) error {
+	currentRepo, err := OpenRepo(".")
+	if err != nil {
+		return err
+	}
+	buildStore, err := buildstore.NewRepositoryStore(currentRepo.RootDir)
+	if err != nil {
+		return err
+	}
+	treeConfig, err := config.ReadCached(buildStore, currentRepo.CommitID)
+	if err != nil {
+		return err
+	}
+	buildDataDir, err := buildstore.BuildDir(buildStore, currentRepo.CommitID)
+	if err != nil {
+		return err
+	}
+
+	var diags []*diag.Diagnostic
+	for _, u := range treeConfig.SourceUnits {
+		graphFile := filepath.Join(buildDataDir, plan.SourceUnitDataFilename(&grapher.Output{}, u))
+		f, err := os.Open(graphFile)
+		if os.IsNotExist(err) {
+			diags = append(diags, unitDiagnostic(u, "no graph data", "The source unit has no graph data. It failed to build, or `src make` has not been run."))
+			continue
+		} else if err != nil {
+			diags = append(diags, unitDiagnostic(u, "invalid graph data", err.Error()))
+			continue
+		}
+
+		refDiags, err := validateGraphRefs(f, currentRepo.RootDir)
+		f.Close()
+		diags = append(diags, refDiags...)
+		if err != nil {
+			diags = append(diags, unitDiagnostic(u, "invalid graph data", err.Error()))
+		}
+	}
+
+	if err := writeDiagnostics(os.Stdout, c.Output.Output, diags); err != nil {
+		return err
+	}
+	var numErrs int
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			numErrs++
+		}
+	}
+	if numErrs > 0 {
+		return fmt.Errorf("validation found %d errors", numErrs)
+	}
+	return nil
+}
+
+// validateGraphRefs returns a diagnostic for each invalid ref in the graph
+// data read from r, and an error if the graph data can't be decoded. The
+// refs are streamed, so that the graph data of large source units isn't
+// held in memory. If rootDir (the repository's root directory) is
+// non-empty, the diagnostics' positions are read from the files in it.
+func validateGraphRefs(r io.Reader, rootDir string) ([]*diag.Diagnostic, error) {
+	var diags []*diag.Diagnostic
+	v := grapher.NewRefValidator()
+	err := grapher.StreamOutput(r, grapher.OutputHandlers{Ref: func(ref *graph.Ref) error {
+		for _, err := range v.Validate(ref) {
+			d := &diag.Diagnostic{Severity: diag.Error, Title: "invalid ref", Message: err.Error()}
+			if refErr, ok := err.(*grapher.RefError); ok {
+				d.File = refErr.Ref.File
+				if rootDir != "" {
+					d.SetOffset(rootDir, refErr.Ref.Start)
+				}
+			}
+			diags = append(diags, d)
+		}
+		return nil
+	}})
+	return diags, err
+}
+
+// unitDiagnostic creates an error diagnostic about a source unit. It is
+// attached to the unit's first file (if any) so that it is displayed near
+// the unit.
+func unitDiagnostic(u *unit.SourceUnit, title, msg string) *diag.Diagnostic {
+	d := &diag.Diagnostic{
+		Severity: diag.Error,
+		Title:    fmt.Sprintf("%s: %s", u.Name, title),
+		Message:  fmt.Sprintf("%s %s: %s", u.Type, u.Name, msg),
+	}
+	if len(u.Files) > 0 {
+		d.File = u.Files[0]
+	}
+	return d
+}
+
+// writeDiagnostics writes diags in the given format, which is either "text"
+// or one of the formats supported by diag.Write.
+func writeDiagnostics(w io.Writer, format string, diags []*diag.Diagnostic) error {
+	if format != "text" {
+		return diag.Write(w, format, diags)
+	}
+	for _, d := range diags {
+		var loc string
+		if d.File != "" {
+			loc = d.File
+			if d.Line > 0 {
+				loc += fmt.Sprintf(":%d:%d", d.Line, d.Column)
+			}
+			loc += ": "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s: %s\n", loc, d.Severity, d.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"sourcegraph.com/sourcegraph/srclib/buildmanifest"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/diag"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+func init() {
+	_, err := CLI.AddCommand("verify",
+		"verify the integrity of stored build data",
+		`Re-reads the stored build data of a commit to detect corruption and partial uploads. It checks that:
+
+- each file listed in the commit's build manifest (written by src make) exists and has the hash and size that the manifest records;
+- each file stored by content (as a blob) or as a delta has the hash that it was stored with, and each chunked or compressed file can be read in full;
+- each file of a known data type decodes as that type (files written by other versions of srclib may not);
+- with --graph, the refs in graph data are valid (as src validate checks).
+
+By default, the local build data of the current commit is verified. Use --store and --repo to verify an artifact store (such as the data directory of src dist coordinator) instead, and --all to verify every commit.
+
+The exit status is non-zero if any errors are found.`,
+		&verifyCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type VerifyCmd struct {
+	Store     string `long:"store" description:"verify the build data in the artifact store in DIR (such as a coordinator's data directory) instead of the local build data" value-name:"DIR"`
+	BlobStore string `long:"blob-store" description:"the blobs of --store are stored in the blob store at URL (see src dist coordinator --blob-store)" value-name:"URL"`
+	Repo      string `long:"repo" description:"repository whose build data to verify (with --store)" value-name:"URI"`
+	Commit    string `long:"commit" description:"commit whose build data to verify (default: the current commit)" value-name:"COMMIT"`
+	All       bool   `long:"all" description:"verify the build data of all commits"`
+	Graph     bool   `long:"graph" description:"also validate the refs in graph data"`
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|github|json|sarif"`
+	} `group:"output"`
+}
+
+var verifyCmd VerifyCmd
+
+func (c *VerifyCmd) Execute(args []string) error {
+	var (
+		buildStore *buildstore.RepositoryStore
+		rootDir    string // the root of the working tree of the current commit, if verifying local build data
+		current    string
+		err        error
+	)
+	if c.Store != "" {
+		if c.Repo == "" {
+			return withKind(UsageError, fmt.Errorf("--repo must be given with --store"))
+		}
+		if c.Commit == "" && !c.All {
+			return withKind(UsageError, fmt.Errorf("--commit or --all must be given with --store"))
+		}
+		if fi, err := os.Stat(c.Store); err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return withKind(UsageError, fmt.Errorf("not a directory: %s", c.Store))
+		}
+		store, err := openArtifactStore(c.Store, c.BlobStore)
+		if err != nil {
+			return err
+		}
+		buildStore, err = store.RepositoryStore(repo.URI(c.Repo))
+		if err != nil {
+			return err
+		}
+	} else {
+		currentRepo, err := OpenRepo(".")
+		if err != nil {
+			return err
+		}
+		if buildStore, err = buildstore.NewRepositoryStore(currentRepo.RootDir); err != nil {
+			return err
+		}
+		rootDir, current = currentRepo.RootDir, currentRepo.CommitID
+		if c.Commit == "" {
+			c.Commit = current
+		}
+	}
+
+	commits := []string{c.Commit}
+	if c.All {
+		if commits, err = buildStore.ListCommits(); err != nil {
+			return err
+		}
+	}
+
+	var diags []*diag.Diagnostic
+	var numFiles int
+	for _, commitID := range commits {
+		dir := ""
+		if commitID == current {
+			dir = rootDir
+		}
+		commitDiags, n, err := c.verifyCommit(buildStore, commitID, dir)
+		if err != nil {
+			return fmt.Errorf("verifying commit %s: %s", commitID, err)
+		}
+		diags = append(diags, commitDiags...)
+		numFiles += n
+	}
+
+	if err := writeDiagnostics(os.Stdout, c.Output.Output, diags); err != nil {
+		return err
+	}
+	var numErrs int
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			numErrs++
+		}
+	}
+	if !quiet() {
+		log.Printf("Verified %d files in %d commits; found %d errors.", numFiles, len(commits), numErrs)
+	}
+	if numErrs > 0 {
+		return fmt.Errorf("verification found %d errors", numErrs)
+	}
+	return nil
+}
+
+// verifyCommit verifies the build data of commitID in s (see VerifyCmd's
+// help), and returns a diagnostic for each problem it finds and the number
+// of files it verified. If rootDir is non-empty, it is the working tree of
+// the commit, which positions in graph data refer to.
+func (c *VerifyCmd) verifyCommit(s *buildstore.RepositoryStore, commitID, rootDir string) ([]*diag.Diagnostic, int, error) {
+	var diags []*diag.Diagnostic
+	problem := func(severity diag.Severity, path, title, format string, args ...interface{}) {
+		diags = append(diags, &diag.Diagnostic{
+			Severity: severity,
+			Title:    title,
+			Message:  s.FilePath(commitID, path) + ": " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	dataFiles, err := s.DataFilesForCommit(commitID)
+	if err != nil {
+		return nil, 0, err
+	}
+	isDataFile := make(map[string]bool, len(dataFiles))
+	for _, f := range dataFiles {
+		isDataFile[filepath.ToSlash(f.Path)] = true
+	}
+
+	// Files stored by content or as deltas must have the hash that they
+	// were stored with. The pieces of chunked files are checked here;
+	// whole files are checked below, along with the manifest's hashes.
+	wantHash := map[string]string{}
+	stored, err := s.StoredFiles(commitID)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, f := range stored {
+		if f.Hash == "" {
+			continue
+		}
+		if isDataFile[f.Path] {
+			wantHash[f.Path] = f.Hash
+			continue
+		}
+		r, err := s.OpenStored(s.FilePath(commitID, f.Path))
+		if err != nil {
+			problem(diag.Error, f.Path, "unreadable artifact", "%s", err)
+			continue
+		}
+		hash, err := buildstore.HashBlob(r)
+		r.Close()
+		if err != nil {
+			problem(diag.Error, f.Path, "unreadable artifact", "%s", err)
+		} else if hash != f.Hash {
+			problem(diag.Error, f.Path, "corrupt artifact", "contents have hash %s, but were stored with hash %s", hash, f.Hash)
+		}
+	}
+
+	wantSize := map[string]int64{}
+	m, err := buildmanifest.Read(s, commitID)
+	if os.IsNotExist(err) {
+		problem(diag.Warning, buildmanifest.Filename, "no build manifest", "the commit has no build manifest, so only how its files are stored can be verified")
+	} else if err != nil {
+		problem(diag.Error, buildmanifest.Filename, "invalid build manifest", "%s", err)
+	} else {
+		for _, f := range m.AllFiles() {
+			if !isDataFile[f.Path] {
+				problem(diag.Error, f.Path, "missing artifact", "the build manifest lists the file, but it doesn't exist (was it only partially uploaded?)")
+				continue
+			}
+			if h, ok := wantHash[f.Path]; ok && h != f.Hash {
+				problem(diag.Error, f.Path, "corrupt artifact", "the file was stored with hash %s, but the build manifest records hash %s", h, f.Hash)
+			}
+			wantHash[f.Path], wantSize[f.Path] = f.Hash, f.Size
+		}
+	}
+
+	for _, df := range dataFiles {
+		path := filepath.ToSlash(df.Path)
+		if !c.verifyFile(s, commitID, path, df.Size, wantHash[path], wantSize, problem) {
+			continue
+		}
+		if df.DataType == "" {
+			continue
+		}
+		dataDiags, err := c.verifyData(s, commitID, path, df.DataType, rootDir)
+		if err != nil {
+			problem(diag.Error, path, "invalid build data", "can't decode %s data: %s", df.DataType, err)
+		}
+		diags = append(diags, dataDiags...)
+	}
+	return diags, len(dataFiles), nil
+}
+
+// verifyFile reads the build data file at path in full, and checks its size
+// (against that which s reports, statSize, and that in wantSize, if any) and
+// its hash (against wantHash, if non-empty). It reports the problems it
+// finds to problem, and returns whether the file is intact.
+func (c *VerifyCmd) verifyFile(s *buildstore.RepositoryStore, commitID, path string, statSize int64, wantHash string, wantSize map[string]int64, problem func(diag.Severity, string, string, string, ...interface{})) bool {
+	f, err := s.Open(s.FilePath(commitID, path))
+	if err != nil {
+		problem(diag.Error, path, "unreadable artifact", "%s", err)
+		return false
+	}
+	defer f.Close()
+	var n byteCount
+	hash, err := buildstore.HashBlob(io.TeeReader(f, &n))
+	if err != nil {
+		problem(diag.Error, path, "unreadable artifact", "%s (after reading %d of %d bytes; was it only partially uploaded?)", err, n, statSize)
+		return false
+	}
+	ok := true
+	if int64(n) != statSize {
+		problem(diag.Error, path, "truncated artifact", "read %d bytes, but the file is stored as %d bytes", n, statSize)
+		ok = false
+	}
+	if size, present := wantSize[path]; present && int64(n) != size {
+		problem(diag.Error, path, "corrupt artifact", "read %d bytes, but the build manifest records %d bytes", n, size)
+		ok = false
+	}
+	if wantHash != "" && hash != wantHash {
+		problem(diag.Error, path, "corrupt artifact", "contents have hash %s, not %s", hash, wantHash)
+		ok = false
+	}
+	return ok
+}
+
+// verifyData checks that the build data file at path decodes as its data
+// type (dataTypeName). Graph data is only checked for well-formedness
+// (without holding it in memory), and, if c.Graph is set, the validity of
+// its refs.
+func (c *VerifyCmd) verifyData(s *buildstore.RepositoryStore, commitID, path, dataTypeName, rootDir string) ([]*diag.Diagnostic, error) {
+	f, err := s.Open(s.FilePath(commitID, path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	emptyInstance := buildstore.DataTypes[dataTypeName]
+	if _, isGraph := emptyInstance.(*grapher.Output); isGraph {
+		if c.Graph {
+			return validateGraphRefs(f, rootDir)
+		}
+		return nil, grapher.StreamOutput(f, grapher.OutputHandlers{})
+	}
+
+	v := reflect.New(reflect.TypeOf(emptyInstance))
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(v.Interface()); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the %s data", dataTypeName)
+	}
+	return nil, nil
+}
+
+// byteCount is an io.Writer that counts the bytes written to it.
+type byteCount int64
+
+func (n *byteCount) Write(p []byte) (int, error) {
+	*n += byteCount(len(p))
+	return len(p), nil
+}
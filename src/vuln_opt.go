@@ -0,0 +1,132 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/diag"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+	"sourcegraph.com/sourcegraph/srclib/vuln"
+)
+
+type VulnOpt struct {
+	Vulns       bool   `long:"vulns" description:"after building, match the versions of resolved dependencies against OSV security advisories"`
+	VulnDB      string `long:"vuln-db" description:"read advisories from the OSV JSON files in DIR instead of the (downloaded and cached) OSV database" value-name:"DIR"`
+	VulnOffline bool   `long:"vuln-offline" description:"only use previously downloaded advisories"`
+}
+
+// checkVulns matches the resolved dependencies in the current repository's
+// build data against advisories, prints and saves the findings (see
+// vuln.Filename), and returns them as diagnostics.
+func (o *VulnOpt) checkVulns() ([]*diag.Diagnostic, error) {
+	repo, units, deps, err := readResolvedDeps()
+	if err != nil {
+		return nil, err
+	}
+
+	var db *vuln.DB
+	if o.VulnDB != "" {
+		db = vuln.NewDB()
+		if err := db.ReadDir(o.VulnDB); err != nil {
+			return nil, err
+		}
+	} else {
+		ecosystems := map[string]bool{}
+		for _, d := range deps {
+			if eco, ok := vuln.Ecosystems[d.ToUnitType]; ok && d.ToVersionString != "" {
+				ecosystems[eco] = true
+			}
+		}
+		var ecoList []string
+		for eco := range ecosystems {
+			ecoList = append(ecoList, eco)
+		}
+		sort.Strings(ecoList)
+
+		cache := vuln.DefaultCache()
+		cache.Offline = o.VulnOffline
+		if db, err = cache.Load(ecoList); err != nil {
+			return nil, err
+		}
+	}
+
+	findings := db.Match(deps)
+	printVulnReport(os.Stderr, findings)
+
+	buildStore, err := buildstore.NewRepositoryStore(repo.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	w, err := buildStore.Create(buildStore.FilePath(repo.CommitID, vuln.Filename))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if findings == nil {
+		findings = []*vuln.Finding{}
+	}
+	if err := json.NewEncoder(w).Encode(findings); err != nil {
+		return nil, err
+	}
+
+	return vulnDiagnostics(units, findings), nil
+}
+
+// vulnDiagnostics returns a diagnostic for each finding. High and critical
+// severity findings are errors; others are warnings.
+func vulnDiagnostics(units []*unit.SourceUnit, findings []*vuln.Finding) []*diag.Diagnostic {
+	byID := make(map[[2]string]*unit.SourceUnit, len(units))
+	for _, u := range units {
+		byID[[2]string{u.Name, u.Type}] = u
+	}
+
+	var diags []*diag.Diagnostic
+	for _, f := range findings {
+		msg := fmt.Sprintf("depends on %s %s, which is affected by %s", f.Package, f.Version, vulnName(f))
+		if f.Summary != "" {
+			msg += ": " + f.Summary
+		}
+		if f.Fixed != "" {
+			msg += fmt.Sprintf(" (fixed in %s)", f.Fixed)
+		}
+		title := "vulnerable dependency " + f.Package
+		var d *diag.Diagnostic
+		if u, present := byID[[2]string{f.FromUnit, f.FromUnitType}]; present {
+			d = unitDiagnostic(u, title, msg)
+		} else {
+			d = &diag.Diagnostic{Title: title, Message: fmt.Sprintf("%s %s: %s", f.FromUnitType, f.FromUnit, msg)}
+		}
+		d.Severity = diag.Warning
+		if s := strings.ToUpper(f.Severity); s == "HIGH" || s == "CRITICAL" {
+			d.Severity = diag.Error
+		}
+		d.Rule = f.Advisory
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// vulnName returns the advisory's ID and aliases (e.g., "GHSA-xxxx
+// (CVE-2020-1234)").
+func vulnName(f *vuln.Finding) string {
+	if len(f.Aliases) == 0 {
+		return f.Advisory
+	}
+	return f.Advisory + " (" + strings.Join(f.Aliases, ", ") + ")"
+}
+
+func printVulnReport(w io.Writer, findings []*vuln.Finding) {
+	fmtStr := "%-40s  %-40s  %-12s  %-20s  %s\n"
+	if len(findings) > 0 {
+		fmt.Fprintf(w, fmtStr, "UNIT", "DEPENDENCY", "VERSION", "ADVISORY", "FIXED IN")
+	}
+	for _, f := range findings {
+		fmt.Fprintf(w, fmtStr, f.FromUnit+" ("+f.FromUnitType+")", f.Package, f.Version, f.Advisory, f.Fixed)
+	}
+	fmt.Fprintf(w, "%d vulnerable dependencies\n", len(findings))
+}
@@ -0,0 +1,267 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/config"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+	"sourcegraph.com/sourcegraph/srclib/workspace"
+)
+
+func init() {
+	c, err := CLI.AddCommand("workspace",
+		"build and query several repositories together",
+		`Build and query the repository roots listed in a workspace manifest (a file named Srcworkspace, in JSON) together, such as an app and checkouts of the libraries that it depends on. Dependencies on the repositories of other roots use the roots' working trees (instead of cloning the repositories), and "src api describe" and "src api refs" find defs and refs in the other roots' build data.`,
+		&workspaceCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("make",
+		"configure and build each root of the workspace",
+		`Configure and build (as "src config" and "src make" do) each root of the workspace, in the order that they are listed in the manifest.`,
+		&workspaceMakeCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = c.AddCommand("list",
+		"list the roots of the workspace",
+		`List the directory and repository URI of each root of the workspace.`,
+		&workspaceListCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type WorkspaceOpt struct {
+	Workspace string `short:"w" long:"workspace" description:"workspace manifest (default: the Srcworkspace file in the current directory or its nearest parent)" value-name:"FILE"`
+}
+
+// read reads the workspace manifest named by the options (or found by
+// workspace.Find), and fills in the roots' repository URIs.
+func (o *WorkspaceOpt) read() (*workspace.Workspace, error) {
+	file := o.Workspace
+	if file == "" {
+		var err error
+		if file, err = workspace.Find("."); err != nil {
+			return nil, err
+		}
+		if file == "" {
+			return nil, withKind(UsageError, fmt.Errorf("no %s found in the current directory or its parents (use --workspace to specify the manifest)", workspace.Filename))
+		}
+	}
+	return readWorkspace(file)
+}
+
+// readWorkspace reads the workspace manifest in file, and fills in the
+// URIs of the roots' repositories that it doesn't specify from their VCS
+// remotes.
+func readWorkspace(file string) (*workspace.Workspace, error) {
+	w, err := workspace.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[repo.URI]string, len(w.Roots))
+	for _, r := range w.Roots {
+		if r.Repo == "" {
+			rr, err := OpenRepo(r.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("workspace root %s: %s", r.Dir, err)
+			}
+			r.Repo = rr.URI()
+		}
+		if dir, present := seen[r.Repo]; present {
+			return nil, fmt.Errorf("%s: workspace roots %s and %s are both of repository %s", file, dir, r.Dir, r.Repo)
+		}
+		seen[r.Repo] = r.Dir
+	}
+	return w, nil
+}
+
+// currentWorkspace returns the workspace that the repository rooted at
+// repoDir is a root of, or nil if there is none. The workspace's manifest is
+// the one named by the workspace.Env environment variable (which `src
+// workspace make` sets for the processes of its builds), or else the
+// nearest Srcworkspace file in repoDir or its parents.
+func currentWorkspace(repoDir string) (*workspace.Workspace, error) {
+	file := os.Getenv(workspace.Env)
+	if file == "" {
+		var err error
+		if file, err = workspace.Find(repoDir); err != nil || file == "" {
+			return nil, err
+		}
+	}
+	w, err := readWorkspace(file)
+	if err != nil {
+		return nil, err
+	}
+	if w.RootAt(repoDir) == nil {
+		if verbose() {
+			log.Printf("Ignoring workspace %s, which doesn't have the repository in %s as a root.", w.File, repoDir)
+		}
+		return nil, nil
+	}
+	return w, nil
+}
+
+// openWorkspaceRoot opens the repository and build store of a workspace
+// root.
+func openWorkspaceRoot(r *workspace.Root) (*Repo, *buildstore.RepositoryStore, error) {
+	rr, err := OpenRepo(r.Dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("workspace root %s: %s", r.Dir, err)
+	}
+	buildStore, err := buildstore.NewRepositoryStore(rr.RootDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rr, buildStore, nil
+}
+
+// openBuiltWorkspaceRoot is like openWorkspaceRoot, but returns nil (and
+// logs a warning) if the root's current commit hasn't been built.
+func openBuiltWorkspaceRoot(r *workspace.Root) (*Repo, *buildstore.RepositoryStore, error) {
+	rr, buildStore, err := openWorkspaceRoot(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := buildStore.Stat(buildStore.CommitPath(rr.CommitID)); os.IsNotExist(err) {
+		log.Printf("Warning: ignoring workspace root %s, whose commit %s hasn't been built (run `src workspace make`).", r.Dir, rr.CommitID)
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return rr, buildStore, nil
+}
+
+// localDefRepo returns the repository (and its build store) that has the
+// build data of the repository with the given URI: the current repository
+// r, or another root of its workspace. If there is none (or it hasn't been
+// built), it returns nil.
+func localDefRepo(buildStore *buildstore.RepositoryStore, r *Repo, uri repo.URI) (*Repo, *buildstore.RepositoryStore, error) {
+	if uri == r.URI() {
+		return r, buildStore, nil
+	}
+	w, err := currentWorkspace(r.RootDir)
+	if err != nil || w == nil {
+		return nil, nil, err
+	}
+	root := w.Root(uri)
+	if root == nil {
+		return nil, nil, nil
+	}
+	return openBuiltWorkspaceRoot(root)
+}
+
+type WorkspaceCmd struct{}
+
+var workspaceCmd WorkspaceCmd
+
+func (c *WorkspaceCmd) Execute(args []string) error { return nil }
+
+type WorkspaceMakeCmd struct {
+	WorkspaceOpt
+
+	ToolchainExecOpt `group:"execution"`
+	ExecLimitOpt     `group:"execution limits"`
+	BuildCacheOpt    `group:"build cache"`
+
+	KeepGoing bool `short:"k" long:"keep-going" description:"build the remaining roots after a root's build fails"`
+}
+
+var workspaceMakeCmd WorkspaceMakeCmd
+
+func (c *WorkspaceMakeCmd) Execute(args []string) error {
+	w, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	// Tell the processes of each root's build (in particular, `src
+	// internal dep-sources`) about the other roots.
+	if err := os.Setenv(workspace.Env, w.File); err != nil {
+		return err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+
+	var failed []string
+	for _, r := range w.Roots {
+		log.Printf("Building workspace root %s (%s).", r.Dir, r.Repo)
+		if err := c.makeRoot(r); err != nil {
+			if !c.KeepGoing {
+				return fmt.Errorf("workspace root %s: %s", r.Dir, err)
+			}
+			log.Printf("Warning: failed to build workspace root %s: %s.", r.Dir, err)
+			failed = append(failed, r.Dir)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to build %d of %d workspace roots: %s", len(failed), len(w.Roots), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (c *WorkspaceMakeCmd) makeRoot(r *workspace.Root) error {
+	if err := os.Chdir(r.Dir); err != nil {
+		return err
+	}
+	opt := config.Options{Repo: string(r.Repo), Subdir: "."}
+
+	configCmd := &ConfigCmd{
+		Options:          opt,
+		ToolchainExecOpt: c.ToolchainExecOpt,
+		BuildCacheOpt:    c.BuildCacheOpt,
+	}
+	if err := configCmd.Execute(nil); err != nil {
+		return err
+	}
+
+	makeCmd := &MakeCmd{
+		Options:          opt,
+		ToolchainExecOpt: c.ToolchainExecOpt,
+		ExecLimitOpt:     c.ExecLimitOpt,
+		BuildCacheOpt:    c.BuildCacheOpt,
+	}
+	return makeCmd.Execute(nil)
+}
+
+type WorkspaceListCmd struct {
+	WorkspaceOpt
+
+	Output struct {
+		Output string `short:"o" long:"output" description:"output format" default:"text" value-name:"text|json"`
+	} `group:"output"`
+}
+
+var workspaceListCmd WorkspaceListCmd
+
+func (c *WorkspaceListCmd) Execute(args []string) error {
+	w, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	if c.Output.Output == "json" {
+		PrintJSON(w.Roots, "")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, r := range w.Roots {
+		fmt.Fprintf(tw, "%s\t%s\n", r.Dir, r.Repo)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,257 @@
+// Package textnav guesses where identifiers are defined by matching
+// patterns (like those of ctags) against the source files in a tree. It is
+// a fallback for positions that the graph data doesn't cover (because the
+// build is stale or the file's language has no toolchain), so its results
+// are only approximate.
+package textnav
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/detect"
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+// IdentAt returns the identifier in src at offset (which may be just past
+// its end) and the offset of its start. If there is no identifier there, it
+// returns "".
+func IdentAt(src []byte, offset int) (string, int) {
+	if offset < 0 || offset > len(src) {
+		return "", 0
+	}
+	start, end := offset, offset
+	for start > 0 && isIdentByte(src[start-1]) {
+		start--
+	}
+	for end < len(src) && isIdentByte(src[end]) {
+		end++
+	}
+	if start == end || (src[start] >= '0' && src[start] <= '9') {
+		return "", 0
+	}
+	return string(src[start:end]), start
+}
+
+// isIdentByte returns whether c can be part of an identifier. Non-ASCII
+// bytes are, so that identifiers with non-ASCII letters aren't split.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// A Def is a probable definition of an identifier.
+type Def struct {
+	Name string
+
+	// Kind is the kind of definition that the pattern that matched it
+	// looks for (such as "func" or "type").
+	Kind string
+
+	// File is the path of the file that contains the definition, and
+	// Start and End are the byte offsets of its name in the file.
+	File  string
+	Start int
+	End   int
+
+	// Line is the 1-based number of the line that it is on, and Text is
+	// the line (with surrounding whitespace trimmed).
+	Line int
+	Text string
+}
+
+// A pattern matches a kind of definition. Its expr contains NAME where the
+// defined name is, which is captured by the expression's first group.
+type pattern struct {
+	kind string
+	expr string
+}
+
+// patterns are the patterns for the source files with each extension.
+var patterns = map[string][]pattern{
+	".go": {
+		{"func", `^func\s+(?:\([^)]*\)\s*)?(NAME)\b`},
+		{"type", `^\s*(?:type\s+)?(NAME)\s+(?:struct|interface)\b`},
+		{"type", `^\s*type\s+(NAME)\b`},
+		{"var", `^\s*(?:var|const)\s+(NAME)\b`},
+	},
+	".js": {
+		{"func", `\bfunction\s*\*?\s*(NAME)\s*\(`},
+		{"class", `\bclass\s+(NAME)\b`},
+		{"var", `\b(?:var|let|const)\s+(NAME)\s*=`},
+		{"func", `^\s*(?:[\w$.]+\.)?(NAME)\s*[:=]\s*(?:async\s+)?function\b`},
+	},
+	".py": {
+		{"func", `^\s*(?:async\s+)?def\s+(NAME)\s*\(`},
+		{"class", `^\s*class\s+(NAME)\b`},
+		{"var", `^(NAME)\s*=[^=]`},
+	},
+	".rb": {
+		{"func", `^\s*def\s+(?:self\.)?(NAME)\b`},
+		{"class", `^\s*(?:class|module)\s+(?:\w+::)*(NAME)\b`},
+		{"var", `^\s*(NAME)\s*=[^=]`},
+	},
+}
+
+// otherPatterns are the patterns for source files whose extensions aren't
+// in patterns.
+var otherPatterns = []pattern{
+	{"func", `\b(?:func|function|def|fn|sub|proc)\s+(NAME)\b`},
+	{"type", `\b(?:class|struct|interface|enum|trait|type|module|union)\s+(NAME)\b`},
+	{"macro", `^\s*#\s*define\s+(NAME)\b`},
+}
+
+// maxFileSize is the size of the largest file that FindDefs searches.
+const maxFileSize = 1 << 20
+
+// FindDefs searches the source files in the tree fs for probable
+// definitions of name. Hidden directories and directories with the names in
+// detect.VendorDirs are skipped, as are binary and very large files.
+//
+// The definitions are sorted by how close they are to the file near (the
+// file the identifier was found in): those in near come first, then those
+// in its directory, then the others, in order of their file names and
+// positions. If limit is positive, at most limit definitions are returned.
+func FindDefs(fs vfs.FileSystem, name, near string, limit int) ([]*Def, error) {
+	if name == "" {
+		return nil, nil
+	}
+	regexps := map[string][]*regexp.Regexp{}
+	compile := func(ps []pattern) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, len(ps))
+		for i, p := range ps {
+			var err error
+			if res[i], err = regexp.Compile(`(?m)` + strings.Replace(p.expr, "NAME", regexp.QuoteMeta(name), -1)); err != nil {
+				return nil, fmt.Errorf("pattern %q: %s", p.expr, err)
+			}
+		}
+		return res, nil
+	}
+
+	var defs []*Def
+	err := vfs.Walk(fs, ".", func(file string, fi os.FileInfo) error {
+		if fi.IsDir() {
+			if file != "." && isSkippedDir(fi.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || fi.Size() > maxFileSize {
+			return nil
+		}
+		src, err := fs.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(src, []byte(name)) || isBinary(src) {
+			return nil
+		}
+
+		ext := path.Ext(file)
+		ps, present := patterns[ext]
+		if !present {
+			ps = otherPatterns
+		}
+		if _, compiled := regexps[ext]; !compiled {
+			if regexps[ext], err = compile(ps); err != nil {
+				return err
+			}
+		}
+		seen := map[int]bool{}
+		for i, re := range regexps[ext] {
+			for _, m := range re.FindAllSubmatchIndex(src, -1) {
+				start, end := m[2], m[3]
+				if seen[start] {
+					continue
+				}
+				seen[start] = true
+				defs = append(defs, newDef(src, file, name, ps[i].kind, start, end))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byDistance{defs, path.Clean(near)})
+	if limit > 0 && len(defs) > limit {
+		defs = defs[:limit]
+	}
+	return defs, nil
+}
+
+func newDef(src []byte, file, name, kind string, start, end int) *Def {
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := bytes.IndexByte(src[start:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += start
+	}
+	return &Def{
+		Name:  name,
+		Kind:  kind,
+		File:  file,
+		Start: start,
+		End:   end,
+		Line:  bytes.Count(src[:start], []byte("\n")) + 1,
+		Text:  string(bytes.TrimSpace(src[lineStart:lineEnd])),
+	}
+}
+
+func isSkippedDir(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, v := range detect.VendorDirs {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary returns whether src looks like the contents of a binary file
+// (i.e., whether it has a NUL byte near its start).
+func isBinary(src []byte) bool {
+	if len(src) > 8000 {
+		src = src[:8000]
+	}
+	return bytes.IndexByte(src, 0) != -1
+}
+
+type byDistance struct {
+	defs []*Def
+	near string
+}
+
+func (v byDistance) Len() int      { return len(v.defs) }
+func (v byDistance) Swap(i, j int) { v.defs[i], v.defs[j] = v.defs[j], v.defs[i] }
+func (v byDistance) Less(i, j int) bool {
+	a, b := v.defs[i], v.defs[j]
+	if da, db := v.distance(a.File), v.distance(b.File); da != db {
+		return da < db
+	}
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	return a.Start < b.Start
+}
+
+// distance returns 0 if file is v.near, 1 if it is in the same directory,
+// and 2 otherwise.
+func (v byDistance) distance(file string) int {
+	switch {
+	case file == v.near:
+		return 0
+	case path.Dir(file) == path.Dir(v.near):
+		return 1
+	}
+	return 2
+}
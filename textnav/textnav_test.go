@@ -0,0 +1,85 @@
+package textnav
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/vfs"
+)
+
+func TestIdentAt(t *testing.T) {
+	src := []byte("x := foo.Bar_2(1)")
+	tests := map[int]struct {
+		name  string
+		start int
+	}{
+		0:  {"x", 0},
+		1:  {"x", 0},
+		2:  {"", 0},
+		5:  {"foo", 5},
+		10: {"Bar_2", 9},
+		15: {"", 0}, // a number
+		17: {"", 0},
+		18: {"", 0}, // out of range
+	}
+	for offset, want := range tests {
+		if name, start := IdentAt(src, offset); name != want.name || start != want.start {
+			t.Errorf("offset %d: got %q at %d, want %q at %d", offset, name, start, want.name, want.start)
+		}
+	}
+}
+
+func TestFindDefs(t *testing.T) {
+	fs := vfs.Map(map[string]string{
+		"a/a.go":                   "package a\n\nfunc Foo() {}\n\nfunc (t *T) Foo() {}\n",
+		"a/b.go":                   "package a\n\ntype Foo struct{}\n\nvar x = Foo{}\n",
+		"c/c.py":                   "class Foo:\n    def bar(self):\n        return Foo()\n",
+		"d/d.c":                    "struct Foo { int x; };\n",
+		"vendor/v.go":              "package v\n\nfunc Foo() {}\n",
+		".hidden/h.go":             "package h\n\nfunc Foo() {}\n",
+		"node_modules/m/index.js":  "function Foo() {}\n",
+		"e/binary.go":              "func Foo() {}\x00",
+		"f/not_a_def.js":           "Foo();\n",
+		"g/longer_name.py":         "def FooBar():\n    pass\n",
+		"h/assignment_not_def.rb":  "if Foo == 1\nend\n",
+		"i/functions_and_vars.js":  "var Foo = 1;\nexports.Foo = function() {};\n",
+		"j/grouped_types.go":       "package j\n\ntype (\n\tFoo struct{}\n)\n",
+		"k/ruby_module_methods.rb": "module A::Foo\n  def self.Foo\n  end\nend\n",
+	})
+	defs, err := FindDefs(fs, "Foo", "a/b.go", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type loc struct {
+		File string
+		Line int
+		Kind string
+	}
+	var got []loc
+	for _, d := range defs {
+		got = append(got, loc{d.File, d.Line, d.Kind})
+	}
+	want := []loc{
+		{"a/b.go", 3, "type"},
+		{"a/a.go", 3, "func"},
+		{"a/a.go", 5, "func"},
+		{"c/c.py", 1, "class"},
+		{"d/d.c", 1, "type"},
+		{"i/functions_and_vars.js", 1, "var"},
+		{"i/functions_and_vars.js", 2, "func"},
+		{"j/grouped_types.go", 4, "type"},
+		{"k/ruby_module_methods.rb", 1, "class"},
+		{"k/ruby_module_methods.rb", 2, "func"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got defs %+v, want %+v", got, want)
+	}
+
+	if d := defs[0]; d.Name != "Foo" || d.Text != "type Foo struct{}" || string("package a\n\ntype Foo struct{}\n"[d.Start:d.End]) != "Foo" {
+		t.Errorf("got def %+v", d)
+	}
+
+	if defs, err := FindDefs(fs, "Foo", "a/b.go", 2); err != nil || len(defs) != 2 {
+		t.Errorf("got %d defs (err %v) with limit 2, want 2", len(defs), err)
+	}
+}
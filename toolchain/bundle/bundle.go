@@ -0,0 +1,442 @@
+// Package bundle implements toolchain bundles, the packaged format in which
+// toolchains are distributed (instead of as repositories that must be
+// cloned and built).
+//
+// A bundle is a gzipped tar file. Its first entry is a manifest (named
+// Srclibbundle) that describes the toolchain (its path, version, and the
+// platforms it runs on) and lists the SHA-256 hash of each of the other
+// files in the bundle, which are the files of the toolchain directory
+// (its Srclibtoolchain, its program in .bin/, its Dockerfile, and any other
+// files they need). Installing a bundle verifies every file against the
+// manifest before the toolchain is put in place.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+)
+
+// ManifestFilename is the name of the manifest in bundles (and in the
+// directories of toolchains installed from bundles).
+const ManifestFilename = "Srclibbundle"
+
+// Limits on the bundles that Install reads, which protect against
+// decompression bombs.
+var (
+	// MaxManifestSize is the maximum size of a bundle's manifest, in bytes.
+	MaxManifestSize int64 = 1 << 20
+
+	// MaxSize is the maximum total size of the (uncompressed) files in a
+	// bundle, in bytes.
+	MaxSize int64 = 1 << 30
+)
+
+// A Manifest describes a bundled toolchain.
+type Manifest struct {
+	// Path is the toolchain path.
+	Path string
+
+	// Version is the version of the toolchain (free-form, e.g., "1.2.0").
+	Version string `json:",omitempty"`
+
+	// Platforms are the platforms (as "GOOS/GOARCH", e.g., "linux/amd64")
+	// that the toolchain's program runs on. If empty, the toolchain runs on
+	// all platforms (e.g., because its program is a script, or because it is
+	// only run as a Docker container).
+	Platforms []string `json:",omitempty"`
+
+	// Files maps the path (relative to the toolchain directory) of each
+	// file in the bundle, other than the manifest, to the SHA-256 hash of
+	// its contents.
+	Files map[string]string
+}
+
+// Platform is the current platform, as "GOOS/GOARCH".
+var Platform = runtime.GOOS + "/" + runtime.GOARCH
+
+// Supports returns whether the bundled toolchain runs on platform (as
+// "GOOS/GOARCH").
+func (m *Manifest) Supports(platform string) bool {
+	if len(m.Platforms) == 0 {
+		return true
+	}
+	for _, p := range m.Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error if m is not a valid manifest.
+func (m *Manifest) Validate() error {
+	if !validRelPath(m.Path) {
+		return fmt.Errorf("invalid toolchain path %q in bundle manifest", m.Path)
+	}
+	for _, p := range m.Platforms {
+		if parts := strings.Split(p, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid platform %q in bundle manifest (must be GOOS/GOARCH, e.g., linux/amd64)", p)
+		}
+	}
+	if _, present := m.Files[toolchain.ConfigFilename]; !present {
+		return fmt.Errorf("bundle manifest for %s doesn't list a %s file", m.Path, toolchain.ConfigFilename)
+	}
+	for name, h := range m.Files {
+		if !validRelPath(name) || name == ManifestFilename {
+			return fmt.Errorf("invalid file name %q in bundle manifest", name)
+		}
+		if b, err := hex.DecodeString(h); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid SHA-256 hash %q of file %s in bundle manifest", h, name)
+		}
+	}
+	return nil
+}
+
+// validRelPath returns whether p is a clean, slash-separated relative path
+// that stays within its base directory.
+func validRelPath(p string) bool {
+	return p != "" && p != "." && path.Clean(p) == p && !path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../") && !strings.Contains(p, `\`)
+}
+
+// Create writes a bundle of the toolchain in dir to w. The manifest's Files
+// are computed from dir (whose .git directory, if any, is omitted); its
+// other fields are taken from m. Bundles may only contain regular files and
+// directories (not symlinks). If key is non-nil, the bundle is signed with
+// it.
+func Create(w io.Writer, dir string, m Manifest, key *PrivateKey) error {
+	var files []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+			return nil
+		case fi.IsDir() && rel == ".git":
+			return filepath.SkipDir
+		case fi.IsDir():
+			return nil
+		case rel == ManifestFilename || rel == SignatureFilename:
+			return nil // from the bundle the toolchain was installed from
+		case !fi.Mode().IsRegular():
+			return fmt.Errorf("can't bundle %s: only regular files and directories can be bundled", p)
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	m.Files = make(map[string]string, len(files))
+	for _, f := range files {
+		h, err := HashFile(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+		m.Files[f] = h
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: ManifestFilename, Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+	if key != nil {
+		sig, err := json.Marshal(key.Sign(manifest))
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: SignatureFilename, Mode: 0644, Size: int64(len(sig)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(sig); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := addFile(tw, filepath.Join(dir, filepath.FromSlash(f)), f); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func addFile(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	mode := int64(0644)
+	if fi.Mode().Perm()&0111 != 0 {
+		mode = 0755
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: fi.Size(), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, f, fi.Size()); err != nil {
+		return fmt.Errorf("%s changed while it was being bundled: %s", file, err)
+	}
+	return nil
+}
+
+// Install reads a bundle from r and installs its toolchain in the
+// directory srclibDir/PATH (where PATH is the toolchain path), replacing
+// any toolchain already there. Before anything is installed, it checks that
+// the toolchain supports the current platform and that the bundle's files
+// match its manifest exactly: every listed file is present with the listed
+// hash, and there are no other files. If v is non-nil, it also checks the
+// bundle's signature according to v's policy. The manifest (and signature,
+// if any) are also installed (as ManifestFilename and SignatureFilename), to
+// record the version of the installed toolchain and so that it can be
+// verified when it is run.
+func Install(r io.Reader, srclibDir string, v *Verifier) (*Manifest, error) {
+	return install(r, srclibDir, v, nil)
+}
+
+// Upgrade is like Install, but after it replaces the existing toolchain, it
+// calls check with the new toolchain's manifest and directory. If check
+// returns an error, the new toolchain is removed and the previous one (if
+// any) is restored.
+func Upgrade(r io.Reader, srclibDir string, v *Verifier, check func(m *Manifest, dir string) error) (*Manifest, error) {
+	return install(r, srclibDir, v, check)
+}
+
+func install(r io.Reader, srclibDir string, v *Verifier, check func(m *Manifest, dir string) error) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %s", err)
+	}
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %s", err)
+	}
+	if hdr.Name != ManifestFilename || hdr.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("invalid bundle: first entry is %q, not the manifest (%s)", hdr.Name, ManifestFilename)
+	}
+	if hdr.Size > MaxManifestSize {
+		return nil, fmt.Errorf("invalid bundle: manifest is larger than the maximum size (%d bytes)", MaxManifestSize)
+	}
+	manifestData, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle manifest: %s", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifestData, &m); err != nil {
+		return nil, fmt.Errorf("reading bundle manifest: %s", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if !m.Supports(Platform) {
+		return nil, fmt.Errorf("toolchain %s (version %q) doesn't support this platform (%s); it supports %s", m.Path, m.Version, Platform, strings.Join(m.Platforms, ", "))
+	}
+
+	if hdr, err = nextEntry(tr); err != nil {
+		return nil, fmt.Errorf("reading bundle: %s", err)
+	}
+	var sig *Signature
+	var sigData []byte
+	if hdr != nil && hdr.Name == SignatureFilename {
+		if sigData, err = ioutil.ReadAll(io.LimitReader(tr, MaxManifestSize)); err != nil {
+			return nil, fmt.Errorf("reading bundle signature: %s", err)
+		}
+		if err := json.Unmarshal(sigData, &sig); err != nil || sig == nil {
+			return nil, fmt.Errorf("invalid bundle signature: %v", err)
+		}
+		if hdr, err = nextEntry(tr); err != nil {
+			return nil, fmt.Errorf("reading bundle: %s", err)
+		}
+	}
+	if err := v.checkSignature("toolchain "+m.Path, manifestData, sig, true); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(srclibDir, filepath.FromSlash(m.Path))
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), "."+filepath.Base(dir)+".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := extract(tr, hdr, &m, tmpDir); err != nil {
+		return nil, fmt.Errorf("invalid bundle of toolchain %s: %s", m.Path, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, ManifestFilename), manifestData, 0644); err != nil {
+		return nil, err
+	}
+	if sigData != nil {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, SignatureFilename), sigData, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the existing toolchain, if any.
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return nil, err
+	}
+	old := tmpDir + ".old"
+	if err := os.Rename(dir, old); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		os.Rename(old, dir)
+		return nil, err
+	}
+	if check != nil {
+		if err := check(&m, dir); err != nil {
+			// Roll back to the previous toolchain. The new one is moved back
+			// to tmpDir, which is removed.
+			if err := os.Rename(dir, tmpDir); err != nil {
+				return nil, fmt.Errorf("toolchain %s (version %q) failed its check, and rolling back failed: %s", m.Path, m.Version, err)
+			}
+			if err := os.Rename(old, dir); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("toolchain %s (version %q) failed its check, and restoring the previous version (from %s) failed: %s", m.Path, m.Version, old, err)
+			}
+			return nil, fmt.Errorf("toolchain %s (version %q) failed its check (rolled back to the previous version): %s", m.Path, m.Version, err)
+		}
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// extract extracts the files in tr (starting with hdr, the first entry
+// after the manifest and signature, or nil if there are none) into dir,
+// checking that they match m.Files.
+func extract(tr *tar.Reader, hdr *tar.Header, m *Manifest, dir string) error {
+	seen := map[string]bool{}
+	var size int64
+	var err error
+	for ; hdr != nil; hdr, err = nextEntry(tr) {
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		name := hdr.Name
+		want, listed := m.Files[name]
+		switch {
+		case !listed:
+			return fmt.Errorf("file %q is not listed in the manifest", name)
+		case seen[name]:
+			return fmt.Errorf("file %s appears more than once", name)
+		case hdr.Typeflag != tar.TypeReg:
+			return fmt.Errorf("file %s is not a regular file", name)
+		}
+		seen[name] = true
+		if size += hdr.Size; size > MaxSize {
+			return fmt.Errorf("files are larger than the maximum size (%d bytes)", MaxSize)
+		}
+
+		var buf bytes.Buffer
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(&buf, h), tr); err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return fmt.Errorf("file %s has SHA-256 hash %s, but the manifest lists %s", name, got, want)
+		}
+		mode := os.FileMode(0644)
+		if hdr.Mode&0111 != 0 {
+			mode = 0755
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, buf.Bytes(), mode); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for name := range m.Files {
+		if !seen[name] {
+			return fmt.Errorf("file %s is listed in the manifest but missing", name)
+		}
+	}
+	return nil
+}
+
+// nextEntry returns the next entry in tr, or nil if there are no more.
+func nextEntry(tr *tar.Reader) (*tar.Header, error) {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return hdr, err
+}
+
+// Installed returns the manifest of the bundle that the toolchain in dir
+// was installed from, or nil if it wasn't installed from a bundle.
+func Installed(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// HashFile returns the hex-encoded SHA-256 hash of the file's contents.
+func HashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,298 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0644)
+		if strings.HasPrefix(name, ".bin/") {
+			mode = 0755
+		}
+		if err := ioutil.WriteFile(p, []byte(data), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hash(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func TestCreateAndInstall(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tcDir, srclibDir := filepath.Join(tmp, "tc"), filepath.Join(tmp, "srclibpath")
+	files := map[string]string{
+		"Srclibtoolchain": `{"Tools":[]}`,
+		".bin/foo":        "#!/bin/sh\n",
+		"lib/a.txt":       "a",
+		".git/HEAD":       "ref: refs/heads/master\n",
+	}
+	writeFiles(t, tcDir, files)
+
+	var buf bytes.Buffer
+	if err := Create(&buf, tcDir, Manifest{Path: "example.com/foo", Version: "1.0"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Install over an existing toolchain, whose files should be removed.
+	writeFiles(t, filepath.Join(srclibDir, "example.com/foo"), map[string]string{"old": "x"})
+	m, err := Install(bytes.NewReader(buf.Bytes()), srclibDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string]string{
+		"Srclibtoolchain": hash(files["Srclibtoolchain"]),
+		".bin/foo":        hash(files[".bin/foo"]),
+		"lib/a.txt":       hash(files["lib/a.txt"]),
+	}
+	if want := (&Manifest{Path: "example.com/foo", Version: "1.0", Files: wantFiles}); !reflect.DeepEqual(m, want) {
+		t.Errorf("got manifest %+v, want %+v", m, want)
+	}
+
+	dir := filepath.Join(srclibDir, "example.com/foo")
+	var got []string
+	filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if want := []string{".bin/foo", "Srclibbundle", "Srclibtoolchain", "lib/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got installed files %v, want %v", got, want)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, ".bin/foo")); err != nil || fi.Mode().Perm()&0111 == 0 {
+		t.Errorf("installed program is not executable (%v, %v)", fi, err)
+	}
+	if installed, err := Installed(dir); err != nil || !reflect.DeepEqual(installed, m) {
+		t.Errorf("got installed manifest %+v (error %v), want %+v", installed, err, m)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Dir(dir)); len(entries) != 1 {
+		t.Errorf("got %d entries in %s, want only the toolchain (no leftover temporary dirs)", len(entries), filepath.Dir(dir))
+	}
+
+	// Bundling an installed toolchain omits its installed manifest.
+	buf.Reset()
+	if err := Create(&buf, dir, Manifest{Path: "example.com/foo", Version: "1.1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Install(&buf, srclibDir, nil); err != nil || m.Version != "1.1" || len(m.Files) != 3 {
+		t.Errorf("got manifest %+v (error %v) reinstalling, want version 1.1 with 3 files", m, err)
+	}
+}
+
+func TestCreate_notToolchain(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	writeFiles(t, tmp, map[string]string{"a": "a"})
+	if err := Create(ioutil.Discard, tmp, Manifest{Path: "example.com/foo"}, nil); err == nil || !strings.Contains(err.Error(), "doesn't list a Srclibtoolchain") {
+		t.Errorf("got error %v, want missing Srclibtoolchain error", err)
+	}
+}
+
+// makeBundle returns a bundle whose manifest is m and whose entries are
+// files, in order.
+func makeBundle(t *testing.T, m interface{}, files ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.WriteHeader(&tar.Header{Name: ManifestFilename, Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg})
+	tw.Write(manifest)
+	for _, hdr := range files {
+		data := hdr.Linkname // the file's contents
+		hdr.Linkname = ""
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(data))
+		}
+		hdr.Mode = 0644
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestInstall_invalid(t *testing.T) {
+	cfg := `{"Tools":[]}`
+	files := map[string]string{"Srclibtoolchain": hash(cfg)}
+	manifest := func(platforms ...string) *Manifest {
+		return &Manifest{Path: "example.com/foo", Platforms: platforms, Files: files}
+	}
+	tests := map[string]struct {
+		bundle  []byte
+		wantErr string
+	}{
+		"ok": {
+			bundle: makeBundle(t, manifest(), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}),
+		},
+		"this platform": {
+			bundle: makeBundle(t, manifest("plan9/mips", Platform), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}),
+		},
+		"not gzipped": {
+			bundle:  []byte("hello"),
+			wantErr: "reading bundle",
+		},
+		"manifest not first": {
+			bundle: func() []byte {
+				var buf bytes.Buffer
+				gw := gzip.NewWriter(&buf)
+				tw := tar.NewWriter(gw)
+				tw.WriteHeader(&tar.Header{Name: "Srclibtoolchain", Mode: 0644, Size: int64(len(cfg)), Typeflag: tar.TypeReg})
+				tw.Write([]byte(cfg))
+				tw.Close()
+				gw.Close()
+				return buf.Bytes()
+			}(),
+			wantErr: `first entry is "Srclibtoolchain"`,
+		},
+		"other platform": {
+			bundle:  makeBundle(t, manifest("plan9/mips"), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}),
+			wantErr: "doesn't support this platform",
+		},
+		"invalid platform": {
+			bundle:  makeBundle(t, manifest("linux"), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}),
+			wantErr: "invalid platform",
+		},
+		"invalid path": {
+			bundle:  makeBundle(t, &Manifest{Path: "../foo", Files: files}),
+			wantErr: "invalid toolchain path",
+		},
+		"invalid file name": {
+			bundle:  makeBundle(t, &Manifest{Path: "example.com/foo", Files: map[string]string{"Srclibtoolchain": hash(cfg), "../x": hash("")}}),
+			wantErr: `invalid file name "../x"`,
+		},
+		"wrong hash": {
+			bundle:  makeBundle(t, manifest(), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg + " "}),
+			wantErr: "file Srclibtoolchain has SHA-256 hash",
+		},
+		"missing file": {
+			bundle:  makeBundle(t, manifest()),
+			wantErr: "listed in the manifest but missing",
+		},
+		"unlisted file": {
+			bundle:  makeBundle(t, manifest(), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}, &tar.Header{Name: "../../evil", Linkname: "x"}),
+			wantErr: `file "../../evil" is not listed`,
+		},
+		"duplicate file": {
+			bundle:  makeBundle(t, manifest(), &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}, &tar.Header{Name: "Srclibtoolchain", Linkname: cfg}),
+			wantErr: "appears more than once",
+		},
+		"symlink": {
+			bundle:  makeBundle(t, manifest(), &tar.Header{Name: "Srclibtoolchain", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}),
+			wantErr: "not a regular file",
+		},
+	}
+	for name, test := range tests {
+		tmp, err := ioutil.TempDir("", "srclib-bundle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Install(bytes.NewReader(test.bundle), tmp, nil)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+		} else {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", name, err, test.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(tmp, "example.com", "foo")); !os.IsNotExist(err) {
+				t.Errorf("%s: toolchain was installed from invalid bundle", name)
+			}
+		}
+		os.RemoveAll(tmp)
+	}
+}
+
+func TestUpgrade_rollback(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	srclibDir := filepath.Join(tmp, "srclibpath")
+	bundleOf := func(version, program string) []byte {
+		tcDir := filepath.Join(tmp, "tc-"+version)
+		writeFiles(t, tcDir, map[string]string{"Srclibtoolchain": `{"Tools":[]}`, ".bin/foo": program})
+		var buf bytes.Buffer
+		if err := Create(&buf, tcDir, Manifest{Path: "example.com/foo", Version: version}, nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	if _, err := Install(bytes.NewReader(bundleOf("1.0.0", "v1")), srclibDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(srclibDir, "example.com", "foo")
+
+	var checked []string
+	check := func(m *Manifest, dir string) error {
+		data, err := ioutil.ReadFile(filepath.Join(dir, ".bin/foo"))
+		if err != nil {
+			return err
+		}
+		checked = append(checked, string(data))
+		if string(data) == "broken" {
+			return errors.New("program is broken")
+		}
+		return nil
+	}
+
+	_, err = Upgrade(bytes.NewReader(bundleOf("1.1.0", "broken")), srclibDir, nil, check)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") || !strings.Contains(err.Error(), "program is broken") {
+		t.Errorf("got error %v, want rolled back error", err)
+	}
+	if m, err := Installed(dir); err != nil || m.Version != "1.0.0" {
+		t.Errorf("got installed manifest %+v (error %v) after rollback, want version 1.0.0", m, err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, ".bin/foo")); string(data) != "v1" {
+		t.Errorf("got program %q after rollback, want %q", data, "v1")
+	}
+
+	if m, err := Upgrade(bytes.NewReader(bundleOf("1.2.0", "v2")), srclibDir, nil, check); err != nil || m.Version != "1.2.0" {
+		t.Errorf("got manifest %+v (error %v), want version 1.2.0", m, err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, ".bin/foo")); string(data) != "v2" {
+		t.Errorf("got program %q after upgrade, want %q", data, "v2")
+	}
+	if want := []string{"broken", "v2"}; !reflect.DeepEqual(checked, want) {
+		t.Errorf("got checked programs %v, want %v", checked, want)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Dir(dir)); len(entries) != 1 {
+		t.Errorf("got %d entries in %s, want only the toolchain (no leftover temporary dirs)", len(entries), filepath.Dir(dir))
+	}
+}
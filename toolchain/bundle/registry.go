@@ -0,0 +1,265 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/util"
+)
+
+// RegistryEnv is the environment variable that holds the URL of the
+// default toolchain registry.
+const RegistryEnv = "SRCLIB_REGISTRY"
+
+// MaxIndexSize is the maximum size of a registry index, in bytes.
+var MaxIndexSize int64 = 16 << 20
+
+// An Index is a toolchain registry's index of the bundles that it
+// distributes. Registries are static: an index is a JSON file served over
+// HTTP(S) (or read from the local filesystem), and the bundles it lists are
+// usually served alongside it.
+type Index struct {
+	// Toolchains maps toolchain paths to the toolchains' bundles, newest
+	// version first.
+	Toolchains map[string][]*Entry
+}
+
+// An Entry in a registry index describes a bundle.
+type Entry struct {
+	// Version is the version of the bundled toolchain (matching its
+	// manifest's Version).
+	Version string
+
+	// Platforms are the platforms that the bundled toolchain supports
+	// (matching its manifest's Platforms).
+	Platforms []string `json:",omitempty"`
+
+	// URL is the bundle's URL. Relative URLs are relative to the index's
+	// URL.
+	URL string
+
+	// SHA256 is the hex-encoded SHA-256 hash of the bundle file.
+	SHA256 string
+
+	// Changelog describes the changes in this version since the previous
+	// version. It is shown to users who upgrade to (or past) this version.
+	Changelog string `json:",omitempty"`
+}
+
+// ReadIndex reads the registry index at indexURL (an http or https URL, or
+// a local file).
+func ReadIndex(indexURL string) (*Index, error) {
+	rc, err := Open(indexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var idx Index
+	if err := util.ReadJSON(rc, &idx, MaxIndexSize, 16); err != nil {
+		return nil, fmt.Errorf("reading registry index %s: %s", indexURL, err)
+	}
+	return &idx, nil
+}
+
+// Find returns the entry for the bundle of the given version (or, if
+// version is empty, the newest version) of the toolchain that supports
+// platform. Its URL is resolved relative to indexURL.
+func (idx *Index) Find(indexURL, toolchainPath, version, platform string) (*Entry, error) {
+	entries, present := idx.Toolchains[toolchainPath]
+	if !present {
+		return nil, fmt.Errorf("toolchain %s not found in registry %s", toolchainPath, indexURL)
+	}
+	for _, e := range entries {
+		if e == nil || (version != "" && e.Version != version) {
+			continue
+		}
+		m := Manifest{Platforms: e.Platforms}
+		if !m.Supports(platform) {
+			continue
+		}
+		return resolveEntry(indexURL, toolchainPath, e)
+	}
+	if version != "" {
+		return nil, fmt.Errorf("no bundle of version %q of toolchain %s for platform %s in registry %s", version, toolchainPath, platform, indexURL)
+	}
+	return nil, fmt.Errorf("no bundle of toolchain %s for platform %s in registry %s", toolchainPath, platform, indexURL)
+}
+
+// FindUpgrade returns the entry for the bundle of the newest version of the
+// toolchain that is an upgrade of the installed version and supports
+// platform, or nil if there is none. Only entries whose versions are
+// semantic versions are considered. If pin is non-nil, the new version must
+// satisfy it; otherwise, it must be compatible with the installed version
+// (i.e., have the same major version, or the same minor version if the
+// major version is 0), unless major is true. Pre-release versions are only
+// chosen if pin allows them.
+//
+// FindUpgrade also returns the entries of all versions after the installed
+// version, up to and including the new version (newest first, one per
+// version), whose changelogs describe the upgrade.
+func (idx *Index) FindUpgrade(indexURL, toolchainPath string, installed *Version, pin *Constraint, major bool, platform string) (*Entry, []*Entry, error) {
+	entries, present := idx.Toolchains[toolchainPath]
+	if !present {
+		return nil, nil, fmt.Errorf("toolchain %s not found in registry %s", toolchainPath, indexURL)
+	}
+	allowed := pin
+	if allowed == nil {
+		op := "^"
+		if major {
+			op = ">="
+		}
+		var err error
+		if allowed, err = ParseConstraint(op + installed.String()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var newest *Entry
+	var newestV *Version
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		v, err := ParseVersion(e.Version)
+		if err != nil || v.Compare(installed) <= 0 || !allowed.Allows(v) {
+			continue
+		}
+		if m := (Manifest{Platforms: e.Platforms}); !m.Supports(platform) {
+			continue
+		}
+		if newestV == nil || v.Compare(newestV) > 0 {
+			newest, newestV = e, v
+		}
+	}
+	if newest == nil {
+		return nil, nil, nil
+	}
+
+	var changes []versionedEntry
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		v, err := ParseVersion(e.Version)
+		if err != nil || v.Compare(installed) <= 0 || v.Compare(newestV) > 0 || seen[v.String()] {
+			continue
+		}
+		seen[v.String()] = true
+		changes = append(changes, versionedEntry{e, v})
+	}
+	sort.Sort(sort.Reverse(entriesByVersion(changes)))
+	changed := make([]*Entry, len(changes))
+	for i, c := range changes {
+		changed[i] = c.Entry
+	}
+
+	e, err := resolveEntry(indexURL, toolchainPath, newest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, changed, nil
+}
+
+type versionedEntry struct {
+	*Entry
+	v *Version
+}
+
+type entriesByVersion []versionedEntry
+
+func (es entriesByVersion) Len() int           { return len(es) }
+func (es entriesByVersion) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+func (es entriesByVersion) Less(i, j int) bool { return es[i].v.Compare(es[j].v) < 0 }
+
+// resolveEntry returns a copy of e (an entry of toolchainPath in the index
+// at indexURL) with its URL resolved relative to indexURL. It returns an
+// error if e has no SHA256 hash.
+func resolveEntry(indexURL, toolchainPath string, e *Entry) (*Entry, error) {
+	if e.SHA256 == "" {
+		return nil, fmt.Errorf("bundle of version %q of toolchain %s in registry %s has no SHA256 (refusing to install it unverified)", e.Version, toolchainPath, indexURL)
+	}
+	e2 := *e
+	resolved, err := resolveURL(indexURL, e.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle URL %q in registry %s: %s", e.URL, indexURL, err)
+	}
+	e2.URL = resolved
+	return &e2, nil
+}
+
+// resolveURL resolves ref (a URL or file path) relative to base (the URL
+// or file path of a registry index).
+func resolveURL(base, ref string) (string, error) {
+	if isHTTP(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return b.ResolveReference(r).String(), nil
+	}
+	if isHTTP(ref) || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(strings.TrimPrefix(base, "file://")), filepath.FromSlash(ref)), nil
+}
+
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// Open opens the file at the given http or https URL, or in the local
+// filesystem.
+func Open(urlOrFile string) (io.ReadCloser, error) {
+	if !isHTTP(urlOrFile) {
+		return os.Open(strings.TrimPrefix(urlOrFile, "file://"))
+	}
+	resp, err := network.Client().Get(urlOrFile)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: HTTP %s", urlOrFile, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Fetch downloads the bundle at the given URL (or local file) and returns
+// its contents. If sha256Hex is nonempty, it returns an error if the
+// bundle's SHA-256 hash differs.
+func Fetch(urlOrFile, sha256Hex string) ([]byte, error) {
+	rc, err := Open(urlOrFile)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(rc, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxSize {
+		return nil, fmt.Errorf("bundle %s is larger than the maximum size (%d bytes)", urlOrFile, MaxSize)
+	}
+	if sha256Hex != "" {
+		h := sha256.Sum256(data)
+		if got := hex.EncodeToString(h[:]); !strings.EqualFold(got, sha256Hex) {
+			return nil, fmt.Errorf("bundle %s has SHA-256 hash %s, want %s", urlOrFile, got, strings.ToLower(sha256Hex))
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package bundle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex_Find(t *testing.T) {
+	idx := &Index{Toolchains: map[string][]*Entry{
+		"example.com/foo": {
+			{Version: "2.0", Platforms: []string{"linux/amd64"}, URL: "foo-2.0-linux-amd64.tar.gz", SHA256: "a"},
+			{Version: "2.0", Platforms: []string{"darwin/arm64"}, URL: "https://cdn.example.com/foo-2.0-darwin-arm64.tar.gz", SHA256: "b"},
+			{Version: "1.0", URL: "/bundles/foo-1.0.tar.gz", SHA256: "c"},
+			{Version: "0.1", URL: "foo-0.1.tar.gz"},
+		},
+	}}
+	tests := []struct {
+		indexURL, version, platform string
+		wantURL                     string
+		wantErr                     string
+	}{
+		{indexURL: "https://example.com/r/index.json", platform: "linux/amd64", wantURL: "https://example.com/r/foo-2.0-linux-amd64.tar.gz"},
+		{indexURL: "https://example.com/r/index.json", platform: "darwin/arm64", wantURL: "https://cdn.example.com/foo-2.0-darwin-arm64.tar.gz"},
+		{indexURL: "https://example.com/r/index.json", platform: "plan9/mips", wantURL: "https://example.com/bundles/foo-1.0.tar.gz"},
+		{indexURL: "https://example.com/r/index.json", version: "1.0", platform: "linux/amd64", wantURL: "https://example.com/bundles/foo-1.0.tar.gz"},
+		{indexURL: "/srv/r/index.json", platform: "linux/amd64", wantURL: filepath.FromSlash("/srv/r/foo-2.0-linux-amd64.tar.gz")},
+		{indexURL: "/srv/r/index.json", version: "1.0", platform: "linux/amd64", wantURL: "/bundles/foo-1.0.tar.gz"},
+		{indexURL: "/srv/r/index.json", version: "3.0", platform: "linux/amd64", wantErr: `no bundle of version "3.0"`},
+		{indexURL: "/srv/r/index.json", version: "0.1", platform: "linux/amd64", wantErr: "has no SHA256"},
+	}
+	for _, test := range tests {
+		e, err := idx.Find(test.indexURL, "example.com/foo", test.version, test.platform)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%+v: got error %v, want it to contain %q", test, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %s", test, err)
+			continue
+		}
+		if e.URL != test.wantURL {
+			t.Errorf("%+v: got URL %q, want %q", test, e.URL, test.wantURL)
+		}
+	}
+
+	if _, err := idx.Find("/r/index.json", "example.com/bar", "", "linux/amd64"); err == nil || !strings.Contains(err.Error(), "not found in registry") {
+		t.Errorf("got error %v, want not found error", err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.json":
+			w.Write([]byte(`{"Toolchains":{"example.com/foo":[{"Version":"1.0","URL":"foo.tar.gz","SHA256":"` + hash("bundle") + `"}]}}`))
+		case "/foo.tar.gz":
+			w.Write([]byte("bundle"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	idx, err := ReadIndex(s.URL + "/index.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := idx.Find(s.URL+"/index.json", "example.com/foo", "", Platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Fetch(e.URL, strings.ToUpper(e.SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "bundle" {
+		t.Errorf("got %q, want %q", data, "bundle")
+	}
+
+	if _, err := Fetch(e.URL, hash("other")); err == nil || !strings.Contains(err.Error(), "has SHA-256 hash") {
+		t.Errorf("got error %v, want hash mismatch error", err)
+	}
+	if _, err := Fetch(s.URL+"/missing.tar.gz", ""); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got error %v, want 404 error", err)
+	}
+}
+
+func TestIndex_FindUpgrade(t *testing.T) {
+	idx := &Index{Toolchains: map[string][]*Entry{
+		"example.com/foo": {
+			{Version: "3.0.0-rc.1", URL: "foo-3.0.0-rc.1.tar.gz", SHA256: "a", Changelog: "Preview of 3.0."},
+			{Version: "2.0.0", URL: "foo-2.0.0.tar.gz", SHA256: "a", Changelog: "Breaking changes."},
+			{Version: "1.3.0", Platforms: []string{"plan9/mips"}, URL: "foo-1.3.0-plan9-mips.tar.gz", SHA256: "a"},
+			{Version: "1.2.0", Platforms: []string{"linux/amd64"}, URL: "foo-1.2.0-linux-amd64.tar.gz", SHA256: "a", Changelog: "Faster."},
+			{Version: "1.2.0", Platforms: []string{"darwin/amd64"}, URL: "foo-1.2.0-darwin-amd64.tar.gz", SHA256: "a", Changelog: "Faster."},
+			{Version: "1.1.0", URL: "foo-1.1.0.tar.gz", SHA256: "a", Changelog: "Bug fixes."},
+			{Version: "latest", URL: "foo-latest.tar.gz", SHA256: "a"},
+			{Version: "1.0.0", URL: "foo-1.0.0.tar.gz", SHA256: "a"},
+		},
+	}}
+	tests := []struct {
+		installed, pin string
+		major          bool
+		wantVersion    string // empty if there is no upgrade
+		wantChanges    []string
+	}{
+		{installed: "1.0.0", wantVersion: "1.2.0", wantChanges: []string{"1.2.0", "1.1.0"}},
+		{installed: "1.0.0", major: true, wantVersion: "2.0.0", wantChanges: []string{"2.0.0", "1.3.0", "1.2.0", "1.1.0"}},
+		{installed: "1.0.0", pin: "~1.1", wantVersion: "1.1.0", wantChanges: []string{"1.1.0"}},
+		{installed: "1.0.0", pin: ">=1.0", wantVersion: "2.0.0", wantChanges: []string{"2.0.0", "1.3.0", "1.2.0", "1.1.0"}},
+		{installed: "1.0.0", pin: ">=3.0.0-rc", wantVersion: "3.0.0-rc.1", wantChanges: []string{"3.0.0-rc.1", "2.0.0", "1.3.0", "1.2.0", "1.1.0"}},
+		{installed: "1.2.0"},
+		{installed: "1.2.0", pin: "1.2.0"},
+		{installed: "2.0.0", major: true},
+	}
+	for _, test := range tests {
+		installed, err := ParseVersion(test.installed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pin *Constraint
+		if test.pin != "" {
+			if pin, err = ParseConstraint(test.pin); err != nil {
+				t.Fatal(err)
+			}
+		}
+		e, changes, err := idx.FindUpgrade("https://example.com/index.json", "example.com/foo", installed, pin, test.major, "linux/amd64")
+		if err != nil {
+			t.Errorf("%+v: %s", test, err)
+			continue
+		}
+		if test.wantVersion == "" {
+			if e != nil {
+				t.Errorf("%+v: got upgrade to %s, want none", test, e.Version)
+			}
+			continue
+		}
+		if e == nil || e.Version != test.wantVersion {
+			t.Errorf("%+v: got upgrade %+v, want version %s", test, e, test.wantVersion)
+			continue
+		}
+		if !strings.HasPrefix(e.URL, "https://example.com/") {
+			t.Errorf("%+v: got unresolved URL %q", test, e.URL)
+		}
+		var gotChanges []string
+		for _, ch := range changes {
+			gotChanges = append(gotChanges, ch.Version)
+		}
+		if !reflect.DeepEqual(gotChanges, test.wantChanges) {
+			t.Errorf("%+v: got changes %v, want %v", test, gotChanges, test.wantChanges)
+		}
+	}
+
+	if _, _, err := idx.FindUpgrade("/r/index.json", "example.com/bar", &Version{Major: 1}, nil, false, "linux/amd64"); err == nil || !strings.Contains(err.Error(), "not found in registry") {
+		t.Errorf("got error %v, want not found error", err)
+	}
+}
@@ -0,0 +1,289 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SignatureFilename is the name of the signature of a bundle's manifest,
+// in signed bundles (where it is the second entry) and in the directories
+// of toolchains installed from them.
+const SignatureFilename = ManifestFilename + ".sig"
+
+// A Signature is an Ed25519 signature of a bundle's manifest (its exact
+// bytes). Because the manifest lists the hash of every file in the bundle,
+// the signature covers the whole toolchain.
+type Signature struct {
+	// KeyID is the ID of the key that made the signature.
+	KeyID string
+
+	// Signature is the Ed25519 signature.
+	Signature []byte
+}
+
+// A PublicKey is a key that verifies bundle signatures. Public keys are
+// stored (and exchanged) as JSON files.
+type PublicKey struct {
+	KeyID     string
+	PublicKey ed25519.PublicKey
+}
+
+// A PrivateKey is a key that signs bundles. Private keys are stored as JSON
+// files, which should only be readable by their owner.
+type PrivateKey struct {
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+}
+
+// keyID returns the ID of a public key: the first 8 bytes of its SHA-256
+// hash, hex-encoded.
+func keyID(pub ed25519.PublicKey) string {
+	h := sha256.Sum256(pub)
+	return hex.EncodeToString(h[:8])
+}
+
+// GenerateKey generates a new key pair for signing bundles.
+func GenerateKey() (*PublicKey, *PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	id := keyID(pub)
+	return &PublicKey{id, pub}, &PrivateKey{id, priv}, nil
+}
+
+// Public returns k's public key.
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{k.KeyID, k.PrivateKey.Public().(ed25519.PublicKey)}
+}
+
+// Sign signs a bundle's manifest.
+func (k *PrivateKey) Sign(manifest []byte) *Signature {
+	return &Signature{KeyID: k.KeyID, Signature: ed25519.Sign(k.PrivateKey, manifest)}
+}
+
+// Verify returns an error if sig isn't k's valid signature of manifest.
+func (k *PublicKey) Verify(manifest []byte, sig *Signature) error {
+	if sig.KeyID != k.KeyID || !ed25519.Verify(k.PublicKey, manifest, sig.Signature) {
+		return fmt.Errorf("invalid signature by key %s", k.KeyID)
+	}
+	return nil
+}
+
+// ReadPublicKey reads a public key file.
+func ReadPublicKey(file string) (*PublicKey, error) {
+	var k PublicKey
+	if err := readJSONFile(file, &k); err != nil {
+		return nil, err
+	}
+	if len(k.PublicKey) != ed25519.PublicKeySize || k.KeyID != keyID(k.PublicKey) {
+		return nil, fmt.Errorf("invalid public key in %s", file)
+	}
+	return &k, nil
+}
+
+// ReadPrivateKey reads a private key file.
+func ReadPrivateKey(file string) (*PrivateKey, error) {
+	var k PrivateKey
+	if err := readJSONFile(file, &k); err != nil {
+		return nil, err
+	}
+	if len(k.PrivateKey) != ed25519.PrivateKeySize || k.KeyID != keyID(k.PrivateKey.Public().(ed25519.PublicKey)) {
+		return nil, fmt.Errorf("invalid private key in %s", file)
+	}
+	return &k, nil
+}
+
+func readJSONFile(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("reading %s: %s", file, err)
+	}
+	return nil
+}
+
+// TrustedKeysDir returns the directory, in srclibDir (the first directory in
+// SRCLIBPATH), that holds the public keys (named KEYID.pub) whose signatures
+// are trusted.
+func TrustedKeysDir(srclibDir string) string {
+	return filepath.Join(srclibDir, ".trusted-keys")
+}
+
+// Trust adds a public key to the trusted keys in dir (see TrustedKeysDir).
+func Trust(dir string, k *PublicKey) error {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, k.KeyID+".pub"), data, 0644)
+}
+
+// ReadTrustedKeys reads the trusted public keys in dir (see
+// TrustedKeysDir), keyed by key ID.
+func ReadTrustedKeys(dir string) (map[string]*PublicKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*PublicKey, len(files))
+	for _, file := range files {
+		k, err := ReadPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(file) != k.KeyID+".pub" {
+			return nil, fmt.Errorf("trusted key file %s contains key %s (it must be named %s.pub)", file, k.KeyID, k.KeyID)
+		}
+		keys[k.KeyID] = k
+	}
+	return keys, nil
+}
+
+// PolicyEnv is the environment variable that holds the signature Policy.
+const PolicyEnv = "SRCLIB_SIGNATURE_POLICY"
+
+// A Policy determines how toolchains that aren't signed by a trusted key
+// are treated.
+type Policy string
+
+const (
+	// Off disables signature verification.
+	Off Policy = "off"
+
+	// Warn, the default, verifies signatures but only warns about bundles
+	// that aren't signed by a trusted key. Toolchains that weren't
+	// installed from bundles (e.g., that were cloned) are run without
+	// warnings.
+	Warn Policy = "warn"
+
+	// Require refuses to install or run toolchains that aren't installed
+	// from bundles signed by a trusted key.
+	Require Policy = "require"
+)
+
+// ParsePolicy parses a signature policy name. The empty string means Warn.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case Off, Warn, Require:
+		return p, nil
+	case "":
+		return Warn, nil
+	}
+	return "", fmt.Errorf("invalid signature policy %q (must be off, warn, or require)", s)
+}
+
+// A Verifier checks the signatures of bundles and of toolchains installed
+// from bundles against a set of trusted keys, according to a policy.
+type Verifier struct {
+	Policy Policy
+	Keys   map[string]*PublicKey // trusted keys, keyed by key ID
+}
+
+// NewVerifier returns a verifier that uses the policy from the PolicyEnv
+// environment variable and the trusted keys in TrustedKeysDir(srclibDir).
+func NewVerifier(srclibDir string) (*Verifier, error) {
+	p, err := ParsePolicy(os.Getenv(PolicyEnv))
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{Policy: p}
+	if p != Off {
+		if v.Keys, err = ReadTrustedKeys(TrustedKeysDir(srclibDir)); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// untrusted handles a toolchain that isn't signed by a trusted key
+// (for the given reason), according to the policy. If warn is false, it
+// doesn't log a warning in Warn mode.
+func (v *Verifier) untrusted(what, reason string, warn bool) error {
+	if v.Policy == Require {
+		return fmt.Errorf("refusing to use %s: %s (the signature policy, %s=%s, requires toolchains to be signed by a trusted key)", what, reason, PolicyEnv, v.Policy)
+	}
+	if warn {
+		log.Printf("Warning: %s: %s.", what, reason)
+	}
+	return nil
+}
+
+// checkSignature checks sig (nil if unsigned) of a bundle's manifest. An
+// invalid signature by a trusted key is always an error.
+func (v *Verifier) checkSignature(what string, manifest []byte, sig *Signature, warn bool) error {
+	if v == nil || v.Policy == Off {
+		return nil
+	}
+	if sig == nil {
+		return v.untrusted(what, "bundle is not signed", warn)
+	}
+	k, trusted := v.Keys[sig.KeyID]
+	if !trusted {
+		return v.untrusted(what, fmt.Sprintf("bundle is signed by untrusted key %s (trust it with \"src toolchain trust\")", sig.KeyID), warn)
+	}
+	if err := k.Verify(manifest, sig); err != nil {
+		return fmt.Errorf("%s: %s", what, err)
+	}
+	return nil
+}
+
+// VerifyInstalled checks, before a toolchain in dir is run, that it was
+// installed from a bundle that is signed by a trusted key (according to the
+// policy) and that its files haven't changed since it was installed.
+func (v *Verifier) VerifyInstalled(toolchainPath, dir string) error {
+	if v == nil || v.Policy == Off {
+		return nil
+	}
+	what := "toolchain " + toolchainPath
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, ManifestFilename))
+	if os.IsNotExist(err) {
+		return v.untrusted(what, "it was not installed from a bundle", false)
+	} else if err != nil {
+		return err
+	}
+
+	var sig *Signature
+	if err := readJSONFile(filepath.Join(dir, SignatureFilename), &sig); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// In Warn mode, unsigned bundles were already warned about when they
+	// were installed.
+	if err := v.checkSignature(what, manifest, sig, false); err != nil {
+		return err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return fmt.Errorf("%s: reading %s: %s", what, ManifestFilename, err)
+	}
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("%s: %s", what, err)
+	}
+	var changed []string
+	for name, want := range m.Files {
+		if h, err := HashFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil || h != want {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("%s: files have changed since it was installed from a bundle: %s (reinstall it)", what, strings.Join(changed, ", "))
+	}
+	return nil
+}
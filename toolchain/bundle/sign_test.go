@@ -0,0 +1,159 @@
+package bundle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := priv.Public(); got.KeyID != pub.KeyID || !bytes.Equal(got.PublicKey, pub.PublicKey) {
+		t.Errorf("got public key %+v, want %+v", got, pub)
+	}
+	if err := Trust(tmp, pub); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadTrustedKeys(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := keys[pub.KeyID]
+	if len(keys) != 1 || k == nil {
+		t.Fatalf("got trusted keys %v, want only %s", keys, pub.KeyID)
+	}
+
+	sig := priv.Sign([]byte("manifest"))
+	if err := k.Verify([]byte("manifest"), sig); err != nil {
+		t.Error(err)
+	}
+	if err := k.Verify([]byte("manifest2"), sig); err == nil {
+		t.Error("got nil error verifying signature of different manifest")
+	}
+
+	// A key file whose name doesn't match its key is rejected.
+	if err := os.Rename(filepath.Join(tmp, pub.KeyID+".pub"), filepath.Join(tmp, "x.pub")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTrustedKeys(tmp); err == nil || !strings.Contains(err.Error(), "must be named") {
+		t.Errorf("got error %v, want misnamed key file error", err)
+	}
+}
+
+func TestInstall_signatures(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tcDir := filepath.Join(tmp, "tc")
+	writeFiles(t, tcDir, map[string]string{"Srclibtoolchain": `{"Tools":[]}`, ".bin/foo": "#!/bin/sh\n"})
+
+	trustedPub, trusted, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, untrusted, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(key *PrivateKey) []byte {
+		var buf bytes.Buffer
+		if err := Create(&buf, tcDir, Manifest{Path: "example.com/foo"}, key); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	forged := *trusted
+	forged.PrivateKey = untrusted.PrivateKey
+
+	tests := map[string]struct {
+		bundle  []byte
+		policy  Policy
+		wantErr string
+	}{
+		"signed":                     {bundle: create(trusted), policy: Require},
+		"unsigned, warn":             {bundle: create(nil), policy: Warn},
+		"untrusted, warn":            {bundle: create(untrusted), policy: Warn},
+		"unsigned, off":              {bundle: create(nil), policy: Off},
+		"invalid signature, off":     {bundle: create(&forged), policy: Off},
+		"unsigned, require":          {bundle: create(nil), policy: Require, wantErr: "bundle is not signed"},
+		"untrusted, require":         {bundle: create(untrusted), policy: Require, wantErr: "signed by untrusted key " + untrusted.KeyID},
+		"invalid signature, warn":    {bundle: create(&forged), policy: Warn, wantErr: "invalid signature by key " + trusted.KeyID},
+		"invalid signature, require": {bundle: create(&forged), policy: Require, wantErr: "invalid signature by key " + trusted.KeyID},
+	}
+	for name, test := range tests {
+		srclibDir := filepath.Join(tmp, "srclibpath", strings.Replace(name, " ", "", -1))
+		v := &Verifier{Policy: test.policy, Keys: map[string]*PublicKey{trustedPub.KeyID: trustedPub}}
+		_, err := Install(bytes.NewReader(test.bundle), srclibDir, v)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", name, err, test.wantErr)
+		}
+		if _, err := os.Stat(filepath.Join(srclibDir, "example.com", "foo")); !os.IsNotExist(err) {
+			t.Errorf("%s: toolchain was installed from bundle that failed verification", name)
+		}
+	}
+}
+
+func TestVerifyInstalled(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "srclib-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tcDir, srclibDir := filepath.Join(tmp, "tc"), filepath.Join(tmp, "srclibpath")
+	writeFiles(t, tcDir, map[string]string{"Srclibtoolchain": `{"Tools":[]}`, ".bin/foo": "#!/bin/sh\n"})
+
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Policy: Require, Keys: map[string]*PublicKey{pub.KeyID: pub}}
+
+	// A toolchain that wasn't installed from a bundle is only refused under
+	// the Require policy.
+	if err := v.VerifyInstalled("example.com/foo", tcDir); err == nil || !strings.Contains(err.Error(), "not installed from a bundle") {
+		t.Errorf("got error %v, want not-installed-from-bundle error", err)
+	}
+	if err := (&Verifier{Policy: Warn}).VerifyInstalled("example.com/foo", tcDir); err != nil {
+		t.Errorf("got error %v under Warn policy, want nil", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Create(&buf, tcDir, Manifest{Path: "example.com/foo"}, priv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(&buf, srclibDir, v); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(srclibDir, "example.com", "foo")
+	if err := v.VerifyInstalled("example.com/foo", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, map[string]string{".bin/foo": "#!/bin/sh\necho evil\n"})
+	if err := v.VerifyInstalled("example.com/foo", dir); err == nil || !strings.Contains(err.Error(), "files have changed since it was installed from a bundle: .bin/foo") {
+		t.Errorf("got error %v, want changed files error", err)
+	}
+	if err := (&Verifier{Policy: Off}).VerifyInstalled("example.com/foo", dir); err != nil {
+		t.Errorf("got error %v under Off policy, want nil", err)
+	}
+}
@@ -0,0 +1,250 @@
+package bundle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Version is a semantic version (see http://semver.org):
+// MAJOR.MINOR.PATCH, optionally followed by a pre-release suffix (e.g.,
+// "-beta.1") and build metadata (e.g., "+20150102"), which is ignored. A
+// leading "v" is allowed.
+//
+// Bundle versions need not be semantic versions, but only bundles whose
+// versions are can be upgraded (with "src toolchain upgrade") or pinned (in
+// a Srcfile).
+type Version struct {
+	Major, Minor, Patch int
+
+	// Pre are the dot-separated identifiers of the pre-release suffix, if
+	// any.
+	Pre []string
+}
+
+// ParseVersion parses a semantic version.
+func ParseVersion(s string) (*Version, error) {
+	v, n, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	if n != 3 {
+		return nil, fmt.Errorf("invalid version %q (must be MAJOR.MINOR.PATCH)", s)
+	}
+	return v, nil
+}
+
+// parsePartialVersion parses a version that may omit its minor and patch
+// numbers (e.g., "1" or "1.2", as in constraints). It returns the number
+// of numbers present.
+func parsePartialVersion(s string) (*Version, int, error) {
+	orig := s
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.Index(s, "+"); i != -1 {
+		s = s[:i]
+	}
+	var v Version
+	if i := strings.Index(s, "-"); i != -1 {
+		v.Pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+		for _, id := range v.Pre {
+			if id == "" {
+				return nil, 0, fmt.Errorf("invalid version %q (empty pre-release identifier)", orig)
+			}
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, 0, fmt.Errorf("invalid version %q (too many numbers)", orig)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("invalid version %q", orig)
+		}
+		*nums[i] = n
+	}
+	if v.Pre != nil && len(parts) != 3 {
+		return nil, 0, fmt.Errorf("invalid version %q (a pre-release suffix requires MAJOR.MINOR.PATCH)", orig)
+	}
+	return &v, len(parts), nil
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != nil {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0, or 1 if v is less than, equal to, or greater than
+// w, according to semantic versioning's precedence rules.
+func (v *Version) Compare(w *Version) int {
+	return compareVersions(v, w, 3)
+}
+
+// compareVersions compares v and w's first n (1 to 3) numbers, and their
+// pre-release suffixes if n is 3.
+func compareVersions(v, w *Version, n int) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{w.Major, w.Minor, w.Patch}
+	for i := 0; i < n; i++ {
+		if c := compareInts(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	if n < 3 {
+		return 0
+	}
+
+	// A pre-release version has lower precedence than the release.
+	switch {
+	case v.Pre == nil && w.Pre == nil:
+		return 0
+	case v.Pre == nil:
+		return 1
+	case w.Pre == nil:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(w.Pre); i++ {
+		x, xErr := strconv.Atoi(v.Pre[i])
+		y, yErr := strconv.Atoi(w.Pre[i])
+		var c int
+		switch {
+		case xErr == nil && yErr == nil:
+			c = compareInts(x, y)
+		case xErr == nil: // numeric identifiers sort first
+			c = -1
+		case yErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(v.Pre[i], w.Pre[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(v.Pre), len(w.Pre))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// A Constraint restricts the versions of a toolchain that may be used, as
+// in a Srcfile's version pins. It is a space-separated list of
+// comparisons, all of which a version must satisfy:
+//
+//	1.2.3, =1.2.3  exactly 1.2.3
+//	>1.2.3, >=1.2.3, <1.2.3, <=1.2.3
+//	^1.2.3         at least 1.2.3 but less than 2.0.0 (a compatible version)
+//	~1.2.3         at least 1.2.3 but less than 1.3.0
+//
+// Versions in comparisons may omit their minor and patch numbers, which
+// then match anything: "1.2" means any 1.2.x version, and "<1.2" means less
+// than 1.2.0. For ^, the first nonzero number may not change (e.g., ^0.2.3
+// means at least 0.2.3 but less than 0.3.0).
+//
+// Pre-release versions only satisfy a constraint if one of its comparisons
+// names a pre-release of the same MAJOR.MINOR.PATCH (e.g., ">=2.0.0-beta"
+// allows 2.0.0-rc.1 but not 2.1.0-rc.1).
+type Constraint struct {
+	s    string
+	cmps []comparison
+}
+
+type comparison struct {
+	op string
+	v  *Version
+	n  int // the number of numbers in v that were specified
+}
+
+// ParseConstraint parses a version constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{s: s}
+	for _, f := range strings.Fields(s) {
+		op := strings.TrimRight(f, "0123456789.v-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		switch op {
+		case "", "=", ">", ">=", "<", "<=", "^", "~":
+		default:
+			return nil, fmt.Errorf("invalid version constraint %q (unknown operator %q)", s, op)
+		}
+		v, n, err := parsePartialVersion(f[len(op):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %s", s, err)
+		}
+		if op == "" {
+			op = "="
+		}
+		c.cmps = append(c.cmps, comparison{op: op, v: v, n: n})
+	}
+	if len(c.cmps) == 0 {
+		return nil, fmt.Errorf("empty version constraint")
+	}
+	return c, nil
+}
+
+func (c *Constraint) String() string { return c.s }
+
+// Allows returns whether v satisfies c.
+func (c *Constraint) Allows(v *Version) bool {
+	if v.Pre != nil {
+		allowPre := false
+		for _, cmp := range c.cmps {
+			if cmp.v.Pre != nil && compareVersions(v, cmp.v, 2) == 0 && v.Patch == cmp.v.Patch {
+				allowPre = true
+			}
+		}
+		if !allowPre {
+			return false
+		}
+	}
+	for _, cmp := range c.cmps {
+		if !cmp.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cmp comparison) allows(v *Version) bool {
+	c := compareVersions(v, cmp.v, cmp.n)
+	switch cmp.op {
+	case "=":
+		return c == 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+
+	// ^ and ~ allow versions from cmp.v up to (but excluding) an upper
+	// bound, which is found by incrementing one of cmp.v's numbers.
+	if compareVersions(v, cmp.v, 3) < 0 {
+		return false
+	}
+	nums := []int{cmp.v.Major, cmp.v.Minor, cmp.v.Patch}
+	i := 0 // the number to increment
+	if cmp.op == "~" {
+		if cmp.n >= 2 {
+			i = 1
+		}
+	} else {
+		for i < cmp.n-1 && nums[i] == 0 {
+			i++
+		}
+	}
+	return compareVersions(v, cmp.v, i) == 0 && []int{v.Major, v.Minor, v.Patch}[i] <= nums[i]
+}
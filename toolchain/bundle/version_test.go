@@ -0,0 +1,97 @@
+package bundle
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3":             "1.2.3",
+		"v1.2.3":            "1.2.3",
+		"1.2.3-beta.1":      "1.2.3-beta.1",
+		"1.2.3+build.5":     "1.2.3",
+		"1.2.3-rc.1+build5": "1.2.3-rc.1",
+		"1.2":               "",
+		"1.2.3.4":           "",
+		"1.x.3":             "",
+		"1.2.3-":            "",
+		"1.2.3-a..b":        "",
+		"":                  "",
+	}
+	for s, want := range tests {
+		v, err := ParseVersion(s)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: got version %s, want error", s, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("%q: got %s, want %s", s, v, want)
+		}
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// In increasing order of precedence (from the semver spec).
+	versions := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0", "10.0.0"}
+	for i, a := range versions {
+		for j, b := range versions {
+			va, _ := ParseVersion(a)
+			vb, _ := ParseVersion(b)
+			want := compareInts(i, j)
+			if got := va.Compare(vb); got != want {
+				t.Errorf("%s.Compare(%s): got %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestConstraint_Allows(t *testing.T) {
+	tests := []struct {
+		constraint string
+		allows     []string
+		disallows  []string
+	}{
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{"=1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{">1.2.3", []string{"1.2.4", "2.0.0"}, []string{"1.2.3", "1.0.0", "1.3.0-beta"}},
+		{">=1.2", []string{"1.2.0", "3.0.0"}, []string{"1.1.9"}},
+		{"<1.2", []string{"1.1.9", "0.1.0"}, []string{"1.2.0", "1.2.1"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0", "2.0.0-rc.1"}},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.0", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0", []string{"0.0.1", "0.9.0"}, []string{"1.0.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{">=1.0 <1.5", []string{"1.0.0", "1.4.9"}, []string{"1.5.0", "0.9.0"}},
+		{">=2.0.0-beta", []string{"2.0.0-beta", "2.0.0-rc.1", "2.0.0", "2.1.0"}, []string{"2.0.0-alpha", "2.1.0-rc.1"}},
+	}
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Errorf("%q: %s", test.constraint, err)
+			continue
+		}
+		for _, s := range test.allows {
+			if v, _ := ParseVersion(s); !c.Allows(v) {
+				t.Errorf("%q doesn't allow %s, want it to", test.constraint, s)
+			}
+		}
+		for _, s := range test.disallows {
+			if v, _ := ParseVersion(s); c.Allows(v) {
+				t.Errorf("%q allows %s, want it not to", test.constraint, s)
+			}
+		}
+	}
+
+	for _, s := range []string{"", "!1.0", ">>1.0", "^1.x", "1.0-beta", ">=1.0<2.0"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("%q: got nil error, want invalid constraint error", s)
+		}
+	}
+}
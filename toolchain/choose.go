@@ -40,7 +40,7 @@ func chooseTool(op, unitType string, tcs []*Info) (*ToolRef, error) {
 	}
 
 	if n := len(satisfying); n == 0 {
-		return nil, fmt.Errorf("no tool satisfies op %q for source unit type %q", op, unitType)
+		return nil, &NotFoundError{fmt.Sprintf("no tool satisfies op %q for source unit type %q", op, unitType)}
 	} else if n > 1 {
 		return nil, fmt.Errorf("%d tools satisfy op %q for source unit type %q (refusing to choose between multiple possibilities)", n, op, unitType)
 	}
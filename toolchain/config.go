@@ -9,4 +9,55 @@ const ConfigFilename = "Srclibtoolchain"
 type Config struct {
 	// Tools is the list of this toolchain's tools and their definitions.
 	Tools []*ToolInfo
+
+	// Image is the name of a prebuilt Docker image (in a registry) that runs
+	// this toolchain, and Entrypoint is the command that runs the toolchain
+	// in that image. They are used by the Kubernetes Job execution method,
+	// which can't build the toolchain's Dockerfile itself.
+	Image      string   `json:",omitempty"`
+	Entrypoint []string `json:",omitempty"`
+
+	// Protocol declares the toolchain protocol versions and capabilities
+	// that the toolchain supports. If it is nil, the toolchain speaks
+	// protocol version 1.
+	Protocol *Protocol `json:",omitempty"`
+
+	// Offsets declares how the toolchain's graphers express the offsets of
+	// defs, refs, and docs in files. If it is empty, srclib assumes byte
+	// offsets (except for the graphers registered in the grapher package,
+	// for which it keeps guessing by source unit type; see
+	// grapher.NormalizeOffsets).
+	Offsets Offsets `json:",omitempty"`
+}
+
+// Offsets is a kind of file offset that a toolchain's graphers may output.
+// Whatever the kind, srclib converts the offsets to byte offsets when it
+// normalizes graph output.
+type Offsets string
+
+const (
+	// ByteOffsets are offsets in bytes from the start of the file.
+	ByteOffsets Offsets = "byte"
+
+	// RuneOffsets are offsets in Unicode code points (as in Python 3
+	// strings) from the start of the file, which is UTF-8-encoded.
+	RuneOffsets Offsets = "rune"
+
+	// UTF16Offsets are offsets in UTF-16 code units (as in JavaScript and
+	// Java strings) from the start of the file, which is UTF-8-encoded.
+	UTF16Offsets Offsets = "utf-16"
+)
+
+// DeclaredOffsets returns the offsets that the toolchain at path declares in
+// its Srclibtoolchain, or "" if it doesn't declare them.
+func DeclaredOffsets(path string) (Offsets, error) {
+	tc, err := Lookup(path)
+	if err != nil {
+		return "", err
+	}
+	c, err := tc.ReadConfig()
+	if err != nil {
+		return "", err
+	}
+	return c.Offsets, nil
 }
@@ -0,0 +1,21 @@
+package toolchain
+
+import "os"
+
+// A NotFoundError indicates that a toolchain or tool could not be found (or
+// is not usable with the current execution methods).
+type NotFoundError struct {
+	Msg string
+}
+
+func (e *NotFoundError) Error() string { return e.Msg }
+
+// IsNotFound returns whether err indicates that a toolchain or tool could not
+// be found. It is true for *NotFoundError values and for the os.ErrNotExist
+// returned by Lookup and Open.
+func IsNotFound(err error) bool {
+	if _, ok := err.(*NotFoundError); ok {
+		return true
+	}
+	return err == os.ErrNotExist
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/network"
 )
 
 // Get downloads the toolchain named by the toolchain path (if it does not
@@ -33,7 +34,7 @@ func Get(path string, update bool) (*Info, error) {
 		} else {
 			substitutedPath = path
 		}
-		cloneURL := "https://" + substitutedPath + ".git"
+		cloneURL := network.Default.Mirror("https://" + substitutedPath + ".git")
 		cmd := exec.Command("git", "clone", cloneURL, toolchainDir)
 		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
 		if err := cmd.Run(); err != nil {
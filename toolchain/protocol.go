@@ -0,0 +1,429 @@
+package toolchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// The toolchain protocol is how srclib runs tools: the arguments, input,
+// and output of each operation. It is versioned, so that it can evolve
+// without breaking toolchains that were written for older versions.
+//
+// A toolchain declares the protocol versions and optional capabilities that
+// it supports in its Srclibtoolchain (see Protocol). Before srclib runs one
+// of its tools, it negotiates the protocol with the toolchain (see
+// Negotiate): it uses the newest version that both support, and the
+// capabilities that both support, and it tells the tool the result in the
+// SRCLIB_PROTOCOL_VERSION and SRCLIB_CAPABILITIES environment variables.
+// Toolchains that don't declare a protocol speak version 1, the original
+// protocol, and are run exactly as they were before the protocol was
+// versioned.
+const (
+	// ProtocolVersion is the newest protocol version that srclib supports.
+	ProtocolVersion = 2
+
+	// MinProtocolVersion is the oldest protocol version that srclib
+	// supports.
+	MinProtocolVersion = 1
+)
+
+// These environment variables tell tools (that speak protocol version 2 or
+// later) which protocol version and capabilities were negotiated. The
+// capabilities are comma-separated. They aren't set for version 1 tools,
+// so tools that don't see them are being run by a version of srclib that
+// predates protocol negotiation.
+const (
+	ProtocolVersionEnv = "SRCLIB_PROTOCOL_VERSION"
+	CapabilitiesEnv    = "SRCLIB_CAPABILITIES"
+)
+
+// A Capability is an optional feature of the toolchain protocol (in
+// version 2 and later). A capability is only used if both srclib and the
+// toolchain support it.
+type Capability string
+
+const (
+	// StreamingOutput lets tools write their output in parts, as a sequence
+	// of JSON values, instead of as a single JSON value. Parts are merged:
+	// arrays are concatenated, and the array-valued properties of objects
+	// are concatenated. For example, a grapher may write each def and ref
+	// as it finds it ({"Defs":[...]} or {"Refs":[...]}).
+	StreamingOutput Capability = "streaming-output"
+
+	// JSONRPC makes tools exchange JSON-RPC 2.0 messages with srclib,
+	// instead of plain JSON, so they can report structured errors. The
+	// tool reads a single request on stdin, whose method is the tool's
+	// subcommand and whose params are {"Args": [...], "Input": INPUT}, and
+	// writes a single response (whose result is the tool's output) on
+	// stdout. With StreamingOutput, it may send parts of its output as
+	// "output" notifications (whose params are the parts) before the
+	// response.
+	JSONRPC Capability = "json-rpc"
+
+	// Docs means that the toolchain's graphers emit docs. In version 2 and
+	// later, srclib discards the docs in the output of graphers that don't
+	// have this capability.
+	Docs Capability = "docs"
+
+	// RefKinds means that the toolchain's graphers emit ref kinds (see
+	// graph.RefKind). In version 2 and later, srclib discards the ref kinds
+	// in the output of graphers that don't have this capability.
+	RefKinds Capability = "ref-kinds"
+
+	// DepSources means that the toolchain's graphers read the sources of
+	// the source unit's dependencies. Before srclib runs such a grapher,
+	// it checks out the repositories of the unit's resolved dependencies
+	// (see package depsrc) and lists them in the file named by the
+	// DepSourcesEnv environment variable.
+	DepSources Capability = "dep-sources"
+
+	// SingleFile means that the toolchain's graphers can quickly graph a
+	// single file of a source unit (such as a file that is being edited).
+	// To graph one file, srclib runs the grapher with the source unit as
+	// usual, and with the SingleFileEnv and SingleFileContentsEnv
+	// environment variables set. The grapher outputs the defs, refs, and
+	// docs in that file only, reading the unit's other files only as needed
+	// to resolve refs.
+	SingleFile Capability = "single-file"
+)
+
+// Capabilities lists the capabilities that srclib supports.
+var Capabilities = []Capability{StreamingOutput, JSONRPC, Docs, RefKinds, DepSources, SingleFile}
+
+// DepSourcesEnv is the environment variable that names the JSON file that
+// lists the checkouts of the dependencies of the source unit being graphed
+// (an array of depsrc.Source objects), for graphers with the DepSources
+// capability.
+const DepSourcesEnv = "SRCLIB_DEP_SOURCES"
+
+// These environment variables tell a grapher with the SingleFile capability
+// to graph a single file: SingleFileEnv is the file's path (relative to the
+// repository root, like the source unit's Files), and SingleFileContentsEnv
+// names a file that holds its current contents, which the grapher reads
+// instead of the file in the tree (which may be out of date).
+const (
+	SingleFileEnv         = "SRCLIB_SINGLE_FILE"
+	SingleFileContentsEnv = "SRCLIB_SINGLE_FILE_CONTENTS"
+)
+
+// Protocol declares the protocol versions and capabilities that a
+// toolchain supports, in its Srclibtoolchain.
+type Protocol struct {
+	// Versions are the protocol versions that the toolchain supports.
+	Versions []int
+
+	// Capabilities are the optional capabilities that the toolchain
+	// supports. Capabilities that srclib doesn't know about are ignored,
+	// so toolchains may declare capabilities that newer versions of srclib
+	// support.
+	Capabilities []Capability `json:",omitempty"`
+}
+
+// A Session describes the protocol that srclib negotiated with a
+// toolchain. A nil *Session means protocol version 1.
+type Session struct {
+	// Toolchain is the toolchain path.
+	Toolchain string
+
+	// Version is the negotiated protocol version.
+	Version int
+
+	// Capabilities are the negotiated capabilities, in the order of the
+	// Capabilities variable.
+	Capabilities []Capability
+}
+
+// Negotiate negotiates the protocol with the toolchain, which supports the
+// protocol p (nil if its Srclibtoolchain doesn't declare one). It returns
+// an error if they have no protocol version in common.
+func Negotiate(toolchainPath string, p *Protocol) (*Session, error) {
+	if p == nil {
+		return &Session{Toolchain: toolchainPath, Version: 1}, nil
+	}
+	if len(p.Versions) == 0 {
+		return nil, fmt.Errorf("toolchain %s declares no protocol versions in its %s", toolchainPath, ConfigFilename)
+	}
+
+	s := &Session{Toolchain: toolchainPath}
+	min, max := p.Versions[0], p.Versions[0]
+	for _, v := range p.Versions {
+		if v >= MinProtocolVersion && v <= ProtocolVersion && v > s.Version {
+			s.Version = v
+		}
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	switch {
+	case s.Version != 0:
+	case min > ProtocolVersion:
+		return nil, fmt.Errorf("toolchain %s requires toolchain protocol version %d or later, but this version of srclib only supports versions %d to %d (upgrade srclib to use it)", toolchainPath, min, MinProtocolVersion, ProtocolVersion)
+	case max < MinProtocolVersion:
+		return nil, fmt.Errorf("toolchain %s only supports toolchain protocol versions up to %d, but this version of srclib requires version %d or later (upgrade the toolchain to use it)", toolchainPath, max, MinProtocolVersion)
+	default:
+		return nil, fmt.Errorf("toolchain %s supports toolchain protocol versions %v, but this version of srclib only supports versions %d to %d", toolchainPath, p.Versions, MinProtocolVersion, ProtocolVersion)
+	}
+
+	if s.Version >= 2 {
+		for _, c := range Capabilities {
+			for _, c2 := range p.Capabilities {
+				if c == c2 {
+					s.Capabilities = append(s.Capabilities, c)
+					break
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// Negotiate reads the toolchain's Srclibtoolchain and negotiates the
+// protocol with it.
+func (t *Info) Negotiate() (*Session, error) {
+	c, err := t.ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return Negotiate(t.Path, c.Protocol)
+}
+
+// Has returns whether the capability c was negotiated.
+func (s *Session) Has(c Capability) bool {
+	if s == nil {
+		return false
+	}
+	for _, c2 := range s.Capabilities {
+		if c == c2 {
+			return true
+		}
+	}
+	return false
+}
+
+// Adapts returns whether srclib adapts the input and output of tools in
+// this session (with EncodeInput and DecodeOutput). Version 1 tools' input
+// and output are passed through unchanged.
+func (s *Session) Adapts() bool { return s != nil && s.Version >= 2 }
+
+// Env returns the environment variables (as "KEY=value") that tell the
+// tool the negotiated protocol, or nil for version 1.
+func (s *Session) Env() []string {
+	if !s.Adapts() {
+		return nil
+	}
+	caps := make([]string, len(s.Capabilities))
+	for i, c := range s.Capabilities {
+		caps[i] = string(c)
+	}
+	return []string{
+		ProtocolVersionEnv + "=" + strconv.Itoa(s.Version),
+		CapabilitiesEnv + "=" + strings.Join(caps, ","),
+	}
+}
+
+// jsonrpcRequest, jsonrpcMessage, and jsonrpcError are JSON-RPC 2.0
+// messages.
+type jsonrpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type jsonrpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+	Result  json.RawMessage  `json:"result"`
+	Error   *jsonrpcError    `json:"error"`
+}
+
+type jsonrpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// EncodeInput returns the data to send on stdin to the tool subcmd, run
+// with args, given its JSON-encoded input (nil if it has none).
+func (s *Session) EncodeInput(subcmd string, args []string, input []byte) ([]byte, error) {
+	if !s.Has(JSONRPC) {
+		return input, nil
+	}
+	params := struct {
+		Args  []string
+		Input json.RawMessage `json:",omitempty"`
+	}{Args: args, Input: bytes.TrimSpace(input)}
+	if params.Args == nil {
+		params.Args = []string{}
+	}
+	data, err := json.Marshal(jsonrpcRequest{JSONRPC: "2.0", ID: 1, Method: subcmd, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// DecodeOutput returns the output (as a single JSON value) of a tool that
+// performs op, given what it wrote on stdout. For version 1 tools, it
+// returns stdout unchanged.
+func (s *Session) DecodeOutput(op string, stdout []byte) ([]byte, error) {
+	if !s.Adapts() {
+		return stdout, nil
+	}
+
+	var parts []json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(stdout))
+	if s.Has(JSONRPC) {
+		for done := false; ; {
+			var msg jsonrpcMessage
+			if err := dec.Decode(&msg); err == io.EOF {
+				if !done {
+					return nil, fmt.Errorf("toolchain %s: tool exited without sending a JSON-RPC response", s.Toolchain)
+				}
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("toolchain %s: reading JSON-RPC message: %s", s.Toolchain, err)
+			}
+			switch {
+			case done:
+				return nil, fmt.Errorf("toolchain %s: tool sent a JSON-RPC message after its response", s.Toolchain)
+			case msg.ID == nil && msg.Method == "output" && s.Has(StreamingOutput):
+				parts = append(parts, msg.Params)
+			case msg.ID == nil:
+				return nil, fmt.Errorf("toolchain %s: unexpected JSON-RPC notification %q", s.Toolchain, msg.Method)
+			case msg.Error != nil:
+				err := fmt.Sprintf("toolchain %s: tool failed: %s (JSON-RPC error %d)", s.Toolchain, msg.Error.Message, msg.Error.Code)
+				if len(msg.Error.Data) > 0 {
+					err += fmt.Sprintf(": %s", msg.Error.Data)
+				}
+				return nil, fmt.Errorf("%s", err)
+			default:
+				parts = append(parts, msg.Result)
+				done = true
+			}
+		}
+	} else {
+		for {
+			var part json.RawMessage
+			if err := dec.Decode(&part); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("toolchain %s: reading tool output: %s", s.Toolchain, err)
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) > 1 && !s.Has(StreamingOutput) {
+			return nil, fmt.Errorf("toolchain %s: tool wrote %d JSON values, but it doesn't have the %s capability", s.Toolchain, len(parts), StreamingOutput)
+		}
+	}
+
+	out, err := mergeOutput(parts)
+	if err != nil {
+		return nil, fmt.Errorf("toolchain %s: merging streamed tool output: %s", s.Toolchain, err)
+	}
+	if op == "graph" {
+		if out, err = s.filterGraphOutput(out); err != nil {
+			return nil, fmt.Errorf("toolchain %s: %s", s.Toolchain, err)
+		}
+	}
+	return out, nil
+}
+
+// mergeOutput merges the parts of a tool's streamed output (see
+// StreamingOutput). Null parts are ignored.
+func mergeOutput(parts []json.RawMessage) (json.RawMessage, error) {
+	var nonNull []json.RawMessage
+	for _, p := range parts {
+		if p = bytes.TrimSpace(p); len(p) > 0 && !bytes.Equal(p, []byte("null")) {
+			nonNull = append(nonNull, p)
+		}
+	}
+	switch {
+	case len(nonNull) == 0:
+		return json.RawMessage("null"), nil
+	case len(nonNull) == 1:
+		return nonNull[0], nil
+	}
+
+	if nonNull[0][0] == '[' {
+		var all []json.RawMessage
+		for _, p := range nonNull {
+			var elems []json.RawMessage
+			if err := json.Unmarshal(p, &elems); err != nil {
+				return nil, fmt.Errorf("all parts must be arrays if the first one is: %s", err)
+			}
+			all = append(all, elems...)
+		}
+		return json.Marshal(all)
+	}
+
+	merged := map[string][]json.RawMessage{}
+	for _, p := range nonNull {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(p, &obj); err != nil {
+			return nil, fmt.Errorf("parts must be arrays or objects: %s", err)
+		}
+		for k, v := range obj {
+			var elems []json.RawMessage
+			if err := json.Unmarshal(v, &elems); err != nil {
+				return nil, fmt.Errorf("property %q must be an array: %s", k, err)
+			}
+			merged[k] = append(merged[k], elems...)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// filterGraphOutput discards the docs and ref kinds in graph output whose
+// capabilities weren't negotiated.
+func (s *Session) filterGraphOutput(out json.RawMessage) (json.RawMessage, error) {
+	if s.Has(Docs) && s.Has(RefKinds) {
+		return out, nil
+	}
+	var o map[string]json.RawMessage
+	if err := json.Unmarshal(out, &o); err != nil || o == nil {
+		return out, nil // invalid output is reported by the caller
+	}
+	changed := false
+	if _, present := o["Docs"]; present && !s.Has(Docs) {
+		log.Printf("Warning: toolchain %s: discarding docs in graph output, because the toolchain doesn't have the %s capability.", s.Toolchain, Docs)
+		delete(o, "Docs")
+		changed = true
+	}
+	if refs, present := o["Refs"]; present && !s.Has(RefKinds) && bytes.Contains(refs, []byte(`"Kind"`)) {
+		var rs []map[string]json.RawMessage
+		if err := json.Unmarshal(refs, &rs); err != nil {
+			return out, nil
+		}
+		n := 0
+		for _, r := range rs {
+			if _, present := r["Kind"]; present {
+				delete(r, "Kind")
+				n++
+			}
+		}
+		if n > 0 {
+			log.Printf("Warning: toolchain %s: discarding the kinds of %d refs in graph output, because the toolchain doesn't have the %s capability.", s.Toolchain, n, RefKinds)
+			data, err := json.Marshal(rs)
+			if err != nil {
+				return nil, err
+			}
+			o["Refs"] = data
+			changed = true
+		}
+	}
+	if !changed {
+		return out, nil
+	}
+	return json.Marshal(o)
+}
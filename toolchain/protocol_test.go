@@ -0,0 +1,242 @@
+package toolchain
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]struct {
+		protocol *Protocol
+		want     *Session
+		wantErr  string
+	}{
+		"undeclared": {
+			want: &Session{Toolchain: "tc", Version: 1},
+		},
+		"version 1 only": {
+			protocol: &Protocol{Versions: []int{1}, Capabilities: []Capability{Docs}},
+			want:     &Session{Toolchain: "tc", Version: 1},
+		},
+		"newest common version": {
+			protocol: &Protocol{Versions: []int{1, 2, 3}, Capabilities: []Capability{RefKinds, "future", Docs}},
+			want:     &Session{Toolchain: "tc", Version: 2, Capabilities: []Capability{Docs, RefKinds}},
+		},
+		"no versions": {
+			protocol: &Protocol{},
+			wantErr:  "declares no protocol versions",
+		},
+		"too new": {
+			protocol: &Protocol{Versions: []int{3, 4}},
+			wantErr:  "requires toolchain protocol version 3 or later",
+		},
+		"too old": {
+			protocol: &Protocol{Versions: []int{0}},
+			wantErr:  "upgrade the toolchain",
+		},
+	}
+	for name, test := range tests {
+		s, err := Negotiate("tc", test.protocol)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(s, test.want) {
+			t.Errorf("%s: got %+v, want %+v", name, s, test.want)
+		}
+	}
+}
+
+func TestSession_Env(t *testing.T) {
+	if env := (&Session{Version: 1}).Env(); env != nil {
+		t.Errorf("version 1: got env %v, want none", env)
+	}
+	s := &Session{Version: 2, Capabilities: []Capability{JSONRPC, Docs}}
+	if want := []string{"SRCLIB_PROTOCOL_VERSION=2", "SRCLIB_CAPABILITIES=json-rpc,docs"}; !reflect.DeepEqual(s.Env(), want) {
+		t.Errorf("got env %v, want %v", s.Env(), want)
+	}
+}
+
+func TestSession_EncodeInput(t *testing.T) {
+	in := []byte(`{"Name":"u"}` + "\n")
+	if got, _ := (&Session{Version: 2}).EncodeInput("graph", nil, in); string(got) != string(in) {
+		t.Errorf("without JSON-RPC: got %q, want input unchanged", got)
+	}
+	got, err := (&Session{Version: 2, Capabilities: []Capability{JSONRPC}}).EncodeInput("graph", []string{"-v"}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"jsonrpc":"2.0","id":1,"method":"graph","params":{"Args":["-v"],"Input":{"Name":"u"}}}` + "\n"; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSession_DecodeOutput(t *testing.T) {
+	plain := []Capability{StreamingOutput, Docs, RefKinds} // all but JSON-RPC
+	tests := map[string]struct {
+		session *Session
+		op      string
+		stdout  string
+		want    string
+		wantErr string
+	}{
+		"version 1 is unchanged": {
+			session: &Session{Version: 1},
+			op:      "graph",
+			stdout:  `{"Docs":[{}]} garbage`,
+			want:    `{"Docs":[{}]} garbage`,
+		},
+		"plain": {
+			session: &Session{Version: 2, Capabilities: plain},
+			stdout:  `[1,2]`,
+			want:    `[1,2]`,
+		},
+		"multiple values without streaming": {
+			session: &Session{Version: 2},
+			stdout:  `[1] [2]`,
+			wantErr: "doesn't have the streaming-output capability",
+		},
+		"streamed arrays": {
+			session: &Session{Version: 2, Capabilities: []Capability{StreamingOutput}},
+			stdout:  "[1]\n[2,3]\nnull\n",
+			want:    `[1,2,3]`,
+		},
+		"streamed objects": {
+			session: &Session{Version: 2, Capabilities: plain},
+			op:      "graph",
+			stdout:  `{"Defs":[{"Path":"a"}]}` + "\n" + `{"Refs":[{"DefPath":"a"}]}` + "\n" + `{"Defs":[{"Path":"b"}]}`,
+			want:    `{"Defs":[{"Path":"a"},{"Path":"b"}],"Refs":[{"DefPath":"a"}]}`,
+		},
+		"streamed non-array property": {
+			session: &Session{Version: 2, Capabilities: plain},
+			stdout:  `{"Defs":[]} {"Defs":1}`,
+			wantErr: `property "Defs" must be an array`,
+		},
+		"JSON-RPC": {
+			session: &Session{Version: 2, Capabilities: []Capability{JSONRPC}},
+			stdout:  `{"jsonrpc":"2.0","id":1,"result":[1]}`,
+			want:    `[1]`,
+		},
+		"JSON-RPC streaming": {
+			session: &Session{Version: 2, Capabilities: []Capability{JSONRPC, StreamingOutput}},
+			stdout:  `{"jsonrpc":"2.0","method":"output","params":[1]} {"jsonrpc":"2.0","method":"output","params":[2]} {"jsonrpc":"2.0","id":1,"result":[3]}`,
+			want:    `[1,2,3]`,
+		},
+		"JSON-RPC notification without streaming": {
+			session: &Session{Version: 2, Capabilities: []Capability{JSONRPC}},
+			stdout:  `{"jsonrpc":"2.0","method":"output","params":[1]} {"jsonrpc":"2.0","id":1,"result":[3]}`,
+			wantErr: `unexpected JSON-RPC notification "output"`,
+		},
+		"JSON-RPC error": {
+			session: &Session{Toolchain: "tc", Version: 2, Capabilities: []Capability{JSONRPC}},
+			stdout:  `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"parse error in a.go","data":{"File":"a.go"}}}`,
+			wantErr: `toolchain tc: tool failed: parse error in a.go (JSON-RPC error -32000): {"File":"a.go"}`,
+		},
+		"JSON-RPC no response": {
+			session: &Session{Version: 2, Capabilities: []Capability{JSONRPC}},
+			stdout:  ``,
+			wantErr: "without sending a JSON-RPC response",
+		},
+		"JSON-RPC message after response": {
+			session: &Session{Version: 2, Capabilities: []Capability{JSONRPC}},
+			stdout:  `{"jsonrpc":"2.0","id":1,"result":[1]} {"jsonrpc":"2.0","id":2,"result":[1]}`,
+			wantErr: "after its response",
+		},
+		"undeclared docs and ref kinds are discarded": {
+			session: &Session{Version: 2},
+			op:      "graph",
+			stdout:  `{"Defs":[],"Docs":[{"Data":"x"}],"Refs":[{"DefPath":"a","Kind":"call"},{"DefPath":"b"}]}`,
+			want:    `{"Defs":[],"Refs":[{"DefPath":"a"},{"DefPath":"b"}]}`,
+		},
+		"declared docs and ref kinds are kept": {
+			session: &Session{Version: 2, Capabilities: []Capability{Docs, RefKinds}},
+			op:      "graph",
+			stdout:  `{"Docs":[{"Data":"x"}],"Refs":[{"DefPath":"a","Kind":"call"}]}`,
+			want:    `{"Docs":[{"Data":"x"}],"Refs":[{"DefPath":"a","Kind":"call"}]}`,
+		},
+		"only graph output is filtered": {
+			session: &Session{Version: 2},
+			op:      "scan",
+			stdout:  `[{"Docs":[1]}]`,
+			want:    `[{"Docs":[1]}]`,
+		},
+	}
+	for name, test := range tests {
+		got, err := test.session.DecodeOutput(test.op, []byte(test.stdout))
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want it to contain %q", name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !jsonEqual(got, []byte(test.want)) {
+			t.Errorf("%s: got %s, want %s", name, got, test.want)
+		}
+	}
+}
+
+// jsonEqual returns whether a and b are equal JSON values (or, if either is
+// invalid JSON, equal bytes).
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestTool_jsonrpc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclib-protocol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The program responds with the negotiated protocol and its request.
+	program := filepath.Join(dir, "program")
+	script := `#!/bin/sh
+read req
+printf '{"jsonrpc":"2.0","method":"output","params":{"Env":["%s","%s"]}}\n' "$SRCLIB_PROTOCOL_VERSION" "$SRCLIB_CAPABILITIES"
+printf '{"jsonrpc":"2.0","id":1,"result":{"Requests":[%s]}}\n' "$req"
+`
+	if err := ioutil.WriteFile(program, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{Toolchain: "example.com/tc", Version: 2, Capabilities: []Capability{StreamingOutput, JSONRPC}}
+	tl := &tool{tc: &programToolchain{program}, toolchain: "example.com/tc", subcmd: "scan", op: "scan", session: session}
+
+	var resp struct {
+		Env      []string
+		Requests []struct {
+			Method string
+			Params struct {
+				Args  []string
+				Input map[string]string
+			}
+		}
+	}
+	if err := tl.Run([]string{"-a"}, map[string]string{"k": "v"}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2", "streaming-output,json-rpc"}; !reflect.DeepEqual(resp.Env, want) {
+		t.Errorf("got env %v, want %v", resp.Env, want)
+	}
+	if len(resp.Requests) != 1 || resp.Requests[0].Method != "scan" || !reflect.DeepEqual(resp.Requests[0].Params.Args, []string{"-a"}) || resp.Requests[0].Params.Input["k"] != "v" {
+		t.Errorf("got requests %+v, want the scan request", resp.Requests)
+	}
+}
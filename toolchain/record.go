@@ -0,0 +1,120 @@
+package toolchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// RecordEnv is the environment variable that holds the path of the
+// directory that tool runs are recorded in (see Recording). If it is empty,
+// runs aren't recorded.
+const RecordEnv = "SRCLIB_RECORD_DIR"
+
+// ReplayEnv is the environment variable that holds the path of a directory
+// of recordings to replay. If it is set, tools aren't run; instead, the
+// output of the recorded run with the same toolchain, tool, args, and input
+// is replayed, so that a build can be reproduced without the toolchains'
+// language runtimes. Only the toolchains' Srclibtoolchain files are needed
+// (to choose the tools to run).
+const ReplayEnv = "SRCLIB_REPLAY_DIR"
+
+// A Recording is the exact input and output of a tool run.
+//
+// Recordings are stored in a directory named by their Key, which contains
+// a run.json file (the JSON encoding of the Recording) and stdin, stdout,
+// and stderr files.
+type Recording struct {
+	Toolchain string   // toolchain path
+	Tool      string   // tool subcommand
+	Args      []string // args passed to the tool (after the subcommand)
+
+	Stdin  []byte `json:"-"`
+	Stdout []byte `json:"-"`
+	Stderr []byte `json:"-"`
+
+	// Error is the error message if the tool failed.
+	Error string `json:",omitempty"`
+}
+
+const recordingFile = "run.json"
+
+// Key returns the name of the directory that r is stored in. It is derived
+// from the run's toolchain, tool, args, and stdin, so a replayed run
+// receives the output of the recorded run with exactly the same input.
+func (r *Recording) Key() string {
+	h := sha256.New()
+	for _, s := range append([]string{r.Toolchain, r.Tool}, r.Args...) {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	h.Write(r.Stdin)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Save writes r to dir, replacing any existing recording of the same run.
+func (r *Recording) Save(dir string) error {
+	runDir := filepath.Join(dir, r.Key())
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{recordingFile: data, "stdin": r.Stdin, "stdout": r.Stdout, "stderr": r.Stderr}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(runDir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindRecording returns the recording in dir of the run of the toolchain's
+// tool with args and stdin.
+func FindRecording(dir, toolchain, tool string, args []string, stdin []byte) (*Recording, error) {
+	r := &Recording{Toolchain: toolchain, Tool: tool, Args: args, Stdin: stdin}
+	runDir := filepath.Join(dir, r.Key())
+	data, err := ioutil.ReadFile(filepath.Join(runDir, recordingFile))
+	if os.IsNotExist(err) {
+		return nil, r.notRecordedError(dir)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("recording %s: %s", runDir, err)
+	}
+	if r.Stdout, err = ioutil.ReadFile(filepath.Join(runDir, "stdout")); err != nil {
+		return nil, err
+	}
+	if r.Stderr, err = ioutil.ReadFile(filepath.Join(runDir, "stderr")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// notRecordedError returns an error saying that r wasn't recorded in dir,
+// and how many runs of the same tool were (with different args or input),
+// to help determine why a replayed build diverged from the recorded one.
+func (r *Recording) notRecordedError(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("no recording of tool run %s %s %v: %s", r.Toolchain, r.Tool, r.Args, err)
+	}
+	var others int
+	for _, e := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(dir, e.Name(), recordingFile))
+		if err != nil {
+			continue
+		}
+		var other Recording
+		if err := json.Unmarshal(data, &other); err == nil && other.Toolchain == r.Toolchain && other.Tool == r.Tool {
+			others++
+		}
+	}
+	return fmt.Errorf("no recording in %s of tool run %s %s %v with this input (%d recorded runs of this tool had different args or input)", dir, r.Toolchain, r.Tool, r.Args, others)
+}
@@ -0,0 +1,82 @@
+package toolchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecording(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclib-recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Recording{Toolchain: "example.com/tc", Tool: "graph", Args: []string{"-a"}, Stdin: []byte("in"), Stdout: []byte("out"), Stderr: []byte("err"), Error: "exit status 1"}
+	if err := r.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FindRecording(dir, "example.com/tc", "graph", []string{"-a"}, []byte("in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("got %+v, want %+v", got, r)
+	}
+
+	_, err = FindRecording(dir, "example.com/tc", "graph", []string{"-a"}, []byte("other"))
+	if err == nil || !strings.Contains(err.Error(), "1 recorded runs of this tool") {
+		t.Errorf("got error %v, want it to mention the other recorded run", err)
+	}
+}
+
+func TestTool_recordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclib-recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The program echoes its input.
+	program := filepath.Join(dir, "program")
+	if err := ioutil.WriteFile(program, []byte("#!/bin/sh\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	recordings := filepath.Join(dir, "recordings")
+	tl := &tool{tc: &programToolchain{program}, toolchain: "example.com/tc", subcmd: "scan"}
+	input := map[string]string{"a": "b"}
+
+	os.Setenv(RecordEnv, recordings)
+	var recorded map[string]string
+	err = tl.Run(nil, input, &recorded)
+	os.Unsetenv(RecordEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, input) {
+		t.Errorf("recorded run: got output %v, want %v", recorded, input)
+	}
+
+	// Replay the run without the program.
+	if err := os.Remove(program); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(ReplayEnv, recordings)
+	defer os.Unsetenv(ReplayEnv)
+	var replayed map[string]string
+	if err := tl.Run(nil, input, &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, input) {
+		t.Errorf("replayed run: got output %v, want %v", replayed, input)
+	}
+
+	if err := tl.Run(nil, map[string]string{"c": "d"}, &replayed); err == nil {
+		t.Error("replaying an unrecorded run: got nil error, want non-nil")
+	}
+}
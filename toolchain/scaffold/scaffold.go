@@ -0,0 +1,161 @@
+// Package scaffold generates the files of a new toolchain: its
+// Srclibtoolchain, stubs of its scanner, grapher, and dependency resolver
+// tools (in one of several languages), a Dockerfile, and a fixture test
+// case for "src test".
+//
+// The generated toolchain works as is: its scanner finds the files with the
+// toolchain's file extensions and reports them as a single source unit, and
+// its other tools output nothing. Toolchain authors fill in the stubs.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Languages are the languages that tool stubs can be generated in.
+var Languages = []string{"go", "python", "sh"}
+
+// Options configures the toolchain that Write generates.
+type Options struct {
+	// Path is the toolchain path (e.g., "github.com/alice/srclib-foo").
+	Path string
+
+	// Language is the language that the tools are written in (one of
+	// Languages).
+	Language string
+
+	// UnitType is the type of the source units that the toolchain's scanner
+	// reports, and that its other tools operate on. If empty, it is derived
+	// from the toolchain path (e.g., "Foo" for "github.com/alice/srclib-foo").
+	UnitType string
+
+	// Extensions are the file extensions (e.g., ".foo") of the source files
+	// that the scanner finds. If empty, the lowercased UnitType is used as
+	// the only extension.
+	Extensions []string
+}
+
+// name returns the last component of the toolchain path, which is the
+// name of its program (in .bin/).
+func (o *Options) name() string { return path.Base(o.Path) }
+
+// unitType returns o.UnitType or, if it's empty, the type derived from the
+// toolchain path.
+func (o *Options) unitType() string {
+	if o.UnitType != "" {
+		return o.UnitType
+	}
+	t := []rune(strings.TrimPrefix(o.name(), "srclib-"))
+	if len(t) > 0 {
+		t[0] = unicode.ToUpper(t[0])
+	}
+	return string(t)
+}
+
+func (o *Options) extensions() []string {
+	if len(o.Extensions) == 0 {
+		return []string{"." + strings.ToLower(o.unitType())}
+	}
+	exts := make([]string, len(o.Extensions))
+	for i, ext := range o.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts[i] = ext
+	}
+	return exts
+}
+
+func (o *Options) validate() error {
+	if o.Path == "" || strings.HasPrefix(o.Path, "/") || path.Clean(o.Path) != o.Path || o.name() == "." {
+		return fmt.Errorf("invalid toolchain path %q (must be a clean relative path, such as github.com/alice/srclib-foo)", o.Path)
+	}
+	if _, present := files[o.Language]; !present {
+		return fmt.Errorf("unsupported language %q (must be one of %s)", o.Language, strings.Join(Languages, ", "))
+	}
+	for _, s := range append([]string{o.unitType()}, o.extensions()...) {
+		if s == "" || s == "." || strings.ContainsAny(s, " \t\n/\\'\"*?[]$`") {
+			return fmt.Errorf("invalid unit type or extension %q", s)
+		}
+	}
+	return nil
+}
+
+// A file is a file in a generated toolchain.
+type file struct {
+	name string // slash-separated path, relative to the toolchain dir (a template)
+	mode os.FileMode
+	tmpl string
+}
+
+// Write generates a new toolchain in dir (which must not contain any of the
+// files to generate). It returns the names of the files it wrote, relative
+// to dir.
+func Write(dir string, opt Options) ([]string, error) {
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	data := struct {
+		Path, Name, UnitType, Language string
+		Extensions                     []string
+	}{opt.Path, opt.name(), opt.unitType(), opt.Language, opt.extensions()}
+
+	fs := append(append([]file(nil), commonFiles...), files[opt.Language]...)
+	contents := map[string][]byte{}
+	modes := map[string]os.FileMode{}
+	for _, f := range fs {
+		name, err := execute(f.name, f.name, data)
+		if err != nil {
+			return nil, err
+		}
+		contents[string(name)], err = execute(f.name, f.tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		modes[string(name)] = f.mode
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return nil, fmt.Errorf("file %s already exists in %s (not overwriting it)", name, dir)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(p, contents[name], modes[name]); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func execute(name, tmpl string, data interface{}) ([]byte, error) {
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,160 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/schema"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		opt            Options
+		wantUnitType   string
+		wantExtensions []string
+		wantErr        string
+	}{
+		{opt: Options{Path: "github.com/alice/srclib-foo", Language: "sh"}, wantUnitType: "Foo", wantExtensions: []string{".foo"}},
+		{opt: Options{Path: "example.com/bar", Language: "go", UnitType: "BarPackage", Extensions: []string{"b", ".bar"}}, wantUnitType: "BarPackage", wantExtensions: []string{".b", ".bar"}},
+		{opt: Options{Path: "", Language: "sh"}, wantErr: "invalid toolchain path"},
+		{opt: Options{Path: "/abs/foo", Language: "sh"}, wantErr: "invalid toolchain path"},
+		{opt: Options{Path: "a/../foo", Language: "sh"}, wantErr: "invalid toolchain path"},
+		{opt: Options{Path: "example.com/srclib-", Language: "sh"}, wantErr: "invalid unit type"},
+		{opt: Options{Path: "example.com/foo", Language: "cobol"}, wantErr: "unsupported language"},
+		{opt: Options{Path: "example.com/foo", Language: "sh", Extensions: []string{"*"}}, wantErr: "invalid unit type or extension"},
+	}
+	for _, test := range tests {
+		err := test.opt.validate()
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%+v: got error %v, want it to contain %q", test.opt, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %s", test.opt, err)
+			continue
+		}
+		if ut := test.opt.unitType(); ut != test.wantUnitType {
+			t.Errorf("%+v: got unit type %q, want %q", test.opt, ut, test.wantUnitType)
+		}
+		if exts := test.opt.extensions(); !reflect.DeepEqual(exts, test.wantExtensions) {
+			t.Errorf("%+v: got extensions %v, want %v", test.opt, exts, test.wantExtensions)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, lang := range Languages {
+		dir, err := ioutil.TempDir("", "srclib-scaffold")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		opt := Options{Path: "example.com/srclib-foo", Language: lang}
+		tcDir := filepath.Join(dir, filepath.FromSlash(opt.Path))
+		names, err := Write(tcDir, opt)
+		if err != nil {
+			t.Fatalf("%s: %s", lang, err)
+		}
+		for _, name := range []string{"Srclibtoolchain", "Dockerfile", "README.md", "testdata/case/sample/sample.foo"} {
+			if _, err := os.Stat(filepath.Join(tcDir, name)); err != nil {
+				t.Errorf("%s: %s (wrote %v)", lang, err, names)
+			}
+		}
+		if _, err := Write(tcDir, opt); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("%s: got error %v writing again, want it to refuse to overwrite files", lang, err)
+		}
+
+		orig := srclib.Path
+		srclib.Path = dir
+		testTools(t, lang, tcDir, opt.Path)
+		srclib.Path = orig
+	}
+}
+
+// testTools checks that the generated toolchain's tools (if they can be
+// built and run here) output valid data.
+func testTools(t *testing.T, lang, tcDir, path string) {
+	switch lang {
+	case "go":
+		src, err := ioutil.ReadFile(filepath.Join(tcDir, "main.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmtSrc, err := format.Source(src); err != nil {
+			t.Fatalf("go: main.go: %s", err)
+		} else if string(fmtSrc) != string(src) {
+			t.Errorf("go: main.go is not gofmt'd")
+		}
+		if _, err := exec.LookPath("go"); err != nil {
+			t.Logf("go: skipping running tools: %s", err)
+			return
+		}
+		cmd := exec.Command("go", "build", "-o", ".bin/srclib-foo", ".")
+		cmd.Dir = tcDir
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GO111MODULE=on")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go: building: %s\n%s", err, out)
+		}
+	case "python":
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Logf("python: skipping running tools: %s", err)
+			return
+		}
+	}
+
+	caseDir := filepath.Join(tcDir, "testdata", "case", "sample")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(caseDir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	run := func(subcmd string, args []string, input interface{}) []byte {
+		tool, err := toolchain.OpenTool(path, subcmd, toolchain.AsProgram)
+		if err != nil {
+			t.Fatalf("%s: %s", lang, err)
+		}
+		var out json.RawMessage
+		if err := tool.Run(args, input, &out); err != nil {
+			t.Fatalf("%s %s: %s", lang, subcmd, err)
+		}
+		if err := schema.CheckOutput(lang+" "+subcmd, subcmd, out, schema.Strict); err != nil {
+			t.Errorf("%s %s: %s", lang, subcmd, err)
+		}
+		return out
+	}
+
+	var units []*unit.SourceUnit
+	if err := json.Unmarshal(run("scan", []string{"--repo", "example.com/r", "--subdir", "."}, map[string]interface{}{}), &units); err != nil {
+		t.Fatal(err)
+	}
+	want := []*unit.SourceUnit{{
+		Name:  "sample",
+		Type:  "Foo",
+		Repo:  "example.com/r",
+		Files: []string{"sample.foo"},
+		Dir:   ".",
+		Ops:   map[string]*toolchain.ToolRef{"graph": nil, "depresolve": nil},
+	}}
+	if !reflect.DeepEqual(units, want) {
+		t.Fatalf("%s scan: got units %+v, want %+v", lang, units, want)
+	}
+	run("graph", nil, units[0])
+	run("depresolve", nil, units[0])
+}
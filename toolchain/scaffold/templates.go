@@ -0,0 +1,374 @@
+package scaffold
+
+// commonFiles are generated for toolchains in all languages.
+var commonFiles = []file{
+	{name: "Srclibtoolchain", mode: 0644, tmpl: `{
+  "Tools": [
+    {
+      "Subcmd": "scan",
+      "Op": "scan",
+      "SourceUnitTypes": ["{{.UnitType}}"]
+    },
+    {
+      "Subcmd": "graph",
+      "Op": "graph",
+      "SourceUnitTypes": ["{{.UnitType}}"]
+    },
+    {
+      "Subcmd": "depresolve",
+      "Op": "depresolve",
+      "SourceUnitTypes": ["{{.UnitType}}"]
+    }
+  ]
+}
+`},
+	{name: ".gitignore", mode: 0644, tmpl: `{{if eq .Language "go"}}/.bin/
+{{end}}/testdata/actual/
+`},
+	{name: "testdata/case/sample/sample{{index .Extensions 0}}", mode: 0644, tmpl: `TODO: Replace this file with a {{.UnitType}} source file (or more) that tests
+the toolchain's tools.
+`},
+	{name: "README.md", mode: 0644, tmpl: `# {{.Path}}
+
+A [srclib](https://srclib.org) toolchain for {{.UnitType}} source units
+({{join .Extensions ", "}} files).
+
+## Tools
+
+The toolchain's program, ` + "`.bin/{{.Name}}`" + `{{if eq .Language "go"}} (built from ` + "`main.go`" + `){{end}},
+implements these tools (as subcommands):
+
+* ` + "`scan`" + `: finds {{.UnitType}} source units in the current directory. (It
+  currently reports all {{join .Extensions ", "}} files as one source unit.)
+* ` + "`graph`" + `: outputs the defs, refs, and docs of a source unit. (TODO)
+* ` + "`depresolve`" + `: resolves a source unit's dependencies. (TODO)
+
+Their input, output, and options are specified in the
+[toolchain overview](https://srclib.org/toolchains/overview/). Run ` + "`src toolchain\nschema OP`" + ` to print the JSON Schema of a tool's output.
+
+## Development
+
+{{if eq .Language "go"}}Build the program with ` + "`make`" + `. Then add{{else}}Add{{end}} this directory to your SRCLIBPATH as the
+toolchain {{.Path}}:
+
+    src toolchain add --dir . {{.Path}}
+
+Run its tools with ` + "`src tool {{.Path}} TOOL`" + ` (e.g., in a directory
+with {{index .Extensions 0}} files), or build a project with ` + "`src do-all`" + `.
+
+## Testing
+
+Each directory in ` + "`testdata/case/`" + ` is a test case (a plain directory, or a
+repository such as a git submodule). Run ` + "`src test -m program`" + `
+to build them and compare their build data to the expected output in
+` + "`testdata/expected/`" + `. The first time (and whenever you change the tools'
+output), run ` + "`src test -m program --update`" + ` to write the expected
+output, review it, and commit it.
+`},
+}
+
+// files are the language-specific files of toolchains, keyed by language.
+var files = map[string][]file{
+	"sh": {
+		{name: ".bin/{{.Name}}", mode: 0755, tmpl: `#!/bin/sh
+#
+# {{.Name}} is the program of the srclib toolchain {{.Path}}.
+# It runs the tool named by its first argument (scan, graph, or depresolve),
+# which reads JSON input on stdin and writes JSON output on stdout.
+
+set -e
+
+# json prints its argument as a JSON string.
+json() {
+	printf '"%s"' "$(printf '%s' "$1" | sed 's/\\/\\\\/g; s/"/\\"/g')"
+}
+
+scan() {
+	repo=
+	while [ $# -gt 0 ]; do
+		case $1 in
+		--repo) repo=$2; shift ;;
+		--repo=*) repo=${1#--repo=} ;;
+		esac
+		shift
+	done
+	cat >/dev/null # the repository config
+
+	# TODO: Find the {{.UnitType}} source units. This reports all {{join .Extensions ", "}} files
+	# as a single source unit.
+	files=$(find . -name .git -prune -o -type f \( {{range $i, $ext := .Extensions}}{{if $i}} -o {{end}}-name '*{{$ext}}'{{end}} \) -print |
+		sed 's|^\./||' | LC_ALL=C sort |
+		while IFS= read -r f; do printf '%s,' "$(json "$f")"; done)
+	if [ -z "$files" ]; then
+		echo '[]'
+		return
+	fi
+	printf '[{"Name":%s,"Type":"{{.UnitType}}","Repo":%s,"Globs":null,"Files":[%s],"Dir":".","Ops":{"depresolve":null,"graph":null}}]\n' \
+		"$(json "$(basename "$PWD")")" "$(json "$repo")" "${files%,}"
+}
+
+graph() {
+	cat >/dev/null # the source unit
+
+	# TODO: Analyze the source unit's files and output their defs, refs,
+	# and docs.
+	echo '{"Defs":[],"Refs":[],"Docs":[]}'
+}
+
+depresolve() {
+	cat >/dev/null # the source unit
+
+	# TODO: Output a resolution of each of the source unit's Dependencies
+	# (in the same order).
+	echo '[]'
+}
+
+case $1 in
+scan | graph | depresolve)
+	tool=$1
+	shift
+	$tool "$@"
+	;;
+*)
+	echo 'usage: {{.Name}} scan|graph|depresolve [OPTION...]' >&2
+	exit 2
+	;;
+esac
+`},
+		{name: "Dockerfile", mode: 0644, tmpl: `FROM alpine:3
+COPY .bin/{{.Name}} /usr/local/bin/{{.Name}}
+WORKDIR /src
+ENTRYPOINT ["{{.Name}}"]
+`},
+	},
+
+	"python": {
+		{name: ".bin/{{.Name}}", mode: 0755, tmpl: `#!/usr/bin/env python3
+"""{{.Name}} is the program of the srclib toolchain {{.Path}}.
+
+It runs the tool named by its first argument (scan, graph, or depresolve),
+which reads JSON input on stdin and writes JSON output on stdout.
+"""
+
+import argparse
+import json
+import os
+import sys
+
+UNIT_TYPE = {{quote .UnitType}}
+EXTENSIONS = ({{range .Extensions}}{{quote .}}, {{end}})
+
+
+def scan(args):
+    json.load(sys.stdin)  # the repository config
+
+    # TODO: Find the {{.UnitType}} source units. This reports all {{join .Extensions ", "}}
+    # files as a single source unit.
+    files = []
+    for dirpath, dirnames, filenames in os.walk("."):
+        dirnames[:] = [d for d in dirnames if d != ".git"]
+        for name in filenames:
+            if name.endswith(EXTENSIONS):
+                files.append(os.path.relpath(os.path.join(dirpath, name)).replace(os.sep, "/"))
+    if not files:
+        return []
+    return [{
+        "Name": os.path.basename(os.getcwd()),
+        "Type": UNIT_TYPE,
+        "Repo": args.repo,
+        "Globs": None,
+        "Files": sorted(files),
+        "Dir": ".",
+        "Ops": {"depresolve": None, "graph": None},
+    }]
+
+
+def graph(args):
+    json.load(sys.stdin)  # the source unit
+
+    # TODO: Analyze the source unit's files and output their defs, refs, and
+    # docs.
+    return {"Defs": [], "Refs": [], "Docs": []}
+
+
+def depresolve(args):
+    json.load(sys.stdin)  # the source unit
+
+    # TODO: Output a resolution of each of the source unit's Dependencies (in
+    # the same order).
+    return []
+
+
+def main():
+    parser = argparse.ArgumentParser(prog={{quote .Name}})
+    tools = parser.add_subparsers(dest="tool", required=True)
+    scan_parser = tools.add_parser("scan")
+    scan_parser.add_argument("--repo", default="")
+    scan_parser.add_argument("--subdir", default=".")
+    scan_parser.set_defaults(run=scan)
+    tools.add_parser("graph").set_defaults(run=graph)
+    tools.add_parser("depresolve").set_defaults(run=depresolve)
+    args, _ = parser.parse_known_args()
+    json.dump(args.run(args), sys.stdout)
+    sys.stdout.write("\n")
+
+
+if __name__ == "__main__":
+    main()
+`},
+		{name: "Dockerfile", mode: 0644, tmpl: `FROM python:3-alpine
+COPY .bin/{{.Name}} /usr/local/bin/{{.Name}}
+WORKDIR /src
+ENTRYPOINT ["{{.Name}}"]
+`},
+	},
+
+	"go": {
+		{name: "main.go", mode: 0644, tmpl: `// Command {{.Name}} is the program of the srclib toolchain
+// {{.Path}}. It runs the tool named by its first argument (scan,
+// graph, or depresolve), which reads JSON input on stdin and writes JSON
+// output on stdout.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const unitType = {{quote .UnitType}}
+
+var extensions = []string{ {{- range $i, $ext := .Extensions}}{{if $i}}, {{end}}{{quote $ext}}{{end -}} }
+
+// sourceUnit is a source unit, as output by the scanner and input to the
+// other tools.
+type sourceUnit struct {
+	Name         string
+	Type         string
+	Repo         string
+	Globs        []string
+	Files        []string
+	Dir          string
+	Dependencies []interface{} ` + "`json:\",omitempty\"`" + `
+	Ops          map[string]interface{}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("{{.Name}}: ")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: {{.Name}} scan|graph|depresolve [OPTION...]")
+		os.Exit(2)
+	}
+	tools := map[string]func(args []string) (interface{}, error){
+		"scan":       scan,
+		"graph":      graph,
+		"depresolve": depresolve,
+	}
+	tool, present := tools[os.Args[1]]
+	if !present {
+		log.Fatalf("unknown tool %q", os.Args[1])
+	}
+	out, err := tool(os.Args[2:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func scan(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	repo := fs.String("repo", "", "URI of the repository being scanned")
+	fs.String("subdir", ".", "path of the current directory in the repository")
+	fs.Parse(args)
+	if _, err := ioutil.ReadAll(os.Stdin); err != nil { // the repository config
+		return nil, err
+	}
+
+	// TODO: Find the {{.UnitType}} source units. This reports all {{join .Extensions ", "}}
+	// files as a single source unit.
+	var files []string
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		for _, ext := range extensions {
+			if info.Mode().IsRegular() && strings.HasSuffix(path, ext) {
+				files = append(files, filepath.ToSlash(path))
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return []*sourceUnit{}, nil
+	}
+	sort.Strings(files)
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return []*sourceUnit{{"{{"}}
+		Name:  filepath.Base(dir),
+		Type:  unitType,
+		Repo:  *repo,
+		Files: files,
+		Dir:   ".",
+		Ops:   map[string]interface{}{"graph": nil, "depresolve": nil},
+	{{"}}"}}, nil
+}
+
+func graph(args []string) (interface{}, error) {
+	var u sourceUnit
+	if err := json.NewDecoder(os.Stdin).Decode(&u); err != nil {
+		return nil, err
+	}
+
+	// TODO: Analyze u.Files and output their defs, refs, and docs.
+	return map[string][]interface{}{"Defs": {}, "Refs": {}, "Docs": {}}, nil
+}
+
+func depresolve(args []string) (interface{}, error) {
+	var u sourceUnit
+	if err := json.NewDecoder(os.Stdin).Decode(&u); err != nil {
+		return nil, err
+	}
+
+	// TODO: Output a resolution of each of u.Dependencies (in the same
+	// order).
+	return []interface{}{}, nil
+}
+`},
+		{name: "go.mod", mode: 0644, tmpl: `module {{.Path}}
+
+go 1.18
+`},
+		{name: "Makefile", mode: 0644, tmpl: `.bin/{{.Name}}: $(wildcard *.go) go.mod
+	go build -o $@ .
+`},
+		{name: "Dockerfile", mode: 0644, tmpl: `FROM golang:1-alpine AS build
+WORKDIR /go/src/{{.Name}}
+COPY go.mod *.go ./
+RUN CGO_ENABLED=0 go build -o /{{.Name}} .
+
+FROM alpine:3
+COPY --from=build /{{.Name}} /usr/local/bin/{{.Name}}
+WORKDIR /src
+ENTRYPOINT ["{{.Name}}"]
+`},
+	},
+}
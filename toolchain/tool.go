@@ -1,6 +1,7 @@
 package toolchain
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -91,10 +92,33 @@ func ListTools(op string) ([]*ToolRef, error) {
 func OpenTool(toolchain, subcmd string, mode Mode) (Tool, error) {
 	tc, err := Open(toolchain, mode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open tool (%s %s): %s", toolchain, subcmd, err)
+		msg := fmt.Sprintf("failed to open tool (%s %s): %s", toolchain, subcmd, err)
+		if IsNotFound(err) {
+			return nil, &NotFoundError{msg}
+		}
+		return nil, errors.New(msg)
 	}
 
-	return &tool{tc, subcmd}, nil
+	info, err := Lookup(toolchain)
+	if err != nil {
+		return nil, err
+	}
+	c, err := info.ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+	session, err := Negotiate(info.Path, c.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	for _, ti := range c.Tools {
+		if ti.Subcmd == subcmd {
+			op = ti.Op
+		}
+	}
+
+	return &tool{tc: tc, toolchain: toolchain, subcmd: subcmd, op: op, session: session}, nil
 }
 
 // A Tool is a subcommand of a Toolchain that performs an single operation, such
@@ -109,8 +133,11 @@ type Tool interface {
 }
 
 type tool struct {
-	tc     Toolchain
-	subcmd string
+	tc        Toolchain
+	toolchain string // toolchain path
+	subcmd    string
+	op        string   // the operation the tool performs, if known
+	session   *Session // the negotiated protocol (nil means version 1)
 }
 
 func (t *tool) Command() (*exec.Cmd, error) {
@@ -120,57 +147,145 @@ func (t *tool) Command() (*exec.Cmd, error) {
 		return nil, err
 	}
 	cmd.Args = append(cmd.Args, t.subcmd)
+	if env := t.session.Env(); env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	return cmd, nil
 }
 
 // TODO(sqs): is it possible for an early return to leave the subprocess running?
 func (t *tool) Run(arg []string, input, resp interface{}) error {
+	if dir := os.Getenv(ReplayEnv); dir != "" {
+		return t.replay(dir, arg, input, resp)
+	}
+	if dir := os.Getenv(RecordEnv); dir != "" {
+		return t.record(dir, arg, input, resp)
+	}
+
 	cmd, err := t.Command()
 	if err != nil {
 		return err
 	}
 	cmd.Args = append(cmd.Args, arg...)
-	cmd.Stderr = os.Stderr
 
-	log.Printf("Running: %v", cmd.Args)
+	if Verbosity() >= Verbose {
+		log.Printf("Running: %v", cmd.Args)
+	}
 
-	var stdin io.WriteCloser
-	if input != nil {
-		data, err := json.Marshal(input)
-		if err != nil {
-			return err
-		}
-		log.Printf("  --> with input %s", data)
+	stdin, err := marshalInput(input)
+	if err != nil {
+		return err
+	}
+	stderr := NewStderrWriter(os.Stderr, RunTag(t.subcmd, stdin))
+	if stdin, err = t.session.EncodeInput(t.subcmd, arg, stdin); err != nil {
+		return err
+	}
+	var stdout bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+	stderr.Close(runErr != nil)
 
-		stdin, err = cmd.StdinPipe()
-		if err != nil {
-			return err
-		}
+	output, err := t.session.DecodeOutput(t.op, stdout.Bytes())
+	if err != nil {
+		return err
 	}
+	if err := json.NewDecoder(bytes.NewReader(output)).Decode(resp); err != nil {
+		return err
+	}
+	return runErr
+}
 
-	stdout, err := cmd.StdoutPipe()
+// record is like Run, but it also saves a Recording of the run in dir.
+func (t *tool) record(dir string, arg []string, input, resp interface{}) error {
+	cmd, err := t.Command()
 	if err != nil {
 		return err
 	}
-	if err := cmd.Start(); err != nil {
+	cmd.Args = append(cmd.Args, arg...)
+
+	if Verbosity() >= Verbose {
+		log.Printf("Running (and recording in %s): %v", dir, cmd.Args)
+	}
+
+	stdin, err := marshalInput(input)
+	if err != nil {
 		return err
 	}
+	encoded, err := t.session.EncodeInput(t.subcmd, arg, stdin)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	tagged := NewStderrWriter(os.Stderr, RunTag(t.subcmd, stdin))
+	cmd.Stdin = bytes.NewReader(encoded)
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.MultiWriter(tagged, &stderr)
+	runErr := cmd.Run()
+	tagged.Close(runErr != nil)
 
-	if input != nil {
-		if err := json.NewEncoder(stdin).Encode(input); err != nil {
-			return err
-		}
-		if err := stdin.Close(); err != nil {
-			return err
-		}
+	// Record the tool's input and output as they would be for protocol
+	// version 1, so that replaying doesn't depend on the protocol.
+	output, err := t.session.DecodeOutput(t.op, stdout.Bytes())
+	if err != nil {
+		output, runErr = stdout.Bytes(), err
+	}
+	r := &Recording{Toolchain: t.toolchain, Tool: t.subcmd, Args: arg, Stdin: stdin, Stdout: output, Stderr: stderr.Bytes()}
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	if err := r.Save(dir); err != nil {
+		return err
 	}
 
-	if err := json.NewDecoder(stdout).Decode(resp); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(output)).Decode(resp); err != nil {
 		return err
 	}
-	if err := cmd.Wait(); err != nil {
+	return runErr
+}
+
+// replay is like Run, but instead of running the tool, it replays the
+// output of the run's Recording in dir.
+func (t *tool) replay(dir string, arg []string, input, resp interface{}) error {
+	if Verbosity() >= Verbose {
+		log.Printf("Replaying (from %s): %s %s %v", dir, t.toolchain, t.subcmd, arg)
+	}
+
+	stdin, err := marshalInput(input)
+	if err != nil {
+		return err
+	}
+	r, err := FindRecording(dir, t.toolchain, t.subcmd, arg, stdin)
+	if err != nil {
 		return err
 	}
+	stderr := NewStderrWriter(os.Stderr, RunTag(t.subcmd, stdin))
+	stderr.Write(r.Stderr)
+	stderr.Close(r.Error != "")
 
+	if err := json.NewDecoder(bytes.NewReader(r.Stdout)).Decode(resp); err != nil {
+		return err
+	}
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
 	return nil
 }
+
+// marshalInput returns the tool's input as it is recorded, and as Run sends
+// it on stdin to version 1 tools: the JSON encoding of input (followed by a
+// newline), or nil if input is nil.
+func marshalInput(input interface{}) ([]byte, error) {
+	if input == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	if Verbosity() >= VeryVerbose {
+		log.Printf("  --> with input %s", data)
+	}
+	return append(data, '\n'), nil
+}
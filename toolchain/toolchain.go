@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"sourcegraph.com/sourcegraph/srclib/network"
 	"sourcegraph.com/sourcegraph/srclib/util"
 
 	"github.com/fsouza/go-dockerclient"
@@ -55,6 +56,19 @@ func (t *Info) ReadConfig() (*Config, error) {
 	return c, nil
 }
 
+// Version returns the commit ID that the toolchain's directory is checked
+// out at, or "" if it isn't in a git repository (e.g., a toolchain that was
+// copied into the SRCLIBPATH).
+func (t *Info) Version() string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	cmd.Dir = t.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(out))
+}
+
 // A Mode value is a set of flags (or 0) that control how toolchains are used.
 type Mode uint
 
@@ -64,6 +78,10 @@ const (
 
 	// AsDockerContainer enables the use of Docker container toolchains.
 	AsDockerContainer
+
+	// AsKubernetesJob enables running toolchains (that specify an Image in
+	// their Srclibtoolchain) as Kubernetes Jobs.
+	AsKubernetesJob
 )
 
 func (m Mode) String() string {
@@ -74,9 +92,18 @@ func (m Mode) String() string {
 	if m&AsDockerContainer > 0 {
 		s = append(s, "run as docker container")
 	}
+	if m&AsKubernetesJob > 0 {
+		s = append(s, "run as kubernetes job")
+	}
 	return strings.Join(s, " | ")
 }
 
+// Verify, if non-nil, is called by Open before it opens a toolchain as a
+// program or Docker container (which run code from the toolchain's
+// directory), to check that the toolchain may be run. If it returns an
+// error, Open fails.
+var Verify func(tc *Info) error
+
 // Open opens a toolchain by path. The mode parameter controls how it is opened.
 func Open(path string, mode Mode) (Toolchain, error) {
 	tc, err := Lookup(path)
@@ -84,6 +111,12 @@ func Open(path string, mode Mode) (Toolchain, error) {
 		return nil, err
 	}
 
+	if Verify != nil && (mode&AsProgram > 0 && tc.Program != "" || mode&AsDockerContainer > 0 && tc.Dockerfile != "") {
+		if err := Verify(tc); err != nil {
+			return nil, err
+		}
+	}
+
 	if mode&AsProgram > 0 && tc.Program != "" {
 		return &programToolchain{filepath.Join(tc.Dir, tc.Program)}, nil
 	}
@@ -95,16 +128,29 @@ func Open(path string, mode Mode) (Toolchain, error) {
 		}
 		return newDockerToolchain(tc.Path, tc.Dir, tc.Dockerfile, wd)
 	}
+	if mode&AsKubernetesJob > 0 {
+		c, err := tc.ReadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if c.Image != "" {
+			if len(c.Entrypoint) == 0 {
+				return nil, fmt.Errorf("toolchain %s has an Image but no Entrypoint in its %s", path, ConfigFilename)
+			}
+			return &kubernetesToolchain{c.Image, c.Entrypoint}, nil
+		}
+	}
 
 	if tc.Program != "" || tc.Dockerfile != "" {
-		return nil, fmt.Errorf("toolchain %s exists but is not usable in current mode (%s)", path, mode)
+		return nil, &NotFoundError{fmt.Sprintf("toolchain %s exists but is not usable in current mode (%s)", path, mode)}
 	}
 	return nil, os.ErrNotExist
 }
 
 // A Toolchain is either a local executable program or a Docker container that
-// wraps such a program. Toolchains contain tools (as subcommands), which
-// perform actions or analysis on a project's source code.
+// wraps such a program (which may be run locally or as a Kubernetes Job).
+// Toolchains contain tools (as subcommands), which perform actions or
+// analysis on a project's source code.
 type Toolchain interface {
 	// Command returns an *exec.Cmd that will execute this toolchain. Do not use
 	// this to execute a tool in this toolchain; use OpenTool instead.
@@ -219,6 +265,78 @@ func (t *dockerToolchain) Command() (*exec.Cmd, error) {
 	// TODO(sqs): once all the toolchains have a "USER srclib" directive, add:
 	//   "--user", "srclib"
 	// to the run options below.
-	cmd := exec.Command("docker", "run", "-i", "--volume="+t.hostVolumeDir+":/src:ro", t.imageName)
+	// Pass the negotiated protocol (see Session.Env) and the network
+	// settings through to the tool, if they are set.
+	args := []string{"run", "-i", "--volume=" + t.hostVolumeDir + ":/src:ro"}
+	for _, name := range append([]string{ProtocolVersionEnv, CapabilitiesEnv}, network.EnvNames...) {
+		args = append(args, "--env="+name)
+	}
+	volumes, err := depSourcesVolumes()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		args = append(args, "--volume="+v+":"+v+":ro")
+	}
+	if len(volumes) > 0 {
+		args = append(args, "--env="+DepSourcesEnv+"="+volumes[0])
+	}
+	if file := os.Getenv(SingleFileContentsEnv); file != "" {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--volume="+file+":"+file+":ro", "--env="+SingleFileEnv, "--env="+SingleFileContentsEnv+"="+file)
+	}
+	cmd := exec.Command("docker", append(args, t.imageName)...)
 	return cmd, nil
 }
+
+// depSourcesVolumes returns the host paths to mount (at the same paths) in
+// a container so that the tool can read the dependency checkouts listed in
+// the file named by DepSourcesEnv, if it is set. The first path is the
+// absolute path of that file.
+func depSourcesVolumes() ([]string, error) {
+	file := os.Getenv(DepSourcesEnv)
+	if file == "" {
+		return nil, nil
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var srcs []struct{ Dir string }
+	if err := json.Unmarshal(data, &srcs); err != nil {
+		return nil, fmt.Errorf("invalid dependency sources file %s: %s", file, err)
+	}
+	volumes := []string{file}
+	for _, src := range srcs {
+		volumes = append(volumes, src.Dir)
+	}
+	return volumes, nil
+}
+
+// kubernetesToolchain is a prebuilt Docker image that is run as a Kubernetes
+// Job.
+type kubernetesToolchain struct {
+	image      string
+	entrypoint []string
+}
+
+// IsBuilt always returns true, since the image must already be in a registry.
+func (t *kubernetesToolchain) IsBuilt() (bool, error) { return true, nil }
+
+// Build is a no-op, since the image must already be in a registry.
+func (t *kubernetesToolchain) Build() error { return nil }
+
+// Command returns an *exec.Cmd that runs the toolchain in a Kubernetes Job
+// (using `src internal kube-job`, which is configured by the SRCLIB_KUBE_*
+// environment variables).
+func (t *kubernetesToolchain) Command() (*exec.Cmd, error) {
+	args := append([]string{"internal", "kube-job", "--image", t.image, "--"}, t.entrypoint...)
+	return exec.Command("src", args...), nil
+}
@@ -0,0 +1,299 @@
+// Package toolchaintest provides a fake toolchain for tests of the code that
+// runs toolchains and uses their output (such as scanning, the build engine,
+// and the store and serve layers), so that it can be tested without any real
+// language toolchain installed.
+//
+// A Toolchain's tools can be run in memory (see Toolchain.Tool), or the
+// Toolchain can be installed in the SRCLIBPATH (see Toolchain.Install) so
+// that it is found and run like any other toolchain, including by "src tool"
+// in Makefile recipes. An installed toolchain's program is the test binary
+// itself, so test packages that install toolchains must call Main from their
+// TestMain function:
+//
+//	func TestMain(m *testing.M) {
+//		toolchaintest.Main()
+//		os.Exit(m.Run())
+//	}
+package toolchaintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+const (
+	// DefaultPath is the toolchain path of a Toolchain whose Path is empty.
+	DefaultPath = "srclib.test/fake"
+
+	// DefaultUnitType is the source unit type of a Toolchain whose UnitType
+	// is empty.
+	DefaultUnitType = "Fake"
+)
+
+// A Toolchain is a fake toolchain whose scanner ("scan"), grapher ("graph"),
+// and dependency resolver ("depresolve") tools output the values of its
+// fields.
+type Toolchain struct {
+	// Path is the toolchain path (DefaultPath if empty).
+	Path string
+
+	// UnitType is the type of the source units that the toolchain scans
+	// for and whose graph and dependency resolution tools it provides
+	// (DefaultUnitType if empty).
+	UnitType string
+
+	// Units are the source units that the scanner outputs. Units whose
+	// Type is empty are given the toolchain's UnitType.
+	Units []*unit.SourceUnit
+
+	// Graphs is the output of the grapher, keyed by source unit name. The
+	// grapher outputs no defs or refs for source units that aren't in it.
+	Graphs map[string]*grapher.Output
+
+	// Deps is the output of the dependency resolver, keyed by source unit
+	// name.
+	Deps map[string][]*dep.Resolution
+
+	// Failures injects failures: a tool fails with the error message
+	// Failures[SUBCMD] or (for a tool that operates on a source unit)
+	// Failures[SUBCMD + " " + UNITNAME] instead of outputting anything.
+	Failures map[string]string
+
+	program string // installed program path, if Install was called
+}
+
+func (t *Toolchain) path() string {
+	if t.Path == "" {
+		return DefaultPath
+	}
+	return t.Path
+}
+
+func (t *Toolchain) unitType() string {
+	if t.UnitType == "" {
+		return DefaultUnitType
+	}
+	return t.UnitType
+}
+
+// config returns the toolchain's Srclibtoolchain config.
+func (t *Toolchain) config() *toolchain.Config {
+	return &toolchain.Config{Tools: []*toolchain.ToolInfo{
+		{Subcmd: "scan", Op: "scan", SourceUnitTypes: []string{t.unitType()}},
+		{Subcmd: "graph", Op: "graph", SourceUnitTypes: []string{t.unitType()}},
+		{Subcmd: "depresolve", Op: "depresolve", SourceUnitTypes: []string{t.unitType()}},
+	}}
+}
+
+// run returns the output of the named tool given its input on stdin.
+func (t *Toolchain) run(subcmd string, stdin []byte) (interface{}, error) {
+	if msg, present := t.Failures[subcmd]; present {
+		return nil, fmt.Errorf("%s %s: %s", t.path(), subcmd, msg)
+	}
+
+	if subcmd == "scan" {
+		units := make([]*unit.SourceUnit, len(t.Units))
+		for i, u := range t.Units {
+			u2 := *u
+			if u2.Type == "" {
+				u2.Type = t.unitType()
+			}
+			units[i] = &u2
+		}
+		return units, nil
+	}
+
+	var u unit.SourceUnit
+	if err := json.Unmarshal(stdin, &u); err != nil {
+		return nil, fmt.Errorf("%s %s: reading source unit: %s", t.path(), subcmd, err)
+	}
+	if msg, present := t.Failures[subcmd+" "+u.Name]; present {
+		return nil, fmt.Errorf("%s %s: %s", t.path(), subcmd, msg)
+	}
+	switch subcmd {
+	case "graph":
+		if o := t.Graphs[u.Name]; o != nil {
+			return o, nil
+		}
+		return &grapher.Output{}, nil
+	case "depresolve":
+		if deps := t.Deps[u.Name]; deps != nil {
+			return deps, nil
+		}
+		return []*dep.Resolution{}, nil
+	}
+	return nil, fmt.Errorf("%s: no such tool %q", t.path(), subcmd)
+}
+
+// Tool returns the toolchain's tool with the given subcommand name. Its Run
+// method runs it in memory (without starting a process). Its Command method
+// returns a command that runs the installed toolchain's program, which can
+// only be run if Install was called.
+func (t *Toolchain) Tool(subcmd string) toolchain.Tool {
+	return &tool{t, subcmd}
+}
+
+type tool struct {
+	tc     *Toolchain
+	subcmd string
+}
+
+func (t *tool) Command() (*exec.Cmd, error) {
+	program := t.tc.program
+	if program == "" {
+		program = t.tc.path()
+	}
+	return exec.Command(program, t.subcmd), nil
+}
+
+func (t *tool) Run(arg []string, input, resp interface{}) error {
+	var stdin []byte
+	if input != nil {
+		var err error
+		if stdin, err = json.Marshal(input); err != nil {
+			return err
+		}
+	}
+	out, err := t.tc.run(t.subcmd, stdin)
+	if err != nil {
+		return err
+	}
+	// Round-trip the output through JSON, as if it came from a process.
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, resp)
+}
+
+// configEnv is the environment variable that holds the path of the JSON
+// encoding of the Toolchain that an installed toolchain's program runs.
+const configEnv = "SRCLIB_TOOLCHAINTEST_CONFIG"
+
+// Install installs the toolchain in a new temporary directory, which it
+// adds to the beginning of the SRCLIBPATH (both srclib.Path and the
+// SRCLIBPATH environment variable, for subprocesses), so that the
+// toolchain is found (by toolchain.Lookup, toolchain.ChooseTool, etc.) and
+// run like any other installed program toolchain. The caller must call the
+// returned function to remove the toolchain and restore the SRCLIBPATH.
+//
+// The toolchain's program runs the current test binary, whose TestMain
+// function must call Main. Changes to t after Install is called don't
+// affect the installed toolchain.
+func (t *Toolchain) Install() (remove func(), err error) {
+	dir, err := ioutil.TempDir("", "srclib-toolchaintest")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	tcDir := filepath.Join(dir, filepath.FromSlash(t.path()))
+	if err := os.MkdirAll(filepath.Join(tcDir, ".bin"), 0755); err != nil {
+		return nil, err
+	}
+
+	cfg, err := json.MarshalIndent(t.config(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tcDir, toolchain.ConfigFilename), cfg, 0644); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	dataFile := filepath.Join(tcDir, "toolchain.json")
+	if err := ioutil.WriteFile(dataFile, data, 0644); err != nil {
+		return nil, err
+	}
+
+	testBinary, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	program := filepath.Join(tcDir, ".bin", filepath.Base(t.path()))
+	script := fmt.Sprintf("#!/bin/sh\n%s=%s exec %s \"$@\"\n", configEnv, shellQuote(dataFile), shellQuote(testBinary))
+	if err := ioutil.WriteFile(program, []byte(script), 0755); err != nil {
+		return nil, err
+	}
+	t.program = program
+
+	origPath, origEnv := srclib.Path, os.Getenv("SRCLIBPATH")
+	srclib.Path = dir
+	for _, p := range filepath.SplitList(origPath) {
+		// Omit nonexistent dirs (such as the default ~/.srclib on a test
+		// machine), which toolchain.List can't walk.
+		if _, err := os.Stat(p); err == nil {
+			srclib.Path += string(filepath.ListSeparator) + p
+		}
+	}
+	if err := os.Setenv("SRCLIBPATH", srclib.Path); err != nil {
+		return nil, err
+	}
+	return func() {
+		srclib.Path = origPath
+		os.Setenv("SRCLIBPATH", origEnv)
+		t.program = ""
+		os.RemoveAll(dir)
+	}, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Main runs the installed toolchain program (see Install) and exits if the
+// current process was started as one. Otherwise, it returns immediately.
+// Call it at the start of TestMain, before flags are parsed.
+func Main() {
+	dataFile := os.Getenv(configEnv)
+	if dataFile == "" {
+		return
+	}
+	if err := runProgram(dataFile, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func runProgram(dataFile string, args []string, stdin io.Reader, stdout io.Writer) error {
+	data, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		return err
+	}
+	var t Toolchain
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s TOOL [ARG...]", t.path())
+	}
+
+	input, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	out, err := t.run(args[0], input)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(stdout).Encode(out)
+}
@@ -0,0 +1,108 @@
+package toolchaintest
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/grapher"
+	"sourcegraph.com/sourcegraph/srclib/scan"
+	"sourcegraph.com/sourcegraph/srclib/toolchain"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func TestMain(m *testing.M) {
+	Main()
+	os.Exit(m.Run())
+}
+
+func newToolchain() *Toolchain {
+	return &Toolchain{
+		Units: []*unit.SourceUnit{{Name: "u1", Files: []string{"a.x"}}, {Name: "u2"}},
+		Graphs: map[string]*grapher.Output{
+			"u1": {
+				Defs: []*graph.Def{{DefKey: graph.DefKey{Path: "f"}, Name: "f", File: "a.x"}},
+				Refs: []*graph.Ref{{DefPath: "f", File: "a.x", Start: 1, End: 2}},
+			},
+		},
+		Failures: map[string]string{"graph u2": "boom"},
+	}
+}
+
+// checkTools checks the output of the tools of newToolchain, opened by
+// openTool.
+func checkTools(t *testing.T, openTool func(subcmd string) toolchain.Tool) {
+	units, err := scan.Scan(openTool("scan"), scan.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 2 || units[0].Name != "u1" || units[0].Type != DefaultUnitType || !reflect.DeepEqual(units[0].Files, []string{"a.x"}) {
+		t.Errorf("got units %+v, want u1 and u2 of type %q", units, DefaultUnitType)
+	}
+
+	var o grapher.Output
+	if err := openTool("graph").Run(nil, units[0], &o); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Defs) != 1 || o.Defs[0].Path != "f" || len(o.Refs) != 1 || o.Refs[0].DefPath != "f" {
+		t.Errorf("got graph output %+v, want def and ref of f", o)
+	}
+
+	if err := openTool("graph").Run(nil, units[1], &o); err == nil {
+		t.Error("got no error from failing grapher")
+	}
+
+	var deps []interface{}
+	if err := openTool("depresolve").Run(nil, units[0], &deps); err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 0 {
+		t.Errorf("got deps %v, want none", deps)
+	}
+}
+
+func TestToolchain_Tool(t *testing.T) {
+	tc := newToolchain()
+	checkTools(t, tc.Tool)
+
+	var o grapher.Output
+	err := tc.Tool("graph").Run(nil, &unit.SourceUnit{Name: "u2"}, &o)
+	if want := "srclib.test/fake graph: boom"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestToolchain_Install(t *testing.T) {
+	tc := newToolchain()
+	remove, err := tc.Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remove()
+
+	ref, err := toolchain.ChooseTool("graph", DefaultUnitType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Toolchain != DefaultPath || ref.Subcmd != "graph" {
+		t.Errorf("got tool %+v, want %s graph", ref, DefaultPath)
+	}
+
+	checkTools(t, func(subcmd string) toolchain.Tool {
+		tool, err := toolchain.OpenTool(DefaultPath, subcmd, toolchain.AsProgram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tool
+	})
+
+	remove()
+	if _, err := toolchain.Lookup(DefaultPath); err == nil {
+		t.Error("got no error looking up toolchain after removing it")
+	}
+	if strings.Contains(os.Getenv("SRCLIBPATH"), "srclib-toolchaintest") {
+		t.Errorf("SRCLIBPATH %q still contains the removed toolchain", os.Getenv("SRCLIBPATH"))
+	}
+}
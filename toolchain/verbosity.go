@@ -0,0 +1,139 @@
+package toolchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+)
+
+// VerbosityEnv is the environment variable that holds the verbosity level
+// (see Verbosity), so that src subprocesses (such as "src tool" in Makefile
+// recipes) and the tools they run use the same level as the src command
+// that started them. Tools may also read it to decide how much to log.
+const VerbosityEnv = "SRCLIB_VERBOSITY"
+
+// Verbosity levels.
+const (
+	// Quiet only shows errors. The stderr of tool runs is only shown if
+	// the tool fails.
+	Quiet = -1
+
+	// Normal shows progress, warnings, and the stderr of tool runs.
+	Normal = 0
+
+	// Verbose also shows details of what is being done, such as the tools
+	// being run and the commands run by each build step.
+	Verbose = 1
+
+	// VeryVerbose also timestamps log messages and shows the input of tool
+	// runs.
+	VeryVerbose = 2
+)
+
+// Verbosity returns the verbosity level (from Quiet to VeryVerbose) in the
+// environment.
+func Verbosity() int {
+	level, _ := strconv.Atoi(os.Getenv(VerbosityEnv))
+	return clampVerbosity(level)
+}
+
+// SetVerbosity sets the verbosity level in the environment. Levels outside
+// of the range from Quiet to VeryVerbose are clamped to it.
+func SetVerbosity(level int) error {
+	return os.Setenv(VerbosityEnv, strconv.Itoa(clampVerbosity(level)))
+}
+
+func clampVerbosity(level int) int {
+	switch {
+	case level < Quiet:
+		return Quiet
+	case level > VeryVerbose:
+		return VeryVerbose
+	}
+	return level
+}
+
+// RunTag returns the tag that identifies a tool run in its stderr output:
+// the tool's subcommand, followed by the name of the source unit in the
+// run's input (if it is a source unit), such as "graph example.com/foo".
+func RunTag(subcmd string, input []byte) string {
+	var u struct{ Name string }
+	if err := json.Unmarshal(input, &u); err != nil {
+		return subcmd
+	}
+	return UnitRunTag(subcmd, u.Name)
+}
+
+// UnitRunTag returns the RunTag of a run of subcmd whose input is the named
+// source unit (or isn't a source unit, if unitName is empty).
+func UnitRunTag(subcmd, unitName string) string {
+	if unitName != "" {
+		return subcmd + " " + unitName
+	}
+	return subcmd
+}
+
+// A StderrWriter writes the stderr of a tool run to an underlying writer,
+// prefixing each line with "[TAG] " (see RunTag) so that the output of
+// concurrent runs can be told apart. At Quiet verbosity, it buffers the
+// output instead, and Close writes it only if the run failed.
+type StderrWriter struct {
+	w      io.Writer
+	prefix []byte
+	quiet  bool
+	buf    bytes.Buffer // the incomplete last line, or all of the output if quiet
+}
+
+// NewStderrWriter returns a writer of the stderr of the tool run with the
+// given tag to w, at the verbosity level in the environment.
+func NewStderrWriter(w io.Writer, tag string) *StderrWriter {
+	return &StderrWriter{w: w, prefix: []byte("[" + tag + "] "), quiet: Verbosity() == Quiet}
+}
+
+func (w *StderrWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.quiet {
+		return len(p), nil
+	}
+	data := w.buf.Bytes()
+	i := bytes.LastIndexByte(data, '\n')
+	if i == -1 {
+		return len(p), nil
+	}
+	err := w.writeLines(data[:i+1])
+	w.buf.Next(i + 1)
+	return len(p), err
+}
+
+// Close writes the rest of the output: the incomplete last line (if any),
+// or, at Quiet verbosity, all of the output if failed is true.
+func (w *StderrWriter) Close(failed bool) error {
+	defer w.buf.Reset()
+	if w.quiet && !failed {
+		return nil
+	}
+	data := w.buf.Bytes()
+	if len(data) == 0 {
+		return nil
+	}
+	if data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	return w.writeLines(data)
+}
+
+// writeLines writes the lines in data (which ends in a newline) with
+// w.prefix.
+func (w *StderrWriter) writeLines(data []byte) error {
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) > 0 {
+			out.Write(w.prefix)
+			out.Write(line)
+		}
+	}
+	_, err := w.w.Write(out.Bytes())
+	return err
+}
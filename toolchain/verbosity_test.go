@@ -0,0 +1,66 @@
+package toolchain
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestRunTag(t *testing.T) {
+	tests := map[string]string{
+		`{"Name": "foo", "Type": "GoPackage"}`: "graph foo",
+		`{"Type": "GoPackage"}`:                "graph",
+		`[1]`:                                  "graph",
+		``:                                     "graph",
+	}
+	for input, want := range tests {
+		if got := RunTag("graph", []byte(input)); got != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestStderrWriter(t *testing.T) {
+	defer os.Setenv(VerbosityEnv, os.Getenv(VerbosityEnv))
+
+	tests := []struct {
+		verbosity int
+		failed    bool
+		want      string
+	}{
+		{verbosity: Normal, want: "[u] a\n[u] bc\n[u] d\n"},
+		{verbosity: Normal, failed: true, want: "[u] a\n[u] bc\n[u] d\n"},
+		{verbosity: Quiet, want: ""},
+		{verbosity: Quiet, failed: true, want: "[u] a\n[u] bc\n[u] d\n"},
+	}
+	for _, test := range tests {
+		if err := SetVerbosity(test.verbosity); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		w := NewStderrWriter(&buf, "u")
+		for _, s := range []string{"a\nb", "c\n", "d"} {
+			if _, err := w.Write([]byte(s)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.verbosity == Normal {
+			if want := "[u] a\n[u] bc\n"; buf.String() != want {
+				t.Errorf("before Close: got %q, want %q", buf.String(), want)
+			}
+		}
+		if err := w.Close(test.failed); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("verbosity %d, failed %v: got %q, want %q", test.verbosity, test.failed, buf.String(), test.want)
+		}
+	}
+
+	for level, want := range map[int]int{-5: Quiet, 1: Verbose, 7: VeryVerbose} {
+		SetVerbosity(level)
+		if got := Verbosity(); got != want {
+			t.Errorf("SetVerbosity(%d): got verbosity %d, want %d", level, got, want)
+		}
+	}
+}
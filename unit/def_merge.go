@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// A DefMergePolicy determines how defs with the same path in a source unit's
+// graph output are merged into a single def.
+type DefMergePolicy string
+
+const (
+	// PreferToolchain keeps only the def emitted by the most preferred
+	// toolchain (see DefMerge.Toolchains).
+	PreferToolchain DefMergePolicy = "prefer-toolchain"
+
+	// MergeFields starts with the def emitted by the most preferred
+	// toolchain and fills in its empty fields from the other defs.
+	MergeFields DefMergePolicy = "merge-fields"
+
+	// ErrorOnDuplicate causes graphing to fail if defs with the same path
+	// are emitted.
+	ErrorOnDuplicate DefMergePolicy = "error"
+)
+
+// DefMerge configures how defs with the same path (e.g., because both a
+// generic grapher and a specialized one emitted them) are merged.
+type DefMerge struct {
+	// Policy is the policy for defs whose path doesn't match any of the
+	// patterns in Paths. If empty, MergeFields is used.
+	Policy DefMergePolicy `json:",omitempty"`
+
+	// Paths maps def path patterns (in the syntax of path.Match) to the
+	// policy for defs whose path matches. If a path matches more than one
+	// pattern, the lexicographically first pattern is used.
+	Paths map[string]DefMergePolicy `json:",omitempty"`
+
+	// Toolchains lists toolchains in descending order of preference. Defs
+	// emitted by toolchains that aren't listed are least preferred, in the
+	// order in which the toolchains' outputs were given.
+	Toolchains []string `json:",omitempty"`
+}
+
+// PolicyFor returns the policy for defs with the given path.
+func (m *DefMerge) PolicyFor(defPath string) DefMergePolicy {
+	if m == nil {
+		return MergeFields
+	}
+	patterns := make([]string, 0, len(m.Paths))
+	for pat := range m.Paths {
+		patterns = append(patterns, pat)
+	}
+	sort.Strings(patterns)
+	for _, pat := range patterns {
+		if match, _ := path.Match(pat, defPath); match {
+			return m.Paths[pat]
+		}
+	}
+	if m.Policy == "" {
+		return MergeFields
+	}
+	return m.Policy
+}
+
+// Validate returns an error if m specifies an invalid policy or path
+// pattern.
+func (m *DefMerge) Validate() error {
+	check := func(p DefMergePolicy) error {
+		switch p {
+		case "", PreferToolchain, MergeFields, ErrorOnDuplicate:
+			return nil
+		}
+		return fmt.Errorf("invalid def merge policy %q (valid policies are %s, %s, and %s)", p, PreferToolchain, MergeFields, ErrorOnDuplicate)
+	}
+	if err := check(m.Policy); err != nil {
+		return err
+	}
+	for pat, p := range m.Paths {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("invalid def merge path pattern %q: %s", pat, err)
+		}
+		if err := check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package unit
+
+import "fmt"
+
+// A FilterAction is what a Filter does to the defs and refs that it matches.
+type FilterAction string
+
+const (
+	// DropFiltered removes the matching defs (along with their docs and the
+	// refs, aliases, and type relations that involve them) and refs. It is
+	// the default.
+	DropFiltered FilterAction = "drop"
+
+	// RedactFiltered keeps the matching defs, but removes their docs,
+	// snippets, and Data. It has no effect on refs.
+	RedactFiltered FilterAction = "redact"
+)
+
+// A Filter drops or redacts defs and refs in a source unit's graph output
+// after it is graphed and normalized, so that they don't end up in shared
+// indexes. A def matches if it satisfies all of the conditions that are
+// set. A ref matches if the filter only has conditions on paths, units, and
+// files, and the ref's def (for Paths and Units) and the ref itself (for
+// Files) satisfy them.
+//
+// Patterns are globs, in which "*" and "?" don't match "/", and "**"
+// matches anything (including "/").
+type Filter struct {
+	// Action is what the filter does to the defs and refs that it matches.
+	// If empty, DropFiltered is used.
+	Action FilterAction `json:",omitempty"`
+
+	// Visibility matches defs that are "exported" or "unexported".
+	Visibility string `json:",omitempty"`
+
+	// Kinds matches defs of any of these kinds.
+	Kinds []string `json:",omitempty"`
+
+	// Labels matches defs that have all of these labels, which are "test",
+	// "local", "anonymous", and "callable" (the def's flags of the same
+	// names).
+	Labels []string `json:",omitempty"`
+
+	// Paths matches defs whose path matches any of these patterns.
+	Paths []string `json:",omitempty"`
+
+	// Units matches defs in any of the source units whose names match these
+	// patterns.
+	Units []string `json:",omitempty"`
+
+	// Files matches defs and refs in any of the files that match these
+	// patterns.
+	Files []string `json:",omitempty"`
+}
+
+// FilterLabels are the labels that a Filter may match.
+var FilterLabels = []string{"test", "local", "anonymous", "callable"}
+
+// Validate returns an error if f has an invalid action, visibility, or label,
+// or if it has no conditions (and would match everything).
+func (f *Filter) Validate() error {
+	switch f.Action {
+	case "", DropFiltered, RedactFiltered:
+	default:
+		return fmt.Errorf("invalid filter action %q (valid actions are %s and %s)", f.Action, DropFiltered, RedactFiltered)
+	}
+	switch f.Visibility {
+	case "", "exported", "unexported":
+	default:
+		return fmt.Errorf("invalid filter visibility %q (valid visibilities are exported and unexported)", f.Visibility)
+	}
+	for _, l := range f.Labels {
+		valid := false
+		for _, l2 := range FilterLabels {
+			if l == l2 {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid filter label %q (valid labels are %v)", l, FilterLabels)
+		}
+	}
+	if f.Visibility == "" && len(f.Kinds) == 0 && len(f.Labels) == 0 && len(f.Paths) == 0 && len(f.Units) == 0 && len(f.Files) == 0 {
+		return fmt.Errorf("filter has no conditions")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package unit
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Normalize configures the passes that normalize a source unit's graph output
+// after its graphers' outputs are merged (see grapher.NormalizePasses).
+type Normalize struct {
+	// Enable lists passes to run that don't run by default.
+	Enable []string `json:",omitempty"`
+
+	// Disable lists passes not to run that run by default.
+	Disable []string `json:",omitempty"`
+
+	// Kinds maps the def kinds that graphers emit to the kinds to use
+	// instead (for the "kinds" pass).
+	Kinds map[string]string `json:",omitempty"`
+
+	// Paths rewrites def paths (for the "paths" pass). Each def path (and
+	// the def path of each ref, doc, and alias) is rewritten by the first
+	// rewrite whose pattern matches it.
+	Paths []*PathRewrite `json:",omitempty"`
+}
+
+// A PathRewrite rewrites the def paths that match Pattern (a regexp) to
+// Replacement (which may refer to submatches of Pattern as $1, ${name},
+// etc.; see regexp.Regexp.Expand).
+type PathRewrite struct {
+	Pattern     string
+	Replacement string
+}
+
+// Validate returns an error if n lists a pass as both enabled and disabled,
+// or has an invalid path rewrite pattern.
+func (n *Normalize) Validate() error {
+	for _, e := range n.Enable {
+		for _, d := range n.Disable {
+			if e == d {
+				return fmt.Errorf("normalization pass %q is both enabled and disabled", e)
+			}
+		}
+	}
+	for _, r := range n.Paths {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid def path rewrite pattern %q: %s", r.Pattern, err)
+		}
+	}
+	return nil
+}
@@ -71,6 +71,31 @@ type SourceUnit struct {
 	// pass options from the Srcfile to tools.
 	Config map[string]interface{} `json:",omitempty"`
 
+	// DefMerge configures how defs with the same path in this source unit's
+	// graph output are merged. If nil, their fields are merged (see
+	// MergeFields).
+	DefMerge *DefMerge `json:",omitempty"`
+
+	// IncludeLocals is whether graphers should emit local defs (such as
+	// local variables and parameters) and refs to them. If nil, the
+	// toolchain's default is used. If false, src removes any local defs
+	// (and refs to them) that the grapher emits anyway.
+	IncludeLocals *bool `json:",omitempty"`
+
+	// Snippets, if set, causes src to embed the source text of each def in
+	// this source unit's graph output (in the def's Snippet field), so that
+	// consumers can show previews of defs without access to the source tree.
+	Snippets *Snippets `json:",omitempty"`
+
+	// Normalize configures the passes that normalize this source unit's
+	// graph output. If nil, the default passes run.
+	Normalize *Normalize `json:",omitempty"`
+
+	// Filters drop or redact defs and refs in this source unit's graph
+	// output (see Filter), in order, so that they don't end up in shared
+	// indexes.
+	Filters []*Filter `json:",omitempty"`
+
 	// Ops enumerates the operations that should be performed on this source
 	// unit. Each key is the name of an operation, and the value is the tool to
 	// use to perform that operation. If the value is nil, the tool is chosen
@@ -83,6 +108,21 @@ type SourceUnit struct {
 
 //END SourceUnit OMIT
 
+// Snippets configures the embedding of defs' source text in graph output.
+type Snippets struct {
+	// MaxLines is the maximum number of lines of each def's source text to
+	// embed. If 0, the whole source text is embedded.
+	MaxLines int `json:",omitempty"`
+}
+
+// Validate returns an error if s is invalid.
+func (s *Snippets) Validate() error {
+	if s.MaxLines < 0 {
+		return fmt.Errorf("invalid snippets MaxLines %d (must be 0 or positive)", s.MaxLines)
+	}
+	return nil
+}
+
 // OpsSorted returns the keys of the Ops map in sorted order.
 func (u *SourceUnit) OpsSorted() []string {
 	ops := make([]string, len(u.Ops))
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiffTrees compares the files in the expected and actual directories and
+// returns a unified diff of their differences, or nil if they contain the
+// same files with the same contents. A directory that doesn't exist is
+// treated as empty.
+//
+// Files whose names end in ".json" are compared as JSON, ignoring
+// differences in whitespace, and are indented before being diffed, so that
+// changes to build data files (which are usually written on a single line)
+// are shown line by line.
+func DiffTrees(expectedDir, actualDir string) ([]byte, error) {
+	expectedFiles, err := listFiles(expectedDir)
+	if err != nil {
+		return nil, err
+	}
+	actualFiles, err := listFiles(actualDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(expectedFiles)+len(actualFiles))
+	for name := range expectedFiles {
+		names = append(names, name)
+	}
+	for name := range actualFiles {
+		if !expectedFiles[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diff bytes.Buffer
+	for _, name := range names {
+		if !actualFiles[name] {
+			fmt.Fprintf(&diff, "Only in expected: %s\n", name)
+			continue
+		}
+		if !expectedFiles[name] {
+			fmt.Fprintf(&diff, "Only in actual: %s\n", name)
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(filepath.Join(expectedDir, name))
+		if err != nil {
+			return nil, err
+		}
+		actual, err := ioutil.ReadFile(filepath.Join(actualDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(expected, actual) {
+			continue
+		}
+		if strings.HasSuffix(name, ".json") {
+			expected, actual = indentJSON(expected), indentJSON(actual)
+			if bytes.Equal(expected, actual) {
+				continue
+			}
+		}
+		fileDiff, err := diffFiles(name, expected, actual)
+		if err != nil {
+			return nil, err
+		}
+		diff.Write(fileDiff)
+	}
+	if diff.Len() == 0 {
+		return nil, nil
+	}
+	return diff.Bytes(), nil
+}
+
+// listFiles returns the slash-separated paths (relative to dir) of the
+// files in dir and its subdirectories.
+func listFiles(dir string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	return files, err
+}
+
+// indentJSON returns data indented, or data unchanged if it isn't a single
+// JSON value.
+func indentJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return data
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// diffFiles returns the output of "diff -u" on the expected and actual
+// contents of the named file.
+func diffFiles(name string, expected, actual []byte) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir("", "src-diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	expectedFile, actualFile := filepath.Join(tmpDir, "expected"), filepath.Join(tmpDir, "actual")
+	if err := ioutil.WriteFile(expectedFile, expected, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(actualFile, actual, 0600); err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command("diff", "-u", "--label", "expected/"+name, "--label", "actual/"+name, expectedFile, actualFile).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(out) > 0 && !exitErr.Success() {
+		err = nil // diff exits with status 1 when the files differ
+	}
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %s", name, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ReadJSON reads JSON data from r and decodes it into v, like
+// json.NewDecoder(r).Decode(v), but for data that may be corrupted or
+// malicious. Instead of exhausting memory, it returns an error if the data
+// is larger than maxSize bytes or if it nests arrays and objects more than
+// maxDepth levels deep. It also returns an error if there is non-whitespace
+// data after the JSON value. The data is held in memory while it's decoded,
+// so maxSize should be a small fraction of the memory available.
+func ReadJSON(r io.Reader, v interface{}, maxSize int64, maxDepth int) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > maxSize {
+		return fmt.Errorf("JSON data is larger than the maximum size (%d bytes)", maxSize)
+	}
+	if err := CheckJSONDepth(data, maxDepth); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// CheckJSONDepth returns an error if data nests arrays and objects more
+// than maxDepth levels deep. It doesn't otherwise check that data is valid
+// JSON.
+func CheckJSONDepth(data []byte, maxDepth int) error {
+	var depth int
+	var inString, escaped bool
+	for i, c := range data {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("JSON data is nested too deeply (more than %d levels) at offset %d", maxDepth, i)
+			}
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return nil
+}
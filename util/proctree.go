@@ -0,0 +1,38 @@
+package util
+
+import "errors"
+
+// ProcessStat describes a running process.
+type ProcessStat struct {
+	PID  int
+	PPID int
+
+	// RSS is the current resident set size of the process, in bytes.
+	RSS int64
+}
+
+// ErrProcessTreeUnsupported is returned by ProcessTree on platforms where
+// it's not implemented.
+var ErrProcessTreeUnsupported = errors.New("listing processes is not supported on this platform")
+
+// ProcessTree returns the process with the given pid and all of its
+// descendants.
+func ProcessTree(pid int) ([]ProcessStat, error) {
+	all, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]ProcessStat)
+	var tree []ProcessStat
+	for _, p := range all {
+		children[p.PPID] = append(children[p.PPID], p)
+		if p.PID == pid {
+			tree = append(tree, p)
+		}
+	}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i].PID]...)
+	}
+	return tree, nil
+}
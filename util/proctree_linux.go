@@ -0,0 +1,45 @@
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func processes() ([]ProcessStat, error) {
+	statFiles, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+	pageSize := int64(os.Getpagesize())
+
+	var ps []ProcessStat
+	for _, file := range statFiles {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			// The process probably exited.
+			continue
+		}
+
+		// The format is "pid (comm) state ppid ...", where comm may contain
+		// spaces and parens, so start parsing after the last ')'. See proc(5).
+		i := bytes.LastIndexByte(data, ')')
+		if i == -1 {
+			continue
+		}
+		fields := bytes.Fields(data[i+1:])
+		if len(fields) < 22 {
+			continue
+		}
+		pid, err := strconv.Atoi(string(bytes.TrimSpace(data[:bytes.IndexByte(data, ' ')])))
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(string(fields[1]))
+		rss, _ := strconv.ParseInt(string(fields[21]), 10, 64)
+		ps = append(ps, ProcessStat{PID: pid, PPID: ppid, RSS: rss * pageSize})
+	}
+	return ps, nil
+}
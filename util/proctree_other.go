@@ -0,0 +1,6 @@
+//go:build !linux
+// +build !linux
+
+package util
+
+func processes() ([]ProcessStat, error) { return nil, ErrProcessTreeUnsupported }
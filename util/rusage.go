@@ -0,0 +1,34 @@
+package util
+
+import (
+	"os"
+	"time"
+)
+
+// ResourceUsage describes the resources consumed by an exited process.
+type ResourceUsage struct {
+	// UserTime and SystemTime are the CPU time spent by the process in user
+	// and kernel mode, respectively.
+	UserTime   time.Duration
+	SystemTime time.Duration
+
+	// MaxRSS is the peak resident set size of the process, in bytes. It is 0
+	// if the platform doesn't report it.
+	MaxRSS int64
+}
+
+// CPUTime returns the total (user plus system) CPU time.
+func (u ResourceUsage) CPUTime() time.Duration { return u.UserTime + u.SystemTime }
+
+// ProcessResourceUsage returns the resources consumed by the exited process
+// described by ps.
+func ProcessResourceUsage(ps *os.ProcessState) ResourceUsage {
+	if ps == nil {
+		return ResourceUsage{}
+	}
+	return ResourceUsage{
+		UserTime:   ps.UserTime(),
+		SystemTime: ps.SystemTime(),
+		MaxRSS:     maxRSS(ps),
+	}
+}
@@ -0,0 +1,13 @@
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+func maxRSS(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss // OS X reports bytes
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package util
+
+import "os"
+
+func maxRSS(ps *os.ProcessState) int64 { return 0 }
@@ -4,16 +4,104 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/sourcegraph/go-blame/blame"
 )
 
+// BlameFilename is the name of the file (in the build data directory for a
+// commit) that the blame of all of the source units' files is written to.
+// Each file is blamed once, even if it is in multiple source units.
+const BlameFilename = "build.blame.json"
+
+// BlameOutput is the blame of a set of files. HunkMap maps each file's path
+// (relative to the repository root) to its hunks, and CommitMap maps the
+// IDs of the hunks' commits to the commits.
 type BlameOutput struct {
 	CommitMap map[string]blame.Commit
 	HunkMap   map[string][]blame.Hunk
 }
 
+// A BlamedHunk is a range of lines in a file and the commit that last
+// changed them.
+type BlamedHunk struct {
+	// StartLine is the first line of the hunk (1-based), and EndLine is the
+	// line after its last line.
+	StartLine, EndLine int
+
+	// StartByte and EndByte are the byte offsets of the start and end of
+	// the hunk.
+	StartByte, EndByte int
+
+	CommitID    string
+	Author      string
+	AuthorEmail string
+	AuthorDate  time.Time
+}
+
+// File returns the hunks of the file, sorted by position, or nil if the
+// file wasn't blamed.
+func (o *BlameOutput) File(file string) []*BlamedHunk {
+	hunks := o.HunkMap[filepath.Clean(file)]
+	if hunks == nil {
+		return nil
+	}
+	blamed := make([]*BlamedHunk, len(hunks))
+	for i, h := range hunks {
+		c := o.CommitMap[h.CommitID]
+		blamed[i] = &BlamedHunk{
+			StartLine:   h.LineStart,
+			EndLine:     h.LineEnd,
+			StartByte:   h.CharStart,
+			EndByte:     h.CharEnd,
+			CommitID:    h.CommitID,
+			Author:      c.Author.Name,
+			AuthorEmail: c.Author.Email,
+			AuthorDate:  c.AuthorDate,
+		}
+	}
+	sort.Sort(hunksByPosition(blamed))
+	return blamed
+}
+
+// Line returns the hunk of the file that contains the line (1-based), or nil
+// if there is none.
+func (o *BlameOutput) Line(file string, line int) *BlamedHunk {
+	for _, h := range o.File(file) {
+		if line >= h.StartLine && line < h.EndLine {
+			return h
+		}
+	}
+	return nil
+}
+
+// Filter returns the blame of only the files (and the commits that their
+// hunks refer to).
+func (o *BlameOutput) Filter(files []string) *BlameOutput {
+	out := &BlameOutput{CommitMap: map[string]blame.Commit{}, HunkMap: map[string][]blame.Hunk{}}
+	for _, file := range files {
+		file = filepath.Clean(file)
+		hunks, present := o.HunkMap[file]
+		if !present {
+			continue
+		}
+		out.HunkMap[file] = hunks
+		for _, h := range hunks {
+			if c, present := o.CommitMap[h.CommitID]; present {
+				out.CommitMap[h.CommitID] = c
+			}
+		}
+	}
+	return out
+}
+
+type hunksByPosition []*BlamedHunk
+
+func (v hunksByPosition) Len() int           { return len(v) }
+func (v hunksByPosition) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v hunksByPosition) Less(i, j int) bool { return v[i].StartLine < v[j].StartLine }
+
 var blameIgnores = []string{
 	"node_modules", "bower_components",
 	"doc", "docs", "build", "vendor",
@@ -3,6 +3,7 @@ package vcsutil
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/sourcegraph/makex"
 
@@ -18,31 +19,85 @@ func init() {
 }
 
 func makeBlameRules(c *config.Tree, dataDir string, existing []makex.Rule, opt plan.Options) ([]makex.Rule, error) {
-	// blame each source unit individually
-	var rules []makex.Rule
+	if len(c.SourceUnits) == 0 {
+		return nil, nil
+	}
+
+	// Blame all of the source units' files once, and then derive each
+	// source unit's blame from that.
+	repoRule := &BlameRule{dataDir, c.SourceUnits}
+	rules := []makex.Rule{repoRule}
 	for _, u := range c.SourceUnits {
-		rules = append(rules, &BlameSourceUnitRule{dataDir, u})
+		rules = append(rules, &BlameSourceUnitRule{dataDir, u, repoRule.Target()})
 	}
 	return rules, nil
 }
 
-type BlameSourceUnitRule struct {
+// BlameRule blames the files of all of a repository's source units, writing
+// the blame to BlameFilename.
+type BlameRule struct {
 	dataDir string
-	Unit    *unit.SourceUnit
+	Units   []*unit.SourceUnit
+}
+
+func (r *BlameRule) Target() string { return filepath.Join(r.dataDir, BlameFilename) }
+
+func (r *BlameRule) unitDataFiles() []string {
+	files := make([]string, len(r.Units))
+	for i, u := range r.Units {
+		files[i] = filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, u))
+	}
+	return files
+}
+
+func (r *BlameRule) Prereqs() []string {
+	ps := r.unitDataFiles()
+	seen := make(map[string]bool)
+	for _, u := range r.Units {
+		for _, f := range u.Files {
+			if !seen[f] {
+				seen[f] = true
+				ps = append(ps, f)
+			}
+		}
+	}
+	return ps
 }
 
+func (r *BlameRule) Recipes() []string {
+	args := r.unitDataFiles()
+	for i, f := range args {
+		args[i] = makex.Quote(f)
+	}
+	return []string{
+		fmt.Sprintf("src internal blame %s 1> $@", strings.Join(args, " ")),
+	}
+}
+
+// BlameSourceUnitRule extracts the blame of a source unit's files from the
+// blame of all of the repository's files (see BlameRule).
+type BlameSourceUnitRule struct {
+	dataDir   string
+	Unit      *unit.SourceUnit
+	RepoBlame string
+}
+
+func (r *BlameSourceUnitRule) SourceUnit() *unit.SourceUnit { return r.Unit }
+
 func (r *BlameSourceUnitRule) Target() string {
 	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(&BlameOutput{}, r.Unit))
 }
 
+func (r *BlameSourceUnitRule) unitDataFile() string {
+	return filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))
+}
+
 func (r *BlameSourceUnitRule) Prereqs() []string {
-	ps := []string{filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit))}
-	ps = append(ps, r.Unit.Files...)
-	return ps
+	return []string{r.unitDataFile(), r.RepoBlame}
 }
 
 func (r *BlameSourceUnitRule) Recipes() []string {
 	return []string{
-		fmt.Sprintf("src internal unit-blame --unit-data %s 1> $@", makex.Quote(filepath.Join(r.dataDir, plan.SourceUnitDataFilename(unit.SourceUnit{}, r.Unit)))),
+		fmt.Sprintf("src internal unit-blame --unit-data %s --blame-data %s 1> $@", makex.Quote(r.unitDataFile()), makex.Quote(r.RepoBlame)),
 	}
 }
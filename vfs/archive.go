@@ -0,0 +1,138 @@
+package vfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Archive returns an in-memory FileSystem for the tree in the archive file,
+// which is a tar file (optionally compressed with gzip or bzip2) or a zip
+// file. The format is determined by the file's contents, not its name.
+//
+// If all of the archive's files are in a single top-level directory (as in
+// the archives that code hosts serve, such as "repo-COMMIT/..."), that
+// directory is the root of the tree. Directories, symbolic links, and file
+// permissions are preserved; other kinds of files (such as devices) are
+// omitted.
+func Archive(file string) (FileSystem, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+	var files map[string]*memFile
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		files, err = readZip(file)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(br); err == nil {
+			files, err = readTar(zr)
+		}
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		files, err = readTar(bzip2.NewReader(br))
+	default:
+		files, err = readTar(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading archive %s: %s", file, err)
+	}
+	return &memFS{desc: file, files: stripTopDir(files)}, nil
+}
+
+func readTar(r io.Reader) (map[string]*memFile, error) {
+	files := map[string]*memFile{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		name := clean(hdr.Name)
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = &memFile{data: data, mode: os.FileMode(hdr.Mode).Perm()}
+		case tar.TypeSymlink:
+			files[name] = &memFile{data: []byte(hdr.Linkname), mode: os.ModeSymlink | 0777}
+		case tar.TypeLink:
+			if target, present := files[clean(hdr.Linkname)]; present {
+				files[name] = target
+			}
+		}
+	}
+}
+
+func readZip(file string) (map[string]*memFile, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	files := make(map[string]*memFile, len(zr.File))
+	for _, zf := range zr.File {
+		name := clean(zf.Name)
+		mode := zf.Mode()
+		if name == "" || !(mode.IsRegular() || mode&os.ModeSymlink != 0) {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if mode.IsRegular() {
+			mode = mode.Perm()
+		} else {
+			mode = os.ModeSymlink | 0777
+		}
+		files[name] = &memFile{data: data, mode: mode}
+	}
+	return files, nil
+}
+
+// stripTopDir removes the top-level directory from the paths of files, if
+// all of them are in the same top-level directory.
+func stripTopDir(files map[string]*memFile) map[string]*memFile {
+	var top string
+	for name := range files {
+		i := strings.Index(name, "/")
+		if i == -1 || (top != "" && name[:i] != top) {
+			return files
+		}
+		top = name[:i]
+	}
+	if top == "" {
+		return files
+	}
+	stripped := make(map[string]*memFile, len(files))
+	for name, f := range files {
+		stripped[strings.TrimPrefix(name, top+"/")] = f
+	}
+	return stripped
+}
@@ -0,0 +1,143 @@
+package vfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// writeArchive writes treeFiles (in the directory prefix) to file, in the
+// given format ("tar", "tar.gz", or "zip").
+func writeArchive(t *testing.T, file, format, prefix string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var names []string
+	for name := range treeFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mode := func(name string) os.FileMode {
+		switch name {
+		case "z/script":
+			return 0755
+		case "z/link.txt":
+			return os.ModeSymlink | 0777
+		}
+		return 0644
+	}
+
+	if format == "zip" {
+		zw := zip.NewWriter(f)
+		for _, name := range names {
+			hdr := &zip.FileHeader{Name: prefix + name, Method: zip.Deflate}
+			hdr.SetMode(mode(name))
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, treeFiles[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var w io.Writer = f
+	if format == "tar.gz" {
+		zw := gzip.NewWriter(f)
+		defer func() {
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	if prefix != "" {
+		if err := tw.WriteHeader(&tar.Header{Name: prefix, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range names {
+		hdr := &tar.Header{Name: prefix + name, Typeflag: tar.TypeReg, Mode: int64(mode(name).Perm()), Size: int64(len(treeFiles[name]))}
+		if name == "z/link.txt" {
+			hdr = &tar.Header{Name: prefix + name, Typeflag: tar.TypeSymlink, Linkname: treeFiles[name], Mode: 0777}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.WriteString(tw, treeFiles[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		format, prefix string
+	}{
+		{"tar", ""},
+		{"tar.gz", "repo-abc/"},
+		{"zip", ""},
+		{"zip", "repo-abc/"},
+	}
+	for i, test := range tests {
+		// Name the file misleadingly, since the format is determined by
+		// its contents.
+		file := filepath.Join(tmpDir, string('a'+rune(i))+".archive")
+		writeArchive(t, file, test.format, test.prefix)
+
+		fs, err := Archive(file)
+		if err != nil {
+			t.Errorf("%s %q: %s", test.format, test.prefix, err)
+			continue
+		}
+		checkTree(t, fs)
+		if fi, err := fs.Stat("z/script"); err != nil || fi.Mode() != 0755 {
+			t.Errorf("%s: Stat(z/script): got %+v (error %v), want mode 0755", fs, fi, err)
+		}
+		if data, err := fs.ReadFile("z/link.txt"); err != nil || string(data) != treeFiles["z/link.txt"] {
+			t.Errorf("%s: got symlink target %q (error %v)", fs, data, err)
+		}
+
+		exportDir := filepath.Join(tmpDir, "export"+string('a'+rune(i)))
+		if err := Export(fs, exportDir); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(filepath.Join(exportDir, "z/link.txt")); err != nil || target != treeFiles["z/link.txt"] {
+			t.Errorf("%s: exported symlink to %q (error %v)", fs, target, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "bad"), []byte("not an archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Archive(filepath.Join(tmpDir, "bad")); err == nil {
+		t.Error("got no error for a file that isn't an archive")
+	}
+}
@@ -0,0 +1,151 @@
+package vfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Git returns a FileSystem for the tree of a commit in the git repository in
+// dir, which may be a bare repository or any directory in a working tree.
+// Files are read from the repository's object database, so the commit
+// needn't be checked out. The rev (such as a branch, tag, or commit ID) is
+// resolved to a commit ID when Git is called.
+//
+// Git submodules are omitted from the tree.
+func Git(dir, rev string) (FileSystem, error) {
+	fs := &gitFS{dir: dir}
+	out, err := fs.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("git repository %s has no commit %q", dir, rev)
+	}
+	fs.commitID = strings.TrimSpace(string(out))
+	return fs, nil
+}
+
+// GitCommitID returns the ID of the commit whose tree fs (which was
+// returned by Git) is, or "" if fs isn't a git tree.
+func GitCommitID(fs FileSystem) string {
+	if fs, ok := fs.(*gitFS); ok {
+		return fs.commitID
+	}
+	return ""
+}
+
+type gitFS struct {
+	dir      string
+	commitID string
+}
+
+func (fs *gitFS) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = fs.dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %s (%s)", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+func (fs *gitFS) ReadFile(name string) ([]byte, error) {
+	name = clean(name)
+	data, err := fs.git("cat-file", "blob", fs.commitID+":"+name)
+	if err != nil {
+		// Report a nonexistent file (or a directory) accurately.
+		fi, statErr := fs.Stat(name)
+		if statErr != nil {
+			return nil, statErr
+		}
+		if fi.IsDir() {
+			return nil, &os.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (fs *gitFS) Stat(name string) (os.FileInfo, error) {
+	name = clean(name)
+	if name == "" {
+		return &fileInfo{name: ".", mode: os.ModeDir | 0755}, nil
+	}
+	fis, err := fs.lsTree(fs.commitID, "--", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(fis) != 1 {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return fis[0], nil
+}
+
+func (fs *gitFS) ReadDir(name string) ([]os.FileInfo, error) {
+	fi, err := fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	fis, err := fs.lsTree(fs.commitID + ":" + clean(name))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byName(fis))
+	return fis, nil
+}
+
+// lsTree runs `git ls-tree` with args and returns the entries that it
+// lists (except for submodules).
+func (fs *gitFS) lsTree(args ...string) ([]os.FileInfo, error) {
+	out, err := fs.git(append([]string{"ls-tree", "-z", "--long"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var fis []os.FileInfo
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry == "" {
+			continue
+		}
+		// Each entry is "MODE TYPE OBJECT SIZE\tPATH".
+		tab := strings.Index(entry, "\t")
+		if tab == -1 {
+			return nil, fmt.Errorf("bad git ls-tree entry %q", entry)
+		}
+		fields := strings.Fields(entry[:tab])
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("bad git ls-tree entry %q", entry)
+		}
+		fi := &fileInfo{name: path.Base(entry[tab+1:])}
+		switch fields[1] {
+		case "tree":
+			fi.mode = os.ModeDir | 0755
+		case "blob":
+			fi.size, err = strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad git ls-tree entry %q", entry)
+			}
+			switch fields[0] {
+			case "120000":
+				fi.mode = os.ModeSymlink | 0777
+			case "100755":
+				fi.mode = 0755
+			default:
+				fi.mode = 0644
+			}
+		default:
+			continue
+		}
+		fis = append(fis, fi)
+	}
+	return fis, nil
+}
+
+func (fs *gitFS) String() string { return fs.dir + "@" + fs.commitID }
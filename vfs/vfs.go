@@ -0,0 +1,243 @@
+// Package vfs provides read-only views of source trees that srclib analyzes:
+// a directory on disk, a commit in a git repository's object database (which
+// may be a bare repository), an archive (such as a .tar.gz file), or an
+// in-memory tree. Code that reads the source files of a tree (such as
+// reading a Srcfile, converting character offsets to byte offsets, and
+// embedding snippets) uses a FileSystem, so that it doesn't require a
+// checkout of the tree.
+//
+// Scanners and graphers are external programs that run in a directory, so to
+// run them on a tree that isn't checked out, the tree is first exported to a
+// temporary directory with Export.
+package vfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A FileSystem is a read-only source tree. File names are slash-separated
+// paths relative to the root of the tree; the root itself is ".".
+//
+// Errors for files that don't exist satisfy os.IsNotExist.
+type FileSystem interface {
+	// ReadFile returns the contents of the named file.
+	ReadFile(name string) ([]byte, error)
+
+	// Stat returns information about the named file or directory.
+	Stat(name string) (os.FileInfo, error)
+
+	// ReadDir returns the entries of the named directory, sorted by name.
+	ReadDir(name string) ([]os.FileInfo, error)
+
+	// String describes the tree (for messages).
+	String() string
+}
+
+// clean cleans the name of a file in a FileSystem.
+func clean(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// OS returns a FileSystem for the directory tree rooted at dir on disk.
+func OS(dir string) FileSystem { return osFS(dir) }
+
+type osFS string
+
+func (fs osFS) path(name string) string {
+	return filepath.Join(string(fs), filepath.FromSlash(clean(name)))
+}
+
+func (fs osFS) ReadFile(name string) ([]byte, error) { return ioutil.ReadFile(fs.path(name)) }
+
+func (fs osFS) Stat(name string) (os.FileInfo, error) { return os.Stat(fs.path(name)) }
+
+func (fs osFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(fs.path(name)) }
+
+func (fs osFS) String() string { return string(fs) }
+
+// Map returns a FileSystem for the in-memory tree whose files are the keys
+// of m (slash-separated paths) and whose contents are m's values.
+// Directories are implied by the files' paths.
+func Map(m map[string]string) FileSystem {
+	fs := &memFS{desc: "in-memory tree", files: make(map[string]*memFile, len(m))}
+	for name, data := range m {
+		fs.files[clean(name)] = &memFile{data: []byte(data), mode: 0644}
+	}
+	return fs
+}
+
+// memFS is an in-memory tree. Its directories are implied by the paths of
+// its files.
+type memFS struct {
+	desc  string
+	files map[string]*memFile // keyed on cleaned path
+}
+
+type memFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+func (fs *memFS) ReadFile(name string) ([]byte, error) {
+	f, present := fs.files[clean(name)]
+	if !present {
+		if fs.isDir(clean(name)) {
+			return nil, &os.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+		}
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return f.data, nil
+}
+
+func (fs *memFS) isDir(name string) bool {
+	if name == "" {
+		return true
+	}
+	for file := range fs.files {
+		if strings.HasPrefix(file, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *memFS) Stat(name string) (os.FileInfo, error) {
+	name = clean(name)
+	if f, present := fs.files[name]; present {
+		return &fileInfo{name: path.Base(name), size: int64(len(f.data)), mode: f.mode}, nil
+	}
+	if fs.isDir(name) {
+		return &fileInfo{name: path.Base("/" + name), mode: os.ModeDir | 0755}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (fs *memFS) ReadDir(name string) ([]os.FileInfo, error) {
+	name = clean(name)
+	if !fs.isDir(name) {
+		if _, present := fs.files[name]; present {
+			return nil, &os.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+		}
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	prefix := name + "/"
+	if name == "" {
+		prefix = ""
+	}
+	seen := map[string]bool{}
+	var fis []os.FileInfo
+	for file := range fs.files {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		entry := strings.SplitN(strings.TrimPrefix(file, prefix), "/", 2)[0]
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		fi, err := fs.Stat(prefix + entry)
+		if err != nil {
+			return nil, err
+		}
+		fis = append(fis, fi)
+	}
+	sort.Sort(byName(fis))
+	return fis, nil
+}
+
+func (fs *memFS) String() string { return fs.desc }
+
+// fileInfo describes a file in a FileSystem that isn't on disk.
+type fileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+type byName []os.FileInfo
+
+func (v byName) Len() int           { return len(v) }
+func (v byName) Less(i, j int) bool { return v[i].Name() < v[j].Name() }
+func (v byName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// Walk calls walkFn for each file and directory in the tree rooted at root
+// (including root), in lexical order. As with filepath.Walk, if walkFn
+// returns filepath.SkipDir for a directory, its contents are skipped.
+func Walk(fs FileSystem, root string, walkFn func(name string, fi os.FileInfo) error) error {
+	root = clean(root)
+	fi, err := fs.Stat(root)
+	if err != nil {
+		return err
+	}
+	if root == "" {
+		root = "."
+	}
+	return walk(fs, root, fi, walkFn)
+}
+
+func walk(fs FileSystem, name string, fi os.FileInfo, walkFn func(string, os.FileInfo) error) error {
+	if err := walkFn(name, fi); err != nil {
+		if fi.IsDir() && err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+	fis, err := fs.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if err := walk(fs, path.Join(name, fi.Name()), fi, walkFn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes the files of the tree to dir on disk (creating it if
+// needed), so that external programs (such as scanners and graphers) can
+// run on it. Symbolic links in the tree are recreated; the contents of a
+// symbolic link in a FileSystem are its target.
+func Export(fs FileSystem, dir string) error {
+	return Walk(fs, ".", func(name string, fi os.FileInfo) error {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(file, 0755)
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := fs.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(string(target), file)
+		case fi.Mode().IsRegular():
+			data, err := fs.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			perm := fi.Mode().Perm()
+			if perm == 0 {
+				perm = 0644
+			}
+			return ioutil.WriteFile(file, data, perm)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,165 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var treeFiles = map[string]string{
+	"Srcfile":    `{}`,
+	"a/b.go":     "package a",
+	"a/c/d.go":   "package c",
+	"a/c/e.txt":  "e",
+	"z/script":   "#!/bin/sh",
+	"z/link.txt": "../a/c/e.txt",
+}
+
+// checkTree checks that fs is the tree of treeFiles (except for the
+// contents of symbolic links, which aren't checked).
+func checkTree(t *testing.T, fs FileSystem) {
+	var names []string
+	err := Walk(fs, ".", func(name string, fi os.FileInfo) error {
+		names = append(names, name)
+		if fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		data, err := fs.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if string(data) != treeFiles[name] {
+			t.Errorf("%s: %s: got contents %q, want %q", fs, name, data, treeFiles[name])
+		}
+		if fi.Size() != int64(len(data)) {
+			t.Errorf("%s: %s: got size %d, want %d", fs, name, fi.Size(), len(data))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s: %s", fs, err)
+	}
+	want := []string{".", "Srcfile", "a", "a/b.go", "a/c", "a/c/d.go", "a/c/e.txt", "z", "z/link.txt", "z/script"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("%s: walked %v, want %v", fs, names, want)
+	}
+
+	if fi, err := fs.Stat("./a/c/"); err != nil || !fi.IsDir() || fi.Name() != "c" {
+		t.Errorf("%s: Stat(a/c): got %+v (error %v), want directory c", fs, fi, err)
+	}
+	for _, name := range []string{"x", "a/x"} {
+		if _, err := fs.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s: Stat(%q): got error %v, want not exist", fs, name, err)
+		}
+		if _, err := fs.ReadFile(name); !os.IsNotExist(err) {
+			t.Errorf("%s: ReadFile(%q): got error %v, want not exist", fs, name, err)
+		}
+	}
+	if _, err := fs.ReadFile("a"); err == nil {
+		t.Errorf("%s: ReadFile(a): got no error for directory", fs)
+	}
+	if _, err := fs.ReadDir("a/b.go"); err == nil {
+		t.Errorf("%s: ReadDir(a/b.go): got no error for file", fs)
+	}
+}
+
+func TestMap(t *testing.T) {
+	checkTree(t, Map(treeFiles))
+}
+
+func TestExport(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := Export(Map(treeFiles), tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, OS(tmpDir))
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.Mkdir(repoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	run(repoDir, "init", "--quiet")
+	for name, data := range treeFiles {
+		file := filepath.Join(repoDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if name == "z/link.txt" {
+			err = os.Symlink(data, file)
+		} else {
+			err = ioutil.WriteFile(file, []byte(data), 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(repoDir, "z/script"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	run(repoDir, "add", ".")
+	run(repoDir, "commit", "--quiet", "-m", "1")
+	commitID := run(repoDir, "rev-parse", "HEAD")
+
+	// Change the working tree, which shouldn't affect the commit's tree.
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "Srcfile"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the commit from a bare clone.
+	bareDir := filepath.Join(tmpDir, "bare")
+	run(tmpDir, "clone", "--quiet", "--bare", repoDir, bareDir)
+	for _, dir := range []string{repoDir, bareDir} {
+		fs, err := Git(dir, "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := GitCommitID(fs); got != commitID {
+			t.Errorf("%s: got commit %s, want %s", fs, got, commitID)
+		}
+		checkTree(t, fs)
+
+		if fi, err := fs.Stat("z/script"); err != nil || fi.Mode() != 0755 {
+			t.Errorf("%s: Stat(z/script): got %+v (error %v), want mode 0755", fs, fi, err)
+		}
+		if fi, err := fs.Stat("z/link.txt"); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: Stat(z/link.txt): got %+v (error %v), want symlink", fs, fi, err)
+		}
+	}
+
+	if _, err := Git(repoDir, "nonexistent"); err == nil {
+		t.Error("got no error for nonexistent rev")
+	}
+	if got := GitCommitID(Map(nil)); got != "" {
+		t.Errorf("got commit %q of a map tree", got)
+	}
+}
@@ -0,0 +1,117 @@
+package vuln
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/srclib"
+	"sourcegraph.com/sourcegraph/srclib/network"
+)
+
+// DefaultURL is the base URL of the OSV database's exports, which has an
+// archive of the advisories of each ecosystem at ECOSYSTEM/all.zip.
+const DefaultURL = "https://osv-vulnerabilities.storage.googleapis.com"
+
+// DefaultMaxAge is the MaxAge of the cache returned by DefaultCache.
+const DefaultMaxAge = 24 * time.Hour
+
+// A Cache is a local copy of the per-ecosystem exports of an OSV database.
+type Cache struct {
+	// Dir is the directory that holds the exports, at
+	// Dir/ECOSYSTEM/all.zip.
+	Dir string
+
+	// URL is the base URL of the database's exports (see DefaultURL).
+	URL string
+
+	// MaxAge is how long a downloaded export is used before it is
+	// downloaded again. If 0, exports are only downloaded once.
+	MaxAge time.Duration
+
+	// Offline is whether to only use the exports that are already
+	// downloaded, however old they are.
+	Offline bool
+
+	// Client is the HTTP client used to download exports. If nil,
+	// network.Client is used.
+	Client *http.Client
+}
+
+// DefaultCache returns a cache of the OSV database's exports in the "osv"
+// directory of the srclib cache directory (srclib.CacheDir).
+func DefaultCache() *Cache {
+	return &Cache{Dir: filepath.Join(srclib.CacheDir, "osv"), URL: DefaultURL, MaxAge: DefaultMaxAge}
+}
+
+// Load returns a DB of the advisories for the ecosystems, downloading the
+// exports that aren't cached or are older than MaxAge (unless Offline). If
+// an export can't be downloaded but an older copy is cached, the older
+// copy is used.
+func (c *Cache) Load(ecosystems []string) (*DB, error) {
+	db := NewDB()
+	for _, eco := range ecosystems {
+		file := filepath.Join(c.Dir, eco, "all.zip")
+		fi, err := os.Stat(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		cached := err == nil
+		if !cached && c.Offline {
+			return nil, fmt.Errorf("no advisories for %s are cached in %s (and downloading them is disabled)", eco, c.Dir)
+		}
+		if !c.Offline && (!cached || (c.MaxAge != 0 && time.Since(fi.ModTime()) > c.MaxAge)) {
+			if err := c.download(eco, file); err != nil {
+				if !cached {
+					return nil, err
+				}
+				log.Printf("Warning: using advisories for %s downloaded at %s: %s.", eco, fi.ModTime().Format(time.RFC3339), err)
+			}
+		}
+		if err := db.ReadZip(file); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// download downloads the export of the ecosystem's advisories to file.
+func (c *Cache) download(ecosystem, file string) error {
+	client := c.Client
+	if client == nil {
+		client = network.Client()
+	}
+	exportURL := strings.TrimSuffix(c.URL, "/") + "/" + url.PathEscape(ecosystem) + "/all.zip"
+	resp, err := client.Get(exportURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: HTTP %s", exportURL, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("downloading %s: %s", exportURL, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
@@ -0,0 +1,361 @@
+// Package vuln matches the resolved dependencies of source units against
+// security advisories in the OSV format (https://ossf.github.io/osv-schema/),
+// such as those published by the OSV database (https://osv.dev).
+package vuln
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/dep"
+)
+
+func init() {
+	buildstore.RegisterDataType("vulns", []*Finding{})
+}
+
+// Filename is the name of the file (in the build data directory for a
+// commit) that the findings of the most recent build are written to.
+const Filename = "build.vulns.json"
+
+// An Advisory is an OSV advisory. Only the fields that srclib uses are
+// decoded.
+type Advisory struct {
+	ID       string      `json:"id"`
+	Aliases  []string    `json:"aliases,omitempty"`
+	Summary  string      `json:"summary,omitempty"`
+	Affected []*Affected `json:"affected"`
+
+	DatabaseSpecific struct {
+		// Severity is the severity of the advisory (e.g., "HIGH"), in
+		// databases (such as GitHub's) that rate advisories.
+		Severity string `json:"severity,omitempty"`
+	} `json:"database_specific"`
+}
+
+// Affected describes the versions of a package that an advisory affects.
+type Affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []*Range `json:"ranges,omitempty"`
+	Versions []string `json:"versions,omitempty"`
+}
+
+// A Range is a range of affected versions, described by the versions at
+// which the vulnerability was introduced and fixed.
+type Range struct {
+	Type   string   `json:"type"`
+	Events []*Event `json:"events"`
+}
+
+// An Event is a version at which a vulnerability was introduced or fixed
+// (or the last version it affects). Exactly one field is set.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+func (e *Event) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	}
+	return e.LastAffected
+}
+
+// affects returns whether version is in the range, and the version that
+// fixed it (if any). Only SEMVER and ECOSYSTEM ranges are supported; the
+// versions of ECOSYSTEM ranges are compared as in CompareVersions, which
+// is only an approximation of some ecosystems' version ordering.
+func (r *Range) affects(version string) (affected bool, fixed string) {
+	if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+		return false, ""
+	}
+	events := make([]*Event, len(r.Events))
+	copy(events, r.Events)
+	sort.Stable(eventsByVersion(events))
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || CompareVersions(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if affected && CompareVersions(version, e.Fixed) < 0 {
+				return true, e.Fixed
+			}
+			affected = false
+		case e.LastAffected != "":
+			if affected && CompareVersions(version, e.LastAffected) <= 0 {
+				return true, ""
+			}
+			affected = false
+		}
+	}
+	return affected, ""
+}
+
+type eventsByVersion []*Event
+
+func (v eventsByVersion) Len() int      { return len(v) }
+func (v eventsByVersion) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v eventsByVersion) Less(i, j int) bool {
+	if v[i].Introduced == "0" {
+		return v[j].Introduced != "0"
+	}
+	if v[j].Introduced == "0" {
+		return false
+	}
+	return CompareVersions(v[i].version(), v[j].version()) < 0
+}
+
+// Ecosystems maps source unit types to the OSV ecosystems of their
+// packages. Dependencies on source units of other types aren't matched.
+var Ecosystems = map[string]string{
+	"GoPackage":       "Go",
+	"CommonJSPackage": "npm",
+	"NPMPackage":      "npm",
+	"PipPackage":      "PyPI",
+	"python":          "PyPI",
+	"RubyGem":         "RubyGems",
+	"JavaArtifact":    "Maven",
+	"MavenArtifact":   "Maven",
+}
+
+// A DB is a set of advisories, indexed by the packages they affect.
+type DB struct {
+	// advisories maps ecosystems to package names to advisories.
+	advisories map[string]map[string][]*Advisory
+}
+
+// NewDB returns an empty DB.
+func NewDB() *DB {
+	return &DB{advisories: map[string]map[string][]*Advisory{}}
+}
+
+// Add adds an advisory to the DB.
+func (db *DB) Add(a *Advisory) {
+	seen := map[[2]string]bool{}
+	for _, aff := range a.Affected {
+		key := [2]string{aff.Package.Ecosystem, aff.Package.Name}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if db.advisories[key[0]] == nil {
+			db.advisories[key[0]] = map[string][]*Advisory{}
+		}
+		db.advisories[key[0]][key[1]] = append(db.advisories[key[0]][key[1]], a)
+	}
+}
+
+// Len returns the number of (package, advisory) pairs in the DB.
+func (db *DB) Len() int {
+	var n int
+	for _, pkgs := range db.advisories {
+		for _, as := range pkgs {
+			n += len(as)
+		}
+	}
+	return n
+}
+
+// ReadZip adds the advisories in a zip archive of OSV JSON files (such as
+// the OSV database's all.zip exports) to the DB.
+func (db *DB) ReadZip(file string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = db.read(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s in %s: %s", f.Name, file, err)
+		}
+	}
+	return nil
+}
+
+// ReadDir adds the advisories in the OSV JSON files in dir (and its
+// subdirectories) to the DB.
+func (db *DB) ReadDir(dir string) error {
+	return filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(file, ".json") {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := db.read(f); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		return nil
+	})
+}
+
+// maxAdvisorySize is the maximum size of an advisory file, in bytes.
+const maxAdvisorySize = 4 << 20
+
+func (db *DB) read(r io.Reader) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxAdvisorySize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxAdvisorySize {
+		return fmt.Errorf("advisory is larger than %d bytes", maxAdvisorySize)
+	}
+	var a *Advisory
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	if a == nil || a.ID == "" {
+		return fmt.Errorf("advisory has no ID")
+	}
+	db.Add(a)
+	return nil
+}
+
+// A Finding is a dependency on a version of a package that an advisory
+// affects.
+type Finding struct {
+	// Advisory is the advisory's ID, and Aliases are its other IDs (such
+	// as CVE IDs).
+	Advisory string
+	Aliases  []string `json:",omitempty"`
+	Summary  string   `json:",omitempty"`
+	Severity string   `json:",omitempty"`
+
+	// FromUnit and FromUnitType identify the source unit that has the
+	// dependency.
+	FromUnit     string
+	FromUnitType string
+
+	// Ecosystem, Package, and Version identify the affected package
+	// version that is depended on.
+	Ecosystem string
+	Package   string
+	Version   string
+
+	// Fixed is the version that fixed the vulnerability, if known.
+	Fixed string `json:",omitempty"`
+}
+
+// Match returns the findings for the dependencies in deps whose versions
+// are affected by advisories in the DB. Dependencies without a version
+// (ToVersionString) aren't matched.
+func (db *DB) Match(deps []*dep.ResolvedDep) []*Finding {
+	var findings []*Finding
+	seen := map[[4]string]bool{}
+	for _, d := range deps {
+		eco, ok := Ecosystems[d.ToUnitType]
+		if !ok || d.ToVersionString == "" {
+			continue
+		}
+		pkg, advisories := db.lookup(eco, d.ToUnit)
+		for _, a := range advisories {
+			affected, fixed := a.affects(eco, pkg, d.ToVersionString)
+			if !affected {
+				continue
+			}
+			key := [4]string{a.ID, d.FromUnitType + ":" + d.FromUnit, pkg, d.ToVersionString}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			findings = append(findings, &Finding{
+				Advisory:     a.ID,
+				Summary:      a.Summary,
+				Severity:     a.DatabaseSpecific.Severity,
+				FromUnit:     d.FromUnit,
+				FromUnitType: d.FromUnitType,
+				Ecosystem:    eco,
+				Package:      pkg,
+				Version:      d.ToVersionString,
+				Aliases:      a.Aliases,
+				Fixed:        fixed,
+			})
+		}
+	}
+	sort.Sort(findingsByUnit(findings))
+	return findings
+}
+
+// lookup returns the advisories for the package name in the ecosystem.
+// Go advisories are for modules, so dependencies on Go packages match the
+// advisories of the longest module path that contains the package.
+func (db *DB) lookup(ecosystem, name string) (string, []*Advisory) {
+	pkgs := db.advisories[ecosystem]
+	if ecosystem != "Go" {
+		return name, pkgs[name]
+	}
+	for p := name; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if as, ok := pkgs[p]; ok {
+			return p, as
+		}
+	}
+	return name, nil
+}
+
+// affects returns whether the advisory affects the version of the package,
+// and the version that fixed it (if known).
+func (a *Advisory) affects(ecosystem, pkg, version string) (bool, string) {
+	for _, aff := range a.Affected {
+		if aff.Package.Ecosystem != ecosystem || aff.Package.Name != pkg {
+			continue
+		}
+		for _, v := range aff.Versions {
+			if CompareVersions(v, version) == 0 {
+				return true, ""
+			}
+		}
+		for _, r := range aff.Ranges {
+			if affected, fixed := r.affects(version); affected {
+				return true, fixed
+			}
+		}
+	}
+	return false, ""
+}
+
+type findingsByUnit []*Finding
+
+func (v findingsByUnit) Len() int      { return len(v) }
+func (v findingsByUnit) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v findingsByUnit) Less(i, j int) bool {
+	a, b := v[i], v[j]
+	if a.FromUnitType != b.FromUnitType {
+		return a.FromUnitType < b.FromUnitType
+	}
+	if a.FromUnit != b.FromUnit {
+		return a.FromUnit < b.FromUnit
+	}
+	if a.Package != b.Package {
+		return a.Package < b.Package
+	}
+	return a.Advisory < b.Advisory
+}
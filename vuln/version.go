@@ -0,0 +1,112 @@
+package vuln
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two version strings, returning -1, 0, or 1 if
+// a is less than, equal to, or greater than b. It orders semantic versions
+// (with or without a leading "v") correctly, and approximates the ordering
+// of other dotted version schemes: versions are compared component by
+// component, numeric parts numerically and other parts lexically, and a
+// pre-release (such as "1.0.0-rc.1" or "1.0rc1") precedes its release.
+// Build metadata (after "+") is ignored.
+func CompareVersions(a, b string) int {
+	a, b = normalizeVersion(a), normalizeVersion(b)
+	if a == b {
+		return 0
+	}
+	ra, pa := splitPrerelease(a)
+	rb, pb := splitPrerelease(b)
+	if c := compareParts(versionParts(ra), versionParts(rb)); c != 0 {
+		return c
+	}
+	// A version without a pre-release is greater than one with one.
+	switch {
+	case pa == "" && pb == "":
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+	return compareParts(versionParts(pa), versionParts(pb))
+}
+
+func normalizeVersion(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.Index(v, "+"); i != -1 {
+		v = v[:i]
+	}
+	return v
+}
+
+// splitPrerelease splits a version into its release and pre-release parts
+// (e.g., "1.0.0-rc.1" and "1.0rc1" into "1.0.0" and "rc.1", and "1.0" and
+// "rc1").
+func splitPrerelease(v string) (release, prerelease string) {
+	if i := strings.Index(v, "-"); i != -1 {
+		return v[:i], v[i+1:]
+	}
+	for i, c := range v {
+		if c != '.' && (c < '0' || c > '9') {
+			return strings.TrimSuffix(v[:i], "."), v[i:]
+		}
+	}
+	return v, ""
+}
+
+// versionParts splits a version into its numeric and non-numeric parts,
+// separated by dots or by changes between digits and other characters.
+func versionParts(v string) []string {
+	var parts []string
+	for _, field := range strings.FieldsFunc(v, func(c rune) bool { return c == '.' || c == '-' || c == '_' }) {
+		start := 0
+		for i := 1; i <= len(field); i++ {
+			if i == len(field) || isDigit(field[i]) != isDigit(field[i-1]) {
+				parts = append(parts, field[start:i])
+				start = i
+			}
+		}
+	}
+	return parts
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// compareParts compares versions' parts. Missing parts are treated as 0, so
+// "1.0" equals "1.0.0".
+func compareParts(a, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		pa, pb := "0", "0"
+		if i < len(a) {
+			pa = a[i]
+		}
+		if i < len(b) {
+			pb = b[i]
+		}
+		na, errA := strconv.ParseUint(pa, 10, 64)
+		nb, errB := strconv.ParseUint(pb, 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case errA == nil:
+			// As in semantic versioning, numeric parts are less than
+			// non-numeric ones.
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(pa, pb); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,177 @@
+package vuln
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/dep"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-alpha.2", "1.0.0-alpha.10", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0+build1", "1.0.0", 0},
+		{"1.0rc1", "1.0", -1},
+	}
+	for _, test := range tests {
+		if got := CompareVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareVersions(%q, %q): got %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := CompareVersions(test.b, test.a); got != -test.want {
+			t.Errorf("CompareVersions(%q, %q): got %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestRange_affects(t *testing.T) {
+	r := &Range{Type: "SEMVER", Events: []*Event{
+		{Fixed: "1.2.0"},
+		{Introduced: "0"},
+		{Introduced: "2.0.0"},
+		{LastAffected: "2.1.0"},
+	}}
+	tests := []struct {
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{"0.1.0", true, "1.2.0"},
+		{"1.1.9", true, "1.2.0"},
+		{"1.2.0", false, ""},
+		{"1.9.0", false, ""},
+		{"2.0.0", true, ""},
+		{"2.1.0", true, ""},
+		{"2.1.1", false, ""},
+	}
+	for _, test := range tests {
+		affected, fixed := r.affects(test.version)
+		if affected != test.affected || fixed != test.fixed {
+			t.Errorf("%s: got (%v, %q), want (%v, %q)", test.version, affected, fixed, test.affected, test.fixed)
+		}
+	}
+
+	if affected, _ := (&Range{Type: "GIT", Events: []*Event{{Introduced: "0"}}}).affects("1.0.0"); affected {
+		t.Error("GIT range: got affected, want unsupported range to not match")
+	}
+}
+
+func testAdvisories() []*Advisory {
+	a1 := &Advisory{ID: "GO-1", Aliases: []string{"CVE-1"}, Summary: "s1"}
+	a1.Affected = []*Affected{{Ranges: []*Range{{Type: "SEMVER", Events: []*Event{{Introduced: "0"}, {Fixed: "1.5.0"}}}}}}
+	a1.Affected[0].Package.Ecosystem, a1.Affected[0].Package.Name = "Go", "example.com/m"
+
+	a2 := &Advisory{ID: "GHSA-2"}
+	a2.DatabaseSpecific.Severity = "HIGH"
+	a2.Affected = []*Affected{{Versions: []string{"2.0.0", "2.0.1"}}}
+	a2.Affected[0].Package.Ecosystem, a2.Affected[0].Package.Name = "npm", "left-pad"
+
+	return []*Advisory{a1, a2}
+}
+
+func TestDB_Match(t *testing.T) {
+	db := NewDB()
+	for _, a := range testAdvisories() {
+		db.Add(a)
+	}
+
+	deps := []*dep.ResolvedDep{
+		{FromUnit: "b", FromUnitType: "GoPackage", ToUnit: "example.com/m/sub/pkg", ToUnitType: "GoPackage", ToVersionString: "v1.4.0"},
+		{FromUnit: "a", FromUnitType: "GoPackage", ToUnit: "example.com/m", ToUnitType: "GoPackage", ToVersionString: "v1.4.0"},
+		{FromUnit: "a", FromUnitType: "GoPackage", ToUnit: "example.com/m", ToUnitType: "GoPackage", ToVersionString: "v1.4.0"},
+		{FromUnit: "a", FromUnitType: "GoPackage", ToUnit: "example.com/mm", ToUnitType: "GoPackage", ToVersionString: "v1.4.0"},
+		{FromUnit: "c", FromUnitType: "GoPackage", ToUnit: "example.com/m", ToUnitType: "GoPackage", ToVersionString: "v1.5.0"},
+		{FromUnit: "d", FromUnitType: "GoPackage", ToUnit: "example.com/m", ToUnitType: "GoPackage"},
+		{FromUnit: "e", FromUnitType: "NPMPackage", ToUnit: "left-pad", ToUnitType: "NPMPackage", ToVersionString: "2.0.1"},
+		{FromUnit: "e", FromUnitType: "NPMPackage", ToUnit: "left-pad", ToUnitType: "NPMPackage", ToVersionString: "2.0.2"},
+	}
+	want := []*Finding{
+		{Advisory: "GO-1", Aliases: []string{"CVE-1"}, Summary: "s1", FromUnit: "a", FromUnitType: "GoPackage", Ecosystem: "Go", Package: "example.com/m", Version: "v1.4.0", Fixed: "1.5.0"},
+		{Advisory: "GO-1", Aliases: []string{"CVE-1"}, Summary: "s1", FromUnit: "b", FromUnitType: "GoPackage", Ecosystem: "Go", Package: "example.com/m", Version: "v1.4.0", Fixed: "1.5.0"},
+		{Advisory: "GHSA-2", Severity: "HIGH", FromUnit: "e", FromUnitType: "NPMPackage", Ecosystem: "npm", Package: "left-pad", Version: "2.0.1"},
+	}
+	if got := db.Match(deps); !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("got findings\n%s\nwant %d findings", gotJSON, len(want))
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	var zipData bytes.Buffer
+	zw := zip.NewWriter(&zipData)
+	for _, a := range testAdvisories()[:1] {
+		w, err := zw.Create(a.ID + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.NewEncoder(w).Encode(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/Go/all.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(zipData.Bytes())
+	}))
+	defer srv.Close()
+
+	tmpdir, err := ioutil.TempDir("", "srclib-vuln-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	c := &Cache{Dir: tmpdir, URL: srv.URL, Client: srv.Client()}
+	if _, err := (&Cache{Dir: tmpdir, URL: srv.URL, Offline: true}).Load([]string{"Go"}); err == nil {
+		t.Error("offline with nothing cached: got no error")
+	}
+	db, err := c.Load([]string{"Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 1 {
+		t.Errorf("got %d advisories, want 1", db.Len())
+	}
+	if _, err := os.Stat(filepath.Join(tmpdir, "Go", "all.zip")); err != nil {
+		t.Error(err)
+	}
+
+	// The cached export is used (with MaxAge 0, it's never downloaded
+	// again).
+	if _, err := c.Load([]string{"Go"}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+
+	if _, err := c.Load([]string{"npm"}); err == nil {
+		t.Error("missing export: got no error")
+	}
+}
@@ -0,0 +1,498 @@
+package workqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+	"sourcegraph.com/sourcegraph/srclib/network"
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// NewHandler returns an HTTP handler that serves q to workers. Build data
+// uploaded by workers is stored in store.
+//
+// The endpoints are:
+//
+//	POST /tasks      add tasks (request body is a JSON array of Tasks)
+//	POST /lease      lease a task (responds with 204 if none are pending)
+//	POST /complete   mark a leased task as done
+//	POST /fail       report that a leased task failed
+//	GET  /status          list all tasks and their states
+//	PUT  /artifacts       upload a build data file for a leased task, or
+//	                      (with a blob hash) link it to an uploaded blob, or
+//	                      (with a path ending in .delta) upload its delta
+//	                      against the file at the task's parent commit
+//	GET  /artifacts       list a commit's build data files, or (with a
+//	                      path) download one
+//	POST /blobs/missing   list which of the given blob hashes are missing
+//	PUT  /blobs           upload a blob for a leased task
+//
+// Build data files are stored by content (see buildstore.BlobRefPath), so
+// workers need only upload the blobs that the coordinator is missing, and
+// identical files are stored once. Files that changed little since the
+// parent commit may be uploaded as deltas (see buildstore.Delta).
+func NewHandler(q *Queue, store *buildstore.MultiStore) http.Handler {
+	h := &handler{q: q, store: store}
+	m := http.NewServeMux()
+	m.HandleFunc("/tasks", h.serveAdd)
+	m.HandleFunc("/lease", h.serveLease)
+	m.HandleFunc("/complete", h.serveComplete)
+	m.HandleFunc("/fail", h.serveFail)
+	m.HandleFunc("/status", h.serveStatus)
+	m.HandleFunc("/artifacts", h.serveArtifacts)
+	m.HandleFunc("/blobs/missing", h.serveMissingBlobs)
+	m.HandleFunc("/blobs", h.serveUploadBlob)
+	return m
+}
+
+type handler struct {
+	q     *Queue
+	store *buildstore.MultiStore
+}
+
+type leaseRequest struct {
+	Worker string
+}
+
+type taskRequest struct {
+	Worker string
+	TaskID string
+	Error  string `json:",omitempty"`
+}
+
+func (h *handler) serveAdd(w http.ResponseWriter, r *http.Request) {
+	var tasks []*Task
+	if !decodeRequest(w, r, "POST", &tasks) {
+		return
+	}
+	writeJSON(w, map[string]int{"Added": h.q.Add(tasks...)})
+}
+
+func (h *handler) serveLease(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if !decodeRequest(w, r, "POST", &req) {
+		return
+	}
+	t := h.q.Lease(req.Worker)
+	if t == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, t)
+}
+
+func (h *handler) serveComplete(w http.ResponseWriter, r *http.Request) {
+	var req taskRequest
+	if !decodeRequest(w, r, "POST", &req) {
+		return
+	}
+	writeTaskError(w, h.q.Complete(req.Worker, req.TaskID))
+}
+
+func (h *handler) serveFail(w http.ResponseWriter, r *http.Request) {
+	var req taskRequest
+	if !decodeRequest(w, r, "POST", &req) {
+		return
+	}
+	writeTaskError(w, h.q.Fail(req.Worker, req.TaskID, req.Error))
+}
+
+func (h *handler) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, h.q.Status())
+}
+
+func (h *handler) serveArtifacts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		if r.URL.Query().Get("path") != "" {
+			h.serveGetArtifact(w, r)
+		} else {
+			h.serveListArtifacts(w, r)
+		}
+	case "PUT":
+		h.serveUploadArtifact(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) serveListArtifacts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	repoStore, commitID, ok := h.commitStore(w, query)
+	if !ok {
+		return
+	}
+	files, err := repoStore.StoredFiles(commitID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, files)
+}
+
+func (h *handler) serveGetArtifact(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	repoStore, commitID, ok := h.commitStore(w, query)
+	if !ok {
+		return
+	}
+	filePath := repoStore.FilePath(commitID, path.Clean("/" + query.Get("path"))[1:])
+	f, err := repoStore.OpenStored(filePath)
+	if os.IsNotExist(err) {
+		// Files stored as deltas are listed as the files they describe, so
+		// serve those reconstructed.
+		if _, err2 := repoStore.Stat(buildstore.DeltaPath(filePath)); err2 == nil {
+			f, err = repoStore.Open(filePath)
+		}
+	}
+	if os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.Copy(w, f)
+}
+
+// commitStore returns the store of the repository named by the "repo" query
+// parameter, and the commit named by the "commit" parameter. If either is
+// missing, it responds with an error and returns false.
+func (h *handler) commitStore(w http.ResponseWriter, query url.Values) (*buildstore.RepositoryStore, string, bool) {
+	repoURI, commitID := query.Get("repo"), query.Get("commit")
+	if repoURI == "" || commitID == "" || strings.Contains(commitID, "/") {
+		http.Error(w, "repo and commit must be given", http.StatusBadRequest)
+		return nil, "", false
+	}
+	repoStore, err := h.store.RepositoryStore(repo.URI(repoURI))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, "", false
+	}
+	return repoStore, commitID, true
+}
+
+func (h *handler) serveUploadArtifact(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	t, err := h.q.Leased(query.Get("worker"), query.Get("task"))
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+
+	filePath := path.Clean("/" + query.Get("path"))[1:]
+	if filePath == "" {
+		http.Error(w, "no artifact path given", http.StatusBadRequest)
+		return
+	}
+
+	repoStore, err := h.store.RepositoryStore(repo.URI(t.RepoURI))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dst := repoStore.FilePath(t.CommitID, filePath)
+	if err := rwvfs.MkdirAll(repoStore, filepath.Dir(dst)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if hash := query.Get("blob"); hash != "" {
+		if err := repoStore.LinkBlob(dst, hash); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dst = buildstore.BlobRefPath(dst)
+	} else if strings.HasSuffix(filePath, ".delta") {
+		var d *buildstore.Delta
+		if err := json.NewDecoder(io.LimitReader(r.Body, buildstore.MaxDeltaFileSize)).Decode(&d); err != nil || d == nil {
+			http.Error(w, fmt.Sprintf("invalid delta: %v", err), http.StatusBadRequest)
+			return
+		}
+		dst, err = repoStore.PutDelta(t.CommitID, strings.TrimSuffix(filePath, ".delta"), d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		f, err := repoStore.Create(dst)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := io.Copy(f, r.Body); err != nil {
+			f.Close()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := f.Close(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Remove the file's previous upload, if it was stored in another form
+	// (chunked, compressed, or as a delta) than this one, so that readers don't see
+	// stale data (see buildstore.StoredPaths).
+	if path, ok := buildstore.DataFilePath(dst); ok {
+		for _, stale := range buildstore.StoredPaths(path) {
+			if stale == dst {
+				continue
+			}
+			if err := repoStore.Remove(stale); err != nil && !os.IsNotExist(err) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) serveMissingBlobs(w http.ResponseWriter, r *http.Request) {
+	var hashes []string
+	if !decodeRequest(w, r, "POST", &hashes) {
+		return
+	}
+	blobs, err := h.store.Blobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	missing := []string{}
+	for _, hash := range hashes {
+		has, err := blobs.Has(hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !has {
+			missing = append(missing, hash)
+		}
+	}
+	writeJSON(w, missing)
+}
+
+func (h *handler) serveUploadBlob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	if _, err := h.q.Leased(query.Get("worker"), query.Get("task")); err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	blobs, err := h.store.Blobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := blobs.Put(query.Get("hash"), r.Body, r.ContentLength); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, method string, v interface{}) bool {
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeTaskError(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case err == ErrNotLeased:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
+// A Client talks to a coordinator's work queue over HTTP.
+type Client struct {
+	// URL is the base URL of the coordinator (e.g., "http://localhost:7070").
+	URL string
+
+	// HTTPClient is the HTTP client to use. If nil, network.Client is
+	// used.
+	HTTPClient *http.Client
+}
+
+// Add adds tasks to the queue and returns the number that were added (i.e.,
+// that weren't already in the queue).
+func (c *Client) Add(tasks []*Task) (int, error) {
+	var resp struct{ Added int }
+	if err := c.do("POST", "/tasks", tasks, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Added, nil
+}
+
+// Lease leases a task for worker. If there are no pending tasks, it returns
+// nil.
+func (c *Client) Lease(worker string) (*Task, error) {
+	var t *Task
+	if err := c.do("POST", "/lease", leaseRequest{Worker: worker}, &t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Complete marks a leased task as done.
+func (c *Client) Complete(worker, taskID string) error {
+	return c.do("POST", "/complete", taskRequest{Worker: worker, TaskID: taskID}, nil)
+}
+
+// Fail reports that a leased task failed.
+func (c *Client) Fail(worker, taskID string, taskErr error) error {
+	return c.do("POST", "/fail", taskRequest{Worker: worker, TaskID: taskID, Error: taskErr.Error()}, nil)
+}
+
+// Status lists all tasks and their states.
+func (c *Client) Status() ([]*TaskStatus, error) {
+	var statuses []*TaskStatus
+	if err := c.do("GET", "/status", nil, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// UploadArtifact uploads a build data file for a leased task. The path is
+// relative to the build data directory of the task's commit.
+func (c *Client) UploadArtifact(worker, taskID, artifactPath string, data io.Reader) error {
+	q := url.Values{"worker": {worker}, "task": {taskID}, "path": {filepath.ToSlash(artifactPath)}}
+	return c.doRaw("PUT", "/artifacts?"+q.Encode(), data, nil)
+}
+
+// LinkArtifact stores a build data file for a leased task as a reference
+// to the blob named by hash, which must have been uploaded (with
+// UploadBlob) already. The path is relative to the build data directory of
+// the task's commit.
+func (c *Client) LinkArtifact(worker, taskID, artifactPath, hash string) error {
+	q := url.Values{"worker": {worker}, "task": {taskID}, "path": {filepath.ToSlash(artifactPath)}, "blob": {hash}}
+	return c.doRaw("PUT", "/artifacts?"+q.Encode(), nil, nil)
+}
+
+// MissingBlobs returns the hashes of the blobs in hashes that the
+// coordinator doesn't have.
+func (c *Client) MissingBlobs(hashes []string) ([]string, error) {
+	var missing []string
+	if err := c.do("POST", "/blobs/missing", hashes, &missing); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// UploadBlob uploads a blob (whose contents, read from data, must have the
+// given hash) for a leased task.
+func (c *Client) UploadBlob(worker, taskID, hash string, data io.Reader) error {
+	q := url.Values{"worker": {worker}, "task": {taskID}, "hash": {hash}}
+	return c.doRaw("PUT", "/blobs?"+q.Encode(), data, nil)
+}
+
+// ListArtifacts lists the build data files that the coordinator has for a
+// commit.
+func (c *Client) ListArtifacts(repoURI, commitID string) ([]*buildstore.StoredFileInfo, error) {
+	q := url.Values{"repo": {repoURI}, "commit": {commitID}}
+	var artifacts []*buildstore.StoredFileInfo
+	if err := c.do("GET", "/artifacts?"+q.Encode(), nil, &artifacts); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// GetArtifact downloads a build data file of a commit. The path is one of
+// those returned by ListArtifacts. The caller must close the returned
+// reader.
+func (c *Client) GetArtifact(repoURI, commitID, artifactPath string) (io.ReadCloser, error) {
+	q := url.Values{"repo": {repoURI}, "commit": {commitID}, "path": {artifactPath}}
+	resp, err := c.send("GET", "/artifacts?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) do(method, path string, body, result interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	return c.doRaw(method, path, r, result)
+}
+
+func (c *Client) doRaw(method, path string, body io.Reader, result interface{}) error {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// send sends a request to the coordinator and returns its response, or an
+// error if it isn't successful.
+func (c *Client) send(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = network.Client()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		return nil, ErrNotLeased
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
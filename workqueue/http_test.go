@@ -0,0 +1,143 @@
+package workqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/rwvfs"
+	"sourcegraph.com/sourcegraph/srclib/buildstore"
+)
+
+func TestHandler_blobs(t *testing.T) {
+	files := make(map[string]string)
+	srv := httptest.NewServer(NewHandler(NewQueue(), buildstore.New(rwvfs.Map(files))))
+	defer srv.Close()
+	cl := &Client{URL: srv.URL}
+
+	if _, err := cl.Add([]*Task{{RepoURI: "r", CommitID: "c", UnitName: "u", UnitType: "t"}}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := cl.Lease("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const data = "0123456789"
+	hash, err := buildstore.HashBlob(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing, err := cl.MissingBlobs([]string{hash}); err != nil || !reflect.DeepEqual(missing, []string{hash}) {
+		t.Errorf("got missing blobs %v (error %v), want %v", missing, err, []string{hash})
+	}
+	if err := cl.LinkArtifact("w", task.ID, "f", hash); err == nil {
+		t.Error("got no error linking to a missing blob")
+	}
+	if err := cl.UploadBlob("w2", task.ID, hash, strings.NewReader(data)); err != ErrNotLeased {
+		t.Errorf("got error %v uploading a blob for another worker's task, want ErrNotLeased", err)
+	}
+	if err := cl.UploadBlob("w", task.ID, hash, strings.NewReader("x")); err == nil {
+		t.Error("got no error uploading a blob with the wrong hash")
+	}
+	if err := cl.UploadBlob("w", task.ID, hash, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if missing, err := cl.MissingBlobs([]string{hash}); err != nil || len(missing) != 0 {
+		t.Errorf("got missing blobs %v (error %v), want none", missing, err)
+	}
+
+	// A file that was uploaded directly is replaced by a link to a blob.
+	if err := cl.UploadArtifact("w", task.ID, "f", strings.NewReader("old")); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"f", "g"} {
+		if err := cl.LinkArtifact("w", task.ID, path, hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, present := files["r/c/f"]; present {
+		t.Error("got stale upload of f, want it removed")
+	}
+
+	artifacts, err := cl.ListArtifacts("r", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []buildstore.StoredFileInfo
+	for _, a := range artifacts {
+		got = append(got, *a)
+	}
+	want := []buildstore.StoredFileInfo{{Path: "f", Hash: hash, Size: 10}, {Path: "g", Hash: hash, Size: 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got artifacts %+v, want %+v", got, want)
+	}
+	r, err := cl.GetArtifact("r", "c", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != data {
+		t.Errorf("got artifact %q (error %v), want %q", b, err, data)
+	}
+	if _, err := cl.GetArtifact("r", "c", "h"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got error %v getting a missing artifact, want 404", err)
+	}
+}
+
+func TestHandler_delta(t *testing.T) {
+	files := map[string]string{"r/c1/f": "a\nb\nc\nd\ne\nf\n"}
+	srv := httptest.NewServer(NewHandler(NewQueue(), buildstore.New(rwvfs.Map(files))))
+	defer srv.Close()
+	cl := &Client{URL: srv.URL}
+
+	if _, err := cl.Add([]*Task{{RepoURI: "r", CommitID: "c2", UnitName: "u", UnitType: "t"}}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := cl.Lease("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const data = "a\nb\nc\nd\nX\nf\n"
+	d := buildstore.NewDelta([]byte(files["r/c1/f"]), []byte(data))
+	d.BaseCommit = "c1"
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.UploadArtifact("w", task.ID, "f", strings.NewReader("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.UploadArtifact("w", task.ID, "f.delta", strings.NewReader("{")); err == nil {
+		t.Error("got no error uploading an invalid delta")
+	}
+	if err := cl.UploadArtifact("w", task.ID, "f.delta", bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if _, present := files["r/c2/f"]; present {
+		t.Error("got stale upload of f, want it removed")
+	}
+
+	artifacts, err := cl.ListArtifacts("r", "c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 || *artifacts[0] != (buildstore.StoredFileInfo{Path: "f", Hash: d.Hash, Size: int64(len(data))}) {
+		t.Errorf("got artifacts %v, want only f", artifacts)
+	}
+	r, err := cl.GetArtifact("r", "c2", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(got) != data {
+		t.Errorf("got artifact %q (error %v), want %q", got, err, data)
+	}
+}
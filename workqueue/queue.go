@@ -0,0 +1,242 @@
+// Package workqueue distributes the analysis of source units across many
+// machines. A coordinator holds a Queue of tasks (one per source unit of a
+// repository commit) and serves it over HTTP; workers lease tasks, run the
+// toolchains, and upload the resulting build data to the coordinator.
+package workqueue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Task is a request to build the data for a single source unit.
+type Task struct {
+	// ID uniquely identifies the task. It is derived from the other fields
+	// (see TaskID), so adding the same task twice is a no-op.
+	ID string
+
+	RepoURI  string
+	CloneURL string
+	CommitID string
+	UnitName string
+	UnitType string
+
+	// Attempts is the number of times the task has been leased.
+	Attempts int
+}
+
+// TaskID returns the ID of the task that builds the given source unit.
+func TaskID(repoURI, commitID, unitName, unitType string) string {
+	return fmt.Sprintf("%s@%s/%s/%s", repoURI, commitID, unitType, unitName)
+}
+
+// TaskState is the state of a task in a Queue.
+type TaskState string
+
+const (
+	Pending TaskState = "pending"
+	Leased  TaskState = "leased"
+	Done    TaskState = "done"
+	Failed  TaskState = "failed"
+)
+
+// TaskStatus describes a task and its state.
+type TaskStatus struct {
+	*Task
+	State TaskState
+
+	// Worker is the worker that currently holds (or last held) the task's
+	// lease.
+	Worker string `json:",omitempty"`
+
+	// LeaseExpires is when the current lease expires, if the task is leased.
+	LeaseExpires time.Time `json:",omitempty"`
+
+	// Error is the error from the most recent failed attempt.
+	Error string `json:",omitempty"`
+}
+
+// ErrNotLeased is returned when a worker tries to complete or fail a task
+// whose lease it doesn't hold (e.g., because the lease expired and the task
+// was given to another worker).
+var ErrNotLeased = errors.New("task is not leased by this worker")
+
+// A Queue holds tasks and hands them out to workers. It is safe for
+// concurrent use.
+type Queue struct {
+	// MaxAttempts is the number of times a task is attempted before it is
+	// marked as failed.
+	MaxAttempts int
+
+	// LeaseDuration is how long a worker has to complete a task before the
+	// task is given to another worker.
+	LeaseDuration time.Duration
+
+	mu      sync.Mutex
+	tasks   map[string]*TaskStatus
+	pending []string // IDs of pending tasks, in FIFO order
+
+	now func() time.Time // for testing
+}
+
+// NewQueue creates a new, empty queue.
+func NewQueue() *Queue {
+	return &Queue{
+		MaxAttempts:   3,
+		LeaseDuration: 30 * time.Minute,
+		tasks:         make(map[string]*TaskStatus),
+		now:           time.Now,
+	}
+}
+
+// Add adds tasks to the queue (setting their IDs) and returns the number
+// that were added. Tasks that are already in the queue (in any state except
+// Failed) are skipped; failed tasks are retried.
+func (q *Queue) Add(tasks ...*Task) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var n int
+	for _, t := range tasks {
+		t.ID = TaskID(t.RepoURI, t.CommitID, t.UnitName, t.UnitType)
+		if s, present := q.tasks[t.ID]; present && s.State != Failed {
+			continue
+		}
+		t.Attempts = 0
+		q.tasks[t.ID] = &TaskStatus{Task: t, State: Pending}
+		q.pending = append(q.pending, t.ID)
+		n++
+	}
+	return n
+}
+
+// Lease gives the next pending task to worker. If there are no pending
+// tasks, it returns nil.
+func (q *Queue) Lease(worker string) *Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expireLeases()
+	for len(q.pending) > 0 {
+		id := q.pending[0]
+		q.pending = q.pending[1:]
+
+		s := q.tasks[id]
+		if s.State != Pending {
+			continue
+		}
+		s.State = Leased
+		s.Worker = worker
+		s.LeaseExpires = q.now().Add(q.LeaseDuration)
+		s.Attempts++
+		t := *s.Task
+		return &t
+	}
+	return nil
+}
+
+// Complete marks the task as done.
+func (q *Queue) Complete(worker, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s, err := q.leased(worker, id)
+	if err != nil {
+		return err
+	}
+	s.State = Done
+	s.LeaseExpires = time.Time{}
+	s.Error = ""
+	return nil
+}
+
+// Fail records that the worker failed to build the task. The task is retried
+// unless it has already been attempted MaxAttempts times.
+func (q *Queue) Fail(worker, id string, taskErr string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s, err := q.leased(worker, id)
+	if err != nil {
+		return err
+	}
+	s.Error = taskErr
+	q.retryOrFail(s)
+	return nil
+}
+
+// Leased returns the task if worker holds its lease, and ErrNotLeased
+// otherwise.
+func (q *Queue) Leased(worker, id string) (*Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s, err := q.leased(worker, id)
+	if err != nil {
+		return nil, err
+	}
+	t := *s.Task
+	return &t, nil
+}
+
+// Status returns the status of all tasks, sorted by ID.
+func (q *Queue) Status() []*TaskStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expireLeases()
+	statuses := make([]*TaskStatus, 0, len(q.tasks))
+	for _, s := range q.tasks {
+		s2 := *s
+		t := *s.Task
+		s2.Task = &t
+		statuses = append(statuses, &s2)
+	}
+	sort.Sort(statusesByID(statuses))
+	return statuses
+}
+
+func (q *Queue) leased(worker, id string) (*TaskStatus, error) {
+	q.expireLeases()
+	s, present := q.tasks[id]
+	if !present {
+		return nil, fmt.Errorf("no such task: %q", id)
+	}
+	if s.State != Leased || s.Worker != worker {
+		return nil, ErrNotLeased
+	}
+	return s, nil
+}
+
+// expireLeases returns tasks whose leases have expired to the queue. The
+// caller must hold q.mu.
+func (q *Queue) expireLeases() {
+	now := q.now()
+	for _, s := range q.tasks {
+		if s.State == Leased && now.After(s.LeaseExpires) {
+			s.Error = fmt.Sprintf("lease held by worker %q expired", s.Worker)
+			q.retryOrFail(s)
+		}
+	}
+}
+
+// retryOrFail requeues the leased task s, or marks it as failed if it has no
+// attempts left. The caller must hold q.mu.
+func (q *Queue) retryOrFail(s *TaskStatus) {
+	s.LeaseExpires = time.Time{}
+	if s.Attempts >= q.MaxAttempts {
+		s.State = Failed
+		return
+	}
+	s.State = Pending
+	q.pending = append(q.pending, s.ID)
+}
+
+type statusesByID []*TaskStatus
+
+func (v statusesByID) Len() int           { return len(v) }
+func (v statusesByID) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v statusesByID) Less(i, j int) bool { return v[i].ID < v[j].ID }
@@ -0,0 +1,72 @@
+package workqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue()
+	q.MaxAttempts = 2
+
+	now := time.Unix(0, 0)
+	q.now = func() time.Time { return now }
+
+	newTasks := func() []*Task {
+		return []*Task{
+			{RepoURI: "r", CommitID: "c", UnitName: "a", UnitType: "t"},
+			{RepoURI: "r", CommitID: "c", UnitName: "b", UnitType: "t"},
+		}
+	}
+	if n := q.Add(newTasks()...); n != 2 {
+		t.Errorf("got %d tasks added, want 2", n)
+	}
+	if n := q.Add(newTasks()...); n != 0 {
+		t.Errorf("got %d duplicate tasks added, want 0", n)
+	}
+
+	a := q.Lease("w1")
+	if a == nil || a.UnitName != "a" {
+		t.Fatalf("got leased task %+v, want unit a", a)
+	}
+	if err := q.Complete("w2", a.ID); err != ErrNotLeased {
+		t.Errorf("got err %v completing another worker's task, want ErrNotLeased", err)
+	}
+	if err := q.Complete("w1", a.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail b once; it should be retried.
+	b := q.Lease("w1")
+	if b == nil || b.UnitName != "b" {
+		t.Fatalf("got leased task %+v, want unit b", b)
+	}
+	if err := q.Fail("w1", b.ID, "oops"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the retry's lease expire; it should then be failed for good.
+	if b = q.Lease("w2"); b == nil || b.Attempts != 2 {
+		t.Fatalf("got leased task %+v, want retry of unit b", b)
+	}
+	now = now.Add(q.LeaseDuration + time.Second)
+	if t2 := q.Lease("w3"); t2 != nil {
+		t.Errorf("got leased task %+v, want none", t2)
+	}
+	if err := q.Complete("w2", b.ID); err != ErrNotLeased {
+		t.Errorf("got err %v completing task with expired lease, want ErrNotLeased", err)
+	}
+
+	states := map[string]TaskState{}
+	for _, s := range q.Status() {
+		states[s.UnitName] = s.State
+	}
+	if want := (map[string]TaskState{"a": Done, "b": Failed}); states["a"] != want["a"] || states["b"] != want["b"] {
+		t.Errorf("got states %v, want %v", states, want)
+	}
+
+	// Failed tasks may be added again.
+	if n := q.Add(newTasks()...); n != 1 {
+		t.Errorf("got %d tasks added after failure, want 1", n)
+	}
+}
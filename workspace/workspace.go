@@ -0,0 +1,158 @@
+// Package workspace reads workspace manifests. A workspace is a set of
+// repository roots that are checked out side by side (such as an app and
+// the libraries that it depends on) and that are built and queried
+// together: refs from one root to defs in another are resolved using the
+// other root's working tree and build data, instead of a clone of the
+// other repository.
+//
+// A workspace manifest is a JSON file (usually named Srcworkspace) such as:
+//
+//	{
+//	  "Roots": [
+//	    {"Dir": "app"},
+//	    {"Dir": "../lib", "Repo": "github.com/alice/lib"}
+//	  ]
+//	}
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/srclib/repo"
+)
+
+// Filename is the usual name of a workspace manifest, which Find looks
+// for.
+const Filename = "Srcworkspace"
+
+// Env is the environment variable that `src workspace make` sets to the
+// manifest's path, so that the processes of each root's build know about
+// the other roots.
+const Env = "SRCLIB_WORKSPACE"
+
+// A Workspace is a set of repository roots.
+type Workspace struct {
+	// Roots are the repository roots, in the order that they are built.
+	Roots []*Root
+
+	// File is the absolute path of the manifest that the workspace was
+	// read from.
+	File string `json:"-"`
+}
+
+// A Root is a repository root in a workspace.
+type Root struct {
+	// Dir is the root directory of the repository's working tree. In the
+	// manifest, it is relative to the manifest's directory (unless it is
+	// absolute); Read makes it absolute.
+	Dir string
+
+	// Repo is the URI of the repository. If it's empty, commands that read
+	// the workspace determine it from the repository's VCS remote (as for
+	// a single repository).
+	Repo repo.URI `json:",omitempty"`
+}
+
+// Read reads the workspace manifest in file.
+func Read(file string) (*Workspace, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var w *Workspace
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if w == nil || len(w.Roots) == 0 {
+		return nil, fmt.Errorf("%s: workspace has no roots", file)
+	}
+	w.File = file
+
+	dirs := make(map[string]bool, len(w.Roots))
+	repos := make(map[repo.URI]bool, len(w.Roots))
+	for _, r := range w.Roots {
+		if r == nil || r.Dir == "" {
+			return nil, fmt.Errorf("%s: workspace root has no Dir", file)
+		}
+		if !filepath.IsAbs(r.Dir) {
+			r.Dir = filepath.Join(filepath.Dir(file), filepath.FromSlash(r.Dir))
+		}
+		r.Dir = filepath.Clean(r.Dir)
+		if dirs[r.Dir] {
+			return nil, fmt.Errorf("%s: workspace has root %s more than once", file, r.Dir)
+		}
+		dirs[r.Dir] = true
+		if r.Repo != "" {
+			if repos[r.Repo] {
+				return nil, fmt.Errorf("%s: workspace has more than one root of repository %s", file, r.Repo)
+			}
+			repos[r.Repo] = true
+		}
+	}
+	return w, nil
+}
+
+// Find returns the path of the workspace manifest named Filename in dir or
+// the nearest of its parents, or "" if there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		file := filepath.Join(dir, Filename)
+		if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+			return file, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Root returns the root of the repository with the given URI, or nil if
+// it's not in the workspace.
+func (w *Workspace) Root(uri repo.URI) *Root {
+	for _, r := range w.Roots {
+		if r.Repo == uri {
+			return r
+		}
+	}
+	return nil
+}
+
+// RootAt returns the root whose directory is dir (an absolute path), or
+// nil if there is none.
+func (w *Workspace) RootAt(dir string) *Root {
+	dir = filepath.Clean(dir)
+	for _, r := range w.Roots {
+		if r.Dir == dir {
+			return r
+		}
+	}
+	return nil
+}
+
+// Dirs returns a map from the URIs of the roots' repositories (for those
+// whose Repo is set) to their directories.
+func (w *Workspace) Dirs() map[repo.URI]string {
+	dirs := make(map[repo.URI]string, len(w.Roots))
+	for _, r := range w.Roots {
+		if r.Repo != "" {
+			dirs[r.Repo] = r.Dir
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,74 @@
+package workspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	wsDir := filepath.Join(tmpDir, "ws")
+	if err := os.MkdirAll(filepath.Join(wsDir, "app", "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(data string) string {
+		file := filepath.Join(wsDir, Filename)
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	file := write(`{"Roots": [{"Dir": "app"}, {"Dir": "../lib", "Repo": "example.com/lib"}]}`)
+	w, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.File != file || len(w.Roots) != 2 {
+		t.Fatalf("got workspace %+v", w)
+	}
+	if want := filepath.Join(wsDir, "app"); w.Roots[0].Dir != want {
+		t.Errorf("got root dir %q, want %q", w.Roots[0].Dir, want)
+	}
+	if want := filepath.Join(tmpDir, "lib"); w.Roots[1].Dir != want {
+		t.Errorf("got root dir %q, want %q", w.Roots[1].Dir, want)
+	}
+	if r := w.Root("example.com/lib"); r != w.Roots[1] {
+		t.Errorf("got root %+v of example.com/lib", r)
+	}
+	if r := w.RootAt(filepath.Join(wsDir, "app") + "/"); r != w.Roots[0] {
+		t.Errorf("got root %+v at app", r)
+	}
+	if dirs := w.Dirs(); len(dirs) != 1 || dirs["example.com/lib"] != w.Roots[1].Dir {
+		t.Errorf("got dirs %v", dirs)
+	}
+
+	// Find looks in the directory and its parents.
+	for _, dir := range []string{wsDir, filepath.Join(wsDir, "app", "sub")} {
+		if got, err := Find(dir); err != nil || got != file {
+			t.Errorf("Find(%q): got %q (error %v), want %q", dir, got, err, file)
+		}
+	}
+	if got, err := Find(tmpDir); err != nil || got != "" {
+		t.Errorf("Find(%q): got %q (error %v), want none", tmpDir, got, err)
+	}
+
+	for _, data := range []string{
+		`{}`,
+		`{"Roots": [{"Repo": "r"}]}`,
+		`{"Roots": [{"Dir": "a"}, {"Dir": "a/"}]}`,
+		`{"Roots": [{"Dir": "a", "Repo": "r"}, {"Dir": "b", "Repo": "r"}]}`,
+		`[`,
+	} {
+		if w, err := Read(write(data)); err == nil {
+			t.Errorf("%s: got workspace %+v, want error", data, w)
+		}
+	}
+}